@@ -1,7 +1,6 @@
 package boredmodule
 
 import (
-	"fmt"
 	"time"
 
 	bot "../sweetiebot"
@@ -44,7 +43,7 @@ func (w *BoredModule) OnIdle(info *bot.GuildInfo, c *discordgo.Channel, t time.T
 			},
 			Timestamp: discordgo.Timestamp(t.Format(time.RFC3339Nano)),
 		}
-		fmt.Println("Sending bored command ", m.Content, " on ", id)
+		info.Logger().With(bot.LogFields{"channel": id}).Debug("Sending bored command", m.Content)
 
 		info.Bot.ProcessCommand(m, info, t.Unix(), info.IsDebug(bot.DiscordChannel(m.ChannelID)), false)
 	}
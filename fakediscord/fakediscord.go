@@ -0,0 +1,571 @@
+// Package fakediscord implements an in-memory stand-in for Discord, consisting of a websocket gateway and the REST
+// endpoints sweetiebot uses, so that end-to-end tests can drive a real bot without touching the network.
+package fakediscord
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+const discordEpoch = 1420070400000
+
+// DefaultTimeout is how long the Wait functions poll before giving up
+var DefaultTimeout = 5 * time.Second
+
+var mentionRegex = regexp.MustCompile("<@!?([0-9]+)>")
+
+// Server holds the entire state of the fake discord. All exported functions are safe to call from multiple goroutines.
+type Server struct {
+	Bot   *discordgo.User // The user account the bot logs in as
+	Owner *discordgo.User // The user that owns every guild created by AddGuild, and the bot application
+
+	http     *httptest.Server
+	lock     sync.Mutex
+	lastID   uint64
+	seq      int
+	conns    map[*conn]bool
+	users    map[string]*discordgo.User
+	guilds   map[string]*discordgo.Guild
+	channels map[string]*discordgo.Channel
+	messages map[string][]*discordgo.Message  // Messages in each channel, oldest first
+	deleted  map[string]bool                  // IDs of every message that has been deleted
+	bans     map[string]map[string]string     // Reason for each ban, by guild and then user
+	timeouts map[string]map[string]time.Time  // When each member's timeout ends, by guild and then user
+	slowmode map[string]int                   // Slowmode of each channel in seconds, which discordgo doesn't know about
+	commands map[string][]*ApplicationCommand // Slash commands registered on each guild
+	tokens   map[string]*Interaction          // Every interaction sent by Interact, by token
+	codes    map[string]string                // User IDs of OAuth2 codes handed out by Authorize, by code
+	bearers  map[string]string                // User IDs of OAuth2 access tokens, by token
+	invites  map[string]string                // Guild IDs of invites created by AddInvite, by code
+	files    map[string][]byte                // Contents of every attachment uploaded to a message, by URL path
+	reacts   map[string][]string              // Emoji the bot has reacted to each message with, by message ID
+	failures map[string]int                   // How many more times each request set up by FailRequests fails
+}
+
+// New starts a fake discord server listening on a local port
+func New() *Server {
+	s := &Server{
+		conns:    make(map[*conn]bool),
+		users:    make(map[string]*discordgo.User),
+		guilds:   make(map[string]*discordgo.Guild),
+		channels: make(map[string]*discordgo.Channel),
+		messages: make(map[string][]*discordgo.Message),
+		deleted:  make(map[string]bool),
+		bans:     make(map[string]map[string]string),
+		timeouts: make(map[string]map[string]time.Time),
+		slowmode: make(map[string]int),
+		commands: make(map[string][]*ApplicationCommand),
+		tokens:   make(map[string]*Interaction),
+		codes:    make(map[string]string),
+		bearers:  make(map[string]string),
+		invites:  make(map[string]string),
+		files:    make(map[string][]byte),
+		reacts:   make(map[string][]string),
+		failures: make(map[string]int),
+	}
+	s.Bot = s.AddUser("Sweetie Bot")
+	s.Bot.Bot = true
+	s.Owner = s.AddUser("Owner")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gateway", s.serveGateway)
+	mux.HandleFunc("/", s.serveREST)
+	s.http = httptest.NewServer(mux)
+	return s
+}
+
+// Close disconnects every gateway connection and shuts down the server
+func (s *Server) Close() {
+	s.lock.Lock()
+	for c := range s.conns {
+		c.ws.Close()
+	}
+	s.conns = make(map[*conn]bool)
+	s.lock.Unlock()
+	s.http.Close()
+}
+
+// URL returns the base URL of the REST endpoints
+func (s *Server) URL() string {
+	return s.http.URL
+}
+
+// GatewayURL returns the websocket URL that the REST gateway endpoint hands out
+func (s *Server) GatewayURL() string {
+	return "ws" + strings.TrimPrefix(s.http.URL, "http") + "/gateway"
+}
+
+// Client returns an HTTP client that sends every request to this server, no matter what host it was addressed to.
+// Assign it to the Client field of a discordgo session before opening it.
+func (s *Server) Client() *http.Client {
+	return &http.Client{Timeout: 20 * time.Second, Transport: &redirectTransport{s.http.Listener.Addr().String()}}
+}
+
+type redirectTransport struct {
+	host string
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.WithContext(req.Context())
+	u := *req.URL
+	u.Scheme = "http"
+	u.Host = t.host
+	r.URL = &u
+	r.Host = t.host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// newID generates a snowflake for the current time. Must be called inside the lock.
+func (s *Server) newID() string {
+	id := uint64(time.Now().UTC().UnixNano()/int64(time.Millisecond)-discordEpoch) << 22
+	if id <= s.lastID {
+		id = s.lastID + 1
+	}
+	s.lastID = id
+	return strconv.FormatUint(id, 10)
+}
+
+func timestamp() discordgo.Timestamp {
+	return discordgo.Timestamp(time.Now().UTC().Format(time.RFC3339Nano))
+}
+
+// AddUser creates a new user account that isn't a member of any guild yet
+func (s *Server) AddUser(name string) *discordgo.User {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	u := &discordgo.User{
+		ID:            s.newID(),
+		Username:      name,
+		Discriminator: strconv.Itoa(1000 + len(s.users)),
+	}
+	s.users[u.ID] = u
+	return u
+}
+
+// AddGuild creates a guild owned by Owner, containing only the owner, the bot and an @everyone role. The bot is given
+// a role with the permissions it needs to moderate the guild.
+func (s *Server) AddGuild(name string) *discordgo.Guild {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	g := &discordgo.Guild{
+		ID:                s.newID(),
+		Name:              name,
+		OwnerID:           s.Owner.ID,
+		VerificationLevel: discordgo.VerificationLevelLow,
+		Emojis:            []*discordgo.Emoji{},
+		Presences:         []*discordgo.Presence{},
+		VoiceStates:       []*discordgo.VoiceState{},
+		Channels:          []*discordgo.Channel{},
+	}
+	g.Roles = []*discordgo.Role{
+		{ID: g.ID, Name: "@everyone", Permissions: discordgo.PermissionReadMessages | discordgo.PermissionSendMessages | discordgo.PermissionReadMessageHistory},
+		{ID: s.newID(), Name: s.Bot.Username, Position: 1, Permissions: discordgo.PermissionBanMembers | discordgo.PermissionKickMembers | discordgo.PermissionManageRoles | discordgo.PermissionManageMessages | discordgo.PermissionManageServer | discordgo.PermissionManageChannels},
+	}
+	joined := time.Now().UTC().AddDate(-1, 0, 0) // Backdated so the owner and the bot never count as part of a raid
+	g.Members = []*discordgo.Member{
+		newMember(g.ID, s.Owner, joined),
+		newMember(g.ID, s.Bot, joined, g.Roles[1].ID),
+	}
+	s.guilds[g.ID] = g
+	s.dispatch(g.ID, "GUILD_CREATE", g)
+	return g
+}
+
+func newMember(guildID string, u *discordgo.User, joined time.Time, roles ...string) *discordgo.Member {
+	return &discordgo.Member{
+		GuildID:  guildID,
+		JoinedAt: joined.Format(time.RFC3339),
+		User:     u,
+		Roles:    append([]string{}, roles...),
+	}
+}
+
+// AddChannel creates a new text channel in the guild
+func (s *Server) AddChannel(guildID string, name string) *discordgo.Channel {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	g := s.guilds[guildID]
+	ch := &discordgo.Channel{
+		ID:                   s.newID(),
+		GuildID:              guildID,
+		Name:                 name,
+		Type:                 discordgo.ChannelTypeGuildText,
+		Position:             len(g.Channels),
+		PermissionOverwrites: []*discordgo.PermissionOverwrite{},
+	}
+	g.Channels = append(g.Channels, ch)
+	s.channels[ch.ID] = ch
+	s.dispatch(guildID, "CHANNEL_CREATE", ch)
+	return ch
+}
+
+// AddRole creates a new role in the guild
+func (s *Server) AddRole(guildID string, name string, permissions int) *discordgo.Role {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.addRole(s.guilds[guildID], name, permissions)
+}
+
+func (s *Server) addRole(g *discordgo.Guild, name string, permissions int) *discordgo.Role {
+	r := &discordgo.Role{
+		ID:          s.newID(),
+		Name:        name,
+		Position:    len(g.Roles),
+		Permissions: permissions,
+	}
+	g.Roles = append(g.Roles, r)
+	s.dispatch(g.ID, "GUILD_ROLE_CREATE", map[string]interface{}{"guild_id": g.ID, "role": r})
+	return r
+}
+
+// AddInvite creates an invite code for a guild. The guild doesn't have to exist, so that tests can post invites to
+// servers the bot isn't on.
+func (s *Server) AddInvite(guildID string, code string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.invites[code] = guildID
+}
+
+// JoinUser adds the user to the guild with the given roles, exactly as if they had accepted an invite
+func (s *Server) JoinUser(guildID string, u *discordgo.User, roles ...string) *discordgo.Member {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	g := s.guilds[guildID]
+	m := newMember(guildID, u, time.Now().UTC(), roles...)
+	g.Members = append(g.Members, m)
+	s.dispatch(guildID, "GUILD_MEMBER_ADD", m)
+	return m
+}
+
+// PostMessage sends a message from the given user, filling in mentions the same way discord would
+func (s *Server) PostMessage(channelID string, author *discordgo.User, content string) *discordgo.Message {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.postMessage(channelID, author, content, nil)
+}
+
+// PostAttachment sends a message from the given user with a file attached to it
+func (s *Server) PostAttachment(channelID string, author *discordgo.User, content string, filename string) *discordgo.Message {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	id := s.newID()
+	return s.postMessage(channelID, author, content, nil, &discordgo.MessageAttachment{
+		ID:       id,
+		Filename: filename,
+		URL:      "https://cdn.discordapp.com/attachments/" + channelID + "/" + id + "/" + filename,
+	})
+}
+
+// PostFile sends a message from the given user with a file attached to it, which can be downloaded from its URL
+func (s *Server) PostFile(channelID string, author *discordgo.User, content string, filename string, data []byte) *discordgo.Message {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.postMessage(channelID, author, content, nil, s.attach(channelID, filename, data))
+}
+
+// attach stores the contents of a file and returns the attachment that links to it. Must be called inside the lock.
+func (s *Server) attach(channelID string, filename string, data []byte) *discordgo.MessageAttachment {
+	id := s.newID()
+	path := "/attachments/" + channelID + "/" + id + "/" + filename
+	s.files[path] = data
+	return &discordgo.MessageAttachment{
+		ID:       id,
+		Filename: filename,
+		URL:      "https://cdn.discordapp.com" + path,
+		Size:     len(data),
+	}
+}
+
+// File returns the contents of an attachment, or nil if it doesn't exist
+func (s *Server) File(attachment *discordgo.MessageAttachment) []byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	u, err := url.Parse(attachment.URL)
+	if err != nil {
+		return nil
+	}
+	return s.files[u.Path]
+}
+
+// channelGuild returns the ID of the guild the channel belongs to, or an empty string for private channels
+func (s *Server) channelGuild(channelID string) string {
+	if ch, ok := s.channels[channelID]; ok {
+		return ch.GuildID
+	}
+	return ""
+}
+
+func (s *Server) postMessage(channelID string, author *discordgo.User, content string, embed *discordgo.MessageEmbed, attachments ...*discordgo.MessageAttachment) *discordgo.Message {
+	m := &discordgo.Message{
+		ID:              s.newID(),
+		ChannelID:       channelID,
+		Content:         content,
+		Timestamp:       timestamp(),
+		Author:          author,
+		MentionEveryone: strings.Contains(content, "@everyone") || strings.Contains(content, "@here"),
+		Mentions:        []*discordgo.User{},
+		Attachments:     append([]*discordgo.MessageAttachment{}, attachments...),
+		Embeds:          []*discordgo.MessageEmbed{},
+	}
+	for _, match := range mentionRegex.FindAllStringSubmatch(content, -1) {
+		if u, ok := s.users[match[1]]; ok {
+			m.Mentions = append(m.Mentions, u)
+		}
+	}
+	if embed != nil {
+		m.Embeds = append(m.Embeds, embed)
+	}
+	s.messages[channelID] = append(s.messages[channelID], m)
+	s.dispatch(s.channelGuild(channelID), "MESSAGE_CREATE", m)
+	return m
+}
+
+func (s *Server) reaction(event string, channelID string, messageID string, userID string, emoji string) {
+	e := map[string]interface{}{"id": nil, "name": emoji}
+	if i := strings.LastIndex(emoji, ":"); i >= 0 { // Custom emoji are written as name:id
+		e["name"], e["id"] = emoji[:i], emoji[i+1:]
+	}
+	guildID := s.channelGuild(channelID)
+	s.dispatch(guildID, event, map[string]interface{}{"user_id": userID, "message_id": messageID, "channel_id": channelID, "guild_id": guildID, "emoji": e})
+}
+
+// React adds a reaction from the given user to a message. Custom emoji are written as name:id.
+func (s *Server) React(channelID string, messageID string, u *discordgo.User, emoji string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.reaction("MESSAGE_REACTION_ADD", channelID, messageID, u.ID, emoji)
+}
+
+// Unreact removes a reaction from the given user on a message
+func (s *Server) Unreact(channelID string, messageID string, u *discordgo.User, emoji string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.reaction("MESSAGE_REACTION_REMOVE", channelID, messageID, u.ID, emoji)
+}
+
+// Reactions returns the emoji the bot has reacted to the message with, in the order it added them
+func (s *Server) Reactions(messageID string) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.reacts[messageID]...)
+}
+
+// FailRequests makes the next n requests with the given method and path, like "guilds/1/bans/2", fail with an internal
+// server error, as if discord was having an outage
+func (s *Server) FailRequests(method string, path string, n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failures[method+" "+strings.Trim(path, "/")] = n
+}
+
+func (s *Server) failRequest(method string, path []string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := method + " " + strings.Join(path, "/")
+	if s.failures[key] <= 0 {
+		return false
+	}
+	s.failures[key]--
+	return true
+}
+
+// Messages returns every message sent in the channel that hasn't been deleted, oldest first
+func (s *Server) Messages(channelID string) []*discordgo.Message {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	r := []*discordgo.Message{}
+	for _, m := range s.messages[channelID] {
+		if !s.deleted[m.ID] {
+			r = append(r, m)
+		}
+	}
+	return r
+}
+
+// BotMessages returns everything the bot has sent to the channel, including messages that were later deleted
+func (s *Server) BotMessages(channelID string) []*discordgo.Message {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	r := []*discordgo.Message{}
+	for _, m := range s.messages[channelID] {
+		if m.Author.ID == s.Bot.ID {
+			r = append(r, m)
+		}
+	}
+	return r
+}
+
+// DirectMessages returns everything the bot has sent to the user in private messages
+func (s *Server) DirectMessages(userID string) []*discordgo.Message {
+	s.lock.Lock()
+	ch := s.dmChannel(userID)
+	s.lock.Unlock()
+	if ch == nil {
+		return []*discordgo.Message{}
+	}
+	return s.BotMessages(ch.ID)
+}
+
+// Deleted returns true if the message with this ID was deleted
+func (s *Server) Deleted(messageID string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.deleted[messageID]
+}
+
+// Banned returns true if the user is currently banned from the guild
+func (s *Server) Banned(guildID string, userID string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.bans[guildID][userID]
+	return ok
+}
+
+// BanReason returns the audit log reason given when the user was banned
+func (s *Server) BanReason(guildID string, userID string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.bans[guildID][userID]
+}
+
+// TimedOut returns true if the member is currently timed out
+func (s *Server) TimedOut(guildID string, userID string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.timeouts[guildID][userID].After(time.Now())
+}
+
+// Slowmode returns how many seconds members must wait between messages in the channel
+func (s *Server) Slowmode(channelID string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.slowmode[channelID]
+}
+
+// HasRole returns true if the user is a member of the guild and has the role
+func (s *Server) HasRole(guildID string, userID string, roleID string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if m := s.member(s.guilds[guildID], userID); m != nil {
+		for _, r := range m.Roles {
+			if r == roleID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsMember returns true if the user is currently a member of the guild
+func (s *Server) IsMember(guildID string, userID string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.member(s.guilds[guildID], userID) != nil
+}
+
+// VerificationLevel returns the current verification level of the guild
+func (s *Server) VerificationLevel(guildID string) discordgo.VerificationLevel {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.guilds[guildID].VerificationLevel
+}
+
+// PermissionOverwrite returns the overwrite for the target on the channel, or nil if there isn't one
+func (s *Server) PermissionOverwrite(channelID string, targetID string) *discordgo.PermissionOverwrite {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if ch, ok := s.channels[channelID]; ok {
+		for _, v := range ch.PermissionOverwrites {
+			if v.ID == targetID {
+				o := *v
+				return &o
+			}
+		}
+	}
+	return nil
+}
+
+// Connected returns true once a client has identified on the gateway
+func (s *Server) Connected() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.conns) > 0
+}
+
+// WaitFor polls the condition until it returns true or DefaultTimeout runs out, returning the last result. Because
+// the bot reacts to events asynchronously, every assertion about its behavior should go through this.
+func (s *Server) WaitFor(condition func() bool) bool {
+	for end := time.Now().Add(DefaultTimeout); time.Now().Before(end); time.Sleep(10 * time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+	return condition()
+}
+
+// WaitForMessage waits for the bot to send a message containing text to the channel and returns it, or nil if it never did
+func (s *Server) WaitForMessage(channelID string, text string) (msg *discordgo.Message) {
+	s.WaitFor(func() bool {
+		for _, m := range s.BotMessages(channelID) {
+			if strings.Contains(m.Content, text) {
+				msg = m
+				return true
+			}
+		}
+		return false
+	})
+	return
+}
+
+// WaitForEmbed waits for the bot to send an embed to the channel whose title or one of whose fields contains text,
+// and returns it, or nil if it never did
+func (s *Server) WaitForEmbed(channelID string, text string) (embed *discordgo.MessageEmbed) {
+	s.WaitFor(func() bool {
+		for _, m := range s.BotMessages(channelID) {
+			for _, e := range m.Embeds {
+				if strings.Contains(e.Title, text) {
+					embed = e
+					return true
+				}
+				for _, f := range e.Fields {
+					if strings.Contains(f.Value, text) {
+						embed = e
+						return true
+					}
+				}
+			}
+		}
+		return false
+	})
+	return
+}
+
+// member finds a member of the guild. Must be called inside the lock.
+func (s *Server) member(g *discordgo.Guild, userID string) *discordgo.Member {
+	if g != nil {
+		for _, m := range g.Members {
+			if m.User.ID == userID {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// dmChannel finds the private channel between the bot and the user. Must be called inside the lock.
+func (s *Server) dmChannel(userID string) *discordgo.Channel {
+	for _, ch := range s.channels {
+		if ch.Type == discordgo.ChannelTypeDM && len(ch.Recipients) > 0 && ch.Recipients[0].ID == userID {
+			return ch
+		}
+	}
+	return nil
+}
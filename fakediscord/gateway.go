@@ -0,0 +1,143 @@
+package fakediscord
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/blackhole12/discordgo"
+	"github.com/gorilla/websocket"
+)
+
+// Gateway opcodes, see https://discordapp.com/developers/docs/topics/opcodes-and-status-codes
+const (
+	opDispatch     = 0
+	opHeartbeat    = 1
+	opIdentify     = 2
+	opResume       = 6
+	opHello        = 10
+	opHeartbeatAck = 11
+)
+
+var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+type conn struct {
+	ws     *websocket.Conn
+	lock   sync.Mutex // websocket connections only support one concurrent writer
+	shard  int
+	shards int // Total shard count sent in IDENTIFY, or 0 if the client isn't sharded
+}
+
+type payload struct {
+	Op   int         `json:"op"`
+	Data interface{} `json:"d"`
+	Seq  int         `json:"s,omitempty"`
+	Type string      `json:"t,omitempty"`
+}
+
+func (c *conn) send(p *payload) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.ws.WriteJSON(p)
+}
+
+// receives returns true if events for the given guild are sent to this connection, following discord's sharding
+// formula. Events that don't belong to a guild are sent to every connection.
+func (c *conn) receives(guildID string) bool {
+	if c.shards < 2 || len(guildID) == 0 {
+		return true
+	}
+	id, _ := strconv.ParseUint(guildID, 10, 64)
+	return int((id>>22)%uint64(c.shards)) == c.shard
+}
+
+// dispatch sends an event to every identified connection responsible for guildID. Must be called inside the lock,
+// which guarantees that clients receive events in the same order the state was modified.
+func (s *Server) dispatch(guildID string, event string, data interface{}) {
+	if len(s.conns) == 0 {
+		return
+	}
+	s.seq++
+	p := &payload{opDispatch, data, s.seq, event}
+	for c := range s.conns {
+		if !c.receives(guildID) {
+			continue
+		}
+		if err := c.send(p); err != nil {
+			delete(s.conns, c)
+			c.ws.Close()
+		}
+	}
+}
+
+func (s *Server) serveGateway(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &conn{ws: ws}
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, c)
+		s.lock.Unlock()
+		ws.Close()
+	}()
+
+	if c.send(&payload{Op: opHello, Data: map[string]interface{}{"heartbeat_interval": 41250, "_trace": []string{"fakediscord"}}}) != nil {
+		return
+	}
+
+	for {
+		var p struct {
+			Op   int             `json:"op"`
+			Data json.RawMessage `json:"d"`
+		}
+		if err := ws.ReadJSON(&p); err != nil {
+			return
+		}
+		switch p.Op {
+		case opHeartbeat:
+			c.send(&payload{Op: opHeartbeatAck})
+		case opIdentify, opResume: // We don't keep any event history, so a resume simply starts a new session
+			var identify struct {
+				Shard []int `json:"shard"`
+			}
+			if json.Unmarshal(p.Data, &identify) == nil && len(identify.Shard) == 2 {
+				c.shard, c.shards = identify.Shard[0], identify.Shard[1]
+			}
+			s.identify(c)
+		}
+		// Everything else, like status updates or member chunk requests, is silently ignored
+	}
+}
+
+// identify sends READY followed by a GUILD_CREATE for every guild on the connection's shard, then registers the connection for future events
+func (s *Server) identify(c *conn) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	guilds := make([]*discordgo.Guild, 0, len(s.guilds))
+	for id := range s.guilds {
+		if !c.receives(id) {
+			continue
+		}
+		guilds = append(guilds, &discordgo.Guild{ID: id, Unavailable: true})
+	}
+	s.seq++
+	c.send(&payload{opDispatch, map[string]interface{}{
+		"v":                6,
+		"session_id":       fmt.Sprintf("fakediscord-%v", s.seq),
+		"user":             s.Bot,
+		"guilds":           guilds,
+		"private_channels": []*discordgo.Channel{},
+	}, s.seq, "READY"})
+	for _, g := range s.guilds {
+		if !c.receives(g.ID) {
+			continue
+		}
+		s.seq++
+		c.send(&payload{opDispatch, g, s.seq, "GUILD_CREATE"})
+	}
+	s.conns[c] = true
+}
@@ -0,0 +1,261 @@
+package fakediscord
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/blackhole12/discordgo"
+)
+
+const (
+	interactionResponseMessage  = 4
+	interactionResponseDeferred = 5
+	messageFlagEphemeral        = 1 << 6
+)
+
+var errUnknownInteraction = &restError{10062, "Unknown interaction"}
+
+// ApplicationCommand is a slash command the bot has registered on a guild
+type ApplicationCommand struct {
+	ID          string                      `json:"id"`
+	Name        string                      `json:"name"`
+	Description string                      `json:"description"`
+	Options     []*ApplicationCommandOption `json:"options,omitempty"`
+}
+
+// ApplicationCommandOption is a single parameter of a slash command
+type ApplicationCommandOption struct {
+	Type        int    `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// Interaction records a slash command sent by Interact, along with everything the bot sent in response to it
+type Interaction struct {
+	ID        string
+	Token     string
+	Deferred  bool                   // True if the bot acknowledged the interaction before responding to it
+	Responses []*InteractionResponse // The original response, followed by any followup messages
+}
+
+// InteractionResponse is a message the bot sent in response to an interaction
+type InteractionResponse struct {
+	Content string                    `json:"content"`
+	Embeds  []*discordgo.MessageEmbed `json:"embeds"`
+	Flags   int                       `json:"flags"`
+	Deleted bool                      `json:"-"`
+}
+
+// Ephemeral returns true if only the user who ran the command can see the response
+func (r *InteractionResponse) Ephemeral() bool {
+	return r.Flags&messageFlagEphemeral != 0
+}
+
+// Contains returns true if text appears in the content of the response, or in the title, description or author of
+// any of its embeds
+func (r *InteractionResponse) Contains(text string) bool {
+	if strings.Contains(r.Content, text) {
+		return true
+	}
+	for _, e := range r.Embeds {
+		if strings.Contains(e.Title, text) || strings.Contains(e.Description, text) || (e.Author != nil && strings.Contains(e.Author.Name, text)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Commands returns the slash commands the bot has registered on the guild
+func (s *Server) Commands(guildID string) []*ApplicationCommand {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*ApplicationCommand{}, s.commands[guildID]...)
+}
+
+// Interact has the user run a slash command in the channel, exactly as if they had picked it from the command list
+// and filled in the given options
+func (s *Server) Interact(channelID string, u *discordgo.User, command string, options map[string]string) *Interaction {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	i := &Interaction{ID: s.newID(), Token: "token" + s.newID(), Responses: []*InteractionResponse{}}
+	s.tokens[i.Token] = i
+
+	names := make([]string, 0, len(options))
+	for k := range options {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	opts := []map[string]interface{}{}
+	for _, k := range names {
+		opts = append(opts, map[string]interface{}{"name": k, "type": 3, "value": options[k]})
+	}
+	data := map[string]interface{}{
+		"id":             i.ID,
+		"application_id": s.Bot.ID,
+		"type":           2,
+		"token":          i.Token,
+		"channel_id":     channelID,
+		"data":           map[string]interface{}{"name": command, "options": opts},
+	}
+	if ch, ok := s.channels[channelID]; ok && len(ch.GuildID) > 0 {
+		data["guild_id"] = ch.GuildID
+		data["member"] = s.member(s.guilds[ch.GuildID], u.ID)
+	} else {
+		data["user"] = u
+	}
+	s.dispatch(s.channelGuild(channelID), "INTERACTION_CREATE", data)
+	return i
+}
+
+// InteractionResponses returns a copy of everything the bot has sent in response to the interaction
+func (s *Server) InteractionResponses(i *Interaction) []InteractionResponse {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	r := make([]InteractionResponse, 0, len(i.Responses))
+	for _, v := range i.Responses {
+		r = append(r, *v)
+	}
+	return r
+}
+
+// WaitForResponse waits for the bot to respond to the interaction with something containing text and returns that
+// response, or nil if it never did
+func (s *Server) WaitForResponse(i *Interaction, text string) (response *InteractionResponse) {
+	s.WaitFor(func() bool {
+		for _, r := range s.InteractionResponses(i) {
+			if !r.Deleted && r.Contains(text) {
+				response = &r
+				return true
+			}
+		}
+		return false
+	})
+	return
+}
+
+func (s *Server) serveApplications(w http.ResponseWriter, r *http.Request, p []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(p) != 4 || p[0] != s.Bot.ID || p[1] != "guilds" || p[3] != "commands" {
+		writeError(w, errNotFound)
+		return
+	}
+	if _, ok := s.guilds[p[2]]; !ok {
+		writeError(w, errUnknownGuild)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, s.commands[p[2]])
+	case "PUT":
+		cmds := []*ApplicationCommand{}
+		if readBody(r, &cmds) != nil || len(cmds) > 100 {
+			writeError(w, errBadRequest)
+			return
+		}
+		for _, c := range cmds {
+			if len(c.Name) == 0 || len(c.Name) > 32 || len(c.Description) == 0 || len([]rune(c.Description)) > 100 || len(c.Options) > 25 {
+				writeError(w, errBadRequest)
+				return
+			}
+			c.ID = s.newID()
+		}
+		s.commands[p[2]] = cmds
+		writeJSON(w, http.StatusOK, cmds)
+	default:
+		writeError(w, errNotFound)
+	}
+}
+
+// serveInteractions handles the initial response to an interaction, which discord only accepts once
+func (s *Server) serveInteractions(w http.ResponseWriter, r *http.Request, p []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(p) != 3 || p[2] != "callback" || r.Method != "POST" {
+		writeError(w, errNotFound)
+		return
+	}
+	i, ok := s.tokens[p[1]]
+	if !ok || i.ID != p[0] || i.Deferred || len(i.Responses) > 0 {
+		writeError(w, errUnknownInteraction)
+		return
+	}
+	var params struct {
+		Type int                  `json:"type"`
+		Data *InteractionResponse `json:"data"`
+	}
+	if readBody(r, &params) != nil {
+		writeError(w, errBadRequest)
+		return
+	}
+	switch {
+	case params.Type == interactionResponseDeferred:
+		i.Deferred = true
+	case params.Type == interactionResponseMessage && params.Data != nil && validResponse(params.Data):
+		i.Responses = append(i.Responses, params.Data)
+	default:
+		writeError(w, errBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveWebhooks handles editing the original response to an interaction and sending followup messages
+func (s *Server) serveWebhooks(w http.ResponseWriter, r *http.Request, p []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(p) < 2 || p[0] != s.Bot.ID {
+		writeError(w, errNotFound)
+		return
+	}
+	i, ok := s.tokens[p[1]]
+	if !ok {
+		writeError(w, errUnknownInteraction)
+		return
+	}
+
+	switch {
+	case len(p) == 2 && r.Method == "POST":
+		var msg InteractionResponse
+		if readBody(r, &msg) != nil || !validResponse(&msg) || (!i.Deferred && len(i.Responses) == 0) {
+			writeError(w, errBadRequest)
+			return
+		}
+		i.Responses = append(i.Responses, &msg)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"id": s.newID(), "content": msg.Content})
+	case len(p) == 4 && p[2] == "messages" && p[3] == "@original":
+		if !i.Deferred && len(i.Responses) == 0 {
+			writeError(w, errUnknownMessage)
+			return
+		}
+		if len(i.Responses) == 0 { // Editing a deferred response replaces the loading message
+			i.Responses = append(i.Responses, &InteractionResponse{})
+		}
+		original := i.Responses[0]
+		switch r.Method {
+		case "PATCH":
+			var msg InteractionResponse
+			if readBody(r, &msg) != nil || !validResponse(&msg) {
+				writeError(w, errBadRequest)
+				return
+			}
+			original.Content = msg.Content
+			original.Embeds = msg.Embeds
+			writeJSON(w, http.StatusOK, map[string]interface{}{"id": i.ID, "content": msg.Content})
+		case "DELETE":
+			original.Deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, errNotFound)
+		}
+	default:
+		writeError(w, errNotFound)
+	}
+}
+
+func validResponse(msg *InteractionResponse) bool {
+	return (len(msg.Content) > 0 || len(msg.Embeds) > 0) && len(msg.Content) <= 2000 && len(msg.Embeds) <= 10
+}
@@ -0,0 +1,36 @@
+package fakediscord
+
+import (
+	"net/http"
+
+	"github.com/blackhole12/discordgo"
+)
+
+// Authorize returns the code discord would send back to the redirect URI after the user clicked Authorize on the
+// OAuth2 login screen. The code can be exchanged once for an access token that identifies the user.
+func (s *Server) Authorize(u *discordgo.User) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	code := "code" + s.newID()
+	s.codes[code] = u.ID
+	return code
+}
+
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	code := r.PostForm.Get("code")
+	user, ok := s.codes[code]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	delete(s.codes, code)
+	token := "bearer" + s.newID()
+	s.bearers[token] = user
+	writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": token, "token_type": "Bearer", "expires_in": 604800, "scope": "identify"})
+}
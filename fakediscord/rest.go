@@ -0,0 +1,667 @@
+package fakediscord
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+// restError is the JSON body discord sends back when a request fails
+type restError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+var (
+	errUnknownChannel = &restError{10003, "Unknown Channel"}
+	errUnknownGuild   = &restError{10004, "Unknown Guild"}
+	errUnknownMember  = &restError{10007, "Unknown Member"}
+	errUnknownMessage = &restError{10008, "Unknown Message"}
+	errUnknownRole    = &restError{10011, "Unknown Role"}
+	errUnknownUser    = &restError{10013, "Unknown User"}
+	errUnknownInvite  = &restError{10006, "Unknown Invite"}
+	errUnknownBan     = &restError{10026, "Unknown Ban"}
+	errNotFound       = &restError{0, "404: Not Found"}
+	errBadRequest     = &restError{50035, "Invalid Form Body"}
+	errInternal       = &restError{0, "500: Internal Server Error"}
+)
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err *restError) {
+	status := http.StatusNotFound
+	if err == errBadRequest {
+		status = http.StatusBadRequest
+	} else if err == errInternal {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, err)
+}
+
+// splitPath strips the api prefix and version from the path, so "/api/v6/channels/1/messages" becomes
+// ["channels", "1", "messages"]
+func splitPath(path string) []string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > 0 && parts[0] == "api" {
+		parts = parts[1:]
+	}
+	if len(parts) > 0 && len(parts[0]) > 1 && parts[0][0] == 'v' {
+		if _, err := strconv.Atoi(parts[0][1:]); err == nil {
+			parts = parts[1:]
+		}
+	}
+	return parts
+}
+
+// readBody decodes a JSON request body into v, including the payload_json field of multipart file uploads
+func readBody(r *http.Request, v interface{}) error {
+	_, err := readUpload(r, v)
+	return err
+}
+
+// readUpload works like readBody, but also returns the contents of any files uploaded with the request, by filename
+func readUpload(r *http.Request, v interface{}) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	if mediatype, params, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && strings.HasPrefix(mediatype, "multipart/") {
+		form, err := multipart.NewReader(r.Body, params["boundary"]).ReadForm(1 << 20)
+		if err != nil {
+			return files, err
+		}
+		for _, headers := range form.File {
+			for _, h := range headers {
+				f, err := h.Open()
+				if err != nil {
+					return files, err
+				}
+				files[h.Filename], err = ioutil.ReadAll(f)
+				f.Close()
+				if err != nil {
+					return files, err
+				}
+			}
+		}
+		if len(form.Value["payload_json"]) > 0 {
+			return files, json.Unmarshal([]byte(form.Value["payload_json"][0]), v)
+		}
+		return files, nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		return files, err
+	}
+	return files, json.Unmarshal(body, v)
+}
+
+func (s *Server) serveREST(w http.ResponseWriter, r *http.Request) {
+	p := splitPath(r.URL.Path)
+	if len(p) == 0 {
+		writeError(w, errNotFound)
+		return
+	}
+	if s.failRequest(r.Method, p) {
+		writeError(w, errInternal)
+		return
+	}
+
+	switch p[0] {
+	case "gateway":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"url": s.GatewayURL(), "shards": 1})
+		return
+	case "oauth2":
+		if len(p) == 3 && p[1] == "applications" && p[2] == "@me" {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"id": s.Bot.ID, "name": s.Bot.Username, "owner": s.Owner})
+			return
+		}
+		if len(p) == 2 && p[1] == "token" && r.Method == "POST" {
+			s.serveToken(w, r)
+			return
+		}
+	case "users":
+		s.serveUsers(w, r, p[1:])
+		return
+	case "channels":
+		s.serveChannels(w, r, p[1:])
+		return
+	case "attachments":
+		s.lock.Lock()
+		data, ok := s.files[r.URL.Path]
+		s.lock.Unlock()
+		if ok && r.Method == "GET" {
+			w.Write(data)
+			return
+		}
+	case "guilds":
+		s.serveGuilds(w, r, p[1:])
+		return
+	case "applications":
+		s.serveApplications(w, r, p[1:])
+		return
+	case "interactions":
+		s.serveInteractions(w, r, p[1:])
+		return
+	case "webhooks":
+		s.serveWebhooks(w, r, p[1:])
+		return
+	case "invite", "invites": // Older versions of discordgo use the singular
+		if len(p) == 2 && r.Method == "GET" {
+			s.serveInvite(w, p[1])
+			return
+		}
+	}
+	writeError(w, errNotFound)
+}
+
+func (s *Server) serveInvite(w http.ResponseWriter, code string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	guildID, ok := s.invites[code]
+	if !ok {
+		writeError(w, errUnknownInvite)
+		return
+	}
+	guild := &discordgo.Guild{ID: guildID}
+	if g, ok := s.guilds[guildID]; ok {
+		guild = &discordgo.Guild{ID: g.ID, Name: g.Name}
+	}
+	writeJSON(w, http.StatusOK, &discordgo.Invite{Guild: guild, Code: code})
+}
+
+func (s *Server) serveUsers(w http.ResponseWriter, r *http.Request, p []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(p) == 0 {
+		writeError(w, errNotFound)
+		return
+	}
+	id := p[0]
+	if id == "@me" {
+		id = s.Bot.ID
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			id = s.bearers[strings.TrimPrefix(auth, "Bearer ")]
+		}
+	}
+	u, ok := s.users[id]
+	if !ok {
+		writeError(w, errUnknownUser)
+		return
+	}
+
+	switch {
+	case len(p) == 1 && r.Method == "GET":
+		writeJSON(w, http.StatusOK, u)
+	case len(p) == 1 && r.Method == "PATCH" && u == s.Bot:
+		var params struct {
+			Username string `json:"username"`
+			Avatar   string `json:"avatar"`
+		}
+		if readBody(r, &params) != nil {
+			writeError(w, errBadRequest)
+			return
+		}
+		if len(params.Username) > 0 {
+			u.Username = params.Username
+		}
+		s.dispatch("", "USER_UPDATE", u)
+		writeJSON(w, http.StatusOK, u)
+	case len(p) == 2 && p[1] == "guilds" && r.Method == "GET" && u == s.Bot:
+		guilds := []*discordgo.UserGuild{}
+		for _, g := range s.guilds {
+			guilds = append(guilds, &discordgo.UserGuild{ID: g.ID, Name: g.Name, Owner: g.OwnerID == u.ID})
+		}
+		writeJSON(w, http.StatusOK, guilds)
+	case len(p) == 2 && p[1] == "channels" && r.Method == "POST" && u == s.Bot:
+		var params struct {
+			RecipientID string `json:"recipient_id"`
+		}
+		if readBody(r, &params) != nil {
+			writeError(w, errBadRequest)
+			return
+		}
+		recipient, ok := s.users[params.RecipientID]
+		if !ok {
+			writeError(w, errUnknownUser)
+			return
+		}
+		ch := s.dmChannel(recipient.ID)
+		if ch == nil {
+			ch = &discordgo.Channel{ID: s.newID(), Type: discordgo.ChannelTypeDM, Recipients: []*discordgo.User{recipient}}
+			s.channels[ch.ID] = ch
+		}
+		writeJSON(w, http.StatusOK, ch)
+	default:
+		writeError(w, errNotFound)
+	}
+}
+
+func (s *Server) serveChannels(w http.ResponseWriter, r *http.Request, p []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(p) == 0 {
+		writeError(w, errNotFound)
+		return
+	}
+	ch, ok := s.channels[p[0]]
+	if !ok {
+		writeError(w, errUnknownChannel)
+		return
+	}
+
+	switch {
+	case len(p) == 1 && r.Method == "GET":
+		writeJSON(w, http.StatusOK, s.channelJSON(ch))
+	case len(p) == 1 && r.Method == "PATCH":
+		var params struct {
+			Slowmode *int `json:"rate_limit_per_user"`
+		}
+		if readBody(r, &params) != nil || (params.Slowmode != nil && (*params.Slowmode < 0 || *params.Slowmode > 21600)) {
+			writeError(w, errBadRequest)
+			return
+		}
+		if params.Slowmode != nil {
+			s.slowmode[ch.ID] = *params.Slowmode
+		}
+		s.dispatch(ch.GuildID, "CHANNEL_UPDATE", ch)
+		writeJSON(w, http.StatusOK, s.channelJSON(ch))
+	case len(p) == 2 && p[1] == "messages" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, s.getMessages(ch.ID, r))
+	case len(p) == 2 && p[1] == "messages" && r.Method == "POST":
+		var params struct {
+			Content string                  `json:"content"`
+			Embed   *discordgo.MessageEmbed `json:"embed"`
+		}
+		files, err := readUpload(r, &params)
+		if err != nil || (len(params.Content) == 0 && params.Embed == nil && len(files) == 0) || len(params.Content) > 2000 {
+			writeError(w, errBadRequest)
+			return
+		}
+		attachments := []*discordgo.MessageAttachment{}
+		for name, data := range files {
+			attachments = append(attachments, s.attach(ch.ID, name, data))
+		}
+		writeJSON(w, http.StatusOK, s.postMessage(ch.ID, s.Bot, params.Content, params.Embed, attachments...))
+	case len(p) == 3 && p[1] == "messages" && (p[2] == "bulk-delete" || p[2] == "bulk_delete") && r.Method == "POST":
+		var params struct {
+			Messages []string `json:"messages"`
+		}
+		if readBody(r, &params) != nil || len(params.Messages) > 100 {
+			writeError(w, errBadRequest)
+			return
+		}
+		for _, id := range params.Messages {
+			s.deleted[id] = true
+		}
+		s.dispatch(ch.GuildID, "MESSAGE_DELETE_BULK", map[string]interface{}{"ids": params.Messages, "channel_id": ch.ID})
+		w.WriteHeader(http.StatusNoContent)
+	case len(p) == 3 && p[1] == "messages":
+		var msg *discordgo.Message
+		for _, m := range s.messages[ch.ID] {
+			if m.ID == p[2] && !s.deleted[m.ID] {
+				msg = m
+			}
+		}
+		if msg == nil {
+			writeError(w, errUnknownMessage)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			writeJSON(w, http.StatusOK, msg)
+		case "DELETE":
+			s.deleted[msg.ID] = true
+			s.dispatch(ch.GuildID, "MESSAGE_DELETE", map[string]interface{}{"id": msg.ID, "channel_id": ch.ID})
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, errNotFound)
+		}
+	case len(p) == 6 && p[1] == "messages" && p[3] == "reactions" && p[5] == "@me" && (r.Method == "PUT" || r.Method == "DELETE"):
+		found := false
+		for _, m := range s.messages[ch.ID] {
+			found = found || (m.ID == p[2] && !s.deleted[m.ID])
+		}
+		if !found {
+			writeError(w, errUnknownMessage)
+			return
+		}
+		reacts := []string{}
+		for _, v := range s.reacts[p[2]] {
+			if v != p[4] {
+				reacts = append(reacts, v)
+			}
+		}
+		event := "MESSAGE_REACTION_REMOVE"
+		if r.Method == "PUT" {
+			reacts = append(reacts, p[4])
+			event = "MESSAGE_REACTION_ADD"
+		}
+		s.reacts[p[2]] = reacts
+		s.reaction(event, ch.ID, p[2], s.Bot.ID, p[4])
+		w.WriteHeader(http.StatusNoContent)
+	case len(p) == 3 && p[1] == "permissions" && (r.Method == "PUT" || r.Method == "DELETE"):
+		overwrites := []*discordgo.PermissionOverwrite{}
+		for _, v := range ch.PermissionOverwrites {
+			if v.ID != p[2] {
+				overwrites = append(overwrites, v)
+			}
+		}
+		if r.Method == "PUT" {
+			o := &discordgo.PermissionOverwrite{}
+			if readBody(r, o) != nil {
+				writeError(w, errBadRequest)
+				return
+			}
+			o.ID = p[2]
+			overwrites = append(overwrites, o)
+		}
+		ch.PermissionOverwrites = overwrites
+		s.dispatch(ch.GuildID, "CHANNEL_UPDATE", ch)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, errNotFound)
+	}
+}
+
+// channelJSON adds the fields discordgo doesn't know about to a channel
+func (s *Server) channelJSON(ch *discordgo.Channel) interface{} {
+	return struct {
+		*discordgo.Channel
+		Slowmode int `json:"rate_limit_per_user"`
+	}{ch, s.slowmode[ch.ID]}
+}
+
+// getMessages implements the before, after and limit parameters of the channel messages endpoint, newest first
+func (s *Server) getMessages(channelID string, r *http.Request) []*discordgo.Message {
+	q := r.URL.Query()
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	before, _ := strconv.ParseUint(q.Get("before"), 10, 64)
+	after, _ := strconv.ParseUint(q.Get("after"), 10, 64)
+
+	list := []*discordgo.Message{}
+	msgs := s.messages[channelID]
+	for i := len(msgs) - 1; i >= 0 && len(list) < limit; i-- {
+		id, _ := strconv.ParseUint(msgs[i].ID, 10, 64)
+		if s.deleted[msgs[i].ID] || (before != 0 && id >= before) || id <= after {
+			continue
+		}
+		list = append(list, msgs[i])
+	}
+	return list
+}
+
+func (s *Server) serveGuilds(w http.ResponseWriter, r *http.Request, p []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(p) == 0 {
+		writeError(w, errNotFound)
+		return
+	}
+	g, ok := s.guilds[p[0]]
+	if !ok {
+		writeError(w, errUnknownGuild)
+		return
+	}
+
+	if len(p) == 1 {
+		switch r.Method {
+		case "GET":
+			writeJSON(w, http.StatusOK, g)
+		case "PATCH":
+			var params struct {
+				Name              string                       `json:"name"`
+				VerificationLevel *discordgo.VerificationLevel `json:"verification_level"`
+			}
+			if readBody(r, &params) != nil {
+				writeError(w, errBadRequest)
+				return
+			}
+			if len(params.Name) > 0 {
+				g.Name = params.Name
+			}
+			if params.VerificationLevel != nil {
+				g.VerificationLevel = *params.VerificationLevel
+			}
+			update := *g // GUILD_UPDATE never contains members or channels
+			update.Members = nil
+			update.Channels = nil
+			update.Presences = nil
+			update.VoiceStates = nil
+			s.dispatch(g.ID, "GUILD_UPDATE", &update)
+			writeJSON(w, http.StatusOK, &update)
+		default:
+			writeError(w, errNotFound)
+		}
+		return
+	}
+
+	switch p[1] {
+	case "channels":
+		if len(p) == 2 && r.Method == "GET" {
+			writeJSON(w, http.StatusOK, g.Channels)
+			return
+		}
+	case "members":
+		s.serveMembers(w, r, g, p[2:])
+		return
+	case "roles":
+		s.serveRoles(w, r, g, p[2:])
+		return
+	case "bans":
+		s.serveBans(w, r, g, p[2:])
+		return
+	}
+	writeError(w, errNotFound)
+}
+
+func (s *Server) serveMembers(w http.ResponseWriter, r *http.Request, g *discordgo.Guild, p []string) {
+	if len(p) == 0 {
+		if r.Method != "GET" {
+			writeError(w, errNotFound)
+			return
+		}
+		q := r.URL.Query()
+		limit, err := strconv.Atoi(q.Get("limit"))
+		if err != nil || limit <= 0 || limit > 1000 {
+			limit = 1
+		}
+		after, _ := strconv.ParseUint(q.Get("after"), 10, 64)
+		members := make([]*discordgo.Member, 0, len(g.Members))
+		for _, m := range g.Members {
+			if id, _ := strconv.ParseUint(m.User.ID, 10, 64); id > after {
+				members = append(members, m)
+			}
+		}
+		sort.Slice(members, func(i, j int) bool {
+			a, _ := strconv.ParseUint(members[i].User.ID, 10, 64)
+			b, _ := strconv.ParseUint(members[j].User.ID, 10, 64)
+			return a < b
+		})
+		if len(members) > limit {
+			members = members[:limit]
+		}
+		writeJSON(w, http.StatusOK, members)
+		return
+	}
+
+	m := s.member(g, p[0])
+	if m == nil {
+		writeError(w, errUnknownMember)
+		return
+	}
+	switch {
+	case len(p) == 1 && r.Method == "GET":
+		writeJSON(w, http.StatusOK, m)
+	case len(p) == 1 && r.Method == "DELETE":
+		s.removeMember(g, m)
+		w.WriteHeader(http.StatusNoContent)
+	case len(p) == 1 && r.Method == "PATCH":
+		var params struct {
+			Until *time.Time `json:"communication_disabled_until"`
+		}
+		if readBody(r, &params) != nil {
+			writeError(w, errBadRequest)
+			return
+		}
+		if params.Until == nil {
+			delete(s.timeouts[g.ID], m.User.ID)
+		} else {
+			if len(s.timeouts[g.ID]) == 0 {
+				s.timeouts[g.ID] = make(map[string]time.Time)
+			}
+			s.timeouts[g.ID][m.User.ID] = *params.Until
+		}
+		s.dispatch(g.ID, "GUILD_MEMBER_UPDATE", m)
+		writeJSON(w, http.StatusOK, m)
+	case len(p) == 3 && p[1] == "roles" && (r.Method == "PUT" || r.Method == "DELETE"):
+		found := false
+		for _, role := range g.Roles {
+			found = found || role.ID == p[2]
+		}
+		if !found {
+			writeError(w, errUnknownRole)
+			return
+		}
+		roles := []string{}
+		for _, v := range m.Roles {
+			if v != p[2] {
+				roles = append(roles, v)
+			}
+		}
+		if r.Method == "PUT" {
+			roles = append(roles, p[2])
+		}
+		m.Roles = roles
+		s.dispatch(g.ID, "GUILD_MEMBER_UPDATE", m)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, errNotFound)
+	}
+}
+
+// removeMember takes the member out of the guild. Must be called inside the lock.
+func (s *Server) removeMember(g *discordgo.Guild, m *discordgo.Member) {
+	for i, v := range g.Members {
+		if v == m {
+			g.Members = append(g.Members[:i], g.Members[i+1:]...)
+			break
+		}
+	}
+	s.dispatch(g.ID, "GUILD_MEMBER_REMOVE", map[string]interface{}{"guild_id": g.ID, "user": m.User})
+}
+
+func (s *Server) serveRoles(w http.ResponseWriter, r *http.Request, g *discordgo.Guild, p []string) {
+	if len(p) == 0 {
+		switch r.Method {
+		case "GET":
+			writeJSON(w, http.StatusOK, g.Roles)
+		case "POST":
+			writeJSON(w, http.StatusOK, s.addRole(g, "new role", 0))
+		default:
+			writeError(w, errNotFound)
+		}
+		return
+	}
+
+	var role *discordgo.Role
+	index := 0
+	for i, v := range g.Roles {
+		if v.ID == p[0] {
+			role = v
+			index = i
+		}
+	}
+	if role == nil || len(p) > 1 {
+		writeError(w, errUnknownRole)
+		return
+	}
+	switch r.Method {
+	case "PATCH":
+		params := *role
+		if readBody(r, &params) != nil {
+			writeError(w, errBadRequest)
+			return
+		}
+		params.ID = role.ID
+		*role = params
+		s.dispatch(g.ID, "GUILD_ROLE_UPDATE", map[string]interface{}{"guild_id": g.ID, "role": role})
+		writeJSON(w, http.StatusOK, role)
+	case "DELETE":
+		g.Roles = append(g.Roles[:index], g.Roles[index+1:]...)
+		for _, m := range g.Members {
+			for i, v := range m.Roles {
+				if v == role.ID {
+					m.Roles = append(m.Roles[:i], m.Roles[i+1:]...)
+					break
+				}
+			}
+		}
+		s.dispatch(g.ID, "GUILD_ROLE_DELETE", map[string]interface{}{"guild_id": g.ID, "role_id": role.ID})
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, errNotFound)
+	}
+}
+
+func (s *Server) serveBans(w http.ResponseWriter, r *http.Request, g *discordgo.Guild, p []string) {
+	if len(p) == 0 {
+		if r.Method != "GET" {
+			writeError(w, errNotFound)
+			return
+		}
+		bans := []*discordgo.GuildBan{}
+		for id, reason := range s.bans[g.ID] {
+			bans = append(bans, &discordgo.GuildBan{Reason: reason, User: s.users[id]})
+		}
+		writeJSON(w, http.StatusOK, bans)
+		return
+	}
+
+	u, ok := s.users[p[0]]
+	if !ok || len(p) > 1 {
+		writeError(w, errUnknownUser)
+		return
+	}
+	switch r.Method {
+	case "PUT":
+		var params struct {
+			Reason string `json:"reason"`
+			Days   int    `json:"delete-message-days"`
+		}
+		readBody(r, &params)
+		if q := r.URL.Query(); len(q.Get("reason")) > 0 {
+			params.Reason = q.Get("reason")
+		}
+		if len(s.bans[g.ID]) == 0 {
+			s.bans[g.ID] = make(map[string]string)
+		}
+		s.bans[g.ID][u.ID] = params.Reason
+		if m := s.member(g, u.ID); m != nil {
+			s.removeMember(g, m)
+		}
+		s.dispatch(g.ID, "GUILD_BAN_ADD", map[string]interface{}{"guild_id": g.ID, "user": u})
+		w.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		if _, ok := s.bans[g.ID][u.ID]; !ok {
+			writeError(w, errUnknownBan)
+			return
+		}
+		delete(s.bans[g.ID], u.ID)
+		s.dispatch(g.ID, "GUILD_BAN_REMOVE", map[string]interface{}{"guild_id": g.ID, "user": u})
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, errNotFound)
+	}
+}
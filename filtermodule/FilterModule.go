@@ -3,6 +3,7 @@ package filtermodule
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -13,13 +14,15 @@ import (
 // FilterModule implements word filters that allow you to look for spoilers or profanity uses regex matching.
 type FilterModule struct {
 	filters map[string]*regexp.Regexp
-	lastmsg int64 // Universal saturation limit on all filter responses
+	words   map[string]map[string]*regexp.Regexp // Each word of each filter on its own, so we can tell which one matched
+	lastmsg int64                                // Universal saturation limit on all filter responses
 }
 
 // New instance of FilterModule
 func New(info *bot.GuildInfo) *FilterModule {
 	w := &FilterModule{
 		filters: make(map[string]*regexp.Regexp),
+		words:   make(map[string]map[string]*regexp.Regexp),
 		lastmsg: 0,
 	}
 	for k := range info.Config.Filter.Filters {
@@ -41,37 +44,100 @@ func (w *FilterModule) Commands() []bot.Command {
 		&removeFilterCommand{w},
 		&deleteFilterCommand{w},
 		&searchFilterCommand{},
+		bot.TypedCommand(&testFilterCommand{w}),
+		bot.TypedCommand(&filterStatsCommand{}),
+		bot.TypedCommand(&falsePositiveCommand{}),
 	}
 }
 
 // Description of the module
 func (w *FilterModule) Description() string {
-	return "Implements customizable filters that search for forbiddan words or phrases and removes them with a customizable response and excludable channels."
+	return "Implements customizable filters that search messages, attachment names and embedded links for forbidden words or phrases, even when they are disguised with accents, lookalike letters, invisible characters or leetspeak. Each filter can log, notify the mods, delete, warn or silence, with a customizable response, excludable channels and exempt roles."
+}
+
+// filterTargets returns every piece of text in a message that filters are matched against: the message itself, a
+// normalized copy of it, and the names of its attachments and the URLs of its embeds.
+func filterTargets(m *discordgo.Message) []string {
+	targets := []string{m.Content}
+	if s := normalizeText(m.Content); s != m.Content {
+		targets = append(targets, s)
+	}
+	for _, v := range m.Attachments {
+		targets = append(targets, v.Filename)
+	}
+	for _, v := range m.Embeds {
+		if len(v.URL) > 0 {
+			targets = append(targets, v.URL)
+		}
+	}
+	return targets
+}
+
+// describeMessage returns the contents of a message along with anything else in it that filters look at
+func describeMessage(m *discordgo.Message) string {
+	s := m.Content
+	for _, v := range m.Attachments {
+		s += "\nAttachment: " + v.Filename
+	}
+	for _, v := range m.Embeds {
+		if len(v.URL) > 0 {
+			s += "\nEmbedded URL: <" + v.URL + ">"
+		}
+	}
+	return s
+}
+
+// exempt returns true if the message was sent in a channel excluded from the filter or by a member of an exempt role
+func (w *FilterModule) exempt(info *bot.GuildInfo, filter string, m *discordgo.Message) bool {
+	if _, ok := info.Config.Filter.Channels[filter][bot.DiscordChannel(m.ChannelID)]; ok {
+		return true
+	}
+	roles := info.Config.Filter.ExemptRoles[filter]
+	return len(roles) > 0 && info.DG.UserHasAnyRole(bot.DiscordUser(m.Author.ID), info.ID, roles)
 }
 
 func (w *FilterModule) matchFilter(info *bot.GuildInfo, m *discordgo.Message) bool {
-	timestamp := bot.GetTimestamp(m)
+	targets := filterTargets(m)
+	matched := []string{}
 	for k, v := range w.filters {
-		if v == nil { // skip empty regex
+		if v == nil || w.exempt(info, k, m) { // skip empty regex
 			continue
 		}
-		if c, ok := info.Config.Filter.Channels[k]; ok {
-			if _, ok := c[bot.DiscordChannel(m.ChannelID)]; ok {
-				continue // This channel is excluded from this filter so skip it
+		for _, s := range targets {
+			if v.MatchString(s) {
+				matched = append(matched, k)
+				break
 			}
 		}
-		if v.MatchString(m.Content) {
-			ch, _ := info.Bot.DG.State.Channel(m.ChannelID)
-			info.ChannelMessageDelete(ch, m.ID)
-			if bot.RateLimit(&w.lastmsg, 5, timestamp.Unix()) {
-				if s := info.Config.Filter.Responses[k]; len(s) > 0 {
-					info.SendMessage(bot.DiscordChannel(m.ChannelID), s)
-				}
-			}
-			return true
+	}
+	if len(matched) == 0 {
+		return false
+	}
+
+	// If several filters match, only the harshest one acts on the message
+	sort.Strings(matched)
+	filter := matched[0]
+	actions := info.Config.Filter.Actions
+	action := actions[filter]
+	for _, k := range matched[1:] {
+		if actions[k].Harsher(action) {
+			filter = k
+			action = actions[k]
+		}
+	}
+
+	timestamp := bot.GetTimestamp(m)
+	number := info.FilterMessage(m, action, "the "+filter+" filter", describeMessage(m))
+	w.recordHits(info, m, matched, targets, filter, number)
+	if !action.Deletes() {
+		return false
+	}
+	if bot.RateLimit(&w.lastmsg, 5, timestamp.Unix()) {
+		if s := info.Config.Filter.Responses[filter]; len(s) > 0 {
+			info.SendMessage(bot.DiscordChannel(m.ChannelID), s)
 		}
 	}
-	return false
+	return true
 }
 
 // OnMessageCreate discord hook
@@ -84,26 +150,46 @@ func (w *FilterModule) OnMessageUpdate(info *bot.GuildInfo, m *discordgo.Message
 	w.matchFilter(info, m)
 }
 
-// OnCommand discord hook
+// OnCommand discord hook. The commands that manage filters are never filtered, because their arguments are often the
+// very words the filters look for.
 func (w *FilterModule) OnCommand(info *bot.GuildInfo, m *discordgo.Message) bool {
+	if args, _ := bot.ParseArguments(strings.TrimPrefix(m.Content, info.Config.Basic.CommandPrefix)); len(args) > 0 {
+		for _, c := range w.Commands() {
+			if strings.EqualFold(args[0], c.Info().Name) {
+				return false
+			}
+		}
+	}
 	return w.matchFilter(info, m)
 }
 
 var templateregex = regexp.MustCompile("%%")
 
+// applyTemplate puts a group of words into a filter's template, if it has one
+func applyTemplate(template string, words string) string {
+	if len(template) > 0 {
+		return templateregex.ReplaceAllLiteralString(template, words)
+	}
+	return words
+}
+
 // UpdateRegex updates all filter regexes
 func (w *FilterModule) UpdateRegex(filter string, info *bot.GuildInfo) (err error) {
 	combine := ""
 	w.filters[filter] = nil
+	w.words[filter] = make(map[string]*regexp.Regexp)
 	if len(info.Config.Filter.Filters[filter]) > 0 {
 		combine = "(" + strings.Join(bot.MapToSlice(info.Config.Filter.Filters[filter]), "|") + ")"
 	}
-	if len(info.Config.Filter.Templates[filter]) > 0 {
-		combine = templateregex.ReplaceAllLiteralString(info.Config.Filter.Templates[filter], combine)
-	}
+	combine = applyTemplate(info.Config.Filter.Templates[filter], combine)
 	if len(combine) > 0 {
 		w.filters[filter], err = regexp.Compile(combine)
 	}
+	if err == nil {
+		for word := range info.Config.Filter.Filters[filter] {
+			w.words[filter][word], _ = regexp.Compile(applyTemplate(info.Config.Filter.Templates[filter], "("+word+")"))
+		}
+	}
 	return
 }
 
@@ -293,8 +379,10 @@ func (c *deleteFilterCommand) Process(args []string, msg *discordgo.Message, ind
 
 	delete(info.Config.Filter.Filters, filter)
 	delete(info.Config.Filter.Channels, filter)
+	delete(info.Config.Filter.ExemptRoles, filter)
 	delete(info.Config.Filter.Responses, filter)
 	delete(info.Config.Filter.Templates, filter)
+	delete(info.Config.Filter.Actions, filter)
 	delete(c.m.filters, filter)
 	c.m.UpdateRegex(filter, info)
 
@@ -0,0 +1,85 @@
+package filtermodule
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Letters that look like latin letters, or are latin letters with accents that people add to slip words past a filter.
+// Each string is every lowercase character that should be treated as the letter it's keyed by.
+var confusableGroups = map[rune]string{
+	'a': "àáâãäåāăąǎȁȃȧạảấầẩẫậắằẳẵặаαɑ@4",
+	'b': "ƀɓвβьъ",
+	'c': "çćĉċčсϲ¢",
+	'd': "ďđɗԁ",
+	'e': "èéêëēĕėęěȅȇẹẻẽếềểễệеєεё3",
+	'f': "ƒ",
+	'g': "ĝğġģǧɡ",
+	'h': "ĥħнһ",
+	'i': "ìíîïĩīĭįıǐȉȋịỉіїι1!|",
+	'j': "ĵјǰ",
+	'k': "ķкκ",
+	'l': "ĺļľŀłӏ",
+	'm': "мμ",
+	'n': "ñńņňŉηп",
+	'o': "òóôõöøōŏőǒȍȏọỏốồổỗộớờởỡợоοσ0",
+	'p': "рρ",
+	'r': "ŕŗřгя",
+	's': "śŝşšșѕ$5",
+	't': "ţťŧțтτ7",
+	'u': "ùúûüũūŭůűųǔȕȗụủưυ",
+	'v': "ν",
+	'w': "ŵшω",
+	'x': "хχ×",
+	'y': "ýÿŷуỳỵỷỹγ",
+	'z': "źżžƶ",
+}
+
+var confusables = make(map[rune]rune)
+
+func init() {
+	for k, v := range confusableGroups {
+		for _, r := range v {
+			confusables[r] = k
+		}
+	}
+}
+
+// foldRune turns stylized unicode letters, like fullwidth, circled or mathematical letters, back into plain ones
+func foldRune(r rune) rune {
+	switch {
+	case r >= 0xFF01 && r <= 0xFF5E: // Fullwidth ASCII
+		return r - 0xFEE0
+	case r >= 0x1D400 && r <= 0x1D6A3: // Mathematical bold, italic, script, fraktur, double-struck, sans-serif and monospace letters
+		return 'a' + (r-0x1D400)%52%26
+	case r >= 0x1D7CE && r <= 0x1D7FF: // Mathematical digits
+		return '0' + (r-0x1D7CE)%10
+	case r >= 0x24B6 && r <= 0x24CF: // Circled capital letters
+		return 'a' + r - 0x24B6
+	case r >= 0x24D0 && r <= 0x24E9: // Circled small letters
+		return 'a' + r - 0x24D0
+	case r >= 0x249C && r <= 0x24B5: // Parenthesized small letters
+		return 'a' + r - 0x249C
+	case r >= 0x1F130 && r <= 0x1F189: // Squared, negative circled and negative squared capital letters
+		return 'a' + (r-0x1F130)%26
+	case r >= 0x1F1E6 && r <= 0x1F1FF: // Regional indicators
+		return 'a' + r - 0x1F1E6
+	}
+	return r
+}
+
+// normalizeText undoes the usual tricks for getting a word past a filter: invisible characters, accents, homoglyphs
+// from other alphabets, stylized unicode letters and leetspeak. The result is lowercase. It is only ever used as an
+// extra string to match against, because it mangles numbers and punctuation.
+func normalizeText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.In(r, unicode.Cf, unicode.Mn, unicode.Me) {
+			return -1 // Zero-width spaces and joiners, soft hyphens, and combining accents or zalgo marks
+		}
+		r = unicode.ToLower(foldRune(r))
+		if c, ok := confusables[r]; ok {
+			return c
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,283 @@
+package filtermodule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	bot "../sweetiebot"
+	"github.com/blackhole12/discordgo"
+)
+
+// How far back !filterstats looks if it isn't given a duration
+var defaultStatsDuration = bot.Duration{Count: 30, Interval: 4}
+
+// The most words and false positives listed by !filterstats
+const maxStatsResults = 10
+
+// matchWord returns the first word in the filter that matches any of the targets. This is empty if the filter has no
+// words, in which case only its template could have matched.
+func (w *FilterModule) matchWord(filter string, targets []string) string {
+	words := make([]string, 0, len(w.words[filter]))
+	for k := range w.words[filter] {
+		words = append(words, k)
+	}
+	sort.Strings(words)
+	for _, word := range words {
+		if r := w.words[filter][word]; r != nil {
+			for _, s := range targets {
+				if r.MatchString(s) {
+					return word
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// recordHits saves which word of each matching filter matched a message, for !filterstats. Only the filter that acted
+// on the message gets the case it created, which is 0 if it didn't create one.
+func (w *FilterModule) recordHits(info *bot.GuildInfo, m *discordgo.Message, matched []string, targets []string, acted string, number uint64) {
+	if !info.Bot.DB.Status.Get() {
+		return
+	}
+	for _, k := range matched {
+		var casenumber *uint64
+		if k == acted && number != 0 {
+			casenumber = &number
+		}
+		info.Bot.DB.AddFilterHit(bot.SBatoi(info.ID), k, w.matchWord(k, targets), bot.SBatoi(m.Author.ID), casenumber)
+	}
+}
+
+// displayWord shows a word in the stats, or that the template matched if the filter has no words
+func displayWord(filter string, word string) string {
+	if len(word) == 0 {
+		return filter + " (template)"
+	}
+	return filter + "/" + word
+}
+
+func falsePositives(n int) string {
+	switch n {
+	case 0:
+		return ""
+	case 1:
+		return " (1 false positive)"
+	}
+	return fmt.Sprintf(" (%v false positives)", n)
+}
+
+type testFilterCommand struct {
+	m *FilterModule
+}
+
+func (c *testFilterCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "TestFilter",
+		Usage:     "Checks if a filter matches some text.",
+		Sensitive: true,
+	}
+}
+
+func (c *testFilterCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	filter := args.String("filter")
+	if _, ok := info.Config.Filter.Filters[filter]; !ok {
+		return "```\nThe " + info.Sanitize(filter, bot.CleanCodeBlock) + " filter does not exist! All filters: " + strings.Join(getAllFilters(info), ", ") + "```", false, nil
+	}
+	text := args.String("text")
+	normalized := normalizeText(text)
+	regex := c.m.filters[filter]
+
+	s := []string{}
+	if regex == nil {
+		s = append(s, "Regex: (empty, matches nothing)")
+	} else {
+		s = append(s, "Regex: "+regex.String())
+	}
+	if t := info.Config.Filter.Templates[filter]; len(t) > 0 {
+		s = append(s, "Template: "+t)
+	}
+	if normalized != text {
+		s = append(s, "Normalized text: "+normalized)
+	}
+	if regex == nil || (!regex.MatchString(text) && !regex.MatchString(normalized)) {
+		s = append(s, "No match.")
+		return "```\n" + info.Sanitize(strings.Join(s, "\n"), bot.CleanCodeBlock) + "```", false, nil
+	}
+
+	words := []string{}
+	for _, word := range bot.MapToSlice(info.Config.Filter.Filters[filter]) {
+		r := c.m.words[filter][word]
+		switch {
+		case r == nil:
+		case r.MatchString(text):
+			words = append(words, word)
+		case r.MatchString(normalized):
+			words = append(words, word+" (after normalizing)")
+		}
+	}
+	sort.Strings(words)
+	if len(words) == 0 {
+		s = append(s, "Matched by the template.")
+	} else {
+		s = append(s, "Matched by: "+strings.Join(words, ", "))
+	}
+	action := info.Config.Filter.Actions[filter]
+	if len(action) == 0 {
+		action = bot.FilterDelete
+	}
+	s = append(s, "Action: "+string(action))
+	return "```\n" + info.Sanitize(strings.Join(s, "\n"), bot.CleanCodeBlock) + "```", false, nil
+}
+
+func (c *testFilterCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Tests [text] against [filter] without posting it, showing the compiled regex, the template, the text after normalizing accents, lookalike letters and leetspeak, and which words in the filter matched.",
+		Params: []bot.CommandUsageParam{
+			{Name: "filter", Desc: "The name of a filter.", Optional: false},
+			{Name: "text", Desc: "The rest of the message is tested against the filter.", Optional: false, Type: bot.ArgRest},
+		},
+	}
+}
+
+type filterStatsCommand struct{}
+
+func (c *filterStatsCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "FilterStats",
+		Usage:     "Shows how often filters are triggered.",
+		Sensitive: true,
+	}
+}
+
+func (c *filterStatsCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	filter := args.String("filter")
+	if _, ok := info.Config.Filter.Filters[filter]; len(filter) > 0 && !ok {
+		return "```\nThe " + info.Sanitize(filter, bot.CleanCodeBlock) + " filter does not exist! All filters: " + strings.Join(getAllFilters(info), ", ") + "```", false, nil
+	}
+	duration := defaultStatsDuration
+	if args.Has("duration") {
+		duration = args.Duration("duration")
+	}
+	now := bot.GetTimestamp(msg)
+	gID := bot.SBatoi(info.ID)
+
+	filters := make(map[string]*bot.FilterStat)
+	hit := make(map[string]bool)
+	words := []string{}
+	for _, v := range info.Bot.DB.GetFilterStats(gID, duration.After(now).Sub(now)) {
+		if len(filter) > 0 && v.Filter != filter {
+			continue
+		}
+		if _, ok := filters[v.Filter]; !ok {
+			filters[v.Filter] = &bot.FilterStat{Filter: v.Filter}
+		}
+		filters[v.Filter].Hits += v.Hits
+		filters[v.Filter].FalsePositives += v.FalsePositives
+		hit[v.Filter+"/"+v.Word] = true
+		if len(words) < maxStatsResults {
+			words = append(words, fmt.Sprintf("  %s: %v%s", displayWord(v.Filter, v.Word), v.Hits, falsePositives(v.FalsePositives)))
+		}
+	}
+
+	s := []string{"Filter hits in the past " + duration.String() + ":"}
+	names := getAllFilters(info)
+	sort.Strings(names)
+	unused := []string{}
+	for _, k := range names {
+		if len(filter) > 0 && k != filter {
+			continue
+		}
+		if v, ok := filters[k]; ok {
+			s = append(s, fmt.Sprintf("  %s: %v%s", k, v.Hits, falsePositives(v.FalsePositives)))
+		} else {
+			s = append(s, "  "+k+": 0")
+		}
+		for _, word := range bot.MapToSlice(info.Config.Filter.Filters[k]) {
+			if !hit[k+"/"+word] {
+				unused = append(unused, k+"/"+word)
+			}
+		}
+	}
+	if len(words) > 0 {
+		s = append(s, "Top words:")
+		s = append(s, words...)
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		s = append(s, "Words with no hits: "+strings.Join(unused, ", "))
+	}
+
+	positives := []string{}
+	for _, v := range info.Bot.DB.GetFalsePositives(gID, maxStatsResults) {
+		if len(filter) == 0 || v.Filter == filter {
+			positives = append(positives, fmt.Sprintf("  Case #%v: %s, sent by %s on %s", v.Case, displayWord(v.Filter, v.Word), info.GetUserName(bot.NewDiscordUser(v.User)), info.ApplyTimezone(v.Timestamp, bot.DiscordUser(msg.Author.ID)).Format("Jan 2, 3:04pm")))
+		}
+	}
+	if len(positives) > 0 {
+		s = append(s, "Recent false positives:")
+		s = append(s, positives...)
+	}
+	return "```\n" + info.Sanitize(strings.Join(s, "\n"), bot.CleanCodeBlock) + "```", len(s) > 15, nil
+}
+
+func (c *filterStatsCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Shows how many times each filter was triggered, the words that triggered them the most, the words that never triggered them, and the most recent false positives reported with `" + info.Config.Basic.CommandPrefix + "falsepositive`. Use this to prune stale words and find patterns that are too broad.",
+		Params: []bot.CommandUsageParam{
+			{Name: "filter", Desc: "Only show the stats for this filter.", Optional: true},
+			{Name: "duration", Desc: "If the keyword `for:` is used, looks for a duration of the form `for: 7 DAYS` and only counts hits in that period. Defaults to " + defaultStatsDuration.String() + ".", Optional: true, Type: bot.ArgDuration, Keyword: "for:"},
+		},
+	}
+}
+
+type falsePositiveCommand struct{}
+
+func (c *falsePositiveCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "FalsePositive",
+		Usage:     "Reports that a filter deleted a message it shouldn't have.",
+		Sensitive: true,
+	}
+}
+
+func (c *falsePositiveCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	number := args.Int("case", 0)
+	gID := bot.SBatoi(info.ID)
+	var mcase *bot.ModCase
+	if number > 0 {
+		mcase = info.Bot.DB.GetCase(gID, uint64(number))
+	}
+	if mcase == nil {
+		return fmt.Sprintf("```\nError: case #%v doesn't exist.```", number), false, nil
+	}
+	ok, err := info.Bot.DB.MarkFalsePositive(gID, uint64(number))
+	if err != nil {
+		return bot.ReturnError(err)
+	}
+	if !ok {
+		return fmt.Sprintf("```\nCase #%v wasn't created by a filter.```", number), false, nil
+	}
+	if !mcase.Pardoned {
+		if err := info.Bot.DB.PardonCase(gID, uint64(number)); err != nil {
+			return bot.ReturnError(err)
+		}
+	}
+	return fmt.Sprintf("```\nMarked case #%v as a false positive and pardoned it.```", number), false, nil
+}
+
+func (c *falsePositiveCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Marks a case created by a filter as a false positive, which pardons it and shows it in `" + info.Config.Basic.CommandPrefix + "filterstats`.",
+		Params: []bot.CommandUsageParam{
+			{Name: "case", Desc: "The case number.", Optional: false, Type: bot.ArgInt},
+		},
+	}
+}
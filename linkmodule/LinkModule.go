@@ -0,0 +1,248 @@
+package linkmodule
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	bot "../sweetiebot"
+	"github.com/blackhole12/discordgo"
+)
+
+// LinkConfig holds the link policy of a server. It is registered as the "Links" config category.
+type LinkConfig struct {
+	AllowDomains map[string]bool                        `json:"allowdomains"`
+	DenyDomains  map[string]bool                        `json:"denydomains"`
+	ChannelAllow map[bot.DiscordChannel]map[string]bool `json:"channelallow"`
+	ChannelDeny  map[bot.DiscordChannel]map[string]bool `json:"channeldeny"`
+	BlockInvites bool                                   `json:"blockinvites"`
+	InviteAllow  map[string]bool                        `json:"inviteallow"`
+	Shorteners   map[string]bool                        `json:"shorteners"`
+	ExemptRoles  map[bot.DiscordRole]bool               `json:"exemptroles"`
+	Action       bot.FilterAction                       `json:"action"`
+	Response     string                                 `json:"response"`
+}
+
+func init() {
+	bot.RegisterConfig("Links", func() interface{} {
+		return &LinkConfig{Action: bot.FilterDelete}
+	}, map[string]string{
+		"allowdomains": "Links to these domains, or any of their subdomains, are always allowed, even if they are denied by another rule or a channel only allows certain domains.",
+		"denydomains":  "Links to these domains, or any of their subdomains, are not allowed anywhere.",
+		"channelallow": "Maps channels to the only domains that can be linked in them, in addition to Links.AllowDomains. Use this to restrict an art channel to image hosts, for example.",
+		"channeldeny":  "Maps channels to domains that can't be linked in them, in addition to Links.DenyDomains.",
+		"blockinvites": "If true, invites to other discord servers are not allowed, unless the server or the invite is in Links.InviteAllow. Invites to this server are always allowed.",
+		"inviteallow":  "Invite codes or server IDs of partner servers that can be linked even if Links.BlockInvites is true.",
+		"shorteners":   "Links to these URL shorteners, like bit.ly or tinyurl.com, are followed to find out where they actually go, and the destination is checked against the link rules instead.",
+		"exemptroles":  "Members with any of these roles can post any link.",
+		"action":       "What happens to a message that breaks the link rules: log, notify, delete, warn or silence. See Filter.Actions for what each action does.",
+		"response":     "Sent to the channel after a message that breaks the link rules is deleted. Only one response is sent every 5 seconds.",
+	})
+}
+
+// Links returns the link policy of the server
+func Links(info *bot.GuildInfo) *LinkConfig {
+	return info.Config.Section("Links").(*LinkConfig)
+}
+
+var inviteRegex = regexp.MustCompile(`(?i)(?:discord\.gg|discord(?:app)?\.com/invite)/([a-z0-9-]+)`)
+
+// The most invites or shortened links remembered before the caches are cleared
+const maxCache = 1000
+
+// LinkModule enforces the link policy of a server on every message
+type LinkModule struct {
+	sync.Mutex
+	invites  map[string]string // Guild IDs of every invite code we've looked up
+	resolver Resolver
+	lastmsg  int64 // Saturation limit on responses
+}
+
+// New instance of LinkModule
+func New() *LinkModule {
+	return &LinkModule{
+		invites:  make(map[string]string),
+		resolver: NewHTTPResolver(),
+	}
+}
+
+// Name of the module
+func (w *LinkModule) Name() string {
+	return "Links"
+}
+
+// Commands in the module
+func (w *LinkModule) Commands() []bot.Command {
+	return []bot.Command{}
+}
+
+// Description of the module
+func (w *LinkModule) Description() string {
+	return "Enforces link rules: domains can be allowed or denied everywhere or in specific channels, invites to other discord servers can be blocked except for partner servers, and shortened links are expanded before they are checked. Members with exempt roles can post anything. Messages that break the rules can be logged, reported to the mods, deleted, or deleted with a warning or a silence, just like filters."
+}
+
+// SetResolver replaces the resolver used to expand shortened links
+func (w *LinkModule) SetResolver(r Resolver) {
+	w.Lock()
+	defer w.Unlock()
+	w.resolver = r
+}
+
+// matchDomain returns true if the host is one of the domains or a subdomain of one
+func matchDomain(domains map[string]bool, host string) bool {
+	for k := range domains {
+		d := strings.Trim(strings.ToLower(k), ". ")
+		if len(d) > 0 && (host == d || strings.HasSuffix(host, "."+d)) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkHost returns the lowercase host of a link, without the port or a leading www
+func linkHost(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(u.Host)
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	return strings.TrimPrefix(host, "www.")
+}
+
+// inviteGuild looks up which server an invite belongs to. Returns an empty string if the invite is invalid.
+func (w *LinkModule) inviteGuild(info *bot.GuildInfo, code string) string {
+	w.Lock()
+	guild, ok := w.invites[code]
+	w.Unlock()
+	if ok {
+		return guild
+	}
+	invite, err := info.DG.Invite(code)
+	if err != nil || invite.Guild == nil {
+		return "" // Don't remember failures, in case discord was just having problems
+	}
+	w.Lock()
+	if len(w.invites) >= maxCache {
+		w.invites = make(map[string]string)
+	}
+	w.invites[code] = invite.Guild.ID
+	w.Unlock()
+	return invite.Guild.ID
+}
+
+// checkInvite returns why an invite isn't allowed, or an empty string if it is
+func (w *LinkModule) checkInvite(info *bot.GuildInfo, config *LinkConfig, code string) string {
+	for k := range config.InviteAllow {
+		if m := inviteRegex.FindStringSubmatch(k); m != nil {
+			k = m[1] // Someone put the whole invite link in the allowlist
+		}
+		if k == code {
+			return ""
+		}
+	}
+	guild := w.inviteGuild(info, code)
+	if guild == info.ID || (len(guild) > 0 && config.InviteAllow[guild]) {
+		return ""
+	}
+	return "invite to another server"
+}
+
+// checkDomain returns why a link to the host isn't allowed in the channel, or an empty string if it is
+func checkDomain(config *LinkConfig, channel bot.DiscordChannel, host string) string {
+	if len(host) == 0 || matchDomain(config.AllowDomains, host) || matchDomain(config.ChannelAllow[channel], host) {
+		return ""
+	}
+	if len(config.ChannelAllow[channel]) > 0 {
+		return "links to " + host + " aren't allowed in this channel"
+	}
+	if matchDomain(config.DenyDomains, host) || matchDomain(config.ChannelDeny[channel], host) {
+		return "links to " + host + " aren't allowed"
+	}
+	return ""
+}
+
+// checkLink returns why a link isn't allowed in the channel, or an empty string if it is. Shortened links are checked
+// against where they actually go.
+func (w *LinkModule) checkLink(info *bot.GuildInfo, config *LinkConfig, channel bot.DiscordChannel, link string) string {
+	host := linkHost(link)
+	if matchDomain(config.Shorteners, host) && !matchDomain(config.AllowDomains, host) {
+		w.Lock()
+		resolver := w.resolver
+		w.Unlock()
+		if resolved, err := resolver.Resolve(link); err == nil && resolved != link {
+			if reason := w.checkLink(info, config, channel, resolved); len(reason) > 0 {
+				return reason + ", shortened with " + host
+			}
+			return ""
+		}
+	}
+	if m := inviteRegex.FindStringSubmatch(link); m != nil && config.BlockInvites {
+		return w.checkInvite(info, config, m[1]) // Invites follow the invite rules instead of the domain rules
+	}
+	return checkDomain(config, channel, host)
+}
+
+// checkMessage returns why a message breaks the link rules, or an empty string if it doesn't
+func (w *LinkModule) checkMessage(info *bot.GuildInfo, config *LinkConfig, m *discordgo.Message) string {
+	channel := bot.DiscordChannel(m.ChannelID)
+	links := bot.FindURLs(m.Content)
+	for _, v := range m.Embeds {
+		if len(v.URL) > 0 {
+			links = append(links, v.URL)
+		}
+	}
+	for _, link := range links {
+		if reason := w.checkLink(info, config, channel, link); len(reason) > 0 {
+			return reason
+		}
+	}
+	if config.BlockInvites { // Invites don't need http:// in front of them to work
+		for _, match := range inviteRegex.FindAllStringSubmatch(m.Content, -1) {
+			if reason := w.checkInvite(info, config, match[1]); len(reason) > 0 {
+				return reason
+			}
+		}
+	}
+	return ""
+}
+
+func (w *LinkModule) enforce(info *bot.GuildInfo, m *discordgo.Message) bool {
+	config := Links(info)
+	if len(config.ExemptRoles) > 0 && info.DG.UserHasAnyRole(bot.DiscordUser(m.Author.ID), info.ID, config.ExemptRoles) {
+		return false
+	}
+	reason := w.checkMessage(info, config, m)
+	if len(reason) == 0 {
+		return false
+	}
+
+	action := config.Action
+	timestamp := bot.GetTimestamp(m)
+	info.FilterMessage(m, action, "the link policy ("+reason+")", m.Content)
+	if !action.Deletes() {
+		return false
+	}
+	if len(config.Response) > 0 && bot.RateLimit(&w.lastmsg, 5, timestamp.Unix()) {
+		info.SendMessage(bot.DiscordChannel(m.ChannelID), config.Response)
+	}
+	return true
+}
+
+// OnMessageCreate discord hook
+func (w *LinkModule) OnMessageCreate(info *bot.GuildInfo, m *discordgo.Message) {
+	w.enforce(info, m)
+}
+
+// OnMessageUpdate discord hook
+func (w *LinkModule) OnMessageUpdate(info *bot.GuildInfo, m *discordgo.Message) {
+	w.enforce(info, m)
+}
+
+// OnCommand discord hook
+func (w *LinkModule) OnCommand(info *bot.GuildInfo, m *discordgo.Message) bool {
+	return w.enforce(info, m)
+}
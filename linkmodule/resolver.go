@@ -0,0 +1,158 @@
+package linkmodule
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// How many redirects are followed when expanding a shortened link
+const maxRedirects = 5
+
+// How long expanding a link can take in total, including every redirect. This happens while a message is being
+// checked, so it has to be short.
+const resolveTimeout = 3 * time.Second
+
+// How long a link that failed to resolve is remembered before it's tried again. This is short, since the failure
+// might only have been a timeout or a shortener that was briefly down.
+const resolveFailureTTL = time.Minute
+
+// Resolver expands a shortened link into the link it redirects to
+type Resolver interface {
+	Resolve(link string) (string, error)
+}
+
+// Address ranges that links are never followed into, so nobody can use a shortener to make the bot poke at the
+// machine it runs on or the network around it
+var blockedNets = parseCIDRs(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16",
+	"::/128", "::1/128", "fc00::/7", "fe80::/10",
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	r := make([]*net.IPNet, len(cidrs))
+	for i, v := range cidrs {
+		_, r[i], _ = net.ParseCIDR(v)
+	}
+	return r
+}
+
+func blockedIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDial refuses connections to blocked addresses. It runs after the host name has been looked up, so a domain
+// that points somewhere private is refused as well.
+func checkDial(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+		return errors.New("refusing to connect to " + host)
+	}
+	return nil
+}
+
+// HTTPResolver expands links by requesting them and following their redirects one at a time, without downloading
+// the page at the end. Only http and https links to public addresses are followed. Results are cached, since the same
+// link is usually posted over and over. Failures are only cached for a minute, so a dead shortener doesn't slow down
+// every message but a link that timed out once gets checked again.
+type HTTPResolver struct {
+	client *http.Client
+	lock   sync.Mutex
+	cache  map[string]resolveResult
+}
+
+type resolveResult struct {
+	link    string
+	err     error
+	expires time.Time // Only set for failures
+}
+
+// NewHTTPResolver creates a resolver that gives up on a link after 3 seconds
+func NewHTTPResolver() *HTTPResolver {
+	dialer := &net.Dialer{Timeout: resolveTimeout, Control: checkDial}
+	return &HTTPResolver{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext:           dialer.DialContext,
+				TLSHandshakeTimeout:   resolveTimeout,
+				ResponseHeaderTimeout: resolveTimeout,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		cache: make(map[string]resolveResult),
+	}
+}
+
+// Resolve follows the redirects of a link and returns where they end up
+func (r *HTTPResolver) Resolve(link string) (string, error) {
+	r.lock.Lock()
+	result, ok := r.cache[link]
+	r.lock.Unlock()
+	if ok && (result.err == nil || time.Now().Before(result.expires)) {
+		return result.link, result.err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	result.link, result.err = r.follow(ctx, link)
+	if result.err != nil {
+		result.expires = time.Now().Add(resolveFailureTTL)
+	}
+
+	r.lock.Lock()
+	if len(r.cache) >= maxCache {
+		r.cache = make(map[string]resolveResult)
+	}
+	r.cache[link] = result
+	r.lock.Unlock()
+	return result.link, result.err
+}
+
+func (r *HTTPResolver) follow(ctx context.Context, link string) (string, error) {
+	resolved := link
+	next, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	for i := 0; ; i++ {
+		if i >= maxRedirects {
+			return "", errors.New("too many redirects")
+		}
+		if next.Scheme != "http" && next.Scheme != "https" {
+			return "", errors.New("refusing to follow a " + next.Scheme + " link")
+		}
+		req, err := http.NewRequest("HEAD", next.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := r.client.Do(req.WithContext(ctx))
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		location := resp.Header.Get("Location")
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || len(location) == 0 {
+			return resolved, nil
+		}
+		if next, err = next.Parse(location); err != nil {
+			return "", err
+		}
+		resolved = next.String()
+	}
+}
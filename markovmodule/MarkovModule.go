@@ -28,9 +28,9 @@ func (w *MarkovModule) Name() string {
 // Commands in the module
 func (w *MarkovModule) Commands() []bot.Command {
 	return []bot.Command{
-		&episodeGenCommand{},
-		&episodeQuoteCommand{},
-		&shipCommand{},
+		bot.TypedCommand(&episodeGenCommand{}),
+		bot.TypedCommand(&episodeQuoteCommand{}),
+		bot.TypedCommand(&shipCommand{}),
 	}
 }
 
@@ -50,7 +50,7 @@ func (c *episodeGenCommand) Info() *bot.CommandInfo {
 func (c *episodeGenCommand) Name() string {
 	return "episodegen"
 }
-func (c *episodeGenCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *episodeGenCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
@@ -58,14 +58,8 @@ func (c *episodeGenCommand) Process(args []string, msg *discordgo.Message, indic
 		return "```\nSorry, I'm busy processing another request right now. Please try again later!```", false, nil
 	}
 	defer c.lock.Clear()
-	maxlines := info.Config.Markov.DefaultLines
-	double := true
-	if len(args) > 0 {
-		maxlines, _ = strconv.Atoi(args[0])
-	}
-	if len(args) > 1 {
-		double = (strings.ToLower(args[1]) != "single")
-	}
+	maxlines := args.Int("lines", info.Config.Markov.DefaultLines)
+	double := args.String("single") != "single"
 	if maxlines > 50 {
 		maxlines = 50
 	}
@@ -95,8 +89,8 @@ func (c *episodeGenCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Randomly generates a my little pony episode using a markov chain, up to a maximum line count of `lines`. Will be sent via PM if the line count exceeds 5.",
 		Params: []bot.CommandUsageParam{
-			{Name: "lines", Desc: "Number of dialogue lines to generate", Optional: false},
-			{Name: "single", Desc: "The markov chain uses double-lookback by default, if this is specified, will revert to single-lookback, which produces much more chaotic results.", Optional: false},
+			{Name: "lines", Desc: "Number of dialogue lines to generate", Optional: true, Type: bot.ArgInt},
+			{Name: "single", Desc: "The markov chain uses double-lookback by default, if this is specified, will revert to single-lookback, which produces much more chaotic results.", Optional: true, Type: bot.ArgEnum, Values: []string{"single", "double"}},
 		},
 	}
 }
@@ -104,6 +98,8 @@ func (c *episodeGenCommand) UsageShort() string { return "Randomly generates epi
 
 var quoteargregex = regexp.MustCompile("s[0-9]+e[0-9]+")
 
+const episodeQuoteParam = "S0E00:000-000|action|speech|\"Character Name\""
+
 type episodeQuoteCommand struct {
 }
 
@@ -113,7 +109,7 @@ func (c *episodeQuoteCommand) Info() *bot.CommandInfo {
 		Usage: "Quotes random or specific lines from the show.",
 	}
 }
-func (c *episodeQuoteCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *episodeQuoteCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
@@ -122,10 +118,10 @@ func (c *episodeQuoteCommand) Process(args []string, msg *discordgo.Message, ind
 	L := 0
 	diff := 0
 	var lines []bot.Transcript
-	if len(args) < 1 {
+	if !args.Has(episodeQuoteParam) {
 		lines = []bot.Transcript{info.Bot.DB.GetRandomQuote()}
 	} else {
-		arg := strings.ToLower(args[0])
+		arg := strings.ToLower(args.String(episodeQuoteParam))
 		switch arg {
 		case "action":
 			lines = []bot.Transcript{info.Bot.DB.GetCharacterQuote("ACTION")}
@@ -177,7 +173,7 @@ func (c *episodeQuoteCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "If the S0E00:000-000 format is used, returns all the lines from the given season and episode, between the starting and ending line numbers (inclusive). Returns a maximum of " + strconv.Itoa(info.Config.Markov.MaxLines) + " lines, but a line count above 5 will be sent in a private message. \n\nIf \"action\" is specified, returns a random action quote from the show.\n\nIf \"speech\" is specified, returns a random quote from one of the characters in the show.\n\nIf a \"Character Name\" is specified, it attempts to quote a random line from the show spoken by that character. If the character can't be found, returns an error. The character name doesn't have to be in quotes unless it has spaces in it, but you must specify the entire name.\n\nIf no arguments are specified, quotes a completely random line from the show.",
 		Params: []bot.CommandUsageParam{
-			{Name: "S0E00:000-000|action|speech|\"Character Name\"", Desc: "Example: `" + info.Config.Basic.CommandPrefix + "quote S4E22:7-14`", Optional: true},
+			{Name: episodeQuoteParam, Desc: "Example: `" + info.Config.Basic.CommandPrefix + "quote S4E22:7-14`", Optional: true},
 		},
 	}
 }
@@ -191,7 +187,7 @@ func (c *shipCommand) Info() *bot.CommandInfo {
 		Usage: "Generates a random ship.",
 	}
 }
-func (c *shipCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *shipCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
@@ -205,11 +201,11 @@ func (c *shipCommand) Process(args []string, msg *discordgo.Message, indices []i
 		b = info.Bot.DB.GetRandomSpeaker()
 	}
 	s := ""
-	if len(args) > 0 {
-		a = args[0]
+	if args.Has("first") {
+		a = args.String("first")
 	}
-	if len(args) > 1 {
-		b = args[1]
+	if args.Has("second") {
+		b = args.String("second")
 	}
 	switch rand.Int31n(11) {
 	case 0:
@@ -308,7 +304,7 @@ func splitSpeaker(speaker string) []string {
 func (sb *SweetieBot) buildMarkov(seasonStart int, episodeStart int) {
 	regex := regexp.MustCompile("[^~!@#$%^&*()_+`=[\\];,./<>?\" \n\r\f\t\v]+[?!.]?")
 
-	sb.DB.sqlResetMarkov.Exec()
+	sb.DB.ResetMarkov()
 
 	var cur uint64
 	var prev uint64
@@ -53,7 +53,7 @@ func (c *rollCommand) eatSymbols(args []string, index *int, s ...string) int {
 	return -1
 }
 func (c *rollCommand) eval(args []string, index *int, info *bot.GuildInfo) float64 {
-	//info.Log(strings.Join(args, "\u00B7"))
+	//info.Logger().Debug(strings.Join(args, "\u00B7"))
 	var r float64
 	if c.eatSymbols(args, index, "+", "-") == 1 {
 		r = -c.factor(args, index, info)
@@ -1,7 +1,6 @@
 package quotemodule
 
 import (
-	"math"
 	"math/rand"
 	"strconv"
 	"strings"
@@ -27,10 +26,10 @@ func (w *QuoteModule) Name() string {
 // Commands in the module
 func (w *QuoteModule) Commands() []bot.Command {
 	return []bot.Command{
-		&quoteCommand{},
-		&addquoteCommand{},
-		&removequoteCommand{},
-		&searchQuoteCommand{},
+		bot.TypedCommand(&quoteCommand{}),
+		bot.TypedCommand(&addquoteCommand{}),
+		bot.TypedCommand(&removequoteCommand{}),
+		bot.TypedCommand(&searchQuoteCommand{}),
 	}
 }
 
@@ -46,8 +45,8 @@ func (c *quoteCommand) Info() *bot.CommandInfo {
 		Usage: "Quotes a user.",
 	}
 }
-func (c *quoteCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	if len(args) < 1 {
+func (c *quoteCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !args.Has("user") {
 		l := 0
 		for _, v := range info.Config.Quote.Quotes {
 			l += len(v)
@@ -65,31 +64,15 @@ func (c *quoteCommand) Process(args []string, msg *discordgo.Message, indices []
 		}
 		return "```\nError: invalid random quote chosen???```", false, nil
 	}
-	last := 1
-	if len(args) > 1 {
-		last = len(args) - 1
-	}
-	i := -1
-	if len(args) > 1 {
-		var err error
-		if i, err = strconv.Atoi(args[last]); err != nil {
-			last++
-		} else if i <= 0 {
-			i = math.MaxInt32
-		}
-		i--
-	}
-	user, err := bot.ParseUser(strings.Join(args[:last], " "), info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
+	user := args.User("user")
 	q, ok := info.Config.Quote.Quotes[user]
 	l := len(q)
 	if !ok || l <= 0 {
 		return "```\nThat user has no quotes.```", false, nil
 	}
-	if i < 0 {
-		i = rand.Intn(l)
+	i := rand.Intn(l)
+	if args.Has("quote") {
+		i = args.Int("quote", 0) - 1
 	}
 	if i >= l || i < 0 {
 		return "```\nInvalid quote index. Use " + info.Config.Basic.CommandPrefix + "searchquote [user] to list a user's quotes and their indexes.```", false, nil
@@ -100,8 +83,8 @@ func (c *quoteCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "If no arguments are specified, returns a random quote. If a user is specified, returns a random quote from that user. If a quote index is specified, returns that specific quote.",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A @user ping or simply the name of the user to quote.", Optional: true},
-			{Name: "quote", Desc: "A specific quote index. Use `" + info.Config.Basic.CommandPrefix + "searchquote` to find a quote index.", Optional: true},
+			{Name: "user", Desc: "A @user ping or simply the name of the user to quote.", Optional: true, Type: bot.ArgUser},
+			{Name: "quote", Desc: "A specific quote index. Use `" + info.Config.Basic.CommandPrefix + "searchquote` to find a quote index.", Optional: true, Type: bot.ArgInt},
 		},
 	}
 }
@@ -117,23 +100,12 @@ func (c *addquoteCommand) Info() *bot.CommandInfo {
 	}
 }
 
-func (c *addquoteCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	if len(args) < 1 {
-		return "```\nMust specify username.```", false, nil
-	}
-	if len(args) < 2 {
-		return "```\nCan't add a blank quote!```", false, nil
-	}
-
-	user, err := bot.ParseUser(args[0], info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-
+func (c *addquoteCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	user := args.User("user")
 	if len(info.Config.Quote.Quotes) == 0 {
 		info.Config.Quote.Quotes = make(map[bot.DiscordUser][]string)
 	}
-	info.Config.Quote.Quotes[user] = append(info.Config.Quote.Quotes[user], msg.Content[indices[1]:])
+	info.Config.Quote.Quotes[user] = append(info.Config.Quote.Quotes[user], args.String("quote"))
 	info.SaveConfig()
 	return "```\nQuote added to " + info.GetUserName(user) + ".```", false, nil
 }
@@ -141,8 +113,8 @@ func (c *addquoteCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Adds a quote to the quote database for the given user. If the user is ambiguous, returns all possible matches.",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A @user ping or simply the name of the user to quote. If the username has spaces, it must be in quotes.", Optional: false},
-			{Name: "quote", Desc: "A specific quote index. Use `" + info.Config.Basic.CommandPrefix + "searchquote` to find a quote index.", Optional: false},
+			{Name: "user", Desc: "A @user ping or simply the name of the user to quote. If the username has spaces, it must be in quotes.", Optional: false, Type: bot.ArgUser},
+			{Name: "quote", Desc: "The text of the quote.", Optional: false, Type: bot.ArgRest},
 		},
 	}
 }
@@ -157,25 +129,9 @@ func (c *removequoteCommand) Info() *bot.CommandInfo {
 		Sensitive: true,
 	}
 }
-func (c *removequoteCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	if len(args) < 1 {
-		return "```\nMust specify username.```", false, nil
-	}
-	if len(args) < 2 {
-		return "```\nMust specify quote index. Use " + info.Config.Basic.CommandPrefix + "searchquote to list them.```", false, nil
-	}
-
-	last := len(args) - 1
-	user, err := bot.ParseUser(strings.Join(args[:last], " "), info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-	index, err := strconv.Atoi(args[last])
-	if err != nil {
-		return "```\nError: could not parse quote index. Did you surround your username with quotes? Use " + info.Config.Basic.CommandPrefix + "searchquote to find a quote index.```", false, nil
-	}
-
-	index--
+func (c *removequoteCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	user := args.User("user")
+	index := args.Int("quote", 0) - 1
 	if index >= len(info.Config.Quote.Quotes[user]) || index < 0 {
 		return "```\nInvalid quote index. Use " + info.Config.Basic.CommandPrefix + "searchquote [user] to list a user's quotes and their indexes.```", false, nil
 	}
@@ -187,8 +143,8 @@ func (c *removequoteCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Removes the quote with the given quote index from the user's set of quotes. If the user is ambiguous, returns all possible matches.",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A @user ping or simply the name of the user to quote. If the username has spaces, it must be in quotes.", Optional: false},
-			{Name: "quote", Desc: "A specific quote index. Use `" + info.Config.Basic.CommandPrefix + "searchquote` to find a quote index.", Optional: false},
+			{Name: "user", Desc: "A @user ping or simply the name of the user whose quote should be removed.", Optional: false, Type: bot.ArgUser},
+			{Name: "quote", Desc: "A specific quote index. Use `" + info.Config.Basic.CommandPrefix + "searchquote` to find a quote index.", Optional: false, Type: bot.ArgInt},
 		},
 	}
 }
@@ -202,8 +158,8 @@ func (c *searchQuoteCommand) Info() *bot.CommandInfo {
 		Usage: "Finds a quote.",
 	}
 }
-func (c *searchQuoteCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	if len(args) < 1 {
+func (c *searchQuoteCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !args.Has("user") {
 		s := make([]uint64, 0, len(info.Config.Quote.Quotes))
 		for k, v := range info.Config.Quote.Quotes {
 			if len(v) > 0 { // Map entries can have 0 quotes associated with them
@@ -213,11 +169,7 @@ func (c *searchQuoteCommand) Process(args []string, msg *discordgo.Message, indi
 		return "```\nThe following users have at least one quote:\n" + strings.Join(info.IDsToUsernames(s, true), "\n") + "```", len(s) > bot.MaxPublicLines, nil
 	}
 
-	user, err := bot.ParseUser(msg.Content[indices[0]:], info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-
+	user := args.User("user")
 	l := len(info.Config.Quote.Quotes[user])
 	if l == 0 {
 		return "```\nThat user has no quotes.```", false, nil
@@ -232,7 +184,7 @@ func (c *searchQuoteCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Lists all quotes for the given user.",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A @user ping or simply the name of the user to quote. If the username has spaces, it must be in quotes.", Optional: false},
+			{Name: "user", Desc: "A @user ping or simply the name of the user whose quotes should be listed. If no user is given, lists everyone who has a quote.", Optional: true, Type: bot.ArgUser},
 		},
 	}
 }
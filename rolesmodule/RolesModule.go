@@ -79,7 +79,7 @@ func GetRoleByNameOrPing(role string, info *bot.GuildInfo) (*discordgo.Role, err
 			return nil, errNotUserAssignable
 		}
 
-		roles, err := info.Bot.DG.GuildRoles(info.ID)
+		roles, err := info.DG.GuildRoles(info.ID)
 		if err != nil {
 			return nil, err
 		}
@@ -118,9 +118,9 @@ func (c *createRoleCommand) Process(args []string, msg *discordgo.Message, indic
 		return "```\nThat role already exists! Use " + info.Config.Basic.CommandPrefix + "addrole to make it user-assignable if it isn't already.```", false, nil
 	}
 
-	r, err := info.Bot.DG.GuildRoleCreate(info.ID)
+	r, err := info.DG.GuildRoleCreate(info.ID)
 	if err == nil {
-		r, err = info.Bot.DG.GuildRoleEdit(info.ID, r.ID, role, 0, false, 0, true)
+		r, err = info.DG.GuildRoleEdit(info.ID, r.ID, role, 0, false, 0, true)
 	}
 	if err != nil {
 		return "```Could not create role! " + err.Error() + "```", false, nil
@@ -168,7 +168,7 @@ func (c *addRoleCommand) Process(args []string, msg *discordgo.Message, indices
 	if ok {
 		return "```\nThat role is already user-assignable!```", false, nil
 	}
-	roles, err := info.Bot.DG.GuildRoles(info.ID)
+	roles, err := info.DG.GuildRoles(info.ID)
 	if err != nil {
 		return "```\nCould not get roles! + " + err.Error() + "```", false, nil
 	}
@@ -209,7 +209,7 @@ func (c *joinRoleCommand) Process(args []string, msg *discordgo.Message, indices
 		return bot.ReturnError(err)
 	}
 	hasrole := info.UserHasRole(bot.DiscordUser(msg.Author.ID), bot.DiscordRole(r.ID))
-	err = info.ResolveRoleAddError(info.Bot.DG.GuildMemberRoleAdd(info.ID, msg.Author.ID, r.ID)) // Try adding the role no matter what, just in case discord screwed up
+	err = info.ResolveRoleAddError(info.DG.GuildMemberRoleAdd(info.ID, msg.Author.ID, r.ID)) // Try adding the role no matter what, just in case discord screwed up
 	if hasrole {
 		return "```\nYou already have that role.```", false, nil
 	}
@@ -243,7 +243,7 @@ func (c *listRoleCommand) Info() *bot.CommandInfo {
 
 func (c *listRoleCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if len(args) < 1 {
-		roles, err := info.Bot.DG.GuildRoles(info.ID)
+		roles, err := info.DG.GuildRoles(info.ID)
 		if err != nil {
 			return fmt.Sprintf("```Error getting roles: %s```", err.Error()), false, nil
 		}
@@ -265,8 +265,8 @@ func (c *listRoleCommand) Process(args []string, msg *discordgo.Message, indices
 	if err != nil {
 		return "```\nGuild not in state?!```", false, nil
 	}
-	info.Bot.DG.State.RLock()
-	defer info.Bot.DG.State.RUnlock()
+	info.DG.State.RLock()
+	defer info.DG.State.RUnlock()
 	out := []string{}
 	for _, v := range guild.Members {
 		if info.UserHasRole(bot.DiscordUser(v.User.ID), bot.DiscordRole(r.ID)) {
@@ -310,7 +310,7 @@ func (c *leaveRoleCommand) Process(args []string, msg *discordgo.Message, indice
 		return bot.ReturnError(err)
 	}
 	hasrole := info.UserHasRole(bot.DiscordUser(msg.Author.ID), bot.DiscordRole(r.ID))
-	err = info.Bot.DG.GuildMemberRoleRemove(info.ID, msg.Author.ID, r.ID) // Try removing it no matter what in case discord screwed up
+	err = info.DG.GuildMemberRoleRemove(info.ID, msg.Author.ID, r.ID) // Try removing it no matter what in case discord screwed up
 	if !hasrole {
 		return "```\nYou don't have that role.```", false, nil
 	}
@@ -383,7 +383,7 @@ func (c *deleteRoleCommand) Process(args []string, msg *discordgo.Message, indic
 	if err != nil {
 		return bot.ReturnError(err)
 	}
-	err = info.Bot.DG.GuildRoleDelete(info.ID, r.ID)
+	err = info.DG.GuildRoleDelete(info.ID, r.ID)
 	if err != nil {
 		return "```\nError deleting role! " + err.Error() + "```", false, nil
 	}
@@ -11,24 +11,34 @@ import (
 	"github.com/blackhole12/discordgo"
 )
 
+// EventConfig holds the RSVP and missed event settings of a server. It is registered as the "Events" config category.
+type EventConfig struct {
+	RSVPEmoji     string                `json:"rsvpemoji"`
+	RSVPReminders bot.ReminderOffsets   `json:"rsvpreminders"`
+	MissedEvents  bot.MissedEventPolicy `json:"missedevents"`
+}
+
+func init() {
+	bot.RegisterConfig("Events", func() interface{} {
+		return &EventConfig{RSVPEmoji: "✅", RSVPReminders: "1 day, 15 minutes", MissedEvents: bot.MissedFire}
+	}, map[string]string{
+		"rsvpemoji":     "The emoji members react with to RSVP to an event posted with `!postevent`. Use a unicode emoji, or `name:id` for a custom emoji.",
+		"rsvpreminders": "How long before an event everyone who RSVPed to it gets a reminder in their DMs, as a comma separated list like `1 day, 15 minutes`. Leave it empty to turn reminders off.",
+		"missedevents":  "What happens to messages, episodes, events and role pings that should have been announced while the bot was offline. `fire` announces them late, `skip` drops them silently, and `summarize` posts a single list of everything that was missed. Unbans, role removals, birthdays and reminders always run late, no matter what this is set to.",
+	})
+}
+
+// Events returns the RSVP and missed event settings of the server
+func Events(info *bot.GuildInfo) *EventConfig {
+	return info.Config.Section("Events").(*EventConfig)
+}
+
 // SchedulerModule manages the scheduling system
 type SchedulerModule struct {
 }
 
 var repeatregex = regexp.MustCompile("repeat -?[0-9]+ (second|minute|hour|day|week|month|quarter|year)s?")
-
-const ( // We don't use iota here because this must match the database values exactly
-	typeEventBan        = 0
-	typeEventBirthday   = 1
-	typeEventMessage    = 2
-	typeEventEpisode    = 3
-	typeEventUnbirthday = 4
-	typeEvent           = 5
-	typeEventReminder   = 6
-	typeEventRole       = 7
-	typeEventSilence    = 8
-	typeEventRemoveRole = 9
-)
+var rruleregex = regexp.MustCompile("(?i)^(rrule:)?freq=")
 
 // New SchedulerModule
 func New() *SchedulerModule {
@@ -42,13 +52,22 @@ func (w *SchedulerModule) Name() string {
 
 // Commands in the module
 func (w *SchedulerModule) Commands() []bot.Command {
+	// AddEvent, RemindMe and AddBirthday parse their own arguments, because what each argument means depends on the
+	// ones before it, and ImportCalendar reads an attachment instead of arguments.
 	return []bot.Command{
-		&scheduleCommand{},
-		&nextCommand{},
+		bot.TypedCommand(&scheduleCommand{}),
+		bot.TypedCommand(&nextCommand{}),
 		&addEventCommand{},
-		&removeEventCommand{},
+		bot.TypedCommand(&removeEventCommand{}),
 		&remindMeCommand{},
 		&addBirthdayCommand{},
+		bot.TypedCommand(&calendarFeedCommand{}),
+		bot.TypedCommand(&exportCalendarCommand{}),
+		&importCalendarCommand{},
+		bot.TypedCommand(&rsvpCommand{}),
+		bot.TypedCommand(&attendeesCommand{}),
+		bot.TypedCommand(&postEventCommand{}),
+		bot.TypedCommand(&failedEventsCommand{}),
 	}
 }
 
@@ -57,15 +76,9 @@ func (w *SchedulerModule) Description() string {
 	return "Manages the scheduling system, and periodically checks for events that need to be processed."
 }
 
-// OnTick discord hook
-func (w *SchedulerModule) OnTick(info *bot.GuildInfo, t time.Time) {
-	if !info.Bot.DB.CheckStatus() {
-		return
-	}
-	events := info.Bot.DB.GetSchedule(bot.SBatoi(info.ID))
-	if len(events) == 0 {
-		return
-	}
+// defaultChannel is where events are announced when they don't have a channel of their own: the first channel the
+// scheduler is restricted to, otherwise the first channel of the bored module, a free channel, or the mod channel.
+func (w *SchedulerModule) defaultChannel(info *bot.GuildInfo) bot.DiscordChannel {
 	channel := info.Config.Basic.ModChannel
 	modulename := bot.ModuleID(strings.ToLower(w.Name()))
 	if len(info.Config.Modules.Channels[modulename]) > 0 {
@@ -92,76 +105,218 @@ func (w *SchedulerModule) OnTick(info *bot.GuildInfo, t time.Time) {
 	}
 
 	if channel == bot.ChannelEmpty {
-		return
+		return channel
 	}
 	if ch, private := info.Bot.ChannelIsPrivate(channel); private || ch == nil || ch.GuildID != info.ID {
 		channel = info.Config.Basic.ModChannel
 	}
+	return channel
+}
 
-	for _, v := range events {
-		switch v.Type {
-		case typeEventBan:
-			err := info.Bot.DG.GuildBanDelete(info.ID, v.Data)
-			if err != nil {
-				info.SendMessage(info.Config.Basic.ModChannel, "Error unbanning <@"+v.Data+">: "+err.Error())
-			} else {
-				info.SendMessage(info.Config.Basic.ModChannel, "Unbanned <@"+v.Data+">")
-			}
-		case typeEventBirthday:
-			if info.Config.Scheduler.BirthdayRole == bot.RoleEmpty {
-				info.Log("No birthday role set!")
-			} else {
-				err := info.ResolveRoleAddError(info.Bot.DG.GuildMemberRoleAdd(info.ID, v.Data, info.Config.Scheduler.BirthdayRole.String()))
-				info.LogError("Failed to set birthday role: ", err)
-			}
-			info.SendMessage(channel, "Happy Birthday <@"+v.Data+">!")
-		case typeEventMessage:
-			info.SendMessage(channel, v.Data)
-		case typeEvent, typeEventEpisode:
-			info.SendMessage(channel, v.Data+" is starting now!")
-		case typeEventUnbirthday:
-			if info.Config.Scheduler.BirthdayRole == bot.RoleEmpty {
-				info.Log("No birthday role set!")
-			} else {
-				err := info.ResolveRoleAddError(info.Bot.DG.GuildMemberRoleRemove(info.ID, v.Data, info.Config.Scheduler.BirthdayRole.String()))
-				info.LogError("Failed to remove birthday role: ", err)
+// eventChannel returns the channel an event should be announced in
+func eventChannel(info *bot.GuildInfo, e *bot.ScheduleEvent, def bot.DiscordChannel) bot.DiscordChannel {
+	if e.Channel == bot.ChannelEmpty {
+		return def
+	}
+	if ch, private := info.Bot.ChannelIsPrivate(e.Channel); private || ch == nil || ch.GuildID != info.ID {
+		return def // The channel was deleted, so fall back to where we'd normally announce it
+	}
+	return e.Channel
+}
+
+// How long an event can be running before the scheduler assumes the bot stopped halfway through and runs it again
+const eventLease = 5 * time.Minute
+
+// How late an announcement can be before it counts as missed, instead of just waiting for the next tick
+const missedEventGrace = 5 * time.Minute
+
+// eventGone returns true if discord says the member or ban an event acts on doesn't exist anymore, so retrying the
+// event won't help
+func eventGone(err error) bool {
+	s := err.Error()
+	return strings.Contains(s, "Unknown Member") || strings.Contains(s, "Unknown User") || strings.Contains(s, "Unknown Ban")
+}
+
+// runEvent does whatever the event is for. Returns an error if it should be retried later.
+func (w *SchedulerModule) runEvent(info *bot.GuildInfo, v *bot.ScheduledJob, channel bot.DiscordChannel) error {
+	data := v.Payload()
+	switch v.Type {
+	case bot.EventBan:
+		err := info.DG.GuildBanDelete(info.ID, data.User.String())
+		if err != nil && !eventGone(err) {
+			return err
+		}
+		info.SendMessage(info.Config.Basic.ModChannel, "Unbanned "+data.User.Display())
+	case bot.EventBirthday:
+		if info.Config.Scheduler.BirthdayRole == bot.RoleEmpty {
+			info.Logger().Warning("No birthday role set!")
+		} else if err := info.DG.GuildMemberRoleAdd(info.ID, data.User.String(), info.Config.Scheduler.BirthdayRole.String()); err != nil {
+			if !eventGone(err) {
+				return info.ResolveRoleAddError(err)
 			}
-		case typeEventReminder:
-			dat := strings.SplitN(v.Data, "|", 2)
-			ch, err := info.Bot.DG.UserChannelCreate(dat[0])
-			info.LogError("Error opening private channel: ", err)
-			if err == nil {
-				info.SendMessage(bot.DiscordChannel(ch.ID), dat[1])
+			info.Logger().With(bot.LogFields{"user": data.User.String()}).LogError("Failed to set birthday role: ", err)
+		}
+		return info.SendMessage(channel, "Happy Birthday "+data.User.Display()+"!")
+	case bot.EventMessage:
+		return info.SendMessage(channel, data.Message)
+	case bot.EventGeneric, bot.EventEpisode:
+		return info.SendMessage(channel, data.Message+" is starting now!")
+	case bot.EventUnbirthday:
+		if info.Config.Scheduler.BirthdayRole == bot.RoleEmpty {
+			info.Logger().Warning("No birthday role set!")
+		} else if err := info.DG.GuildMemberRoleRemove(info.ID, data.User.String(), info.Config.Scheduler.BirthdayRole.String()); err != nil && !eventGone(err) {
+			return info.ResolveRoleAddError(err)
+		}
+	case bot.EventReminder:
+		ch, err := info.DG.UserChannelCreate(data.User.String())
+		if err != nil {
+			return err
+		}
+		return info.SendMessage(bot.DiscordChannel(ch.ID), data.Message)
+	case bot.EventRole:
+		return info.SendMessage(channel, data.Mention+" "+data.Message)
+	case bot.EventSilence:
+		if err := info.DG.RemoveRole(info.ID, data.User, info.Config.Basic.SilenceRole); err != nil {
+			if !eventGone(err) {
+				return err
 			}
-		case typeEventRole:
-			dat := strings.SplitN(v.Data, "|", 2)
-			info.SendMessage(channel, dat[0]+" "+dat[1])
-		case typeEventSilence:
-			err := info.Bot.DG.RemoveRole(info.ID, bot.DiscordUser(v.Data), info.Config.Basic.SilenceRole)
-			if err != nil {
-				info.SendMessage(info.Config.Basic.ModChannel, "Error unsilencing <@"+v.Data+">: "+err.Error())
-			} else {
-				info.SendMessage(info.Config.Basic.ModChannel, "Unsilenced <@"+v.Data+">")
+			info.SendMessage(info.Config.Basic.ModChannel, "Error unsilencing "+data.User.Display()+": "+err.Error())
+		} else {
+			info.SendMessage(info.Config.Basic.ModChannel, "Unsilenced "+data.User.Display())
+		}
+	case bot.EventRemoveRole:
+		if data.Role == bot.RoleEmpty {
+			info.SendMessage(info.Config.Basic.ModChannel, "Invalid data in role removal event: "+v.Data)
+		} else if err := info.DG.RemoveRole(info.ID, data.User, data.Role); err != nil {
+			if !eventGone(err) {
+				return err
 			}
-		case typeEventRemoveRole:
-			dat := strings.SplitN(v.Data, "|", 2)
-			if len(dat) != 2 {
-				info.SendMessage(info.Config.Basic.ModChannel, "Invalid data in role removal event: "+v.Data)
-			} else {
-				role := bot.DiscordRole(dat[1])
-				err := info.Bot.DG.RemoveRole(info.ID, bot.DiscordUser(dat[0]), role)
-				if err != nil {
-					info.SendMessage(info.Config.Basic.ModChannel, "Error removing "+role.Show(info)+" from <@"+dat[0]+">: "+err.Error())
-				} else {
-					info.SendMessage(info.Config.Basic.ModChannel, "Removed "+role.Show(info)+" from <@"+dat[0]+">")
+			info.SendMessage(info.Config.Basic.ModChannel, "Error removing "+data.Role.Show(info)+" from "+data.User.Display()+": "+err.Error())
+		} else {
+			info.SendMessage(info.Config.Basic.ModChannel, "Removed "+data.Role.Show(info)+" from "+data.User.Display())
+		}
+	case bot.EventTimeout: // Discord ends the timeout by itself, so we only have to tell the moderators
+		info.SendMessage(info.Config.Basic.ModChannel, "Timeout expired for "+data.User.Display())
+	}
+	return nil
+}
+
+// finishMissed marks an event that was missed as done. If it repeats, any later occurrences that were also missed are
+// skipped too, so it isn't announced over and over. Returns how many occurrences were missed.
+func finishMissed(info *bot.GuildInfo, id uint64, now time.Time) int {
+	n := 1
+	for ; n < bot.MaxScheduleRows; n++ {
+		if info.Bot.DB.FinishEvent(id) != nil {
+			break
+		}
+		e := info.Bot.DB.GetEvent(bot.SBatoi(info.ID), id)
+		if e == nil || e.Date.After(now.Add(-missedEventGrace)) {
+			break
+		}
+	}
+	return n
+}
+
+// OnTick discord hook
+func (w *SchedulerModule) OnTick(info *bot.GuildInfo, t time.Time) {
+	if !info.Bot.DB.CheckStatus() {
+		return
+	}
+	t = t.UTC()
+	w.remindAttendees(info, t)
+	events := info.Bot.DB.GetSchedule(bot.SBatoi(info.ID))
+	if len(events) == 0 {
+		return
+	}
+	def := w.defaultChannel(info)
+	policy := Events(info).MissedEvents
+	missed := make(map[bot.DiscordChannel][]string)
+
+	for i := range events {
+		v := &events[i]
+		channel := eventChannel(info, &v.ScheduleEvent, def)
+		if channel == bot.ChannelEmpty {
+			continue // Wait until there's somewhere to announce it
+		}
+		if !info.Bot.DB.StartEvent(v.ID, t.Add(eventLease)) {
+			continue
+		}
+		late := v.Attempts == 0 && bot.EventIsAnnouncement(v.Type) && t.Sub(v.Date) > missedEventGrace
+		if late && (policy == bot.MissedSkip || policy == bot.MissedSummarize) {
+			n := finishMissed(info, v.ID, t)
+			if policy == bot.MissedSummarize {
+				line := fmt.Sprintf("**%s** %s", info.ApplyTimezone(v.Date, bot.UserEmpty).Format("Jan 2 3:04pm"), v.Payload().Message)
+				if n > 1 {
+					line += " (missed " + strconv.Itoa(n) + " times)"
 				}
+				missed[channel] = append(missed[channel], line)
 			}
+			continue
+		}
+		if err := w.runEvent(info, v, channel); err != nil {
+			w.eventFailed(info, v, err, t)
+		} else if late {
+			finishMissed(info, v.ID, t)
+		} else {
+			info.Bot.DB.FinishEvent(v.ID)
 		}
+	}
 
-		info.Bot.DB.RemoveSchedule(v.ID)
+	for channel, lines := range missed {
+		info.SendMessage(channel, info.Sanitize(info.GetBotName()+" was offline and missed "+bot.Pluralize(int64(len(lines)), " announcement")+":\n"+strings.Join(lines, "\n"), bot.CleanMentions|bot.CleanPings))
 	}
 }
 
+// eventFailed records that an event failed, so it's retried later. Once it fails too many times, the moderators are
+// told that the scheduler gave up on it.
+func (w *SchedulerModule) eventFailed(info *bot.GuildInfo, v *bot.ScheduledJob, err error, now time.Time) {
+	attempts := v.Attempts + 1
+	log := info.Logger().With(bot.LogFields{"event": v.ID, "attempts": attempts})
+	state, dberr := info.Bot.DB.FailEvent(v.ID, attempts, err.Error(), now)
+	if dberr != nil {
+		log.LogError("Failed to record event failure: ", dberr)
+		return
+	}
+	if state != bot.JobFailed {
+		log.Warning("Scheduled event failed, retrying in "+bot.EventRetryDelay(attempts).String()+":", err)
+		return
+	}
+	ty, data := describeEvent(info, &v.ScheduleEvent)
+	info.SendMessage(info.Config.Basic.ModChannel, info.Sanitize(fmt.Sprintf("Gave up on event #%v [%s] %s after %s: %s\nUse `%sfailedevents retry %v` to try again, or `%sfailedevents dismiss %v` to drop it.", v.ID, ty, data, bot.Pluralize(int64(attempts), " attempt"), err.Error(), info.Config.Basic.CommandPrefix, v.ID, info.Config.Basic.CommandPrefix, v.ID), bot.CleanPings))
+}
+
+// describeEvent returns the name of an event's type and what it's about, as shown by !schedule
+func describeEvent(info *bot.GuildInfo, v *bot.ScheduleEvent) (string, string) {
+	payload := v.Payload()
+	data := payload.Message
+	mt := "UNKNOWN"
+	switch v.Type {
+	case bot.EventBan:
+		mt = "UNBAN"
+		data = payload.User.Display()
+	case bot.EventBirthday:
+		mt = "BIRTHDAY"
+		data = payload.User.Display()
+	case bot.EventMessage:
+		mt = "MESSAGE"
+	case bot.EventEpisode:
+		mt = "EPISODE"
+	case bot.EventGeneric:
+		mt = "EVENT"
+	case bot.EventReminder:
+		mt = "REMINDER"
+	case bot.EventRole:
+		mt = "ROLE:" + bot.ReplaceAllRolePings(payload.Mention, info)
+	case bot.EventRemoveRole:
+		mt = "REMOVAL:" + payload.Role.Show(info)
+		data = payload.User.Display()
+	case bot.EventTimeout:
+		mt = "TIMEOUT"
+		data = payload.User.Display()
+	}
+	return mt, data
+}
+
 type scheduleCommand struct {
 }
 
@@ -171,30 +326,21 @@ func (c *scheduleCommand) Info() *bot.CommandInfo {
 		Usage: "Gets a list of upcoming scheduled events.",
 	}
 }
-func (c *scheduleCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *scheduleCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
 	timestamp := bot.GetTimestamp(msg)
-	maxresults := 5
+	maxresults := args.Int("maxresults", 5)
 	var ty uint8
 	ty = 255
-	if len(args) > 1 {
-		ty = getScheduleType(args[0])
+	if n, err := strconv.Atoi(args.String("type")); err == nil && !args.Has("maxresults") {
+		maxresults = n // Just a number means the type was left out
+	} else if args.Has("type") {
+		ty = getScheduleType(args.String("type"))
 		if ty == 255 {
 			return "```\nUnknown schedule type.```", false, nil
 		}
-		maxresults, _ = strconv.Atoi(args[1])
-	} else if len(args) > 0 {
-		var err error
-		maxresults, err = strconv.Atoi(args[0])
-		if err != nil {
-			maxresults = 5
-			ty = getScheduleType(args[0])
-			if ty == 255 {
-				return "```\nUnknown schedule type.```", false, nil
-			}
-		}
 	}
 	if maxresults > 20 {
 		maxresults = 20
@@ -202,13 +348,13 @@ func (c *scheduleCommand) Process(args []string, msg *discordgo.Message, indices
 	if maxresults < 1 {
 		maxresults = 1
 	}
-	if !info.UserIsMod(bot.DiscordUser(msg.Author.ID)) && !info.UserIsAdmin(bot.DiscordUser(msg.Author.ID)) && (ty == 0 || ty == 4 || ty == 8) {
+	if !info.UserIsMod(bot.DiscordUser(msg.Author.ID)) && !info.UserIsAdmin(bot.DiscordUser(msg.Author.ID)) && (ty == bot.EventBan || ty == bot.EventUnbirthday || ty == bot.EventSilence || ty == bot.EventTimeout) {
 		return "```\nYou aren't allowed to view those events.```", false, nil
 	}
 	var events []bot.ScheduleEvent
 	if ty == 255 {
 		events = info.Bot.DB.GetEvents(bot.SBatoi(info.ID), maxresults)
-	} else if ty == 6 {
+	} else if ty == bot.EventReminder {
 		events = info.Bot.DB.GetReminders(bot.SBatoi(info.ID), msg.Author.ID, maxresults)
 	} else {
 		events = info.Bot.DB.GetEventsByType(bot.SBatoi(info.ID), ty, maxresults)
@@ -225,34 +371,20 @@ func (c *scheduleCommand) Process(args []string, msg *discordgo.Message, indices
 		} else {
 			t = info.ApplyTimezone(v.Date, bot.DiscordUser(msg.Author.ID)).Format("Jan 2 2006 3:04pm")
 		}
-		data := v.Data
-		mt := "UNKNOWN"
-		switch v.Type {
-		case typeEventBan:
-			mt = "UNBAN"
-			data = "<@" + data + ">"
-		case typeEventBirthday:
-			mt = "BIRTHDAY"
-			data = "<@" + data + ">"
-		case typeEventMessage:
-			mt = "MESSAGE"
-		case typeEventEpisode:
-			mt = "EPISODE"
-		case typeEvent:
-			mt = "EVENT"
-		case typeEventReminder:
-			mt = "REMINDER"
-			data = strings.SplitN(data, "|", 2)[1]
-		case typeEventRole:
-			datas := strings.SplitN(data, "|", 2)
-			mt = "ROLE:" + bot.ReplaceAllRolePings(datas[0], info)
-			data = datas[1]
-		case typeEventRemoveRole:
-			datas := strings.SplitN(data, "|", 2)
-			mt = "REMOVAL:" + bot.DiscordRole(datas[1]).Show(info)
-			data = "<@" + datas[0] + ">"
-		}
-		lines[k+1] = fmt.Sprintf("#%v **%s** [%s] %s", bot.SBitoa(v.ID), t, mt, info.Sanitize(data, bot.CleanMentions|bot.CleanPings|bot.CleanEmotes))
+		mt, data := describeEvent(info, &v)
+		details := ""
+		if v.Channel != bot.ChannelEmpty {
+			details += " in " + v.Channel.Show(info)
+		}
+		if rule := v.Rule(); rule != nil {
+			details += " (repeats " + rule.Describe() + ")"
+		}
+		if v.Type == bot.EventGeneric || v.Type == bot.EventEpisode {
+			if n := info.Bot.DB.CountRSVPs(v.ID); n > 0 {
+				details += " (" + strconv.Itoa(n) + " going)"
+			}
+		}
+		lines[k+1] = fmt.Sprintf("#%v **%s** [%s] %s%s", bot.SBitoa(v.ID), t, mt, info.Sanitize(data, bot.CleanMentions|bot.CleanPings|bot.CleanEmotes), details)
 	}
 
 	return strings.Join(lines, "\n"), len(lines) > 6, nil
@@ -262,7 +394,7 @@ func (c *scheduleCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 		Desc: "Lists up to `maxresults` upcoming events from the schedule. If the first argument is specified, lists only events of that type. Some event types can only be viewed by moderators. Max results: 20",
 		Params: []bot.CommandUsageParam{
 			{Name: "type", Desc: "Can be one of: bans, birthdays, messages, episodes, events, roles, reminders.", Optional: true},
-			{Name: "maxresults", Desc: "Defaults to 5.", Optional: true},
+			{Name: "maxresults", Desc: "Defaults to 5.", Optional: true, Type: bot.ArgInt},
 		},
 	}
 }
@@ -270,23 +402,25 @@ func (c *scheduleCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 func getScheduleType(s string) uint8 {
 	switch strings.ToLower(s) {
 	case "bans", "ban":
-		return typeEventBan
+		return bot.EventBan
 	case "birthdays", "birthday":
-		return typeEventBirthday
+		return bot.EventBirthday
 	case "messages", "message":
-		return typeEventMessage
+		return bot.EventMessage
 	case "episodes", "episode":
-		return typeEventEpisode
+		return bot.EventEpisode
 	case "events", "event":
-		return typeEvent
+		return bot.EventGeneric
 	case "reminders", "reminder":
-		return typeEventReminder
+		return bot.EventReminder
 	case "roles", "role":
-		return typeEventRole
+		return bot.EventRole
 	case "silences", "silence":
-		return typeEventSilence
+		return bot.EventSilence
 	case "removals", "removal":
-		return typeEventRemoveRole
+		return bot.EventRemoveRole
+	case "timeouts", "timeout":
+		return bot.EventTimeout
 	}
 	return 255
 }
@@ -300,14 +434,11 @@ func (c *nextCommand) Info() *bot.CommandInfo {
 		Usage: "Gets time until next event.",
 	}
 }
-func (c *nextCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *nextCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	if len(args) < 1 {
-		return "```\nYou must specify an event type.```", false, nil
-	}
-	ty := getScheduleType(args[0])
+	ty := getScheduleType(args.String("type"))
 	if ty == 255 {
 		return "```\nError: Invalid type specified.```", false, nil
 	}
@@ -318,16 +449,16 @@ func (c *nextCommand) Process(args []string, msg *discordgo.Message, indices []i
 	}
 	diff := bot.TimeDiff(event.Date.Sub(timestamp))
 	switch event.Type {
-	case typeEventBirthday:
-		return info.Sanitize("```\nIt'll be <@"+event.Data+">'s birthday in "+diff+"```", bot.CleanMentions|bot.CleanPings|bot.CleanEmotes), false, nil
-	case typeEventMessage:
+	case bot.EventBirthday:
+		return info.Sanitize("```\nIt'll be "+event.Payload().User.Display()+"'s birthday in "+diff+"```", bot.CleanMentions|bot.CleanPings|bot.CleanEmotes), false, nil
+	case bot.EventMessage:
 		return "```\n" + info.GetBotName() + " is scheduled to send a message in " + diff + "```", false, nil
-	case typeEventEpisode:
+	case bot.EventEpisode:
 		return "```\n" + event.Data + " airs in " + diff + "```", false, nil
-	case typeEvent:
+	case bot.EventGeneric:
 		return "```\n" + event.Data + " starts in " + diff + "```", false, nil
-	case typeEventRole:
-		return "```\n" + info.GetBotName() + " is scheduled to send a message to " + bot.ReplaceAllRolePings(strings.SplitN(event.Data, "|", 2)[0], info) + " in " + diff + "```", false, nil
+	case bot.EventRole:
+		return "```\n" + info.GetBotName() + " is scheduled to send a message to " + bot.ReplaceAllRolePings(event.Payload().Mention, info) + " in " + diff + "```", false, nil
 	default:
 		return "```\nThere are no upcoming events of that type (or you aren't allowed to view them).```", false, nil
 	}
@@ -336,7 +467,7 @@ func (c *nextCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Gets the time until the next event of the given type.",
 		Params: []bot.CommandUsageParam{
-			{Name: "type", Desc: "Can be one of: bans, birthdays, messages, episodes, events, reminders.", Optional: true},
+			{Name: "type", Desc: "Can be one of: bans, birthdays, messages, episodes, events, reminders.", Optional: false},
 		},
 	}
 }
@@ -363,16 +494,29 @@ func (c *addEventCommand) Process(args []string, msg *discordgo.Message, indices
 	if ty == 255 {
 		return "```\nError: Invalid type specified.```", false, nil
 	}
-	if ty == typeEventReminder {
+	if ty == bot.EventReminder {
 		return "```\nError: You cannot add a reminder event this way. Use " + info.Config.Basic.CommandPrefix + "remindme instead.```", false, nil
 	}
 	data := ""
-	if ty == typeEventRole {
+	if ty == bot.EventRole {
 		data = strings.ToLower(args[1])
 		data += "|"
 		args = append(args[:1], args[2:]...)
 		indices = append(indices[:1], indices[2:]...)
 	}
+	channel := bot.ChannelEmpty
+	if len(args) > 2 && strings.HasPrefix(args[1], "<#") {
+		ch, err := bot.ParseChannel(args[1], nil)
+		if err != nil {
+			return bot.ReturnError(err)
+		}
+		if c, private := info.Bot.ChannelIsPrivate(ch); private || c == nil || c.GuildID != info.ID {
+			return "```\nError: That channel isn't on this server!```", false, nil
+		}
+		channel = ch
+		args = append(args[:1], args[2:]...)
+		indices = append(indices[:1], indices[2:]...)
+	}
 	timestamp := bot.GetTimestamp(msg)
 	t, err := info.ParseCommonTime(args[1], bot.DiscordUser(msg.Author.ID), timestamp)
 	if err != nil {
@@ -384,6 +528,9 @@ func (c *addEventCommand) Process(args []string, msg *discordgo.Message, indices
 	}
 
 	if len(args) > 2 && repeatregex.MatchString(strings.ToLower(args[2])) {
+		if channel != bot.ChannelEmpty {
+			return "```\nError: Events announced in a specific channel have to use a recurrence rule, like \"FREQ=WEEKLY\", instead of REPEAT.```", false, nil
+		}
 		repeats := strings.Split(args[2], " ")
 		repeat, err := strconv.Atoi(repeats[1])
 		if err != nil {
@@ -401,12 +548,27 @@ func (c *addEventCommand) Process(args []string, msg *discordgo.Message, indices
 		if err := info.Bot.DB.AddScheduleRepeat(bot.SBatoi(info.ID), t, repeatinterval, repeat, ty, data); err != nil {
 			return bot.ReturnError(err)
 		}
+	} else if len(args) > 2 && rruleregex.MatchString(args[2]) {
+		rule, err := bot.ParseRecurrence(args[2], info.GetTimezone(bot.DiscordUser(msg.Author.ID)))
+		if err == nil {
+			err = rule.Validate(t)
+		}
+		if err != nil {
+			return bot.ReturnError(err)
+		}
+		if len(args) > 3 {
+			data += msg.Content[indices[3]:]
+		}
+		if err := info.Bot.DB.AddScheduleEvent(bot.SBatoi(info.ID), t, ty, bot.ParseEventData(ty, data), channel, rule); err != nil {
+			return bot.ReturnError(err)
+		}
+		return "```\nAdded event to schedule, repeating " + rule.Describe() + ".```", false, nil
 	} else {
 		if len(args) > 2 {
 			data += msg.Content[indices[2]:]
 		}
 
-		if err := info.Bot.DB.AddSchedule(bot.SBatoi(info.ID), t, ty, data); err != nil {
+		if err := info.Bot.DB.AddScheduleEvent(bot.SBatoi(info.ID), t, ty, bot.ParseEventData(ty, data), channel, nil); err != nil {
 			return bot.ReturnError(err)
 		}
 	}
@@ -415,24 +577,20 @@ func (c *addEventCommand) Process(args []string, msg *discordgo.Message, indices
 }
 func (c *addEventCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
-		Desc: "Adds an arbitrary event to the schedule table. For example: `" + info.Config.Basic.CommandPrefix + "addevent message \"12 Jun 16\" \"REPEAT 1 YEAR\" happy birthday!`, or `" + info.Config.Basic.CommandPrefix + "addevent episode \"9 Dec 15\" Slice of Life`. ",
+		Desc: "Adds an arbitrary event to the schedule table. For example: `" + info.Config.Basic.CommandPrefix + "addevent message \"12 Jun 16\" \"REPEAT 1 YEAR\" happy birthday!`, `" + info.Config.Basic.CommandPrefix + "addevent episode \"9 Dec 15\" Slice of Life`, or `" + info.Config.Basic.CommandPrefix + "addevent event #movie-night \"10 Mar 8pm\" FREQ=MONTHLY;BYDAY=2TU Movie Night`. ",
 		Params: []bot.CommandUsageParam{
 			{Name: "type", Desc: "Can be one of: ban, message, episode, event, role.", Optional: false},
 			{Name: "role", Desc: "A ping of the role that should be notified. Only include this when using the role event type.", Optional: true},
 			{Name: "date", Desc: "A date in the format `12 Jun 16 2:10pm`, in quotes. The time, year, and timezone are all optional.", Optional: false},
+			{Name: "channel", Desc: "A channel ping. If included, the event is announced in this channel instead of the scheduler's usual channel.", Optional: true},
 			{Name: "REPEAT N INTERVAL", Desc: "INTERVAL can be one of SECONDS/MINUTES/HOURS/DAYS/WEEKS/MONTHS/YEARS. This parameter MUST be surrounded by quotes!", Optional: true},
+			{Name: "FREQ=...", Desc: "Instead of REPEAT, an iCalendar recurrence rule, like `FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH` or `FREQ=MONTHLY;BYDAY=-1FR`. Supports FREQ (DAILY/WEEKLY/MONTHLY/YEARLY), INTERVAL, BYDAY, BYMONTHDAY, BYMONTH, UNTIL and COUNT, plus TZID to pick the timezone (defaults to yours) and EXDATE to skip dates, like `EXDATE=20241224,20241231`. The event keeps its local time of day across daylight saving changes.", Optional: true},
 		},
 	}
 }
 
 func userOwnsEvent(e *bot.ScheduleEvent, u *discordgo.User) bool {
-	if e.Type == 6 {
-		dat := strings.SplitN(e.Data, "|", 2)
-		if dat[0] == u.ID {
-			return true
-		}
-	}
-	return false
+	return e.Type == bot.EventReminder && e.Payload().User.Equals(u.ID)
 }
 
 type removeEventCommand struct {
@@ -445,18 +603,11 @@ func (c *removeEventCommand) Info() *bot.CommandInfo {
 	}
 }
 
-func (c *removeEventCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *removeEventCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	if len(args) < 1 {
-		return "```\nYou must specify an event ID.```", false, nil
-	}
-	id, err := strconv.ParseUint(args[0], 10, 64)
-	if err != nil {
-		return "```\nCould not parse event ID. Make sure you only specify the number itself.```", false, nil
-	}
-
+	id := uint64(args.Int("ID", 0))
 	e := info.Bot.DB.GetEvent(bot.DiscordGuild(info.ID).Convert(), id)
 	if e == nil {
 		return "```\nError: Event does not exist.```", false, nil
@@ -472,7 +623,7 @@ func (c *removeEventCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Removes an event with the given ID from the schedule. ",
 		Params: []bot.CommandUsageParam{
-			{Name: "ID", Desc: "The event ID as gotten from a `" + info.Config.Basic.CommandPrefix + "schedule` command.", Optional: false},
+			{Name: "ID", Desc: "The event ID as gotten from a `" + info.Config.Basic.CommandPrefix + "schedule` command.", Optional: false, Type: bot.ArgInt},
 		},
 	}
 }
@@ -549,7 +700,7 @@ func (c *remindMeCommand) Process(args []string, msg *discordgo.Message, indices
 	if len(arg) == 0 {
 		return "```\nWhat am I reminding you about? I can't send you a blank message!```", false, nil
 	}
-	if err := info.Bot.DB.AddSchedule(bot.SBatoi(info.ID), t, 6, msg.Author.ID+"|"+arg); err != nil {
+	if err := info.Bot.DB.AddSchedule(bot.SBatoi(info.ID), t, bot.EventReminder, bot.EventData{User: bot.DiscordUser(msg.Author.ID), Message: arg}.Encode(bot.EventReminder)); err != nil {
 		return bot.ReturnError(err)
 	}
 	return "Reminder set for " + bot.TimeDiff(t.Sub(timestamp)) + " from now.", false, nil
@@ -607,10 +758,10 @@ func (c *addBirthdayCommand) Process(args []string, msg *discordgo.Message, indi
 		t = t.AddDate(1, 0, 0)
 	}
 
-	if err := info.Bot.DB.AddScheduleRepeat(bot.SBatoi(info.ID), t, 8, 1, typeEventBirthday, user.String()); err != nil { // Create the normal birthday event at 12 AM on this server's timezone
+	if err := info.Bot.DB.AddScheduleRepeat(bot.SBatoi(info.ID), t, 8, 1, bot.EventBirthday, user.String()); err != nil { // Create the normal birthday event at 12 AM on this server's timezone
 		return bot.ReturnError(err)
 	}
-	if err := info.Bot.DB.AddScheduleRepeat(bot.SBatoi(info.ID), t.AddDate(0, 0, 1), 8, 1, typeEventUnbirthday, user.String()); err != nil { // Create the hidden "remove birthday role" event 24 hours later.
+	if err := info.Bot.DB.AddScheduleRepeat(bot.SBatoi(info.ID), t.AddDate(0, 0, 1), 8, 1, bot.EventUnbirthday, user.String()); err != nil { // Create the hidden "remove birthday role" event 24 hours later.
 		return bot.ReturnError(err)
 	}
 	return info.Sanitize("```Added a birthday for "+user.Display()+"```", bot.CleanMentions|bot.CleanPings), false, nil
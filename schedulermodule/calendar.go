@@ -0,0 +1,227 @@
+package schedulermodule
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	bot "../sweetiebot"
+	"github.com/blackhole12/discordgo"
+)
+
+// The largest calendar file that !importcalendar will download
+const maxCalendarSize = 1 << 20
+
+// How many skipped events !importcalendar lists before it stops explaining why
+const maxSkippedReasons = 10
+
+type calendarFeedCommand struct {
+}
+
+func (c *calendarFeedCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "CalendarFeed",
+		Usage:     "Manages the calendar feed of the schedule.",
+		Sensitive: true,
+	}
+}
+
+func (c *calendarFeedCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if args.Has("on/off/reset") {
+		var err error
+		switch args.String("on/off/reset") {
+		case "on", "enable":
+			err = info.SetCalendarFeed(true, false)
+		case "off", "disable":
+			err = info.SetCalendarFeed(false, false)
+			if err == nil {
+				return "```\nThe calendar feed is now off.```", false, nil
+			}
+		case "reset":
+			err = info.SetCalendarFeed(true, true)
+		}
+		if err != nil {
+			return bot.ReturnError(err)
+		}
+	}
+	link := info.CalendarURL()
+	if len(link) == 0 {
+		return "```\nThe calendar feed is off. Use " + info.Config.Basic.CommandPrefix + "calendarfeed on to turn it on.```", false, nil
+	}
+	return "Members can subscribe to the schedule's events and episodes in their calendar apps with this link: <" + link + ">\nAnyone with the link can see the schedule, so if it gets shared somewhere it shouldn't, use `" + info.Config.Basic.CommandPrefix + "calendarfeed reset` to replace it.", false, nil
+}
+func (c *calendarFeedCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Shows the link to the iCalendar feed of upcoming events and episodes, which members can subscribe to in Google Calendar, Outlook or any other calendar app. The feed is off until it's turned on, and the link contains a secret, so only people you give it to can see it.",
+		Params: []bot.CommandUsageParam{
+			{Name: "on/off/reset", Desc: "Turns the feed on or off, or replaces its link with a new one, so the old link stops working.", Optional: true, Type: bot.ArgEnum, Values: []string{"on", "off", "reset", "enable", "disable"}},
+		},
+	}
+}
+
+type exportCalendarCommand struct {
+}
+
+func (c *exportCalendarCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:  "ExportCalendar",
+		Usage: "Exports upcoming events as an iCalendar file.",
+	}
+}
+
+func (c *exportCalendarCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	events := info.CalendarEvents()
+	if len(events) == 0 {
+		return "```\nThere are no upcoming events or episodes to export.```", false, nil
+	}
+	host := info.Bot.WebDomain
+	if len(host) == 0 {
+		host = "sweetiebot"
+	}
+	var buf bytes.Buffer
+	if err := bot.WriteCalendar(&buf, info.Name, host, events); err != nil {
+		return bot.ReturnError(err)
+	}
+	if _, err := info.DG.ChannelFileSendWithMessage(msg.ChannelID, "Exported "+bot.Pluralize(int64(len(events)), " event")+". Open the file in your calendar app to add them.", "schedule.ics", &buf); err != nil {
+		return bot.ReturnError(err)
+	}
+	return "", false, nil
+}
+func (c *exportCalendarCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Sends every upcoming event and episode in the schedule as an .ics file, which can be opened in any calendar app, or imported into another server with `" + info.Config.Basic.CommandPrefix + "importcalendar`. Repeating events keep their recurrence rules.",
+	}
+}
+
+type importCalendarCommand struct {
+}
+
+func (c *importCalendarCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "ImportCalendar",
+		Usage:     "Imports events from an iCalendar file.",
+		Sensitive: true,
+	}
+}
+
+// downloadCalendar fetches the .ics file attached to the message
+func downloadCalendar(info *bot.GuildInfo, msg *discordgo.Message) ([]byte, error) {
+	var attachment *discordgo.MessageAttachment
+	for _, v := range msg.Attachments {
+		if strings.HasSuffix(strings.ToLower(v.Filename), ".ics") {
+			attachment = v
+		}
+	}
+	if attachment == nil {
+		return nil, fmt.Errorf("You have to attach an .ics file to the message")
+	}
+	if attachment.Size > maxCalendarSize {
+		return nil, fmt.Errorf("Calendar files can't be larger than %v KB", maxCalendarSize/1024)
+	}
+	resp, err := info.DG.Client.Get(attachment.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Couldn't download %s: %s", attachment.Filename, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCalendarSize+1))
+	if err == nil && len(data) > maxCalendarSize {
+		err = fmt.Errorf("Calendar files can't be larger than %v KB", maxCalendarSize/1024)
+	}
+	return data, err
+}
+
+// importEvent adds an event from a calendar to the schedule. Returns why it was skipped, or an empty string if it wasn't.
+func importEvent(info *bot.GuildInfo, e *bot.CalendarEvent, now time.Time) string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	start := e.Start.UTC()
+	if e.Recurrence != nil { // Skip ahead to the next occurrence that hasn't happened yet
+		for i := 0; !start.After(now) && i < bot.MaxScheduleRows; i++ {
+			next, ok := e.Recurrence.Advance(start)
+			if !ok {
+				return "it's over"
+			}
+			start = next
+		}
+	}
+	if !start.After(now) {
+		return "it's in the past"
+	}
+	ty := bot.EventGeneric
+	if e.Episode {
+		ty = bot.EventEpisode
+	}
+	guild := bot.SBatoi(info.ID)
+	if t := info.Bot.DB.GetScheduleDate(guild, ty, e.Summary); t != nil && t.Equal(start) {
+		return "it's already in the schedule"
+	}
+	channel := e.Channel
+	if ch, private := info.Bot.ChannelIsPrivate(channel); private || ch == nil || ch.GuildID != info.ID {
+		channel = bot.ChannelEmpty // The channel belongs to the server the calendar was exported from
+	}
+	if err := info.Bot.DB.AddScheduleEvent(guild, start, ty, bot.EventData{Message: e.Summary}, channel, e.Recurrence); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func (c *importCalendarCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	data, err := downloadCalendar(info, msg)
+	if err != nil {
+		return bot.ReturnError(err)
+	}
+	events, err := bot.ParseCalendar(bytes.NewReader(data), info.GetTimezone(bot.DiscordUser(msg.Author.ID)))
+	if err != nil {
+		return bot.ReturnError(err)
+	}
+
+	now := bot.GetTimestamp(msg)
+	imported := 0
+	skipped := []string{}
+	for i := range events {
+		reason := importEvent(info, &events[i], now)
+		if len(reason) == 0 {
+			imported++
+			continue
+		}
+		name := events[i].Summary
+		if len(name) == 0 {
+			name = "An event"
+		}
+		skipped = append(skipped, name+": "+reason)
+	}
+
+	s := "Imported " + bot.Pluralize(int64(imported), " event") + "."
+	if len(skipped) > 0 {
+		s += " Skipped " + strconv.Itoa(len(skipped)) + ":"
+		for i, v := range skipped {
+			if i >= maxSkippedReasons {
+				s += "\n  ...and " + strconv.Itoa(len(skipped)-i) + " more."
+				break
+			}
+			s += "\n  " + v
+		}
+	}
+	return "```\n" + info.Sanitize(s, bot.CleanCodeBlock) + "```", len(skipped) > 5, nil
+}
+func (c *importCalendarCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Adds the events in an attached .ics file to the schedule. Events in the EPISODE category become episodes, and everything else becomes an event. Repeating events keep their recurrence rules, as long as they only use rules the schedule supports (see `" + info.Config.Basic.CommandPrefix + "help addevent`). Events that are over, or that are already in the schedule, are skipped. Times without a timezone are read in your timezone.",
+		Params: []bot.CommandUsageParam{
+			{Name: "file", Desc: "An .ics file attached to the message, exported from a calendar app or with `" + info.Config.Basic.CommandPrefix + "exportcalendar`.", Optional: false},
+		},
+	}
+}
@@ -0,0 +1,80 @@
+package schedulermodule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	bot "../sweetiebot"
+	"github.com/blackhole12/discordgo"
+)
+
+type failedEventsCommand struct {
+}
+
+func (c *failedEventsCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "FailedEvents",
+		Usage:     "Lists scheduled events that failed.",
+		Sensitive: true,
+	}
+}
+
+func (c *failedEventsCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	guild := bot.SBatoi(info.ID)
+	if args.Has("retry/dismiss") {
+		if !args.Has("ID") {
+			return "```\nYou must specify an event ID.```", false, nil
+		}
+		id, err := strconv.ParseUint(strings.TrimPrefix(args.String("ID"), "#"), 10, 64)
+		if err != nil {
+			return "```\nCould not parse event ID. Make sure you only specify the number itself.```", false, nil
+		}
+		switch args.String("retry/dismiss") {
+		case "retry":
+			ok, err := info.Bot.DB.RetryFailedEvent(guild, id)
+			if err != nil {
+				return bot.ReturnError(err)
+			}
+			if !ok {
+				return "```\nError: Event #" + bot.SBitoa(id) + " hasn't failed.```", false, nil
+			}
+			return "```\nEvent #" + bot.SBitoa(id) + " will be retried in a moment.```", false, nil
+		case "dismiss":
+			for _, v := range info.Bot.DB.GetFailedEvents(guild, bot.MaxScheduleRows) {
+				if v.ID == id {
+					if err := info.Bot.DB.FinishEvent(id); err != nil {
+						return bot.ReturnError(err)
+					}
+					return "```\nDismissed event #" + bot.SBitoa(id) + ". If it repeats, it will run again at its next occurrence.```", false, nil
+				}
+			}
+			return "```\nError: Event #" + bot.SBitoa(id) + " hasn't failed.```", false, nil
+		}
+	}
+
+	events := info.Bot.DB.GetFailedEvents(guild, 20)
+	if len(events) == 0 {
+		return "```\nNo scheduled events have failed.```", false, nil
+	}
+	lines := make([]string, 0, len(events)+1)
+	lines = append(lines, "Failed Events:")
+	for _, v := range events {
+		ty, data := describeEvent(info, &v.ScheduleEvent)
+		t := info.ApplyTimezone(v.Date, bot.DiscordUser(msg.Author.ID)).Format("Jan 2 2006 3:04pm")
+		lines = append(lines, fmt.Sprintf("#%v **%s** [%s] %s - failed %s: `%s`", bot.SBitoa(v.ID), t, ty, info.Sanitize(data, bot.CleanMentions|bot.CleanPings|bot.CleanEmotes), bot.Pluralize(int64(v.Attempts), " time"), info.Sanitize(v.Error, bot.CleanCode)))
+	}
+	return strings.Join(lines, "\n"), len(lines) > 6, nil
+}
+func (c *failedEventsCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "When a scheduled event fails, like an unban that discord rejects, the scheduler tries it again after a minute, then waits twice as long after every failure, up to an hour. After " + strconv.Itoa(bot.MaxEventAttempts) + " attempts it gives up and tells the mod channel. This lists the events it gave up on, so they can be retried once the problem is fixed, or dismissed.",
+		Params: []bot.CommandUsageParam{
+			{Name: "retry/dismiss", Desc: "Runs the event again on the next tick, or drops it. Dismissing a repeating event only skips the occurrence that failed.", Optional: true, Type: bot.ArgEnum, Values: []string{"retry", "dismiss"}},
+			{Name: "ID", Desc: "The ID of a failed event, as listed by this command.", Optional: true},
+		},
+	}
+}
@@ -0,0 +1,282 @@
+package schedulermodule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	bot "../sweetiebot"
+	"github.com/blackhole12/discordgo"
+)
+
+// How many upcoming events of each type are checked for due reminders on every tick
+const maxRemindedEvents = 100
+
+// rsvpEmoji returns the emoji members react with to RSVP, in the form the discord API expects: the emoji itself, or
+// name:id for a custom emoji.
+func rsvpEmoji(info *bot.GuildInfo) string {
+	emoji := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(Events(info).RSVPEmoji), "<"), ">")
+	if strings.Count(emoji, ":") > 1 { // A custom emoji pasted as <:name:id> or <a:name:id>
+		emoji = emoji[strings.Index(emoji, ":")+1:]
+	}
+	return emoji
+}
+
+// showEmoji returns how an emoji from rsvpEmoji is written in a message
+func showEmoji(emoji string) string {
+	if strings.Contains(emoji, ":") {
+		return "<:" + emoji + ">"
+	}
+	return emoji
+}
+
+// describeReminders lists when members who RSVP get reminded, like "1 day and 15 minutes before it starts"
+func describeReminders(info *bot.GuildInfo) string {
+	offsets := Events(info).RSVPReminders.Durations()
+	s := make([]string, len(offsets))
+	for i, v := range offsets {
+		s[i] = v.String()
+	}
+	switch len(s) {
+	case 0:
+		return ""
+	case 1:
+		return s[0] + " before it starts"
+	}
+	return strings.Join(s[:len(s)-1], ", ") + " and " + s[len(s)-1] + " before it starts"
+}
+
+// getRSVPEvent finds the event members are RSVPing to, which has to be an event or an episode. Returns an error
+// message if it can't.
+func getRSVPEvent(info *bot.GuildInfo, arg string) (*bot.ScheduleEvent, string) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(arg, "#"), 10, 64)
+	if err != nil {
+		return nil, "```\nCould not parse event ID. Make sure you only specify the number itself.```"
+	}
+	e := info.Bot.DB.GetEvent(bot.SBatoi(info.ID), id)
+	if e == nil {
+		return nil, "```\nError: Event does not exist.```"
+	}
+	if e.Type != bot.EventGeneric && e.Type != bot.EventEpisode {
+		return nil, "```\nError: You can only RSVP to events and episodes.```"
+	}
+	return e, ""
+}
+
+// remindAttendees DMs everyone going to an upcoming event once each of the reminder offsets before it passes. A member
+// who RSVPs after an offset has passed only gets the reminders that are still to come.
+func (w *SchedulerModule) remindAttendees(info *bot.GuildInfo, now time.Time) {
+	offsets := Events(info).RSVPReminders
+	if len(offsets) == 0 {
+		return
+	}
+	guild := bot.SBatoi(info.ID)
+	for _, ty := range []uint8{bot.EventGeneric, bot.EventEpisode} {
+		for _, e := range info.Bot.DB.GetEventsByType(guild, ty, maxRemindedEvents) {
+			due, offset, ok := offsets.DueReminder(e.Date, now)
+			if !ok {
+				if e.Date.After(now) {
+					break // Events are sorted by date, so none of the later ones are due either
+				}
+				continue
+			}
+			data := e.Payload()
+			for _, r := range info.Bot.DB.GetRSVPs(e.ID) {
+				if !r.Reminded.Before(due) {
+					continue
+				}
+				ch, err := info.DG.UserChannelCreate(r.User.String())
+				info.Logger().With(bot.LogFields{"user": r.User.String()}).LogError("Error opening private channel: ", err)
+				if err == nil {
+					info.SendMessage(bot.DiscordChannel(ch.ID), "Reminder: **"+data.Message+"** starts in "+offset.String()+" on "+info.Name+"! If you can't make it anymore, use `"+info.Config.Basic.CommandPrefix+"rsvp "+bot.SBitoa(e.ID)+" cancel` on the server.")
+				}
+				info.Bot.DB.SetRSVPReminded(e.ID, r.User, now)
+			}
+		}
+	}
+}
+
+// OnMessageReactionAdd discord hook
+func (w *SchedulerModule) OnMessageReactionAdd(info *bot.GuildInfo, r *discordgo.MessageReaction) {
+	if reactionEmoji(r) != rsvpEmoji(info) || !info.Bot.DB.CheckStatus() {
+		return
+	}
+	if e := info.Bot.DB.GetRSVPEvent(bot.SBatoi(info.ID), bot.SBatoi(r.MessageID)); e != nil {
+		info.Bot.DB.AddRSVP(bot.SBatoi(info.ID), e.ID, bot.DiscordUser(r.UserID))
+	}
+}
+
+// OnMessageReactionRemove discord hook
+func (w *SchedulerModule) OnMessageReactionRemove(info *bot.GuildInfo, r *discordgo.MessageReaction) {
+	if reactionEmoji(r) != rsvpEmoji(info) || !info.Bot.DB.CheckStatus() {
+		return
+	}
+	if e := info.Bot.DB.GetRSVPEvent(bot.SBatoi(info.ID), bot.SBatoi(r.MessageID)); e != nil {
+		info.Bot.DB.RemoveRSVP(e.ID, bot.DiscordUser(r.UserID))
+	}
+}
+
+// reactionEmoji returns the emoji of a reaction in the same form as rsvpEmoji
+func reactionEmoji(r *discordgo.MessageReaction) string {
+	if len(r.Emoji.ID) > 0 {
+		return r.Emoji.Name + ":" + r.Emoji.ID
+	}
+	return r.Emoji.Name
+}
+
+type rsvpCommand struct {
+}
+
+func (c *rsvpCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:  "RSVP",
+		Usage: "Says you're going to an event.",
+	}
+}
+
+func (c *rsvpCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	e, errmsg := getRSVPEvent(info, args.String("ID"))
+	if e == nil {
+		return errmsg, false, nil
+	}
+	name := info.Sanitize(e.Payload().Message, bot.CleanCodeBlock)
+	user := bot.DiscordUser(msg.Author.ID)
+
+	if args.Has("cancel") {
+		removed, err := info.Bot.DB.RemoveRSVP(e.ID, user)
+		if err != nil {
+			return bot.ReturnError(err)
+		}
+		if !removed {
+			return "```\nYou weren't going to " + name + ".```", false, nil
+		}
+		return "```\nYou're no longer going to " + name + ".```", false, nil
+	}
+
+	added, err := info.Bot.DB.AddRSVP(bot.SBatoi(info.ID), e.ID, user)
+	if err != nil {
+		return bot.ReturnError(err)
+	}
+	if !added {
+		return "```\nYou're already going to " + name + ".```", false, nil
+	}
+	s := "You're going to " + name + "!"
+	if reminders := describeReminders(info); len(reminders) > 0 {
+		s += " " + info.GetBotName() + " will remind you " + reminders + ", as long as you accept direct messages from this server."
+	}
+	return "```\n" + s + "```", false, nil
+}
+func (c *rsvpCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Says you're going to an event or episode, so you'll show up in `" + info.Config.Basic.CommandPrefix + "attendees` and get a reminder in your DMs before it starts. You can also RSVP by reacting to a message posted with `" + info.Config.Basic.CommandPrefix + "postevent`.",
+		Params: []bot.CommandUsageParam{
+			{Name: "ID", Desc: "The event ID as gotten from a `" + info.Config.Basic.CommandPrefix + "schedule` command.", Optional: false},
+			{Name: "cancel", Desc: "Says you aren't going anymore.", Optional: true, Type: bot.ArgEnum, Values: []string{"cancel", "no", "remove"}},
+		},
+	}
+}
+
+type attendeesCommand struct {
+}
+
+func (c *attendeesCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:  "Attendees",
+		Usage: "Lists who's going to an event.",
+	}
+}
+
+func (c *attendeesCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	e, errmsg := getRSVPEvent(info, args.String("ID"))
+	if e == nil {
+		return errmsg, false, nil
+	}
+	name := e.Payload().Message
+	rsvps := info.Bot.DB.GetRSVPs(e.ID)
+	if len(rsvps) == 0 {
+		return "```\n" + info.Sanitize("Nobody has RSVPed to "+name+" yet. Use "+info.Config.Basic.CommandPrefix+"rsvp "+bot.SBitoa(e.ID)+" to go.", bot.CleanCodeBlock) + "```", false, nil
+	}
+	names := make([]string, len(rsvps))
+	for i, v := range rsvps {
+		names[i] = info.GetUserName(v.User)
+	}
+	s := fmt.Sprintf("%s going to %s:\n%s", bot.Pluralize(int64(len(rsvps)), " member"), name, strings.Join(names, ", "))
+	return "```\n" + info.Sanitize(s, bot.CleanCodeBlock) + "```", len(rsvps) > 50, nil
+}
+func (c *attendeesCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Lists everyone who has RSVPed to an event or episode, in the order they RSVPed.",
+		Params: []bot.CommandUsageParam{
+			{Name: "ID", Desc: "The event ID as gotten from a `" + info.Config.Basic.CommandPrefix + "schedule` command.", Optional: false},
+		},
+	}
+}
+
+type postEventCommand struct {
+}
+
+func (c *postEventCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "PostEvent",
+		Usage:     "Posts an event that members can RSVP to.",
+		Sensitive: true,
+	}
+}
+
+func (c *postEventCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	e, errmsg := getRSVPEvent(info, args.String("ID"))
+	if e == nil {
+		return errmsg, false, nil
+	}
+	emoji := rsvpEmoji(info)
+	if len(emoji) == 0 {
+		return "```\nError: There's no RSVP emoji. Set one with " + info.Config.Basic.CommandPrefix + "setconfig events.rsvpemoji.```", false, nil
+	}
+
+	channel := eventChannel(info, e, bot.DiscordChannel(msg.ChannelID))
+	if args.Has("channel") {
+		ch := args.Channel("channel")
+		if c, private := info.Bot.ChannelIsPrivate(ch); private || c == nil || c.GuildID != info.ID {
+			return "```\nError: That channel isn't on this server!```", false, nil
+		}
+		channel = ch
+	}
+
+	s := fmt.Sprintf("**%s** starts <t:%v:F>! React with %s if you're going.", e.Payload().Message, e.Date.Unix(), showEmoji(emoji))
+	if reminders := describeReminders(info); len(reminders) > 0 {
+		s += " Everyone who's going gets a reminder " + reminders + "."
+	}
+	post, err := info.DG.ChannelMessageSend(channel.String(), info.Sanitize(s, bot.CleanPings))
+	if err != nil {
+		return bot.ReturnError(err)
+	}
+	if err = info.Bot.DB.SetRSVPMessage(bot.SBatoi(info.ID), e.ID, bot.SBatoi(post.ID)); err != nil {
+		return bot.ReturnError(err)
+	}
+	if err = info.DG.MessageReactionAdd(post.ChannelID, post.ID, emoji); err != nil {
+		return "```\nPosted the event, but couldn't react to it with the RSVP emoji: " + err.Error() + "```", false, nil
+	}
+	if channel == bot.DiscordChannel(msg.ChannelID) {
+		return "", false, nil
+	}
+	return "```\nPosted event #" + bot.SBitoa(e.ID) + " in " + info.Sanitize(channel.Show(info), bot.CleanCodeBlock) + ".```", false, nil
+}
+func (c *postEventCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Posts an event or episode that members can RSVP to by reacting with the `events.rsvpemoji` emoji. Removing the reaction takes back the RSVP. Only the most recent post of an event counts, so posting it again replaces the old post.",
+		Params: []bot.CommandUsageParam{
+			{Name: "ID", Desc: "The event ID as gotten from a `" + info.Config.Basic.CommandPrefix + "schedule` command.", Optional: false},
+			{Name: "channel", Desc: "A channel ping. Defaults to the channel the event is announced in, if it has one, and otherwise the current channel.", Optional: true, Type: bot.ArgChannel},
+		},
+	}
+}
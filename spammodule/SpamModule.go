@@ -17,21 +17,27 @@ type userPressure struct {
 	pressure    float32
 	lastmessage int64
 	lastcache   string
+	history     []*spamMessage // The user's last spam.duplicatelookback messages
 }
 
 // SpamModule detects banned emotes and deletes them
 type SpamModule struct {
 	sync.Mutex
 	tracker      map[bot.DiscordUser]*userPressure
-	lockdown     discordgo.VerificationLevel // if -1 no lockdown was initiated, otherwise remembers the previous lockdown setting
-	lastlockdown time.Time
+	recent       []*spamMessage           // Recent messages from everyone, used to detect copypasta waves
+	joins        []*screenJoin            // Recent joins, used to find clusters of similar names
+	screened     map[string]*screenResult // Members flagged by join screening since the last digest
+	screenorder  []string                 // The order members in screened were flagged in
+	lockdownlock sync.Mutex               // Held while engaging or disabling a lockdown, which takes several requests
+	lockdown     int                      // lockdownUnknown, lockdownOff or lockdownOn
+	lockdownends *time.Time               // When the current lockdown ends, or nil if it has to be disabled manually
 }
 
 // New spam module
 func New() *SpamModule {
 	w := &SpamModule{
 		tracker:  make(map[bot.DiscordUser]*userPressure),
-		lockdown: -1,
+		screened: make(map[string]*screenResult),
 	}
 	return w
 }
@@ -49,26 +55,46 @@ func (w *SpamModule) Commands() []bot.Command {
 		&getPressureCommand{w},
 		&getRaidCommand{w},
 		&banRaidCommand{w},
+		bot.TypedCommand(&replaySpamCommand{}),
+		bot.TypedCommand(&lockdownCommand{w}),
 	}
 }
 
 // Description of the module
 func (w *SpamModule) Description() string {
-	return "Tracks all channels it is active on for spammers. Each message someone sends generates \"pressure\", which decays rapidly. Long messages, messages with links, or messages with pings will generate more pressure. If a user generates too much pressure, they will be silenced and the moderators notified. Also detects groups of people joining at the same time and alerts the moderators of a potential raid."
+	return "Tracks all channels it is active on for spammers. Each message someone sends generates \"pressure\", which decays rapidly. Long messages, messages with links, pings, invites, lots of emojis or zalgo text, and messages that repeat what the user or other users recently said will generate more pressure. If a user generates too much pressure, they will be silenced and the moderators notified. Also detects groups of people joining at the same time and alerts the moderators of a potential raid, which can engage a lockdown of the server, and can screen new members by account age, avatar and username."
 }
 
 // OnTick discord hook
 func (w *SpamModule) OnTick(info *bot.GuildInfo, t time.Time) {
-	if w.lockdown != -1 && t.Sub(w.lastlockdown) > (time.Duration(info.Config.Spam.LockdownDuration)*time.Second) {
+	w.sendScreenDigest(info)
+	if !info.Bot.DB.CheckStatus() {
+		return
+	}
+	if engaged, ends := w.lockdownEnds(info); engaged && ends != nil && !t.Before(*ends) {
 		w.DisableLockdown(info)
 	}
 }
 
-func silenceMember(user *discordgo.User, info *bot.GuildInfo) int8 {
-	defer info.Bot.DG.GuildMemberRoleAdd(info.ID, user.ID, info.Config.Basic.SilenceRole.String()) // No matter what, tell discord to make this spammer silent even if we've already done this, because discord is fucking stupid and sometimes fails for no reason
-	m := info.Bot.DG.GetMemberCreate(user, info.ID)
-	info.Bot.DG.State.Lock()         // Manually set our internal state to say this spammer is silent to prevent race conditions
-	defer info.Bot.DG.State.Unlock() // this defer will execute BEFORE our doDiscordSilence defer, minimizing lock time
+// silenceMember silences a member, or times them out if spam.timeoutduration is set. If the timeout fails, they are
+// silenced instead, unless there is no silence role. Returns 1 if they were already silenced, 0 if they weren't, and -1
+// if they couldn't be, along with whether they were timed out and the error that stopped them from being timed out.
+func silenceMember(user *discordgo.User, info *bot.GuildInfo) (int8, bool, error) {
+	if info.Config.Spam.TimeoutDuration > 0 {
+		code, err := timeoutMember(user, info)
+		if code >= 0 || info.Config.Basic.SilenceRole == bot.RoleEmpty {
+			return code, code >= 0, err
+		}
+	}
+	return addSilenceRole(user, info), false, nil
+}
+
+// addSilenceRole gives a member the silence role. Returns 1 if they already had it, and 0 if they didn't.
+func addSilenceRole(user *discordgo.User, info *bot.GuildInfo) int8 {
+	defer info.DG.GuildMemberRoleAdd(info.ID, user.ID, info.Config.Basic.SilenceRole.String()) // No matter what, tell discord to make this spammer silent even if we've already done this, because discord is fucking stupid and sometimes fails for no reason
+	m := info.DG.GetMemberCreate(user, info.ID)
+	info.DG.State.Lock()         // Manually set our internal state to say this spammer is silent to prevent race conditions
+	defer info.DG.State.Unlock() // this defer will execute BEFORE our doDiscordSilence defer, minimizing lock time
 	if bot.MemberHasRole(m, info.Config.Basic.SilenceRole) {
 		return 1
 	}
@@ -77,10 +103,41 @@ func silenceMember(user *discordgo.User, info *bot.GuildInfo) int8 {
 	return 0
 }
 
+func timeoutMember(user *discordgo.User, info *bot.GuildInfo) (int8, error) {
+	if info.TimedOut(bot.DiscordUser(user.ID)) != nil {
+		return 1, nil
+	}
+	duration := time.Duration(info.Config.Spam.TimeoutDuration) * time.Second
+	if duration > bot.MaxTimeout {
+		duration = bot.MaxTimeout
+	}
+	if _, err := info.TimeoutMember(bot.DiscordUser(user.ID), time.Now().UTC().Add(duration)); err != nil {
+		info.Logger().With(bot.LogFields{"user": user.ID}).LogError("Error timing out member: ", err)
+		return -1, err
+	}
+	return 0, nil
+}
+
+// spamCase records a case against a member that was automatically silenced or timed out
+func spamCase(u *discordgo.User, info *bot.GuildInfo, reason string, timedOut bool) {
+	user := bot.DiscordUser(u.ID)
+	if !timedOut {
+		info.AddCase(bot.CaseSilence, user, info.Bot.SelfID, "Automatically silenced for "+reason+".", 0, nil)
+		return
+	}
+	var event *uint64
+	var duration time.Duration
+	if until := info.TimedOut(user); until != nil {
+		event = info.Bot.DB.FindEvent(u.ID, bot.SBatoi(info.ID), bot.EventTimeout)
+		duration = until.Sub(time.Now().UTC()).Round(time.Second)
+	}
+	info.AddCase(bot.CaseTimeout, user, info.Bot.SelfID, "Automatically timed out for "+reason+".", duration, event)
+}
+
 func killSpammer(u *discordgo.User, info *bot.GuildInfo, msg *discordgo.Message, reason string, oldpressure float32, newpressure float32) {
 	// Before anything else happens, we delete this message. This ensures that even if we get rate-limited, we can still delete any new messages
 	if info.Config.Spam.MaxRemoveLookback >= 0 {
-		info.Bot.DG.ChannelMessageDelete(msg.ChannelID, msg.ID)
+		info.DG.ChannelMessageDelete(msg.ChannelID, msg.ID)
 	}
 
 	timestamp := bot.GetTimestamp(msg)
@@ -92,7 +149,7 @@ func killSpammer(u *discordgo.User, info *bot.GuildInfo, msg *discordgo.Message,
 		}
 	}
 
-	ch, err := info.Bot.DG.State.Channel(msg.ChannelID)
+	ch, err := info.DG.State.Channel(msg.ChannelID)
 	chname := msg.ChannelID
 	if err == nil {
 		chname = ch.Name
@@ -103,12 +160,18 @@ func killSpammer(u *discordgo.User, info *bot.GuildInfo, msg *discordgo.Message,
 	}
 	logmsg := fmt.Sprintf("Killing spammer %s (pressure: %v -> %v). Last message sent on #%s in %s: \n%s%s", u.Username, oldpressure, newpressure, chname, info.Name, lastmsg, msgembeds)
 	if info.Config.Users.WelcomeChannel.Equals(msg.ChannelID) {
-		info.Bot.DG.GuildBanCreateWithReason(info.ID, u.ID, "Autobanned for "+reason+" in the welcome channel.", 1)
+		info.DG.GuildBanCreateWithReason(info.ID, u.ID, "Autobanned for "+reason+" in the welcome channel.", 1)
+		info.AddCase(bot.CaseBan, bot.DiscordUser(u.ID), info.Bot.SelfID, "Autobanned for "+reason+" in the welcome channel.", 0, nil)
 		info.SendMessage(info.Config.Basic.ModChannel, "Alert: <@"+u.ID+"> was banned for "+reason+" in the welcome channel.")
-		info.Log(logmsg)
+		info.MessageLogger(msg).Warning(logmsg)
 		return
 	}
-	silenced := silenceMember(u, info) > 0
+	code, timedOut, err := silenceMember(u, info)
+	silenced := code > 0
+	if code == 0 {
+		info.Bot.Metrics.SpamSilences.Inc()
+		spamCase(u, info, reason, timedOut)
+	}
 
 	if info.Config.Spam.MaxRemoveLookback > 0 && !silenced {
 		IDs := []string{msg.ID}
@@ -117,8 +180,8 @@ func killSpammer(u *discordgo.User, info *bot.GuildInfo, msg *discordgo.Message,
 
 	EndLoop: // Even though this label is defined above the for loop, breaking to this label will actually skip the for loop entirely. Don't ask.
 		for {
-			messages, err := info.Bot.DG.ChannelMessages(msg.ChannelID, 99, lastid, "", "")
-			info.LogError("Error encountered while attempting to retrieve messages: ", err)
+			messages, err := info.DG.ChannelMessages(msg.ChannelID, 99, lastid, "", "")
+			info.MessageLogger(msg).LogError("Error encountered while attempting to retrieve messages: ", err)
 			if len(messages) == 0 || err != nil {
 				break
 			}
@@ -134,77 +197,108 @@ func killSpammer(u *discordgo.User, info *bot.GuildInfo, msg *discordgo.Message,
 			}
 		}
 
-		info.Bot.DG.BulkDeleteBypass(msg.ChannelID, IDs) // We use the bypass because we can't risk the channel not being in the state for some reason
+		info.DG.BulkDeleteBypass(msg.ChannelID, IDs) // We use the bypass because we can't risk the channel not being in the state for some reason
 	} // otherwise we don't delete anything
 
-	if !silenced { // Only send the alert if they weren't silenced already
-		info.SendMessage(info.Config.Basic.ModChannel, "Alert: <@"+u.ID+"> was silenced for "+reason+". Please investigate.") // Alert admins
-		info.Log(logmsg)
+	if code < 0 {
+		info.SendMessage(info.Config.Basic.ModChannel, "Alert: <@"+u.ID+"> could not be timed out for "+reason+" ("+err.Error()+"). Please investigate.")
+		info.MessageLogger(msg).Warning(logmsg)
+	} else if !silenced { // Only send the alert if they weren't silenced already
+		action := "silenced"
+		if timedOut {
+			action = "timed out"
+		}
+		info.SendMessage(info.Config.Basic.ModChannel, "Alert: <@"+u.ID+"> was "+action+" for "+reason+". Please investigate.") // Alert admins
+		info.MessageLogger(msg).Warning(logmsg)
 	} else {
-		info.Log("Killing spammer " + u.Username)
+		info.MessageLogger(msg).Info("Killing spammer " + u.Username)
 	}
 }
 
 // Gets the pressure generated from an isolated message, ignoring the context.
-func getPressure(info *bot.GuildInfo, m *discordgo.Message, edited bool) float32 {
-	p := info.Config.Spam.ImagePressure * float32(len(m.Attachments))
-	p += info.Config.Spam.PingPressure * float32(len(m.Mentions))
-	p += info.Config.Spam.ImagePressure * float32(len(m.Embeds))
-	p += info.Config.Spam.LengthPressure * float32(len(m.Content))
-	p += info.Config.Spam.LinePressure * float32(strings.Count(m.Content, "\n"))
-	p += info.Config.Spam.BasePressure
+func getPressure(config *bot.BotConfig, m *discordgo.Message, edited bool) float32 {
+	p := config.Spam.ImagePressure * float32(len(m.Attachments))
+	p += config.Spam.PingPressure * float32(len(m.Mentions))
+	p += config.Spam.ImagePressure * float32(len(m.Embeds))
+	p += config.Spam.LengthPressure * float32(len(m.Content))
+	p += config.Spam.LinePressure * float32(strings.Count(m.Content, "\n"))
+	p += getContentPressure(config, m)
+	p += config.Spam.BasePressure
 	if edited { // Editing a message contributes only the square root of the total (so you can edit a post with lots of pictures and not get instabanned)
 		p = float32(math.Sqrt(float64(p)))
 	}
 	return p
 }
 
+// isExempt returns true if the author of a message is never checked for spam
+func isExempt(info *bot.GuildInfo, config *bot.BotConfig, author *discordgo.User) bool {
+	user := bot.DiscordUser(author.ID)
+	return info.UserIsMod(user) || info.UserIsAdmin(user) ||
+		(config.Spam.IgnoreRole != bot.RoleEmpty && info.UserHasRole(user, config.Spam.IgnoreRole)) ||
+		author.Bot
+}
+
+// addPressure adds the pressure generated by a message to its author's pressure using the given settings, and returns
+// their tracker along with their previous pressure. Returns false if the message has an invalid timestamp.
+func (w *SpamModule) addPressure(config *bot.BotConfig, m *discordgo.Message, edited bool) (*userPressure, float32, bool) {
+	author := bot.DiscordUser(m.Author.ID)
+	timestamp := bot.GetTimestamp(m)
+	w.Lock()
+	_, ok := w.tracker[author]
+	if !ok {
+		w.tracker[author] = &userPressure{0, timestamp.Unix()*1000 + int64(timestamp.Nanosecond()/1000000), "", nil}
+	}
+	track := w.tracker[author]
+	w.Unlock()
+	p := getPressure(config, m, edited)
+	if len(m.Content) > 0 && strings.ToLower(m.Content) == track.lastcache {
+		p += config.Spam.RepeatPressure
+	}
+	track.lastcache = strings.ToLower(m.Content)
+	last := track.lastmessage
+	track.lastmessage = timestamp.Unix()*1000 + int64(timestamp.Nanosecond()/1000000)
+	if track.lastmessage < last { // This can happen because discord has a bad habit of re-sending timestamps if anything so much as touches a message
+		track.lastmessage = last
+		return track, track.pressure, false // An invalid timestamp is never spam
+	}
+	interval := track.lastmessage - last
+	if !edited { // An edited message would just be a duplicate of itself
+		p += w.getContextPressure(config, m, track, track.lastmessage)
+	}
+
+	override, ok := config.Spam.MaxChannelPressure[bot.DiscordChannel(m.ChannelID)]
+	if ok && override > 0.0 {
+		p *= (config.Spam.MaxPressure / override)
+	}
+	oldpressure := track.pressure
+	track.pressure -= config.Spam.BasePressure * (float32(interval) / (config.Spam.PressureDecay * 1000.0))
+	if track.pressure < 0 {
+		track.pressure = 0
+	}
+	track.pressure += p
+	return track, oldpressure, true
+}
+
 func (w *SpamModule) checkSpam(info *bot.GuildInfo, m *discordgo.Message, edited bool) bool {
 	if m.Author != nil {
 		author := bot.DiscordUser(m.Author.ID)
 		if info.UserHasRole(author, info.Config.Basic.SilenceRole) && !info.Config.Users.WelcomeChannel.Equals(m.ChannelID) {
-			ch, _ := info.Bot.DG.Channel(m.ChannelID)
+			ch, _ := info.DG.Channel(m.ChannelID)
 			info.ChannelMessageDelete(ch, m.ID)
 			return true
 		}
-		if info.UserIsMod(author) || info.UserIsAdmin(author) ||
-			(info.Config.Spam.IgnoreRole != bot.RoleEmpty && info.UserHasRole(author, info.Config.Spam.IgnoreRole)) ||
-			m.Author.Bot {
+		if isExempt(info, &info.Config, m.Author) {
 			return false
 		}
-		timestamp := bot.GetTimestamp(m)
-		w.Lock()
-		_, ok := w.tracker[author]
-		if !ok {
-			w.tracker[author] = &userPressure{0, timestamp.Unix()*1000 + int64(timestamp.Nanosecond()/1000000), ""}
-		}
-		track := w.tracker[author]
-		w.Unlock()
-		p := getPressure(info, m, edited)
-		if len(m.Content) > 0 && strings.ToLower(m.Content) == track.lastcache {
-			p += info.Config.Spam.RepeatPressure
-		}
-		track.lastcache = strings.ToLower(m.Content)
-		last := track.lastmessage
-		track.lastmessage = timestamp.Unix()*1000 + int64(timestamp.Nanosecond()/1000000)
-		if track.lastmessage < last { // This can happen because discord has a bad habit of re-sending timestamps if anything so much as touches a message
-			track.lastmessage = last
-			return false // An invalid timestamp is never spam
-		}
-		interval := track.lastmessage - last
-
-		override, ok := info.Config.Spam.MaxChannelPressure[bot.DiscordChannel(m.ChannelID)]
-		if ok && override > 0.0 {
-			p *= (info.Config.Spam.MaxPressure / override)
-		}
-		oldpressure := track.pressure
-		track.pressure -= info.Config.Spam.BasePressure * (float32(interval) / (info.Config.Spam.PressureDecay * 1000.0))
-		if track.pressure < 0 {
-			track.pressure = 0
-		}
-		track.pressure += p
+		track, oldpressure, ok := w.addPressure(&info.Config, m, edited)
 		//fmt.Println("Current Pressure: ", track.pressure)
-		if track.pressure > info.Config.Spam.MaxPressure {
+		if ok && track.pressure > info.Config.Spam.MaxPressure {
+			if info.Config.Spam.DryRun {
+				info.SendMessage(info.Config.Basic.ModChannel, fmt.Sprintf("Dry run: <@%s> would have been silenced for spamming too many messages (pressure: %v -> %v).", m.Author.ID, oldpressure, track.pressure))
+				info.MessageLogger(m).Info("Dry run: would have killed spammer " + m.Author.Username)
+				track.pressure = 0 // Pretend they were silenced, so each burst of spam is only reported once
+				return false
+			}
 			killSpammer(m.Author, info, m, "spamming too many messages", oldpressure, track.pressure)
 			return true
 		}
@@ -222,41 +316,6 @@ func (w *SpamModule) OnCommand(info *bot.GuildInfo, m *discordgo.Message) bool {
 	return w.checkSpam(info, m, false)
 }
 
-// DisableLockdown disables the guild lockdown, if there is one
-func (w *SpamModule) DisableLockdown(info *bot.GuildInfo) {
-	if w.lockdown != -1 {
-		modchan := info.Config.Basic.ModChannel
-		if info.Bot.Debug {
-			modchan, _ = info.Bot.DebugChannels[bot.DiscordGuild(info.ID)]
-		}
-		guild, err := info.GetGuild()
-		if err != nil {
-			info.SendMessage(modchan, "Guild cannot be found in state?!")
-		} else if guild.VerificationLevel != discordgo.VerificationLevelHigh {
-			info.SendMessage(modchan, fmt.Sprintf("The verification level is at %v instead of %v, which means it was manually changed by someone other than "+info.GetBotName()+", so it has not been restored.", guild.VerificationLevel, discordgo.VerificationLevelHigh))
-		} else {
-			g := discordgo.GuildParams{
-				Name:                        "",
-				Region:                      "",
-				VerificationLevel:           &w.lockdown,
-				DefaultMessageNotifications: 0,
-				AfkChannelID:                "",
-				AfkTimeout:                  0,
-				Icon:                        "",
-				OwnerID:                     "",
-				Splash:                      "",
-			}
-			_, err = info.Bot.DG.GuildEdit(info.ID, g)
-		}
-		if err != nil {
-			info.SendMessage(modchan, "Could not disengage lockdown! Make sure you've given the "+info.Bot.AppName+" role the Manage Server permission, you'll have to manually restore it yourself this time.")
-		} else {
-			info.SendMessage(modchan, "Lockdown disengaged, server verification levels restored.")
-		}
-		w.lockdown = -1
-	}
-}
-
 func (w *SpamModule) checkRaid(info *bot.GuildInfo, m *discordgo.Member, t time.Time) {
 	if !info.Bot.DB.CheckStatus() {
 		return
@@ -272,34 +331,30 @@ func (w *SpamModule) checkRaid(info *bot.GuildInfo, m *discordgo.Member, t time.
 				silenceMember(v.User, info)
 			}
 		}
-		ch := info.Config.Basic.ModChannel
-		if info.Bot.Debug {
-			ch, _ = info.Bot.DebugChannels[bot.DiscordGuild(info.ID)]
-		}
+		ch := modChannel(info)
 		message := "Use `" + info.Config.Basic.CommandPrefix + "autosilence all` to silence them!"
-		if info.Config.Spam.AutoSilence > 0 {
+		if info.Config.Spam.AutoSilence > 0 && info.Config.Spam.TimeoutDuration > 0 {
+			message = "Autosilence has been engaged and the following users timed out:"
+		} else if info.Config.Spam.AutoSilence > 0 {
 			message = "Autosilence has been engaged and the following users silenced:"
 		}
 		go info.SendMessage(ch, info.Config.Basic.ModRole.Display()+" Possible Raid Detected! "+message+"\n```"+strings.Join(s, "\n")+"```")
 		if info.Config.Spam.LockdownDuration > 0 {
-			if w.lockdown == -1 { // Only engage lockdown if it wasn't already engaged
-				guild, err := info.GetGuild()
-				if err != nil {
-					w.lockdown = discordgo.VerificationLevelHigh
-				} else {
-					w.lockdown = guild.VerificationLevel
-				}
-				level := discordgo.VerificationLevelHigh
-				g := discordgo.GuildParams{"", "", &level, 0, "", 0, "", "", ""}
-				_, err = info.Bot.DG.GuildEdit(info.ID, g)
-				if err != nil {
-					info.SendMessage(ch, "Could not engage lockdown! Make sure you've given "+info.GetBotName()+" the Manage Server permission, or disable the lockdown entirely via `"+info.Config.Basic.CommandPrefix+"setconfig spam.lockdownduration 0`.")
-				} else {
-					info.SendMessage(ch, fmt.Sprintf("Lockdown engaged! Server verification level will be reset in %v seconds. This lockdown can be manually ended via `"+info.Config.Basic.CommandPrefix+"autosilence off/alert/log`.", info.Config.Spam.LockdownDuration))
+			ends := t.Add(time.Duration(info.Config.Spam.LockdownDuration) * time.Second).UTC()
+			// Only engage lockdown if it wasn't already engaged, otherwise just push back its end. A lockdown that was
+			// manually engaged without an end is left alone.
+			if locked, current := w.lockdownEnds(info); !locked || (current != nil && current.Before(ends)) {
+				engaged, problems := w.EngageLockdown(info, &ends)
+				if engaged {
+					message := fmt.Sprintf("Lockdown engaged! Server verification level will be reset in %v seconds. This lockdown can be manually ended via `"+info.Config.Basic.CommandPrefix+"lockdown off` or `"+info.Config.Basic.CommandPrefix+"autosilence off`.", info.Config.Spam.LockdownDuration)
+					if len(problems) > 0 {
+						message += "\n" + strings.Join(problems, "\n")
+					}
+					info.SendMessage(ch, message)
+				} else if len(problems) > 0 {
+					info.SendMessage(ch, "Could not engage lockdown! "+strings.Join(problems, " ")+" You can disable the lockdown entirely via `"+info.Config.Basic.CommandPrefix+"setconfig spam.lockdownduration 0`.")
 				}
 			}
-			// Otherwise just reset the timer
-			w.lastlockdown = t
 		}
 	}
 }
@@ -312,6 +367,7 @@ func (w *SpamModule) OnGuildMemberAdd(info *bot.GuildInfo, m *discordgo.Member,
 			info.SendMessage(info.Config.Users.WelcomeChannel, "<@"+m.User.ID+"> "+info.Config.Users.WelcomeMessage)
 		}
 	}
+	w.screenMember(info, m.User, t)
 	w.checkRaid(info, m, t)
 }
 
@@ -367,7 +423,11 @@ func (c *autoSilenceCommand) Process(args []string, msg *discordgo.Message, indi
 	if info.Config.Spam.AutoSilence <= 0 {
 		c.s.DisableLockdown(info)
 	} else if c.s.isRecentRaid(info, timestamp) { // If there has recently been a raid, silence everyone who joined or theoretically could have joined since the beginning of the raid.
-		c.s.lastlockdown = timestamp // Reset lockdown timer just in case
+		if locked, ends := c.s.lockdownEnds(info); locked && ends != nil { // Reset lockdown timer just in case
+			if t := timestamp.Add(time.Duration(info.Config.Spam.LockdownDuration) * time.Second).UTC(); t.After(*ends) {
+				c.s.EngageLockdown(info, &t)
+			}
+		}
 		if !info.Bot.DB.CheckStatus() {
 			return "```\nAutosilence was engaged, but a database error prevents me from retroactively applying it!```", false, nil
 		}
@@ -413,7 +473,7 @@ func (c *wipeCommand) WipeMessages(ch *discordgo.Channel, num int, seconds int,
 		if n > 99 {
 			n = 99
 		}
-		list, err := info.Bot.DG.ChannelMessages(ch.ID, n, lastid, "", "")
+		list, err := info.DG.ChannelMessages(ch.ID, n, lastid, "", "")
 		if err != nil || len(list) == 0 {
 			return ret, err
 		}
@@ -565,10 +625,119 @@ func (c *banRaidCommand) Process(args []string, msg *discordgo.Message, indices
 	reason := fmt.Sprintf("Banned by %s#%s via the !banraid command.", msg.Author.Username, msg.Author.Discriminator)
 	users := c.s.getRaidUsers(info)
 	for _, v := range users {
-		info.Bot.DG.GuildBanCreateWithReason(info.ID, v.ID, reason, 1)
+		info.DG.GuildBanCreateWithReason(info.ID, v.ID, reason, 1)
 	}
 	return fmt.Sprintf("```\nBanned %v users. The ban log will reflect who ran this command.```", len(users)), false, nil
 }
 func (c *banRaidCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{Desc: "Bans all users that are considered part of the most recent raid, if there was one. Use " + info.Config.Basic.CommandPrefix + "getraid to check who will be banned before using this command."}
 }
+
+// The most logged messages !replayspam will run through the spam filter at once. Longer periods are cut off after the
+// oldest messages, and the reply says where it stopped.
+const maxReplayMessages = 5000
+
+// replaySpam runs logged messages through a fresh spam tracker using the given settings and returns the message that
+// pushed each user over the limit, without acting on any of them. Each user is only silenced once, like they would be
+// if the messages had actually been sent.
+func replaySpam(info *bot.GuildInfo, config *bot.BotConfig, messages []*discordgo.Message) []*discordgo.Message {
+	w := New()
+	killed := make(map[string]bool)
+	kills := []*discordgo.Message{}
+	for _, m := range messages {
+		m.Author.Bot = info.Bot.SelfID.Equals(m.Author.ID) // The chatlog doesn't remember who is a bot, but it does log our own messages
+		if killed[m.Author.ID] || isExempt(info, config, m.Author) {
+			continue
+		}
+		if track, _, ok := w.addPressure(config, m, false); ok && track.pressure > config.Spam.MaxPressure {
+			killed[m.Author.ID] = true
+			kills = append(kills, m)
+		}
+	}
+	return kills
+}
+
+type replaySpamCommand struct {
+}
+
+func (c *replaySpamCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "ReplaySpam",
+		Usage:     "Replays chat history through the spam filter.",
+		Sensitive: true,
+	}
+}
+
+func (c *replaySpamCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	channel := args.Channel("channel")
+	duration := args.Duration("duration")
+	end := bot.GetTimestamp(msg)
+	start := bot.Duration{Count: -duration.Count, Interval: duration.Interval}.After(end)
+
+	config := info.Config // Copy the config so the candidate settings never touch the real one
+	config.Spam.MaxChannelPressure = make(map[bot.DiscordChannel]float32)
+	for k, v := range info.Config.Spam.MaxChannelPressure {
+		config.Spam.MaxChannelPressure[k] = v
+	}
+	for _, setting := range args.Strings("settings") {
+		kv := strings.SplitN(setting, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Sprintf("```\n%s isn't a setting! Settings must look like maxpressure=80.```", setting), false, nil
+		}
+		name := strings.TrimPrefix(strings.ToLower(kv[0]), "spam.")
+		if name == "maxchannelpressure" { // Only one channel is replayed, so this sets the override for that channel
+			f, err := strconv.ParseFloat(kv[1], 32)
+			if err != nil {
+				return fmt.Sprintf("```\n%s is not a number!```", kv[1]), false, nil
+			}
+			config.Spam.MaxChannelPressure[channel] = float32(f)
+			continue
+		}
+		if _, ok := config.Spam.MaxChannelPressure[channel]; ok && name == "maxpressure" {
+			delete(config.Spam.MaxChannelPressure, channel) // Otherwise the channel override would hide the new max pressure
+		}
+		message := "spam." + name + " " + kv[1]
+		if s, ok := config.SetConfig(info, []string{"spam." + name, kv[1]}, []int{0, len(name) + 6}, message); !ok {
+			return "```\n" + s + "```", false, nil
+		}
+	}
+
+	messages := info.Bot.DB.GetChatlog(bot.SBatoi(info.ID), channel.Convert(), start, end, maxReplayMessages+1)
+	truncated := len(messages) > maxReplayMessages
+	if truncated {
+		messages = messages[:maxReplayMessages]
+	}
+	kills := replaySpam(info, &config, messages)
+	s := fmt.Sprintf("Replayed %v messages from %s over the past %s. ", len(messages), channel.Show(info), duration.String())
+	if truncated {
+		last := info.ApplyTimezone(bot.GetTimestamp(messages[len(messages)-1]), bot.DiscordUser(msg.Author.ID)).Format("Jan 2 3:04:05pm")
+		s += fmt.Sprintf("That's as many as can be replayed at once, so it stopped at %s, and nothing after that was replayed. Replay a shorter period to check the rest. ", last)
+	}
+	if len(kills) == 0 {
+		return "```\n" + s + "No one would have been silenced.```", false, nil
+	}
+	lines := []string{s + fmt.Sprintf("%v users would have been silenced:", len(kills))}
+	for i, m := range kills {
+		if i >= 20 {
+			lines = append(lines, fmt.Sprintf("...and %v more.", len(kills)-i))
+			break
+		}
+		t := info.ApplyTimezone(bot.GetTimestamp(m), bot.DiscordUser(msg.Author.ID)).Format("Jan 2 3:04:05pm")
+		content := info.Sanitize(m.Content, bot.CleanCodeBlock)
+		if len(content) > 100 {
+			content = content[:100] + "..."
+		}
+		lines = append(lines, fmt.Sprintf("%s at %s: %s", m.Author.Username, t, content))
+	}
+	return "```\n" + strings.Join(lines, "\n") + "```", false, nil
+}
+func (c *replaySpamCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Runs the messages logged in a channel over the given period of time through the spam filter, and reports who would have been silenced, without actually silencing anyone. Any spam settings given are only used for the replay, so you can try out new settings on real history before changing them with `" + info.Config.Basic.CommandPrefix + "setconfig`. Pings, attachments and embeds aren't logged, so they don't add pressure during a replay. At most " + strconv.Itoa(maxReplayMessages) + " messages are replayed, starting from the oldest.",
+		Params: []bot.CommandUsageParam{
+			{Name: "channel", Desc: "The channel to replay.", Optional: false, Type: bot.ArgChannel},
+			{Name: "duration", Desc: "How far back to replay, like `2 hours`.", Optional: false, Type: bot.ArgDuration},
+			{Name: "settings", Desc: "Spam settings to try out, like `maxpressure=80 pressuredecay=3`. `maxchannelpressure=80` sets the max pressure for the replayed channel.", Optional: true, Variadic: true},
+		},
+	}
+}
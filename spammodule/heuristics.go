@@ -0,0 +1,165 @@
+package spammodule
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	bot "../sweetiebot"
+	"github.com/blackhole12/discordgo"
+)
+
+// Messages are only compared against messages sent within this many milliseconds of them
+const spamHistoryWindow = 2 * 60 * 1000
+
+// How many recent messages from everyone on the server are kept to look for copypasta waves
+const maxRecentMessages = 100
+
+// Normalized messages shorter than this are never considered duplicates, so "lol" and "same" don't count as spam
+const minDuplicateLength = 10
+
+// Two messages are near-duplicates if this fraction of their trigrams are shared
+const duplicateThreshold = 0.7
+
+var inviteRegex = regexp.MustCompile(`(?i)(discord\.gg|discord(?:app)?\.com/invite)/[a-z0-9-]+`)
+var customEmojiRegex = regexp.MustCompile(`<a?:\w+:[0-9]+>`)
+
+type spamMessage struct {
+	author   bot.DiscordUser
+	channel  string
+	text     string
+	trigrams map[string]bool
+	time     int64 // milliseconds, same as userPressure.lastmessage
+}
+
+// normalizeSpam strips everything except letters and digits and lowercases them, so that changing the spacing,
+// punctuation, capitalization or adding zalgo marks doesn't make a message look different.
+func normalizeSpam(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+func newSpamMessage(m *discordgo.Message, time int64) *spamMessage {
+	msg := &spamMessage{
+		author:   bot.DiscordUser(m.Author.ID),
+		channel:  m.ChannelID,
+		text:     normalizeSpam(m.Content),
+		trigrams: make(map[string]bool),
+		time:     time,
+	}
+	r := []rune(msg.text)
+	for i := 0; i+3 <= len(r); i++ {
+		msg.trigrams[string(r[i:i+3])] = true
+	}
+	return msg
+}
+
+// similar returns true if the messages are near-duplicates, based on how many trigrams they have in common
+func (msg *spamMessage) similar(other *spamMessage) bool {
+	if len(msg.text) < minDuplicateLength || len(other.text) < minDuplicateLength {
+		return false
+	}
+	if msg.text == other.text {
+		return true
+	}
+	shared := 0
+	for k := range msg.trigrams {
+		if other.trigrams[k] {
+			shared++
+		}
+	}
+	total := len(msg.trigrams) + len(other.trigrams) - shared
+	return total > 0 && float32(shared)/float32(total) >= duplicateThreshold
+}
+
+// countZalgo counts combining marks that are stacked on top of another combining mark. Normal accented text only
+// ever has one mark per letter, but zalgo text piles dozens of them onto each one.
+func countZalgo(s string) int {
+	n := 0
+	mark := false
+	for _, r := range s {
+		isMark := unicode.In(r, unicode.Mn, unicode.Me)
+		if isMark && mark {
+			n++
+		}
+		mark = isMark
+	}
+	return n
+}
+
+func isEmoji(r rune) bool {
+	return (r >= 0x1F300 && r <= 0x1FAFF) || // Pictographs, emoticons, transport and supplemental symbols
+		(r >= 0x2600 && r <= 0x27BF) || // Miscellaneous symbols and dingbats
+		(r >= 0x1F1E6 && r <= 0x1F1FF) // Regional indicators used for flags
+}
+
+// countEmoji counts both unicode emojis and custom server emojis
+func countEmoji(s string) int {
+	n := len(customEmojiRegex.FindAllStringIndex(s, -1))
+	for _, r := range s {
+		if isEmoji(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// Gets the pressure generated by the content of an isolated message: zalgo text, emojis and invite links.
+func getContentPressure(config *bot.BotConfig, m *discordgo.Message) float32 {
+	p := config.Spam.ZalgoPressure * float32(countZalgo(m.Content))
+	p += config.Spam.EmojiPressure * float32(countEmoji(m.Content))
+	p += config.Spam.InvitePressure * float32(len(inviteRegex.FindAllStringIndex(m.Content, -1)))
+	return p
+}
+
+// Gets the pressure generated by a message being a near-duplicate of the user's recent messages, of the same message
+// in other channels, or of recent messages posted by other users, then adds it to the history.
+func (w *SpamModule) getContextPressure(config *bot.BotConfig, m *discordgo.Message, track *userPressure, time int64) float32 {
+	lookback := config.Spam.DuplicateLookback
+	if lookback <= 0 {
+		return 0
+	}
+	msg := newSpamMessage(m, time)
+	var p float32
+	channels := make(map[string]bool)
+	for _, h := range track.history {
+		if time-h.time < spamHistoryWindow && msg.similar(h) {
+			p += config.Spam.DuplicatePressure
+			if h.channel != msg.channel && !channels[h.channel] {
+				channels[h.channel] = true
+				p += config.Spam.CrossChannelPressure
+			}
+		}
+	}
+	track.history = append(track.history, msg)
+	if len(track.history) > lookback {
+		track.history = track.history[len(track.history)-lookback:]
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	users := make(map[bot.DiscordUser]bool)
+	recent := w.recent[:0]
+	for _, r := range w.recent {
+		if time-r.time >= spamHistoryWindow {
+			continue
+		}
+		recent = append(recent, r)
+		if r.author != msg.author && !users[r.author] && msg.similar(r) {
+			users[r.author] = true
+			p += config.Spam.CopypastaPressure
+		}
+	}
+	if len(msg.text) >= minDuplicateLength {
+		recent = append(recent, msg)
+	}
+	if len(recent) > maxRecentMessages {
+		recent = recent[len(recent)-maxRecentMessages:]
+	}
+	w.recent = recent
+	return p
+}
@@ -0,0 +1,333 @@
+package spammodule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	bot "../sweetiebot"
+	"github.com/blackhole12/discordgo"
+)
+
+// LockdownConfig holds the channels a lockdown locks or slows down. It is registered as the "Lockdown" config category.
+type LockdownConfig struct {
+	Channels         map[bot.DiscordChannel]bool `json:"channels"`
+	Slowmode         int                         `json:"slowmode"`
+	SlowmodeChannels map[bot.DiscordChannel]bool `json:"slowmodechannels"`
+}
+
+func init() {
+	bot.RegisterConfig("Lockdown", func() interface{} { return &LockdownConfig{} }, map[string]string{
+		"channels":         "A list of channels that @everyone is denied permission to send messages in during a lockdown. When the lockdown ends, the previous permissions of these channels are restored exactly.",
+		"slowmode":         "If greater than 0, every channel in Lockdown.SlowmodeChannels is put in slowmode for this many seconds during a lockdown. Their previous slowmode is restored when the lockdown ends.",
+		"slowmodechannels": "A list of channels that are put in slowmode during a lockdown, for channels that should stay open but slowed down. Has no effect unless Lockdown.Slowmode is set.",
+	})
+}
+
+// Lockdown returns the lockdown channels of the server
+func Lockdown(info *bot.GuildInfo) *LockdownConfig {
+	return info.Config.Section("Lockdown").(*LockdownConfig)
+}
+
+// Lockdown states. The state is loaded from the database the first time it's needed, so a lockdown that was engaged
+// before the bot restarted still ends on time.
+const (
+	lockdownUnknown = iota
+	lockdownOff
+	lockdownOn
+)
+
+// modChannel returns the channel lockdown and raid alerts are sent to
+func modChannel(info *bot.GuildInfo) bot.DiscordChannel {
+	if info.Bot.Debug {
+		ch, _ := info.Bot.DebugChannels[bot.DiscordGuild(info.ID)]
+		return ch
+	}
+	return info.Config.Basic.ModChannel
+}
+
+// lockdownChannel returns a copy of a channel on this guild from the state, or nil if it doesn't exist
+func lockdownChannel(info *bot.GuildInfo, ch bot.DiscordChannel) *discordgo.Channel {
+	channel, err := info.DG.State.Channel(ch.String())
+	if err != nil || channel.GuildID != info.ID {
+		return nil
+	}
+	info.DG.State.RLock()
+	defer info.DG.State.RUnlock()
+	c := *channel
+	c.PermissionOverwrites = append([]*discordgo.PermissionOverwrite{}, channel.PermissionOverwrites...)
+	return &c
+}
+
+// loadLockdown finds out if a lockdown was already engaged, which can happen if the bot restarted during one. The
+// caller must hold lockdownlock.
+func (w *SpamModule) loadLockdown(info *bot.GuildInfo) {
+	if w.lockdown != lockdownUnknown || !info.Bot.DB.CheckStatus() {
+		return
+	}
+	w.lockdown = lockdownOff
+	if states := info.Bot.DB.GetLockdown(bot.SBatoi(info.ID)); len(states) > 0 {
+		w.lockdown = lockdownOn
+		w.lockdownends = states[0].Ends
+	}
+}
+
+// lockdownEnds returns whether a lockdown is engaged and when it will end, which is nil if it has to be disabled
+// manually
+func (w *SpamModule) lockdownEnds(info *bot.GuildInfo) (bool, *time.Time) {
+	w.lockdownlock.Lock()
+	defer w.lockdownlock.Unlock()
+	w.loadLockdown(info)
+	return w.lockdown == lockdownOn, w.lockdownends
+}
+
+// EngageLockdown raises the server verification level, denies @everyone permission to send messages in
+// lockdown.channels and puts lockdown.slowmodechannels in slowmode until the given time, or until it's manually
+// disabled if ends is nil. Everything is saved to the database before it's changed, so it can be restored even if the
+// bot restarts. If a lockdown is already engaged, this only changes when it ends. Returns true if a new lockdown was
+// engaged, along with everything that couldn't be locked down.
+func (w *SpamModule) EngageLockdown(info *bot.GuildInfo, ends *time.Time) (bool, []string) {
+	if !info.Bot.DB.CheckStatus() {
+		return false, []string{"A database error prevents me from saving the lockdown, so it was not engaged."}
+	}
+	w.lockdownlock.Lock()
+	defer w.lockdownlock.Unlock()
+	w.loadLockdown(info)
+	guild := bot.SBatoi(info.ID)
+	if w.lockdown == lockdownOn {
+		info.Bot.DB.SetLockdownEnd(guild, ends)
+		w.lockdownends = ends
+		return false, nil
+	}
+
+	problems := []string{}
+	engaged := false
+	save := func(state bot.LockdownState) bool {
+		state.Ends = ends
+		if info.Bot.DB.AddLockdown(guild, state) != nil {
+			problems = append(problems, "A database error prevented me from saving the lockdown.")
+			return false
+		}
+		return true
+	}
+
+	level := discordgo.VerificationLevelHigh
+	previous := level
+	if g, err := info.GetGuild(); err == nil {
+		previous = g.VerificationLevel
+	}
+	if save(bot.LockdownState{Type: bot.LockdownVerification, Value: int(previous)}) {
+		if _, err := info.DG.GuildEdit(info.ID, discordgo.GuildParams{"", "", &level, 0, "", 0, "", "", ""}); err != nil {
+			info.Bot.DB.RemoveLockdown(guild, bot.LockdownVerification, 0)
+			problems = append(problems, "Could not raise the verification level! Make sure you've given "+info.GetBotName()+" the Manage Server permission.")
+		} else {
+			engaged = true
+		}
+	}
+
+	config := Lockdown(info)
+	for ch := range config.Channels {
+		channel := lockdownChannel(info, ch)
+		if channel == nil {
+			continue
+		}
+		state := bot.LockdownState{Type: bot.LockdownPermissions, Channel: ch.Convert()}
+		for _, v := range channel.PermissionOverwrites {
+			if strings.ToLower(v.Type) == "role" && v.ID == info.ID { // The @everyone role has the same ID as the guild
+				state.Overwrite = true
+				state.Allow = v.Allow
+				state.Deny = v.Deny
+				break
+			}
+		}
+		if !save(state) {
+			continue
+		}
+		allow := state.Allow &^ discordgo.PermissionSendMessages
+		deny := state.Deny | discordgo.PermissionSendMessages
+		if err := info.ChannelPermissionSet(channel, info.ID, "role", allow, deny); err != nil {
+			info.Bot.DB.RemoveLockdown(guild, bot.LockdownPermissions, state.Channel)
+			problems = append(problems, "Could not lock "+ch.Display()+"! Make sure "+info.GetBotName()+" has the Manage Roles permission on it.")
+		} else {
+			engaged = true
+		}
+	}
+
+	if config.Slowmode > 0 {
+		for ch := range config.SlowmodeChannels {
+			if lockdownChannel(info, ch) == nil {
+				continue
+			}
+			problem := "Could not put " + ch.Display() + " in slowmode! Make sure " + info.GetBotName() + " has the Manage Channels permission on it."
+			slowmode, err := info.DG.ChannelSlowmode(ch.String())
+			if err != nil {
+				problems = append(problems, problem)
+				continue
+			}
+			if !save(bot.LockdownState{Type: bot.LockdownSlowmode, Channel: ch.Convert(), Value: slowmode}) {
+				continue
+			}
+			if err = info.DG.SetChannelSlowmode(ch.String(), config.Slowmode); err != nil {
+				info.Bot.DB.RemoveLockdown(guild, bot.LockdownSlowmode, ch.Convert())
+				problems = append(problems, problem)
+			} else {
+				engaged = true
+			}
+		}
+	}
+
+	if engaged {
+		w.lockdown = lockdownOn
+		w.lockdownends = ends
+	}
+	return engaged, problems
+}
+
+// restoreLockdown undoes one part of a lockdown. Returns a description of the problem if it couldn't be restored.
+func restoreLockdown(info *bot.GuildInfo, state bot.LockdownState) string {
+	switch state.Type {
+	case bot.LockdownVerification:
+		guild, err := info.GetGuild()
+		if err != nil {
+			return "Guild cannot be found in state?!"
+		}
+		if guild.VerificationLevel != discordgo.VerificationLevelHigh {
+			return fmt.Sprintf("The verification level is at %v instead of %v, which means it was manually changed by someone other than "+info.GetBotName()+", so it has not been restored.", guild.VerificationLevel, discordgo.VerificationLevelHigh)
+		}
+		level := discordgo.VerificationLevel(state.Value)
+		if _, err = info.DG.GuildEdit(info.ID, discordgo.GuildParams{"", "", &level, 0, "", 0, "", "", ""}); err != nil {
+			return "Could not restore the verification level! Make sure you've given the " + info.Bot.AppName + " role the Manage Server permission, you'll have to manually restore it yourself this time."
+		}
+	case bot.LockdownPermissions:
+		ch := bot.NewDiscordChannel(state.Channel)
+		channel := lockdownChannel(info, ch)
+		if channel == nil {
+			return "" // The channel was deleted, so there's nothing left to restore
+		}
+		var err error
+		if state.Overwrite {
+			err = info.ChannelPermissionSet(channel, info.ID, "role", state.Allow, state.Deny)
+		} else {
+			err = info.ChannelPermissionDelete(channel, info.ID)
+		}
+		if err != nil {
+			return "Could not restore the permissions of " + ch.Display() + "! You'll have to manually allow @everyone to send messages there again."
+		}
+	case bot.LockdownSlowmode:
+		ch := bot.NewDiscordChannel(state.Channel)
+		if lockdownChannel(info, ch) == nil {
+			return ""
+		}
+		if err := info.DG.SetChannelSlowmode(ch.String(), state.Value); err != nil {
+			return fmt.Sprintf("Could not restore the slowmode of %s to %v seconds!", ch.Display(), state.Value)
+		}
+	}
+	return ""
+}
+
+// disableLockdown restores everything the current lockdown changed. Returns false if there was no lockdown.
+func (w *SpamModule) disableLockdown(info *bot.GuildInfo) (bool, []string) {
+	w.lockdownlock.Lock()
+	defer w.lockdownlock.Unlock()
+	w.loadLockdown(info)
+	if w.lockdown != lockdownOn {
+		return false, nil
+	}
+	if !info.Bot.DB.CheckStatus() {
+		return false, []string{"A database error prevents me from finding out what the lockdown changed, so it has not been disengaged."}
+	}
+	guild := bot.SBatoi(info.ID)
+	problems := []string{}
+	for _, state := range info.Bot.DB.GetLockdown(guild) {
+		if s := restoreLockdown(info, state); len(s) > 0 {
+			problems = append(problems, s)
+		}
+		info.Bot.DB.RemoveLockdown(guild, state.Type, state.Channel)
+	}
+	w.lockdown = lockdownOff
+	w.lockdownends = nil
+	return true, problems
+}
+
+// DisableLockdown disables the guild lockdown, if there is one, and tells the mod channel
+func (w *SpamModule) DisableLockdown(info *bot.GuildInfo) {
+	if disabled, problems := w.disableLockdown(info); disabled || len(problems) > 0 {
+		info.SendMessage(modChannel(info), lockdownDisengagedMessage(disabled, problems))
+	}
+}
+
+func lockdownDisengagedMessage(disabled bool, problems []string) string {
+	if !disabled {
+		return strings.Join(problems, "\n")
+	}
+	if len(problems) > 0 {
+		return "Lockdown disengaged, but some things could not be restored:\n" + strings.Join(problems, "\n")
+	}
+	return "Lockdown disengaged, server verification level and channel permissions restored."
+}
+
+type lockdownCommand struct {
+	s *SpamModule
+}
+
+func (c *lockdownCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "Lockdown",
+		Usage:     "Engages or disengages a lockdown.",
+		Sensitive: true,
+	}
+}
+
+func (c *lockdownCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	switch args.String("action") {
+	case "on":
+		var ends *time.Time
+		if args.Has("duration") {
+			t := args.Duration("duration").After(bot.GetTimestamp(msg)).UTC()
+			ends = &t
+		}
+		engaged, problems := c.s.EngageLockdown(info, ends)
+		var s string
+		switch {
+		case engaged:
+			s = "Lockdown engaged!"
+		case len(problems) == 0:
+			s = "A lockdown was already engaged."
+		default:
+			return "```\nCould not engage lockdown!\n" + strings.Join(problems, "\n") + "```", false, nil
+		}
+		if ends != nil {
+			s += " It will end " + info.ApplyTimezone(*ends, bot.DiscordUser(msg.Author.ID)).Format(time.RFC1123) + "."
+		} else {
+			s += " It will last until someone uses " + info.Config.Basic.CommandPrefix + "lockdown off."
+		}
+		if len(problems) > 0 {
+			s += "\n" + strings.Join(problems, "\n")
+		}
+		return "```\n" + s + "```", false, nil
+	case "off":
+		disabled, problems := c.s.disableLockdown(info)
+		if !disabled && len(problems) == 0 {
+			return "```\nThere is no lockdown to disengage.```", false, nil
+		}
+		return "```\n" + lockdownDisengagedMessage(disabled, problems) + "```", false, nil
+	}
+	engaged, ends := c.s.lockdownEnds(info)
+	if !engaged {
+		return "```\nThere is no lockdown engaged.```", false, nil
+	}
+	if ends == nil {
+		return "```\nA lockdown is engaged until someone uses " + info.Config.Basic.CommandPrefix + "lockdown off.```", false, nil
+	}
+	return "```\nA lockdown is engaged until " + info.ApplyTimezone(*ends, bot.DiscordUser(msg.Author.ID)).Format(time.RFC1123) + ".```", false, nil
+}
+
+func (c *lockdownCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Engages a lockdown, which raises the server verification level to the highest level, denies @everyone permission to send messages in `lockdown.channels`, and puts `lockdown.slowmodechannels` in slowmode for `lockdown.slowmode` seconds. Moderators need their own permission overwrite or the administrator permission to keep talking in locked channels. When the lockdown ends, everything is restored to exactly how it was before, even if " + info.GetBotName() + " restarted in the meantime. Without an action, tells you if a lockdown is engaged.",
+		Params: []bot.CommandUsageParam{
+			{Name: "action", Desc: "`on` engages a lockdown, and `off` disengages it.", Optional: true, Type: bot.ArgEnum, Values: []string{"on", "off"}},
+			{Name: "duration", Desc: "If the keyword `for:` is used after `on`, looks for a duration of the form `for: 30 MINUTES` after which the lockdown ends on its own. Otherwise, it lasts until someone uses `" + info.Config.Basic.CommandPrefix + "lockdown off`. Using this during a lockdown changes when it ends.", Optional: true, Type: bot.ArgDuration, Keyword: "for:"},
+		},
+	}
+}
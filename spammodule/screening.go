@@ -0,0 +1,251 @@
+package spammodule
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	bot "../sweetiebot"
+	"github.com/blackhole12/discordgo"
+)
+
+// ScreeningConfig holds the join screening rules of a server. It is registered as the "Screening" config category.
+type ScreeningConfig struct {
+	MinAccountAge       int64            `json:"minaccountage"`
+	AccountAgeAction    bot.ScreenAction `json:"accountageaction"`
+	DefaultAvatarAction bot.ScreenAction `json:"defaultavataraction"`
+	NameBlocklist       map[string]bool  `json:"nameblocklist"`
+	NameBlocklistAction bot.ScreenAction `json:"nameblocklistaction"`
+	NameClusterSize     int              `json:"nameclustersize"`
+	NameClusterAction   bot.ScreenAction `json:"nameclusteraction"`
+}
+
+func init() {
+	bot.RegisterConfig("Screening", func() interface{} {
+		return &ScreeningConfig{MinAccountAge: 86400, NameClusterSize: 3}
+	}, map[string]string{
+		"minaccountage":       "New members whose accounts were created less than this many seconds ago fail join screening. Defaults to 86400 (1 day). Use Screening.AccountAgeAction to enable this rule.",
+		"accountageaction":    "What to do to new members with accounts younger than Screening.MinAccountAge: alert, silence, kick, ban, or none to disable the rule. Everyone flagged by join screening is listed in a digest posted to the mod channel.",
+		"defaultavataraction": "What to do to new members that haven't set an avatar: alert, silence, kick, ban, or none to disable the rule.",
+		"nameblocklist":       "A list of regular expressions that new members' usernames are checked against, ignoring case. Use Screening.NameBlocklistAction to enable this rule.",
+		"nameblocklistaction": "What to do to new members whose usernames match Screening.NameBlocklist: alert, silence, kick, ban, or none to disable the rule.",
+		"nameclustersize":     "If at least this many members with nearly the same username (ignoring numbers, symbols and small differences) join within Spam.RaidTime seconds of each other, they all fail join screening. Defaults to 3. Use Screening.NameClusterAction to enable this rule.",
+		"nameclusteraction":   "What to do to groups of new members with similar usernames: alert, silence, kick, ban, or none to disable the rule.",
+	})
+}
+
+// Screening returns the join screening rules of the server
+func Screening(info *bot.GuildInfo) *ScreeningConfig {
+	return info.Config.Section("Screening").(*ScreeningConfig)
+}
+
+// The most join screening results listed in a single digest
+const maxScreenDigest = 30
+
+type screenJoin struct {
+	user    *discordgo.User
+	name    string // The skeleton of their username, see nameSkeleton
+	time    time.Time
+	flagged bool // Whether they've already been flagged as part of a cluster
+}
+
+type screenResult struct {
+	user    *discordgo.User
+	reasons []string
+	action  bot.ScreenAction // The harshest action taken against them so far
+}
+
+// nameSkeleton keeps only the letters of a username, lowercased, so that names like "Raider123" and "raider_456" match
+func nameSkeleton(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+func levenshtein(a []rune, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// similarNames returns true if two name skeletons are the same or only a few letters apart
+func similarNames(a string, b string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	if a == b {
+		return true
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	return longest >= 4 && levenshtein(ra, rb) <= longest/4
+}
+
+// matchNameBlocklist returns the first pattern in the blocklist that matches the username, ignoring invalid patterns
+func matchNameBlocklist(blocklist map[string]bool, name string) string {
+	for k := range blocklist {
+		r, err := regexp.Compile("(?i)" + k)
+		if err == nil && r.MatchString(name) {
+			return k
+		}
+	}
+	return ""
+}
+
+// addJoin remembers a new member's name. If at least size members with similar names, including them, joined within
+// the window, it returns the ones that haven't been flagged for it yet and marks them as flagged.
+func (w *SpamModule) addJoin(u *discordgo.User, t time.Time, window time.Duration, size int) []*discordgo.User {
+	w.Lock()
+	defer w.Unlock()
+	join := &screenJoin{u, nameSkeleton(u.Username), t, false}
+	joins := w.joins[:0]
+	cluster := []*screenJoin{join}
+	for _, v := range w.joins {
+		if t.Sub(v.time) > window {
+			continue
+		}
+		joins = append(joins, v)
+		if v.user.ID != u.ID && similarNames(v.name, join.name) {
+			cluster = append(cluster, v)
+		}
+	}
+	w.joins = append(joins, join)
+	if len(cluster) < size {
+		return nil
+	}
+	users := []*discordgo.User{}
+	for _, v := range cluster {
+		if !v.flagged {
+			v.flagged = true
+			users = append(users, v.user)
+		}
+	}
+	return users
+}
+
+// screenMember checks a new member against all the enabled join screening rules
+func (w *SpamModule) screenMember(info *bot.GuildInfo, u *discordgo.User, t time.Time) {
+	config := Screening(info)
+	if config.AccountAgeAction != bot.ScreenNone && config.MinAccountAge > 0 {
+		age := t.Sub(bot.SnowflakeTime(bot.SBatoi(u.ID)))
+		if age < time.Duration(config.MinAccountAge)*time.Second {
+			w.screen(info, u, config.AccountAgeAction, "account created "+bot.TimeDiff(age)+" ago")
+		}
+	}
+	if config.DefaultAvatarAction != bot.ScreenNone && len(u.Avatar) == 0 {
+		w.screen(info, u, config.DefaultAvatarAction, "default avatar")
+	}
+	if config.NameBlocklistAction != bot.ScreenNone {
+		if pattern := matchNameBlocklist(config.NameBlocklist, u.Username); len(pattern) > 0 {
+			w.screen(info, u, config.NameBlocklistAction, "username matches "+pattern)
+		}
+	}
+	if config.NameClusterAction != bot.ScreenNone && config.NameClusterSize > 1 {
+		for _, v := range w.addJoin(u, t, time.Duration(info.Config.Spam.RaidTime)*time.Second, config.NameClusterSize) {
+			w.screen(info, v, config.NameClusterAction, "similar name to other new members")
+		}
+	}
+}
+
+// screen records that a member failed a screening rule for the next digest, and punishes them unless they've already
+// received an equal or harsher punishment.
+func (w *SpamModule) screen(info *bot.GuildInfo, u *discordgo.User, action bot.ScreenAction, reason string) {
+	w.Lock()
+	r, ok := w.screened[u.ID]
+	if !ok {
+		r = &screenResult{user: u}
+		w.screened[u.ID] = r
+		w.screenorder = append(w.screenorder, u.ID)
+	}
+	for _, v := range r.reasons {
+		if v == reason {
+			w.Unlock()
+			return
+		}
+	}
+	r.reasons = append(r.reasons, reason)
+	harsher := action.Harsher(r.action)
+	if harsher {
+		r.action = action
+	}
+	w.Unlock()
+	if !harsher {
+		return
+	}
+
+	user := bot.DiscordUser(u.ID)
+	caseReason := "Failed join screening: " + reason + "."
+	logger := info.Logger().With(bot.LogFields{"user": u.ID})
+	switch action {
+	case bot.ScreenSilence:
+		if code, timedOut, _ := silenceMember(u, info); code == 0 {
+			spamCase(u, info, "failing join screening ("+reason+")", timedOut)
+		}
+	case bot.ScreenKick:
+		if err := info.DG.GuildMemberDelete(info.ID, u.ID); err != nil {
+			logger.LogError("Error kicking member for join screening: ", err)
+			return
+		}
+		info.AddCase(bot.CaseKick, user, info.Bot.SelfID, caseReason, 0, nil)
+	case bot.ScreenBan:
+		if err := info.DG.GuildBanCreateWithReason(info.ID, u.ID, caseReason, 1); err != nil {
+			logger.LogError("Error banning member for join screening: ", err)
+			return
+		}
+		info.AddCase(bot.CaseBan, user, info.Bot.SelfID, caseReason, 0, nil)
+	}
+}
+
+// sendScreenDigest posts everyone flagged by join screening since the last digest to the mod channel, so a raid
+// produces one message instead of one alert per member.
+func (w *SpamModule) sendScreenDigest(info *bot.GuildInfo) {
+	w.Lock()
+	if len(w.screenorder) == 0 {
+		w.Unlock()
+		return
+	}
+	order := w.screenorder
+	screened := w.screened
+	w.screenorder = nil
+	w.screened = make(map[string]*screenResult)
+	w.Unlock()
+
+	s := make([]string, 0, len(order)+1)
+	for i, id := range order {
+		if i >= maxScreenDigest {
+			s = append(s, fmt.Sprintf("...and %v more.", len(order)-i))
+			break
+		}
+		r := screened[id]
+		s = append(s, fmt.Sprintf("%s#%s [%s]: %s", r.user.Username, r.user.Discriminator, r.action, strings.Join(r.reasons, ", ")))
+	}
+	info.SendMessage(info.Config.Basic.ModChannel, fmt.Sprintf("Join screening flagged %v new members:\n```\n%s```", len(order), info.Sanitize(strings.Join(s, "\n"), bot.CleanCodeBlock)))
+}
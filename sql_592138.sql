@@ -0,0 +1,36 @@
+DELIMITER //
+
+CREATE TABLE IF NOT EXISTS `cases` (
+  `ID` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `Guild` bigint(20) unsigned NOT NULL,
+  `Number` bigint(20) unsigned NOT NULL,
+  `User` bigint(20) unsigned NOT NULL,
+  `Moderator` bigint(20) unsigned NOT NULL,
+  `Type` tinyint(3) unsigned NOT NULL,
+  `Reason` varchar(1000) NOT NULL DEFAULT '',
+  `Duration` int(10) unsigned DEFAULT NULL,
+  `Schedule` bigint(20) unsigned DEFAULT NULL,
+  `Timestamp` datetime NOT NULL,
+  `Pardoned` tinyint(1) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`ID`),
+  UNIQUE KEY `INDEX_GUILD_NUMBER` (`Guild`,`Number`),
+  KEY `INDEX_GUILD_USER` (`Guild`,`User`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Numbered moderation actions taken against users.'//
+
+DROP PROCEDURE IF EXISTS `RemoveGuild`//
+CREATE PROCEDURE `RemoveGuild`(
+	IN `_guild` BIGINT UNSIGNED
+)
+    MODIFIES SQL DATA
+BEGIN
+
+DELETE FROM `members` WHERE Guild = _guild;
+DELETE FROM `polls` WHERE Guild = _guild;
+DELETE FROM `schedule` WHERE Guild = _guild;
+DELETE FROM `chatlog` WHERE Guild = _guild;
+DELETE FROM `debuglog` WHERE Guild = _guild;
+DELETE FROM `editlog` WHERE Guild = _guild;
+DELETE FROM `tags` WHERE Guild = _guild;
+DELETE FROM `cases` WHERE Guild = _guild;
+
+END//
@@ -0,0 +1,32 @@
+DELIMITER //
+
+CREATE TABLE IF NOT EXISTS `lockdown` (
+  `Guild` bigint(20) unsigned NOT NULL,
+  `Type` tinyint(3) unsigned NOT NULL,
+  `Channel` bigint(20) unsigned NOT NULL,
+  `Value` int(11) NOT NULL DEFAULT 0,
+  `Overwrite` tinyint(1) NOT NULL DEFAULT 0,
+  `Allow` bigint(20) NOT NULL DEFAULT 0,
+  `Deny` bigint(20) NOT NULL DEFAULT 0,
+  `Ends` datetime DEFAULT NULL,
+  PRIMARY KEY (`Guild`,`Type`,`Channel`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Everything a lockdown changed, along with what it was before, so it can be undone.'//
+
+DROP PROCEDURE IF EXISTS `RemoveGuild`//
+CREATE PROCEDURE `RemoveGuild`(
+	IN `_guild` BIGINT UNSIGNED
+)
+    MODIFIES SQL DATA
+BEGIN
+
+DELETE FROM `members` WHERE Guild = _guild;
+DELETE FROM `polls` WHERE Guild = _guild;
+DELETE FROM `schedule` WHERE Guild = _guild;
+DELETE FROM `chatlog` WHERE Guild = _guild;
+DELETE FROM `debuglog` WHERE Guild = _guild;
+DELETE FROM `editlog` WHERE Guild = _guild;
+DELETE FROM `tags` WHERE Guild = _guild;
+DELETE FROM `cases` WHERE Guild = _guild;
+DELETE FROM `lockdown` WHERE Guild = _guild;
+
+END//
@@ -0,0 +1,35 @@
+DELIMITER //
+
+CREATE TABLE IF NOT EXISTS `filterhits` (
+  `ID` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `Guild` bigint(20) unsigned NOT NULL,
+  `Filter` varchar(128) NOT NULL,
+  `Word` varchar(255) NOT NULL DEFAULT '',
+  `User` bigint(20) unsigned NOT NULL,
+  `CaseNumber` bigint(20) unsigned DEFAULT NULL,
+  `FalsePositive` tinyint(1) NOT NULL DEFAULT 0,
+  `Timestamp` datetime NOT NULL,
+  PRIMARY KEY (`ID`),
+  KEY `INDEX_GUILD_TIMESTAMP` (`Guild`,`Timestamp`),
+  KEY `INDEX_GUILD_CASE` (`Guild`,`CaseNumber`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Every time a word in a filter matched a message, and whether a moderator said it was a false positive.'//
+
+DROP PROCEDURE IF EXISTS `RemoveGuild`//
+CREATE PROCEDURE `RemoveGuild`(
+	IN `_guild` BIGINT UNSIGNED
+)
+    MODIFIES SQL DATA
+BEGIN
+
+DELETE FROM `members` WHERE Guild = _guild;
+DELETE FROM `polls` WHERE Guild = _guild;
+DELETE FROM `schedule` WHERE Guild = _guild;
+DELETE FROM `chatlog` WHERE Guild = _guild;
+DELETE FROM `debuglog` WHERE Guild = _guild;
+DELETE FROM `editlog` WHERE Guild = _guild;
+DELETE FROM `tags` WHERE Guild = _guild;
+DELETE FROM `cases` WHERE Guild = _guild;
+DELETE FROM `lockdown` WHERE Guild = _guild;
+DELETE FROM `filterhits` WHERE Guild = _guild;
+
+END//
@@ -0,0 +1,5 @@
+DELIMITER //
+
+ALTER TABLE `schedule`
+	ADD COLUMN `Channel` BIGINT(20) UNSIGNED NULL DEFAULT NULL AFTER `Data`,
+	ADD COLUMN `Recurrence` VARCHAR(1024) NULL DEFAULT NULL AFTER `Channel`//
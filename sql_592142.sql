@@ -0,0 +1,34 @@
+DELIMITER //
+
+ALTER TABLE `schedule`
+	ADD COLUMN `RSVPMessage` BIGINT(20) UNSIGNED NULL DEFAULT NULL AFTER `Recurrence`//
+
+CREATE TABLE IF NOT EXISTS `rsvps` (
+  `Event` bigint(20) unsigned NOT NULL,
+  `User` bigint(20) unsigned NOT NULL,
+  `Guild` bigint(20) unsigned NOT NULL,
+  `Reminded` datetime NOT NULL,
+  PRIMARY KEY (`Event`,`User`),
+  KEY `INDEX_GUILD` (`Guild`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Who is going to each event in the schedule, and when they were last reminded about it.'//
+
+DROP PROCEDURE IF EXISTS `RemoveGuild`//
+CREATE PROCEDURE `RemoveGuild`(
+	IN `_guild` BIGINT UNSIGNED
+)
+    MODIFIES SQL DATA
+BEGIN
+
+DELETE FROM `members` WHERE Guild = _guild;
+DELETE FROM `polls` WHERE Guild = _guild;
+DELETE FROM `schedule` WHERE Guild = _guild;
+DELETE FROM `chatlog` WHERE Guild = _guild;
+DELETE FROM `debuglog` WHERE Guild = _guild;
+DELETE FROM `editlog` WHERE Guild = _guild;
+DELETE FROM `tags` WHERE Guild = _guild;
+DELETE FROM `cases` WHERE Guild = _guild;
+DELETE FROM `lockdown` WHERE Guild = _guild;
+DELETE FROM `filterhits` WHERE Guild = _guild;
+DELETE FROM `rsvps` WHERE Guild = _guild;
+
+END//
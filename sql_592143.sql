@@ -0,0 +1,7 @@
+DELIMITER //
+
+ALTER TABLE `schedule`
+	ADD COLUMN `State` TINYINT(3) UNSIGNED NOT NULL DEFAULT 0 AFTER `RSVPMessage`,
+	ADD COLUMN `Attempts` INT(10) UNSIGNED NOT NULL DEFAULT 0 AFTER `State`,
+	ADD COLUMN `RetryAt` DATETIME NULL DEFAULT NULL AFTER `Attempts`,
+	ADD COLUMN `LastError` VARCHAR(1024) NULL DEFAULT NULL AFTER `RetryAt`//
@@ -2,10 +2,12 @@ package main
 
 import (
 	"os"
+	"strings"
 
 	"../boredmodule"
 	"../bucketmodule"
 	"../filtermodule"
+	"../linkmodule"
 	"../markovmodule"
 	"../miscmodule"
 	"../pollmodule"
@@ -21,7 +23,7 @@ import (
 )
 
 func loader(guild *sweetiebot.GuildInfo) []sweetiebot.Module {
-	modules := make([]sweetiebot.Module, 0, 18)
+	modules := make([]sweetiebot.Module, 0, 19)
 	modules = append(modules, &sweetiebot.InfoModule{})
 	modules = append(modules, &sweetiebot.ConfigModule{})
 	modules = append(modules, &sweetiebot.DebugModule{})
@@ -39,10 +41,14 @@ func loader(guild *sweetiebot.GuildInfo) []sweetiebot.Module {
 	modules = append(modules, wittymodule.New(guild))
 	modules = append(modules, spammodule.New())
 	modules = append(modules, filtermodule.New(guild))
+	modules = append(modules, linkmodule.New())
 
 	return modules
 }
 func mainCode() int {
+	if len(os.Args) > 1 && strings.ToLower(os.Args[1]) == "migrations" {
+		return migrateTool(os.Args[2:])
+	}
 	bot := sweetiebot.New("", loader)
 	if bot != nil {
 		return bot.Connect()
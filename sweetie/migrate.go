@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"../sweetiebot"
+)
+
+const migrateUsage = `Usage: sweetie migrations <command>
+  list                      Lists every config migration
+  dryrun <guild ID>         Shows what migrating <guild ID>.json to the current config version would change
+  backups <guild ID>        Lists the pre-migration backups of <guild ID>.json
+  rollback <guild ID> [v]   Restores <guild ID>.json from the backup taken before it was migrated from version v,
+                            or from the newest backup. Don't do this while the bot is running.`
+
+// migrateTool lets the bot owner inspect config migrations before starting a new version of the bot, and undo them
+func migrateTool(args []string) int {
+	if len(args) < 1 || (strings.ToLower(args[0]) != "list" && len(args) < 2) {
+		fmt.Println(migrateUsage)
+		return 1
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "list":
+		for _, m := range sweetiebot.Migrations {
+			fmt.Printf("%3v: %s\n", m.Version, m.Description)
+		}
+	case "dryrun":
+		applied, diff, err := sweetiebot.DryRunMigration(args[1])
+		if err != nil {
+			fmt.Println("Error: ", err.Error())
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Printf("%s.json is already at config version %v\n", args[1], sweetiebot.ConfigVersion)
+			return 0
+		}
+		fmt.Printf("Migrating %s.json would run:\n", args[1])
+		for _, m := range applied {
+			fmt.Printf("%3v: %s\n", m.Version, m.Description)
+		}
+		fmt.Println("\nChanges to the config file (changes to discord or the database are not shown):")
+		fmt.Println(diff)
+	case "backups":
+		backups := sweetiebot.ConfigBackups(args[1])
+		if len(backups) == 0 {
+			fmt.Printf("%s.json has never been migrated\n", args[1])
+		}
+		for _, v := range backups {
+			fmt.Printf("Config version %v\n", v)
+		}
+	case "rollback":
+		version := -1
+		if len(args) > 2 {
+			v, err := strconv.Atoi(args[2])
+			if err != nil {
+				fmt.Println(args[2], "is not a config version")
+				return 1
+			}
+			version = v
+		}
+		version, err := sweetiebot.RollbackConfig(args[1], version)
+		if err != nil {
+			fmt.Println("Error: ", err.Error())
+			return 1
+		}
+		fmt.Printf("Restored %s.json to how it was at config version %v\n", args[1], version)
+	default:
+		fmt.Println(migrateUsage)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,1365 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"../fakediscord"
+	"../linkmodule"
+	"../spammodule"
+	"../sweetiebot"
+	"github.com/blackhole12/discordgo"
+)
+
+// These tests run the real bot, with every module the loader provides, against a fake discord server. The bot
+// reads selfhost.json and writes guild configs to the working directory, so the tests run inside a scratch directory.
+const selfhostConfig = `{"token": "fake", "dbdriver": "sqlite", "dbauth": ":memory:", "webport": ""}`
+
+// webdir is the root of the repository, which holds the web templates
+var webdir string
+
+func TestMain(m *testing.M) {
+	if wd, err := os.Getwd(); err == nil {
+		webdir = filepath.Dir(wd)
+	}
+	dir, err := ioutil.TempDir("", "sweetie")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	os.Chdir(dir)
+	ioutil.WriteFile("selfhost.json", []byte(selfhostConfig), 0644)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testGuild is a bot connected to a fake discord with a single guild that has already been through !setup
+type testGuild struct {
+	*fakediscord.Server
+	t       *testing.T
+	Sweetie *sweetiebot.SweetieBot
+	Guild   *discordgo.Guild
+	General *discordgo.Channel
+	Mods    *discordgo.Channel
+	Log     *discordgo.Channel
+	ModRole *discordgo.Role
+	done    chan int
+}
+
+func startBot(t *testing.T) *testGuild {
+	s := fakediscord.New()
+	g := &testGuild{Server: s, t: t, done: make(chan int, 1)}
+	g.Guild = s.AddGuild("Test Server")
+	g.General = s.AddChannel(g.Guild.ID, "general")
+	g.Mods = s.AddChannel(g.Guild.ID, "mods")
+	g.Log = s.AddChannel(g.Guild.ID, "log")
+	g.ModRole = s.AddRole(g.Guild.ID, "Mods", discordgo.PermissionBanMembers|discordgo.PermissionManageMessages)
+
+	g.Sweetie = sweetiebot.New("", loader)
+	if g.Sweetie == nil {
+		s.Close()
+		t.Fatal("Failed to create bot")
+	}
+	for _, shard := range g.Sweetie.Shards {
+		shard.Client = s.Client()
+	}
+	go func() { g.done <- g.Sweetie.Connect() }()
+
+	if !s.WaitFor(func() bool { return g.Info() != nil }) {
+		g.Stop()
+		t.Fatal("Bot never attached to the guild")
+	}
+	g.Command(s.Owner, g.Mods, "setup <@&"+g.ModRole.ID+"> <#"+g.Mods.ID+"> <#"+g.Log.ID+">", "Server configured!")
+	return g
+}
+
+// Stop disconnects the bot and shuts down the fake server
+func (g *testGuild) Stop() {
+	g.Sweetie.Stop()
+	select {
+	case <-g.done:
+	case <-time.After(fakediscord.DefaultTimeout):
+		g.t.Error("Bot did not disconnect")
+	}
+	g.Close()
+}
+
+// Info returns the bot's state for the test guild, or nil if the bot hasn't seen it yet
+func (g *testGuild) Info() *sweetiebot.GuildInfo {
+	g.Sweetie.GuildsLock.RLock()
+	defer g.Sweetie.GuildsLock.RUnlock()
+	return g.Sweetie.Guilds[sweetiebot.DiscordGuild(g.Guild.ID)]
+}
+
+// Command posts a command and fails the test unless the bot replies in the same channel with something containing reply
+func (g *testGuild) Command(author *discordgo.User, ch *discordgo.Channel, command string, reply string) {
+	g.PostMessage(ch.ID, author, "!"+command)
+	if g.WaitForMessage(ch.ID, reply) == nil {
+		g.t.Fatalf("Expected !%s to reply with %q, but the bot said: %v", command, reply, g.botSaid(ch))
+	}
+}
+
+// Slash runs a slash command and fails the test unless the bot responds with something containing reply
+func (g *testGuild) Slash(author *discordgo.User, ch *discordgo.Channel, command string, options map[string]string, reply string) *fakediscord.InteractionResponse {
+	i := g.Interact(ch.ID, author, command, options)
+	r := g.WaitForResponse(i, reply)
+	if r == nil {
+		g.t.Fatalf("Expected /%s to respond with %q, but the bot said: %v", command, reply, g.InteractionResponses(i))
+	}
+	return r
+}
+
+// Join creates a new user and has them join the test guild
+func (g *testGuild) Join(name string) *discordgo.User {
+	u := g.AddUser(name)
+	g.JoinUser(g.Guild.ID, u)
+	return u
+}
+
+// Silenced returns true if the user has the silence role that !setup created
+func (g *testGuild) Silenced(u *discordgo.User) bool {
+	info := g.Info()
+	info.ConfigLock.RLock()
+	role := info.Config.Basic.SilenceRole.String()
+	info.ConfigLock.RUnlock()
+	return g.HasRole(g.Guild.ID, u.ID, role)
+}
+
+func (g *testGuild) botSaid(ch *discordgo.Channel) string {
+	s := []string{}
+	for _, m := range g.BotMessages(ch.ID) {
+		s = append(s, m.Content)
+	}
+	return strings.Join(s, "\n")
+}
+
+func TestSetup(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	info := g.Info()
+	if !info.Config.SetupDone || info.Config.Basic.ModChannel.String() != g.Mods.ID || info.Config.Basic.ModRole.String() != g.ModRole.ID {
+		t.Error("Setup did not configure the guild")
+	}
+	silence := info.Config.Basic.SilenceRole.String()
+	if !g.WaitFor(func() bool {
+		o := g.PermissionOverwrite(g.General.ID, silence)
+		return o != nil && o.Deny&discordgo.PermissionSendMessages != 0
+	}) {
+		t.Error("Silence role was not denied send messages permission on #general")
+	}
+	if len(g.DirectMessages(g.Owner.ID)) == 0 {
+		t.Error("Owner was not sent the introductory PM")
+	}
+}
+
+func TestSharding(t *testing.T) {
+	ioutil.WriteFile("selfhost.json", []byte(`{"token": "fake", "dbdriver": "sqlite", "dbauth": ":memory:", "webport": "", "shardcount": 2}`), 0644)
+	defer ioutil.WriteFile("selfhost.json", []byte(selfhostConfig), 0644)
+	delay := sweetiebot.ShardConnectDelay
+	sweetiebot.ShardConnectDelay = 10 * time.Millisecond
+	defer func() { sweetiebot.ShardConnectDelay = delay }()
+
+	g := startBot(t)
+	defer g.Stop()
+	if len(g.Sweetie.Shards) != 2 {
+		t.Fatalf("Expected 2 shards, got %v", len(g.Sweetie.Shards))
+	}
+
+	// Guild IDs are snowflakes, so a guild created a millisecond later may land on the other shard
+	var other *discordgo.Guild
+	for i := 0; i < 10 && other == nil; i++ {
+		time.Sleep(2 * time.Millisecond)
+		guild := g.AddGuild("Other Server")
+		if g.Sweetie.ShardID(guild.ID) != g.Sweetie.ShardID(g.Guild.ID) {
+			other = guild
+		}
+	}
+	if other == nil {
+		t.Fatal("Could not create a guild on the second shard")
+	}
+	var info *sweetiebot.GuildInfo
+	if !g.WaitFor(func() bool {
+		g.Sweetie.GuildsLock.RLock()
+		defer g.Sweetie.GuildsLock.RUnlock()
+		info = g.Sweetie.Guilds[sweetiebot.DiscordGuild(other.ID)]
+		return info != nil
+	}) {
+		t.Fatal("Bot never attached to the guild on the second shard")
+	}
+	if info.DG != g.Sweetie.Shard(other.ID) || info.DG == g.Info().DG {
+		t.Error("Guild was not assigned to its own shard")
+	}
+
+	mods := g.AddChannel(other.ID, "mods")
+	log := g.AddChannel(other.ID, "log")
+	role := g.AddRole(other.ID, "Mods", discordgo.PermissionBanMembers)
+	g.Command(g.Owner, mods, "setup <@&"+role.ID+"> <#"+mods.ID+"> <#"+log.ID+">", "Server configured!")
+}
+
+func TestSpamRaid(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	raiders := []*discordgo.User{}
+	for i := 0; i < 4; i++ { // Spam.RaidSize defaults to 4
+		raiders = append(raiders, g.Join(fmt.Sprintf("Raider%v", i)))
+	}
+	if g.WaitForMessage(g.Mods.ID, "Possible Raid Detected!") == nil {
+		t.Fatal("Raid was not detected. Bot said: ", g.botSaid(g.Mods))
+	}
+	for _, u := range raiders {
+		if !g.WaitFor(func() bool { return g.Silenced(u) }) {
+			t.Error(u.Username, "was not silenced")
+		}
+	}
+	if !g.WaitFor(func() bool { return g.VerificationLevel(g.Guild.ID) == discordgo.VerificationLevelHigh }) {
+		t.Error("Lockdown did not raise the verification level")
+	}
+	if g.WaitForMessage(g.Mods.ID, "Lockdown engaged!") == nil {
+		t.Error("Lockdown was not announced")
+	}
+}
+
+func TestSpamSilence(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	spammer := g.Join("Spammer")
+	bystander := g.Join("Bystander")
+	msgs := []*discordgo.Message{}
+	for i := 0; i < 10; i++ {
+		msgs = append(msgs, g.PostMessage(g.General.ID, spammer, "buy cheap gems"))
+	}
+	g.PostMessage(g.General.ID, bystander, "please stop")
+
+	if !g.WaitFor(func() bool { return g.Silenced(spammer) }) {
+		t.Fatal("Spammer was not silenced")
+	}
+	if g.WaitForMessage(g.Mods.ID, "was silenced for spamming too many messages") == nil {
+		t.Error("Moderators were not alerted. Bot said: ", g.botSaid(g.Mods))
+	}
+	if !g.WaitFor(func() bool {
+		for _, m := range msgs {
+			if g.Deleted(m.ID) {
+				return true
+			}
+		}
+		return false
+	}) {
+		t.Error("None of the spam was deleted")
+	}
+	if g.Silenced(bystander) {
+		t.Error("Bystander was silenced")
+	}
+}
+
+func TestSpamTimeout(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	g.Command(g.Owner, g.Mods, "setconfig spam.timeoutduration 600", "Successfully set")
+	spammer := g.Join("Spammer")
+	for i := 0; i < 10; i++ {
+		g.PostMessage(g.General.ID, spammer, "buy cheap gems")
+	}
+	if !g.WaitFor(func() bool { return g.TimedOut(g.Guild.ID, spammer.ID) }) {
+		t.Fatal("Spammer was not timed out")
+	}
+	if g.WaitForMessage(g.Mods.ID, "was timed out for spamming too many messages") == nil {
+		t.Error("Moderators were not alerted. Bot said: ", g.botSaid(g.Mods))
+	}
+	if g.Silenced(spammer) {
+		t.Error("Spammer was given the silence role as well as a timeout")
+	}
+	if c := g.Info().Bot.DB.GetCase(sweetiebot.SBatoi(g.Guild.ID), 1); c == nil || c.Type != sweetiebot.CaseTimeout || c.Schedule == nil {
+		t.Errorf("Timeout was not recorded with its expiry event: %+v", c)
+	}
+}
+
+func TestSpamTimeoutFailure(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	g.Command(g.Owner, g.Mods, "setconfig spam.timeoutduration 600", "Successfully set")
+	spammer := g.Join("Spammer")
+	g.FailRequests("PATCH", "guilds/"+g.Guild.ID+"/members/"+spammer.ID, 100)
+	for i := 0; i < 10; i++ {
+		g.PostMessage(g.General.ID, spammer, "buy cheap gems")
+	}
+	if !g.WaitFor(func() bool { return g.Silenced(spammer) }) {
+		t.Fatal("Spammer was not silenced when the timeout failed")
+	}
+	if g.WaitForMessage(g.Mods.ID, "was silenced for spamming too many messages") == nil {
+		t.Error("Moderators were not alerted. Bot said: ", g.botSaid(g.Mods))
+	}
+	if c := g.Info().Bot.DB.GetCase(sweetiebot.SBatoi(g.Guild.ID), 1); c == nil || c.Type != sweetiebot.CaseSilence {
+		t.Errorf("Silence was not recorded: %+v", c)
+	}
+}
+
+func TestSpamContent(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	spammers := map[*discordgo.User]string{
+		g.Join("Inviter"): "join discord.gg/abc discord.gg/def https://discord.com/invite/ghi",
+		g.Join("Zalgo"):   "h" + strings.Repeat("\u0336", 50) + "i",
+		g.Join("Emoji"):   strings.Repeat("🔥", 30) + strings.Repeat("<:gem:12345>", 15),
+	}
+	bystander := g.Join("Bystander")
+	for u, content := range spammers {
+		g.PostMessage(g.General.ID, u, content)
+	}
+	g.PostMessage(g.General.ID, bystander, "café 🙂 come join us at discord.gg/abc")
+
+	for u := range spammers {
+		if !g.WaitFor(func() bool { return g.Silenced(u) }) {
+			t.Error(u.Username, "was not silenced")
+		}
+	}
+	if g.Silenced(bystander) {
+		t.Error("Bystander was silenced")
+	}
+}
+
+func TestSpamDuplicates(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	art := g.AddChannel(g.Guild.ID, "art")
+	memes := g.AddChannel(g.Guild.ID, "memes")
+	spammer := g.Join("Spammer")
+	g.PostMessage(g.General.ID, spammer, "Free nitro at totally-legit.example!")
+	g.PostMessage(art.ID, spammer, "free NITRO at totally-legit.example!!!")
+	g.PostMessage(memes.ID, spammer, "FREE nitro at totally legit example")
+	if !g.WaitFor(func() bool { return g.Silenced(spammer) }) {
+		t.Fatal("Spammer posting the same thing in several channels was not silenced")
+	}
+
+	users := []*discordgo.User{}
+	for i := 0; i < 12; i++ {
+		users = append(users, g.Join(fmt.Sprintf("Pasta%v", i)))
+	}
+	bystander := g.Join("Bystander")
+	for i, u := range users {
+		g.PostMessage(g.General.ID, u, fmt.Sprintf("Copy this message to every server you're in or the bots will get you%s", strings.Repeat("!", i)))
+	}
+	g.PostMessage(g.General.ID, bystander, "what is going on in here")
+	if !g.WaitFor(func() bool {
+		for _, u := range users {
+			if g.Silenced(u) {
+				return true
+			}
+		}
+		return false
+	}) {
+		t.Error("Nobody in the copypasta wave was silenced")
+	}
+	if g.Silenced(bystander) {
+		t.Error("Bystander was silenced")
+	}
+}
+
+func TestSpamDryRun(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	g.Command(g.Owner, g.Mods, "setconfig spam.dryrun true", "Successfully set")
+	spammer := g.Join("Spammer")
+	for i := 0; i < 10; i++ {
+		g.PostMessage(g.General.ID, spammer, "buy cheap gems")
+	}
+	if g.WaitForMessage(g.Mods.ID, "Dry run: <@"+spammer.ID+"> would have been silenced") == nil {
+		t.Fatal("Dry run was not reported. Bot said: ", g.botSaid(g.Mods))
+	}
+	if g.Silenced(spammer) {
+		t.Error("Spammer was silenced during a dry run")
+	}
+
+	gID := sweetiebot.SBatoi(g.Guild.ID)
+	if !g.WaitFor(func() bool {
+		return len(g.Info().Bot.DB.GetChatlog(gID, sweetiebot.SBatoi(g.General.ID), time.Now().Add(-time.Hour), time.Now(), 100)) >= 10
+	}) {
+		t.Fatal("Spam was never logged")
+	}
+	general := "<#" + g.General.ID + ">"
+	g.Command(g.Owner, g.Mods, "replayspam "+general+" 1 hour", "1 users would have been silenced:\nSpammer at")
+	g.Command(g.Owner, g.Mods, "replayspam "+general+" 1 hour maxpressure=1000", "No one would have been silenced")
+	g.Command(g.Owner, g.Mods, "replayspam "+general+" 1 hour maxchannelpressure=1000", "No one would have been silenced")
+	g.Command(g.Owner, g.Mods, "replayspam "+general+" 1 hour maxpressure", "isn't a setting")
+	if p := g.Info().Config.Spam.MaxPressure; p != 60 {
+		t.Error("Replaying changed the real max pressure to", p)
+	}
+	if len(g.Info().Config.Spam.MaxChannelPressure) != 0 {
+		t.Error("Replaying changed the real channel max pressure")
+	}
+}
+
+func TestJoinScreening(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	g.Command(g.Owner, g.Mods, "setconfig spam.raidsize 0", "Successfully set")
+	g.Command(g.Owner, g.Mods, "setconfig screening.accountageaction alert", "Successfully set")
+	g.Command(g.Owner, g.Mods, "setconfig screening.nameblocklist free.*nitro", "free.*nitro")
+	g.Command(g.Owner, g.Mods, "setconfig screening.nameblocklistaction ban", "Successfully set")
+	g.Command(g.Owner, g.Mods, "setconfig screening.nameclusteraction silence", "Successfully set")
+	g.Command(g.Owner, g.Mods, "setconfig screening.nameclusteraction explode", "not a screening action")
+
+	nitro := g.Join("FreeNitro4U")
+	if !g.WaitFor(func() bool { return g.Banned(g.Guild.ID, nitro.ID) }) {
+		t.Error("Blocklisted name was not banned")
+	}
+	raiders := []*discordgo.User{g.Join("Raider1"), g.Join("raider_22"), g.Join("RAIDERR333")}
+	for _, u := range raiders {
+		if !g.WaitFor(func() bool { return g.Silenced(u) }) {
+			t.Error(u.Username, "was not silenced")
+		}
+	}
+	innocent := g.Join("Innocent")
+
+	info := g.Info()
+	for _, m := range info.Modules {
+		if h, ok := m.(sweetiebot.ModuleOnTick); ok && m.Name() == "Spam" {
+			h.OnTick(info, time.Now().UTC())
+		}
+	}
+	digest := g.WaitForMessage(g.Mods.ID, "Join screening flagged 5 new members")
+	if digest == nil {
+		t.Fatal("Digest was not posted. Bot said: ", g.botSaid(g.Mods))
+	}
+	for _, s := range []string{"FreeNitro4U#", "[ban]: account created", "username matches free.*nitro", "raider_22#", "[silence]", "similar name to other new members", "Innocent#"} {
+		if !strings.Contains(digest.Content, s) {
+			t.Errorf("Digest is missing %q: %s", s, digest.Content)
+		}
+	}
+	if g.Silenced(innocent) || g.Banned(g.Guild.ID, innocent.ID) {
+		t.Error("Innocent member was punished for only failing an alert rule")
+	}
+}
+
+func TestLockdown(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	info := g.Info()
+	announcements := g.AddChannel(g.Guild.ID, "announcements")
+	level := g.VerificationLevel(g.Guild.ID)
+	// #general already has an overwrite for @everyone that has to be restored exactly, while #announcements has none
+	if err := info.DG.ChannelPermissionSet(g.General.ID, g.Guild.ID, "role", discordgo.PermissionAttachFiles, discordgo.PermissionEmbedLinks); err != nil {
+		t.Fatal(err)
+	}
+	if err := info.DG.SetChannelSlowmode(g.General.ID, 5); err != nil {
+		t.Fatal(err)
+	}
+	g.Command(g.Owner, g.Mods, "setconfig lockdown.channels <#"+g.General.ID+"> <#"+announcements.ID+">", "[")
+	g.Command(g.Owner, g.Mods, "setconfig lockdown.slowmodechannels <#"+g.General.ID+">", "[")
+	g.Command(g.Owner, g.Mods, "setconfig lockdown.slowmode 30", "Successfully set")
+	g.Command(g.Owner, g.Mods, "lockdown", "There is no lockdown engaged.")
+	g.Command(g.Owner, g.Mods, "lockdown on for: 1 hour", "Lockdown engaged!")
+
+	if !g.WaitFor(func() bool { return g.VerificationLevel(g.Guild.ID) == discordgo.VerificationLevelHigh }) {
+		t.Error("Lockdown did not raise the verification level")
+	}
+	if o := g.PermissionOverwrite(g.General.ID, g.Guild.ID); o == nil || o.Deny != discordgo.PermissionEmbedLinks|discordgo.PermissionSendMessages || o.Allow != discordgo.PermissionAttachFiles {
+		t.Errorf("#general was not locked: %+v", o)
+	}
+	if o := g.PermissionOverwrite(announcements.ID, g.Guild.ID); o == nil || o.Deny != discordgo.PermissionSendMessages {
+		t.Errorf("#announcements was not locked: %+v", o)
+	}
+	if g.Slowmode(g.General.ID) != 30 {
+		t.Error("#general was not put in slowmode")
+	}
+	g.Command(g.Owner, g.Mods, "lockdown", "A lockdown is engaged until")
+	g.Command(g.Owner, g.Mods, "lockdown on for: 1 minute", "A lockdown was already engaged.")
+	if !g.WaitFor(func() bool {
+		guild, err := info.GetGuild()
+		return err == nil && guild.VerificationLevel == discordgo.VerificationLevelHigh
+	}) {
+		t.Fatal("Bot never saw the verification level change")
+	}
+
+	// A new module has nothing in memory, just like after a restart, so this only works if the lockdown was saved
+	spammodule.New().OnTick(info, time.Now().UTC().Add(2*time.Minute))
+	if g.WaitForMessage(g.Mods.ID, "Lockdown disengaged") == nil {
+		t.Fatal("Lockdown was not disengaged after a restart. Bot said: ", g.botSaid(g.Mods))
+	}
+	if g.VerificationLevel(g.Guild.ID) != level {
+		t.Error("Verification level was not restored")
+	}
+	if o := g.PermissionOverwrite(g.General.ID, g.Guild.ID); o == nil || o.Deny != discordgo.PermissionEmbedLinks || o.Allow != discordgo.PermissionAttachFiles {
+		t.Errorf("#general's permissions were not restored: %+v", o)
+	}
+	if o := g.PermissionOverwrite(announcements.ID, g.Guild.ID); o != nil {
+		t.Errorf("#announcements should not have an overwrite anymore: %+v", o)
+	}
+	if g.Slowmode(g.General.ID) != 5 {
+		t.Error("#general's slowmode was not restored")
+	}
+	if len(info.Bot.DB.GetLockdown(sweetiebot.SBatoi(g.Guild.ID))) != 0 {
+		t.Error("Lockdown was not removed from the database")
+	}
+}
+
+func TestFilterDelete(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	g.Command(g.Owner, g.Mods, `setfilter badwords "Watch your language!"`, "Created badwords")
+	g.Command(g.Owner, g.Mods, "addfilter badwords heck", "Added heck to badwords")
+	u := g.Join("Potty Mouth")
+	clean := g.PostMessage(g.General.ID, u, "what the hello")
+	dirty := g.PostMessage(g.General.ID, u, "what the heck")
+
+	if !g.WaitFor(func() bool { return g.Deleted(dirty.ID) }) {
+		t.Error("Filtered message was not deleted")
+	}
+	if g.WaitForMessage(g.General.ID, "Watch your language!") == nil {
+		t.Error("Filter response was not sent")
+	}
+	if g.Deleted(clean.ID) {
+		t.Error("Clean message was deleted")
+	}
+}
+
+func TestFilterEvasion(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	g.Command(g.Owner, g.Mods, `setfilter badwords "Watch your language!"`, "Created badwords")
+	g.Command(g.Owner, g.Mods, "addfilter badwords heck", "Added heck to badwords")
+	u := g.Join("Sneaky")
+	for _, text := range []string{"what the h3ck", "what the h\u200be\u200bc\u200bk", "what the h\u0435ck", "what the ＨＥＣＫ", "what the h\u00e9\u00e7k"} {
+		m := g.PostMessage(g.General.ID, u, text)
+		if !g.WaitFor(func() bool { return g.Deleted(m.ID) }) {
+			t.Errorf("Disguised message %q was not deleted", text)
+		}
+	}
+	attached := g.PostAttachment(g.General.ID, u, "look at this", "heck.png")
+	if !g.WaitFor(func() bool { return g.Deleted(attached.ID) }) {
+		t.Error("Message with a filtered attachment name was not deleted")
+	}
+	clean := g.PostMessage(g.General.ID, u, "what the hello")
+	time.Sleep(100 * time.Millisecond)
+	if g.Deleted(clean.ID) {
+		t.Error("Clean message was deleted")
+	}
+}
+
+func TestFilterActions(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	g.Command(g.Owner, g.Mods, `setfilter spoilers ""`, "Created spoilers")
+	g.Command(g.Owner, g.Mods, "addfilter spoilers ending", "Added ending to spoilers")
+	g.Command(g.Owner, g.Mods, "setconfig filter.actions spoilers notify", "Successfully set")
+	g.Command(g.Owner, g.Mods, "setconfig filter.actions spoilers explode", "not a filter action")
+	u := g.Join("Spoiler")
+	notified := g.PostMessage(g.General.ID, u, "the ending was great")
+	if g.WaitForMessage(g.Mods.ID, "triggered the spoilers filter") == nil {
+		t.Error("Mods were not notified")
+	}
+	if g.Deleted(notified.ID) {
+		t.Error("Notify filter deleted the message")
+	}
+
+	g.Command(g.Owner, g.Mods, `setfilter badwords "Watch your language!"`, "Created badwords")
+	g.Command(g.Owner, g.Mods, "addfilter badwords heck", "Added heck to badwords")
+	g.Command(g.Owner, g.Mods, "setconfig filter.actions badwords warn", "Successfully set")
+	g.Command(g.Owner, g.Mods, "setconfig filter.exemptroles badwords <@&"+g.ModRole.ID+">", "Successfully set")
+
+	// Both filters match, so the harsher one wins
+	warned := g.PostMessage(g.General.ID, u, "what the heck was that ending")
+	if !g.WaitFor(func() bool { return g.Deleted(warned.ID) }) {
+		t.Error("Warn filter did not delete the message")
+	}
+	if !g.WaitFor(func() bool {
+		for _, m := range g.DirectMessages(u.ID) {
+			if strings.Contains(m.Content, "You have been warned") && strings.Contains(m.Content, "badwords") {
+				return true
+			}
+		}
+		return false
+	}) {
+		t.Error("User was not warned")
+	}
+	if g.Silenced(u) {
+		t.Error("Warn filter silenced the user")
+	}
+
+	mod := g.AddUser("Mod")
+	g.JoinUser(g.Guild.ID, mod, g.ModRole.ID)
+	exempt := g.PostMessage(g.General.ID, mod, "what the heck")
+
+	g.Command(g.Owner, g.Mods, "setconfig filter.actions badwords silence", "Successfully set")
+	silenced := g.PostMessage(g.General.ID, u, "heck")
+	if !g.WaitFor(func() bool { return g.Deleted(silenced.ID) && g.Silenced(u) }) {
+		t.Error("Silence filter did not delete the message and silence the user")
+	}
+	if g.Deleted(exempt.ID) {
+		t.Error("Message from an exempt role was deleted")
+	}
+
+	// Each filtered message is recorded as a single case of the action's type
+	gID := sweetiebot.SBatoi(g.Guild.ID)
+	if c := g.Info().Bot.DB.GetCase(gID, 1); c == nil || c.Type != sweetiebot.CaseWarning || !strings.Contains(c.Reason, "the badwords filter") {
+		t.Error("Warn filter did not record a warning: ", c)
+	}
+	if c := g.Info().Bot.DB.GetCase(gID, 2); c == nil || c.Type != sweetiebot.CaseSilence || !strings.Contains(c.Reason, "the badwords filter") {
+		t.Error("Silence filter did not record a silence: ", c)
+	}
+	if c := g.Info().Bot.DB.GetCase(gID, 3); c != nil {
+		t.Error("Filtered messages recorded an extra case: ", c)
+	}
+}
+
+func TestFilterStats(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	g.Command(g.Owner, g.Mods, `setfilter badwords ""`, "Created badwords")
+	g.Command(g.Owner, g.Mods, "addfilter badwords heck", "Added heck to badwords")
+	g.Command(g.Owner, g.Mods, "addfilter badwords darn", "Added darn to badwords")
+	g.Command(g.Owner, g.Mods, "addfilter badwords gosh", "Added gosh to badwords")
+	g.Command(g.Owner, g.Mods, "testfilter badwords what the h3ck", "Matched by: heck (after normalizing)")
+	g.Command(g.Owner, g.Mods, "testfilter badwords what the hello", "No match.")
+	g.Command(g.Owner, g.Mods, "testfilter nonsense heck", "does not exist")
+
+	u := g.Join("Potty Mouth")
+	gID := sweetiebot.SBatoi(g.Guild.ID)
+	for i, text := range []string{"heck", "what the heck", "darn it"} {
+		m := g.PostMessage(g.General.ID, u, text)
+		if !g.WaitFor(func() bool {
+			hits := 0
+			for _, v := range g.Info().Bot.DB.GetFilterStats(gID, time.Hour) {
+				hits += v.Hits
+			}
+			return g.Deleted(m.ID) && hits == i+1
+		}) {
+			t.Fatalf("Filter hit for %q was not recorded", text)
+		}
+	}
+
+	g.Command(g.Owner, g.Mods, "filterstats", "badwords/heck: 2")
+	if s := g.botSaid(g.Mods); !strings.Contains(s, "badwords: 3") || !strings.Contains(s, "Words with no hits: badwords/gosh") {
+		t.Error("Filter stats were wrong: ", s)
+	}
+	g.Command(g.Owner, g.Mods, "falsepositive 99", "doesn't exist")
+	g.Command(g.Owner, g.Mods, "falsepositive 1", "Marked case #1 as a false positive")
+	if c := g.Info().Bot.DB.GetCase(gID, 1); c == nil || !c.Pardoned {
+		t.Error("False positive was not pardoned")
+	}
+	g.Command(g.Owner, g.Mods, "filterstats badwords for: 1 day", "Case #1: badwords/heck")
+}
+
+// fakeResolver expands shortened links from a fixed table instead of the internet
+type fakeResolver map[string]string
+
+func (r fakeResolver) Resolve(link string) (string, error) {
+	if s, ok := r[link]; ok {
+		return s, nil
+	}
+	return link, nil
+}
+
+func TestLinkPolicy(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	for _, m := range g.Info().Modules {
+		if links, ok := m.(*linkmodule.LinkModule); ok {
+			links.SetResolver(fakeResolver{"https://bit.ly/abc": "https://evil.example.com/free-nitro"})
+		}
+	}
+	art := g.AddChannel(g.Guild.ID, "art")
+	g.AddInvite(g.Guild.ID, "home")
+	g.AddInvite("1000", "raid")
+	g.AddInvite("2000", "partner")
+	g.AddInvite("3000", "friends")
+	g.Command(g.Owner, g.Mods, "setconfig links.denydomains example.com", "Successfully set")
+	g.Command(g.Owner, g.Mods, "setconfig links.allowdomains safe.example.com", "Successfully set")
+	g.Command(g.Owner, g.Mods, "setconfig links.channelallow <#"+art.ID+"> imgur.com", "Successfully set")
+	g.Command(g.Owner, g.Mods, "setconfig links.shorteners bit.ly", "Successfully set")
+	g.Command(g.Owner, g.Mods, "setconfig links.blockinvites true", "Successfully set")
+	g.Command(g.Owner, g.Mods, "setconfig links.inviteallow partner 3000", "Successfully set")
+	g.Command(g.Owner, g.Mods, "setconfig links.response No links like that, please.", "Successfully set")
+	g.Command(g.Owner, g.Mods, "setconfig links.action explode", "not a filter action")
+
+	u := g.Join("Linker")
+	mod := g.AddUser("Mod")
+	g.JoinUser(g.Guild.ID, mod, g.ModRole.ID)
+	allowed := []*discordgo.Message{
+		g.PostMessage(g.General.ID, u, "this is fine: https://safe.example.com/page"),
+		g.PostMessage(g.General.ID, u, "https://other.org/page"),
+		g.PostMessage(art.ID, u, "my drawing https://imgur.com/abc"),
+		g.PostMessage(g.General.ID, u, "come back to discord.gg/home"),
+		g.PostMessage(g.General.ID, u, "our partners: https://discord.gg/partner and discord.gg/friends"),
+	}
+	denied := []*discordgo.Message{
+		g.PostMessage(g.General.ID, u, "check out https://example.com/page"),
+		g.PostMessage(g.General.ID, u, "check out https://www.sub.example.com/page"),
+		g.PostMessage(art.ID, u, "https://other.org/page"),
+		g.PostMessage(g.General.ID, u, "join discord.gg/raid"),
+		g.PostMessage(g.General.ID, u, "join https://discord.com/invite/expired"),
+		g.PostMessage(g.General.ID, u, "free nitro https://bit.ly/abc"),
+	}
+	for _, m := range denied {
+		if !g.WaitFor(func() bool { return g.Deleted(m.ID) }) {
+			t.Errorf("%q was not deleted", m.Content)
+		}
+	}
+	if g.WaitForMessage(g.General.ID, "No links like that, please.") == nil {
+		t.Error("Response was not sent. Bot said: ", g.botSaid(g.General))
+	}
+
+	g.Command(g.Owner, g.Mods, "setconfig links.exemptroles <@&"+g.ModRole.ID+">", "Successfully set")
+	allowed = append(allowed, g.PostMessage(g.General.ID, mod, "https://example.com/page discord.gg/raid"))
+	g.Command(g.Owner, g.Mods, "setconfig links.action notify", "Successfully set")
+	notified := g.PostMessage(g.General.ID, u, "free nitro https://bit.ly/abc")
+	if g.WaitForMessage(g.Mods.ID, "links to evil.example.com aren't allowed, shortened with bit.ly") == nil {
+		t.Error("Mods were not notified. Bot said: ", g.botSaid(g.Mods))
+	}
+	allowed = append(allowed, notified)
+	for _, m := range allowed {
+		if g.Deleted(m.ID) {
+			t.Errorf("%q was deleted", m.Content)
+		}
+	}
+}
+
+func TestSchedulerUnban(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	u := g.Join("Troublemaker")
+	g.Command(g.Owner, g.Mods, "ban <@"+u.ID+"> for: 1 fortnight", "Invalid {duration}")
+	g.Command(g.Owner, g.Mods, "ban <@"+u.ID+"> for: 1 second because testing", "Banned")
+	if !g.Banned(g.Guild.ID, u.ID) || g.IsMember(g.Guild.ID, u.ID) {
+		t.Fatal("User was not banned")
+	}
+	if !strings.Contains(g.BanReason(g.Guild.ID, u.ID), "testing") {
+		t.Error("Ban reason was not passed to discord: ", g.BanReason(g.Guild.ID, u.ID))
+	}
+
+	// The scheduler normally runs every 20 seconds from the idle loop, so we tick it ourselves once the ban expires
+	time.Sleep(2 * time.Second)
+	info := g.Info()
+	for _, m := range info.Modules {
+		if h, ok := m.(sweetiebot.ModuleOnTick); ok && m.Name() == "Scheduler" {
+			h.OnTick(info, time.Now().UTC())
+		}
+	}
+	if !g.WaitFor(func() bool { return !g.Banned(g.Guild.ID, u.ID) }) {
+		t.Error("User was not unbanned")
+	}
+	if g.WaitForMessage(g.Mods.ID, "Unbanned <@"+u.ID+">") == nil {
+		t.Error("Unban was not announced. Bot said: ", g.botSaid(g.Mods))
+	}
+}
+
+func TestSchedulerRecurrence(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	movies := g.AddChannel(g.Guild.ID, "movie-night")
+	g.Command(g.Owner, g.Mods, "addevent event <#"+movies.ID+"> \"13 Jan 2099 8:00pm\" FREQ=SECONDLY Movie Night", "not a supported frequency")
+	g.Command(g.Owner, g.Mods, "addevent event <#"+movies.ID+"> \"13 Jan 2099 8:00pm\" FREQ=MONTHLY;BYDAY=2TU Movie Night", "repeating every month on the 2nd Tuesday")
+	g.Command(g.Owner, g.Mods, "schedule events", "(repeats every month on the 2nd Tuesday")
+
+	// Put a weekly event in the past so the next tick announces it in its own channel and moves it to next week
+	info := g.Info()
+	gID := sweetiebot.SBatoi(g.Guild.ID)
+	rule, err := sweetiebot.ParseRecurrence("FREQ=WEEKLY", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	data := sweetiebot.EventData{Message: "Weekly check-in"}
+	if err := info.Bot.DB.AddScheduleEvent(gID, due, sweetiebot.EventMessage, data, sweetiebot.DiscordChannel(movies.ID), rule); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range info.Modules {
+		if h, ok := m.(sweetiebot.ModuleOnTick); ok && m.Name() == "Scheduler" {
+			h.OnTick(info, time.Now().UTC())
+		}
+	}
+	if g.WaitForMessage(movies.ID, "Weekly check-in") == nil {
+		t.Error("Event was not announced in its channel. Bot said: ", g.botSaid(movies))
+	}
+	events := info.Bot.DB.GetEventsByType(gID, sweetiebot.EventMessage, 1)
+	if len(events) != 1 || !events[0].Date.Equal(due.AddDate(0, 0, 7)) {
+		t.Error("Recurring event was not moved to its next occurrence: ", events)
+	}
+}
+
+func TestSchedulerRetry(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	u := g.Join("Troublemaker")
+	g.Command(g.Owner, g.Mods, "ban <@"+u.ID+"> for: 1 second because testing", "Banned")
+	info := g.Info()
+	gID := sweetiebot.SBatoi(g.Guild.ID)
+	events := info.Bot.DB.GetEventsByType(gID, sweetiebot.EventBan, 1)
+	if len(events) != 1 {
+		t.Fatal("Unban was not scheduled")
+	}
+	id := events[0].ID
+	tick := func() {
+		for _, m := range info.Modules {
+			if h, ok := m.(sweetiebot.ModuleOnTick); ok && m.Name() == "Scheduler" {
+				h.OnTick(info, time.Now().UTC())
+			}
+		}
+	}
+
+	// If discord rejects the unban, the event has to stay in the schedule so it's retried
+	g.FailRequests("DELETE", "guilds/"+g.Guild.ID+"/bans/"+u.ID, 1)
+	time.Sleep(2 * time.Second)
+	tick()
+	if !g.Banned(g.Guild.ID, u.ID) {
+		t.Fatal("User was unbanned even though discord failed")
+	}
+	if info.Bot.DB.GetEvent(gID, id) == nil {
+		t.Fatal("Failed unban was removed from the schedule")
+	}
+
+	// Skip the backoff and fail the last attempt, so the scheduler gives up and tells the moderators
+	if _, err := info.Bot.DB.FailEvent(id, sweetiebot.MaxEventAttempts-1, "testing", time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	g.FailRequests("DELETE", "guilds/"+g.Guild.ID+"/bans/"+u.ID, 1)
+	tick()
+	if g.WaitForMessage(g.Mods.ID, "Gave up on event #"+sweetiebot.SBitoa(id)) == nil {
+		t.Fatal("Failed event was not reported. Bot said: ", g.botSaid(g.Mods))
+	}
+	g.Command(g.Owner, g.Mods, "failedevents", fmt.Sprintf("- failed %v times", sweetiebot.MaxEventAttempts))
+	g.Command(g.Owner, g.Mods, "failedevents retry 0", "hasn't failed")
+	g.Command(g.Owner, g.Mods, "failedevents retry "+sweetiebot.SBitoa(id), "will be retried in a moment")
+	tick()
+	if !g.WaitFor(func() bool { return !g.Banned(g.Guild.ID, u.ID) }) {
+		t.Error("User was not unbanned after retrying")
+	}
+	g.Command(g.Owner, g.Mods, "failedevents", "No scheduled events have failed")
+}
+
+func TestSchedulerMissedEvents(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	g.Command(g.Owner, g.Mods, "setconfig events.missedevents ignore", "not a missed event policy")
+	g.Command(g.Owner, g.Mods, "setconfig events.missedevents summarize", "Successfully set")
+
+	// Pretend the bot was offline for a while, so a daily announcement was missed three times
+	info := g.Info()
+	gID := sweetiebot.SBatoi(g.Guild.ID)
+	rule, err := sweetiebot.ParseRecurrence("FREQ=DAILY", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := time.Now().UTC().Add(-50 * time.Hour).Truncate(time.Second)
+	if err := info.Bot.DB.AddScheduleEvent(gID, due, sweetiebot.EventMessage, sweetiebot.EventData{Message: "Daily check-in"}, sweetiebot.DiscordChannel(g.General.ID), rule); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range info.Modules {
+		if h, ok := m.(sweetiebot.ModuleOnTick); ok && m.Name() == "Scheduler" {
+			h.OnTick(info, time.Now().UTC())
+		}
+	}
+	if g.WaitForMessage(g.General.ID, "missed 1 announcement") == nil {
+		t.Fatal("Missed events were not summarized. Bot said: ", g.botSaid(g.General))
+	}
+	if g.WaitForMessage(g.General.ID, "(missed 3 times)") == nil {
+		t.Error("Summary did not count the missed occurrences. Bot said: ", g.botSaid(g.General))
+	}
+	events := info.Bot.DB.GetEventsByType(gID, sweetiebot.EventMessage, 1)
+	if len(events) != 1 || !events[0].Date.Equal(due.AddDate(0, 0, 3)) {
+		t.Error("Missed event was not moved to its next occurrence: ", events)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	g.Command(g.Owner, g.Mods, "calendarfeed", "The calendar feed is off")
+	g.Command(g.Owner, g.Mods, "calendarfeed on", "/calendar/"+g.Guild.ID+"/")
+	link := g.Info().CalendarURL()
+	g.PostMessage(g.Mods.ID, g.Owner, "!calendarfeed reset")
+	if !g.WaitFor(func() bool { return g.Info().CalendarURL() != link }) {
+		t.Error("Resetting the calendar feed did not change its link")
+	}
+
+	g.Command(g.Owner, g.Mods, "exportcalendar", "no upcoming events")
+	g.Command(g.Owner, g.Mods, "addevent episode \"2 Feb 2099 6:00pm\" S10E01", "Added event to schedule.")
+	g.Command(g.Owner, g.Mods, "addevent event <#"+g.General.ID+"> \"13 Jan 2099 8:00pm\" FREQ=MONTHLY;BYDAY=2TU Movie Night", "repeating every month")
+	g.Command(g.Owner, g.Mods, "exportcalendar", "Exported 2 events")
+	export := g.WaitForMessage(g.Mods.ID, "Exported 2 events")
+	if len(export.Attachments) != 1 || export.Attachments[0].Filename != "schedule.ics" {
+		t.Fatal("Calendar was not attached to the message: ", export.Attachments)
+	}
+	ics := string(g.File(export.Attachments[0]))
+	for _, s := range []string{"SUMMARY:S10E01", "CATEGORIES:EPISODE", "SUMMARY:Movie Night", "RRULE:FREQ=MONTHLY;BYDAY=2TU", "X-SWEETIEBOT-CHANNEL:" + g.General.ID} {
+		if !strings.Contains(ics, s) {
+			t.Errorf("Exported calendar is missing %q: %s", s, ics)
+		}
+	}
+
+	g.Command(g.Owner, g.Mods, "importcalendar", "attach an .ics file")
+	g.PostFile(g.Mods.ID, g.Owner, "!importcalendar", "schedule.ics", []byte(ics))
+	if g.WaitForMessage(g.Mods.ID, "Imported 0 events. Skipped 2") == nil || g.WaitForMessage(g.Mods.ID, "already in the schedule") == nil {
+		t.Error("Importing the same calendar did not skip its events. Bot said: ", g.botSaid(g.Mods))
+	}
+	g.PostFile(g.Mods.ID, g.Owner, "!importcalendar", "other.ics", []byte(strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"SUMMARY:Watch party",
+		"DTSTART:20990301T200000Z",
+		"X-SWEETIEBOT-CHANNEL:1",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Long ago",
+		"DTSTART:20000301T200000Z",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")))
+	if g.WaitForMessage(g.Mods.ID, "Imported 1 event. Skipped 1") == nil || g.WaitForMessage(g.Mods.ID, "Long ago: it's in the past") == nil {
+		t.Error("Calendar was not imported. Bot said: ", g.botSaid(g.Mods))
+	}
+	events := g.Info().Bot.DB.GetEventsByType(sweetiebot.SBatoi(g.Guild.ID), sweetiebot.EventGeneric, 10)
+	if len(events) != 2 || events[1].Payload().Message != "Watch party" || events[1].Channel != sweetiebot.ChannelEmpty {
+		t.Error("Imported event was not added to the schedule: ", events)
+	}
+}
+
+func TestEventRSVP(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	u := g.Join("Moviegoer")
+	v := g.Join("Latecomer")
+	g.Command(g.Owner, g.Mods, "addevent event <#"+g.General.ID+"> \"13 Jan 2099 8:00pm\" Movie Night", "Added event to schedule.")
+	info := g.Info()
+	gID := sweetiebot.SBatoi(g.Guild.ID)
+	events := info.Bot.DB.GetEventsByType(gID, sweetiebot.EventGeneric, 1)
+	if len(events) != 1 {
+		t.Fatal("Event was not added: ", events)
+	}
+	event := events[0].ID
+	id := sweetiebot.SBitoa(event)
+
+	g.Command(u, g.General, "rsvp "+id, "You're going to Movie Night!")
+	g.Command(u, g.General, "rsvp "+id, "You're already going to Movie Night.")
+	g.Command(g.Owner, g.Mods, "schedule events", "Movie Night in #general (1 going)")
+
+	g.Command(g.Owner, g.Mods, "postevent "+id, "Posted event #"+id+" in #general")
+	post := g.WaitForMessage(g.General.ID, "React with ✅ if you're going")
+	if post == nil {
+		t.Fatal("Event was not posted. Bot said: ", g.botSaid(g.General))
+	}
+	if !g.WaitFor(func() bool { r := g.Reactions(post.ID); return len(r) == 1 && r[0] == "✅" }) {
+		t.Error("Bot did not react to the post: ", g.Reactions(post.ID))
+	}
+	g.React(g.General.ID, post.ID, v, "👍")
+	g.React(g.General.ID, post.ID, v, "✅")
+	if !g.WaitFor(func() bool { return info.Bot.DB.CountRSVPs(event) == 2 }) {
+		t.Error("Reacting to the post did not RSVP")
+	}
+	g.Command(g.Owner, g.Mods, "attendees "+id, "2 members going to Movie Night")
+	g.Unreact(g.General.ID, post.ID, v, "✅")
+	if !g.WaitFor(func() bool { return info.Bot.DB.CountRSVPs(event) == 1 }) {
+		t.Error("Removing the reaction did not take back the RSVP")
+	}
+	g.Command(v, g.General, "rsvp "+id+" cancel", "You weren't going to Movie Night.")
+
+	// An event 10 minutes from now is past its 15 minute reminder, so the next tick reminds everyone who RSVPed before then
+	soon := time.Now().UTC().Add(10 * time.Minute).Truncate(time.Second)
+	if err := info.Bot.DB.AddScheduleEvent(gID, soon, sweetiebot.EventGeneric, sweetiebot.EventData{Message: "Game Night"}, sweetiebot.ChannelEmpty, nil); err != nil {
+		t.Fatal(err)
+	}
+	events = info.Bot.DB.GetEventsByType(gID, sweetiebot.EventGeneric, 1)
+	if len(events) != 1 || events[0].Payload().Message != "Game Night" {
+		t.Fatal("Event was not added: ", events)
+	}
+	info.Bot.DB.AddRSVP(gID, events[0].ID, sweetiebot.DiscordUser(u.ID))
+	info.Bot.DB.AddRSVP(gID, events[0].ID, sweetiebot.DiscordUser(v.ID))
+	info.Bot.DB.SetRSVPReminded(events[0].ID, sweetiebot.DiscordUser(u.ID), soon.Add(-time.Hour))
+	for _, m := range info.Modules {
+		if h, ok := m.(sweetiebot.ModuleOnTick); ok && m.Name() == "Scheduler" {
+			h.OnTick(info, time.Now().UTC())
+		}
+	}
+	if !g.WaitFor(func() bool {
+		for _, m := range g.DirectMessages(u.ID) {
+			if strings.Contains(m.Content, "Reminder: **Game Night** starts in 15 minutes") {
+				return true
+			}
+		}
+		return false
+	}) {
+		t.Error("Attendee was not reminded about the event")
+	}
+	if len(g.DirectMessages(v.ID)) != 0 {
+		t.Error("Member who RSVPed after the reminder was due got one anyway")
+	}
+	for _, r := range info.Bot.DB.GetRSVPs(events[0].ID) {
+		if r.User == sweetiebot.DiscordUser(u.ID) && r.Reminded.Before(soon.Add(-15*time.Minute)) {
+			t.Error("Reminder was not recorded, so it would be sent again")
+		}
+	}
+}
+
+func TestTagSearch(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	for _, v := range []string{"pony", "pegasus", "princess"} {
+		g.Command(g.Owner, g.Mods, "new "+v, "Created the "+v+" tag")
+	}
+	g.Command(g.Owner, g.Mods, "add pony+princess Twilight Sparkle", "Twilight Sparkle: ")
+	g.Command(g.Owner, g.Mods, "add pony+pegasus Rainbow Dash", "Rainbow Dash: ")
+	g.Command(g.Owner, g.Mods, "add pony+pegasus+princess Princess Luna", "Princess Luna: ")
+
+	g.Command(g.Owner, g.Mods, "tags pony+(pegasus", "Expected ) to close the ( at position 6")
+	g.Command(g.Owner, g.Mods, "tags pony-pegasus", "Expected + or |, but found - at position 5")
+	g.Command(g.Owner, g.Mods, "tags pony+unicorn", "The unicorn tag does not exist")
+	g.Command(g.Owner, g.Mods, "tags PONY + -(pegasus+princess)", "2 items satisfy")
+	g.Command(g.Owner, g.Mods, "tags princess|pegasus", "3 items satisfy")
+	g.Command(g.Owner, g.Mods, "searchtags pony+-pegasus", "Number of items matching pony+-pegasus: 1")
+	g.Command(g.Owner, g.Mods, "pick pony+-pony", "No items were returned by pony+-pony")
+}
+
+func TestCases(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	u := g.Join("Rulebreaker")
+	g.Command(g.Owner, g.Mods, "warn <@"+u.ID+"> stop that", "Warned Rulebreaker (Case #1)")
+	if !g.WaitFor(func() bool {
+		for _, m := range g.DirectMessages(u.ID) {
+			if strings.Contains(m.Content, "stop that") {
+				return true
+			}
+		}
+		return false
+	}) {
+		t.Error("Warned user was not sent the reason")
+	}
+	if g.WaitForEmbed(g.Mods.ID, "Case #1: Warning") == nil {
+		t.Error("Warning was not logged to the mod channel")
+	}
+
+	g.Command(g.Owner, g.Mods, "silence <@"+u.ID+"> for: 2 hours because: being rude", "Silenced Rulebreaker. (Case #2)")
+	if !g.Silenced(u) {
+		t.Error("User was not silenced")
+	}
+	c := g.Info().Bot.DB.GetCase(sweetiebot.SBatoi(g.Guild.ID), 2)
+	if c == nil || c.Schedule == nil || c.Reason != "being rude" || c.Duration != 2*time.Hour {
+		t.Fatalf("Silence case was not recorded with its unsilence event: %+v", c)
+	}
+
+	// Names with spaces don't need quotes, since everything up to the next keyword is part of the name
+	v := g.Join("John Smith")
+	g.Join("John")
+	g.Command(g.Owner, g.Mods, "silence John Smith for: 5 minutes because: testing", "Silenced John Smith. (Case #3)")
+	if !g.Silenced(v) {
+		t.Error("Member with spaces in their name was not silenced")
+	}
+	c = g.Info().Bot.DB.GetCase(sweetiebot.SBatoi(g.Guild.ID), 3)
+	if c == nil || c.User != sweetiebot.SBatoi(v.ID) || c.Reason != "testing" || c.Duration != 5*time.Minute {
+		t.Fatalf("Silence case for a name with spaces is wrong: %+v", c)
+	}
+
+	g.Command(g.Owner, g.Mods, "editreason 2 being very rude", "Updated the reason for case #2")
+	g.Command(g.Owner, g.Mods, "pardon 1", "Pardoned case #1")
+	g.Command(g.Owner, g.Mods, "pardon 1", "already been pardoned")
+	g.Command(g.Owner, g.Mods, "pardon 4", "case #4 doesn't exist")
+	g.Command(g.Owner, g.Mods, "cases <@"+u.ID+">", "Cases for Rulebreaker")
+	if g.WaitForMessage(g.Mods.ID, "#2 Silence for 2 hours by") == nil || g.WaitForMessage(g.Mods.ID, "stop that [pardoned]") == nil {
+		t.Error("Case history is wrong. Bot said: ", g.botSaid(g.Mods))
+	}
+	g.PostMessage(g.Mods.ID, g.Owner, "!case 2")
+	if g.WaitForEmbed(g.Mods.ID, "being very rude") == nil {
+		t.Error("Case was not shown with its new reason")
+	}
+}
+
+func TestKickAndTimeout(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	u := g.Join("Loudmouth")
+	g.Command(g.Owner, g.Mods, "timeout <@"+u.ID+"> for: 30 days", "28 days")
+	g.Command(g.Owner, g.Mods, "timeout <@"+u.ID+"> for: 2 seconds because: shouting", "Timed out Loudmouth for 2 seconds. (Case #1)")
+	if !g.TimedOut(g.Guild.ID, u.ID) {
+		t.Error("User was not timed out")
+	}
+	c := g.Info().Bot.DB.GetCase(sweetiebot.SBatoi(g.Guild.ID), 1)
+	if c == nil || c.Type != sweetiebot.CaseTimeout || c.Reason != "shouting" || c.Schedule == nil {
+		t.Fatalf("Timeout case was not recorded with its expiry event: %+v", c)
+	}
+
+	time.Sleep(3 * time.Second)
+	info := g.Info()
+	for _, m := range info.Modules {
+		if h, ok := m.(sweetiebot.ModuleOnTick); ok && m.Name() == "Scheduler" {
+			h.OnTick(info, time.Now().UTC())
+		}
+	}
+	if g.WaitForMessage(g.Mods.ID, "Timeout expired for <@"+u.ID+">") == nil {
+		t.Error("Timeout expiry was not announced. Bot said: ", g.botSaid(g.Mods))
+	}
+
+	g.Command(g.Owner, g.Mods, "kick Loudmouth because: still shouting", "Kicked Loudmouth from the server. (Case #2)")
+	if !g.WaitFor(func() bool { return !g.IsMember(g.Guild.ID, u.ID) }) {
+		t.Error("User was not kicked")
+	}
+	if c := g.Info().Bot.DB.GetCase(sweetiebot.SBatoi(g.Guild.ID), 2); c == nil || c.Reason != "still shouting" {
+		t.Errorf("Kick case was not recorded with its reason: %+v", c)
+	}
+	if g.Banned(g.Guild.ID, u.ID) {
+		t.Error("Kicked user was banned")
+	}
+	if g.WaitForEmbed(g.Mods.ID, "Case #2: Kick") == nil {
+		t.Error("Kick was not logged to the mod channel")
+	}
+
+	v := g.Join("Very Loud Person")
+	g.Join("Very")
+	g.Command(g.Owner, g.Mods, "timeout Very Loud Person for: 5 minutes because: testing", "Timed out Very Loud Person for 5 minutes. (Case #3)")
+	if !g.TimedOut(g.Guild.ID, v.ID) {
+		t.Error("Member with spaces in their name was not timed out")
+	}
+}
+
+func TestQuotes(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	g.Join("John Smith")
+	g.Join("John")
+	g.Command(g.Owner, g.Mods, "quote", "There are no quotes.")
+	g.Command(g.Owner, g.Mods, `addquote "John Smith" hello there`, "Quote added to John Smith.")
+	g.Command(g.Owner, g.Mods, `addquote "John Smith"`, "Missing {quote}")
+	g.Command(g.Owner, g.Mods, "quote John Smith 1", "**John Smith**: hello there")
+	g.Command(g.Owner, g.Mods, "searchquote John Smith", "1. hello there")
+	g.Command(g.Owner, g.Mods, "removequote John Smith 2", "Invalid quote index")
+	g.Command(g.Owner, g.Mods, "removequote John Smith", `Invalid {quote} "Smith"`)
+	g.Command(g.Owner, g.Mods, "removequote John Smith 1", "Deleted quote #1 from John Smith.")
+	g.Command(g.Owner, g.Mods, "quote John Smith", "That user has no quotes.")
+}
+
+func TestEscalation(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	g.Command(g.Owner, g.Mods, `setfilter badwords "Watch your language!"`, "Created badwords")
+	g.Command(g.Owner, g.Mods, "addfilter badwords heck", "Added heck to badwords")
+	g.Command(g.Owner, g.Mods, `setconfig users.escalation "2 filters in 1 hour" silence for 10 minutes`, "Successfully set")
+	u := g.Join("Repeat Offender")
+	gID := sweetiebot.SBatoi(g.Guild.ID)
+
+	for i := 0; i < 2; i++ {
+		if g.Silenced(u) {
+			t.Fatal("User was silenced before reaching the threshold")
+		}
+		dirty := g.PostMessage(g.General.ID, u, "what the heck")
+		number := uint64(i + 1)
+		if !g.WaitFor(func() bool { return g.Deleted(dirty.ID) && g.Info().Bot.DB.GetCase(gID, number) != nil }) {
+			t.Fatal("Filtered message was not deleted and recorded")
+		}
+	}
+	if !g.WaitFor(func() bool { return g.Silenced(u) }) {
+		t.Fatal("User was not silenced after two filter hits")
+	}
+	c := g.Info().Bot.DB.GetCase(gID, 3)
+	if c == nil || c.Type != sweetiebot.CaseSilence || c.Reason != "Escalation: 2 filter in 1 hour" || c.Duration != 10*time.Minute || c.Schedule == nil {
+		t.Fatalf("Escalated silence was not recorded with its unsilence event: %+v", c)
+	}
+
+	// A manual silence counts towards the next rule, which bans them
+	g.Command(g.Owner, g.Mods, `setconfig users.escalation "2 silences in 1 week" ban for 1 day`, "Successfully set")
+	g.Command(g.Owner, g.Mods, "unsilence <@"+u.ID+">", "Unsilenced Repeat Offender. (Case #4)")
+	g.Command(g.Owner, g.Mods, "silence <@"+u.ID+"> because: still swearing", "Silenced Repeat Offender. (Case #5)")
+	if !g.WaitFor(func() bool { return g.Banned(g.Guild.ID, u.ID) }) {
+		t.Fatal("User was not banned after two silences")
+	}
+	if !strings.Contains(g.BanReason(g.Guild.ID, u.ID), "2 silence in 1 week") {
+		t.Error("Escalation rule was not given as the ban reason: ", g.BanReason(g.Guild.ID, u.ID))
+	}
+	if g.WaitForEmbed(g.Mods.ID, "Case #6: Ban") == nil {
+		t.Error("Escalated ban was not logged to the mod channel")
+	}
+	if c = g.Info().Bot.DB.GetCase(gID, 6); c == nil || c.Moderator != g.Info().Bot.SelfID.Convert() || c.Schedule == nil {
+		t.Errorf("Escalated ban was not recorded with its unban event: %+v", c)
+	}
+
+	g.Command(g.Owner, g.Mods, `setconfig users.escalation "2 filters" ban`, "Key error")
+}
+
+func TestSlashCommands(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	registered := func(name string) *fakediscord.ApplicationCommand {
+		for _, c := range g.Commands(g.Guild.ID) {
+			if c.Name == name {
+				return c
+			}
+		}
+		return nil
+	}
+	if !g.WaitFor(func() bool { return registered("about") != nil }) { // Commands are disabled until !setup runs
+		t.Fatal("Commands were not registered after setup")
+	}
+	if registered("fight") != nil {
+		t.Error("Commands in disabled modules were registered")
+	}
+	if c := registered("setconfig"); c == nil || len(c.Options) == 0 || c.Options[0].Name != "parameter-value" {
+		t.Errorf("SetConfig options were not built from its usage: %+v", c)
+	}
+
+	if r := g.Slash(g.Owner, g.General, "about", nil, "Sweetie Bot v"); r.Ephemeral() {
+		t.Error("About response should be visible to everyone")
+	}
+	if r := g.Slash(g.Owner, g.General, "help", nil, "Sweetie Bot"); !r.Ephemeral() {
+		t.Error("Help response should be ephemeral, because it is normally sent in a private message")
+	}
+	u := g.Join("Regular")
+	if r := g.Slash(u, g.General, "setconfig", map[string]string{"parameter-value": "basic.listentobots true"}, "permission"); !r.Ephemeral() {
+		t.Error("Errors should be ephemeral")
+	}
+
+	g.Slash(g.Owner, g.Mods, "setconfig", map[string]string{"parameter-value": "basic.slashcommands false"}, "Successfully set")
+	if !g.WaitFor(func() bool { return len(g.Commands(g.Guild.ID)) == 0 }) {
+		t.Error("Disabling slash commands did not remove them")
+	}
+}
+
+// dashboardLogin logs in to the dashboard as the given user through the fake discord's OAuth2 flow. The returned
+// client doesn't follow redirects, so they can be checked.
+func (g *testGuild) dashboardLogin(web *httptest.Server, u *discordgo.User) *http.Client {
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(web.URL + "/dashboard/login")
+	if err != nil {
+		g.t.Fatal(err)
+	}
+	resp.Body.Close()
+	login, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasSuffix(login.Path, "oauth2/authorize") {
+		g.t.Fatalf("Login redirected to %q instead of discord", resp.Header.Get("Location"))
+	}
+	callback := url.Values{"code": {g.Authorize(u)}, "state": {login.Query().Get("state")}}
+	resp, err = client.Get(web.URL + "/dashboard/callback?" + callback.Encode())
+	if err != nil {
+		g.t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/dashboard/" {
+		g.t.Fatalf("Login as %s failed with %s", u.Username, resp.Status)
+	}
+	return client
+}
+
+func readPage(t *testing.T, resp *http.Response, err error) (int, string) {
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+var csrfRegex = regexp.MustCompile(`name="csrf" value="([0-9a-f]+)"`)
+
+func TestDashboard(t *testing.T) {
+	g := startBot(t)
+	defer g.Stop()
+
+	g.Sweetie.OAuthSecret = "secret"
+	mux := http.NewServeMux()
+	if err := g.Sweetie.ConfigureDashboard(mux, webdir); err != nil {
+		t.Fatal(err)
+	}
+	web := httptest.NewServer(mux)
+	defer web.Close()
+	page := web.URL + "/dashboard/" + g.Guild.ID + "/"
+
+	anonymous := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	if resp, err := anonymous.Get(page); err != nil || resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/dashboard/login" {
+		t.Error("Dashboard did not ask an anonymous user to log in")
+	}
+
+	member := g.dashboardLogin(web, g.Join("Member"))
+	if _, body := readPage(t, member.Get(web.URL+"/dashboard/")); !strings.Contains(body, "You don't moderate any servers") {
+		t.Error("Dashboard listed servers a regular member can't edit")
+	}
+	if code, _ := readPage(t, member.Get(page)); code != http.StatusNotFound {
+		t.Errorf("Regular member got %v instead of 404 for the server config", code)
+	}
+
+	mod := g.AddUser("Mod")
+	g.JoinUser(g.Guild.ID, mod, g.ModRole.ID)
+	if !g.WaitFor(func() bool { return g.Info().UserIsMod(sweetiebot.DiscordUser(mod.ID)) }) {
+		t.Fatal("Bot never saw the moderator join")
+	}
+	if code, _ := readPage(t, g.dashboardLogin(web, mod).Get(page)); code != http.StatusOK {
+		t.Errorf("Moderator got %v instead of 200 for the server config", code)
+	}
+
+	owner := g.dashboardLogin(web, g.Owner)
+	if _, body := readPage(t, owner.Get(web.URL+"/dashboard/")); !strings.Contains(body, "/dashboard/"+g.Guild.ID+"/") {
+		t.Error("Dashboard did not list the owner's server")
+	}
+	_, body := readPage(t, owner.Get(page))
+	match := csrfRegex.FindStringSubmatch(body)
+	if match == nil || !strings.Contains(body, `name="witty.responses.key"`) {
+		t.Fatal("Server config page is missing the witty.responses form")
+	}
+	csrf := match[1]
+
+	form := url.Values{"csrf": {csrf}, "witty.responses.key": {"Hello", ""}, "witty.responses.value": {"hi there", ""}, "witty.cooldown": {"30"}}
+	if resp, err := owner.PostForm(page+"witty", form); err != nil || resp.StatusCode != http.StatusSeeOther {
+		t.Fatal("Saving the witty config failed")
+	}
+	info := g.Info()
+	info.ConfigLock.RLock()
+	if info.Config.Witty.Responses["hello"] != "hi there" || info.Config.Witty.Cooldown != 30 {
+		t.Errorf("Witty config was not changed: %v", info.Config.Witty)
+	}
+	info.ConfigLock.RUnlock()
+	if data, _ := ioutil.ReadFile(g.Guild.ID + ".json"); !strings.Contains(string(data), "hi there") {
+		t.Error("Config file was not saved")
+	}
+	if g.WaitForMessage(g.Log.ID, "changed the witty configuration") == nil {
+		t.Error("Config change was not logged")
+	}
+
+	form = url.Values{"csrf": {csrf}, "basic.commandprefix": {"?"}, "basic.modchannel": {"#nonexistent"}}
+	if code, body := readPage(t, owner.PostForm(page+"basic", form)); code != http.StatusBadRequest || !strings.Contains(body, "basic.modchannel") {
+		t.Errorf("Invalid channel was not rejected: %v", code)
+	}
+	info.ConfigLock.RLock()
+	if info.Config.Basic.CommandPrefix == "?" || info.Config.Basic.ModChannel.String() != g.Mods.ID {
+		t.Error("Config was changed even though part of the form was invalid")
+	}
+	info.ConfigLock.RUnlock()
+
+	form.Set("csrf", "forged")
+	if code, _ := readPage(t, owner.PostForm(page+"basic", form)); code != http.StatusForbidden {
+		t.Errorf("Form with the wrong csrf token got %v instead of 403", code)
+	}
+}
@@ -145,6 +145,25 @@ CREATE TABLE IF NOT EXISTS `aliases` (
   CONSTRAINT `ALIASES_USERS` FOREIGN KEY (`User`) REFERENCES `users` (`ID`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4//
 
+-- Data exporting was unselected.
+-- Dumping structure for table sweetiebot.cases
+CREATE TABLE IF NOT EXISTS `cases` (
+  `ID` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `Guild` bigint(20) unsigned NOT NULL,
+  `Number` bigint(20) unsigned NOT NULL,
+  `User` bigint(20) unsigned NOT NULL,
+  `Moderator` bigint(20) unsigned NOT NULL,
+  `Type` tinyint(3) unsigned NOT NULL,
+  `Reason` varchar(1000) NOT NULL DEFAULT '',
+  `Duration` int(10) unsigned DEFAULT NULL,
+  `Schedule` bigint(20) unsigned DEFAULT NULL,
+  `Timestamp` datetime NOT NULL,
+  `Pardoned` tinyint(1) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`ID`),
+  UNIQUE KEY `INDEX_GUILD_NUMBER` (`Guild`,`Number`),
+  KEY `INDEX_GUILD_USER` (`Guild`,`User`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Numbered moderation actions taken against users.'//
+
 -- Data exporting was unselected.
 -- Dumping structure for table sweetiebot.chatlog
 CREATE TABLE IF NOT EXISTS `chatlog` (
@@ -426,6 +445,22 @@ END IF;
 RETURN date2;
 END//
 
+-- Dumping structure for table sweetiebot.filterhits
+CREATE TABLE IF NOT EXISTS `filterhits` (
+  `ID` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `Guild` bigint(20) unsigned NOT NULL,
+  `Filter` varchar(128) NOT NULL,
+  `Word` varchar(255) NOT NULL DEFAULT '',
+  `User` bigint(20) unsigned NOT NULL,
+  `CaseNumber` bigint(20) unsigned DEFAULT NULL,
+  `FalsePositive` tinyint(1) NOT NULL DEFAULT 0,
+  `Timestamp` datetime NOT NULL,
+  PRIMARY KEY (`ID`),
+  KEY `INDEX_GUILD_TIMESTAMP` (`Guild`,`Timestamp`),
+  KEY `INDEX_GUILD_CASE` (`Guild`,`CaseNumber`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Every time a word in a filter matched a message, and whether a moderator said it was a false positive.'//
+
+-- Data exporting was unselected.
 -- Dumping structure for table sweetiebot.items
 CREATE TABLE IF NOT EXISTS `items` (
   `ID` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
@@ -455,6 +490,20 @@ CREATE TABLE IF NOT EXISTS `itemtags` (
   CONSTRAINT `FK_itemtags_tags` FOREIGN KEY (`Tag`) REFERENCES `tags` (`ID`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4//
 
+-- Data exporting was unselected.
+-- Dumping structure for table sweetiebot.lockdown
+CREATE TABLE IF NOT EXISTS `lockdown` (
+  `Guild` bigint(20) unsigned NOT NULL,
+  `Type` tinyint(3) unsigned NOT NULL,
+  `Channel` bigint(20) unsigned NOT NULL,
+  `Value` int(11) NOT NULL DEFAULT 0,
+  `Overwrite` tinyint(1) NOT NULL DEFAULT 0,
+  `Allow` bigint(20) NOT NULL DEFAULT 0,
+  `Deny` bigint(20) NOT NULL DEFAULT 0,
+  `Ends` datetime DEFAULT NULL,
+  PRIMARY KEY (`Guild`,`Type`,`Channel`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Everything a lockdown changed, along with what it was before, so it can be undone.'//
+
 -- Data exporting was unselected.
 -- Dumping structure for table sweetiebot.markov_transcripts_speaker
 CREATE TABLE IF NOT EXISTS `markov_transcripts_speaker` (
@@ -548,6 +597,10 @@ DELETE FROM `chatlog` WHERE Guild = _guild;
 DELETE FROM `debuglog` WHERE Guild = _guild;
 DELETE FROM `editlog` WHERE Guild = _guild;
 DELETE FROM `tags` WHERE Guild = _guild;
+DELETE FROM `cases` WHERE Guild = _guild;
+DELETE FROM `lockdown` WHERE Guild = _guild;
+DELETE FROM `filterhits` WHERE Guild = _guild;
+DELETE FROM `rsvps` WHERE Guild = _guild;
 
 END//
 
@@ -588,6 +641,17 @@ UPDATE markov_transcripts SET ID = 0 WHERE ID = 1;
 
 END//
 
+-- Dumping structure for table sweetiebot.rsvps
+CREATE TABLE IF NOT EXISTS `rsvps` (
+  `Event` bigint(20) unsigned NOT NULL,
+  `User` bigint(20) unsigned NOT NULL,
+  `Guild` bigint(20) unsigned NOT NULL,
+  `Reminded` datetime NOT NULL,
+  PRIMARY KEY (`Event`,`User`),
+  KEY `INDEX_GUILD` (`Guild`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Who is going to each event in the schedule, and when they were last reminded about it.'//
+
+-- Data exporting was unselected.
 -- Dumping structure for table sweetiebot.schedule
 CREATE TABLE IF NOT EXISTS `schedule` (
   `ID` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
@@ -597,6 +661,13 @@ CREATE TABLE IF NOT EXISTS `schedule` (
   `Repeat` int(11) DEFAULT NULL,
   `Type` tinyint(3) unsigned NOT NULL,
   `Data` text NOT NULL,
+  `Channel` bigint(20) unsigned DEFAULT NULL,
+  `Recurrence` varchar(1024) DEFAULT NULL,
+  `RSVPMessage` bigint(20) unsigned DEFAULT NULL,
+  `State` tinyint(3) unsigned NOT NULL DEFAULT 0,
+  `Attempts` int(10) unsigned NOT NULL DEFAULT 0,
+  `RetryAt` datetime DEFAULT NULL,
+  `LastError` varchar(1024) DEFAULT NULL,
   PRIMARY KEY (`ID`),
   KEY `INDEX_GUILD_DATE_TYPE` (`Date`,`Guild`,`Type`),
   KEY `INDEX_GUILD` (`Guild`)
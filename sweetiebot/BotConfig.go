@@ -31,6 +31,7 @@ type BotConfig struct {
 		ListenToBots          bool                    `json:"listentobots"`
 		CommandPrefix         string                  `json:"commandprefix"`
 		SilenceRole           DiscordRole             `json:"silencerole"`
+		SlashCommands         bool                    `json:"slashcommands"`
 	} `json:"basic"`
 	Modules struct {
 		Channels           map[ModuleID]map[DiscordChannel]bool  `json:"modulechannels"`
@@ -43,30 +44,40 @@ type BotConfig struct {
 		CommandMaxDuration int64                                 `json:"commandmaxduration"`
 	} `json:"modules"`
 	Spam struct {
-		ImagePressure      float32                    `json:"imagepressure"`
-		PingPressure       float32                    `json:"pingpressure"`
-		LengthPressure     float32                    `json:"lengthpressure"`
-		RepeatPressure     float32                    `json:"repeatpressure"`
-		LinePressure       float32                    `json:"linepressure"`
-		BasePressure       float32                    `json:"basepressure"`
-		PressureDecay      float32                    `json:"pressuredecay"`
-		MaxPressure        float32                    `json:"maxpressure"`
-		MaxChannelPressure map[DiscordChannel]float32 `json:"maxchannelpressure"`
-		MaxRemoveLookback  int                        `json:"MaxSpamRemoveLookback"`
-		IgnoreRole         DiscordRole                `json:"ignorerole"`
-		RaidTime           int64                      `json:"maxraidtime"`
-		RaidSize           int                        `json:"raidsize"`
-		AutoSilence        int                        `json:"autosilence"`
-		LockdownDuration   int                        `json:"lockdownduration"`
+		ImagePressure        float32                    `json:"imagepressure"`
+		PingPressure         float32                    `json:"pingpressure"`
+		LengthPressure       float32                    `json:"lengthpressure"`
+		RepeatPressure       float32                    `json:"repeatpressure"`
+		LinePressure         float32                    `json:"linepressure"`
+		BasePressure         float32                    `json:"basepressure"`
+		PressureDecay        float32                    `json:"pressuredecay"`
+		MaxPressure          float32                    `json:"maxpressure"`
+		MaxChannelPressure   map[DiscordChannel]float32 `json:"maxchannelpressure"`
+		MaxRemoveLookback    int                        `json:"MaxSpamRemoveLookback"`
+		IgnoreRole           DiscordRole                `json:"ignorerole"`
+		RaidTime             int64                      `json:"maxraidtime"`
+		RaidSize             int                        `json:"raidsize"`
+		AutoSilence          int                        `json:"autosilence"`
+		LockdownDuration     int                        `json:"lockdownduration"`
+		TimeoutDuration      int64                      `json:"timeoutduration"`
+		DuplicatePressure    float32                    `json:"duplicatepressure"`
+		DuplicateLookback    int                        `json:"duplicatelookback"`
+		CrossChannelPressure float32                    `json:"crosschannelpressure"`
+		CopypastaPressure    float32                    `json:"copypastapressure"`
+		ZalgoPressure        float32                    `json:"zalgopressure"`
+		EmojiPressure        float32                    `json:"emojipressure"`
+		InvitePressure       float32                    `json:"invitepressure"`
+		DryRun               bool                       `json:"dryrun"`
 	} `json:"spam"`
 	Users struct {
-		TimezoneLocation string               `json:"timezonelocation"`
-		WelcomeChannel   DiscordChannel       `json:"welcomechannel"`
-		WelcomeMessage   string               `json:"welcomemessage"`
-		SilenceMessage   string               `json:"silencemessage"`
-		Roles            map[DiscordRole]bool `json:"userroles"`
-		NotifyChannel    DiscordChannel       `json:"joinchannel"`
-		TrackUserLeft    bool                 `json:"trackuserleft"`
+		TimezoneLocation string                                 `json:"timezonelocation"`
+		WelcomeChannel   DiscordChannel                         `json:"welcomechannel"`
+		WelcomeMessage   string                                 `json:"welcomemessage"`
+		SilenceMessage   string                                 `json:"silencemessage"`
+		Roles            map[DiscordRole]bool                   `json:"userroles"`
+		NotifyChannel    DiscordChannel                         `json:"joinchannel"`
+		TrackUserLeft    bool                                   `json:"trackuserleft"`
+		Escalation       map[EscalationTrigger]EscalationAction `json:"escalation"`
 	} `json:"users"`
 	Bucket struct {
 		MaxItems       int             `json:"maxbucket"`
@@ -82,10 +93,12 @@ type BotConfig struct {
 		UseMemberNames bool `json:"usemembernames"`
 	} `json:"markov"`
 	Filter struct {
-		Filters   map[string]map[string]bool         `json:"filters"`
-		Channels  map[string]map[DiscordChannel]bool `json:"channels"`
-		Responses map[string]string                  `json:"responses"`
-		Templates map[string]string                  `json:"templates"`
+		Filters     map[string]map[string]bool         `json:"filters"`
+		Channels    map[string]map[DiscordChannel]bool `json:"channels"`
+		ExemptRoles map[string]map[DiscordRole]bool    `json:"exemptroles"`
+		Responses   map[string]string                  `json:"responses"`
+		Templates   map[string]string                  `json:"templates"`
+		Actions     map[string]FilterAction            `json:"actions"`
 	} `json:"filter"`
 	Bored struct {
 		Cooldown int64           `json:"maxbored"`
@@ -98,6 +111,7 @@ type BotConfig struct {
 	Log struct {
 		Cooldown int64          `json:"maxerror"`
 		Channel  DiscordChannel `json:"logchannel"`
+		Level    LogLevel       `json:"loglevel"`
 	} `json:"log"`
 	Witty struct {
 		Responses map[string]string `json:"witty"`
@@ -116,6 +130,7 @@ type BotConfig struct {
 	Quote struct {
 		Quotes map[DiscordUser][]string `json:"quotes"`
 	} `json:"quote"`
+	sections map[string]interface{} // Categories registered by modules, keyed by their lowercase name
 }
 
 // ConfigHelp is a map of help strings for the configuration options above
@@ -131,6 +146,7 @@ var ConfigHelp = map[string]map[string]string{
 		"listentobots":          "If true, processes messages from other bots and allows them to run commands. Bots can never trigger anti-spam. Defaults to false.",
 		"commandprefix":         "Determines the SINGLE ASCII CHARACTER prefix used to denote bot commands. You can't set it to an emoji or any weird foreign character. The default is `!`. If this is set to an invalid value, it defaults to `!`.",
 		"silencerole":           "This should be a role with no permissions, so the bot can quarantine potential spammers without banning them.",
+		"slashcommands":         "If true, every enabled command is also registered as a discord slash command, so it can be run by typing `/` followed by the command name. Slash commands obey the same restrictions as normal commands. Defaults to true.",
 	},
 	"modules": {
 		"commandroles":       "A map of which roles are allowed to run which command. If no mapping exists, everyone can run the command.",
@@ -143,21 +159,30 @@ var ConfigHelp = map[string]map[string]string{
 		"channels":           "A mapping of what channels a given module can operate on. If no mapping is given, a module operates on all channels. If `!` is included as a channel, it switches from a whitelist to a blacklist, enabling you to exclude certain channels instead of allow certain channels.",
 	},
 	"spam": {
-		"imagepressure":      "Additional pressure generated by each image, link or attachment in a message. Defaults to (MaxPressure - BasePressure) / 6 = 8.3, instantly silencing anyone posting 6 or more links at once.",
-		"repeatpressure":     "Additional pressure generated by a message that is identical to the previous message sent (ignores case). Defaults to BasePressure, effectively doubling the pressure penalty for repeated messages.",
-		"pingpressure":       "Additional pressure generated by each unique ping in a message. Defaults to (MaxPressure - BasePressure) / 20 = 2.5, instantly silencing anyone pinging 20 or more people at once.",
-		"lengthpressure":     "Additional pressure generated by each individual character in the message. Discord allows messages up to 2000 characters in length. Defaults to (MaxPressure - BasePressure) / 8000 = 0.00625, silencing anyone posting 3 huge messages at the same time.",
-		"linepressure":       "Additional pressure generated by each newline in the message. Defaults to (MaxPressure - BasePressure) / 70 = 0.714, silencing anyone posting more than 70 newlines in a single message",
-		"basepressure":       "The base pressure generated by sending a message, regardless of length or content. Defaults to 10",
-		"maxpressure":        "The maximum pressure allowed. If a user's pressure exceeds this amount, they will be silenced. Defaults to 60, which is intended to ban after a maximum of 6 short messages sent in rapid succession.",
-		"maxchannelpressure": "Per-channel pressure override. If a channel's pressure is specified in this map, it will override the global maxpressure setting.",
-		"pressuredecay":      "The number of seconds it takes for a user to lose Spam.BasePressure from their pressure amount. Defaults to 2.5, so after sending 3 messages, it will take 7.5 seconds for their pressure to return to 0.",
-		"maxremovelookback":  "Number of seconds back the bot should delete messages of a silenced user on the channel they spammed on. If set to 0, the bot will only delete the message that caused the user to be silenced. If less than 0, the bot won't delete any messages.",
-		"ignorerole":         "If set, the bot will exclude anyone with this role from spam detection. Use with caution.",
-		"raidtime":           "In order to trigger a raid alarm, at least `spam.raidsize` people must join the chat within this many seconds of each other.",
-		"raidsize":           "Specifies how many people must have joined the server within the `spam.raidtime` period to qualify as a raid.",
-		"autosilence":        "Gets the current autosilence state. Use the `!autosilence` command to set this.",
-		"lockdownduration":   "Determines how long the server's verification mode will temporarily be increased to tableflip levels after a raid is detected. If set to 0, disables lockdown entirely.",
+		"imagepressure":        "Additional pressure generated by each image, link or attachment in a message. Defaults to (MaxPressure - BasePressure) / 6 = 8.3, instantly silencing anyone posting 6 or more links at once.",
+		"repeatpressure":       "Additional pressure generated by a message that is identical to the previous message sent (ignores case). Defaults to BasePressure, effectively doubling the pressure penalty for repeated messages.",
+		"pingpressure":         "Additional pressure generated by each unique ping in a message. Defaults to (MaxPressure - BasePressure) / 20 = 2.5, instantly silencing anyone pinging 20 or more people at once.",
+		"lengthpressure":       "Additional pressure generated by each individual character in the message. Discord allows messages up to 2000 characters in length. Defaults to (MaxPressure - BasePressure) / 8000 = 0.00625, silencing anyone posting 3 huge messages at the same time.",
+		"linepressure":         "Additional pressure generated by each newline in the message. Defaults to (MaxPressure - BasePressure) / 70 = 0.714, silencing anyone posting more than 70 newlines in a single message",
+		"basepressure":         "The base pressure generated by sending a message, regardless of length or content. Defaults to 10",
+		"maxpressure":          "The maximum pressure allowed. If a user's pressure exceeds this amount, they will be silenced. Defaults to 60, which is intended to ban after a maximum of 6 short messages sent in rapid succession.",
+		"maxchannelpressure":   "Per-channel pressure override. If a channel's pressure is specified in this map, it will override the global maxpressure setting.",
+		"pressuredecay":        "The number of seconds it takes for a user to lose Spam.BasePressure from their pressure amount. Defaults to 2.5, so after sending 3 messages, it will take 7.5 seconds for their pressure to return to 0.",
+		"maxremovelookback":    "Number of seconds back the bot should delete messages of a silenced user on the channel they spammed on. If set to 0, the bot will only delete the message that caused the user to be silenced. If less than 0, the bot won't delete any messages.",
+		"ignorerole":           "If set, the bot will exclude anyone with this role from spam detection. Use with caution.",
+		"raidtime":             "In order to trigger a raid alarm, at least `spam.raidsize` people must join the chat within this many seconds of each other.",
+		"raidsize":             "Specifies how many people must have joined the server within the `spam.raidtime` period to qualify as a raid.",
+		"autosilence":          "Gets the current autosilence state. Use the `!autosilence` command to set this.",
+		"lockdownduration":     "Determines how long the server's verification mode will temporarily be increased to tableflip levels after a raid is detected. If set to 0, disables lockdown entirely.",
+		"timeoutduration":      "If greater than 0, spammers and raiders are given a native discord timeout for this many seconds instead of the silence role, so servers don't need to maintain one. If a timeout fails, the silence role is used instead if there is one. Discord doesn't allow timeouts longer than 28 days (2419200 seconds).",
+		"duplicatepressure":    "Additional pressure generated for each of the user's last `spam.duplicatelookback` messages that is nearly the same as the new one, ignoring case, punctuation, spacing and small changes. Only messages sent in the past 2 minutes that are at least 10 letters long are compared. Defaults to BasePressure / 2 = 5.",
+		"duplicatelookback":    "How many of each user's recent messages are compared against their new messages to find near-duplicates. Defaults to 5. If set to 0, disables duplicate, cross-channel and copypasta detection.",
+		"crosschannelpressure": "Additional pressure generated for each other channel the user posted a near-duplicate of the message in during the past 2 minutes. Defaults to (MaxPressure - BasePressure) / 4 = 12.5, silencing anyone posting the same thing in 4 channels at once.",
+		"copypastapressure":    "Additional pressure generated for each other user that posted a near-duplicate of the message in the past 2 minutes, to catch waves of copypasta. Defaults to (MaxPressure - BasePressure) / 10 = 5.",
+		"zalgopressure":        "Additional pressure generated by each combining character stacked on top of another one, which is how zalgo text is made. Defaults to (MaxPressure - BasePressure) / 40 = 1.25.",
+		"emojipressure":        "Additional pressure generated by each emoji in the message, including custom emojis. Defaults to (MaxPressure - BasePressure) / 40 = 1.25, silencing anyone posting 40 or more emojis at once.",
+		"invitepressure":       "Additional pressure generated by each discord invite link in the message. Defaults to (MaxPressure - BasePressure) / 2 = 25, silencing anyone posting two invites at once.",
+		"dryrun":               "If true, the bot still calculates everyone's pressure, but instead of silencing spammers, it tells the mod channel who would have been silenced. Use this with `!replayspam` to tune the spam settings before enforcing them.",
 	},
 	"bucket": {
 		"maxitems":       "Determines the maximum number of items that can be carried in the bucket. If set to 0, the bucket is disabled.",
@@ -180,12 +205,15 @@ var ConfigHelp = map[string]map[string]string{
 		"roles":            "A list of all user-assignable roles. Manage it via !addrole and !removerole",
 		"notifychannel":    "If set to a channel ID other than zero, sends a message to that channel whenever a new user joins the server.",
 		"trackuserleft":    "If true, tracks users that leave the server if notifychannel is set.",
+		"escalation":       "Rules that automatically punish users with too many cases. Each key is a trigger like `3 filter in 1 day` (the case types are warning, silence, unsilence, timeout, kick, ban and filter), and each value is a punishment like `silence for 10 minutes`, `timeout for 1 hour`, `kick` or `ban for 1 week`. Leave off the duration to make a silence or ban permanent, or a timeout last as long as discord allows (28 days). Pardoned cases don't count. Example: `!setconfig users.escalation \"2 silence in 1 week\" ban for 1 day`",
 	},
 	"filter": {
-		"filters":   "A collection of word lists for each filter. These are combined into a single regex of the form `(word1|word2|etc...)`, depending on the filter template.",
-		"channels":  "A collection of channel exclusions for each filter.",
-		"responses": "The response message sent by each filter when triggered.",
-		"templates": "The template used to construct the regex. `%%` is replaced with `(word1|word2|etc...)` using the filter's word list. Example: `\\[\\]\\(\\/r?%%[-) \"]` is transformed into `\\[\\]\\(\\/r?(word1|word2)[-) \"]`",
+		"filters":     "A collection of word lists for each filter. These are combined into a single regex of the form `(word1|word2|etc...)`, depending on the filter template.",
+		"channels":    "A collection of channel exclusions for each filter.",
+		"exemptroles": "A collection of roles for each filter whose members are never filtered by it.",
+		"responses":   "The response message sent by each filter when triggered.",
+		"templates":   "The template used to construct the regex. `%%` is replaced with `(word1|word2|etc...)` using the filter's word list. Example: `\\[\\]\\(\\/r?%%[-) \"]` is transformed into `\\[\\]\\(\\/r?(word1|word2)[-) \"]`",
+		"actions":     "What each filter does when it matches a message: `log` only reports it in the log channel, `notify` reports it in the mod channel, `delete` removes it, `warn` removes it and warns the author, and `silence` removes it and silences the author. Filters without an action delete messages. If several filters match, the harshest action is used. Example: `!setconfig filter.actions spoilers notify`",
 	},
 	"bored": {
 		"cooldown": "The bored cooldown timer, in seconds. This is the length of time a channel must be inactive before a bored message is posted.",
@@ -198,6 +226,7 @@ var ConfigHelp = map[string]map[string]string{
 	"log": {
 		"channel":  "This is the channel where log output is sent.",
 		"cooldown": "The cooldown time to display an error message, in seconds, intended to prevent the bot from spamming itself. Default: 4",
+		"level":    "The least severe log messages that are sent to the log channel: `debug`, `info`, `warning`, `error` or `none`. Default: info",
 	},
 	"witty": {
 		"responses": "Stores the replies used by the Witty module and must be configured using `!addwit` or `!removewit`",
@@ -221,6 +250,132 @@ var ConfigHelp = map[string]map[string]string{
 	},
 }
 
+// configSection is a config category registered by a module instead of being declared in BotConfig
+type configSection struct {
+	name   string
+	key    string
+	create func() interface{}
+}
+
+var configSections []configSection
+
+// RegisterConfig adds a config category to every guild's configuration. create must return a pointer to a struct
+// containing the default values, and help maps the option names to their help strings. The category is saved under
+// its lowercase name in the config file, and modules can retrieve it with BotConfig.Section. This must be called
+// from an init function so the category exists before any config files are loaded.
+func RegisterConfig(name string, create func() interface{}, help map[string]string) {
+	key := strings.ToLower(name)
+	if v := reflect.ValueOf(create()); v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		panic("Config category " + name + " must be a pointer to a struct")
+	}
+	t := reflect.TypeOf(BotConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if strings.ToLower(t.Field(i).Name) == key || strings.ToLower(tag) == key {
+			panic("Config category " + name + " conflicts with a builtin config option")
+		}
+	}
+	for _, s := range configSections {
+		if s.key == key {
+			panic("Config category " + name + " was registered twice")
+		}
+	}
+	configSections = append(configSections, configSection{name, key, create})
+	ConfigHelp[key] = make(map[string]string, len(help))
+	for k, v := range help {
+		ConfigHelp[key][strings.ToLower(k)] = v
+	}
+}
+
+// Section returns the config category registered under the given name, which should be type asserted to the
+// struct returned by that category's create function. Returns nil if no category with that name was registered.
+func (config *BotConfig) Section(name string) interface{} {
+	key := strings.ToLower(name)
+	if s, ok := config.sections[key]; ok {
+		return s
+	}
+	for _, s := range configSections {
+		if s.key == key {
+			return s.create() // This config was never filled, so all we can give back are the defaults
+		}
+	}
+	return nil
+}
+
+// configField is a top-level config option, which is usually a category of options
+type configField struct {
+	Name  string
+	Value reflect.Value
+}
+
+// fields returns the top-level config options declared in BotConfig followed by the categories registered by modules
+func (config *BotConfig) fields() []configField {
+	t := reflect.ValueOf(config).Elem()
+	fields := make([]configField, 0, t.NumField()+len(configSections))
+	for i := 0; i < t.NumField(); i++ {
+		if len(t.Type().Field(i).PkgPath) == 0 { // skip unexported fields
+			fields = append(fields, configField{t.Type().Field(i).Name, t.Field(i)})
+		}
+	}
+	for _, s := range configSections {
+		if v, ok := config.sections[s.key]; ok {
+			fields = append(fields, configField{s.name, reflect.ValueOf(v).Elem()})
+		}
+	}
+	return fields
+}
+
+// botConfigFields has the same fields as BotConfig but none of its methods, so the json package can encode it normally
+type botConfigFields BotConfig
+
+// MarshalJSON writes each registered config category as a top-level object next to the builtin ones
+func (config BotConfig) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(botConfigFields(config))
+	if err != nil || len(config.sections) == 0 {
+		return data, err
+	}
+	raw := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	for k, v := range config.sections {
+		if raw[k], err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON reads the builtin config options, then any registered config categories present in the data
+func (config *BotConfig) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*botConfigFields)(config)); err != nil {
+		return err
+	}
+	if len(configSections) == 0 {
+		return nil
+	}
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if config.sections == nil {
+		config.sections = make(map[string]interface{})
+	}
+	for _, s := range configSections {
+		v, ok := config.sections[s.key]
+		if !ok {
+			v = s.create()
+			config.sections[s.key] = v
+		}
+		if r, ok := raw[s.key]; ok {
+			if err := json.Unmarshal(r, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func getConfigHelp(module string, option string) (string, bool) {
 	x, ok := ConfigHelp[strings.ToLower(module)]
 	if !ok {
@@ -230,7 +385,7 @@ func getConfigHelp(module string, option string) (string, bool) {
 	return s, b
 }
 
-// ConfigVersion is the latest version of the config file
+// ConfigVersion is the latest version of the config file, which must be the version of the last entry in Migrations
 var ConfigVersion = 24
 
 // DefaultConfig returns a default BotConfig struct. We can't define this as a variable because you can't initialize nested structs in a sane way in Go
@@ -243,6 +398,7 @@ func DefaultConfig() *BotConfig {
 	config.Basic.IgnoreInvalidCommands = false
 	config.Basic.Importable = false
 	config.Basic.CommandPrefix = "!"
+	config.Basic.SlashCommands = true
 	config.Modules.CommandPerDuration = 3
 	config.Modules.CommandMaxDuration = 15
 	config.Spam.MaxPressure = 60
@@ -258,6 +414,13 @@ func DefaultConfig() *BotConfig {
 	config.Spam.RaidSize = 4
 	config.Spam.AutoSilence = 1 // Default to raid mode
 	config.Spam.LockdownDuration = 120
+	config.Spam.DuplicatePressure = config.Spam.BasePressure / 2
+	config.Spam.DuplicateLookback = 5
+	config.Spam.CrossChannelPressure = (config.Spam.MaxPressure - config.Spam.BasePressure) / 4
+	config.Spam.CopypastaPressure = (config.Spam.MaxPressure - config.Spam.BasePressure) / 10
+	config.Spam.ZalgoPressure = (config.Spam.MaxPressure - config.Spam.BasePressure) / 40
+	config.Spam.EmojiPressure = (config.Spam.MaxPressure - config.Spam.BasePressure) / 40
+	config.Spam.InvitePressure = (config.Spam.MaxPressure - config.Spam.BasePressure) / 2
 	config.Bucket.MaxItems = 10
 	config.Bucket.MaxItemLength = 100
 	config.Bucket.MaxFightHP = 300
@@ -269,32 +432,37 @@ func DefaultConfig() *BotConfig {
 	config.Bored.Cooldown = 500
 	config.Bored.Commands = map[string]bool{"!quote": true, "!drop": true}
 	config.Log.Cooldown = 4
+	config.Log.Level = LogInfo
 	config.Witty.Cooldown = 180
 	config.Miscellaneous.MaxSearchResults = 10
 	config.Status.Cooldown = 3600
+	config.sections = make(map[string]interface{}, len(configSections))
+	for _, s := range configSections {
+		config.sections[s.key] = s.create()
+	}
 
 	return config
 }
 
 // FixRequest takes a request that is not fully qualified and attempts to find a fully qualified version
-func FixRequest(arg string, t reflect.Value) (string, error) {
+func (config *BotConfig) FixRequest(arg string) (string, error) {
 	args := strings.SplitN(strings.ToLower(arg), ".", 3)
 	list := []string{}
-	n := t.NumField()
+	fields := config.fields()
 
-	for i := 0; i < n; i++ {
-		if strings.ToLower(t.Type().Field(i).Name) == args[0] {
+	for _, field := range fields {
+		if strings.ToLower(field.Name) == args[0] {
 			return arg, nil
 		}
 	}
 
-	for i := 0; i < n; i++ {
-		switch t.Field(i).Kind() {
+	for _, field := range fields {
+		switch field.Value.Kind() {
 		case reflect.Struct:
-			f := t.Field(i)
+			f := field.Value
 			for j := 0; j < f.NumField(); j++ {
 				if strings.ToLower(f.Type().Field(j).Name) == args[0] {
-					list = append(list, t.Type().Field(i).Name)
+					list = append(list, field.Name)
 				}
 			}
 		}
@@ -350,6 +518,48 @@ func setConfigValue(f reflect.Value, value string, info *GuildInfo) error {
 			return fmt.Errorf("%s is not a command name!", value)
 		}
 		f.SetString(value)
+	case LogLevel:
+		l, err := ParseLogLevel(value)
+		if err != nil {
+			return err
+		}
+		f.SetUint(uint64(l))
+	case EscalationTrigger:
+		t, err := ParseEscalationTrigger(value)
+		if err != nil {
+			return err
+		}
+		f.SetString(string(t))
+	case EscalationAction:
+		a, err := ParseEscalationAction(value)
+		if err != nil {
+			return err
+		}
+		f.SetString(string(a))
+	case ScreenAction:
+		a, err := ParseScreenAction(value)
+		if err != nil {
+			return err
+		}
+		f.SetString(string(a))
+	case FilterAction:
+		a, err := ParseFilterAction(value)
+		if err != nil {
+			return err
+		}
+		f.SetString(string(a))
+	case ReminderOffsets:
+		r, err := ParseReminderOffsets(value)
+		if err != nil {
+			return err
+		}
+		f.SetString(string(r))
+	case MissedEventPolicy:
+		p, err := ParseMissedEventPolicy(value)
+		if err != nil {
+			return err
+		}
+		f.SetString(string(p))
 	case int, int8, int16, int32, int64:
 		k, err := strconv.ParseInt(value, 10, 64)
 		if err != nil {
@@ -463,19 +673,18 @@ func setConfigMapList(f reflect.Value, key string, values []string, info *GuildI
 func (config *BotConfig) SetConfig(info *GuildInfo, args []string, indices []int, message string) (string, bool) {
 	name := args[0]
 	names := strings.SplitN(strings.ToLower(name), ".", 3)
-	t := reflect.ValueOf(config).Elem()
-	for i := 0; i < t.NumField(); i++ {
-		if strings.ToLower(t.Type().Field(i).Name) == names[0] {
+	for _, field := range config.fields() {
+		if strings.ToLower(field.Name) == names[0] {
 			if len(names) < 2 {
 				return "Can't set a configuration category! Use \"Category.Option\" to set a specific option.", false
 			}
-			switch t.Field(i).Kind() {
+			switch field.Value.Kind() {
 			case reflect.Struct:
-				for j := 0; j < t.Field(i).NumField(); j++ {
-					if strings.ToLower(t.Field(i).Type().Field(j).Name) == names[1] {
-						f := t.Field(i).Field(j)
+				for j := 0; j < field.Value.NumField(); j++ {
+					if strings.ToLower(field.Value.Type().Field(j).Name) == names[1] {
+						f := field.Value.Field(j)
 						switch f.Interface().(type) {
-						case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, float32, float64, uint64, DiscordChannel, DiscordRole, DiscordUser:
+						case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, float32, float64, uint64, DiscordChannel, DiscordRole, DiscordUser, LogLevel, ScreenAction, FilterAction, ReminderOffsets, MissedEventPolicy:
 							value := ""
 							if len(indices) > 1 {
 								value = message[indices[1]:]
@@ -497,7 +706,7 @@ func (config *BotConfig) SetConfig(info *GuildInfo, args []string, indices []int
 							default:
 								return name + " must be set to either 'true' or 'false'", false
 							}
-						case map[string]string, map[CommandID]int64, map[DiscordChannel]float32, map[int]string:
+						case map[string]string, map[CommandID]int64, map[DiscordChannel]float32, map[int]string, map[EscalationTrigger]EscalationAction, map[string]FilterAction:
 							if len(indices) < 2 {
 								return "No key parameter given", false
 							}
@@ -506,7 +715,7 @@ func (config *BotConfig) SetConfig(info *GuildInfo, args []string, indices []int
 								value = message[indices[2]:]
 							}
 							return setConfigKeyValue(f, strings.ToLower(args[1]), value, info)
-						case map[string]map[DiscordChannel]bool, map[CommandID]map[DiscordRole]bool, map[string]map[string]bool, map[DiscordUser][]string, map[CommandID]map[DiscordChannel]bool, map[ModuleID]map[DiscordChannel]bool:
+						case map[string]map[DiscordChannel]bool, map[CommandID]map[DiscordRole]bool, map[string]map[string]bool, map[DiscordUser][]string, map[CommandID]map[DiscordChannel]bool, map[ModuleID]map[DiscordChannel]bool, map[string]map[DiscordRole]bool, map[DiscordChannel]map[string]bool:
 							if len(indices) < 2 {
 								return "No key parameter given", false
 							}
@@ -603,11 +812,11 @@ func getConfigMapList(f reflect.Value, state *discordgo.State, guild string) (s
 
 func (config *BotConfig) GetConfig(f reflect.Value, state *discordgo.State, guild string) (s []string) {
 	switch f.Interface().(type) {
-	case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, float32, float64, uint64, DiscordChannel, DiscordRole, DiscordUser, ModuleID, CommandID, bool:
+	case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, float32, float64, uint64, DiscordChannel, DiscordRole, DiscordUser, ModuleID, CommandID, bool, LogLevel, ScreenAction, FilterAction, ReminderOffsets, MissedEventPolicy:
 		s = append(s, getConfigValue(f, state, guild))
-	case map[DiscordChannel]bool, map[string]bool, map[DiscordRole]bool, map[string]string, map[CommandID]int64, map[DiscordChannel]float32, map[int]string, map[CommandID]bool, map[ModuleID]bool:
+	case map[DiscordChannel]bool, map[string]bool, map[DiscordRole]bool, map[string]string, map[CommandID]int64, map[DiscordChannel]float32, map[int]string, map[EscalationTrigger]EscalationAction, map[string]FilterAction, map[CommandID]bool, map[ModuleID]bool:
 		s = getConfigList(f, state, guild)
-	case map[string]map[DiscordChannel]bool, map[CommandID]map[DiscordRole]bool, map[string]map[string]bool, map[DiscordUser][]string, map[CommandID]map[DiscordChannel]bool, map[ModuleID]map[DiscordChannel]bool:
+	case map[string]map[DiscordChannel]bool, map[CommandID]map[DiscordRole]bool, map[string]map[string]bool, map[DiscordUser][]string, map[CommandID]map[DiscordChannel]bool, map[ModuleID]map[DiscordChannel]bool, map[string]map[DiscordRole]bool, map[DiscordChannel]map[string]bool:
 		s = getConfigMapList(f, state, guild)
 	default:
 		data, err := json.Marshal(f.Interface())
@@ -638,662 +847,36 @@ func (config *BotConfig) IsCommandDisabled(command Command) (str string) {
 	return
 }
 
-// FillConfig ensures root maps are not nil
+// FillConfig ensures root maps are not nil and that every registered config category exists
 func (config *BotConfig) FillConfig() {
-	if len(config.Basic.FreeChannels) == 0 {
-		config.Basic.FreeChannels = make(map[DiscordChannel]bool)
-	}
-	if len(config.Basic.Aliases) == 0 {
-		config.Basic.Aliases = make(map[string]string)
-	}
-	if len(config.Modules.Channels) == 0 {
-		config.Modules.Channels = make(map[ModuleID]map[DiscordChannel]bool)
-	}
-	if len(config.Modules.Disabled) == 0 {
-		config.Modules.Disabled = make(map[ModuleID]bool)
-	}
-	if len(config.Modules.CommandRoles) == 0 {
-		config.Modules.CommandRoles = make(map[CommandID]map[DiscordRole]bool)
-	}
-	if len(config.Modules.CommandChannels) == 0 {
-		config.Modules.CommandChannels = make(map[CommandID]map[DiscordChannel]bool)
-	}
-	if len(config.Modules.CommandLimits) == 0 {
-		config.Modules.CommandLimits = make(map[CommandID]int64)
-	}
-	if len(config.Modules.CommandDisabled) == 0 {
-		config.Modules.CommandDisabled = make(map[CommandID]bool)
-	}
-	if len(config.Spam.MaxChannelPressure) == 0 {
-		config.Spam.MaxChannelPressure = make(map[DiscordChannel]float32)
-	}
-	if len(config.Users.Roles) == 0 {
-		config.Users.Roles = make(map[DiscordRole]bool)
-	}
-	if len(config.Bucket.Items) == 0 {
-		config.Bucket.Items = make(map[string]bool)
-	}
-	if len(config.Filter.Filters) == 0 {
-		config.Filter.Filters = make(map[string]map[string]bool)
-	}
-	if len(config.Filter.Channels) == 0 {
-		config.Filter.Channels = make(map[string]map[DiscordChannel]bool)
-	}
-	if len(config.Filter.Responses) == 0 {
-		config.Filter.Responses = make(map[string]string)
-	}
-	if len(config.Filter.Templates) == 0 {
-		config.Filter.Templates = make(map[string]string)
-	}
-	if len(config.Bored.Commands) == 0 {
-		config.Bored.Commands = make(map[string]bool)
-	}
-	if len(config.Information.Rules) == 0 {
-		config.Information.Rules = make(map[int]string)
-	}
-	if len(config.Witty.Responses) == 0 {
-		config.Witty.Responses = make(map[string]string)
-	}
-	if len(config.Status.Lines) == 0 {
-		config.Status.Lines = make(map[string]bool)
-	}
-	if len(config.Quote.Quotes) == 0 {
-		config.Quote.Quotes = make(map[DiscordUser][]string)
-	}
-}
-
-type legacyBotConfig struct {
-	Version               int                        `json:"version"`
-	LastVersion           int                        `json:"lastversion"`
-	Maxerror              int64                      `json:"maxerror"`
-	Maxwit                int64                      `json:"maxwit"`
-	Maxbored              int64                      `json:"maxbored"`
-	BoredCommands         map[string]bool            `json:"boredcommands"`
-	MaxPMlines            int                        `json:"maxpmlines"`
-	Maxquotelines         int                        `json:"maxquotelines"`
-	Maxsearchresults      int                        `json:"maxsearchresults"`
-	Defaultmarkovlines    int                        `json:"defaultmarkovlines"`
-	Commandperduration    int                        `json:"commandperduration"`
-	Commandmaxduration    int64                      `json:"commandmaxduration"`
-	StatusDelayTime       int                        `json:"statusdelaytime"`
-	MaxRaidTime           int64                      `json:"maxraidtime"`
-	RaidSize              int                        `json:"raidsize"`
-	Witty                 map[string]string          `json:"witty"`
-	Aliases               map[string]string          `json:"aliases"`
-	MaxBucket             int                        `json:"maxbucket"`
-	MaxBucketLength       int                        `json:"maxbucketlength"`
-	MaxFightHP            int                        `json:"maxfighthp"`
-	MaxFightDamage        int                        `json:"maxfightdamage"`
-	MaxImageSpam          int                        `json:"maximagespam"`
-	MaxAttachSpam         int                        `json:"maxattachspam"`
-	MaxPingSpam           int                        `json:"maxpingspam"`
-	MaxMessageSpam        map[int64]int              `json:"maxmessagespam"`
-	MaxSpamRemoveLookback int                        `json:maxspamremovelookback`
-	IgnoreInvalidCommands bool                       `json:"ignoreinvalidcommands"`
-	UseMemberNames        bool                       `json:"usemembernames"`
-	Importable            bool                       `json:"importable"`
-	HideNegativeRules     bool                       `json:"hidenegativerules"`
-	Timezone              int                        `json:"timezone"`
-	TimezoneLocation      string                     `json:"timezonelocation"`
-	AutoSilence           int                        `json:"autosilence"`
-	AlertRole             uint64                     `json:"alertrole"`
-	SilentRole            uint64                     `json:"silentrole"`
-	LogChannel            uint64                     `json:"logchannel"`
-	ModChannel            uint64                     `json:"modchannel"`
-	WelcomeChannel        uint64                     `json:"welcomechannel"`
-	WelcomeMessage        string                     `json:"welcomemessage"`
-	SilenceMessage        string                     `json:"silencemessage"`
-	BirthdayRole          uint64                     `json:"birthdayrole"`
-	SpoilChannels         []uint64                   `json:"spoilchannels"`
-	FreeChannels          map[string]bool            `json:"freechannels"`
-	Command_roles         map[string]map[string]bool `json:"command_roles"`
-	Command_channels      map[string]map[string]bool `json:"command_channels"`
-	Command_limits        map[string]int64           `json:command_limits`
-	Command_disabled      map[string]bool            `json:command_disabled`
-	Module_disabled       map[string]bool            `json:module_disabled`
-	Module_channels       map[string]map[string]bool `json:module_channels`
-	Collections           map[string]map[string]bool `json:"collections"`
-	Groups                map[string]map[string]bool `json:"groups"`
-	Quotes                map[uint64][]string        `json:"quotes"`
-	Rules                 map[int]string             `json:"rules"`
-}
-
-type legacyBotConfigV10 struct {
-	Basic struct {
-		Commandperduration int   `json:"commandperduration"`
-		Commandmaxduration int64 `json:"commandmaxduration"`
-	} `json:"basic"`
-}
-
-type legacyBotConfigV12 struct {
-	Spam struct {
-		MaxImages int `json:"maximagespam"`
-		MaxPings  int `json:"maxpingspam"`
-	} `json:"spam"`
-}
-
-type legacyBotConfigV13 struct {
-	Basic struct {
-		Groups map[string]map[string]bool `json:"groups"`
-	} `json:"basic"`
-}
-
-type legacyBotConfigV19 struct {
-	Basic struct {
-		Collections map[string]map[string]bool `json:"collections"`
-	} `json:"basic"`
-}
-
-type legacyBotConfigV20 struct {
-	Collections map[string]map[string]bool `json:"collections"`
-	Spam        struct {
-		SilentRole     DiscordRole `json:"silentrole"`
-		SilenceMessage string      `json:"silencemessage"`
-	} `json:"spam"`
-	Basic struct {
-		AlertRole     DiscordRole `json:"alertrole"`
-		TrackUserLeft bool        `json:"trackuserleft"`
-	} `json:"basic"`
-	Search struct {
-		MaxResults int `json:"maxsearchresults"`
-	} `json:"search"`
-	Spoiler struct {
-		Channels []DiscordChannel `json:"spoilchannels"`
-	} `json:"spoiler"`
-	Schedule struct {
-		BirthdayRole DiscordRole `json:"birthdayrole"`
-	} `json:"schedule"`
-}
-
-func restrictCommand(v string, roles map[CommandID]map[DiscordRole]bool, modrole DiscordRole) {
-	id := CommandID(v)
-	_, ok := roles[id]
-	if !ok && modrole != "" {
-		roles[id] = make(map[DiscordRole]bool)
-		roles[id][modrole] = true
-	}
-}
-
-// MigrateSettings from earlier config version
-func (guild *GuildInfo) MigrateSettings(config []byte) error {
-	err := json.Unmarshal(config, &guild.Config)
-	if err != nil {
-		return err
-	}
-
-	if guild.Config.Version < 10 {
-		legacy := legacyBotConfig{}
-		err := json.Unmarshal(config, &legacy)
-		if err != nil {
-			return err
-		}
-
-		if legacy.Version == 0 {
-			if len(legacy.Command_roles) == 0 {
-				legacy.Command_roles = make(map[string]map[string]bool)
-			}
-			legacy.MaxImageSpam = 3
-			legacy.MaxAttachSpam = 1
-			legacy.MaxPingSpam = 24
-			legacy.MaxMessageSpam = make(map[int64]int)
-			legacy.MaxMessageSpam[1] = 4
-			legacy.MaxMessageSpam[9] = 10
-			legacy.MaxMessageSpam[12] = 15
-		}
-
-		if legacy.Version <= 1 {
-			if len(legacy.Aliases) == 0 {
-				legacy.Aliases = make(map[string]string)
-			}
-			legacy.Aliases["cute"] = "pick cute"
-		}
-
-		if legacy.Version <= 3 {
-			legacy.BoredCommands = make(map[string]bool)
-		}
-
-		if legacy.Version <= 5 {
-			legacy.TimezoneLocation = "Etc/GMT"
-			if legacy.Timezone < 0 {
-				legacy.TimezoneLocation += "+"
-			}
-			legacy.TimezoneLocation += strconv.Itoa(-legacy.Timezone) // Etc has the sign reversed
-		}
-
-		guild.Config.Basic.ModRole = NewDiscordRole(legacy.AlertRole)
-		guild.Config.Basic.Aliases = legacy.Aliases
-		guild.Config.Filter.Filters = legacy.Collections
-		guild.Config.Basic.FreeChannels = make(map[DiscordChannel]bool)
-		for k, v := range legacy.FreeChannels {
-			if ch, err := ParseChannel(k, nil); err == nil {
-				guild.Config.Basic.FreeChannels[ch] = v
-			}
-		}
-		guild.Config.Basic.IgnoreInvalidCommands = legacy.IgnoreInvalidCommands
-		guild.Config.Basic.Importable = legacy.Importable
-		guild.Config.Basic.ModChannel = NewDiscordChannel(legacy.ModChannel)
-		guild.Config.Basic.SilenceRole = NewDiscordRole(legacy.SilentRole)
-		guild.Config.Modules.CommandChannels = make(map[CommandID]map[DiscordChannel]bool)
-		for key := range legacy.Command_channels {
-			guild.Config.Modules.CommandChannels[CommandID(key)] = make(map[DiscordChannel]bool)
-			for k, v := range legacy.Command_channels[key] {
-				if ch, err := ParseChannel(k, nil); err == nil {
-					guild.Config.Modules.CommandChannels[CommandID(key)][ch] = v
-				}
-			}
-		}
-		guild.Config.Modules.CommandDisabled = make(map[CommandID]bool)
-		for key := range legacy.Command_disabled {
-			guild.Config.Modules.CommandDisabled[CommandID(key)] = true
-		}
-		guild.Config.Modules.CommandLimits = make(map[CommandID]int64)
-		for key, v := range legacy.Command_limits {
-			guild.Config.Modules.CommandLimits[CommandID(key)] = v
-		}
-		guild.Config.Modules.CommandRoles = make(map[CommandID]map[DiscordRole]bool)
-		for key := range legacy.Command_roles {
-			guild.Config.Modules.CommandRoles[CommandID(key)] = make(map[DiscordRole]bool)
-			for k, v := range legacy.Command_roles[key] {
-				if r, err := ParseRole(k, nil); err == nil {
-					guild.Config.Modules.CommandRoles[CommandID(key)][r] = v
-				}
-			}
-		}
-
-		guild.Config.Modules.CommandMaxDuration = legacy.Commandmaxduration
-		guild.Config.Modules.CommandPerDuration = legacy.Commandperduration
-		guild.Config.Modules.Channels = make(map[ModuleID]map[DiscordChannel]bool)
-		for key := range legacy.Module_channels {
-			guild.Config.Modules.Channels[ModuleID(key)] = make(map[DiscordChannel]bool)
-			for k, v := range legacy.Module_channels[key] {
-				if ch, err := ParseChannel(k, nil); err == nil {
-					guild.Config.Modules.Channels[ModuleID(key)][ch] = v
-				}
-			}
-		}
-		guild.Config.Modules.Disabled = make(map[ModuleID]bool)
-		for key := range legacy.Module_disabled {
-			guild.Config.Modules.Disabled[ModuleID(key)] = true
-		}
-		guild.Config.Spam.AutoSilence = legacy.AutoSilence
-		//guild.Config.Spam.MaxAttach = legacy.MaxAttachSpam
-		//guild.Config.Spam.MaxImages = legacy.MaxImageSpam
-		//guild.Config.Spam.MaxMessages = legacy.MaxMessageSpam
-		//guild.Config.Spam.MaxPings = legacy.MaxPingSpam
-		guild.Config.Spam.RaidTime = legacy.MaxRaidTime
-		guild.Config.Spam.MaxRemoveLookback = legacy.MaxSpamRemoveLookback
-		guild.Config.Spam.RaidSize = legacy.RaidSize
-		guild.Config.Bucket.MaxItems = legacy.MaxBucket
-		guild.Config.Bucket.MaxItemLength = legacy.MaxBucketLength
-		guild.Config.Bucket.MaxFightDamage = legacy.MaxFightDamage
-		guild.Config.Bucket.MaxFightHP = legacy.MaxFightHP
-		guild.Config.Markov.DefaultLines = legacy.Defaultmarkovlines
-		guild.Config.Markov.MaxPMlines = legacy.MaxPMlines
-		guild.Config.Markov.MaxLines = legacy.Maxquotelines
-		guild.Config.Markov.UseMemberNames = legacy.UseMemberNames
-		guild.Config.Users.TimezoneLocation = legacy.TimezoneLocation
-		guild.Config.Users.WelcomeChannel = NewDiscordChannel(legacy.WelcomeChannel)
-		guild.Config.Users.WelcomeMessage = legacy.WelcomeMessage
-		guild.Config.Users.SilenceMessage = legacy.SilenceMessage
-		guild.Config.Bored.Commands = legacy.BoredCommands
-		guild.Config.Bored.Cooldown = legacy.Maxbored
-		guild.Config.Information.HideNegativeRules = legacy.HideNegativeRules
-		guild.Config.Information.Rules = legacy.Rules
-		guild.Config.Log.Channel = NewDiscordChannel(legacy.LogChannel)
-		guild.Config.Log.Cooldown = legacy.Maxerror
-		guild.Config.Witty.Cooldown = legacy.Maxwit
-		guild.Config.Witty.Responses = legacy.Witty
-		guild.Config.Scheduler.BirthdayRole = NewDiscordRole(legacy.BirthdayRole)
-		guild.Config.Miscellaneous.MaxSearchResults = legacy.Maxsearchresults
-		guild.Config.Filter.Channels = make(map[string]map[DiscordChannel]bool)
-		guild.Config.Filter.Channels["spoiler"] = make(map[DiscordChannel]bool)
-		for _, v := range legacy.SpoilChannels {
-			guild.Config.Filter.Channels["spoiler"][NewDiscordChannel(v)] = true
-		}
-		guild.Config.Status.Cooldown = legacy.StatusDelayTime
-		guild.Config.Quote.Quotes = make(map[DiscordUser][]string)
-		for k, v := range legacy.Quotes {
-			guild.Config.Quote.Quotes[NewDiscordUser(k)] = v
-		}
-
-		newcommands := []string{"addevent", "addbirthday", "autosilence", "silence", "unsilence", "wipewelcome", "new", "addquote", "removequote", "removealias", "delete", "createpoll", "deletepoll", "addoption"}
-		for _, v := range newcommands {
-			restrictCommand(v, guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		}
+	if config.sections == nil {
+		config.sections = make(map[string]interface{}, len(configSections))
 	}
-
-	if guild.Config.Version == 10 {
-		legacy := legacyBotConfigV10{}
-		err := json.Unmarshal(config, &legacy)
-		if err == nil {
-			guild.Config.Modules.CommandMaxDuration = legacy.Basic.Commandmaxduration
-			guild.Config.Modules.CommandPerDuration = legacy.Basic.Commandperduration
-		} else {
-			fmt.Println(err.Error())
+	for _, s := range configSections {
+		if _, ok := config.sections[s.key]; !ok {
+			config.sections[s.key] = s.create()
 		}
 	}
-
-	if guild.Config.Version <= 11 {
-		restrictCommand("getaudit", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-	}
-
-	if guild.Config.Version <= 12 {
-		guild.Config.Spam.BasePressure = 10.0
-		guild.Config.Spam.MaxPressure = 60.0
-		guild.Config.Spam.ImagePressure = ((guild.Config.Spam.MaxPressure - guild.Config.Spam.BasePressure) / 6.0)
-		guild.Config.Spam.PingPressure = ((guild.Config.Spam.MaxPressure - guild.Config.Spam.BasePressure) / 24.0)
-		guild.Config.Spam.LengthPressure = ((guild.Config.Spam.MaxPressure - guild.Config.Spam.BasePressure) / (2000.0 * 4))
-		guild.Config.Spam.RepeatPressure = guild.Config.Spam.BasePressure
-		guild.Config.Spam.PressureDecay = 2.5
-
-		legacy := legacyBotConfigV12{}
-		err := json.Unmarshal(config, &legacy)
-		if err == nil {
-			if legacy.Spam.MaxImages > 0 {
-				guild.Config.Spam.ImagePressure = ((guild.Config.Spam.MaxPressure - guild.Config.Spam.BasePressure) / float32(legacy.Spam.MaxImages+1))
-			} else {
-				guild.Config.Spam.ImagePressure = 0
-			}
-			if legacy.Spam.MaxPings > 0 {
-				guild.Config.Spam.PingPressure = ((guild.Config.Spam.MaxPressure - guild.Config.Spam.BasePressure) / float32(legacy.Spam.MaxPings+1))
-			} else {
-				guild.Config.Spam.PingPressure = 0
-			}
-		} else {
-			fmt.Println(err.Error())
+	for _, field := range config.fields() {
+		if field.Value.Kind() != reflect.Struct {
+			continue
 		}
-	}
-
-	if guild.Config.Version <= 13 {
-		legacy := legacyBotConfigV13{}
-		err := json.Unmarshal(config, &legacy)
-		if err == nil {
-			guild.Config.Users.Roles = make(map[DiscordRole]bool, len(legacy.Basic.Groups))
-			idmap := make(map[string]string, len(legacy.Basic.Groups)) // Map initial group name to new role ID
-
-			for k, v := range legacy.Basic.Groups {
-				role := k
-				check, err := GetRoleByName(role, guild)
-				if check != nil {
-					role = "sb-" + role
-				}
-				r, err := guild.Bot.DG.GuildRoleCreate(guild.ID)
-				if err == nil {
-					r, err = guild.Bot.DG.GuildRoleEdit(guild.ID, r.ID, role, 0, false, 0, true)
-				}
-				if err == nil {
-					idmap[strings.ToLower(k)] = r.ID
-					if id, err := ParseRole(r.ID, nil); err == nil {
-						guild.Config.Users.Roles[id] = true
-					}
-
-					for u := range v {
-						err = guild.Bot.DG.GuildMemberRoleAdd(guild.ID, u, r.ID)
-						if err != nil {
-							fmt.Println(err)
-						}
-					}
-				} else {
-					fmt.Println(err)
-				}
+		for j := 0; j < field.Value.NumField(); j++ {
+			f := field.Value.Field(j)
+			if f.Kind() == reflect.Map && f.Len() == 0 && f.CanSet() {
+				f.Set(reflect.MakeMap(f.Type()))
 			}
-
-			stmt, err := guild.Bot.DB.Prepare("SELECT ID, Data FROM schedule WHERE Guild = ? AND Type = 7")
-			stmt2, err := guild.Bot.DB.Prepare("UPDATE schedule SET Data = ? WHERE ID = ?")
-			if err != nil {
-				fmt.Println(err)
-			} else {
-				q, err := stmt.Query(SBatoi(guild.ID))
-				if err != nil {
-					fmt.Println(err)
-				} else {
-					defer q.Close()
-					for q.Next() {
-						var id uint64
-						var dat string
-						if err := q.Scan(&id, &dat); err == nil {
-							datas := strings.SplitN(dat, "|", 2)
-							groups := strings.Split(datas[0], "+")
-							for i := range groups {
-								rid, ok := idmap[strings.ToLower(groups[i])]
-								if ok {
-									groups[i] = "<@&" + rid + ">"
-								}
-							}
-							_, err = stmt2.Exec(strings.Join(groups, " ")+"|"+datas[1], id)
-							if err != nil {
-								fmt.Println(err)
-							}
-						}
-					}
-				}
-			}
-		} else {
-			fmt.Println(err.Error())
 		}
 	}
-
-	if guild.Config.Version <= 14 {
-		restrictCommand("addrole", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		restrictCommand("removerole", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		restrictCommand("deleterole", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-	}
-
-	if guild.Config.Version <= 15 {
-		restrictCommand("bannewcomers", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		guild.Config.Spam.LockdownDuration = 120
-	}
-
-	if guild.Config.Version <= 16 {
-		guild.Config.Basic.CommandPrefix = "!"
-	}
-
-	if guild.Config.Version <= 17 {
-		guild.Config.SetupDone = true
-	}
-
-	if guild.Config.Version <= 18 {
-		restrictCommand("banraid", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		restrictCommand("getraid", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		restrictCommand("wipe", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		restrictCommand("bannewcomers", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		restrictCommand("getpressure", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		guild.Config.Spam.LinePressure = (guild.Config.Spam.MaxPressure - guild.Config.Spam.BasePressure) / 70.0
-	}
-
-	if guild.Config.Version <= 19 {
-		guild.Bot.GuildsLock.Lock()
-		if len(guild.Config.Filter.Filters) == 0 {
-			guild.Config.Filter.Filters = make(map[string]map[string]bool)
-		}
-		legacy := legacyBotConfigV19{}
-		err := json.Unmarshal(config, &legacy)
-		if err == nil {
-			guild.Config.Bucket.Items = legacy.Basic.Collections["bucket"]
-			guild.Config.Filter.Filters["emote"] = legacy.Basic.Collections["emote"]
-			guild.Config.Status.Lines = legacy.Basic.Collections["status"]
-			guild.Config.Filter.Filters["spoiler"] = legacy.Basic.Collections["spoiler"]
-			delete(legacy.Basic.Collections, "bucket")
-			delete(legacy.Basic.Collections, "emote")
-			delete(legacy.Basic.Collections, "status")
-			delete(legacy.Basic.Collections, "spoiler")
-
-			gID := SBatoi(guild.ID)
-			for k, v := range legacy.Basic.Collections {
-				if len(v) > 0 {
-					fmt.Println("Importing:", k)
-					guild.Bot.DB.CreateTag(k, gID)
-					tag, err := guild.Bot.DB.GetTag(k, gID)
-					if err == nil {
-						for item := range v {
-							id, err := guild.Bot.DB.AddItem(item)
-							if err == nil || err != ErrDuplicateEntry {
-								guild.Bot.DB.AddTag(id, tag)
-							}
-						}
-					}
-				} else {
-					fmt.Println("Skipping empty collection:", k)
-				}
-			}
-		} else {
-			fmt.Println(err.Error())
-		}
-		guild.Bot.GuildsLock.Unlock()
-		restrictCommand("addset", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		restrictCommand("removeset", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		restrictCommand("searchset", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-	}
-
-	if guild.Config.Version <= 20 {
-		legacy := legacyBotConfigV20{}
-		err := json.Unmarshal(config, &legacy)
-		if err == nil {
-			guild.Config.Basic.ModRole = legacy.Basic.AlertRole
-			guild.Config.Miscellaneous.MaxSearchResults = legacy.Search.MaxResults
-			guild.Config.Scheduler.BirthdayRole = legacy.Schedule.BirthdayRole
-			guild.Config.Filter.Filters = make(map[string]map[string]bool)
-			guild.Config.Filter.Channels = make(map[string]map[DiscordChannel]bool)
-			guild.Config.Filter.Responses = make(map[string]string)
-			guild.Config.Filter.Templates = make(map[string]string)
-			guild.Config.Bucket.Items = make(map[string]bool)
-			guild.Config.Status.Lines = make(map[string]bool)
-			guild.Config.Users.TrackUserLeft = legacy.Basic.TrackUserLeft
-			guild.Config.Users.SilenceMessage = legacy.Spam.SilenceMessage
-			guild.Config.Basic.SilenceRole = legacy.Spam.SilentRole
-
-			if bucket, ok := legacy.Collections["bucket"]; ok {
-				for k, v := range bucket {
-					guild.Config.Bucket.Items[k] = v
-				}
-			}
-
-			if status, ok := legacy.Collections["status"]; ok {
-				for k, v := range status {
-					guild.Config.Status.Lines[k] = v
-				}
-			}
-
-			if guild.Config.Spam.AutoSilence == -2 {
-				guild.Config.Users.NotifyChannel = guild.Config.Log.Channel
-			} else if guild.Config.Spam.AutoSilence != 0 {
-				guild.Config.Users.NotifyChannel = guild.Config.Basic.ModChannel
-			}
-			if guild.Config.Spam.AutoSilence < 0 {
-				guild.Config.Spam.AutoSilence = 0
-			}
-
-			if spoilers, ok := legacy.Collections["spoiler"]; (ok && len(spoilers) > 0) || len(legacy.Spoiler.Channels) > 0 {
-				guild.Config.Filter.Filters["spoiler"] = make(map[string]bool)
-				if ok {
-					for k, v := range spoilers {
-						guild.Config.Filter.Filters["spoiler"][k] = v
-					}
-				}
-				guild.Config.Filter.Channels["spoiler"] = make(map[DiscordChannel]bool)
-				for _, v := range legacy.Spoiler.Channels {
-					guild.Config.Filter.Channels["spoiler"][v] = true
-				}
-				guild.Config.Filter.Responses["spoiler"] = "[](/nospoilers) ```\nNO SPOILERS! Posting spoilers is a bannable offense. All discussion about new and future content MUST be in #mylittlespoilers.```"
-			}
-
-			if emotes, ok := legacy.Collections["emote"]; ok && len(emotes) > 0 {
-				guild.Config.Filter.Filters["emote"] = make(map[string]bool)
-				for k, v := range emotes {
-					guild.Config.Filter.Filters["emote"][k] = v
-				}
-				guild.Config.Filter.Channels["emote"] = make(map[DiscordChannel]bool)
-				guild.Config.Filter.Responses["emote"] = "```\nThat emote isn't allowed here! Try to avoid using large or disturbing emotes, as they can be problematic.```"
-				guild.Config.Filter.Templates["emote"] = "\\[\\]\\(\\/r?%%[-) \"]"
-			}
-		}
-
-		if guild.Config.Basic.ModRole == "0" {
-			guild.Config.Basic.ModRole = ""
-		}
-		if guild.Config.Basic.ModChannel == "0" {
-			guild.Config.Basic.ModChannel = ""
-		}
-		if guild.Config.Basic.SilenceRole == "0" {
-			guild.Config.Basic.SilenceRole = ""
-		}
-		if guild.Config.Spam.IgnoreRole == "0" {
-			guild.Config.Spam.IgnoreRole = ""
-		}
-		if guild.Config.Users.WelcomeChannel == "0" {
-			guild.Config.Users.WelcomeChannel = ""
-		}
-		if guild.Config.Users.NotifyChannel == "0" {
-			guild.Config.Users.NotifyChannel = ""
-		}
-		if guild.Config.Log.Channel == "0" {
-			guild.Config.Log.Channel = ""
-		}
-		if guild.Config.Scheduler.BirthdayRole == "0" {
-			guild.Config.Scheduler.BirthdayRole = ""
-		}
-
-		for k := range guild.Config.Modules.Channels {
-			switch k {
-			case "schedule":
-				guild.Config.Modules.Channels["scheduler"] = guild.Config.Modules.Channels[k]
-				delete(guild.Config.Modules.Channels, k)
-			case "anti-spam":
-				guild.Config.Modules.Channels["spam"] = guild.Config.Modules.Channels[k]
-				delete(guild.Config.Modules.Channels, k)
-			case "help/about":
-				guild.Config.Modules.Channels["information"] = guild.Config.Modules.Channels[k]
-				delete(guild.Config.Modules.Channels, k)
-			}
-		}
-
-		for k := range guild.Config.Modules.Disabled {
-			switch k {
-			case "schedule":
-				guild.Config.Modules.Channels["scheduler"] = guild.Config.Modules.Channels[k]
-				delete(guild.Config.Modules.Channels, k)
-			case "anti-spam":
-				guild.Config.Modules.Channels["spam"] = guild.Config.Modules.Channels[k]
-				delete(guild.Config.Modules.Channels, k)
-			case "help/about":
-				guild.Config.Modules.Channels["information"] = guild.Config.Modules.Channels[k]
-				delete(guild.Config.Modules.Channels, k)
-			}
-		}
-	}
-
-	if guild.Config.Version <= 21 {
-		restrictCommand("assignrole", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-	}
-
-	if guild.Config.Version <= 22 {
-		restrictCommand("setfilter", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		restrictCommand("addfilter", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		restrictCommand("removefilter", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		restrictCommand("searchfilter", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		restrictCommand("addstatus", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		restrictCommand("removestatus", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-		restrictCommand("setstatus", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-	}
-
-	if guild.Config.Version <= 23 {
-		restrictCommand("createrole", guild.Config.Modules.CommandRoles, guild.Config.Basic.ModRole)
-	}
-
-	if guild.Config.Version != ConfigVersion {
-		guild.Config.Version = ConfigVersion // set version to most recent config version
-		guild.SaveConfig()
-	}
-	return nil
 }
 
 func GetSubStruct(arg []string, f reflect.Value, j int, info *GuildInfo) []string {
 	val := f.Field(j)
 	if len(arg) > 2 {
 		switch f.Field(j).Interface().(type) {
-		case map[string]bool, map[string]string, map[string]int64, map[string]map[DiscordChannel]bool, map[string]map[string]bool:
+		case map[string]bool, map[string]string, map[string]int64, map[string]map[DiscordChannel]bool, map[string]map[string]bool, map[string]map[DiscordRole]bool, map[string]FilterAction:
 			val = f.Field(j).MapIndex(reflect.ValueOf(arg[2]))
-		case map[DiscordChannel]bool, map[DiscordChannel]float32:
+		case map[DiscordChannel]bool, map[DiscordChannel]float32, map[DiscordChannel]map[string]bool:
 			val = f.Field(j).MapIndex(reflect.ValueOf(DiscordChannel(arg[2])))
 		case map[DiscordRole]bool:
 			val = f.Field(j).MapIndex(reflect.ValueOf(DiscordRole(arg[2])))
@@ -1313,5 +896,5 @@ func GetSubStruct(arg []string, f reflect.Value, j int, info *GuildInfo) []strin
 			return []string{fmt.Sprintf("can't find %v", arg[2])}
 		}
 	}
-	return info.Config.GetConfig(val, info.Bot.DG.State, info.ID)
+	return info.Config.GetConfig(val, info.DG.State, info.ID)
 }
@@ -1,6 +1,7 @@
 package sweetiebot
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
@@ -61,7 +62,7 @@ func TestSetConfig(t *testing.T) {
 	config := &BotConfig{}
 	fnImportable := func(name string) {
 		config.Basic.Importable = false
-		name, _ = FixRequest(name, reflect.ValueOf(config).Elem())
+		name, _ = config.FixRequest(name)
 		if s, ok := config.internalSetConfig(nil, name, "true"); !ok {
 			t.Errorf("SetConfig(%s) returned %v", name, s)
 		}
@@ -89,7 +90,7 @@ func TestSetConfig(t *testing.T) {
 	dbmock.ExpectQuery("SELECT DISTINCT M.ID FROM members.*").WithArgs(sqlmock.AnyArg(), "1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"ID"}).AddRow(1))
 
 	fnSetInterface := func(name string, value interface{}) {
-		name, _ = FixRequest(name, reflect.ValueOf(config).Elem())
+		name, _ = config.FixRequest(name)
 		if s, ok := config.internalSetConfig(info, name, fmt.Sprintf("%v", value)); !ok {
 			t.Errorf("SetConfig(%s) returned %v", name, s)
 		}
@@ -104,7 +105,7 @@ func TestSetConfig(t *testing.T) {
 	Check(config.Modules.CommandMaxDuration, int64(123456), t)
 
 	fnFreeChannels := func(value DiscordChannel, extra ...string) {
-		name, _ := FixRequest("FreeChannels", reflect.ValueOf(config).Elem())
+		name, _ := config.FixRequest("FreeChannels")
 		if s, ok := config.internalSetConfig(info, append([]string{name, value.String()}, extra...)...); !ok {
 			t.Errorf("SetConfig(FreeChannels) returned %v", s)
 		}
@@ -128,7 +129,7 @@ func TestSetConfig(t *testing.T) {
 	fnFreeChannels(DiscordChannel("2345"))
 
 	fnCommandLimits := func(key string, value int64) {
-		name, _ := FixRequest("CommandLimits", reflect.ValueOf(config).Elem())
+		name, _ := config.FixRequest("CommandLimits")
 		var s string
 		var ok bool
 		if value != 0 {
@@ -175,6 +176,10 @@ func TestSetConfig(t *testing.T) {
 					config.internalSetConfig(info, path, "true")
 					Check(p.Field(i).Field(j).Interface().(bool), true, t)
 					continue
+				case LogLevel:
+					config.internalSetConfig(info, path, "warning")
+					Check(p.Field(i).Field(j).Interface().(LogLevel), LogWarning, t)
+					continue
 				}
 
 				config.internalSetConfig(info, path, "1", "1")
@@ -254,3 +259,57 @@ func TestSetConfig(t *testing.T) {
 		}
 	}
 }
+
+type testConfig struct {
+	Greeting string          `json:"greeting"`
+	Limit    int             `json:"limit"`
+	Words    map[string]bool `json:"words"`
+	Screen   ScreenAction    `json:"screen"`
+}
+
+func init() {
+	RegisterConfig("Testing", func() interface{} { return &testConfig{Greeting: "hello", Limit: 3} }, map[string]string{
+		"greeting": "What to say.",
+		"limit":    "How often to say it.",
+		"words":    "Words to say it to.",
+		"screen":   "What to do to new members.",
+	})
+}
+
+func TestRegisterConfig(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	Check(config.Section("testing").(*testConfig).Limit, 3, t)
+	Check(config.Section("nonexistent"), nil, t)
+	name, err := config.FixRequest("greeting")
+	Check(err, nil, t)
+	Check(name, "testing.greeting", t)
+	_, ok := config.internalSetConfig(nil, "Testing.Limit", "5")
+	Check(ok, true, t)
+	_, ok = config.internalSetConfig(nil, "testing.words", "a", "b")
+	Check(ok, true, t)
+	_, ok = getConfigHelp("Testing", "Limit")
+	Check(ok, true, t)
+
+	data, err := json.Marshal(config)
+	Check(err, nil, t)
+	loaded := DefaultConfig()
+	Check(json.Unmarshal(data, loaded), nil, t)
+	section := loaded.Section("Testing").(*testConfig)
+	Check(section.Limit, 5, t)
+	Check(section.Greeting, "hello", t)
+	Check(len(section.Words), 2, t)
+	Check(loaded.Basic.CommandPrefix, "!", t)
+
+	// Config files written before the category existed should load with the defaults
+	legacy := DefaultConfig()
+	Check(json.Unmarshal([]byte(`{"version": 24, "basic": {"commandprefix": "^"}}`), legacy), nil, t)
+	Check(legacy.Basic.CommandPrefix, "^", t)
+	Check(legacy.Section("testing").(*testConfig).Limit, 3, t)
+
+	empty := &BotConfig{}
+	empty.FillConfig()
+	Check(empty.Section("testing").(*testConfig).Words != nil, true, t)
+	Check(empty.Basic.Aliases != nil, true, t)
+}
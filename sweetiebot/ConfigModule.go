@@ -47,12 +47,13 @@ func (c *setConfigCommand) Process(args []string, msg *discordgo.Message, indice
 		return "```\nNo value to set!```", false, nil
 	}
 	var err error
-	args[0], err = FixRequest(args[0], reflect.ValueOf(&info.Config).Elem())
+	args[0], err = info.Config.FixRequest(args[0])
 	if err != nil {
 		return ReturnError(err)
 	}
 	n, ok := info.Config.SetConfig(info, args, indices, msg.Content)
 	info.SaveConfig()
+	info.RegisterSlashCommands()
 	if ok {
 		return "```\nSuccessfully set " + args[0] + " to " + n + ".```", false, nil
 	}
@@ -82,14 +83,13 @@ func (c *getConfigCommand) Info() *CommandInfo {
 }
 
 func (c *getConfigCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	t := reflect.ValueOf(&info.Config).Elem()
-	n := t.NumField()
+	config := info.Config.fields()
 	if len(args) < 1 {
-		fields := make([]*discordgo.MessageEmbedField, 0, n)
-		for i := 0; i < n; i++ {
-			switch t.Field(i).Kind() {
+		fields := make([]*discordgo.MessageEmbedField, 0, len(config))
+		for _, field := range config {
+			switch field.Value.Kind() {
 			case reflect.Struct:
-				f := t.Field(i)
+				f := field.Value
 				s := make([]string, 0, f.NumField())
 				for j := 0; j < f.NumField(); j++ {
 					str := f.Type().Field(j).Name
@@ -112,7 +112,7 @@ func (c *getConfigCommand) Process(args []string, msg *discordgo.Message, indice
 					}
 					s = append(s, str)
 				}
-				fields = append(fields, &discordgo.MessageEmbedField{Name: t.Type().Field(i).Name, Value: strings.Join(s, "\n"), Inline: true})
+				fields = append(fields, &discordgo.MessageEmbedField{Name: field.Name, Value: strings.Join(s, "\n"), Inline: true})
 			}
 		}
 		embed := &discordgo.MessageEmbed{
@@ -129,7 +129,7 @@ func (c *getConfigCommand) Process(args []string, msg *discordgo.Message, indice
 		return "", false, nil
 	}
 	var err error
-	args[0], err = FixRequest(args[0], t)
+	args[0], err = info.Config.FixRequest(args[0])
 	if err != nil {
 		return ReturnError(err)
 	}
@@ -138,11 +138,11 @@ func (c *getConfigCommand) Process(args []string, msg *discordgo.Message, indice
 		arg = append(arg, args[1])
 	}
 
-	for i := 0; i < n; i++ {
-		if strings.ToLower(t.Type().Field(i).Name) == arg[0] {
-			switch t.Field(i).Kind() {
+	for _, field := range config {
+		if strings.ToLower(field.Name) == arg[0] {
+			switch field.Value.Kind() {
 			case reflect.Struct:
-				f := t.Field(i)
+				f := field.Value
 				if len(arg) > 1 {
 					for j := 0; j < f.NumField(); j++ {
 						if strings.ToLower(f.Type().Field(j).Name) == arg[1] {
@@ -159,7 +159,7 @@ func (c *getConfigCommand) Process(args []string, msg *discordgo.Message, indice
 					fields := make([]*discordgo.MessageEmbedField, 0, f.NumField())
 					dump := []string{}
 					for j := 0; j < f.NumField(); j++ {
-						desc, ok := getConfigHelp(t.Type().Field(i).Name, f.Type().Field(j).Name)
+						desc, ok := getConfigHelp(field.Name, f.Type().Field(j).Name)
 						if !ok {
 							desc = "\u200b"
 						}
@@ -177,8 +177,8 @@ func (c *getConfigCommand) Process(args []string, msg *discordgo.Message, indice
 					embed := &discordgo.MessageEmbed{
 						Type: "rich",
 						Author: &discordgo.MessageEmbedAuthor{
-							URL:     "https://sweetiebot.io/help/" + strings.ToLower(t.Type().Field(i).Name),
-							Name:    t.Type().Field(i).Name + " Config Category",
+							URL:     "https://sweetiebot.io/help/" + strings.ToLower(field.Name),
+							Name:    field.Name + " Config Category",
 							IconURL: fmt.Sprintf("https://cdn.discordapp.com/avatars/%v/%s.jpg", info.Bot.SelfID, info.Bot.SelfAvatar),
 						},
 						Description: "```\n" + strings.Join(dump, "\n") + "```",
@@ -232,7 +232,7 @@ func (c *setupCommand) Process(args []string, msg *discordgo.Message, indices []
 	if err != nil || guild == nil {
 		return "```\nCan't find guild in state object?!?", false, nil
 	}
-	perms, _ := info.Bot.DG.UserPermissions(DiscordUser(msg.Author.ID), info.ID)
+	perms, _ := info.DG.UserPermissions(DiscordUser(msg.Author.ID), info.ID)
 	if perms&discordgo.PermissionAdministrator == 0 {
 		return "```\nOnly administrators can use this command!```", false, nil
 	}
@@ -270,13 +270,13 @@ func (c *setupCommand) Process(args []string, msg *discordgo.Message, indices []
 		}
 	}
 
-	silent, err := info.Bot.DG.GuildRoleCreate(info.ID)
+	silent, err := info.DG.GuildRoleCreate(info.ID)
 	if err != nil {
 		return fmt.Sprintf("```\nFailed to create the silent role! %s```", err.Error()), false, nil
 	}
-	_, err = info.Bot.DG.GuildRoleEdit(info.ID, silent.ID, "Silence", 0, false, discordgo.PermissionReadMessages, false)
+	_, err = info.DG.GuildRoleEdit(info.ID, silent.ID, "Silence", 0, false, discordgo.PermissionReadMessages, false)
 	if err != nil {
-		info.Bot.DG.GuildRoleDelete(info.ID, silent.ID)
+		info.DG.GuildRoleDelete(info.ID, silent.ID)
 		return fmt.Sprintf("```\nFailed to set up the silent role! %s```", err.Error()), false, nil
 	}
 
@@ -310,6 +310,7 @@ func (c *setupCommand) Process(args []string, msg *discordgo.Message, indices []
 	info.setupSilenceRole()
 	info.Config.SetupDone = true
 	info.SaveConfig()
+	info.RegisterSlashCommands()
 	return fmt.Sprintf("```\nServer configured!\nModerator Role: %v\nMod Channel: %v\nLog Channel: %v```\nNow that you've done basic configuration on %s, here are some additional features you can enable. For additional help, type `"+info.Config.Basic.CommandPrefix+"help` for a list of commands and modules, or `"+info.Config.Basic.CommandPrefix+"getconfig` with no arguments for a list of configuration options. Using `"+info.Config.Basic.CommandPrefix+"help <module>` will display detailed help for that module and all its commands. Using `"+info.Config.Basic.CommandPrefix+"getconfig <group>` will display detailed help for all the configuration options in that configuration group. If you're still confused, please check out the readme: https://github.com/blackhole12/sweetiebot/blob/master/README.md \n\n**Bucket**\nIf you'd like to enable the bucket, use the command `"+info.Config.Basic.CommandPrefix+"enable Bucket`. It defaults to carrying a maximum of 10 items, but you can change this via the `Bucket.MaxItems` option.\n\n**Bored Module**\nIf you'd like "+info.GetBotName()+" to perform actions when the chat in a certain channel hasn't been active for a period of time, use `"+info.Config.Basic.CommandPrefix+"enable bored` followed by `"+info.Config.Basic.CommandPrefix+"setconfig modules.channels bored #yourchannel`, where `#yourchannel` is your general chat channel. The commands picked from are stored in `bored.commands`. By default, it will quote someone or attempt to throw an item out of the bucket.\n\n**Free Channels**\nIf you like, you can designate a channel to be free from command restrictions, so people can spam silly bot commands to their hearts content. If you had a channel called `#bot` for this, you can disable all command restrictions by using the command ```"+info.Config.Basic.CommandPrefix+"setconfig basic.freechannels #bot```.", modname, modchannel, logchannel, info.GetBotName()), false, nil
 }
 func (c *setupCommand) Usage(info *GuildInfo) *CommandUsage {
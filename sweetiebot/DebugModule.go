@@ -122,6 +122,7 @@ func setCommandEnable(args []string, enable bool, success string, info *GuildInf
 				info.Config.Modules.Disabled[ModuleID(name)] = true
 			}
 			info.SaveConfig()
+			info.RegisterSlashCommands()
 			return "", false, DumpCommandsModules(info, "", "**Success!** "+args[0]+success, msg)
 		}
 	}
@@ -134,6 +135,7 @@ func setCommandEnable(args []string, enable bool, success string, info *GuildInf
 				info.Config.Modules.CommandDisabled[k] = true
 			}
 			info.SaveConfig()
+			info.RegisterSlashCommands()
 			return "", false, DumpCommandsModules(info, "", "**Success!** "+args[0]+success, msg)
 		}
 	}
@@ -302,15 +304,25 @@ func (c *listGuildsCommand) Process(args []string, msg *discordgo.Message, indic
 	if !info.Bot.Owner.Equals(msg.Author.ID) {
 		return "```\nOnly the owner of the bot itself can call this!```", false, nil
 	}
-	info.Bot.DG.State.RLock()
-	guilds := append([]*discordgo.Guild{}, info.Bot.DG.State.Guilds...)
-	info.Bot.DG.State.RUnlock()
+	guilds := []*discordgo.Guild{}
+	shards := make([]string, 0, len(info.Bot.Shards))
+	for i, shard := range info.Bot.Shards {
+		shard.State.RLock()
+		guilds = append(guilds, shard.State.Guilds...)
+		shard.State.RUnlock()
+		count, missed := info.Bot.ShardStatus(i)
+		status := "connected"
+		if !shard.DataReady {
+			status = "disconnected"
+		}
+		shards = append(shards, fmt.Sprintf("Shard %v: %v servers, %s, %v missed heartbeats", i, count, status, missed))
+	}
 	sort.Sort(guildSlice(guilds))
 	s := make([]string, 0, len(guilds))
 	private := 0
 	for _, v := range guilds {
 		username := "<@" + v.OwnerID + ">"
-		m, _ := info.Bot.DG.GetMember(DiscordUser(v.OwnerID), v.ID)
+		m, _ := info.Bot.Shard(v.ID).GetMember(DiscordUser(v.OwnerID), v.ID)
 		if m != nil {
 			username = m.User.Username + "#" + m.User.Discriminator
 		}
@@ -324,10 +336,10 @@ func (c *listGuildsCommand) Process(args []string, msg *discordgo.Message, indic
 			private++
 		}
 	}
-	return fmt.Sprintf("```\n%s has joined these servers:\n%s\n\n+ %v private servers (Basic.Importable is false)```", info.GetBotName(), strings.Join(s, "\n"), private), len(s) > 8, nil
+	return fmt.Sprintf("```\n%s\n\n%s has joined these servers:\n%s\n\n+ %v private servers (Basic.Importable is false)```", strings.Join(shards, "\n"), info.GetBotName(), strings.Join(s, "\n"), private), len(s) > 8, nil
 }
 func (c *listGuildsCommand) Usage(info *GuildInfo) *CommandUsage {
-	return &CommandUsage{Desc: "Lists the servers the bot is on."}
+	return &CommandUsage{Desc: "Lists the status of each shard and the servers the bot is on."}
 }
 
 type announceCommand struct {
@@ -510,7 +522,7 @@ func (c *setProfileCommand) Process(args []string, msg *discordgo.Message, indic
 	if len(args) > 1 {
 		avatarfile = msg.Content[indices[1]:]
 	}
-	if err := info.Bot.DG.ChangeBotName(args[0], avatarfile); err != nil {
+	if err := info.DG.ChangeBotName(args[0], avatarfile); err != nil {
 		return fmt.Sprintf("```\nError changing bot name or avatar: %s```", err.Error()), false, nil
 	}
 	if len(args) < 1 {
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/blackhole12/discordgo"
 )
@@ -48,7 +49,7 @@ func (u DiscordUser) Display() string {
 
 // Show channel name if available, or display ping
 func (ch DiscordChannel) Show(info *GuildInfo) string {
-	if channel, err := info.Bot.DG.State.Channel(string(ch)); err == nil {
+	if channel, err := info.DG.State.Channel(string(ch)); err == nil {
 		return "#" + channel.Name
 	}
 	return ch.Display()
@@ -56,7 +57,7 @@ func (ch DiscordChannel) Show(info *GuildInfo) string {
 
 // Show role name if available, or display ping
 func (r DiscordRole) Show(info *GuildInfo) string {
-	if role, err := info.Bot.DG.State.Role(info.ID, string(r)); err == nil {
+	if role, err := info.DG.State.Role(info.ID, string(r)); err == nil {
 		return "@" + role.Name
 	}
 	return r.Display()
@@ -397,3 +398,54 @@ func (s *DiscordGoSession) BulkDeleteBypass(channelID string, messages []string)
 	}
 	return s.ChannelMessagesBulkDelete(channelID, messages[i:])
 }
+
+// EndpointInteractions is the root of the application command and interaction endpoints, which only exist in newer
+// versions of the API than the one discordgo uses
+var EndpointInteractions = discordgo.EndpointDiscord + "api/v8/"
+
+// InteractionRequest sends a request to one of the application command or interaction endpoints, none of which are
+// supported by discordgo. The bucket should leave out any interaction tokens so it can be shared between requests.
+func (s *DiscordGoSession) InteractionRequest(method string, endpoint string, bucket string, data interface{}) ([]byte, error) {
+	return s.RequestWithBucketID(method, EndpointInteractions+endpoint, data, EndpointInteractions+bucket)
+}
+
+// EndpointTimeouts is the root of the guild member endpoints in the API version that added member timeouts, which
+// discordgo doesn't know about
+var EndpointTimeouts = discordgo.EndpointDiscord + "api/v9/"
+
+// TimeoutMember uses discord's native timeout to stop a member from sending messages, reacting, or speaking until the
+// given time. A zero time removes the timeout.
+func (s *DiscordGoSession) TimeoutMember(guildID string, userID DiscordUser, until time.Time) error {
+	var data struct {
+		Until *string `json:"communication_disabled_until"`
+	}
+	if !until.IsZero() {
+		t := until.UTC().Format(time.RFC3339)
+		data.Until = &t
+	}
+	endpoint := EndpointTimeouts + "guilds/" + guildID + "/members/"
+	_, err := s.RequestWithBucketID("PATCH", endpoint+userID.String(), data, endpoint)
+	return err
+}
+
+// ChannelSlowmode returns how many seconds members must wait between messages in a channel, which discordgo's channel
+// struct doesn't have a field for
+func (s *DiscordGoSession) ChannelSlowmode(channelID string) (int, error) {
+	var data struct {
+		Slowmode int `json:"rate_limit_per_user"`
+	}
+	body, err := s.RequestWithBucketID("GET", discordgo.EndpointChannel(channelID), nil, discordgo.EndpointChannel(channelID))
+	if err == nil {
+		err = json.Unmarshal(body, &data)
+	}
+	return data.Slowmode, err
+}
+
+// SetChannelSlowmode changes how many seconds members must wait between messages in a channel. Zero disables slowmode.
+func (s *DiscordGoSession) SetChannelSlowmode(channelID string, seconds int) error {
+	data := struct {
+		Slowmode int `json:"rate_limit_per_user"`
+	}{seconds}
+	_, err := s.RequestWithBucketID("PATCH", discordgo.EndpointChannel(channelID), data, discordgo.EndpointChannel(channelID))
+	return err
+}
@@ -71,6 +71,10 @@ func (s *DiscordGoSession) ChannelPermissionSet(channelID, targetID, targetType
 	mock.Input(s.ChannelPermissionSet, channelID, targetID, targetType, allow, deny)
 	return
 }
+func (s *DiscordGoSession) ChannelPermissionDelete(channelID, targetID string) (err error) {
+	mock.Input(s.ChannelPermissionDelete, channelID, targetID)
+	return
+}
 func (s *DiscordGoSession) Guild(guildID string) (st *discordgo.Guild, err error) {
 	mock.Input(s.Guild, guildID)
 	return s.State.Guild(guildID)
@@ -180,6 +184,10 @@ func (s *DiscordGoSession) RequestWithLockedBucket(method, urlStr, contentType s
 	mock.Input(s.RequestWithLockedBucket, method, urlStr, contentType, b, bucket, sequence)
 	return
 }
+func (s *DiscordGoSession) RequestWithBucketID(method, urlStr string, data interface{}, bucketID string) (response []byte, err error) {
+	mock.Input(s.RequestWithBucketID, method, urlStr, data, bucketID)
+	return
+}
 
 func TestRemoveRole(t *testing.T) {
 	sb, _, _ := MockSweetieBot(t)
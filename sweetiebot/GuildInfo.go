@@ -9,7 +9,6 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/blackhole12/discordgo"
@@ -33,7 +32,11 @@ type GuildInfo struct {
 	Modules      []Module
 	commands     map[CommandID]Command
 	commandmap   map[CommandID]ModuleID // Exists entirely so the help command can match commands to their parent module
+	slashLock    sync.Mutex
+	slashPayload []byte // The slash commands that were last registered on this guild
+	slashVersion int    // Incremented every time the slash commands are rebuilt, so an outdated registration is dropped
 	Bot          *SweetieBot
+	DG           *DiscordGoSession // The session of the shard this guild is on
 }
 
 var errOwnerExclusive = errors.New("Only the owner of the bot can run this command!")
@@ -57,6 +60,7 @@ func NewGuildInfo(sb *SweetieBot, g *discordgo.Guild) *GuildInfo {
 		commandmap:   make(map[CommandID]ModuleID),
 		lastlogerr:   0,
 		Bot:          sb,
+		DG:           sb.Shard(g.ID),
 		Config:       *DefaultConfig(),
 	}
 }
@@ -73,16 +77,16 @@ func (info *GuildInfo) SaveConfig() (err error) {
 	data, err := json.Marshal(info.Config)
 	if err == nil {
 		if len(data) > info.Bot.MaxConfigSize {
-			info.Log("Error saving config file: Config file is too large! Config files cannot exceed " + strconv.Itoa(info.Bot.MaxConfigSize) + " bytes.")
+			info.Logger().Error("Error saving config file: Config file is too large! Config files cannot exceed " + strconv.Itoa(info.Bot.MaxConfigSize) + " bytes.")
 			err = errConfigFileTooLarge
 		} else {
 			err = ioutil.WriteFile(info.ID+".json", data, 0664)
 			if err != nil {
-				info.Log("Error saving config file: ", err.Error())
+				info.Logger().LogError("Error saving config file: ", err)
 			}
 		}
 	} else {
-		info.Log("Error writing json: ", err.Error())
+		info.Logger().LogError("Error writing json: ", err)
 	}
 	return
 }
@@ -90,7 +94,7 @@ func (info *GuildInfo) SaveConfig() (err error) {
 // SendEmbed sends an embed message to the channel, splitting it into multiple messages if necessary
 func (info *GuildInfo) SendEmbed(channelID DiscordChannel, embed *discordgo.MessageEmbed) error {
 	if channelID == "heartbeat" {
-		atomic.AddUint32(&info.Bot.heartbeat, 1)
+		info.Bot.heartbeatReceived(info)
 		return nil
 	}
 	if ch, private := info.Bot.ChannelIsPrivate(channelID); !private && (ch == nil || ch.GuildID != info.ID) {
@@ -101,14 +105,14 @@ func (info *GuildInfo) SendEmbed(channelID DiscordChannel, embed *discordgo.Mess
 	for len(fields) > 25 {
 		embed.Fields = fields[:25]
 		fields = fields[25:]
-		if _, err := info.Bot.DG.ChannelMessageSendEmbed(channelID.String(), embed); err != nil {
+		if _, err := info.DG.ChannelMessageSendEmbed(channelID.String(), embed); err != nil {
 			return err
 		}
 	}
 	embed.Fields = fields
-	_, err := info.Bot.DG.ChannelMessageSendEmbed(channelID.String(), embed)
+	_, err := info.DG.ChannelMessageSendEmbed(channelID.String(), embed)
 	return err
-	//info.Bot.DG.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+	//info.DG.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
 	//	Content: "Test content",
 	//	Embed:   embed,
 	//})
@@ -116,7 +120,7 @@ func (info *GuildInfo) SendEmbed(channelID DiscordChannel, embed *discordgo.Mess
 
 // RequestPostWithBuffer uses a buffer and a buffer combination function to combine multiple messages if there are fewer than minRequests requests left in the current bucket
 func (info *GuildInfo) RequestPostWithBuffer(urlStr string, data *discordgo.MessageSend, minRemaining int) (response []byte, err error) {
-	b := info.Bot.DG.Ratelimiter.GetBucket(urlStr)
+	b := info.DG.Ratelimiter.GetBucket(urlStr)
 	b.Lock()
 	if b.Userdata == nil {
 		b.Userdata = &sbRequestBuffer{nil, 0}
@@ -125,7 +129,7 @@ func (info *GuildInfo) RequestPostWithBuffer(urlStr string, data *discordgo.Mess
 
 	// data can be nil here, which tells the buffer to check if it's full
 	remain := buffer.Append(data)
-	softwait := info.Bot.DG.Ratelimiter.GetWaitTime(b, minRemaining)
+	softwait := info.DG.Ratelimiter.GetWaitTime(b, minRemaining)
 
 	if remain == 0 && softwait > 0 {
 		b.Release(nil)
@@ -137,17 +141,17 @@ func (info *GuildInfo) RequestPostWithBuffer(urlStr string, data *discordgo.Mess
 		data, remain = buffer.Process()
 
 		if data != nil {
-			if wait := info.Bot.DG.Ratelimiter.GetWaitTime(b, 1); wait > 0 {
+			if wait := info.DG.Ratelimiter.GetWaitTime(b, 1); wait > 0 {
 				//fmt.Printf("Hit rate limit in buffered request, sleeping for %v (%v remaining)\n", wait, remain)
 				time.Sleep(wait)
 			}
 
 			b.Remaining--
-			softwait = info.Bot.DG.Ratelimiter.GetWaitTime(b, minRemaining)
+			softwait = info.DG.Ratelimiter.GetWaitTime(b, minRemaining)
 			var body []byte
 			body, err = json.Marshal(data)
 			if err == nil {
-				response, err = info.Bot.DG.RequestWithLockedBucket("POST", urlStr, "application/json", body, b, 0)
+				response, err = info.DG.RequestWithLockedBucket("POST", urlStr, "application/json", body, b, 0)
 			} else {
 				b.Release(nil)
 				break
@@ -177,40 +181,50 @@ func (info *GuildInfo) sendContent(channelID DiscordChannel, message string, min
 		Content: message,
 	}, minRequest)
 	if err != nil {
-		fmt.Println("Failed to send message: ", err.Error())
+		info.Logger().hostOnly().With(LogFields{"channel": channelID}).Error("Failed to send message:", err)
 	}
 }
 
 // SendMessage sends a message to the given channel, splitting it into multiple messages if necessary, and combining smaller messages if a rate limit is about to be hit
 func (info *GuildInfo) SendMessage(channelID DiscordChannel, message string) error {
 	if channelID == "heartbeat" {
-		atomic.AddUint32(&info.Bot.heartbeat, 1)
+		info.Bot.heartbeatReceived(info)
 		return nil
 	}
 	if ch, private := info.Bot.ChannelIsPrivate(channelID); !private && (ch == nil || ch.GuildID != info.ID) {
 		return errInvalidChannel
 	}
 
-	for len(message) > 1999 { // discord has a 2000 character limit
+	parts := splitMessage(message)
+	for _, part := range parts[:len(parts)-1] {
+		info.sendContent(channelID, part, 1)
+	}
+	info.sendContent(channelID, parts[len(parts)-1], 2)
+
+	return nil
+}
+
+// splitMessage breaks a message up into pieces that fit within discord's 2000 character limit, preferring to split
+// on newlines and keeping code blocks intact
+func splitMessage(message string) (parts []string) {
+	for len(message) > 1999 {
 		if message[0:3] == "```" && message[len(message)-3:] == "```" {
 			index := strings.LastIndex(message[:1995], "\n")
 			if index < 10 { // Ensure we process at least 10 characters to prevent an infinite loop
 				index = 1995
 			}
-			info.sendContent(channelID, message[:index]+"```", 1)
+			parts = append(parts, message[:index]+"```")
 			message = "```\n" + message[index:]
 		} else {
 			index := strings.LastIndex(message[:1999], "\n")
 			if index < 10 {
 				index = 1999
 			}
-			info.sendContent(channelID, message[:index], 1)
+			parts = append(parts, message[:index])
 			message = message[index:]
 		}
 	}
-	info.sendContent(channelID, message, 2)
-
-	return nil
+	return append(parts, message)
 }
 
 // ProcessModule returns true if a module should process events on this channel
@@ -262,8 +276,8 @@ func (info *GuildInfo) userBulkUpdate(members []*discordgo.Member) {
 	}
 
 	stmt := fmt.Sprintf("INSERT IGNORE INTO users (ID, Username, Discriminator, Avatar, LastSeen, LastNameChange) VALUES %s", strings.Join(valueStrings, ","))
-	_, err := info.Bot.DB.db.Exec(stmt, valueArgs...)
-	info.LogError("Error in UserBulkUpdate", err)
+	_, err := info.Bot.DB.Exec(stmt, valueArgs...)
+	info.Logger().LogError("Error in UserBulkUpdate: ", err)
 }
 
 func (info *GuildInfo) memberBulkUpdate(members []*discordgo.Member) {
@@ -275,8 +289,8 @@ func (info *GuildInfo) memberBulkUpdate(members []*discordgo.Member) {
 		valueArgs = append(valueArgs, SBatoi(m.User.ID), SBatoi(info.ID), GetJoinedAt(m), m.Nick)
 	}
 	stmt := fmt.Sprintf("INSERT IGNORE INTO members (ID, Guild, FirstSeen, Nickname) VALUES %s", strings.Join(valueStrings, ","))
-	_, err := info.Bot.DB.db.Exec(stmt, valueArgs...)
-	info.LogError("Error in MemberBulkUpdate", err)
+	_, err := info.Bot.DB.Exec(stmt, valueArgs...)
+	info.Logger().LogError("Error in MemberBulkUpdate: ", err)
 }
 
 // ProcessGuild updates guild information and adds the initial member list to the database
@@ -310,8 +324,8 @@ func (info *GuildInfo) FindChannelID(name string) string {
 	if err != nil {
 		return ""
 	}
-	info.Bot.DG.State.RLock()
-	defer info.Bot.DG.State.RUnlock()
+	info.DG.State.RLock()
+	defer info.DG.State.RUnlock()
 	for _, v := range guild.Channels {
 		if v.Name == name {
 			return v.ID
@@ -321,25 +335,6 @@ func (info *GuildInfo) FindChannelID(name string) string {
 	return ""
 }
 
-// Log the given arguments to the server, the command line, and the database
-func (info *GuildInfo) Log(args ...interface{}) {
-	s := fmt.Sprint(args...)
-	fmt.Printf("[%s] %s\n", time.Now().Format(time.Stamp), s)
-	if info.Bot.DB != nil && info != nil && info.Bot.IsMainGuild(info) && info.Bot.DB.Status.Get() {
-		info.Bot.DB.Audit(AuditTypeLog, nil, s, SBatoi(info.ID))
-	}
-	if info != nil && info.Config.Log.Channel != ChannelEmpty {
-		info.SendMessage(info.Config.Log.Channel, "```\n"+s+"```")
-	}
-}
-
-// LogError logs an error only if it exists
-func (info *GuildInfo) LogError(msg string, err error) {
-	if err != nil {
-		info.Log(msg, err.Error())
-	}
-}
-
 // SendError prints an error message with a saturation limit
 func (info *GuildInfo) SendError(channelID DiscordChannel, message string, t int64) {
 	if info != nil && RateLimit(&info.lastlogerr, info.Config.Log.Cooldown, t) { // Don't print more than one error message every n seconds.
@@ -349,7 +344,7 @@ func (info *GuildInfo) SendError(channelID DiscordChannel, message string, t int
 
 // UserHasRole returns true if the specified user ID has the given role ID (both in strings)
 func (info *GuildInfo) UserHasRole(userID DiscordUser, role DiscordRole) bool {
-	m, err := info.Bot.DG.GetMember(userID, info.ID)
+	m, err := info.DG.GetMember(userID, info.ID)
 	if err == nil {
 		return MemberHasRole(m, role)
 	}
@@ -395,7 +390,7 @@ func (info *GuildInfo) UserCanUseCommand(userID DiscordUser, command Command, ig
 		err = errIgnored
 		return
 	}
-	if info.Bot.DG.UserHasAnyRole(userID, info.ID, info.Config.Modules.CommandRoles[name]) {
+	if info.DG.UserHasAnyRole(userID, info.ID, info.Config.Modules.CommandRoles[name]) {
 		return
 	}
 	err = errors.New("You don't have permission to run this command! Allowed Roles: " + info.GetRoles(name))
@@ -407,7 +402,7 @@ func (info *GuildInfo) UserIsAdmin(userID DiscordUser) bool {
 	if userID == info.Bot.Owner {
 		return true
 	}
-	perms, err := info.Bot.DG.UserPermissions(userID, info.ID) // First get permissions from the cache. If this errors out, default to not an admin
+	perms, err := info.DG.UserPermissions(userID, info.ID) // First get permissions from the cache. If this errors out, default to not an admin
 	return err == nil && ((perms & discordgo.PermissionAdministrator) != 0)
 }
 
@@ -423,8 +418,8 @@ func (info *GuildInfo) GetRoles(command CommandID) string {
 		return ""
 	}
 
-	info.Bot.DG.State.RLock()
-	defer info.Bot.DG.State.RUnlock()
+	info.DG.State.RLock()
+	defer info.DG.State.RUnlock()
 	_, reverse := m["!"]
 	s := make([]string, 0, len(m))
 	for k := range m {
@@ -448,12 +443,12 @@ func (info *GuildInfo) GetChannels(command CommandID) string {
 		return ""
 	}
 
-	info.Bot.DG.State.RLock()
-	defer info.Bot.DG.State.RUnlock()
+	info.DG.State.RLock()
+	defer info.DG.State.RUnlock()
 	_, reverse := m["!"]
 	s := make([]string, 0, len(m))
 	for k := range m {
-		c, err := info.Bot.DG.State.Channel(k.String())
+		c, err := info.DG.State.Channel(k.String())
 		if err == nil {
 			s = append(s, "#"+c.Name)
 		}
@@ -467,26 +462,42 @@ func (info *GuildInfo) GetChannels(command CommandID) string {
 	return strings.Join(s, ", ")
 }
 
+// UsageLine returns a one line summary of how to call a command, like "> !ban {user} [for: duration] [reason]"
+func (info *GuildInfo) UsageLine(c Command, usage *CommandUsage) string {
+	use := "> " + info.Config.Basic.CommandPrefix + strings.ToLower(c.Info().Name)
+	for _, v := range usage.Params {
+		if v.Optional {
+			use += fmt.Sprintf(" [%s]", v.Display())
+		} else {
+			use += fmt.Sprintf(" {%s}", v.Display())
+		}
+		if v.Variadic {
+			use += "..."
+		}
+	}
+	return use
+}
+
 // FormatUsage constructs a help string for the given command based on it's usage
 func (info *GuildInfo) FormatUsage(c Command, usage *CommandUsage) *discordgo.MessageEmbed {
 	name := CommandID(strings.ToLower(c.Info().Name))
 	r := info.GetRoles(name)
 	ch := info.GetChannels(name)
 	fields := make([]*discordgo.MessageEmbedField, 0, len(usage.Params))
-	use := "> " + info.Config.Basic.CommandPrefix + string(name)
+	use := info.UsageLine(c, usage)
 	for _, v := range usage.Params {
 		opt := ""
 		if v.Optional {
 			opt = " [OPTIONAL]"
-			use += fmt.Sprintf(" [%s]", v.Name)
-		} else {
-			use += fmt.Sprintf(" {%s}", v.Name)
 		}
 		if v.Variadic {
 			opt = " (...) " + opt
-			use += "..."
 		}
-		fields = append(fields, &discordgo.MessageEmbedField{Name: v.Name + opt, Value: v.Desc, Inline: false})
+		desc := v.Desc
+		if v.Type != ArgString {
+			desc += "\n*Expects " + v.Hint() + ".*"
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{Name: v.Display() + opt, Value: desc, Inline: false})
 	}
 
 	if len(ch) > 0 {
@@ -547,7 +558,7 @@ func (info *GuildInfo) setupSilenceRole() {
 	if info.Config.Basic.SilenceRole != RoleEmpty {
 		guild, err := info.GetGuild()
 		if err != nil {
-			info.Log("Failed to setup silence roles!")
+			info.Logger().LogError("Failed to setup silence roles: ", err)
 			return
 		}
 		for _, ch := range guild.Channels {
@@ -625,13 +636,13 @@ func (info *GuildInfo) findUsernameInternal(arg string, serveronly bool, recurse
 	}
 	if len(discriminant) > 0 {
 		for _, v := range r {
-			m, err := info.Bot.DG.GetMember(NewDiscordUser(v), info.ID)
+			m, err := info.DG.GetMember(NewDiscordUser(v), info.ID)
 			if err == nil && m.User.Discriminator == discriminant && strings.ToLower(m.User.Username) == user {
 				return []uint64{v}
 			}
 		}
 		for _, v := range r {
-			m, err := info.Bot.DG.GetMember(NewDiscordUser(v), info.ID)
+			m, err := info.DG.GetMember(NewDiscordUser(v), info.ID)
 			if err == nil && m.User.Discriminator == discriminant && strings.ToLower(m.Nick) == user {
 				return []uint64{v}
 			}
@@ -649,7 +660,7 @@ func (info *GuildInfo) findUsernameInternal(arg string, serveronly bool, recurse
 // GetUserName returns a string representation of the user's name if possible, otherwise pings them.
 func (info *GuildInfo) GetUserName(user DiscordUser) string {
 	u := user.String()
-	m, _ := info.Bot.DG.State.Member(info.ID, u)
+	m, _ := info.DG.State.Member(info.ID, u)
 	if m == nil {
 		return "<@" + u + ">"
 	}
@@ -661,14 +672,14 @@ func (info *GuildInfo) GetUserName(user DiscordUser) string {
 
 // GetGuild returns the guild object associated with this info object
 func (info *GuildInfo) GetGuild() (*discordgo.Guild, error) {
-	return info.Bot.DG.State.Guild(info.ID)
+	return info.DG.State.Guild(info.ID)
 }
 
 // IDsToUsernames converts an array of integer IDs to an array of username strings
 func (info *GuildInfo) IDsToUsernames(IDs []uint64, discriminator bool) []string {
 	s := make([]string, 0, len(IDs))
 	for _, v := range IDs {
-		m, _ := info.Bot.DG.State.Member(info.ID, SBitoa(v))
+		m, _ := info.DG.State.Member(info.ID, SBitoa(v))
 		if m != nil {
 			if len(m.Nick) > 0 {
 				if discriminator {
@@ -714,7 +725,7 @@ func (info *GuildInfo) Sanitize(s string, flags int) string {
 		s = urlregex.ReplaceAllStringFunc(s, func(str string) string { return "<" + str + ">" })
 	}
 	if (flags & CleanMentions) != 0 {
-		s = info.Bot.DG.ReplaceAllMentions(s, info.Bot.DB, info.ID)
+		s = info.DG.ReplaceAllMentions(s, info.Bot.DB, info.ID)
 	}
 	if (flags & CleanPings) != 0 {
 		s = mentionRegex.ReplaceAllStringFunc(s, func(str string) string { return "<\\@" + str[2:] })
@@ -733,7 +744,7 @@ func (info *GuildInfo) BulkDelete(channel *discordgo.Channel, messages []string)
 	if channel == nil || channel.GuildID != info.ID {
 		return errInvalidChannel
 	}
-	return info.Bot.DG.BulkDeleteBypass(channel.ID, messages)
+	return info.DG.BulkDeleteBypass(channel.ID, messages)
 }
 
 // ChannelMessageDelete checks the channel guildID before calling the real ChannelMessageDelete
@@ -741,7 +752,7 @@ func (info *GuildInfo) ChannelMessageDelete(channel *discordgo.Channel, messageI
 	if channel == nil || channel.GuildID != info.ID {
 		return errInvalidChannel
 	}
-	return info.Bot.DG.ChannelMessageDelete(channel.ID, messageID)
+	return info.DG.ChannelMessageDelete(channel.ID, messageID)
 }
 
 // ChannelPermissionSet check the channel guildID before calling the real ChannelPermissionSet
@@ -749,7 +760,15 @@ func (info *GuildInfo) ChannelPermissionSet(channel *discordgo.Channel, targetID
 	if channel == nil || channel.GuildID != info.ID {
 		return errInvalidChannel
 	}
-	return info.Bot.DG.ChannelPermissionSet(channel.ID, targetID, targetType, allow, deny)
+	return info.DG.ChannelPermissionSet(channel.ID, targetID, targetType, allow, deny)
+}
+
+// ChannelPermissionDelete checks the channel guildID before calling the real ChannelPermissionDelete
+func (info *GuildInfo) ChannelPermissionDelete(channel *discordgo.Channel, targetID string) (err error) {
+	if channel == nil || channel.GuildID != info.ID {
+		return errInvalidChannel
+	}
+	return info.DG.ChannelPermissionDelete(channel.ID, targetID)
 }
 
 // Clean out all commands or modules that no longer exist
@@ -797,7 +816,7 @@ func (info *GuildInfo) Clean() {
 
 func (info *GuildInfo) ResolveRoleAddError(err error) error {
 	if err != nil {
-		if perms, err := info.Bot.DG.UserPermissions(info.Bot.SelfID, info.ID); err == nil && (perms&discordgo.PermissionManageRoles) == 0 {
+		if perms, err := info.DG.UserPermissions(info.Bot.SelfID, info.ID); err == nil && (perms&discordgo.PermissionManageRoles) == 0 {
 			return errors.New("I can't change roles because I don't have the Manage Roles permission!")
 		}
 		msg := "http 403 forbidden"
@@ -807,3 +826,41 @@ func (info *GuildInfo) ResolveRoleAddError(err error) error {
 	}
 	return err
 }
+
+// MaxTimeout is the longest discord allows a member to be timed out for
+const MaxTimeout = 28 * 24 * time.Hour
+
+// TimeoutMember times out a member until the given time. If the database is available, this also replaces any earlier
+// expiry event for the member with one that tells the mod channel when this timeout ends, and returns its ID.
+func (info *GuildInfo) TimeoutMember(user DiscordUser, until time.Time) (*uint64, error) {
+	if until.Sub(time.Now().UTC()) > MaxTimeout {
+		return nil, errors.New("Discord can't time someone out for longer than 28 days!")
+	}
+	if err := info.DG.TimeoutMember(info.ID, user, until); err != nil {
+		return nil, err
+	}
+	if !info.Bot.DB.Status.Get() {
+		return nil, nil
+	}
+	gID := SBatoi(info.ID)
+	if id := info.Bot.DB.FindEvent(user.String(), gID, EventTimeout); id != nil {
+		info.Bot.DB.RemoveSchedule(*id)
+	}
+	if err := info.Bot.DB.AddSchedule(gID, until, EventTimeout, user.String()); err != nil {
+		return nil, err
+	}
+	return info.Bot.DB.FindEvent(user.String(), gID, EventTimeout), nil
+}
+
+// TimedOut returns when a member's timeout ends, or nil if they aren't timed out. discordgo doesn't track timeouts, so
+// this relies on the expiry event created by TimeoutMember.
+func (info *GuildInfo) TimedOut(user DiscordUser) *time.Time {
+	if !info.Bot.DB.Status.Get() {
+		return nil
+	}
+	t := info.Bot.DB.GetScheduleDate(SBatoi(info.ID), EventTimeout, user.String())
+	if t == nil || !t.After(time.Now().UTC()) {
+		return nil
+	}
+	return t
+}
@@ -270,17 +270,17 @@ func TestLog(t *testing.T) {
 	for _, v := range sb.Guilds {
 		mock.Expect(v.Bot.DG.RequestWithLockedBucket, "POST", discordgo.EndpointChannelMessages(v.Config.Log.Channel.String()), "application/json", MockAny{}, MockAny{}, 0)
 		dbmock.ExpectExec("INSERT INTO debuglog.*").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
-		v.Log("Test")
+		v.Logger().Info("Test")
 	}
 }
 
 func TestLogError(t *testing.T) {
 	sb, dbmock, _ := MockSweetieBot(t)
 	for _, v := range sb.Guilds {
-		v.LogError("Test", nil)
+		v.Logger().LogError("Test", nil)
 		mock.Expect(v.Bot.DG.RequestWithLockedBucket, "POST", discordgo.EndpointChannelMessages(v.Config.Log.Channel.String()), "application/json", MockAny{}, MockAny{}, 0)
 		dbmock.ExpectExec("INSERT INTO debuglog.*").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
-		v.LogError("test: ", errors.New("Ignore this error"))
+		v.Logger().LogError("test: ", errors.New("Ignore this error"))
 	}
 }
 
@@ -594,6 +594,18 @@ func TestChannelPermissionSet(t *testing.T) {
 	}
 }
 
+func TestChannelPermissionDelete(t *testing.T) {
+	sb, _, _ := MockSweetieBot(t)
+	for k, v := range sb.Guilds {
+		i := int(k.Convert() & 0xFF)
+		ch := mockDiscordChannel(TestChannelFree, i)
+		Check(v.ChannelPermissionDelete(nil, ""), errInvalidChannel, t)
+		Check(v.ChannelPermissionDelete(mockDiscordChannel(1234, 999), ""), errInvalidChannel, t)
+		mock.Expect(v.Bot.DG.ChannelPermissionDelete, ch.ID, "1")
+		Check(v.ChannelPermissionDelete(ch, "1"), nil, t)
+	}
+}
+
 func TestNewGuildInfo(t *testing.T) {
 	sb, _, _ := MockSweetieBot(t)
 	g := NewGuildInfo(sb, &discordgo.Guild{
@@ -23,10 +23,10 @@ func (w *InfoModule) Name() string {
 // Commands in the module
 func (w *InfoModule) Commands() []Command {
 	return []Command{
-		&helpCommand{},
-		&aboutCommand{},
-		&rulesCommand{},
-		&changelogCommand{},
+		TypedCommand(&helpCommand{}),
+		TypedCommand(&aboutCommand{}),
+		TypedCommand(&rulesCommand{}),
+		TypedCommand(&changelogCommand{}),
 	}
 }
 
@@ -92,11 +92,11 @@ func DumpCommandsModules(info *GuildInfo, footer string, description string, msg
 	}
 }
 
-func (c *helpCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	if len(args) == 0 {
+func (c *helpCommand) ProcessArgs(args *CommandArgs, msg *discordgo.Message, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !args.Has("command/module") {
 		return "", true, DumpCommandsModules(info, "For more information on a specific command, type !help [command].", "", msg)
 	}
-	arg := strings.ToLower(args[0])
+	arg := strings.ToLower(args.String("command/module"))
 	for _, v := range info.Modules {
 		if strings.Compare(strings.ToLower(v.Name()), arg) == 0 {
 			cmds := v.Commands()
@@ -170,7 +170,7 @@ func (c *aboutCommand) Info() *CommandInfo {
 		ServerIndependent: true,
 	}
 }
-func (c *aboutCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *aboutCommand) ProcessArgs(args *CommandArgs, msg *discordgo.Message, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	tag := " [release]"
 	if info.Bot.Debug {
 		tag = " [debug]"
@@ -217,11 +217,11 @@ func (c *rulesCommand) Info() *CommandInfo {
 func (c *rulesCommand) Name() string {
 	return "Rules"
 }
-func (c *rulesCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *rulesCommand) ProcessArgs(args *CommandArgs, msg *discordgo.Message, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if len(info.Config.Information.Rules) == 0 {
 		return "```\nI don't know what the rules are in this server... ¯\\_(ツ)_/¯```", false, nil
 	}
-	if len(args) < 1 {
+	if !args.Has("index") {
 		rules := make([]string, 0, len(info.Config.Information.Rules)+1)
 		rules = append(rules, "Official rules of "+info.Name+":")
 		keys := MapIntToSlice(info.Config.Information.Rules)
@@ -235,10 +235,7 @@ func (c *rulesCommand) Process(args []string, msg *discordgo.Message, indices []
 		return strings.Join(rules, "\n"), len(rules) > maxPublicRules, nil
 	}
 
-	arg, err := strconv.Atoi(args[0])
-	if err != nil {
-		return "```\nRule index must be a number!```", false, nil
-	}
+	arg := args.Int("index", 0)
 	rule, ok := info.Config.Information.Rules[arg]
 	if !ok {
 		return "```\nThat's not a rule! Stop making things up!```", false, nil
@@ -249,7 +246,7 @@ func (c *rulesCommand) Usage(info *GuildInfo) *CommandUsage {
 	return &CommandUsage{
 		Desc: "Lists all the rules in this server, or displays the specific rule requested, if it exists. Rules can be set using `" + info.Config.Basic.CommandPrefix + "setconfig rules 1 this is a rule`",
 		Params: []CommandUsageParam{
-			{Name: "index", Desc: "Index of the rule to display. If omitted, displays all rules.", Optional: true},
+			{Name: "index", Desc: "Index of the rule to display. If omitted, displays all rules.", Optional: true, Type: ArgInt},
 		},
 	}
 }
@@ -266,9 +263,9 @@ func (c *changelogCommand) Info() *CommandInfo {
 		Usage: "Retrieves the changelog for the bot.",
 	}
 }
-func (c *changelogCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *changelogCommand) ProcessArgs(args *CommandArgs, msg *discordgo.Message, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	v := Version{0, 0, 0, 0}
-	if len(args) == 0 {
+	if !args.Has("version") {
 		versions := make([]string, 0, len(info.Bot.changelog)+1)
 		versions = append(versions, "All versions of "+info.GetBotName()+" with a changelog:")
 		keys := MapIntToSlice(info.Bot.changelog)
@@ -280,10 +277,10 @@ func (c *changelogCommand) Process(args []string, msg *discordgo.Message, indice
 		}
 		return "```\n" + strings.Join(versions, "\n") + "```", len(versions) > MaxPublicLines, nil
 	}
-	if strings.ToLower(args[0]) == "current" {
+	if strings.ToLower(args.String("version")) == "current" {
 		v = BotVersion
 	} else {
-		s := strings.Split(args[0], ".")
+		s := strings.Split(args.String("version"), ".")
 		if len(s) > 0 {
 			i, _ := strconv.Atoi(s[0])
 			v.major = byte(i)
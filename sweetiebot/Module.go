@@ -89,6 +89,18 @@ type ModuleOnGuildRoleDelete interface {
 	OnGuildRoleDelete(*GuildInfo, *discordgo.GuildRoleDelete)
 }
 
+// ModuleOnMessageReactionAdd hook interface
+type ModuleOnMessageReactionAdd interface {
+	Module
+	OnMessageReactionAdd(*GuildInfo, *discordgo.MessageReaction)
+}
+
+// ModuleOnMessageReactionRemove hook interface
+type ModuleOnMessageReactionRemove interface {
+	Module
+	OnMessageReactionRemove(*GuildInfo, *discordgo.MessageReaction)
+}
+
 // ModuleOnCommand hook interface
 type ModuleOnCommand interface {
 	Module
@@ -114,6 +126,9 @@ type CommandUsageParam struct {
 	Desc     string
 	Optional bool
 	Variadic bool
+	Type     ArgType  // How ParseCommandArgs validates this parameter
+	Values   []string // Allowed values of an ArgEnum parameter
+	Keyword  string   // If set, the parameter must be introduced by this keyword, like "for:"
 }
 
 // CommandUsage defines the help parameters for a command
@@ -141,20 +156,22 @@ type Command interface {
 }
 
 type moduleHooks struct {
-	OnEvent             []ModuleOnEvent
-	OnMessageCreate     []ModuleOnMessageCreate
-	OnMessageUpdate     []ModuleOnMessageUpdate
-	OnMessageDelete     []ModuleOnMessageDelete
-	OnGuildUpdate       []ModuleOnGuildUpdate
-	OnGuildMemberAdd    []ModuleOnGuildMemberAdd
-	OnGuildMemberRemove []ModuleOnGuildMemberRemove
-	OnGuildMemberUpdate []ModuleOnGuildMemberUpdate
-	OnGuildBanAdd       []ModuleOnGuildBanAdd
-	OnGuildBanRemove    []ModuleOnGuildBanRemove
-	OnGuildRoleDelete   []ModuleOnGuildRoleDelete
-	OnCommand           []ModuleOnCommand
-	OnIdle              []ModuleOnIdle
-	OnTick              []ModuleOnTick
+	OnEvent                 []ModuleOnEvent
+	OnMessageCreate         []ModuleOnMessageCreate
+	OnMessageUpdate         []ModuleOnMessageUpdate
+	OnMessageDelete         []ModuleOnMessageDelete
+	OnGuildUpdate           []ModuleOnGuildUpdate
+	OnGuildMemberAdd        []ModuleOnGuildMemberAdd
+	OnGuildMemberRemove     []ModuleOnGuildMemberRemove
+	OnGuildMemberUpdate     []ModuleOnGuildMemberUpdate
+	OnGuildBanAdd           []ModuleOnGuildBanAdd
+	OnGuildBanRemove        []ModuleOnGuildBanRemove
+	OnGuildRoleDelete       []ModuleOnGuildRoleDelete
+	OnMessageReactionAdd    []ModuleOnMessageReactionAdd
+	OnMessageReactionRemove []ModuleOnMessageReactionRemove
+	OnCommand               []ModuleOnCommand
+	OnIdle                  []ModuleOnIdle
+	OnTick                  []ModuleOnTick
 }
 
 // RegisterModule registers a module with this guild
@@ -192,6 +209,12 @@ func (info *GuildInfo) RegisterModule(m Module) {
 	if h, ok := m.(ModuleOnGuildRoleDelete); ok {
 		info.hooks.OnGuildRoleDelete = append(info.hooks.OnGuildRoleDelete, h)
 	}
+	if h, ok := m.(ModuleOnMessageReactionAdd); ok {
+		info.hooks.OnMessageReactionAdd = append(info.hooks.OnMessageReactionAdd, h)
+	}
+	if h, ok := m.(ModuleOnMessageReactionRemove); ok {
+		info.hooks.OnMessageReactionRemove = append(info.hooks.OnMessageReactionRemove, h)
+	}
 	if h, ok := m.(ModuleOnCommand); ok {
 		info.hooks.OnCommand = append(info.hooks.OnCommand, h)
 	}
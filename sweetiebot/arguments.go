@@ -0,0 +1,383 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+// ArgType tells the argument parser how to validate and convert a command parameter
+type ArgType uint8
+
+// Argument types understood by ParseCommandArgs. ArgString is the default, so untyped parameters keep working.
+const (
+	ArgString ArgType = iota
+	ArgUser
+	ArgRole
+	ArgChannel
+	ArgInt
+	ArgDuration
+	ArgTime
+	ArgEnum
+	ArgRest
+)
+
+// maxTimeTokens is the most arguments a single time parameter can span, e.g. "Jan 2 2006 3:04pm PST" is 5
+const maxTimeTokens = 6
+
+var intervalNames = map[uint8]string{1: "second", 2: "minute", 3: "hour", 4: "day", 5: "week", 6: "month", 7: "quarter", 8: "year"}
+
+// Display returns the parameter as it appears in a usage line, including its keyword
+func (p CommandUsageParam) Display() string {
+	if len(p.Keyword) > 0 {
+		return p.Keyword + " " + p.Name
+	}
+	return p.Name
+}
+
+// Hint describes what kind of value the parameter expects
+func (p CommandUsageParam) Hint() string {
+	switch p.Type {
+	case ArgUser:
+		return "a ping of a user, or their name"
+	case ArgRole:
+		return "a ping of a role, or its name"
+	case ArgChannel:
+		return "a ping of a channel, or its name"
+	case ArgInt:
+		return "a whole number"
+	case ArgDuration:
+		return "a duration like 5 MINUTES or 2 DAYS"
+	case ArgTime:
+		return "a time like 3:04pm or Jan 2 3:04pm"
+	case ArgEnum:
+		return "one of: " + strings.Join(p.Values, ", ")
+	case ArgRest:
+		return "the rest of the message"
+	}
+	return "some text"
+}
+
+// Duration is an amount of calendar time, like 5 minutes or 2 months, that can be added to a point in time
+type Duration struct {
+	Count    int
+	Interval uint8 // See ParseRepeatInterval
+}
+
+// After returns the time that is d after t
+func (d Duration) After(t time.Time) time.Time {
+	return addRepeatInterval(t, d.Interval, d.Count)
+}
+
+// Before returns the time that is d before t
+func (d Duration) Before(t time.Time) time.Time {
+	return addRepeatInterval(t, d.Interval, -d.Count)
+}
+
+func (d Duration) String() string {
+	s := strconv.Itoa(d.Count) + " " + intervalNames[d.Interval]
+	if d.Count != 1 {
+		s += "s"
+	}
+	return s
+}
+
+// CommandArgs holds the converted arguments of a command, keyed by parameter name
+type CommandArgs struct {
+	values map[string][]interface{}
+}
+
+// Has returns true if the parameter was given
+func (a *CommandArgs) Has(name string) bool {
+	return len(a.values[name]) > 0
+}
+
+func (a *CommandArgs) get(name string) interface{} {
+	if v := a.values[name]; len(v) > 0 {
+		return v[0]
+	}
+	return nil
+}
+
+// String returns a string, enum or rest-of-line parameter, or an empty string if it wasn't given
+func (a *CommandArgs) String(name string) string {
+	s, _ := a.get(name).(string)
+	return s
+}
+
+// Strings returns every value of a variadic string parameter
+func (a *CommandArgs) Strings(name string) []string {
+	r := make([]string, 0, len(a.values[name]))
+	for _, v := range a.values[name] {
+		r = append(r, v.(string))
+	}
+	return r
+}
+
+// User returns a user parameter, or UserEmpty if it wasn't given
+func (a *CommandArgs) User(name string) DiscordUser {
+	if u, ok := a.get(name).(DiscordUser); ok {
+		return u
+	}
+	return UserEmpty
+}
+
+// Users returns every value of a variadic user parameter
+func (a *CommandArgs) Users(name string) []DiscordUser {
+	r := make([]DiscordUser, 0, len(a.values[name]))
+	for _, v := range a.values[name] {
+		r = append(r, v.(DiscordUser))
+	}
+	return r
+}
+
+// Role returns a role parameter, or RoleEmpty if it wasn't given
+func (a *CommandArgs) Role(name string) DiscordRole {
+	if r, ok := a.get(name).(DiscordRole); ok {
+		return r
+	}
+	return RoleEmpty
+}
+
+// Channel returns a channel parameter, or ChannelEmpty if it wasn't given
+func (a *CommandArgs) Channel(name string) DiscordChannel {
+	if c, ok := a.get(name).(DiscordChannel); ok {
+		return c
+	}
+	return ChannelEmpty
+}
+
+// Int returns an integer parameter, or def if it wasn't given
+func (a *CommandArgs) Int(name string, def int) int {
+	if i, ok := a.get(name).(int); ok {
+		return i
+	}
+	return def
+}
+
+// Duration returns a duration parameter, or a zero duration if it wasn't given
+func (a *CommandArgs) Duration(name string) Duration {
+	d, _ := a.get(name).(Duration)
+	return d
+}
+
+// Time returns a time parameter, or the zero time if it wasn't given
+func (a *CommandArgs) Time(name string) time.Time {
+	t, _ := a.get(name).(time.Time)
+	return t
+}
+
+func (a *CommandArgs) add(name string, v interface{}) {
+	a.values[name] = append(a.values[name], v)
+}
+
+func isKeyword(s string, params []CommandUsageParam) bool {
+	for _, p := range params {
+		if len(p.Keyword) > 0 && strings.EqualFold(p.Keyword, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// greedy returns true if a parameter can take up every argument until the next keyword, which is only unambiguous if no
+// parameter without a keyword comes after it, except for whole numbers right after it, which are taken from the end.
+func greedy(p CommandUsageParam, rest []CommandUsageParam) bool {
+	if p.Type != ArgUser && !p.Variadic {
+		return false
+	}
+	rest = rest[trailingInts(rest):]
+	for _, v := range rest {
+		if len(v.Keyword) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// trailingInts returns how many of the parameters at the start of rest are whole numbers without a keyword
+func trailingInts(rest []CommandUsageParam) int {
+	n := 0
+	for n < len(rest) && rest[n].Type == ArgInt && len(rest[n].Keyword) == 0 {
+		n++
+	}
+	return n
+}
+
+func argError(p *CommandUsageParam, s string, err error) error {
+	if err == nil || err == errNotUser || err == errNotRole || err == errNotChannel {
+		return fmt.Errorf("Invalid {%s} \"%s\": expected %s.", p.Name, s, p.Hint())
+	}
+	return fmt.Errorf("Invalid {%s} \"%s\": %s", p.Name, s, err.Error())
+}
+
+// convertArg converts a single argument to the parameter's type
+func convertArg(p *CommandUsageParam, s string, info *GuildInfo) (interface{}, error) {
+	switch p.Type {
+	case ArgUser:
+		u, err := ParseUser(s, info)
+		if err != nil {
+			return nil, argError(p, s, err)
+		}
+		return u, nil
+	case ArgRole, ArgChannel:
+		guild, err := info.GetGuild()
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if p.Type == ArgRole {
+			v, err = ParseRole(s, guild)
+		} else {
+			v, err = ParseChannel(s, guild)
+		}
+		if err != nil {
+			return nil, argError(p, s, err)
+		}
+		return v, nil
+	case ArgInt:
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, argError(p, s, nil)
+		}
+		return i, nil
+	case ArgEnum:
+		for _, v := range p.Values {
+			if strings.EqualFold(v, s) {
+				return v, nil
+			}
+		}
+		return nil, argError(p, s, nil)
+	}
+	return s, nil
+}
+
+// ParseCommandArgs validates args against the parameters in usage and converts them to their types. Parameters are
+// matched in order: a parameter with a keyword is only matched if its keyword is the next argument, and optional
+// parameters are skipped when they run out of arguments. Arguments left over after the last parameter are ignored.
+func ParseCommandArgs(usage *CommandUsage, args []string, indices []int, msg *discordgo.Message, info *GuildInfo) (*CommandArgs, error) {
+	r := &CommandArgs{values: make(map[string][]interface{})}
+	i := 0
+	for k := range usage.Params {
+		p := &usage.Params[k]
+		if len(p.Keyword) > 0 {
+			if i >= len(args) || !strings.EqualFold(args[i], p.Keyword) {
+				if p.Optional {
+					continue
+				}
+				return nil, fmt.Errorf("Missing {%s}: expected %s followed by %s.", p.Name, p.Keyword, p.Hint())
+			}
+			i++
+		}
+		if i >= len(args) || isKeyword(args[i], usage.Params[k+1:]) {
+			if p.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("Missing {%s}: expected %s.", p.Name, p.Hint())
+		}
+
+		switch {
+		case p.Type == ArgRest:
+			if i < len(indices) && indices[i] <= len(msg.Content) {
+				r.add(p.Name, strings.TrimSpace(msg.Content[indices[i]:]))
+			} else {
+				r.add(p.Name, strings.Join(args[i:], " "))
+			}
+			i = len(args)
+		case p.Type == ArgDuration:
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("Missing {%s}: expected %s.", p.Name, p.Hint())
+			}
+			count, err := strconv.Atoi(args[i])
+			interval := ParseRepeatInterval(args[i+1])
+			if err != nil || interval == 255 {
+				return nil, argError(p, args[i]+" "+args[i+1], nil)
+			}
+			if count < 1 {
+				return nil, argError(p, args[i]+" "+args[i+1], fmt.Errorf("%s is not a positive number!", args[i]))
+			}
+			r.add(p.Name, Duration{count, interval})
+			i += 2
+		case p.Type == ArgTime:
+			end := i + maxTimeTokens
+			if end > len(args) {
+				end = len(args)
+			}
+			for ; end > i; end-- {
+				t, err := info.ParseCommonTime(strings.Join(args[i:end], " "), DiscordUser(msg.Author.ID), GetTimestamp(msg))
+				if err == nil {
+					r.add(p.Name, t)
+					break
+				}
+			}
+			if end == i {
+				return nil, argError(p, args[i], nil)
+			}
+			i = end
+		case greedy(*p, usage.Params[k+1:]):
+			end := i + 1
+			for end < len(args) && !isKeyword(args[end], usage.Params[k+1:]) {
+				end++
+			}
+			// Leave the numbers at the end to the parameters after this one, but never take away its first argument
+			for n := trailingInts(usage.Params[k+1:]); n > 0 && end-1 > i; n-- {
+				if _, err := strconv.Atoi(args[end-1]); err != nil && usage.Params[k+n].Optional {
+					break
+				}
+				end--
+			}
+			if p.Variadic {
+				for _, s := range args[i:end] {
+					v, err := convertArg(p, s, info)
+					if err != nil {
+						return nil, err
+					}
+					r.add(p.Name, v)
+				}
+			} else {
+				v, err := convertArg(p, strings.Join(args[i:end], " "), info)
+				if err != nil {
+					return nil, err
+				}
+				r.add(p.Name, v)
+			}
+			i = end
+		default:
+			v, err := convertArg(p, args[i], info)
+			if err != nil {
+				return nil, err
+			}
+			r.add(p.Name, v)
+			i++
+		}
+	}
+	return r, nil
+}
+
+// ArgCommand is a command whose arguments are parsed and validated according to its usage before it is run
+type ArgCommand interface {
+	Info() *CommandInfo
+	ProcessArgs(*CommandArgs, *discordgo.Message, *GuildInfo) (string, bool, *discordgo.MessageEmbed)
+	Usage(*GuildInfo) *CommandUsage
+}
+
+type typedCommand struct {
+	ArgCommand
+}
+
+// TypedCommand wraps an ArgCommand so it can be returned from a module's Commands()
+func TypedCommand(c ArgCommand) Command {
+	return &typedCommand{c}
+}
+
+func (c *typedCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	usage := c.Usage(info)
+	a, err := ParseCommandArgs(usage, args, indices, msg, info)
+	if err != nil {
+		return "```\n" + err.Error() + "\nUsage: " + info.UsageLine(c, usage) + "```", false, nil
+	}
+	return c.ProcessArgs(a, msg, info)
+}
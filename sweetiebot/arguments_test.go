@@ -0,0 +1,127 @@
+package sweetiebot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var argTestUsage = &CommandUsage{
+	Params: []CommandUsageParam{
+		{Name: "user", Type: ArgUser},
+		{Name: "duration", Optional: true, Type: ArgDuration, Keyword: "for:"},
+		{Name: "mode", Optional: true, Type: ArgEnum, Values: []string{"quiet", "loud"}},
+		{Name: "count", Optional: true, Type: ArgInt},
+		{Name: "reason", Optional: true, Type: ArgRest},
+	},
+}
+
+func parseTestArgs(usage *CommandUsage, content string) (*CommandArgs, error) {
+	args, indices := ParseArguments(content[1:])
+	return ParseCommandArgs(usage, args[1:], indices[1:], MockMessage(content, TestChannel, 0, TestUserBoring, 0), nil)
+}
+
+func TestParseCommandArgs(t *testing.T) {
+	t.Parallel()
+
+	a, err := parseTestArgs(argTestUsage, "!test <@123> for: 5 minutes LOUD 3   because  reasons")
+	Check(err, nil, t)
+	Check(a.User("user"), DiscordUser("123"), t)
+	Check(a.Duration("duration"), Duration{5, 2}, t)
+	Check(a.String("mode"), "loud", t)
+	Check(a.Int("count", 0), 3, t)
+	Check(a.String("reason"), "because  reasons", t)
+
+	a, err = parseTestArgs(argTestUsage, "!test <@123> quiet")
+	Check(err, nil, t)
+	Check(a.Has("duration"), false, t)
+	Check(a.Has("reason"), false, t)
+	Check(a.Int("count", 7), 7, t)
+	Check(a.String("mode"), "quiet", t)
+
+	_, err = parseTestArgs(argTestUsage, "!test")
+	Check(err.Error(), "Missing {user}: expected a ping of a user, or their name.", t)
+	_, err = parseTestArgs(argTestUsage, "!test <@123> for: 5")
+	Check(err.Error(), "Missing {duration}: expected a duration like 5 MINUTES or 2 DAYS.", t)
+	_, err = parseTestArgs(argTestUsage, "!test <@123> for: five minutes")
+	Check(err.Error(), "Invalid {duration} \"five minutes\": expected a duration like 5 MINUTES or 2 DAYS.", t)
+	_, err = parseTestArgs(argTestUsage, "!test <@123> for: -5 days")
+	Check(err.Error(), "Invalid {duration} \"-5 days\": -5 is not a positive number!", t)
+	_, err = parseTestArgs(argTestUsage, "!test <@123> for: 0 minutes")
+	Check(err.Error(), "Invalid {duration} \"0 minutes\": 0 is not a positive number!", t)
+	_, err = parseTestArgs(argTestUsage, "!test <@123> sideways")
+	Check(err.Error(), "Invalid {mode} \"sideways\": expected one of: quiet, loud.", t)
+	_, err = parseTestArgs(argTestUsage, "!test <@123> quiet 2.5")
+	Check(err.Error(), "Invalid {count} \"2.5\": expected a whole number.", t)
+	_, err = parseTestArgs(argTestUsage, "!test notauser")
+	Check(err.Error(), "Invalid {user} \"notauser\": expected a ping of a user, or their name.", t)
+}
+
+func TestParseCommandArgsGreedy(t *testing.T) {
+	t.Parallel()
+
+	usage := &CommandUsage{
+		Params: []CommandUsageParam{
+			{Name: "words", Variadic: true},
+			{Name: "duration", Optional: true, Type: ArgDuration, Keyword: "for:"},
+		},
+	}
+	a, err := parseTestArgs(usage, "!test a \"b c\" d FOR: 1 day")
+	Check(err, nil, t)
+	Check(strings.Join(a.Strings("words"), "|"), "a|b c|d", t)
+	Check(a.Duration("duration").String(), "1 day", t)
+
+	a, err = parseTestArgs(usage, "!test a b")
+	Check(err, nil, t)
+	Check(len(a.Strings("words")), 2, t)
+
+	_, err = parseTestArgs(usage, "!test for: 1 day")
+	Check(err.Error(), "Missing {words}: expected some text.", t)
+
+	// Commands run by the fuzzer and by slash commands don't always have indices, so the rest of the line is rebuilt
+	rest := &CommandUsage{Params: []CommandUsageParam{{Name: "text", Type: ArgRest}}}
+	a, err = ParseCommandArgs(rest, []string{"some", "text"}, []int{}, MockMessage("", TestChannel, 0, TestUserBoring, 0), nil)
+	Check(err, nil, t)
+	Check(a.String("text"), "some text", t)
+
+	// Whole numbers after a greedy parameter are taken from the end
+	numbered := &CommandUsage{
+		Params: []CommandUsageParam{
+			{Name: "words", Variadic: true},
+			{Name: "index", Optional: true, Type: ArgInt},
+		},
+	}
+	a, err = parseTestArgs(numbered, "!test a b 3")
+	Check(err, nil, t)
+	Check(strings.Join(a.Strings("words"), "|"), "a|b", t)
+	Check(a.Int("index", 0), 3, t)
+	a, err = parseTestArgs(numbered, "!test a b")
+	Check(err, nil, t)
+	Check(strings.Join(a.Strings("words"), "|"), "a|b", t)
+	Check(a.Has("index"), false, t)
+	a, err = parseTestArgs(numbered, "!test 3")
+	Check(err, nil, t)
+	Check(strings.Join(a.Strings("words"), "|"), "3", t)
+	numbered.Params[1].Optional = false
+	_, err = parseTestArgs(numbered, "!test a b")
+	Check(err.Error(), "Invalid {index} \"b\": expected a whole number.", t)
+}
+
+func TestDuration(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2019, 1, 31, 12, 0, 0, 0, time.UTC)
+	Check(Duration{90, 1}.After(start), start.Add(90*time.Second), t)
+	Check(Duration{2, 5}.After(start), start.AddDate(0, 0, 14), t)
+	Check(Duration{1, 6}.After(start), time.Date(2019, 2, 28, 12, 0, 0, 0, time.UTC), t)
+	Check(Duration{3, 4}.String(), "3 days", t)
+	Check(Duration{1, 7}.String(), "1 quarter", t)
+}
+
+func TestUsageLine(t *testing.T) {
+	t.Parallel()
+
+	info := &GuildInfo{Config: *DefaultConfig()}
+	Check(info.UsageLine(&slashTestCommand{}, argTestUsage), "> !slash test {user} [for: duration] [mode] [count] [reason]", t)
+	Check(argTestUsage.Params[2].Hint(), "one of: quiet, loud", t)
+}
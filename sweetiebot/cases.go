@@ -0,0 +1,137 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/blackhole12/discordgo"
+)
+
+// Case types. We don't use iota here because these are stored in the database and must never change.
+const (
+	CaseWarning   = 0
+	CaseSilence   = 1
+	CaseUnsilence = 2
+	CaseBan       = 3
+	CaseFilter    = 4
+	CaseKick      = 5
+	CaseTimeout   = 6
+)
+
+// MaxCaseReason is the maximum length of a case reason in bytes. Longer reasons are truncated.
+const MaxCaseReason = 1000
+
+// truncateReason cuts a reason down to at most max bytes without splitting a character
+func truncateReason(reason string, max int) string {
+	if len(reason) <= max {
+		return reason
+	}
+	i := max - len(" [truncated]")
+	for i > 0 && !utf8.RuneStart(reason[i]) {
+		i--
+	}
+	return reason[:i] + " [truncated]"
+}
+
+var caseTypes = map[uint8]struct {
+	name  string
+	color int
+}{
+	CaseWarning:   {"Warning", 0xf1c40f},
+	CaseSilence:   {"Silence", 0xe67e22},
+	CaseUnsilence: {"Unsilence", 0x2ecc71},
+	CaseBan:       {"Ban", 0xe74c3c},
+	CaseFilter:    {"Filter", 0x95a5a6},
+	CaseKick:      {"Kick", 0xd35400},
+	CaseTimeout:   {"Timeout", 0xf39c12},
+}
+
+// CaseTypeName returns the display name of a case type
+func CaseTypeName(ty uint8) string {
+	if t, ok := caseTypes[ty]; ok {
+		return t.name
+	}
+	return "Unknown"
+}
+
+// ParseCaseType returns the case type with the given name, ignoring case and plurals
+func ParseCaseType(s string) (uint8, bool) {
+	s = strings.TrimSuffix(strings.ToLower(s), "s")
+	for k, v := range caseTypes {
+		if strings.ToLower(v.name) == s {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// AddCase records a moderation action against a user as a new numbered case, posts it to the mod channel, and then
+// applies any escalation rule the new case triggers. For automatic actions, moderator should be the bot itself.
+// Returns the case number, or 0 if it couldn't be recorded.
+func (info *GuildInfo) AddCase(ty uint8, user DiscordUser, moderator DiscordUser, reason string, duration time.Duration, schedule *uint64) uint64 {
+	return info.addCase(ty, user, moderator, reason, duration, schedule, 0)
+}
+
+// addCase adds a case, keeping track of how many escalations led to it so a set of rules can't punish someone forever
+func (info *GuildInfo) addCase(ty uint8, user DiscordUser, moderator DiscordUser, reason string, duration time.Duration, schedule *uint64, depth int) uint64 {
+	if !info.Bot.DB.Status.Get() {
+		return 0
+	}
+	reason = truncateReason(reason, MaxCaseReason)
+	number, err := info.Bot.DB.AddCase(SBatoi(info.ID), user.Convert(), moderator.Convert(), ty, reason, duration, schedule)
+	if err != nil {
+		info.Logger().With(LogFields{"user": user.String()}).LogError("Error adding case: ", err)
+		return 0
+	}
+	c := ModCase{
+		Number:    number,
+		User:      user.Convert(),
+		Moderator: moderator.Convert(),
+		Type:      ty,
+		Reason:    reason,
+		Duration:  duration,
+		Schedule:  schedule,
+		Timestamp: time.Now().UTC(),
+	}
+	if info.Config.Basic.ModChannel != ChannelEmpty {
+		info.SendEmbed(info.Config.Basic.ModChannel, info.CaseEmbed(&c))
+	}
+	info.escalate(ty, user, depth)
+	return number
+}
+
+// CaseEmbed formats a case for display
+func (info *GuildInfo) CaseEmbed(c *ModCase) *discordgo.MessageEmbed {
+	user := NewDiscordUser(c.User)
+	moderator := NewDiscordUser(c.Moderator)
+	reason := c.Reason
+	if len(reason) == 0 {
+		reason = "No reason given."
+	}
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "User", Value: user.Display() + " (" + info.GetUserName(user) + ")", Inline: true},
+		{Name: "Moderator", Value: moderator.Display() + " (" + info.GetUserName(moderator) + ")", Inline: true},
+		{Name: "Reason", Value: reason, Inline: false},
+	}
+	if c.Duration > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Duration", Value: TimeDiff(c.Duration), Inline: true})
+	}
+	if c.Schedule != nil {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Schedule Event", Value: fmt.Sprintf("#%v", *c.Schedule), Inline: true})
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Type:      "rich",
+		Title:     fmt.Sprintf("Case #%v: %s", c.Number, CaseTypeName(c.Type)),
+		Color:     caseTypes[c.Type].color,
+		Fields:    fields,
+		Timestamp: c.Timestamp.Format(time.RFC3339),
+	}
+	if c.Pardoned {
+		embed.Color = 0xaaaaaa
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: "This case has been pardoned."}
+	}
+	return embed
+}
@@ -0,0 +1,671 @@
+package sweetiebot
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+// DashboardSessionTimeout is how long someone stays logged in to the web dashboard
+var DashboardSessionTimeout = 24 * time.Hour
+
+const dashboardCookie = "sweetiebot"
+const dashboardLoginTimeout = 10 * time.Minute
+const dashboardBlankRows = 3 // Empty rows added to the end of each map so new keys can be added
+
+type dashboardSession struct {
+	user    DiscordUser
+	name    string
+	csrf    string // Must be included in every form, so other sites can't submit forms on the user's behalf
+	expires time.Time
+}
+
+// dashboard serves the web interface that lets server owners and moderators edit their guild's configuration. Users
+// log in through discord's OAuth2 flow, which only tells us who they are, and permissions are then checked against
+// the guild exactly like they are for commands.
+type dashboard struct {
+	sb       *SweetieBot
+	t        *template.Template
+	lock     sync.Mutex
+	sessions map[string]*dashboardSession
+	states   map[string]time.Time // OAuth2 states that haven't been used yet, and when they expire
+}
+
+type dashboardGuild struct {
+	ID   string
+	Name string
+}
+
+type dashboardChoice struct {
+	Value    string
+	Name     string
+	Selected bool
+}
+
+type dashboardRow struct {
+	Key   string
+	Value string
+}
+
+// dashboardOption is a single config option, along with what kind of form input should be used to edit it
+type dashboardOption struct {
+	Path    string // Full lowercase name of the option, like "basic.modrole", which is also the name of its input
+	Help    string
+	Kind    string // One of text, bool, select, multiselect, list, map, maplist or json (which can't be edited)
+	Value   string
+	Checked bool
+	Choices []dashboardChoice
+	Rows    []dashboardRow
+}
+
+type dashboardCategory struct {
+	Name    string
+	URL     string
+	Options []dashboardOption
+}
+
+type dashboardData struct {
+	webData
+	User       string
+	CSRF       string
+	Guilds     []dashboardGuild
+	Guild      dashboardGuild
+	Categories []dashboardCategory
+	Saved      string
+	Errors     []string
+}
+
+// ConfigureDashboard adds the web dashboard to a mux, using the templates in the web.html file in webdir. Users log in
+// with discord, so the dashboard is only available if oauthsecret is set in selfhost.json.
+func (sb *SweetieBot) ConfigureDashboard(mux *http.ServeMux, webdir string) error {
+	if len(sb.OAuthSecret) == 0 {
+		return errors.New("oauthsecret must be set to enable the dashboard")
+	}
+	t, err := loadWebTemplate(webdir)
+	if err != nil {
+		return err
+	}
+	d := &dashboard{
+		sb:       sb,
+		t:        t,
+		sessions: make(map[string]*dashboardSession),
+		states:   make(map[string]time.Time),
+	}
+	mux.HandleFunc("/dashboard/login", d.loginHandler)
+	mux.HandleFunc("/dashboard/callback", d.callbackHandler)
+	mux.HandleFunc("/dashboard/logout", d.logoutHandler)
+	mux.HandleFunc("/dashboard/", d.dashboardHandler)
+	return nil
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// redirectURL returns the address discord sends users back to after they log in
+func (d *dashboard) redirectURL(r *http.Request) string {
+	scheme := "http"
+	if d.sb.WebSecure || r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	host := d.sb.WebDomain
+	if len(host) == 0 {
+		host = r.Host
+	}
+	return scheme + "://" + host + "/dashboard/callback"
+}
+
+// expire removes old sessions and login attempts. Must be called inside the lock.
+func (d *dashboard) expire() {
+	now := time.Now()
+	for k, v := range d.states {
+		if v.Before(now) {
+			delete(d.states, k)
+		}
+	}
+	for k, v := range d.sessions {
+		if v.expires.Before(now) {
+			delete(d.sessions, k)
+		}
+	}
+}
+
+func (d *dashboard) loginHandler(w http.ResponseWriter, r *http.Request) {
+	state := randomToken()
+	d.lock.Lock()
+	d.expire()
+	d.states[state] = time.Now().Add(dashboardLoginTimeout)
+	d.lock.Unlock()
+
+	v := url.Values{
+		"client_id":     {SBitoa(d.sb.AppID)},
+		"redirect_uri":  {d.redirectURL(r)},
+		"response_type": {"code"},
+		"scope":         {"identify"},
+		"state":         {state},
+	}
+	http.Redirect(w, r, discordgo.EndpointOauth2+"authorize?"+v.Encode(), http.StatusFound)
+}
+
+func (d *dashboard) callbackHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	d.lock.Lock()
+	expires, ok := d.states[q.Get("state")]
+	delete(d.states, q.Get("state"))
+	d.lock.Unlock()
+	if !ok || expires.Before(time.Now()) {
+		http.Error(w, "Your login attempt expired, please try again.", http.StatusBadRequest)
+		return
+	}
+	if len(q.Get("code")) == 0 {
+		http.Error(w, "Login cancelled.", http.StatusForbidden)
+		return
+	}
+	user, err := d.identify(q.Get("code"), d.redirectURL(r))
+	if err != nil {
+		d.sb.Logger.Warning("Dashboard login failed:", err)
+		http.Error(w, "Discord login failed.", http.StatusBadGateway)
+		return
+	}
+
+	id := randomToken()
+	d.lock.Lock()
+	d.sessions[id] = &dashboardSession{DiscordUser(user.ID), user.Username, randomToken(), time.Now().Add(DashboardSessionTimeout)}
+	d.lock.Unlock()
+	http.SetCookie(w, &http.Cookie{
+		Name:     dashboardCookie,
+		Value:    id,
+		Path:     "/dashboard/",
+		MaxAge:   int(DashboardSessionTimeout / time.Second),
+		Secure:   d.sb.WebSecure,
+		HttpOnly: true,
+	})
+	http.Redirect(w, r, "/dashboard/", http.StatusFound)
+}
+
+// identify exchanges an OAuth2 code for an access token, and uses that token to find out who logged in
+func (d *dashboard) identify(code string, redirect string) (*discordgo.User, error) {
+	resp, err := d.sb.DG.Client.PostForm(discordgo.EndpointOauth2+"token", url.Values{
+		"client_id":     {SBitoa(d.sb.AppID)},
+		"client_secret": {d.sb.OAuthSecret},
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirect},
+		"scope":         {"identify"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token request returned %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", discordgo.EndpointUser("@me"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	resp, err = d.sb.DG.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("user request returned %s", resp.Status)
+	}
+	user := &discordgo.User{}
+	if err = json.NewDecoder(resp.Body).Decode(user); err != nil {
+		return nil, err
+	}
+	if len(user.ID) == 0 {
+		return nil, errors.New("discord did not return a user")
+	}
+	return user, nil
+}
+
+func (d *dashboard) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(dashboardCookie); err == nil {
+		d.lock.Lock()
+		delete(d.sessions, c.Value)
+		d.lock.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: dashboardCookie, Path: "/dashboard/", MaxAge: -1})
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (d *dashboard) session(r *http.Request) *dashboardSession {
+	c, err := r.Cookie(dashboardCookie)
+	if err != nil {
+		return nil
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	s, ok := d.sessions[c.Value]
+	if !ok || s.expires.Before(time.Now()) {
+		delete(d.sessions, c.Value)
+		return nil
+	}
+	return s
+}
+
+// canEdit returns true if the user is allowed to change the guild's configuration
+func canEdit(info *GuildInfo, user DiscordUser) bool {
+	return info.OwnerID == user || info.UserIsMod(user)
+}
+
+// guild returns the guild with the given ID, or nil if it doesn't exist or the user can't edit it
+func (d *dashboard) guild(id string, s *dashboardSession) *GuildInfo {
+	d.sb.GuildsLock.RLock()
+	info, ok := d.sb.Guilds[DiscordGuild(id)]
+	d.sb.GuildsLock.RUnlock()
+	if !ok || !canEdit(info, s.user) {
+		return nil
+	}
+	return info
+}
+
+func (d *dashboard) newData(s *dashboardSession, title string) *dashboardData {
+	return &dashboardData{
+		webData: webData{
+			Name:      "Sweetie Bot",
+			Title:     title,
+			Year:      time.Now().Year(),
+			Dashboard: true,
+		},
+		User: s.name,
+		CSRF: s.csrf,
+	}
+}
+
+// render writes a dashboard page, which is sent with a 400 status if there are any errors in the submitted form
+func (d *dashboard) render(w http.ResponseWriter, name string, data *dashboardData) {
+	var b bytes.Buffer
+	if err := d.t.ExecuteTemplate(&b, name, data); err != nil {
+		d.sb.Logger.Error("Error rendering dashboard page:", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if len(data.Errors) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	io.Copy(w, &b)
+}
+
+// dashboardHandler serves /dashboard/ (the list of guilds), /dashboard/guild/ (the configuration of a guild) and
+// /dashboard/guild/category, which a category's form is posted to.
+func (d *dashboard) dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	s := d.session(r)
+	if s == nil {
+		if r.Method == "GET" {
+			http.Redirect(w, r, "/dashboard/login", http.StatusFound)
+		} else {
+			http.Error(w, "Not logged in", http.StatusForbidden)
+		}
+		return
+	}
+	parts := splitURL(r.URL.Path)
+	switch {
+	case len(parts) == 1 && r.Method == "GET":
+		d.serveGuilds(w, s)
+	case len(parts) == 2 && r.Method == "GET":
+		if info := d.guild(parts[1], s); info != nil {
+			d.serveConfig(w, s, info, r.URL.Query().Get("saved"), nil)
+			return
+		}
+		http.Error(w, "Server not found", http.StatusNotFound)
+	case len(parts) == 3 && r.Method == "POST":
+		if info := d.guild(parts[1], s); info != nil {
+			d.saveConfig(w, r, s, info, strings.ToLower(parts[2]))
+			return
+		}
+		http.Error(w, "Server not found", http.StatusNotFound)
+	case len(parts) > 3:
+		http.Error(w, "Page not found", http.StatusNotFound)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+func (d *dashboard) serveGuilds(w http.ResponseWriter, s *dashboardSession) {
+	data := d.newData(s, "Dashboard")
+	d.sb.GuildsLock.RLock()
+	for _, info := range d.sb.Guilds {
+		if canEdit(info, s.user) {
+			data.Guilds = append(data.Guilds, dashboardGuild{info.ID, info.Name})
+		}
+	}
+	d.sb.GuildsLock.RUnlock()
+	sort.Slice(data.Guilds, func(i, j int) bool {
+		return strings.ToLower(data.Guilds[i].Name) < strings.ToLower(data.Guilds[j].Name)
+	})
+	d.render(w, "dashboard", data)
+}
+
+func (d *dashboard) serveConfig(w http.ResponseWriter, s *dashboardSession, info *GuildInfo, saved string, errs []string) {
+	data := d.newData(s, info.Name)
+	data.Guild = dashboardGuild{info.ID, info.Name}
+	data.Saved = saved
+	data.Errors = errs
+
+	info.ConfigLock.RLock()
+	for _, field := range info.Config.fields() {
+		if field.Value.Kind() != reflect.Struct {
+			continue
+		}
+		key := strings.ToLower(field.Name)
+		category := dashboardCategory{Name: field.Name, URL: key}
+		for j := 0; j < field.Value.NumField(); j++ {
+			if len(field.Value.Type().Field(j).PkgPath) > 0 {
+				continue
+			}
+			name := strings.ToLower(field.Value.Type().Field(j).Name)
+			help, _ := getConfigHelp(key, name)
+			category.Options = append(category.Options, newDashboardOption(field.Value.Field(j), key+"."+name, help, info))
+		}
+		data.Categories = append(data.Categories, category)
+	}
+	info.ConfigLock.RUnlock()
+	d.render(w, "guildconfig", data)
+}
+
+// dashboardValue returns a value the way it's displayed in a form, which must also be understood by setConfigValue
+func dashboardValue(f reflect.Value, info *GuildInfo) string {
+	switch f.Interface().(type) {
+	case DiscordChannel:
+		if ch, err := info.DG.State.Channel(f.String()); err == nil {
+			return "#" + ch.Name
+		}
+	case DiscordRole:
+		if r, err := info.DG.State.Role(info.ID, f.String()); err == nil {
+			return "@" + r.Name
+		}
+	}
+	return fmt.Sprint(f.Interface())
+}
+
+func sortedKeys(f reflect.Value) []reflect.Value {
+	keys := f.MapKeys()
+	sort.Sort(valueArray(keys))
+	return keys
+}
+
+// guildChoices lists the channels or roles of a guild, with the ones in selected marked as selected. Any selected IDs
+// that no longer exist in the guild are included at the end, so saving the form doesn't silently remove them.
+func guildChoices(info *GuildInfo, channels bool, selected map[string]bool) (choices []dashboardChoice) {
+	found := make(map[string]bool)
+	if g, err := info.GetGuild(); err == nil {
+		info.DG.State.RLock()
+		if channels {
+			for _, ch := range g.Channels {
+				if ch.Type == discordgo.ChannelTypeGuildText {
+					choices = append(choices, dashboardChoice{ch.ID, "#" + ch.Name, selected[ch.ID]})
+					found[ch.ID] = true
+				}
+			}
+		} else {
+			for _, r := range g.Roles {
+				choices = append(choices, dashboardChoice{r.ID, "@" + r.Name, selected[r.ID]})
+				found[r.ID] = true
+			}
+		}
+		info.DG.State.RUnlock()
+	}
+	sort.Slice(choices, func(i, j int) bool { return strings.ToLower(choices[i].Name) < strings.ToLower(choices[j].Name) })
+	for k := range selected {
+		if !found[k] && len(k) > 0 {
+			choices = append(choices, dashboardChoice{k, k, true})
+		}
+	}
+	return
+}
+
+func newDashboardOption(f reflect.Value, path string, help string, info *GuildInfo) dashboardOption {
+	o := dashboardOption{Path: path, Help: help}
+	switch f.Interface().(type) {
+	case bool:
+		o.Kind = "bool"
+		o.Checked = f.Bool()
+	case DiscordChannel, DiscordRole:
+		_, channels := f.Interface().(DiscordChannel)
+		o.Kind = "select"
+		o.Choices = append([]dashboardChoice{{"", "(none)", f.Len() == 0}}, guildChoices(info, channels, map[string]bool{f.String(): true})...)
+	case LogLevel:
+		o.Kind = "select"
+		for _, v := range logLevelNames {
+			o.Choices = append(o.Choices, dashboardChoice{v, v, v == f.Interface().(LogLevel).String()})
+		}
+	case ScreenAction:
+		o.Kind = "select"
+		for _, v := range screenActions {
+			name := string(v)
+			if v == ScreenNone {
+				name = "(none)"
+			}
+			o.Choices = append(o.Choices, dashboardChoice{string(v), name, v == f.Interface().(ScreenAction)})
+		}
+	case FilterAction:
+		o.Kind = "select"
+		action := f.Interface().(FilterAction)
+		for _, v := range filterActions {
+			o.Choices = append(o.Choices, dashboardChoice{string(v), string(v), v == action || (len(action) == 0 && v == FilterDelete)})
+		}
+	case MissedEventPolicy:
+		o.Kind = "select"
+		policy := f.Interface().(MissedEventPolicy)
+		for _, v := range missedEventPolicies {
+			o.Choices = append(o.Choices, dashboardChoice{string(v), string(v), v == policy || (len(policy) == 0 && v == MissedFire)})
+		}
+	case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, float32, float64, uint64, DiscordUser, ModuleID, CommandID, ReminderOffsets:
+		o.Kind = "text"
+		o.Value = fmt.Sprint(f.Interface())
+	case map[DiscordChannel]bool, map[DiscordRole]bool:
+		_, channels := f.Interface().(map[DiscordChannel]bool)
+		selected := make(map[string]bool, f.Len())
+		for _, k := range f.MapKeys() {
+			selected[k.String()] = true
+		}
+		o.Kind = "multiselect"
+		o.Choices = guildChoices(info, channels, selected)
+	case map[string]bool, map[CommandID]bool, map[ModuleID]bool:
+		o.Kind = "list"
+		s := []string{}
+		for _, k := range sortedKeys(f) {
+			s = append(s, dashboardValue(k, info))
+		}
+		o.Value = strings.Join(s, "\n")
+	case map[string]string, map[CommandID]int64, map[DiscordChannel]float32, map[int]string, map[EscalationTrigger]EscalationAction, map[string]FilterAction:
+		o.Kind = "map"
+		for _, k := range sortedKeys(f) {
+			o.Rows = append(o.Rows, dashboardRow{dashboardValue(k, info), dashboardValue(f.MapIndex(k), info)})
+		}
+	case map[string]map[DiscordChannel]bool, map[CommandID]map[DiscordRole]bool, map[string]map[string]bool, map[DiscordUser][]string, map[CommandID]map[DiscordChannel]bool, map[ModuleID]map[DiscordChannel]bool, map[string]map[DiscordRole]bool, map[DiscordChannel]map[string]bool:
+		o.Kind = "maplist"
+		for _, k := range sortedKeys(f) {
+			v := f.MapIndex(k)
+			s := []string{}
+			if v.Kind() == reflect.Slice {
+				for i := 0; i < v.Len(); i++ {
+					s = append(s, dashboardValue(v.Index(i), info))
+				}
+			} else {
+				for _, item := range sortedKeys(v) {
+					s = append(s, dashboardValue(item, info))
+				}
+			}
+			o.Rows = append(o.Rows, dashboardRow{dashboardValue(k, info), strings.Join(s, "\n")})
+		}
+	default:
+		o.Kind = "json"
+		if data, err := json.MarshalIndent(f.Interface(), "", "  "); err == nil {
+			o.Value = string(data)
+		}
+	}
+	if o.Kind == "map" || o.Kind == "maplist" {
+		for i := 0; i < dashboardBlankRows; i++ {
+			o.Rows = append(o.Rows, dashboardRow{})
+		}
+	}
+	return o
+}
+
+// splitLines splits the contents of a textarea into one value per line, skipping blank lines
+func splitLines(s string) []string {
+	values := []string{}
+	for _, v := range strings.Split(s, "\n") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// setDashboardValue sets a config option from a submitted form using the same functions as !setconfig. Options that
+// aren't in the form are left alone. Map keys are lowercased, just like they are by !setconfig.
+func setDashboardValue(f reflect.Value, path string, form url.Values, info *GuildInfo) error {
+	values, ok := form[path]
+	keys := form[path+".key"]
+	if f.Kind() == reflect.Map && f.Type().Elem() != reflect.TypeOf(true) {
+		values, ok = form[path+".value"] // Maps have a key input and a value input for every row
+	}
+	if !ok {
+		return nil
+	}
+	switch f.Interface().(type) {
+	case bool:
+		f.SetBool(values[len(values)-1] == "true") // The checkbox comes after a hidden input with a value of false
+	case DiscordUser:
+		if v := strings.TrimSpace(values[0]); len(v) > 0 {
+			return setConfigValue(f, v, info)
+		}
+		f.SetString("")
+	case string:
+		f.SetString(values[0])
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, float32, float64, uint64, DiscordChannel, DiscordRole, ModuleID, CommandID, LogLevel, ScreenAction, FilterAction, ReminderOffsets, MissedEventPolicy:
+		return setConfigValue(f, strings.TrimSpace(values[0]), info)
+	case map[DiscordChannel]bool, map[DiscordRole]bool:
+		selected := []string{}
+		for _, v := range values { // An empty hidden input is always included, because selecting nothing sends nothing
+			if len(v) > 0 {
+				selected = append(selected, v)
+			}
+		}
+		if s, ok := setConfigList(f, selected, info); !ok {
+			return errors.New(s)
+		}
+	case map[string]bool, map[CommandID]bool, map[ModuleID]bool:
+		if s, ok := setConfigList(f, splitLines(values[0]), info); !ok {
+			return errors.New(s)
+		}
+	case map[string]string, map[CommandID]int64, map[DiscordChannel]float32, map[int]string, map[EscalationTrigger]EscalationAction, map[string]FilterAction:
+		f.Set(reflect.MakeMap(f.Type()))
+		for i, k := range keys {
+			if k = strings.TrimSpace(k); len(k) > 0 && i < len(values) && len(values[i]) > 0 {
+				if s, ok := setConfigKeyValue(f, strings.ToLower(k), values[i], info); !ok {
+					return errors.New(s)
+				}
+			}
+		}
+	case map[string]map[DiscordChannel]bool, map[CommandID]map[DiscordRole]bool, map[string]map[string]bool, map[DiscordUser][]string, map[CommandID]map[DiscordChannel]bool, map[ModuleID]map[DiscordChannel]bool, map[string]map[DiscordRole]bool, map[DiscordChannel]map[string]bool:
+		f.Set(reflect.MakeMap(f.Type()))
+		for i, k := range keys {
+			if k = strings.TrimSpace(k); len(k) > 0 && i < len(values) {
+				if list := splitLines(values[i]); len(list) > 0 {
+					if s, ok := setConfigMapList(f, strings.ToLower(k), list, info); !ok {
+						return errors.New(s)
+					}
+				}
+			}
+		}
+	default:
+		return errors.New("this option can't be changed from the dashboard")
+	}
+	return nil
+}
+
+// findCategory returns the struct holding the options of the given config category
+func findCategory(config *BotConfig, key string) reflect.Value {
+	for _, field := range config.fields() {
+		if field.Value.Kind() == reflect.Struct && strings.ToLower(field.Name) == key {
+			return field.Value
+		}
+	}
+	return reflect.Value{}
+}
+
+// saveConfig applies a category's form to a copy of the config, so that nothing changes unless every value is valid,
+// then replaces the category and saves the config file.
+func (d *dashboard) saveConfig(w http.ResponseWriter, r *http.Request, s *dashboardSession, info *GuildInfo, key string) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("csrf") != s.csrf {
+		http.Error(w, "Invalid form", http.StatusForbidden)
+		return
+	}
+
+	errs := []string{}
+	info.ConfigLock.Lock()
+	live := findCategory(&info.Config, key)
+	if !live.IsValid() {
+		info.ConfigLock.Unlock()
+		http.Error(w, "Configuration category not found", http.StatusNotFound)
+		return
+	}
+	edited := &BotConfig{}
+	data, err := json.Marshal(info.Config)
+	if err == nil {
+		err = json.Unmarshal(data, edited)
+	}
+	if err != nil {
+		errs = append(errs, "Error copying config: "+err.Error())
+	} else {
+		edited.FillConfig()
+		category := findCategory(edited, key)
+		for j := 0; j < category.NumField(); j++ {
+			if len(category.Type().Field(j).PkgPath) > 0 {
+				continue
+			}
+			path := key + "." + strings.ToLower(category.Type().Field(j).Name)
+			if err := setDashboardValue(category.Field(j), path, r.PostForm, info); err != nil {
+				errs = append(errs, path+": "+err.Error())
+			}
+		}
+		if len(errs) == 0 {
+			live.Set(category)
+		}
+	}
+	info.ConfigLock.Unlock()
+
+	if len(errs) == 0 {
+		if err := info.SaveConfig(); err != nil {
+			errs = append(errs, "Error saving config: "+err.Error())
+		} else {
+			info.RegisterSlashCommands()
+			info.Logger().With(LogFields{"user": s.user, "category": key}).Info(s.name, "changed the", key, "configuration from the dashboard.")
+			http.Redirect(w, r, "/dashboard/"+info.ID+"/?saved="+url.QueryEscape(key)+"#"+key, http.StatusSeeOther)
+			return
+		}
+	}
+	d.serveConfig(w, s, info, "", errs)
+}
@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/blackhole12/discordgo"
-	"github.com/go-sql-driver/mysql" // Blank import is the correct way to import a sql driver
 )
 
 // ErrDuplicateEntry - Error 1062: Duplicate entry for unique key
@@ -23,10 +22,13 @@ type BotDB struct {
 	db                        *sql.DB
 	Status                    AtomicBool
 	lastattempt               time.Time
-	log                       logger
+	log                       *LogEntry
 	driver                    string
+	storage                   Storage
 	conn                      string
 	statuslock                AtomicFlag
+	errorCount                Counter
+	reconnects                CounterVec
 	sqlAddMessage             *sql.Stmt
 	sqlAddUser                *sql.Stmt
 	sqlAddMember              *sql.Stmt
@@ -68,6 +70,9 @@ type BotDB struct {
 	sqlResetMarkov            *sql.Stmt
 	sqlAddSchedule            *sql.Stmt
 	sqlAddScheduleRepeat      *sql.Stmt
+	sqlAddScheduleEvent       *sql.Stmt
+	sqlGetRecurrence          *sql.Stmt
+	sqlAdvanceSchedule        *sql.Stmt
 	sqlGetSchedule            *sql.Stmt
 	sqlRemoveSchedule         *sql.Stmt
 	sqlCountEvents            *sql.Stmt
@@ -111,31 +116,61 @@ type BotDB struct {
 	sqlGetItemTags            *sql.Stmt
 	sqlGetTags                *sql.Stmt
 	sqlImportTag              *sql.Stmt
-}
-
-func dbLoad(log logger, driver string, conn string) (*BotDB, error) {
-	cdb, err := sql.Open(driver, conn)
+	sqlAddCase                *sql.Stmt
+	sqlGetCaseNumber          *sql.Stmt
+	sqlGetCase                *sql.Stmt
+	sqlGetUserCases           *sql.Stmt
+	sqlSetCaseReason          *sql.Stmt
+	sqlPardonCase             *sql.Stmt
+	sqlCountRecentCases       *sql.Stmt
+	sqlGetChatlog             *sql.Stmt
+	sqlAddLockdown            *sql.Stmt
+	sqlSetLockdownEnd         *sql.Stmt
+	sqlGetLockdown            *sql.Stmt
+	sqlRemoveLockdown         *sql.Stmt
+	sqlAddFilterHit           *sql.Stmt
+	sqlGetFilterStats         *sql.Stmt
+	sqlMarkFalsePositive      *sql.Stmt
+	sqlGetFalsePositives      *sql.Stmt
+	sqlAddRSVP                *sql.Stmt
+	sqlRemoveRSVP             *sql.Stmt
+	sqlGetRSVPs               *sql.Stmt
+	sqlCountRSVPs             *sql.Stmt
+	sqlSetRSVPReminded        *sql.Stmt
+	sqlRemoveEventRSVPs       *sql.Stmt
+	sqlSetRSVPMessage         *sql.Stmt
+	sqlGetRSVPEvent           *sql.Stmt
+	sqlStartEvent             *sql.Stmt
+	sqlResetEvent             *sql.Stmt
+	sqlSetEventFailure        *sql.Stmt
+	sqlRetryFailedEvent       *sql.Stmt
+	sqlGetFailedEvents        *sql.Stmt
+}
+
+// dbLoad connects to the database using the storage backend for the given driver. If the driver doesn't exist, it
+// returns nil, because there is nothing to fall back to.
+func dbLoad(log *LogEntry, driver string, conn string) (*BotDB, error) {
+	storage, err := NewStorage(driver)
+	if err != nil {
+		return nil, err
+	}
+	cdb, err := storage.Open(conn)
 	r := BotDB{
 		db:          cdb,
 		lastattempt: time.Now().UTC(),
 		log:         log,
-		driver:      driver,
+		driver:      storage.Driver(),
 		conn:        conn,
+		storage:     storage,
 	}
 	r.Status.Set(err == nil)
-	if err != nil {
-		return &r, err
-	}
-
-	r.db.SetMaxOpenConns(70)
-	err = r.db.Ping()
-	r.Status.Set(err == nil)
 	return &r, err
 }
 
 // Close destroys the database connection
 func (db *BotDB) Close() {
 	if db.db != nil {
+		db.storage.Close()
 		db.db.Close()
 		db.db = nil
 	}
@@ -145,26 +180,27 @@ func (db *BotDB) standardErr(err error) error {
 	if err == nil {
 		return nil
 	}
-	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-		switch mysqlErr.Number {
-		case 1062:
-			return ErrDuplicateEntry
-		case 1205:
-			return ErrLockWaitTimeout
-		}
-	}
-	return err
+	return db.storage.StandardErr(err)
 }
 
-// Prepare a sql statement and logs an error if it fails
+// Prepare a sql statement and logs an error if it fails. The query is written for MySQL and translated by the storage backend.
 func (db *BotDB) Prepare(s string) (*sql.Stmt, error) {
+	s = db.storage.Statement(s)
+	if len(s) == 0 { // The storage backend implements this statement itself
+		return nil, nil
+	}
 	statement, err := db.db.Prepare(s)
 	if err != nil {
-		fmt.Println("Preparing: ", s, "\nSQL Error: ", err.Error())
+		db.log.With(LogFields{"query": s}).Error("SQL error while preparing statement:", err)
 	}
 	return statement, err
 }
 
+// Exec executes a one-off MySQL query, translated by the storage backend, without preparing it
+func (db *BotDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.db.Exec(db.storage.Statement(query), args...)
+}
+
 // DBReconnectTimeout is the reconnect time interval in seconds
 const DBReconnectTimeout = time.Duration(30) * time.Second
 
@@ -181,17 +217,19 @@ func (db *BotDB) CheckStatus() bool {
 		}
 
 		if db.lastattempt.Add(DBReconnectTimeout).Before(time.Now().UTC()) {
-			db.log.Log("Database failure detected! Attempting to reboot database connection...")
+			db.log.Warning("Database failure detected! Attempting to reboot database connection...")
 			db.lastattempt = time.Now().UTC()
 			err := db.db.Ping()
 			if err != nil {
+				db.reconnects.With("failure").Inc()
 				db.log.LogError("Reconnection failed! Another attempt will be made in "+TimeDiff(DBReconnectTimeout)+". Error: ", err)
 				return false
 			}
 			err = db.LoadStatements()                       // If we re-establish connection, we must reload statements in case they were lost or never loaded in the first place
 			db.log.LogError("LoadStatements failed: ", err) // if loading the statements fails we're screwed anyway so we just log the error and keep going
 			db.Status.Set(true)                             // Only after loading the statements do we set status to true
-			db.log.Log("Reconnection succeeded, exiting out of No Database mode.")
+			db.reconnects.With("success").Inc()
+			db.log.Info("Reconnection succeeded, exiting out of No Database mode.")
 		} else { // If not, just fail
 			return false
 		}
@@ -244,14 +282,17 @@ func (db *BotDB) LoadStatements() error {
 	db.sqlResetMarkov, err = db.Prepare("CALL ResetMarkov()")
 	db.sqlAddSchedule, err = db.Prepare("INSERT INTO schedule (Guild, Date, Type, Data) VALUES (?, ?, ?, ?)")
 	db.sqlAddScheduleRepeat, err = db.Prepare("INSERT INTO schedule (Guild, Date, `RepeatInterval`, `Repeat`, Type, Data) VALUES (?, ?, ?, ?, ?, ?)")
-	db.sqlGetSchedule, err = db.Prepare("SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Date <= UTC_TIMESTAMP() ORDER BY Date ASC")
+	db.sqlAddScheduleEvent, err = db.Prepare("INSERT INTO schedule (Guild, Date, Type, Data, Channel, Recurrence) VALUES (?, ?, ?, ?, ?, ?)")
+	db.sqlGetRecurrence, err = db.Prepare("SELECT Date, Recurrence FROM schedule WHERE ID = ?")
+	db.sqlAdvanceSchedule, err = db.Prepare("UPDATE schedule SET Date = ?, Recurrence = ? WHERE ID = ?")
+	db.sqlGetSchedule, err = db.Prepare("SELECT ID, Date, Type, Data, Channel, Recurrence, State, Attempts, LastError FROM schedule WHERE Guild = ? AND Date <= UTC_TIMESTAMP() AND State != 2 AND (RetryAt IS NULL OR RetryAt <= UTC_TIMESTAMP()) ORDER BY Date ASC")
 	db.sqlRemoveSchedule, err = db.Prepare("CALL RemoveSchedule(?)")
 	db.sqlCountEvents, err = db.Prepare("SELECT COUNT(*) FROM schedule WHERE Guild = ?")
-	db.sqlGetEvent, err = db.Prepare("SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND ID = ?")
-	db.sqlGetEvents, err = db.Prepare("SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Type != 0 AND Type != 4 AND Type != 6 ORDER BY Date ASC LIMIT ?")
-	db.sqlGetEventsByType, err = db.Prepare("SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Type = ? ORDER BY Date ASC LIMIT ?")
-	db.sqlGetNextEvent, err = db.Prepare("SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Type = ? ORDER BY Date ASC LIMIT 1")
-	db.sqlGetReminders, err = db.Prepare("SELECT ID, Date, Type, Data FROM schedule WHERE Guild = ? AND Type = 6 AND Data LIKE ? ORDER BY Date ASC LIMIT ?")
+	db.sqlGetEvent, err = db.Prepare("SELECT ID, Date, Type, Data, Channel, Recurrence FROM schedule WHERE Guild = ? AND ID = ?")
+	db.sqlGetEvents, err = db.Prepare("SELECT ID, Date, Type, Data, Channel, Recurrence FROM schedule WHERE Guild = ? AND Type != 0 AND Type != 4 AND Type != 6 ORDER BY Date ASC LIMIT ?")
+	db.sqlGetEventsByType, err = db.Prepare("SELECT ID, Date, Type, Data, Channel, Recurrence FROM schedule WHERE Guild = ? AND Type = ? ORDER BY Date ASC LIMIT ?")
+	db.sqlGetNextEvent, err = db.Prepare("SELECT ID, Date, Type, Data, Channel, Recurrence FROM schedule WHERE Guild = ? AND Type = ? ORDER BY Date ASC LIMIT 1")
+	db.sqlGetReminders, err = db.Prepare("SELECT ID, Date, Type, Data, Channel, Recurrence FROM schedule WHERE Guild = ? AND Type = 6 AND Data LIKE ? ORDER BY Date ASC LIMIT ?")
 	db.sqlGetScheduleDate, err = db.Prepare("SELECT Date FROM schedule WHERE Guild = ? AND Type = ? AND Data = ?")
 	db.sqlGetTimeZone, err = db.Prepare("SELECT Location FROM users WHERE ID = ?")
 	db.sqlFindTimeZone, err = db.Prepare("SELECT Location FROM timezones WHERE Location LIKE ?")
@@ -287,6 +328,35 @@ func (db *BotDB) LoadStatements() error {
 	db.sqlGetItemTags, err = db.Prepare("SELECT T.Name FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID WHERE M.Item = ? AND T.Guild = ?")
 	db.sqlGetTags, err = db.Prepare("SELECT T.Name, COUNT(M.Item) FROM tags T LEFT OUTER JOIN itemtags M ON T.ID = M.Tag WHERE T.Guild = ? GROUP BY T.Name")
 	db.sqlImportTag, err = db.Prepare("INSERT IGNORE INTO itemtags (Item, Tag) SELECT Item, ? FROM itemtags WHERE Tag = ?")
+	db.sqlAddCase, err = db.Prepare("INSERT INTO cases (Guild, Number, User, Moderator, Type, Reason, Duration, Schedule, Timestamp) SELECT ?, COALESCE(MAX(Number), 0) + 1, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP() FROM cases WHERE Guild = ?")
+	db.sqlGetCaseNumber, err = db.Prepare("SELECT Number FROM cases WHERE ID = ?")
+	db.sqlGetCase, err = db.Prepare("SELECT Number, User, Moderator, Type, Reason, Duration, Schedule, Timestamp, Pardoned FROM cases WHERE Guild = ? AND Number = ?")
+	db.sqlGetUserCases, err = db.Prepare("SELECT Number, User, Moderator, Type, Reason, Duration, Schedule, Timestamp, Pardoned FROM cases WHERE Guild = ? AND User = ? ORDER BY Number DESC LIMIT ?")
+	db.sqlSetCaseReason, err = db.Prepare("UPDATE cases SET Reason = ? WHERE Guild = ? AND Number = ?")
+	db.sqlPardonCase, err = db.Prepare("UPDATE cases SET Pardoned = 1 WHERE Guild = ? AND Number = ?")
+	db.sqlCountRecentCases, err = db.Prepare("SELECT COUNT(*) FROM cases WHERE Guild = ? AND User = ? AND Type = ? AND Pardoned = 0 AND Timestamp > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)")
+	db.sqlGetChatlog, err = db.Prepare("SELECT C.ID, C.Author, U.Username, C.Message FROM chatlog C INNER JOIN users U ON C.Author = U.ID WHERE C.Guild = ? AND C.Channel = ? AND C.ID >= ? AND C.ID < ? ORDER BY C.ID ASC LIMIT ?")
+	db.sqlAddLockdown, err = db.Prepare("INSERT IGNORE INTO lockdown (Guild, Type, Channel, Value, Overwrite, Allow, Deny, Ends) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	db.sqlSetLockdownEnd, err = db.Prepare("UPDATE lockdown SET Ends = ? WHERE Guild = ?")
+	db.sqlGetLockdown, err = db.Prepare("SELECT Type, Channel, Value, Overwrite, Allow, Deny, Ends FROM lockdown WHERE Guild = ?")
+	db.sqlRemoveLockdown, err = db.Prepare("DELETE FROM lockdown WHERE Guild = ? AND Type = ? AND Channel = ?")
+	db.sqlAddFilterHit, err = db.Prepare("INSERT INTO filterhits (Guild, Filter, Word, User, CaseNumber, Timestamp) VALUES (?, ?, ?, ?, ?, UTC_TIMESTAMP())")
+	db.sqlGetFilterStats, err = db.Prepare("SELECT Filter, Word, COUNT(*), SUM(FalsePositive) FROM filterhits WHERE Guild = ? AND Timestamp > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND) GROUP BY Filter, Word ORDER BY COUNT(*) DESC, Filter, Word")
+	db.sqlMarkFalsePositive, err = db.Prepare("UPDATE filterhits SET FalsePositive = 1 WHERE Guild = ? AND CaseNumber = ?")
+	db.sqlGetFalsePositives, err = db.Prepare("SELECT CaseNumber, Filter, Word, User, Timestamp FROM filterhits WHERE Guild = ? AND FalsePositive = 1 ORDER BY Timestamp DESC, ID DESC LIMIT ?")
+	db.sqlAddRSVP, err = db.Prepare("INSERT IGNORE INTO rsvps (Event, User, Guild, Reminded) VALUES (?, ?, ?, UTC_TIMESTAMP())")
+	db.sqlRemoveRSVP, err = db.Prepare("DELETE FROM rsvps WHERE Event = ? AND User = ?")
+	db.sqlGetRSVPs, err = db.Prepare("SELECT User, Reminded FROM rsvps WHERE Event = ? ORDER BY Reminded ASC")
+	db.sqlCountRSVPs, err = db.Prepare("SELECT COUNT(*) FROM rsvps WHERE Event = ?")
+	db.sqlSetRSVPReminded, err = db.Prepare("UPDATE rsvps SET Reminded = ? WHERE Event = ? AND User = ?")
+	db.sqlRemoveEventRSVPs, err = db.Prepare("DELETE FROM rsvps WHERE Event = ? AND Event NOT IN (SELECT ID FROM schedule)")
+	db.sqlSetRSVPMessage, err = db.Prepare("UPDATE schedule SET RSVPMessage = ? WHERE Guild = ? AND ID = ?")
+	db.sqlGetRSVPEvent, err = db.Prepare("SELECT ID, Date, Type, Data, Channel, Recurrence FROM schedule WHERE Guild = ? AND RSVPMessage = ?")
+	db.sqlStartEvent, err = db.Prepare("UPDATE schedule SET State = 1, RetryAt = ? WHERE ID = ? AND State != 2 AND (RetryAt IS NULL OR RetryAt <= UTC_TIMESTAMP())")
+	db.sqlResetEvent, err = db.Prepare("UPDATE schedule SET State = 0, Attempts = 0, RetryAt = NULL, LastError = NULL WHERE ID = ?")
+	db.sqlSetEventFailure, err = db.Prepare("UPDATE schedule SET State = ?, Attempts = ?, RetryAt = ?, LastError = ? WHERE ID = ?")
+	db.sqlRetryFailedEvent, err = db.Prepare("UPDATE schedule SET State = 0, Attempts = 0, RetryAt = NULL WHERE Guild = ? AND ID = ? AND State = 2")
+	db.sqlGetFailedEvents, err = db.Prepare("SELECT ID, Date, Type, Data, Channel, Recurrence, State, Attempts, LastError FROM schedule WHERE Guild = ? AND State = 2 ORDER BY Date ASC LIMIT ?")
 	return err
 }
 
@@ -313,6 +383,7 @@ func (db *BotDB) parseStringResults(q *sql.Rows) []string {
 // CheckError logs any unknown errors and pings the database to check if it's still there
 func (db *BotDB) CheckError(name string, err error) error {
 	if err != nil && err != sql.ErrNoRows && err != sql.ErrTxDone && err != ErrDuplicateEntry {
+		db.errorCount.Inc()
 		if db.Status.Get() {
 			db.log.LogError(name+" error: ", err)
 		}
@@ -325,7 +396,7 @@ func (db *BotDB) CheckError(name string, err error) error {
 
 // AddMessage logs a message to the chatlog
 func (db *BotDB) AddMessage(id uint64, author uint64, message string, channel uint64, everyone bool, guild uint64) {
-	_, err := db.sqlAddMessage.Exec(id, author, message, channel, everyone, guild)
+	err := db.storage.AddChat(db, id, author, message, channel, everyone, guild)
 	db.CheckError("AddMessage", err)
 }
 
@@ -338,13 +409,13 @@ type PingContext struct {
 
 // AddUser adds or updates user information
 func (db *BotDB) AddUser(id uint64, username string, discriminator int, avatar string, isonline bool) {
-	_, err := db.sqlAddUser.Exec(id, username, discriminator, avatar, isonline)
+	err := db.storage.AddUser(db, id, username, discriminator, avatar, isonline)
 	db.CheckError("AddUser", err)
 }
 
 // AddMember adds or updates guild-specific user information
 func (db *BotDB) AddMember(id uint64, guild uint64, firstseen time.Time, nickname string) {
-	_, err := db.sqlAddMember.Exec(id, guild, firstseen, nickname)
+	err := db.storage.AddMember(db, id, guild, firstseen, nickname)
 	db.CheckError("AddMember", err)
 }
 
@@ -509,7 +580,7 @@ func (db *BotDB) Audit(ty uint8, user *discordgo.User, message string, guild uin
 	}
 
 	if err != nil && db.Status.Get() {
-		fmt.Println("Logger failed to log to database! ", err.Error())
+		db.log.hostOnly().Error("Logger failed to log to database!", err)
 	}
 }
 
@@ -563,7 +634,7 @@ func (db *BotDB) GetTableCounts() string {
 func (db *BotDB) AddTranscript(season int, episode int, line int, speaker string, text string) {
 	_, err := db.sqlAddTranscript.Exec(season, episode, line, speaker, text)
 	if err != nil {
-		db.log.Log("AddTranscript error: ", err.Error(), "\nS", season, "E", episode, ":", line, " ", speaker, ": ", text)
+		db.log.With(LogFields{"season": season, "episode": episode, "line": line, "speaker": speaker}).Error("AddTranscript error:", err, text)
 	}
 }
 
@@ -605,16 +676,19 @@ func (db *BotDB) RemoveTranscript(season int, episode int, line int) {
 
 // AddMarkov adds a line to the markov chain
 func (db *BotDB) AddMarkov(last uint64, last2 uint64, speaker string, text string) uint64 {
-	var id uint64
-	err := db.sqlAddMarkov.QueryRow(last, last2, speaker, text).Scan(&id)
+	id, err := db.storage.AddMarkov(db, last, last2, speaker, text)
 	db.CheckError("AddMarkov", err)
 	return id
 }
 
+// ResetMarkov deletes the entire markov chain
+func (db *BotDB) ResetMarkov() error {
+	return db.CheckError("ResetMarkov", db.storage.ResetMarkov(db))
+}
+
 // GetMarkovLine generates a line from the markov chain
 func (db *BotDB) GetMarkovLine(last uint64) (string, uint64) {
-	var r sql.NullString
-	err := db.sqlGetMarkovLine.QueryRow(last).Scan(&r)
+	r, err := db.storage.GetMarkovLine(db, last)
 	if db.CheckError("GetMarkovLine", err) != nil || !r.Valid {
 		return "", 0
 	}
@@ -627,8 +701,7 @@ func (db *BotDB) GetMarkovLine(last uint64) (string, uint64) {
 
 // GetMarkovLine2 generates a line from the markov chain
 func (db *BotDB) GetMarkovLine2(last uint64, last2 uint64) (string, uint64, uint64) {
-	var r sql.NullString
-	err := db.sqlGetMarkovLine2.QueryRow(last, last2).Scan(&r)
+	r, err := db.storage.GetMarkovLine2(db, last, last2)
 	if db.CheckError("GetMarkovLine2", err) != nil || !r.Valid {
 		return "", 0, 0
 	}
@@ -732,10 +805,35 @@ func (db *BotDB) CountNewUsers(seconds int64, guild uint64) int {
 	return i
 }
 
-// RemoveSchedule removes the event with the given ID
+// RemoveSchedule removes the event with the given ID. If the event repeats and is due, it is moved to its next
+// occurrence instead.
 func (db *BotDB) RemoveSchedule(id uint64) error {
-	_, err := db.sqlRemoveSchedule.Exec(id)
-	return db.CheckError("RemoveSchedule", err)
+	var date time.Time
+	var rule sql.NullString
+	err := db.sqlGetRecurrence.QueryRow(id).Scan(&date, &rule)
+	if err == nil && rule.Valid && len(rule.String) > 0 && !date.After(time.Now().UTC()) {
+		if r, perr := ParseRecurrence(rule.String, nil); perr == nil {
+			if next, ok := r.Advance(date); ok {
+				_, err = db.sqlAdvanceSchedule.Exec(next, r.String(), id)
+				return db.CheckError("RemoveSchedule", err)
+			}
+		}
+	} else if err != nil && err != sql.ErrNoRows {
+		return db.CheckError("RemoveSchedule", err)
+	}
+	// The procedure deletes events that don't have a legacy repeat interval, including recurring events that are over
+	err = db.storage.RemoveSchedule(db, id)
+	if db.CheckError("RemoveSchedule", err) != nil {
+		return err
+	}
+	_, err = db.sqlRemoveEventRSVPs.Exec(id)
+	return db.CheckError("RemoveEventRSVPs", err)
+}
+
+// RemoveGuild deletes all data associated with a guild
+func (db *BotDB) RemoveGuild(guild uint64) error {
+	err := db.storage.RemoveGuild(db, guild)
+	return db.CheckError("RemoveGuild", err)
 }
 
 // AddSchedule adds an event to the schedule
@@ -767,25 +865,167 @@ func (db *BotDB) AddScheduleRepeat(guild uint64, date time.Time, repeatinterval
 	return err
 }
 
+// AddScheduleEvent adds an event to the schedule that can announce itself in a specific channel and repeat according
+// to a recurrence rule. Either of them can be empty.
+func (db *BotDB) AddScheduleEvent(guild uint64, date time.Time, ty uint8, data EventData, channel DiscordChannel, rule *Recurrence) error {
+	var i int
+	err := db.sqlCountEvents.QueryRow(guild).Scan(&i)
+	if db.CheckError("CountEvents", err) == nil {
+		if i >= MaxScheduleRows {
+			return fmt.Errorf("Can't have more than %v events!", MaxScheduleRows)
+		}
+		var ch *uint64
+		if channel != ChannelEmpty {
+			id := channel.Convert()
+			ch = &id
+		}
+		var recurrence *string
+		if rule != nil {
+			s := rule.String()
+			recurrence = &s
+		}
+		_, err = db.sqlAddScheduleEvent.Exec(guild, date, ty, data.Encode(ty), ch, recurrence)
+		return db.CheckError("AddScheduleEvent", err)
+	}
+	return err
+}
+
 // ScheduleEvent describes an event in the schedule
 type ScheduleEvent struct {
-	ID   uint64
-	Date time.Time
-	Type uint8
-	Data string
+	ID         uint64
+	Date       time.Time
+	Type       uint8
+	Data       string
+	Channel    DiscordChannel // Where the event is announced, or empty to use the scheduler's default channel
+	Recurrence string         // The recurrence rule of the event, or empty if it doesn't repeat or uses a legacy repeat
+}
+
+// Payload decodes the data of the event
+func (e *ScheduleEvent) Payload() EventData {
+	return ParseEventData(e.Type, e.Data)
 }
 
-// GetSchedule gets all events for a guild
-func (db *BotDB) GetSchedule(guild uint64) []ScheduleEvent {
+// Rule parses the recurrence rule of the event, returning nil if it doesn't have one
+func (e *ScheduleEvent) Rule() *Recurrence {
+	if len(e.Recurrence) == 0 {
+		return nil
+	}
+	r, err := ParseRecurrence(e.Recurrence, nil)
+	if err != nil {
+		return nil
+	}
+	return r
+}
+
+func scanScheduleEvent(row interface {
+	Scan(dest ...interface{}) error
+}) (ScheduleEvent, error) {
+	e := ScheduleEvent{}
+	var channel sql.NullInt64
+	var rule sql.NullString
+	err := row.Scan(&e.ID, &e.Date, &e.Type, &e.Data, &channel, &rule)
+	if channel.Valid {
+		e.Channel = NewDiscordChannel(uint64(channel.Int64))
+	}
+	e.Recurrence = rule.String
+	return e, err
+}
+
+// ScheduledJob is an event along with how running it has gone so far
+type ScheduledJob struct {
+	ScheduleEvent
+	State    uint8
+	Attempts int    // How many times in a row running the event has failed
+	Error    string // Why running the event last failed
+}
+
+func scanScheduledJob(row interface {
+	Scan(dest ...interface{}) error
+}) (ScheduledJob, error) {
+	j := ScheduledJob{}
+	var channel sql.NullInt64
+	var rule sql.NullString
+	var lasterr sql.NullString
+	err := row.Scan(&j.ID, &j.Date, &j.Type, &j.Data, &channel, &rule, &j.State, &j.Attempts, &lasterr)
+	if channel.Valid {
+		j.Channel = NewDiscordChannel(uint64(channel.Int64))
+	}
+	j.Recurrence = rule.String
+	j.Error = lasterr.String
+	return j, err
+}
+
+// GetSchedule gets all events for a guild that are due to run, skipping failed events and events waiting to be retried
+func (db *BotDB) GetSchedule(guild uint64) []ScheduledJob {
 	q, err := db.sqlGetSchedule.Query(guild)
 	if db.CheckError("GetSchedule", err) != nil {
-		return []ScheduleEvent{}
+		return []ScheduledJob{}
 	}
 	defer q.Close()
-	r := make([]ScheduleEvent, 0, 2)
+	r := make([]ScheduledJob, 0, 2)
+	for q.Next() {
+		if p, err := scanScheduledJob(q); err == nil {
+			r = append(r, p)
+		}
+	}
+	return r
+}
+
+// StartEvent marks an event as running, so it isn't run again until the lease expires, in case the bot stops before it
+// finishes. Returns false if the event was removed, failed, or is already running.
+func (db *BotDB) StartEvent(id uint64, lease time.Time) bool {
+	res, err := db.sqlStartEvent.Exec(lease.UTC(), id)
+	if db.CheckError("StartEvent", err) != nil {
+		return false
+	}
+	n, err := res.RowsAffected()
+	return err == nil && n > 0
+}
+
+// FinishEvent marks an event as done, which removes it from the schedule, or moves it to its next occurrence if it
+// repeats.
+func (db *BotDB) FinishEvent(id uint64) error {
+	_, err := db.sqlResetEvent.Exec(id)
+	if db.CheckError("FinishEvent", err) != nil {
+		return err
+	}
+	return db.RemoveSchedule(id)
+}
+
+// FailEvent records that running an event failed. If it hasn't failed MaxEventAttempts times yet, it goes back to
+// pending and is retried after a delay, otherwise it's marked as failed. Returns the new state of the event.
+func (db *BotDB) FailEvent(id uint64, attempts int, reason string, now time.Time) (uint8, error) {
+	state := JobPending
+	var retry interface{} = now.UTC().Add(EventRetryDelay(attempts))
+	if attempts >= MaxEventAttempts {
+		state = JobFailed
+		retry = nil
+	}
+	_, err := db.sqlSetEventFailure.Exec(state, attempts, retry, truncateReason(reason, 1024), id)
+	return state, db.CheckError("FailEvent", err)
+}
+
+// RetryFailedEvent puts a failed event back in the schedule, so it runs again on the next tick. Returns false if the
+// event doesn't exist or hasn't failed.
+func (db *BotDB) RetryFailedEvent(guild uint64, id uint64) (bool, error) {
+	res, err := db.sqlRetryFailedEvent.Exec(guild, id)
+	if db.CheckError("RetryFailedEvent", err) != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetFailedEvents gets the events in a guild that the scheduler gave up on, oldest first
+func (db *BotDB) GetFailedEvents(guild uint64, maxnum int) []ScheduledJob {
+	q, err := db.sqlGetFailedEvents.Query(guild, maxnum)
+	if db.CheckError("GetFailedEvents", err) != nil {
+		return []ScheduledJob{}
+	}
+	defer q.Close()
+	r := make([]ScheduledJob, 0, 2)
 	for q.Next() {
-		p := ScheduleEvent{}
-		if err := q.Scan(&p.ID, &p.Date, &p.Type, &p.Data); err == nil {
+		if p, err := scanScheduledJob(q); err == nil {
 			r = append(r, p)
 		}
 	}
@@ -794,12 +1034,11 @@ func (db *BotDB) GetSchedule(guild uint64) []ScheduleEvent {
 
 // GetEvent gets the event data for the given ID
 func (db *BotDB) GetEvent(guild uint64, id uint64) *ScheduleEvent {
-	e := &ScheduleEvent{}
-	err := db.sqlGetEvent.QueryRow(guild, id).Scan(&e.ID, &e.Date, &e.Type, &e.Data)
+	e, err := scanScheduleEvent(db.sqlGetEvent.QueryRow(guild, id))
 	if err == sql.ErrNoRows || db.CheckError("GetEvent", err) != nil {
 		return nil
 	}
-	return e
+	return &e
 }
 
 // GetEvents gets all events for a guild up to maxnum
@@ -811,8 +1050,7 @@ func (db *BotDB) GetEvents(guild uint64, maxnum int) []ScheduleEvent {
 	defer q.Close()
 	r := make([]ScheduleEvent, 0, 2)
 	for q.Next() {
-		p := ScheduleEvent{}
-		if err := q.Scan(&p.ID, &p.Date, &p.Type, &p.Data); err == nil {
+		if p, err := scanScheduleEvent(q); err == nil {
 			r = append(r, p)
 		}
 	}
@@ -828,8 +1066,7 @@ func (db *BotDB) GetEventsByType(guild uint64, ty uint8, maxnum int) []ScheduleE
 	defer q.Close()
 	r := make([]ScheduleEvent, 0, 2)
 	for q.Next() {
-		p := ScheduleEvent{}
-		if err := q.Scan(&p.ID, &p.Date, &p.Type, &p.Data); err == nil {
+		if p, err := scanScheduleEvent(q); err == nil {
 			r = append(r, p)
 		}
 	}
@@ -838,10 +1075,9 @@ func (db *BotDB) GetEventsByType(guild uint64, ty uint8, maxnum int) []ScheduleE
 
 // GetNextEvent gets the next event of the given type
 func (db *BotDB) GetNextEvent(guild uint64, ty uint8) ScheduleEvent {
-	p := ScheduleEvent{}
-	err := db.sqlGetNextEvent.QueryRow(guild, ty).Scan(&p.ID, &p.Date, &p.Type, &p.Data)
+	p, err := scanScheduleEvent(db.sqlGetNextEvent.QueryRow(guild, ty))
 	if err == sql.ErrNoRows || db.CheckError("GetNextEvent", err) != nil {
-		return ScheduleEvent{0, time.Now().UTC(), 0, ""}
+		return ScheduleEvent{Date: time.Now().UTC()}
 	}
 	return p
 }
@@ -855,8 +1091,7 @@ func (db *BotDB) GetReminders(guild uint64, id string, maxnum int) []ScheduleEve
 	defer q.Close()
 	r := make([]ScheduleEvent, 0, 2)
 	for q.Next() {
-		p := ScheduleEvent{}
-		if err := q.Scan(&p.ID, &p.Date, &p.Type, &p.Data); err == nil {
+		if p, err := scanScheduleEvent(q); err == nil {
 			r = append(r, p)
 		}
 	}
@@ -1129,8 +1364,8 @@ func (db *BotDB) GetNewcomers(lookback int, guild uint64) []uint64 {
 
 // AddItem adds an item or just returns the ID if it already exists.
 func (db *BotDB) AddItem(item string) (uint64, error) {
-	var id uint64
-	err := db.standardErr(db.sqlAddItem.QueryRow(item).Scan(&id))
+	id, err := db.storage.AddItem(db, item)
+	err = db.standardErr(err)
 
 	if db.CheckError("AddItem", err) != nil {
 		return 0, err
@@ -1263,3 +1498,331 @@ func (db *BotDB) ImportTag(srcTag uint64, destTag uint64) error {
 	db.CheckError("ImportTag", err)
 	return err
 }
+
+// ModCase is a single numbered moderation action taken against a user on a guild
+type ModCase struct {
+	Number    uint64
+	User      uint64
+	Moderator uint64
+	Type      uint8
+	Reason    string
+	Duration  time.Duration // Zero if the action was permanent
+	Schedule  *uint64       // The schedule event that will undo the action, if there is one
+	Timestamp time.Time
+	Pardoned  bool
+}
+
+func scanModCase(row interface {
+	Scan(dest ...interface{}) error
+}) (ModCase, error) {
+	c := ModCase{}
+	var duration sql.NullInt64
+	var schedule sql.NullInt64
+	err := row.Scan(&c.Number, &c.User, &c.Moderator, &c.Type, &c.Reason, &duration, &schedule, &c.Timestamp, &c.Pardoned)
+	if duration.Valid {
+		c.Duration = time.Duration(duration.Int64) * time.Second
+	}
+	if schedule.Valid {
+		id := uint64(schedule.Int64)
+		c.Schedule = &id
+	}
+	return c, err
+}
+
+// AddCase records a moderation action and returns the case number assigned to it, which counts up from 1 on each guild
+func (db *BotDB) AddCase(guild uint64, user uint64, moderator uint64, ty uint8, reason string, duration time.Duration, schedule *uint64) (uint64, error) {
+	var seconds *int64
+	if duration > 0 {
+		s := int64(duration / time.Second)
+		seconds = &s
+	}
+	var res sql.Result
+	var err error
+	for i := 0; i < 3; i++ { // Two cases added at the same time can be assigned the same number, so we retry if that happens
+		res, err = db.sqlAddCase.Exec(guild, user, moderator, ty, reason, seconds, schedule, guild)
+		if err = db.standardErr(err); err != ErrDuplicateEntry {
+			break
+		}
+	}
+	if db.CheckError("AddCase", err) != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	var number uint64
+	err = db.sqlGetCaseNumber.QueryRow(id).Scan(&number)
+	if db.CheckError("GetCaseNumber", err) != nil {
+		return 0, err
+	}
+	return number, nil
+}
+
+// GetCase returns the case with the given number on a guild, or nil if it doesn't exist
+func (db *BotDB) GetCase(guild uint64, number uint64) *ModCase {
+	c, err := scanModCase(db.sqlGetCase.QueryRow(guild, number))
+	if err == sql.ErrNoRows || db.CheckError("GetCase", err) != nil {
+		return nil
+	}
+	return &c
+}
+
+// GetUserCases returns up to maxresults of a user's most recent cases on a guild, newest first
+func (db *BotDB) GetUserCases(guild uint64, user uint64, maxresults int) []ModCase {
+	q, err := db.sqlGetUserCases.Query(guild, user, maxresults)
+	if db.CheckError("GetUserCases", err) != nil {
+		return []ModCase{}
+	}
+	defer q.Close()
+	r := make([]ModCase, 0, 5)
+	for q.Next() {
+		if c, err := scanModCase(q); err == nil {
+			r = append(r, c)
+		}
+	}
+	return r
+}
+
+// SetCaseReason replaces the reason given for a case
+func (db *BotDB) SetCaseReason(guild uint64, number uint64, reason string) error {
+	_, err := db.sqlSetCaseReason.Exec(truncateReason(reason, MaxCaseReason), guild, number)
+	return db.CheckError("SetCaseReason", err)
+}
+
+// PardonCase marks a case as pardoned, so it no longer counts against the user
+func (db *BotDB) PardonCase(guild uint64, number uint64) error {
+	_, err := db.sqlPardonCase.Exec(guild, number)
+	return db.CheckError("PardonCase", err)
+}
+
+// CountRecentCases returns how many cases of the given type a user has accumulated in the past duration, not counting
+// any that were pardoned
+func (db *BotDB) CountRecentCases(guild uint64, user uint64, ty uint8, duration time.Duration) int {
+	var i int
+	err := db.sqlCountRecentCases.QueryRow(guild, user, ty, int64(duration/time.Second)).Scan(&i)
+	if db.CheckError("CountRecentCases", err) != nil {
+		return 0
+	}
+	return i
+}
+
+// GetChatlog returns up to maxresults messages logged in a channel between start and end, oldest first. Message IDs
+// are snowflakes, so this searches by ID instead of the Timestamp column, which also gives millisecond precision.
+func (db *BotDB) GetChatlog(guild uint64, channel uint64, start time.Time, end time.Time, maxresults int) []*discordgo.Message {
+	q, err := db.sqlGetChatlog.Query(guild, channel, TimeSnowflake(start), TimeSnowflake(end), maxresults)
+	if db.CheckError("GetChatlog", err) != nil {
+		return []*discordgo.Message{}
+	}
+	defer q.Close()
+	r := make([]*discordgo.Message, 0, 64)
+	for q.Next() {
+		var id, author uint64
+		m := &discordgo.Message{Author: &discordgo.User{}, ChannelID: SBitoa(channel)}
+		if err := q.Scan(&id, &author, &m.Author.Username, &m.Content); err == nil {
+			m.ID = SBitoa(id)
+			m.Author.ID = SBitoa(author)
+			ms := int64((id >> 22) + DiscordEpoch)
+			m.Timestamp = discordgo.Timestamp(time.Unix(0, ms*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano))
+			r = append(r, m)
+		}
+	}
+	return r
+}
+
+// Lockdown types. These are stored in the database, so they must never change.
+const (
+	LockdownVerification = 0 // The guild's verification level was raised
+	LockdownPermissions  = 1 // @everyone was denied permission to send messages in a channel
+	LockdownSlowmode     = 2 // A channel was put in slowmode
+)
+
+// LockdownState is something a lockdown changed on a guild, along with what it was before, so it can be restored
+type LockdownState struct {
+	Type      uint8
+	Channel   uint64 // Zero for the guild's verification level
+	Value     int    // The previous verification level of the guild, or the previous slowmode of the channel in seconds
+	Overwrite bool   // Whether the channel had a permission overwrite for @everyone
+	Allow     int    // The permissions that overwrite allowed
+	Deny      int    // The permissions that overwrite denied
+	Ends      *time.Time
+}
+
+// AddLockdown saves something a lockdown is about to change. If the lockdown already changed it, the original state
+// is kept, so engaging a lockdown twice won't replace it with the locked down state.
+func (db *BotDB) AddLockdown(guild uint64, state LockdownState) error {
+	_, err := db.sqlAddLockdown.Exec(guild, state.Type, state.Channel, state.Value, state.Overwrite, state.Allow, state.Deny, state.Ends)
+	return db.CheckError("AddLockdown", err)
+}
+
+// SetLockdownEnd changes when every part of a guild's lockdown ends. A nil end means it never ends on its own.
+func (db *BotDB) SetLockdownEnd(guild uint64, ends *time.Time) error {
+	_, err := db.sqlSetLockdownEnd.Exec(ends, guild)
+	return db.CheckError("SetLockdownEnd", err)
+}
+
+// GetLockdown returns everything the current lockdown on a guild has changed, which is empty if there isn't one
+func (db *BotDB) GetLockdown(guild uint64) []LockdownState {
+	q, err := db.sqlGetLockdown.Query(guild)
+	if db.CheckError("GetLockdown", err) != nil {
+		return []LockdownState{}
+	}
+	defer q.Close()
+	r := make([]LockdownState, 0, 4)
+	for q.Next() {
+		state := LockdownState{}
+		if err := q.Scan(&state.Type, &state.Channel, &state.Value, &state.Overwrite, &state.Allow, &state.Deny, &state.Ends); err == nil {
+			r = append(r, state)
+		}
+	}
+	return r
+}
+
+// RemoveLockdown forgets about something a lockdown changed after it has been restored
+func (db *BotDB) RemoveLockdown(guild uint64, ty uint8, channel uint64) error {
+	_, err := db.sqlRemoveLockdown.Exec(guild, ty, channel)
+	return db.CheckError("RemoveLockdown", err)
+}
+
+// FilterStat is how many times a word in a filter matched a message, and how many of those were false positives
+type FilterStat struct {
+	Filter         string
+	Word           string // Empty if the filter has no words and only its template matched
+	Hits           int
+	FalsePositives int
+}
+
+// FilterFalsePositive is a message that a filter acted on, which a moderator said should not have been filtered
+type FilterFalsePositive struct {
+	Case      uint64
+	Filter    string
+	Word      string
+	User      uint64
+	Timestamp time.Time
+}
+
+// AddFilterHit records that a word in a filter matched a message. The case number is nil if the filter didn't create a
+// case, because it only logged the message or notified the moderators.
+func (db *BotDB) AddFilterHit(guild uint64, filter string, word string, user uint64, casenumber *uint64) error {
+	_, err := db.sqlAddFilterHit.Exec(guild, filter, word, user, casenumber)
+	return db.CheckError("AddFilterHit", err)
+}
+
+// GetFilterStats returns how many times each word in each filter matched a message in the past duration, most hits first
+func (db *BotDB) GetFilterStats(guild uint64, duration time.Duration) []FilterStat {
+	q, err := db.sqlGetFilterStats.Query(guild, int64(duration/time.Second))
+	if db.CheckError("GetFilterStats", err) != nil {
+		return []FilterStat{}
+	}
+	defer q.Close()
+	r := make([]FilterStat, 0, 16)
+	for q.Next() {
+		stat := FilterStat{}
+		if err := q.Scan(&stat.Filter, &stat.Word, &stat.Hits, &stat.FalsePositives); err == nil {
+			r = append(r, stat)
+		}
+	}
+	return r
+}
+
+// MarkFalsePositive marks the filter hit that created a case as a false positive. Returns false if no filter hit
+// created that case.
+func (db *BotDB) MarkFalsePositive(guild uint64, number uint64) (bool, error) {
+	res, err := db.sqlMarkFalsePositive.Exec(guild, number)
+	if db.CheckError("MarkFalsePositive", err) != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetFalsePositives returns up to maxresults of the most recent filter hits marked as false positives
+func (db *BotDB) GetFalsePositives(guild uint64, maxresults int) []FilterFalsePositive {
+	q, err := db.sqlGetFalsePositives.Query(guild, maxresults)
+	if db.CheckError("GetFalsePositives", err) != nil {
+		return []FilterFalsePositive{}
+	}
+	defer q.Close()
+	r := make([]FilterFalsePositive, 0, maxresults)
+	for q.Next() {
+		v := FilterFalsePositive{}
+		if err := q.Scan(&v.Case, &v.Filter, &v.Word, &v.User, &v.Timestamp); err == nil {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// RSVP is a member who said they're going to an event
+type RSVP struct {
+	User     DiscordUser
+	Reminded time.Time // When they last got a reminder about the event, or when they RSVPed if they haven't gotten one yet
+}
+
+// AddRSVP says a member is going to an event. Returns false if they already were.
+func (db *BotDB) AddRSVP(guild uint64, event uint64, user DiscordUser) (bool, error) {
+	res, err := db.sqlAddRSVP.Exec(event, user.Convert(), guild)
+	if db.CheckError("AddRSVP", err) != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RemoveRSVP says a member isn't going to an event anymore. Returns false if they weren't going.
+func (db *BotDB) RemoveRSVP(event uint64, user DiscordUser) (bool, error) {
+	res, err := db.sqlRemoveRSVP.Exec(event, user.Convert())
+	if db.CheckError("RemoveRSVP", err) != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetRSVPs returns everyone going to an event, in the order they RSVPed
+func (db *BotDB) GetRSVPs(event uint64) []RSVP {
+	q, err := db.sqlGetRSVPs.Query(event)
+	if db.CheckError("GetRSVPs", err) != nil {
+		return []RSVP{}
+	}
+	defer q.Close()
+	r := make([]RSVP, 0, 8)
+	for q.Next() {
+		var user uint64
+		v := RSVP{}
+		if err := q.Scan(&user, &v.Reminded); err == nil {
+			v.User = NewDiscordUser(user)
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// CountRSVPs returns how many members are going to an event
+func (db *BotDB) CountRSVPs(event uint64) int {
+	var i int
+	err := db.sqlCountRSVPs.QueryRow(event).Scan(&i)
+	db.CheckError("CountRSVPs", err)
+	return i
+}
+
+// SetRSVPReminded records when a member was last reminded about an event
+func (db *BotDB) SetRSVPReminded(event uint64, user DiscordUser, t time.Time) error {
+	_, err := db.sqlSetRSVPReminded.Exec(t, event, user.Convert())
+	return db.CheckError("SetRSVPReminded", err)
+}
+
+// SetRSVPMessage sets the message that members can react to in order to RSVP to an event
+func (db *BotDB) SetRSVPMessage(guild uint64, event uint64, message uint64) error {
+	_, err := db.sqlSetRSVPMessage.Exec(message, guild, event)
+	return db.CheckError("SetRSVPMessage", err)
+}
+
+// GetRSVPEvent returns the event that a message is the RSVP message of, or nil if it isn't one
+func (db *BotDB) GetRSVPEvent(guild uint64, message uint64) *ScheduleEvent {
+	e, err := scanScheduleEvent(db.sqlGetRSVPEvent.QueryRow(guild, message))
+	if err == sql.ErrNoRows || db.CheckError("GetRSVPEvent", err) != nil {
+		return nil
+	}
+	return &e
+}
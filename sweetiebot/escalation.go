@@ -0,0 +1,243 @@
+package sweetiebot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EscalationTrigger is the condition of an escalation rule, of the form "3 filter in 1 day". The rule applies once a
+// user has that many unpardoned cases of that type within that much time.
+type EscalationTrigger string
+
+// EscalationAction is the punishment of an escalation rule, of the form "silence for 10 minutes", "timeout for 1 hour",
+// "kick" or "ban". Leaving off the duration makes a silence or ban permanent, and a timeout as long as discord allows.
+type EscalationAction string
+
+// Escalations can cause other escalations, like a silence leading to a ban, but only this many times in a row, so a
+// rule that triggers itself can't keep punishing someone forever.
+const maxEscalationDepth = 3
+
+type escalationTrigger struct {
+	count  int
+	ty     uint8
+	window Duration
+}
+
+type escalationAction struct {
+	ty     uint8    // CaseSilence, CaseTimeout, CaseKick or CaseBan
+	length Duration // A zero count means the punishment is permanent
+}
+
+// How harsh each punishment is, so that the worst one wins when several rules apply at once
+var escalationSeverity = map[uint8]int{CaseTimeout: 1, CaseSilence: 2, CaseKick: 3, CaseBan: 4}
+
+func parseDuration(count string, interval string) (Duration, error) {
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 {
+		return Duration{}, fmt.Errorf("%s is not a positive number!", count)
+	}
+	d := Duration{n, ParseRepeatInterval(interval)}
+	if d.Interval == 255 {
+		return Duration{}, fmt.Errorf("%s is not a valid interval!", interval)
+	}
+	return d, nil
+}
+
+func (t EscalationTrigger) parse() (r escalationTrigger, err error) {
+	s := strings.Fields(strings.ToLower(string(t)))
+	if len(s) != 5 || s[2] != "in" {
+		return r, errors.New("an escalation trigger must look like \"3 filter in 1 day\"")
+	}
+	if r.count, err = strconv.Atoi(s[0]); err != nil || r.count < 1 {
+		return r, fmt.Errorf("%s is not a positive number!", s[0])
+	}
+	var ok bool
+	if r.ty, ok = ParseCaseType(s[1]); !ok {
+		return r, fmt.Errorf("%s is not a case type! Use warning, silence, unsilence, timeout, kick, ban or filter.", s[1])
+	}
+	r.window, err = parseDuration(s[3], s[4])
+	return
+}
+
+func (t escalationTrigger) String() string {
+	return fmt.Sprintf("%v %s in %s", t.count, strings.ToLower(CaseTypeName(t.ty)), t.window.String())
+}
+
+// ParseEscalationTrigger checks that an escalation trigger is valid and returns it in a standard form
+func ParseEscalationTrigger(s string) (EscalationTrigger, error) {
+	t, err := EscalationTrigger(s).parse()
+	if err != nil {
+		return "", err
+	}
+	return EscalationTrigger(t.String()), nil
+}
+
+func (a EscalationAction) parse() (r escalationAction, err error) {
+	s := strings.Fields(strings.ToLower(string(a)))
+	if len(s) != 1 && (len(s) != 4 || s[1] != "for") {
+		return r, errors.New("an escalation action must look like \"silence for 10 minutes\" or \"ban\"")
+	}
+	switch s[0] {
+	case "silence":
+		r.ty = CaseSilence
+	case "timeout":
+		r.ty = CaseTimeout
+	case "kick":
+		r.ty = CaseKick
+	case "ban":
+		r.ty = CaseBan
+	default:
+		return r, fmt.Errorf("%s is not a punishment! Use silence, timeout, kick or ban.", s[0])
+	}
+	if len(s) == 4 {
+		if r.ty == CaseKick {
+			return r, errors.New("a kick can't last for a period of time")
+		}
+		if r.length, err = parseDuration(s[2], s[3]); err != nil {
+			return
+		}
+	}
+	if r.ty == CaseTimeout {
+		now := time.Now().UTC()
+		if r.length.Count == 0 {
+			r.length = Duration{28, 4}
+		} else if r.length.After(now).Sub(now) > MaxTimeout {
+			return r, errors.New("discord can't time someone out for longer than 28 days")
+		}
+	}
+	return
+}
+
+func (a escalationAction) String() string {
+	s := strings.ToLower(CaseTypeName(a.ty))
+	if a.length.Count > 0 {
+		s += " for " + a.length.String()
+	}
+	return s
+}
+
+// ParseEscalationAction checks that an escalation action is valid and returns it in a standard form
+func ParseEscalationAction(s string) (EscalationAction, error) {
+	a, err := EscalationAction(s).parse()
+	if err != nil {
+		return "", err
+	}
+	return EscalationAction(a.String()), nil
+}
+
+// harsher returns true if this action is a worse punishment than the other one. Bans are worse than kicks, which are
+// worse than silences and timeouts, and permanent punishments are worse than temporary ones.
+func (a escalationAction) harsher(other escalationAction, now time.Time) bool {
+	if a.ty != other.ty {
+		return escalationSeverity[a.ty] > escalationSeverity[other.ty]
+	}
+	if a.length.Count == 0 || other.length.Count == 0 {
+		return a.length.Count == 0 && other.length.Count != 0
+	}
+	return a.length.After(now).After(other.length.After(now))
+}
+
+// escalate checks the escalation rules after a user receives a case of the given type and applies the harshest
+// punishment out of all the rules whose thresholds have been reached.
+func (info *GuildInfo) escalate(ty uint8, user DiscordUser, depth int) {
+	if depth >= maxEscalationDepth || len(info.Config.Users.Escalation) == 0 {
+		return
+	}
+	now := time.Now().UTC()
+	var trigger EscalationTrigger
+	var action *escalationAction
+	for k, v := range info.Config.Users.Escalation {
+		t, err := k.parse()
+		if err != nil || t.ty != ty {
+			continue
+		}
+		a, err := v.parse()
+		if err != nil {
+			continue
+		}
+		if action != nil && !a.harsher(*action, now) && (action.harsher(a, now) || k > trigger) { // Break ties by name so the same rule always wins
+			continue
+		}
+		if info.Bot.DB.CountRecentCases(SBatoi(info.ID), user.Convert(), ty, t.window.After(now).Sub(now)) >= t.count {
+			trigger = k
+			action = &a
+		}
+	}
+	if action != nil {
+		info.applyEscalation(user, trigger, *action, now, depth)
+	}
+}
+
+// applyEscalation punishes a user for triggering an escalation rule. Temporary silences and bans create the same
+// unsilence and unban events as !silence and !ban, which the scheduler module then carries out.
+func (info *GuildInfo) applyEscalation(user DiscordUser, trigger EscalationTrigger, action escalationAction, now time.Time, depth int) {
+	gID := SBatoi(info.ID)
+	reason := "Escalation: " + string(trigger)
+	var until *time.Time
+	var duration time.Duration
+	if action.length.Count > 0 {
+		t := action.length.After(now)
+		until = &t
+		duration = t.Sub(now)
+	}
+	logger := info.Logger().With(LogFields{"user": user.String()})
+
+	var schedule *uint64
+	switch action.ty {
+	case CaseSilence:
+		if info.Config.Basic.SilenceRole == RoleEmpty {
+			return
+		}
+		existing := info.Bot.DB.GetScheduleDate(gID, EventSilence, user.String())
+		if info.UserHasRole(user, info.Config.Basic.SilenceRole) && (existing == nil || (until != nil && !until.After(*existing))) {
+			return // They're already silenced for at least this long
+		}
+		if err := info.ResolveRoleAddError(info.DG.GuildMemberRoleAdd(info.ID, user.String(), info.Config.Basic.SilenceRole.String())); err != nil {
+			logger.LogError("Error silencing user for escalation: ", err)
+			return
+		}
+		if existing != nil { // Replace the old unsilence event so it doesn't cut the new silence short
+			if id := info.Bot.DB.FindEvent(user.String(), gID, EventSilence); id != nil {
+				info.Bot.DB.RemoveSchedule(*id)
+			}
+		}
+		schedule = info.scheduleEscalation(until, EventSilence, user)
+	case CaseTimeout:
+		if existing := info.TimedOut(user); existing != nil && !until.After(*existing) {
+			return
+		}
+		var err error
+		if schedule, err = info.TimeoutMember(user, *until); err != nil {
+			logger.LogError("Error timing out user for escalation: ", err)
+			return
+		}
+	case CaseKick:
+		if err := info.DG.GuildMemberDelete(info.ID, user.String()); err != nil {
+			logger.LogError("Error kicking user for escalation: ", err)
+			return
+		}
+	case CaseBan:
+		if err := info.DG.GuildBanCreateWithReason(info.ID, user.String(), reason, 0); err != nil {
+			logger.LogError("Error banning user for escalation: ", err)
+			return
+		}
+		schedule = info.scheduleEscalation(until, EventBan, user)
+	}
+	info.addCase(action.ty, user, info.Bot.SelfID, reason, duration, schedule, depth+1)
+}
+
+// scheduleEscalation adds the event that ends a temporary punishment and returns its ID, or nil if it's permanent
+func (info *GuildInfo) scheduleEscalation(until *time.Time, ty uint8, user DiscordUser) *uint64 {
+	if until == nil {
+		return nil
+	}
+	gID := SBatoi(info.ID)
+	if err := info.Bot.DB.AddSchedule(gID, *until, ty, user.String()); err != nil {
+		info.Logger().With(LogFields{"user": user.String()}).LogError("Error scheduling end of escalation: ", err)
+		return nil
+	}
+	return info.Bot.DB.FindEvent(user.String(), gID, ty)
+}
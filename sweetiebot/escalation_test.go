@@ -0,0 +1,85 @@
+package sweetiebot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEscalation(t *testing.T) {
+	t.Parallel()
+
+	for k, v := range map[string]string{
+		"3 filter in 1 day":        "3 filter in 1 day",
+		"3 FILTERS IN 24 HOURS":    "3 filter in 24 hours",
+		"  2 silences in 1  week ": "2 silence in 1 week",
+		"1 warning in 30 days":     "1 warning in 30 days",
+	} {
+		r, err := ParseEscalationTrigger(k)
+		Check(err, nil, t)
+		Check(r, EscalationTrigger(v), t)
+	}
+	for _, v := range []string{"", "3 filter", "0 filter in 1 day", "3 kicks in 1 day", "3 filter in 1 fortnight", "3 filter by 1 day", "x filter in 1 day"} {
+		_, err := ParseEscalationTrigger(v)
+		CheckNot(err, nil, t)
+	}
+
+	for k, v := range map[string]string{
+		"silence for 10 minutes": "silence for 10 minutes",
+		"Ban For 1 Day":          "ban for 1 day",
+		"ban":                    "ban",
+		"silence":                "silence",
+		"timeout for 1 hour":     "timeout for 1 hour",
+		"timeout":                "timeout for 28 days",
+		"kick":                   "kick",
+	} {
+		r, err := ParseEscalationAction(k)
+		Check(err, nil, t)
+		Check(r, EscalationAction(v), t)
+	}
+	for _, v := range []string{"", "warning for 1 day", "silence 10 minutes", "ban for -1 days", "ban for 1", "kick for 1 day", "timeout for 5 weeks"} {
+		_, err := ParseEscalationAction(v)
+		CheckNot(err, nil, t)
+	}
+}
+
+func TestEscalationHarsher(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	silence := escalationAction{CaseSilence, Duration{10, 2}}
+	longSilence := escalationAction{CaseSilence, Duration{1, 4}}
+	permSilence := escalationAction{CaseSilence, Duration{}}
+	ban := escalationAction{CaseBan, Duration{1, 4}}
+	timeout := escalationAction{CaseTimeout, Duration{28, 4}}
+	kick := escalationAction{CaseKick, Duration{}}
+	Check(longSilence.harsher(silence, now), true, t)
+	Check(silence.harsher(longSilence, now), false, t)
+	Check(silence.harsher(silence, now), false, t)
+	Check(permSilence.harsher(longSilence, now), true, t)
+	Check(longSilence.harsher(permSilence, now), false, t)
+	Check(permSilence.harsher(permSilence, now), false, t)
+	Check(ban.harsher(permSilence, now), true, t)
+	Check(permSilence.harsher(ban, now), false, t)
+	Check(silence.harsher(timeout, now), true, t)
+	Check(kick.harsher(permSilence, now), true, t)
+	Check(ban.harsher(kick, now), true, t)
+}
+
+func TestSetEscalationConfig(t *testing.T) {
+	t.Parallel()
+
+	config := &BotConfig{}
+	_, ok := config.internalSetConfig(nil, "users.escalation", `"3 FILTERS in 1 day"`, "silence", "for", "10", "minutes")
+	Check(ok, true, t)
+	Check(config.Users.Escalation["3 filter in 1 day"], EscalationAction("silence for 10 minutes"), t)
+	_, ok = config.internalSetConfig(nil, "users.escalation", `"3 filters in 1 day"`, "ban")
+	Check(ok, true, t)
+	Check(config.Users.Escalation["3 filter in 1 day"], EscalationAction("ban"), t)
+	_, ok = config.internalSetConfig(nil, "users.escalation", `"3 filters"`, "ban")
+	Check(ok, false, t)
+	_, ok = config.internalSetConfig(nil, "users.escalation", `"3 filters in 1 day"`, "kick", "for", "1", "day")
+	Check(ok, false, t)
+	Check(len(config.Users.Escalation), 1, t)
+	config.internalSetConfig(nil, "users.escalation", `"3 filter in 1 day"`)
+	Check(len(config.Users.Escalation), 0, t)
+}
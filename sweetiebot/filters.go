@@ -0,0 +1,112 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blackhole12/discordgo"
+)
+
+// FilterAction is what happens to a message that matches one of the word filters in the filter config
+type FilterAction string
+
+// Filter actions. Filters without an action delete the message, which is what they always did.
+const (
+	FilterLog     FilterAction = "log"
+	FilterNotify  FilterAction = "notify"
+	FilterDelete  FilterAction = "delete"
+	FilterWarn    FilterAction = "warn"
+	FilterSilence FilterAction = "silence"
+)
+
+// From least to most severe
+var filterActions = []FilterAction{FilterLog, FilterNotify, FilterDelete, FilterWarn, FilterSilence}
+
+// ParseFilterAction parses the name of a filter action, ignoring case
+func ParseFilterAction(s string) (FilterAction, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, v := range filterActions {
+		if s == string(v) {
+			return v, nil
+		}
+	}
+	return FilterDelete, fmt.Errorf("%s is not a filter action! Use log, notify, delete, warn or silence.", s)
+}
+
+// Harsher returns true if this action is more severe than the other one. An empty action is the same as delete.
+func (a FilterAction) Harsher(other FilterAction) bool {
+	return a.severity() > other.severity()
+}
+
+// Deletes returns true if the action removes the message
+func (a FilterAction) Deletes() bool {
+	return !FilterNotify.Harsher(a)
+}
+
+func (a FilterAction) severity() int {
+	for i, v := range filterActions {
+		if a == v {
+			return i
+		}
+	}
+	return 2 // FilterDelete
+}
+
+// FilterMessage applies the action of a rule that a message broke, like a word filter. rule names the rule in
+// everything this says about it, like "the badwords filter", and content is what gets shown of the message. Deleting
+// the message records a single case of the same type as the action, so a warning or a silence is recorded as a warning
+// or a silence naming the rule, and a plain deletion as a filter case. Returns that case, or 0 if the action doesn't
+// delete messages or the case couldn't be recorded.
+func (info *GuildInfo) FilterMessage(m *discordgo.Message, action FilterAction, rule string, content string) uint64 {
+	user := DiscordUser(m.Author.ID)
+	switch action {
+	case FilterLog:
+		info.Logger().With(LogFields{"user": m.Author.ID, "channel": m.ChannelID}).Info(fmt.Sprintf("%s triggered %s: %s", m.Author.Username, rule, content))
+		return 0
+	case FilterNotify:
+		info.SendMessage(info.Config.Basic.ModChannel, fmt.Sprintf("%s triggered %s in <#%s>:\n```\n%s```", user.Display(), rule, m.ChannelID, info.Sanitize(content, CleanCodeBlock)))
+		return 0
+	}
+
+	ch, _ := info.DG.State.Channel(m.ChannelID)
+	info.ChannelMessageDelete(ch, m.ID)
+	info.Bot.Metrics.FilterDeletions.Inc()
+	switch action {
+	case FilterWarn:
+		return info.filterWarn(user, rule, content)
+	case FilterSilence:
+		if info.filterSilence(user) {
+			return info.AddCase(CaseSilence, user, info.Bot.SelfID, "Automatically silenced for triggering "+rule+": "+content, 0, nil)
+		}
+	}
+	return info.AddCase(CaseFilter, user, info.Bot.SelfID, "Message deleted by "+rule+": "+content, 0, nil)
+}
+
+// filterWarn records a warning against someone who broke a rule and tells them about it in a private message
+func (info *GuildInfo) filterWarn(user DiscordUser, rule string, content string) uint64 {
+	number := info.AddCase(CaseWarning, user, info.Bot.SelfID, "Triggered "+rule+": "+content, 0, nil)
+	if number == 0 {
+		return 0
+	}
+	ch, err := info.DG.UserChannelCreate(user.String())
+	if err == nil {
+		err = info.SendMessage(DiscordChannel(ch.ID), "You have been warned on "+info.Name+": Triggered "+rule+".")
+	}
+	if err != nil {
+		info.Logger().With(LogFields{"user": user.String()}).LogError("Error sending filter warning: ", err)
+	}
+	return number
+}
+
+// filterSilence silences someone who broke a rule. Returns false if they couldn't be silenced or already were, in
+// which case only the deletion is recorded.
+func (info *GuildInfo) filterSilence(user DiscordUser) bool {
+	if info.Config.Basic.SilenceRole == RoleEmpty || info.UserHasRole(user, info.Config.Basic.SilenceRole) {
+		return false
+	}
+	if err := info.ResolveRoleAddError(info.DG.GuildMemberRoleAdd(info.ID, user.String(), info.Config.Basic.SilenceRole.String())); err != nil {
+		info.Logger().With(LogFields{"user": user.String()}).LogError("Error silencing user for triggering a filter: ", err)
+		return false
+	}
+	return true
+}
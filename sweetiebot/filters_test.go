@@ -0,0 +1,45 @@
+package sweetiebot
+
+import "testing"
+
+func TestParseFilterAction(t *testing.T) {
+	t.Parallel()
+
+	for k, v := range map[string]FilterAction{
+		"log":      FilterLog,
+		"Notify":   FilterNotify,
+		" DELETE ": FilterDelete,
+		"warn":     FilterWarn,
+		"silence":  FilterSilence,
+	} {
+		a, err := ParseFilterAction(k)
+		Check(err, nil, t)
+		Check(a, v, t)
+	}
+	_, err := ParseFilterAction("ban")
+	CheckNot(err, nil, t)
+	_, err = ParseFilterAction("")
+	CheckNot(err, nil, t)
+
+	Check(FilterSilence.Harsher(FilterWarn), true, t)
+	Check(FilterWarn.Harsher(FilterDelete), true, t)
+	Check(FilterDelete.Harsher(FilterNotify), true, t)
+	Check(FilterNotify.Harsher(FilterLog), true, t)
+	Check(FilterDelete.Harsher(FilterDelete), false, t)
+	Check(FilterAction("").Harsher(FilterNotify), true, t)
+	Check(FilterAction("").Harsher(FilterDelete), false, t)
+	Check(FilterLog.Deletes(), false, t)
+	Check(FilterNotify.Deletes(), false, t)
+	Check(FilterDelete.Deletes(), true, t)
+	Check(FilterSilence.Deletes(), true, t)
+	Check(FilterAction("").Deletes(), true, t)
+
+	config := &BotConfig{}
+	config.FillConfig()
+	_, ok := config.internalSetConfig(nil, "filter.actions", "badwords WARN")
+	Check(ok, true, t)
+	Check(config.Filter.Actions["badwords"], FilterWarn, t)
+	_, ok = config.internalSetConfig(nil, "filter.actions", "badwords explode")
+	Check(ok, false, t)
+	Check(config.Filter.Actions["badwords"], FilterWarn, t)
+}
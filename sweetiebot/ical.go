@@ -0,0 +1,373 @@
+package sweetiebot
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CalendarEvent is an event read from an iCalendar file. If Err is set, the event couldn't be understood and should be
+// skipped, and Err explains why.
+type CalendarEvent struct {
+	UID        string
+	Summary    string
+	Start      time.Time
+	Recurrence *Recurrence // nil if the event doesn't repeat
+	Episode    bool        // True if the event is in the EPISODE category, which is how episodes are exported
+	Channel    DiscordChannel
+	Err        error
+}
+
+// CalendarConfig holds the calendar feed settings of a server. It is registered as the "Calendar" config category.
+type CalendarConfig struct {
+	Feed   bool   `json:"feed"`
+	Secret string `json:"secret"`
+}
+
+func init() {
+	RegisterConfig("Calendar", func() interface{} { return &CalendarConfig{} }, map[string]string{
+		"feed":   "If true, upcoming events and episodes are published as an iCalendar feed that members can subscribe to in their calendar apps. Use `!calendarfeed` to turn it on and get the link.",
+		"secret": "The secret part of the calendar feed's link. Anyone who knows it can see the schedule's events and episodes. Use `!calendarfeed reset` to change it if the link gets shared somewhere it shouldn't.",
+	})
+}
+
+// Calendar returns the calendar feed settings of the server. The caller must hold the config lock.
+func Calendar(info *GuildInfo) *CalendarConfig {
+	return info.Config.Section("Calendar").(*CalendarConfig)
+}
+
+// iCalendar lines are folded once they get longer than this many bytes
+const icalLineLength = 75
+
+var icalEscaper = strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\r\n", "\\n", "\n", "\\n")
+var icalUnescaper = strings.NewReplacer("\\\\", "\\", "\\;", ";", "\\,", ",", "\\n", "\n", "\\N", "\n")
+
+// icalWriter writes content lines, folding them and ending them with CRLF like RFC 5545 requires
+type icalWriter struct {
+	*bufio.Writer
+}
+
+func (w icalWriter) line(s string) {
+	limit := icalLineLength
+	for len(s) > limit {
+		i := limit
+		for i > 0 && !utf8.RuneStart(s[i]) { // Never split a multi-byte character
+			i--
+		}
+		w.WriteString(s[:i] + "\r\n ")
+		s = s[i:]
+		limit = icalLineLength - 1 // The space at the start of a continuation line counts
+	}
+	w.WriteString(s + "\r\n")
+}
+
+// WriteCalendar writes events and episodes from the schedule as an iCalendar file. Repeating events include their
+// recurrence rule and skipped dates. Each event's UID combines its ID with host, so that calendar apps can tell which
+// events they already have.
+func WriteCalendar(out io.Writer, name string, host string, events []ScheduleEvent) error {
+	w := icalWriter{bufio.NewWriter(out)}
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//Sweetie Bot//Schedule " + BotVersion.String() + "//EN")
+	w.line("CALSCALE:GREGORIAN")
+	w.line("X-WR-CALNAME:" + icalEscaper.Replace(name))
+	for _, e := range events {
+		if e.Type != EventGeneric && e.Type != EventEpisode {
+			continue
+		}
+		w.line("BEGIN:VEVENT")
+		w.line(fmt.Sprintf("UID:%v@%s", e.ID, host))
+		w.line("DTSTAMP:" + stamp)
+		if rule := e.Rule(); rule != nil {
+			// Repeating events have to be written in their own timezone so they keep their time across daylight saving
+			loc := rule.location()
+			local := e.Date.In(loc)
+			w.line("DTSTART;TZID=" + loc.String() + ":" + local.Format("20060102T150405"))
+			w.line("RRULE:" + rule.RRule())
+			if len(rule.Except) > 0 {
+				dates := make([]string, len(rule.Except))
+				for i, v := range rule.Except {
+					dates[i] = v + local.Format("T150405")
+				}
+				w.line("EXDATE;TZID=" + loc.String() + ":" + strings.Join(dates, ","))
+			}
+		} else {
+			w.line("DTSTART:" + e.Date.UTC().Format("20060102T150405Z"))
+		}
+		w.line("SUMMARY:" + icalEscaper.Replace(e.Payload().Message))
+		if e.Type == EventEpisode {
+			w.line("CATEGORIES:EPISODE")
+		} else {
+			w.line("CATEGORIES:EVENT")
+		}
+		if e.Channel != ChannelEmpty {
+			w.line("X-SWEETIEBOT-CHANNEL:" + e.Channel.String())
+		}
+		w.line("END:VEVENT")
+	}
+	w.line("END:VCALENDAR")
+	return w.Flush()
+}
+
+// icalProperty is a single unfolded content line, like DTSTART;TZID=America/New_York:20240109T190000
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+func parseICalProperty(line string) (icalProperty, bool) {
+	p := icalProperty{params: make(map[string]string)}
+	quoted := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return p, false
+	}
+	p.value = line[colon+1:]
+	parts := strings.Split(line[:colon], ";")
+	p.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		if kv := strings.SplitN(param, "=", 2); len(kv) == 2 {
+			p.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], "\"")
+		}
+	}
+	return p, true
+}
+
+// location returns the timezone the property's time is in. Times without a timezone are in loc.
+func (p icalProperty) location(loc *time.Location) (*time.Location, error) {
+	if tz, ok := p.params["TZID"]; ok {
+		l, err := time.LoadLocation(strings.TrimPrefix(tz, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a timezone", tz)
+		}
+		return l, nil
+	}
+	return loc, nil
+}
+
+// parseICalTime reads a DATE or DATE-TIME value. Dates on their own start at midnight.
+func parseICalTime(value string, loc *time.Location) (time.Time, error) {
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse("20060102T150405Z", value)
+	}
+	if len(value) == 8 {
+		return time.ParseInLocation("20060102", value, loc)
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+// ParseCalendar reads every VEVENT in an iCalendar file. Times that don't say what timezone they're in are read in loc.
+// Events that use features the schedule doesn't support are still returned, but with Err set.
+func ParseCalendar(r io.Reader, loc *time.Location) ([]CalendarEvent, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:] // Unfold continuation lines
+		} else if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || strings.ToUpper(lines[0]) != "BEGIN:VCALENDAR" {
+		return nil, errors.New("That isn't an iCalendar file")
+	}
+
+	events := []CalendarEvent{}
+	var e *CalendarEvent
+	var rrule, exdates []icalProperty
+	var start *icalProperty
+	depth := 0 // How deep we are inside components within the event, like alarms
+	for _, line := range lines {
+		p, ok := parseICalProperty(line)
+		if !ok {
+			continue
+		}
+		value := strings.ToUpper(p.value)
+		switch {
+		case p.name == "BEGIN" && value == "VEVENT" && e == nil:
+			e = &CalendarEvent{}
+			rrule, exdates, start = nil, nil, nil
+		case e == nil:
+		case p.name == "BEGIN":
+			depth++
+		case p.name == "END" && depth > 0:
+			depth--
+		case depth > 0:
+		case p.name == "END" && value == "VEVENT":
+			if start == nil {
+				e.Err = errors.New("it doesn't have a start date")
+			} else if e.Err == nil {
+				e.Err = e.resolve(*start, rrule, exdates, loc)
+			}
+			if e.Err == nil && len(strings.TrimSpace(e.Summary)) == 0 {
+				e.Err = errors.New("it doesn't have a name")
+			}
+			events = append(events, *e)
+			e = nil
+		case p.name == "UID":
+			e.UID = p.value
+		case p.name == "SUMMARY":
+			e.Summary = icalUnescaper.Replace(p.value)
+		case p.name == "DTSTART":
+			dtstart := p
+			start = &dtstart
+		case p.name == "RRULE":
+			rrule = append(rrule, p)
+		case p.name == "EXDATE":
+			exdates = append(exdates, p)
+		case p.name == "CATEGORIES":
+			for _, c := range strings.Split(value, ",") {
+				e.Episode = e.Episode || strings.TrimSpace(c) == "EPISODE"
+			}
+		case p.name == "X-SWEETIEBOT-CHANNEL":
+			e.Channel = DiscordChannel(p.value)
+		case p.name == "STATUS" && value == "CANCELLED":
+			e.Err = errors.New("it was cancelled")
+		case p.name == "RECURRENCE-ID":
+			e.Err = errors.New("changes to a single occurrence of a repeating event aren't supported")
+		case p.name == "RDATE":
+			e.Err = errors.New("extra dates added to a repeating event aren't supported")
+		}
+	}
+	return events, nil
+}
+
+// resolve works out when the event starts and how it repeats
+func (e *CalendarEvent) resolve(start icalProperty, rrule []icalProperty, exdates []icalProperty, loc *time.Location) error {
+	l, err := start.location(loc)
+	if err != nil {
+		return err
+	}
+	if e.Start, err = parseICalTime(start.value, l); err != nil {
+		return fmt.Errorf("%s is not a valid start date", start.value)
+	}
+	if len(rrule) > 1 {
+		return errors.New("it has more than one recurrence rule")
+	}
+	if len(rrule) == 0 {
+		return nil
+	}
+	if strings.HasSuffix(start.value, "Z") {
+		l = time.UTC
+	}
+	if e.Recurrence, err = ParseRecurrence(rrule[0].value, l); err != nil {
+		return err
+	}
+	if err = e.Recurrence.Validate(e.Start); err != nil {
+		return err
+	}
+	for _, p := range exdates {
+		exl, err := p.location(l)
+		if err != nil {
+			return err
+		}
+		for _, v := range strings.Split(p.value, ",") {
+			t, err := parseICalTime(v, exl)
+			if err != nil {
+				return fmt.Errorf("%s is not a valid date to skip", v)
+			}
+			e.Recurrence.Except = append(e.Recurrence.Except, t.In(l).Format("20060102"))
+		}
+	}
+	sort.Strings(e.Recurrence.Except)
+	return nil
+}
+
+// CalendarEvents returns every upcoming event and episode in the guild's schedule, soonest first
+func (info *GuildInfo) CalendarEvents() []ScheduleEvent {
+	guild := SBatoi(info.ID)
+	events := append(info.Bot.DB.GetEventsByType(guild, EventGeneric, MaxScheduleRows), info.Bot.DB.GetEventsByType(guild, EventEpisode, MaxScheduleRows)...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Date.Before(events[j].Date) })
+	return events
+}
+
+// SetCalendarFeed turns the guild's calendar feed on or off and saves the config. The feed gets a new secret the first
+// time it is turned on, or if reset is true, which breaks every link to it that was handed out before.
+func (info *GuildInfo) SetCalendarFeed(enabled bool, reset bool) error {
+	info.ConfigLock.Lock()
+	config := Calendar(info)
+	config.Feed = enabled
+	if reset || (enabled && len(config.Secret) == 0) {
+		config.Secret = randomToken()
+	}
+	info.ConfigLock.Unlock()
+	return info.SaveConfig()
+}
+
+// CalendarURL returns the address of the guild's calendar feed, or an empty string if the feed is turned off
+func (info *GuildInfo) CalendarURL() string {
+	info.ConfigLock.RLock()
+	config := Calendar(info)
+	enabled, secret := config.Feed, config.Secret
+	info.ConfigLock.RUnlock()
+	if !enabled || len(secret) == 0 {
+		return ""
+	}
+	scheme := "http"
+	host := info.Bot.WebDomain
+	if info.Bot.WebSecure {
+		scheme = "https"
+	} else if strings.HasPrefix(info.Bot.WebPort, ":") && info.Bot.WebPort != ":80" {
+		host += info.Bot.WebPort
+	}
+	return scheme + "://" + host + "/calendar/" + info.ID + "/" + secret + ".ics"
+}
+
+// calendarHandler serves the calendar feed of a guild at /calendar/<guild>/<secret>.ics. Guilds that haven't turned
+// the feed on, and requests with the wrong secret, get a 404 so they can't tell which guilds have a feed.
+func (sb *SweetieBot) calendarHandler(w http.ResponseWriter, r *http.Request) {
+	parts := splitURL(r.URL.Path)
+	if len(parts) != 3 || !strings.HasSuffix(parts[2], ".ics") || (r.Method != "GET" && r.Method != "HEAD") {
+		http.Error(w, "Page not found", http.StatusNotFound)
+		return
+	}
+	sb.GuildsLock.RLock()
+	info, ok := sb.Guilds[DiscordGuild(parts[1])]
+	sb.GuildsLock.RUnlock()
+	if !ok {
+		http.Error(w, "Page not found", http.StatusNotFound)
+		return
+	}
+	info.ConfigLock.RLock()
+	config := Calendar(info)
+	enabled, secret := config.Feed, config.Secret
+	info.ConfigLock.RUnlock()
+	if !enabled || len(secret) == 0 || subtle.ConstantTimeCompare([]byte(secret), []byte(strings.TrimSuffix(parts[2], ".ics"))) != 1 {
+		http.Error(w, "Page not found", http.StatusNotFound)
+		return
+	}
+	if !sb.DB.CheckStatus() {
+		http.Error(w, "Database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	host := sb.WebDomain
+	if len(host) == 0 {
+		host = r.Host
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	WriteCalendar(w, info.Name, host, info.CalendarEvents())
+}
@@ -0,0 +1,185 @@
+package sweetiebot
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestWriteCalendar(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("Movie night ", 10) + "🎬"
+	var buf bytes.Buffer
+	err := WriteCalendar(&buf, "Test, Server", "example.com", []ScheduleEvent{
+		{ID: 1, Date: time.Date(2030, 1, 8, 1, 0, 0, 0, time.UTC), Type: EventGeneric, Data: "Game night; bring snacks", Channel: "123", Recurrence: "FREQ=WEEKLY;BYDAY=TU;TZID=America/Los_Angeles;EXDATE=20300115"},
+		{ID: 2, Date: time.Date(2030, 2, 1, 18, 0, 0, 0, time.UTC), Type: EventEpisode, Data: "S10E01"},
+		{ID: 3, Date: time.Date(2030, 2, 2, 18, 0, 0, 0, time.UTC), Type: EventReminder, Data: "1234|secret"},
+		{ID: 4, Date: time.Date(2030, 2, 3, 18, 0, 0, 0, time.UTC), Type: EventGeneric, Data: long},
+	})
+	if !Check(err, nil, t) {
+		return
+	}
+	s := buf.String()
+	for _, v := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-CALNAME:Test\\, Server\r\n",
+		"UID:1@example.com\r\n",
+		"DTSTART;TZID=America/Los_Angeles:20300107T170000\r\n",
+		"RRULE:FREQ=WEEKLY;BYDAY=TU\r\n",
+		"EXDATE;TZID=America/Los_Angeles:20300115T170000\r\n",
+		"SUMMARY:Game night\\; bring snacks\r\n",
+		"X-SWEETIEBOT-CHANNEL:123\r\n",
+		"DTSTART:20300201T180000Z\r\n",
+		"CATEGORIES:EPISODE\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(s, v) {
+			t.Errorf("calendar is missing %q", v)
+		}
+	}
+	if strings.Contains(s, "secret") {
+		t.Error("calendar shouldn't include reminders")
+	}
+	for _, line := range strings.Split(s, "\r\n") {
+		if len(line) > icalLineLength {
+			t.Errorf("line is longer than %v bytes: %q", icalLineLength, line)
+		}
+	}
+
+	events, err := ParseCalendar(&buf, time.UTC)
+	if !Check(err, nil, t) || !Check(len(events), 3, t) {
+		return
+	}
+	Check(events[0].Err, nil, t)
+	Check(events[0].Summary, "Game night; bring snacks", t)
+	Check(events[0].Start.Equal(time.Date(2030, 1, 8, 1, 0, 0, 0, time.UTC)), true, t)
+	Check(events[0].Channel, DiscordChannel("123"), t)
+	Check(events[0].Episode, false, t)
+	if CheckNot(events[0].Recurrence, (*Recurrence)(nil), t) {
+		Check(events[0].Recurrence.String(), "FREQ=WEEKLY;BYDAY=TU;TZID=America/Los_Angeles;EXDATE=20300115", t)
+	}
+	Check(events[1].Summary, "S10E01", t)
+	Check(events[1].Episode, true, t)
+	Check(events[1].Recurrence, (*Recurrence)(nil), t)
+	Check(events[2].Summary, long, t)
+}
+
+func TestParseCalendar(t *testing.T) {
+	t.Parallel()
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("timezone database isn't available")
+	}
+
+	_, err = ParseCalendar(strings.NewReader("hello"), time.UTC)
+	CheckNot(err, nil, t)
+
+	events, err := ParseCalendar(strings.NewReader(strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"SUMMARY:Floating",
+		"DTSTART:20300105T120000",
+		"BEGIN:VALARM",
+		"SUMMARY:Not the event",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:All day",
+		"DTSTART;VALUE=DATE:20300106",
+		"RRULE:FREQ=YEARLY",
+		"EXDATE;VALUE=DATE:20310106",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Moved",
+		"DTSTART:20300105T120000Z",
+		"RECURRENCE-ID:20300105T120000Z",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Every hour",
+		"DTSTART:20300105T120000Z",
+		"RRULE:FREQ=HOURLY",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Never again",
+		"DTSTART:20300105T120000Z",
+		"RRULE:FREQ=YEARLY;INTERVAL=100;BYMONTH=2;BYMONTHDAY=30",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Nowhere",
+		"DTSTART;TZID=Nowhere/Special:20300105T120000",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Cancelled",
+		"DTSTART:20300105T120000Z",
+		"STATUS:CANCELLED",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:No start",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20300105T120000Z",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")), ny)
+	if !Check(err, nil, t) || !Check(len(events), 9, t) {
+		return
+	}
+	Check(events[0].Err, nil, t)
+	Check(events[0].Summary, "Floating", t)
+	Check(events[0].Start.Equal(time.Date(2030, 1, 5, 12, 0, 0, 0, ny)), true, t)
+	Check(events[1].Err, nil, t)
+	Check(events[1].Start.Equal(time.Date(2030, 1, 6, 0, 0, 0, 0, ny)), true, t)
+	if CheckNot(events[1].Recurrence, (*Recurrence)(nil), t) {
+		Check(events[1].Recurrence.String(), "FREQ=YEARLY;TZID=America/New_York;EXDATE=20310106", t)
+	}
+	for _, e := range events[2:] {
+		if e.Err == nil {
+			t.Errorf("expected %q to fail", e.Summary)
+		}
+	}
+}
+
+func TestCalendarHandler(t *testing.T) {
+	sb, dbmock, _ := MockSweetieBot(t)
+	dbmock.MatchExpectationsInOrder(false)
+	info := sb.Guilds[NewDiscordGuild(TestServer|0)]
+	feed := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		sb.calendarHandler(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	Check(info.CalendarURL(), "", t)
+	Check(feed("/calendar/"+info.ID+"/.ics").Code, http.StatusNotFound, t)
+	config := Calendar(info)
+	config.Feed = true
+	config.Secret = "secret"
+	sb.WebDomain = "example.com"
+	sb.WebPort = ":8080"
+	Check(info.CalendarURL(), "http://example.com:8080/calendar/"+info.ID+"/secret.ics", t)
+	sb.WebSecure = true
+	Check(info.CalendarURL(), "https://example.com/calendar/"+info.ID+"/secret.ics", t)
+
+	Check(feed("/calendar/"+info.ID+"/wrong.ics").Code, http.StatusNotFound, t)
+	Check(feed("/calendar/1/secret.ics").Code, http.StatusNotFound, t)
+	Check(feed("/calendar/"+info.ID+"/secret").Code, http.StatusNotFound, t)
+
+	columns := []string{"ID", "Date", "Type", "Data", "Channel", "Recurrence"}
+	dbmock.ExpectQuery("SELECT ID, Date, Type, Data, Channel, Recurrence FROM schedule.*").WithArgs(sqlmock.AnyArg(), EventGeneric, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows(columns).AddRow(1, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), EventGeneric, "Movie night", "", ""))
+	dbmock.ExpectQuery("SELECT ID, Date, Type, Data, Channel, Recurrence FROM schedule.*").WithArgs(sqlmock.AnyArg(), EventEpisode, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows(columns))
+	w := feed("/calendar/" + info.ID + "/secret.ics")
+	Check(w.Code, http.StatusOK, t)
+	Check(w.Header().Get("Content-Type"), "text/calendar; charset=utf-8", t)
+	if !strings.Contains(w.Body.String(), "SUMMARY:Movie night\r\n") {
+		t.Errorf("feed is missing the event: %s", w.Body.String())
+	}
+
+	config.Feed = false
+	Check(feed("/calendar/"+info.ID+"/secret.ics").Code, http.StatusNotFound, t)
+}
@@ -0,0 +1,522 @@
+package sweetiebot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+// Slash commands are newer than the version of discordgo we use, so this file defines the small part of the
+// application command and interaction API that we need.
+const (
+	interactionApplicationCommand = 2
+	interactionResponseMessage    = 4 // Responds to the interaction with a message
+	interactionResponseDeferred   = 5 // Acknowledges the interaction, which shows a loading message until we edit it
+	optionTypeString              = 3
+	optionTypeInteger             = 4
+	optionTypeUser                = 6
+	optionTypeChannel             = 7
+	optionTypeRole                = 8
+	messageFlagEphemeral          = 1 << 6 // Only the user who ran the command can see the message
+)
+
+// Limits that discord imposes on application commands
+const (
+	maxSlashCommands    = 100
+	maxSlashOptions     = 25
+	maxSlashName        = 32
+	maxSlashDescription = 100
+)
+
+// interactionDeferDelay is how long a slash command can run before we acknowledge it and send the result later.
+// Discord fails the interaction if we don't acknowledge it within 3 seconds.
+var interactionDeferDelay = 2 * time.Second
+
+var slashNameRegex = regexp.MustCompile("[^a-z0-9_-]+")
+
+type applicationCommand struct {
+	Name        string                      `json:"name"`
+	Description string                      `json:"description"`
+	Options     []*applicationCommandOption `json:"options,omitempty"`
+}
+
+type applicationCommandOption struct {
+	Type        int                         `json:"type"`
+	Name        string                      `json:"name"`
+	Description string                      `json:"description"`
+	Required    bool                        `json:"required,omitempty"`
+	Choices     []*applicationCommandChoice `json:"choices,omitempty"`
+	keyword     string                      // Put in front of the value when the command is rebuilt
+	argType     ArgType
+}
+
+type applicationCommandChoice struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type interactionOption struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+type interaction struct {
+	ID        string            `json:"id"`
+	Type      int               `json:"type"`
+	Token     string            `json:"token"`
+	GuildID   string            `json:"guild_id"`
+	ChannelID string            `json:"channel_id"`
+	Member    *discordgo.Member `json:"member"` // Only sent for interactions in a guild
+	User      *discordgo.User   `json:"user"`   // Only sent for interactions in private messages
+	Data      struct {
+		Name    string               `json:"name"`
+		Options []*interactionOption `json:"options"`
+	} `json:"data"`
+}
+
+type interactionMessage struct {
+	Content string                    `json:"content,omitempty"`
+	Embeds  []*discordgo.MessageEmbed `json:"embeds,omitempty"`
+	Flags   int                       `json:"flags,omitempty"`
+}
+
+type interactionResponse struct {
+	Type int                 `json:"type"`
+	Data *interactionMessage `json:"data,omitempty"`
+}
+
+// slashName turns a command or parameter name into a valid application command name
+func slashName(s string) string {
+	s = strings.Trim(slashNameRegex.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(s) > maxSlashName {
+		s = strings.TrimRight(s[:maxSlashName], "-")
+	}
+	return s
+}
+
+// slashDescription truncates a description to the maximum length discord allows, using fallback if it's empty
+func slashDescription(s string, fallback string) string {
+	if len(s) == 0 {
+		s = fallback
+	}
+	if len(s) == 0 {
+		s = "No description."
+	}
+	if r := []rune(s); len(r) > maxSlashDescription {
+		s = string(r[:maxSlashDescription-3]) + "..."
+	}
+	return s
+}
+
+// slashOptionType returns the option type discord should use for a parameter. Durations, times and other parameters
+// that discord has no type for are sent as strings.
+func slashOptionType(ty ArgType) int {
+	switch ty {
+	case ArgUser:
+		return optionTypeUser
+	case ArgChannel:
+		return optionTypeChannel
+	case ArgRole:
+		return optionTypeRole
+	case ArgInt:
+		return optionTypeInteger
+	}
+	return optionTypeString
+}
+
+// slashCommand builds the application command for a command out of its usage information. Every parameter becomes an
+// option of the matching type, and every parameter after the first optional one is optional, because discord requires
+// required options to come first. Variadic parameters are always strings, because they can hold more than one value.
+func slashCommand(info *GuildInfo, c Command) *applicationCommand {
+	cmd := &applicationCommand{
+		Name:        slashName(c.Info().Name),
+		Description: slashDescription(c.Info().Usage, c.Info().Name),
+		Options:     []*applicationCommandOption{},
+	}
+	usage := c.Usage(info)
+	if usage == nil {
+		return cmd
+	}
+	required := true
+	names := make(map[string]bool)
+	for _, p := range usage.Params {
+		if len(cmd.Options) >= maxSlashOptions {
+			break
+		}
+		required = required && !p.Optional
+		name := slashName(p.Name)
+		if len(name) == 0 {
+			name = "arg"
+		}
+		base := name
+		for i := 2; names[name]; i++ { // Parameter names aren't guaranteed to be unique once they've been sanitized
+			suffix := "-" + strconv.Itoa(i)
+			if len(base)+len(suffix) > maxSlashName {
+				base = base[:maxSlashName-len(suffix)]
+			}
+			name = base + suffix
+		}
+		names[name] = true
+		option := &applicationCommandOption{
+			Type:        slashOptionType(p.Type),
+			Name:        name,
+			Description: slashDescription(p.Desc, p.Name),
+			Required:    required,
+			keyword:     p.Keyword,
+			argType:     p.Type,
+		}
+		if p.Variadic {
+			option.Type = optionTypeString
+		}
+		if p.Type == ArgEnum {
+			for _, v := range p.Values {
+				option.Choices = append(option.Choices, &applicationCommandChoice{v, v})
+			}
+		}
+		cmd.Options = append(cmd.Options, option)
+	}
+	return cmd
+}
+
+// slashCommands returns the application commands for every enabled command in an enabled module, sorted by name
+func (info *GuildInfo) slashCommands() []*applicationCommand {
+	names := make([]string, 0, len(info.commands))
+	for k := range info.commands {
+		names = append(names, string(k))
+	}
+	sort.Strings(names)
+
+	cmds := []*applicationCommand{}
+	for _, name := range names {
+		c := info.commands[CommandID(name)]
+		if _, disabled := info.Config.Modules.CommandDisabled[CommandID(name)]; disabled {
+			continue
+		}
+		if _, disabled := info.Config.Modules.Disabled[info.commandmap[CommandID(name)]]; disabled {
+			continue
+		}
+		if cmd := slashCommand(info, c); len(cmd.Name) > 0 {
+			if len(cmds) >= maxSlashCommands {
+				info.Logger().Debug(fmt.Sprintf("Only the first %v commands were registered as slash commands.", maxSlashCommands))
+				break
+			}
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+}
+
+// findSlashCommand finds the command that was registered under the given application command name
+func (info *GuildInfo) findSlashCommand(name string) (Command, bool) {
+	if c, ok := info.commands[CommandID(name)]; ok {
+		return c, true
+	}
+	for k, c := range info.commands {
+		if slashName(string(k)) == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// applicationID returns the ID of the bot's application. Bots created since 2016 share their ID with their
+// application, which lets us register commands before the application information has been fetched.
+func (sb *SweetieBot) applicationID() string {
+	if sb.AppID != 0 {
+		return strconv.FormatUint(sb.AppID, 10)
+	}
+	return sb.SelfID.String()
+}
+
+// RegisterSlashCommands replaces the guild's slash commands with the currently enabled commands, or removes them all
+// if Basic.SlashCommands is false. It does nothing if the commands haven't changed since they were last registered.
+func (info *GuildInfo) RegisterSlashCommands() {
+	if register := info.prepareSlashCommands(); register != nil {
+		register()
+	}
+}
+
+// prepareSlashCommands builds the guild's slash commands from its config, and returns a function that sends them to
+// discord, which can be run in the background. Returns nil if there is nothing to send, including when slash commands
+// are disabled and were never registered.
+func (info *GuildInfo) prepareSlashCommands() func() {
+	if info.Bot.IsUserMode {
+		return nil
+	}
+	cmds := []*applicationCommand{}
+	if info.Config.Basic.SlashCommands {
+		cmds = info.slashCommands()
+	}
+	payload, err := json.Marshal(cmds)
+	if err != nil {
+		info.Logger().Error("Error encoding slash commands:", err)
+		return nil
+	}
+
+	info.slashLock.Lock()
+	defer info.slashLock.Unlock()
+	info.slashVersion++
+	version := info.slashVersion
+	if (info.slashPayload == nil && len(cmds) == 0) || bytes.Equal(payload, info.slashPayload) {
+		return nil
+	}
+	return func() {
+		info.slashLock.Lock()
+		defer info.slashLock.Unlock()
+		if version != info.slashVersion {
+			return // The commands changed again, and the newer registration replaces this one
+		}
+		endpoint := "applications/" + info.Bot.applicationID() + "/guilds/" + info.ID + "/commands"
+		if _, err := info.DG.InteractionRequest("PUT", endpoint, endpoint, cmds); err != nil {
+			info.Logger().LogError("Failed to register slash commands: ", err)
+			return
+		}
+		info.slashPayload = payload
+	}
+}
+
+// interactionValue turns the value of an option back into text. Discord sends users, roles and channels as IDs,
+// and numbers are decoded from JSON as floats.
+func interactionValue(value interface{}) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+// interactionContent rebuilds the text of a command from the options of an interaction, in the order the options
+// were registered, so it can be parsed exactly like a command sent as a message. The last option usually consumes
+// the rest of the message, so every other option is quoted if it contains spaces, except for durations and times,
+// which span several arguments.
+func interactionContent(prefix string, cmd *applicationCommand, options []*interactionOption) string {
+	values := make(map[string]string, len(options))
+	for _, o := range options {
+		values[o.Name] = interactionValue(o.Value)
+	}
+	args := []string{}
+	quote := []bool{}
+	for _, o := range cmd.Options {
+		if v, ok := values[o.Name]; ok && len(v) > 0 {
+			switch o.Type {
+			case optionTypeUser:
+				v = "<@" + v + ">"
+			case optionTypeRole:
+				v = "<@&" + v + ">"
+			case optionTypeChannel:
+				v = "<#" + v + ">"
+			}
+			if len(o.keyword) > 0 {
+				args = append(args, o.keyword)
+				quote = append(quote, false)
+			}
+			args = append(args, v)
+			quote = append(quote, o.argType != ArgDuration && o.argType != ArgTime)
+		}
+	}
+	for i, v := range args {
+		if i < len(args)-1 && quote[i] && strings.ContainsAny(v, " \t\n") && v[0] != '"' {
+			args[i] = "\"" + v + "\""
+		}
+	}
+	return strings.Join(append([]string{prefix + cmd.Name}, args...), " ")
+}
+
+// InteractionCreate discord hook. discordgo doesn't know about interactions, so we pick them out of the raw events.
+func (sb *SweetieBot) InteractionCreate(s *discordgo.Session, e *discordgo.Event) {
+	if e.Type != "INTERACTION_CREATE" {
+		return
+	}
+	var i interaction
+	if err := json.Unmarshal(e.RawData, &i); err != nil {
+		sb.Logger.Error("Error decoding interaction:", err)
+		return
+	}
+	if i.Type == interactionApplicationCommand {
+		sb.processInteraction(&i)
+	}
+}
+
+// processInteraction runs the command a slash command refers to, subject to all the same restrictions as a command
+// sent as a message. The result is sent as the response to the interaction, and is ephemeral if the command would
+// have sent it in a private message.
+func (sb *SweetieBot) processInteraction(i *interaction) {
+	author := i.User
+	if i.Member != nil {
+		author = i.Member.User
+	}
+	info := sb.getGuildFromID(i.GuildID)
+	if info == nil || author == nil {
+		return // We only register slash commands on guilds, so this is an interaction for another instance
+	}
+	channelID := DiscordChannel(i.ChannelID)
+	isdebug := info.IsDebug(channelID)
+	if boolXOR(sb.Debug, isdebug) { // debug builds only respond to the debug channel, and release builds ignore it
+		return
+	}
+
+	out := newInteractionOutput(sb, i)
+	defer out.finish()
+	now := time.Now().UTC()
+	c, ok := info.findSlashCommand(i.Data.Name)
+	if !ok {
+		out.Error(info, "Sorry, "+i.Data.Name+" is not a valid command.", now.Unix())
+		return
+	}
+
+	prefix := "!"
+	if len(info.Config.Basic.CommandPrefix) == 1 {
+		prefix = info.Config.Basic.CommandPrefix
+	}
+	m := &discordgo.Message{
+		ID:        i.ID,
+		ChannelID: i.ChannelID,
+		Content:   interactionContent(prefix, slashCommand(info, c), i.Data.Options),
+		Timestamp: discordgo.Timestamp(now.Format(time.RFC3339)),
+		Author:    author,
+		Mentions:  []*discordgo.User{},
+	}
+	for _, match := range UserRegex.FindAllString(m.Content, -1) {
+		if member, err := info.DG.GetMember(DiscordUser(StripPing(match)), info.ID); err == nil {
+			m.Mentions = append(m.Mentions, member.User)
+		}
+	}
+
+	args, indices := ParseArguments(m.Content[1:])
+	_, isfree := info.Config.Basic.FreeChannels[channelID]
+	sb.runCommand(c, args[1:], indices[1:], m, info, now.Unix(), isdebug, isfree, false, out)
+}
+
+// interactionOutput sends the result of a slash command as the response to its interaction. If the command takes
+// too long, the interaction is acknowledged and the result is edited in once it's ready.
+type interactionOutput struct {
+	sb        *SweetieBot
+	id        string
+	token     string
+	lock      sync.Mutex
+	deferred  bool
+	responded bool
+	timer     *time.Timer
+}
+
+func newInteractionOutput(sb *SweetieBot, i *interaction) *interactionOutput {
+	o := &interactionOutput{sb: sb, id: i.ID, token: i.Token}
+	o.timer = time.AfterFunc(interactionDeferDelay, o.deferResponse)
+	return o
+}
+
+func (o *interactionOutput) webhook() string {
+	return "webhooks/" + o.sb.applicationID()
+}
+
+func (o *interactionOutput) callback(r *interactionResponse) error {
+	_, err := o.sb.DG.InteractionRequest("POST", "interactions/"+o.id+"/"+o.token+"/callback", "interactions", r)
+	return err
+}
+
+func (o *interactionOutput) deferResponse() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if !o.responded && !o.deferred {
+		o.deferred = o.callback(&interactionResponse{Type: interactionResponseDeferred}) == nil
+	}
+}
+
+func (o *interactionOutput) send(msg *interactionMessage) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.timer.Stop()
+	if o.responded {
+		_, err := o.sb.DG.InteractionRequest("POST", o.webhook()+"/"+o.token, o.webhook(), msg)
+		return err
+	}
+	o.responded = true
+	if !o.deferred {
+		return o.callback(&interactionResponse{interactionResponseMessage, msg})
+	}
+	original := o.webhook() + "/" + o.token + "/messages/@original"
+	if msg.Flags&messageFlagEphemeral == 0 {
+		_, err := o.sb.DG.InteractionRequest("PATCH", original, o.webhook(), msg)
+		return err
+	}
+	// A deferred response can't be made ephemeral after the fact, so we replace it with an ephemeral followup
+	o.sb.DG.InteractionRequest("DELETE", original, o.webhook(), nil)
+	_, err := o.sb.DG.InteractionRequest("POST", o.webhook()+"/"+o.token, o.webhook(), msg)
+	return err
+}
+
+// finish makes sure the interaction gets a response, even if the command didn't have anything to say
+func (o *interactionOutput) finish() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.timer.Stop()
+	if o.responded {
+		return
+	}
+	o.responded = true
+	if o.deferred {
+		o.sb.DG.InteractionRequest("DELETE", o.webhook()+"/"+o.token+"/messages/@original", o.webhook(), nil)
+	} else {
+		o.callback(&interactionResponse{interactionResponseMessage, &interactionMessage{Content: "```\nDone!```", Flags: messageFlagEphemeral}})
+	}
+}
+
+// Error tells the user why their command failed. Unlike SendError, this isn't rate limited, because only the user
+// who ran the command can see it.
+func (o *interactionOutput) Error(info *GuildInfo, message string, t int64) {
+	if err := o.send(&interactionMessage{Content: "```\n" + message + "```", Flags: messageFlagEphemeral}); err != nil {
+		o.logError(info, err)
+	}
+}
+
+// Ignore tells the user the command was discarded, because discord shows an error if an interaction gets no response
+func (o *interactionOutput) Ignore(info *GuildInfo, err error) {
+	if err == errIgnored {
+		o.Error(info, "That command can't be used here.", 0)
+	} else {
+		o.Error(info, "You can't use that command: "+err.Error()+".", 0)
+	}
+}
+
+// logError records a failed response in the host log
+func (o *interactionOutput) logError(info *GuildInfo, err error) {
+	info.Logger().hostOnly().With(LogFields{"interaction": o.id}).Error("Failed to respond to interaction:", err)
+}
+
+func (o *interactionOutput) Result(info *GuildInfo, m *discordgo.Message, result string, usepm bool, embed *discordgo.MessageEmbed) {
+	flags := 0
+	if usepm {
+		flags = messageFlagEphemeral
+	}
+	if embed != nil {
+		fields := embed.Fields
+		for len(fields) > 25 {
+			e := *embed
+			e.Fields = fields[:25]
+			fields = fields[25:]
+			if err := o.send(&interactionMessage{Embeds: []*discordgo.MessageEmbed{&e}, Flags: flags}); err != nil {
+				o.logError(info, err)
+				return
+			}
+		}
+		e := *embed
+		e.Fields = fields
+		if err := o.send(&interactionMessage{Embeds: []*discordgo.MessageEmbed{&e}, Flags: flags}); err != nil {
+			o.logError(info, err)
+		}
+	} else if len(result) > 0 {
+		for _, part := range splitMessage(result) {
+			if err := o.send(&interactionMessage{Content: part, Flags: flags}); err != nil {
+				o.logError(info, err)
+				return
+			}
+		}
+	}
+}
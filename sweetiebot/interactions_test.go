@@ -0,0 +1,136 @@
+package sweetiebot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blackhole12/discordgo"
+)
+
+type slashTestCommand struct {
+}
+
+func (c *slashTestCommand) Info() *CommandInfo {
+	return &CommandInfo{
+		Name:  "Slash Test",
+		Usage: strings.Repeat("a", 150),
+	}
+}
+func (c *slashTestCommand) Process(args []string, msg *discordgo.Message, indices []int, info *GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	return "", false, nil
+}
+func (c *slashTestCommand) Usage(info *GuildInfo) *CommandUsage {
+	return &CommandUsage{
+		Params: []CommandUsageParam{
+			{Name: "#channel", Desc: "", Optional: false},
+			{Name: "[channel]", Desc: "Duplicate", Optional: true},
+			{Name: "user", Desc: "Required, but after an optional parameter", Optional: false},
+			{Name: "???", Desc: "Arbitrary string", Optional: false, Variadic: true},
+		},
+	}
+}
+
+func TestSlashCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := slashCommand(&GuildInfo{}, &slashTestCommand{})
+	Check(cmd.Name, "slash-test", t)
+	Check(len([]rune(cmd.Description)), maxSlashDescription, t)
+	Check(len(cmd.Options), 4, t)
+	Check(cmd.Options[0].Name, "channel", t)
+	Check(cmd.Options[0].Description, "#channel", t)
+	Check(cmd.Options[0].Required, true, t)
+	Check(cmd.Options[1].Name, "channel-2", t)
+	Check(cmd.Options[1].Required, false, t)
+	Check(cmd.Options[2].Required, false, t)
+	Check(cmd.Options[3].Name, "arg", t)
+
+	Check(slashName("Get Config"), "get-config", t)
+	Check(slashName("module|command"), "module-command", t)
+	Check(len(slashName(strings.Repeat("ab ", 20))), maxSlashName, t)
+	Check(slashDescription("", "fallback"), "fallback", t)
+}
+
+func TestInteractionContent(t *testing.T) {
+	t.Parallel()
+
+	cmd := slashCommand(&GuildInfo{}, &slashTestCommand{})
+	Check(interactionContent("!", cmd, []*interactionOption{}), "!slash-test", t)
+	Check(interactionContent("!", cmd, []*interactionOption{
+		{"arg", "some words here"},
+		{"channel", "#general"},
+		{"user", "Cloud Hop"},
+	}), "!slash-test #general \"Cloud Hop\" some words here", t)
+	Check(interactionContent(".", cmd, []*interactionOption{{"channel-2", "  spaced out  "}}), ".slash-test spaced out", t)
+}
+
+func TestRegisterSlashCommands(t *testing.T) {
+	sb, _, _ := MockSweetieBot(t)
+	info := sb.Guilds[NewDiscordGuild(TestServer)]
+	module := &InfoModule{}
+	info.RegisterModule(module)
+	for _, command := range module.Commands() {
+		info.AddCommand(command, module)
+	}
+	endpoint := EndpointInteractions + "applications/" + sb.SelfID.String() + "/guilds/" + info.ID + "/commands"
+
+	mock.Expect(sb.DG.RequestWithBucketID, "PUT", endpoint, MockAny{}, endpoint)
+	info.RegisterSlashCommands()
+	info.RegisterSlashCommands() // Nothing changed, so this must not send another request
+	Check(len(info.slashCommands()), len(module.Commands()), t)
+
+	info.Config.Modules.CommandDisabled["about"] = true
+	mock.Expect(sb.DG.RequestWithBucketID, "PUT", endpoint, MockAny{}, endpoint)
+	info.RegisterSlashCommands()
+	Check(len(info.slashCommands()), len(module.Commands())-1, t)
+
+	info.Config.Basic.SlashCommands = false
+	mock.Expect(sb.DG.RequestWithBucketID, "PUT", endpoint, []*applicationCommand{}, endpoint)
+	info.RegisterSlashCommands()
+	Check(mock.Check(), true, t)
+
+	// A guild that never had slash commands doesn't have to remove them every time the bot starts
+	info.slashPayload = nil
+	Check(info.prepareSlashCommands() == nil, true, t)
+
+	// A registration that was overtaken by a newer one is dropped
+	info.Config.Basic.SlashCommands = true
+	old := info.prepareSlashCommands()
+	mock.Expect(sb.DG.RequestWithBucketID, "PUT", endpoint, MockAny{}, endpoint)
+	info.RegisterSlashCommands()
+	old()
+	Check(mock.Check(), true, t)
+}
+
+type slashTypedCommand struct {
+	slashTestCommand
+}
+
+func (c *slashTypedCommand) Usage(info *GuildInfo) *CommandUsage {
+	return argTestUsage
+}
+
+func TestSlashTypedOptions(t *testing.T) {
+	t.Parallel()
+
+	cmd := slashCommand(&GuildInfo{}, &slashTypedCommand{})
+	Check(len(cmd.Options), 5, t)
+	Check(cmd.Options[0].Type, optionTypeUser, t)
+	Check(cmd.Options[1].Type, optionTypeString, t)
+	Check(len(cmd.Options[2].Choices), 2, t)
+	Check(cmd.Options[2].Choices[1].Value, "loud", t)
+	Check(cmd.Options[3].Type, optionTypeInteger, t)
+
+	content := interactionContent("!", cmd, []*interactionOption{
+		{"user", "123"},
+		{"duration", "5 minutes"},
+		{"count", float64(3)},
+		{"mode", "quiet"},
+		{"reason", "just because"},
+	})
+	Check(content, "!slash-test <@123> for: 5 minutes quiet 3 just because", t)
+	a, err := parseTestArgs(argTestUsage, content)
+	Check(err, nil, t)
+	Check(a.Duration("duration"), Duration{5, 2}, t)
+	Check(a.Int("count", 0), 3, t)
+}
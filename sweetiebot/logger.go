@@ -1,24 +1,305 @@
 package sweetiebot
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/blackhole12/discordgo"
+)
+
+// LogLevel is the severity of a log entry
+type LogLevel uint8
+
+// Log levels, from least to most severe. LogNone is only used as a threshold, to turn logging off completely.
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarning
+	LogError
+	LogNone
 )
 
-type logger interface {
-	Log(args ...interface{})
-	LogError(msg string, err error)
+var logLevelNames = []string{"debug", "info", "warning", "error", "none"}
+
+func (l LogLevel) String() string {
+	if int(l) < len(logLevelNames) {
+		return logLevelNames[l]
+	}
+	return "unknown"
+}
+
+// ParseLogLevel parses the name of a log level, ignoring case
+func ParseLogLevel(s string) (LogLevel, error) {
+	for i, v := range logLevelNames {
+		if strings.EqualFold(s, v) {
+			return LogLevel(i), nil
+		}
+	}
+	return LogNone, fmt.Errorf("%s is not a log level! Use one of: %s", s, strings.Join(logLevelNames, ", "))
+}
+
+// MarshalText stores a log level by name in config files
+func (l LogLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText reads a log level by name
+func (l *LogLevel) UnmarshalText(b []byte) (err error) {
+	*l, err = ParseLogLevel(string(b))
+	return
+}
+
+// LogFields are key/value pairs attached to a log entry, like the guild, channel, user or command it came from
+type LogFields map[string]interface{}
+
+// Logger writes leveled log entries to the console and, if a file has been opened, to that file as one JSON object
+// per line, so host logs can be searched by any field.
+type Logger struct {
+	Level LogLevel // Entries below this level are discarded
+	lock  sync.Mutex
+	out   io.Writer
+	file  *rotatingFile
+}
+
+// NewLogger creates a logger that writes human-readable entries to out
+func NewLogger(level LogLevel, out io.Writer) *Logger {
+	return &Logger{Level: level, out: out}
+}
+
+// OpenFile starts writing JSON entries to path. Once the file reaches maxSize bytes it is renamed to path.1, and up to
+// maxFiles old files are kept.
+func (l *Logger) OpenFile(path string, maxSize int64, maxFiles int) error {
+	f, err := openRotatingFile(path, maxSize, maxFiles)
+	if err != nil {
+		return err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file = f
+	return nil
+}
+
+// Close closes the log file, if there is one
+func (l *Logger) Close() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+func (l *Logger) write(level LogLevel, fields LogFields, msg string) {
+	if l == nil || level < l.Level {
+		return
+	}
+	now := time.Now()
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.out != nil {
+		line := fmt.Sprintf("[%s] %-7s %s", now.Format(time.Stamp), strings.ToUpper(level.String()), msg)
+		for _, k := range keys {
+			line += fmt.Sprintf(" %s=%v", k, fields[k])
+		}
+		fmt.Fprintln(l.out, line)
+	}
+	if l.file != nil {
+		entry := make(map[string]interface{}, len(fields)+3)
+		for k, v := range fields {
+			entry[k] = v
+		}
+		entry["time"] = now.UTC().Format(time.RFC3339Nano)
+		entry["level"] = level.String()
+		entry["msg"] = msg
+		if b, err := json.Marshal(entry); err == nil {
+			l.file.Write(append(b, '\n'))
+		}
+	}
+}
+
+// With returns a log entry with the given fields attached
+func (l *Logger) With(fields LogFields) *LogEntry {
+	return (&LogEntry{logger: l}).With(fields)
+}
+
+// Debug logs its arguments, separated by spaces, at LogDebug
+func (l *Logger) Debug(args ...interface{}) { l.With(nil).Log(LogDebug, args...) }
+
+// Info logs its arguments, separated by spaces, at LogInfo
+func (l *Logger) Info(args ...interface{}) { l.With(nil).Log(LogInfo, args...) }
+
+// Warning logs its arguments, separated by spaces, at LogWarning
+func (l *Logger) Warning(args ...interface{}) { l.With(nil).Log(LogWarning, args...) }
+
+// Error logs its arguments, separated by spaces, at LogError
+func (l *Logger) Error(args ...interface{}) { l.With(nil).Log(LogError, args...) }
+
+// LogError logs an error at LogError only if it exists
+func (l *Logger) LogError(msg string, err error) { l.With(nil).LogError(msg, err) }
+
+// LogEntry is a set of fields that are attached to everything logged through it. Entries that belong to a guild are
+// also posted in that guild's log channel if they are at least as severe as the guild's Log.Level option.
+type LogEntry struct {
+	logger *Logger
+	guild  *GuildInfo
+	fields LogFields
+}
+
+// With returns a copy of the entry with more fields attached
+func (e *LogEntry) With(fields LogFields) *LogEntry {
+	r := &LogEntry{logger: e.logger, guild: e.guild, fields: make(LogFields, len(e.fields)+len(fields))}
+	for k, v := range e.fields {
+		r.fields[k] = v
+	}
+	for k, v := range fields {
+		r.fields[k] = v
+	}
+	return r
 }
 
-type emptyLog struct{}
+// hostOnly returns a copy of the entry that is only written to the host log, never the database or a log channel.
+// Failures to send messages or write audit logs use this, so they can't cause more failures.
+func (e *LogEntry) hostOnly() *LogEntry {
+	return &LogEntry{logger: e.logger, fields: e.fields}
+}
 
-func (log *emptyLog) Log(args ...interface{}) {
-	s := fmt.Sprint(args...)
-	fmt.Printf("[%s] %s\n", time.Now().Format(time.Stamp), s)
+// Log logs its arguments, separated by spaces, at the given level
+func (e *LogEntry) Log(level LogLevel, args ...interface{}) {
+	e.write(level, strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
 }
 
-func (log *emptyLog) LogError(msg string, err error) {
+func (e *LogEntry) write(level LogLevel, msg string) {
+	e.logger.write(level, e.fields, msg)
+	if info := e.guild; info != nil && level != LogDebug {
+		if info.Bot.DB != nil && info.Bot.IsMainGuild(info) && info.Bot.DB.Status.Get() {
+			info.Bot.DB.Audit(AuditTypeLog, nil, msg, SBatoi(info.ID))
+		}
+		if info.Config.Log.Channel != ChannelEmpty && level >= info.Config.Log.Level {
+			info.SendMessage(info.Config.Log.Channel, "```\n"+msg+"```")
+		}
+	}
+}
+
+// Debug logs its arguments, separated by spaces, at LogDebug
+func (e *LogEntry) Debug(args ...interface{}) { e.Log(LogDebug, args...) }
+
+// Info logs its arguments, separated by spaces, at LogInfo
+func (e *LogEntry) Info(args ...interface{}) { e.Log(LogInfo, args...) }
+
+// Warning logs its arguments, separated by spaces, at LogWarning
+func (e *LogEntry) Warning(args ...interface{}) { e.Log(LogWarning, args...) }
+
+// Error logs its arguments, separated by spaces, at LogError
+func (e *LogEntry) Error(args ...interface{}) { e.Log(LogError, args...) }
+
+// LogError logs an error at LogError only if it exists
+func (e *LogEntry) LogError(msg string, err error) {
 	if err != nil {
-		log.Log(msg, err.Error())
+		e.write(LogError, msg+err.Error())
+	}
+}
+
+// consoleLogger is used by guilds that aren't attached to a bot, like the ones a migration dry run creates
+var consoleLogger = NewLogger(LogInfo, os.Stdout)
+
+// Logger returns a log entry for this guild, which also posts to the guild's log channel
+func (info *GuildInfo) Logger() *LogEntry {
+	if info.Bot == nil {
+		return consoleLogger.With(LogFields{"guild": info.ID})
+	}
+	e := info.Bot.Logger.With(LogFields{"guild": info.ID})
+	e.guild = info
+	return e
+}
+
+// MessageLogger returns a log entry for something that happened because of a message in this guild. Commands log
+// through this with the message ID attached, so any error they log can be matched up with the command that caused it.
+func (info *GuildInfo) MessageLogger(m *discordgo.Message) *LogEntry {
+	fields := LogFields{"channel": m.ChannelID, "message": m.ID}
+	if m.Author != nil {
+		fields["user"] = m.Author.ID
+	}
+	return info.Logger().With(fields)
+}
+
+// rotatingFile is a log file that is renamed to path.1 once it gets too big, shifting older files up by one
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	size     int64
+	f        *os.File
+}
+
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	return r, r.open()
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = stat.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	r.f.Close()
+	os.Remove(fmt.Sprintf("%s.%v", r.path, r.maxFiles))
+	for i := r.maxFiles - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%v", r.path, i), fmt.Sprintf("%s.%v", r.path, i+1))
+	}
+	if r.maxFiles > 0 {
+		os.Rename(r.path, r.path+".1")
+	} else {
+		os.Remove(r.path)
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Write(b []byte) (int, error) {
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(b)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			r.f = nil
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Close() error {
+	if r.f == nil {
+		return nil
 	}
+	err := r.f.Close()
+	r.f = nil
+	return err
 }
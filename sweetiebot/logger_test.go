@@ -0,0 +1,107 @@
+package sweetiebot
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blackhole12/discordgo"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	t.Parallel()
+
+	for i, v := range logLevelNames {
+		l, err := ParseLogLevel(strings.ToUpper(v))
+		Check(err, nil, t)
+		Check(l, LogLevel(i), t)
+		Check(l.String(), v, t)
+	}
+	_, err := ParseLogLevel("loud")
+	CheckNot(err, nil, t)
+
+	var config struct {
+		Level LogLevel `json:"level"`
+	}
+	Check(json.Unmarshal([]byte(`{"level": "warning"}`), &config), nil, t)
+	Check(config.Level, LogWarning, t)
+	b, _ := json.Marshal(config)
+	Check(string(b), `{"level":"warning"}`, t)
+}
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "sweetielog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sweetie.log")
+
+	var out bytes.Buffer
+	l := NewLogger(LogInfo, &out)
+	Check(l.OpenFile(path, 1024, 2), nil, t)
+	l.Debug("hidden")
+	l.With(LogFields{"guild": "123", "command": "ban"}).Error("Error banning user:", "missing permissions")
+	l.Close()
+
+	Check(strings.Contains(out.String(), "hidden"), false, t)
+	Check(strings.Contains(out.String(), "ERROR   Error banning user: missing permissions command=ban guild=123"), true, t)
+
+	data, err := ioutil.ReadFile(path)
+	Check(err, nil, t)
+	var entry map[string]interface{}
+	Check(json.Unmarshal(data, &entry), nil, t)
+	Check(entry["level"], "error", t)
+	Check(entry["guild"], "123", t)
+	Check(entry["command"], "ban", t)
+	Check(entry["msg"], "Error banning user: missing permissions", t)
+}
+
+func TestLoggerRotation(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "sweetielog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sweetie.log")
+
+	l := NewLogger(LogDebug, nil)
+	Check(l.OpenFile(path, 300, 2), nil, t)
+	for i := 0; i < 10; i++ {
+		l.Info(strings.Repeat("x", 50))
+	}
+	l.Close()
+
+	_, err = os.Stat(path + ".1")
+	Check(err, nil, t)
+	_, err = os.Stat(path + ".2")
+	Check(err, nil, t)
+	_, err = os.Stat(path + ".3")
+	Check(os.IsNotExist(err), true, t)
+	stat, err := os.Stat(path)
+	Check(err, nil, t)
+	Check(stat.Size() <= 300, true, t)
+}
+
+func TestLogChannelLevel(t *testing.T) {
+	sb, dbmock, _ := MockSweetieBot(t)
+	for _, v := range sb.Guilds {
+		v.Config.Log.Level = LogError
+		dbmock.ExpectExec("INSERT INTO debuglog.*").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+		v.Logger().Info("Not important enough for the log channel")
+		v.Logger().Debug("Never posted")
+
+		mock.Expect(v.Bot.DG.RequestWithLockedBucket, "POST", discordgo.EndpointChannelMessages(v.Config.Log.Channel.String()), "application/json", MockAny{}, MockAny{}, 0)
+		dbmock.ExpectExec("INSERT INTO debuglog.*").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+		v.Logger().Error("Posted")
+	}
+}
@@ -0,0 +1,237 @@
+package sweetiebot
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counter is a metric that only ever goes up. The zero value is ready to use from multiple goroutines.
+type Counter struct {
+	v uint64
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.v, 1)
+}
+
+// Get returns the current value of the counter
+func (c *Counter) Get() uint64 {
+	return atomic.LoadUint64(&c.v)
+}
+
+// CounterVec is a set of counters distinguished by the value of a single label, like the name of a command. The zero
+// value is ready to use.
+type CounterVec struct {
+	lock     sync.RWMutex
+	counters map[string]*Counter
+}
+
+// With returns the counter for the given label value, creating it if necessary
+func (c *CounterVec) With(label string) *Counter {
+	c.lock.RLock()
+	counter, ok := c.counters[label]
+	c.lock.RUnlock()
+	if ok {
+		return counter
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.counters == nil {
+		c.counters = make(map[string]*Counter)
+	}
+	if counter, ok = c.counters[label]; !ok {
+		counter = &Counter{}
+		c.counters[label] = counter
+	}
+	return counter
+}
+
+func (c *CounterVec) labels() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	labels := make([]string, 0, len(c.counters))
+	for k := range c.counters {
+		labels = append(labels, k)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets a HistogramVec uses if it isn't given any
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram counts observations in buckets, along with their total, so percentiles and averages can be calculated
+type Histogram struct {
+	lock    sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// Observe records a single value
+func (h *Histogram) Observe(v float64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// HistogramVec is a set of histograms distinguished by the value of a single label. The zero value uses DefaultBuckets.
+type HistogramVec struct {
+	Buckets    []float64
+	lock       sync.RWMutex
+	histograms map[string]*Histogram
+}
+
+// With returns the histogram for the given label value, creating it if necessary
+func (h *HistogramVec) With(label string) *Histogram {
+	h.lock.RLock()
+	histogram, ok := h.histograms[label]
+	h.lock.RUnlock()
+	if ok {
+		return histogram
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.histograms == nil {
+		h.histograms = make(map[string]*Histogram)
+	}
+	if histogram, ok = h.histograms[label]; !ok {
+		buckets := h.Buckets
+		if len(buckets) == 0 {
+			buckets = DefaultBuckets
+		}
+		histogram = &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		h.histograms[label] = histogram
+	}
+	return histogram
+}
+
+func (h *HistogramVec) labels() []string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	labels := make([]string, 0, len(h.histograms))
+	for k := range h.histograms {
+		labels = append(labels, k)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// Metrics are the counters the core and modules update as they work, which are exposed on /metrics. Anything the bot
+// already tracks elsewhere, like the message count or queue lengths, is read when the metrics are requested instead.
+type Metrics struct {
+	Commands        CounterVec   // Commands run, by command name
+	CommandDuration HistogramVec // How long commands took to process, by command name
+	RateLimited     CounterVec   // Commands rejected by a rate limit, by which limit it was
+	SpamSilences    Counter      // Users silenced by the spam module
+	FilterDeletions Counter      // Messages deleted by the filter module
+}
+
+// metricsWriter writes metrics in the Prometheus text exposition format
+type metricsWriter struct {
+	*bufio.Writer
+}
+
+func (w metricsWriter) header(name string, kind string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (w metricsWriter) value(name string, labels string, v interface{}) {
+	if len(labels) > 0 {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s%s %v\n", name, labels, v)
+}
+
+func (w metricsWriter) metric(name string, kind string, help string, v interface{}) {
+	w.header(name, kind, help)
+	w.value(name, "", v)
+}
+
+func (w metricsWriter) counterVec(name string, help string, label string, c *CounterVec) {
+	w.header(name, "counter", help)
+	for _, k := range c.labels() {
+		w.value(name, metricLabel(label, k), c.With(k).Get())
+	}
+}
+
+func (w metricsWriter) histogramVec(name string, help string, label string, h *HistogramVec) {
+	w.header(name, "histogram", help)
+	for _, k := range h.labels() {
+		histogram := h.With(k)
+		l := metricLabel(label, k)
+		histogram.lock.Lock()
+		for i, b := range histogram.buckets {
+			w.value(name+"_bucket", l+","+metricLabel("le", strconv.FormatFloat(b, 'g', -1, 64)), histogram.counts[i])
+		}
+		w.value(name+"_bucket", l+","+metricLabel("le", "+Inf"), histogram.count)
+		w.value(name+"_sum", l, histogram.sum)
+		w.value(name+"_count", l, histogram.count)
+		histogram.lock.Unlock()
+	}
+}
+
+func metricLabel(name string, value string) string {
+	return name + "=" + strconv.Quote(value)
+}
+
+// WriteMetrics writes every metric in the Prometheus text exposition format
+func (sb *SweetieBot) WriteMetrics(w *bufio.Writer) {
+	m := metricsWriter{w}
+	m.metric("sweetiebot_messages_total", "counter", "Messages received from discord.", atomic.LoadUint32(&sb.MessageCount))
+	m.counterVec("sweetiebot_commands_total", "Commands run, by command.", "command", &sb.Metrics.Commands)
+	m.histogramVec("sweetiebot_command_duration_seconds", "Time taken to process a command, by command.", "command", &sb.Metrics.CommandDuration)
+	m.counterVec("sweetiebot_ratelimited_total", "Commands rejected because a rate limit was hit, by limit.", "limit", &sb.Metrics.RateLimited)
+	m.metric("sweetiebot_spam_silences_total", "counter", "Users silenced by the spam module.", sb.Metrics.SpamSilences.Get())
+	m.metric("sweetiebot_filter_deletions_total", "counter", "Messages deleted by the filter module.", sb.Metrics.FilterDeletions.Get())
+	if sb.DB != nil {
+		m.metric("sweetiebot_db_errors_total", "counter", "Unexpected database errors.", sb.DB.errorCount.Get())
+		m.counterVec("sweetiebot_db_reconnects_total", "Attempts to reconnect to the database, by result.", "result", &sb.DB.reconnects)
+		up := 0
+		if sb.DB.Status.Get() {
+			up = 1
+		}
+		m.metric("sweetiebot_db_up", "gauge", "1 if the database is connected, 0 if the bot is in No Database mode.", up)
+	}
+	m.header("sweetiebot_queue_depth", "gauge", "Items waiting in an internal queue, by queue.")
+	m.value("sweetiebot_queue_depth", metricLabel("queue", "defer"), len(sb.deferChan))
+	m.value("sweetiebot_queue_depth", metricLabel("queue", "member"), len(sb.memberChan))
+	m.header("sweetiebot_missed_heartbeats", "gauge", "Deadlock detector heartbeats missed in a row, by shard.")
+	for i := range sb.Shards {
+		_, missed := sb.ShardStatus(i)
+		m.value("sweetiebot_missed_heartbeats", metricLabel("shard", strconv.Itoa(i)), missed)
+	}
+	sb.GuildsLock.RLock()
+	guilds := len(sb.Guilds)
+	sb.GuildsLock.RUnlock()
+	m.metric("sweetiebot_guilds", "gauge", "Servers the bot is attached to.", guilds)
+	m.metric("sweetiebot_uptime_seconds", "gauge", "Seconds since the bot started.", time.Now().UTC().Unix()-sb.StartTime)
+}
+
+// metricsHandler serves /metrics. If metricstoken is set in selfhost.json, the request must include it as a bearer
+// token. Otherwise the metrics are open to anyone who can reach the web server.
+func (sb *SweetieBot) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if len(sb.MetricsToken) > 0 && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+sb.MetricsToken)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	b := bufio.NewWriter(w)
+	sb.WriteMetrics(b)
+	b.Flush()
+}
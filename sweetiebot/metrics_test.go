@@ -0,0 +1,71 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	var h HistogramVec
+	h.Buckets = []float64{0.1, 1}
+	h.With("ping").Observe(0.05)
+	h.With("ping").Observe(0.5)
+	h.With("ping").Observe(5)
+	Check(h.With("ping").counts[0], uint64(1), t)
+	Check(h.With("ping").counts[1], uint64(2), t)
+	Check(h.With("ping").count, uint64(3), t)
+
+	var c CounterVec
+	c.With("b").Inc()
+	c.With("a").Inc()
+	c.With("b").Inc()
+	Check(strings.Join(c.labels(), ","), "a,b", t)
+	Check(c.With("b").Get(), uint64(2), t)
+}
+
+func TestMetricsHandler(t *testing.T) {
+	sb, _, _ := MockSweetieBot(t)
+	sb.Metrics.Commands.With("ping").Inc()
+	sb.Metrics.CommandDuration.With("ping").Observe(0.02)
+	sb.Metrics.RateLimited.With("global").Inc()
+	sb.Metrics.SpamSilences.Inc()
+	sb.DB.reconnects.With("failure").Inc()
+
+	w := httptest.NewRecorder()
+	sb.metricsHandler(w, httptest.NewRequest("GET", "/metrics", nil))
+	Check(w.Code, http.StatusOK, t)
+	body := w.Body.String()
+	for _, s := range []string{
+		"# TYPE sweetiebot_commands_total counter\n",
+		"sweetiebot_commands_total{command=\"ping\"} 1\n",
+		"sweetiebot_command_duration_seconds_bucket{command=\"ping\",le=\"0.01\"} 0\n",
+		"sweetiebot_command_duration_seconds_bucket{command=\"ping\",le=\"0.025\"} 1\n",
+		"sweetiebot_command_duration_seconds_bucket{command=\"ping\",le=\"+Inf\"} 1\n",
+		"sweetiebot_command_duration_seconds_count{command=\"ping\"} 1\n",
+		"sweetiebot_ratelimited_total{limit=\"global\"} 1\n",
+		"sweetiebot_spam_silences_total 1\n",
+		"sweetiebot_filter_deletions_total 0\n",
+		"sweetiebot_db_reconnects_total{result=\"failure\"} 1\n",
+		"sweetiebot_queue_depth{queue=\"member\"} 0\n",
+		fmt.Sprintf("sweetiebot_guilds %v\n", NumServers),
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("metrics output is missing %q", s)
+		}
+	}
+
+	sb.MetricsToken = "secret"
+	w = httptest.NewRecorder()
+	sb.metricsHandler(w, httptest.NewRequest("GET", "/metrics", nil))
+	Check(w.Code, http.StatusUnauthorized, t)
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	sb.metricsHandler(w, r)
+	Check(w.Code, http.StatusOK, t)
+}
@@ -0,0 +1,995 @@
+package sweetiebot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigDocument is the raw JSON object of a guild's config file. Migrations work on the document instead of a
+// BotConfig so they can still read options that have since been removed or renamed.
+type ConfigDocument map[string]interface{}
+
+// Migration upgrades a config file from the previous config version to Version. When dryrun is true, Migrate must
+// only change the document, without touching discord or the database.
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error
+}
+
+// Migrations lists every config migration in the order they are applied. Each migration upgrades a config file from
+// the version before it, so new migrations must always be added to the end with ConfigVersion set to their version.
+var Migrations = []Migration{
+	{
+		Version:     10,
+		Description: "Group the original flat config options into categories",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				legacy := legacyBotConfig{}
+				err := doc.Decode(&legacy)
+				if err != nil {
+					return err
+				}
+
+				if legacy.Version == 0 {
+					if len(legacy.Command_roles) == 0 {
+						legacy.Command_roles = make(map[string]map[string]bool)
+					}
+					legacy.MaxImageSpam = 3
+					legacy.MaxAttachSpam = 1
+					legacy.MaxPingSpam = 24
+					legacy.MaxMessageSpam = make(map[int64]int)
+					legacy.MaxMessageSpam[1] = 4
+					legacy.MaxMessageSpam[9] = 10
+					legacy.MaxMessageSpam[12] = 15
+				}
+
+				if legacy.Version <= 1 {
+					if len(legacy.Aliases) == 0 {
+						legacy.Aliases = make(map[string]string)
+					}
+					legacy.Aliases["cute"] = "pick cute"
+				}
+
+				if legacy.Version <= 3 {
+					legacy.BoredCommands = make(map[string]bool)
+				}
+
+				if legacy.Version <= 5 {
+					legacy.TimezoneLocation = "Etc/GMT"
+					if legacy.Timezone < 0 {
+						legacy.TimezoneLocation += "+"
+					}
+					legacy.TimezoneLocation += strconv.Itoa(-legacy.Timezone) // Etc has the sign reversed
+				}
+
+				c.Basic.ModRole = NewDiscordRole(legacy.AlertRole)
+				c.Basic.Aliases = legacy.Aliases
+				c.Filter.Filters = legacy.Collections
+				c.Basic.FreeChannels = make(map[DiscordChannel]bool)
+				for k, v := range legacy.FreeChannels {
+					if ch, err := ParseChannel(k, nil); err == nil {
+						c.Basic.FreeChannels[ch] = v
+					}
+				}
+				c.Basic.IgnoreInvalidCommands = legacy.IgnoreInvalidCommands
+				c.Basic.Importable = legacy.Importable
+				c.Basic.ModChannel = NewDiscordChannel(legacy.ModChannel)
+				c.Basic.SilenceRole = NewDiscordRole(legacy.SilentRole)
+				c.Modules.CommandChannels = make(map[CommandID]map[DiscordChannel]bool)
+				for key := range legacy.Command_channels {
+					c.Modules.CommandChannels[CommandID(key)] = make(map[DiscordChannel]bool)
+					for k, v := range legacy.Command_channels[key] {
+						if ch, err := ParseChannel(k, nil); err == nil {
+							c.Modules.CommandChannels[CommandID(key)][ch] = v
+						}
+					}
+				}
+				c.Modules.CommandDisabled = make(map[CommandID]bool)
+				for key := range legacy.Command_disabled {
+					c.Modules.CommandDisabled[CommandID(key)] = true
+				}
+				c.Modules.CommandLimits = make(map[CommandID]int64)
+				for key, v := range legacy.Command_limits {
+					c.Modules.CommandLimits[CommandID(key)] = v
+				}
+				c.Modules.CommandRoles = make(map[CommandID]map[DiscordRole]bool)
+				for key := range legacy.Command_roles {
+					c.Modules.CommandRoles[CommandID(key)] = make(map[DiscordRole]bool)
+					for k, v := range legacy.Command_roles[key] {
+						if r, err := ParseRole(k, nil); err == nil {
+							c.Modules.CommandRoles[CommandID(key)][r] = v
+						}
+					}
+				}
+
+				c.Modules.CommandMaxDuration = legacy.Commandmaxduration
+				c.Modules.CommandPerDuration = legacy.Commandperduration
+				c.Modules.Channels = make(map[ModuleID]map[DiscordChannel]bool)
+				for key := range legacy.Module_channels {
+					c.Modules.Channels[ModuleID(key)] = make(map[DiscordChannel]bool)
+					for k, v := range legacy.Module_channels[key] {
+						if ch, err := ParseChannel(k, nil); err == nil {
+							c.Modules.Channels[ModuleID(key)][ch] = v
+						}
+					}
+				}
+				c.Modules.Disabled = make(map[ModuleID]bool)
+				for key := range legacy.Module_disabled {
+					c.Modules.Disabled[ModuleID(key)] = true
+				}
+				c.Spam.AutoSilence = legacy.AutoSilence
+				//c.Spam.MaxAttach = legacy.MaxAttachSpam
+				//c.Spam.MaxImages = legacy.MaxImageSpam
+				//c.Spam.MaxMessages = legacy.MaxMessageSpam
+				//c.Spam.MaxPings = legacy.MaxPingSpam
+				c.Spam.RaidTime = legacy.MaxRaidTime
+				c.Spam.MaxRemoveLookback = legacy.MaxSpamRemoveLookback
+				c.Spam.RaidSize = legacy.RaidSize
+				c.Bucket.MaxItems = legacy.MaxBucket
+				c.Bucket.MaxItemLength = legacy.MaxBucketLength
+				c.Bucket.MaxFightDamage = legacy.MaxFightDamage
+				c.Bucket.MaxFightHP = legacy.MaxFightHP
+				c.Markov.DefaultLines = legacy.Defaultmarkovlines
+				c.Markov.MaxPMlines = legacy.MaxPMlines
+				c.Markov.MaxLines = legacy.Maxquotelines
+				c.Markov.UseMemberNames = legacy.UseMemberNames
+				c.Users.TimezoneLocation = legacy.TimezoneLocation
+				c.Users.WelcomeChannel = NewDiscordChannel(legacy.WelcomeChannel)
+				c.Users.WelcomeMessage = legacy.WelcomeMessage
+				c.Users.SilenceMessage = legacy.SilenceMessage
+				c.Bored.Commands = legacy.BoredCommands
+				c.Bored.Cooldown = legacy.Maxbored
+				c.Information.HideNegativeRules = legacy.HideNegativeRules
+				c.Information.Rules = legacy.Rules
+				c.Log.Channel = NewDiscordChannel(legacy.LogChannel)
+				c.Log.Cooldown = legacy.Maxerror
+				c.Witty.Cooldown = legacy.Maxwit
+				c.Witty.Responses = legacy.Witty
+				c.Scheduler.BirthdayRole = NewDiscordRole(legacy.BirthdayRole)
+				c.Miscellaneous.MaxSearchResults = legacy.Maxsearchresults
+				c.Filter.Channels = make(map[string]map[DiscordChannel]bool)
+				c.Filter.Channels["spoiler"] = make(map[DiscordChannel]bool)
+				for _, v := range legacy.SpoilChannels {
+					c.Filter.Channels["spoiler"][NewDiscordChannel(v)] = true
+				}
+				c.Status.Cooldown = legacy.StatusDelayTime
+				c.Quote.Quotes = make(map[DiscordUser][]string)
+				for k, v := range legacy.Quotes {
+					c.Quote.Quotes[NewDiscordUser(k)] = v
+				}
+
+				newcommands := []string{"addevent", "addbirthday", "autosilence", "silence", "unsilence", "wipewelcome", "new", "addquote", "removequote", "removealias", "delete", "createpoll", "deletepoll", "addoption"}
+				for _, v := range newcommands {
+					restrictCommand(v, c.Modules.CommandRoles, c.Basic.ModRole)
+				}
+				return nil
+			})
+		},
+	},
+	{
+		Version:     11,
+		Description: "Move the command rate limits from basic to modules",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				legacy := legacyBotConfigV10{}
+				err := doc.Decode(&legacy)
+				if err == nil {
+					if legacy.Basic.Commandmaxduration != nil { // Only config files saved by version 10 have these
+						c.Modules.CommandMaxDuration = *legacy.Basic.Commandmaxduration
+					}
+					if legacy.Basic.Commandperduration != nil {
+						c.Modules.CommandPerDuration = *legacy.Basic.Commandperduration
+					}
+				} else {
+					guild.Logger().Error("Migration error:", err)
+				}
+				return nil
+			})
+		},
+	},
+	{
+		Version:     12,
+		Description: "Restrict !getaudit to moderators",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				restrictCommand("getaudit", c.Modules.CommandRoles, c.Basic.ModRole)
+				return nil
+			})
+		},
+	},
+	{
+		Version:     13,
+		Description: "Replace the spam limits with the pressure system",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				c.Spam.BasePressure = 10.0
+				c.Spam.MaxPressure = 60.0
+				c.Spam.ImagePressure = ((c.Spam.MaxPressure - c.Spam.BasePressure) / 6.0)
+				c.Spam.PingPressure = ((c.Spam.MaxPressure - c.Spam.BasePressure) / 24.0)
+				c.Spam.LengthPressure = ((c.Spam.MaxPressure - c.Spam.BasePressure) / (2000.0 * 4))
+				c.Spam.RepeatPressure = c.Spam.BasePressure
+				c.Spam.PressureDecay = 2.5
+
+				legacy := legacyBotConfigV12{}
+				err := doc.Decode(&legacy)
+				if err == nil {
+					if legacy.Spam.MaxImages > 0 {
+						c.Spam.ImagePressure = ((c.Spam.MaxPressure - c.Spam.BasePressure) / float32(legacy.Spam.MaxImages+1))
+					} else {
+						c.Spam.ImagePressure = 0
+					}
+					if legacy.Spam.MaxPings > 0 {
+						c.Spam.PingPressure = ((c.Spam.MaxPressure - c.Spam.BasePressure) / float32(legacy.Spam.MaxPings+1))
+					} else {
+						c.Spam.PingPressure = 0
+					}
+				} else {
+					guild.Logger().Error("Migration error:", err)
+				}
+				return nil
+			})
+		},
+	},
+	{
+		Version:     14,
+		Description: "Replace groups with user-assignable roles, which creates a role for each group and updates group pings in the schedule",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				legacy := legacyBotConfigV13{}
+				err := doc.Decode(&legacy)
+				if err == nil {
+					c.Users.Roles = make(map[DiscordRole]bool, len(legacy.Basic.Groups))
+					idmap := make(map[string]string, len(legacy.Basic.Groups)) // Map initial group name to new role ID
+
+					if dryrun {
+						return nil // Creating the roles is the only way to find out what their IDs will be
+					}
+					for k, v := range legacy.Basic.Groups {
+						role := k
+						check, err := GetRoleByName(role, guild)
+						if check != nil {
+							role = "sb-" + role
+						}
+						r, err := guild.DG.GuildRoleCreate(guild.ID)
+						if err == nil {
+							r, err = guild.DG.GuildRoleEdit(guild.ID, r.ID, role, 0, false, 0, true)
+						}
+						if err == nil {
+							idmap[strings.ToLower(k)] = r.ID
+							if id, err := ParseRole(r.ID, nil); err == nil {
+								c.Users.Roles[id] = true
+							}
+
+							for u := range v {
+								err = guild.DG.GuildMemberRoleAdd(guild.ID, u, r.ID)
+								if err != nil {
+									guild.Logger().Error("Migration error:", err)
+								}
+							}
+						} else {
+							guild.Logger().Error("Migration error:", err)
+						}
+					}
+
+					stmt, err := guild.Bot.DB.Prepare("SELECT ID, Data FROM schedule WHERE Guild = ? AND Type = 7")
+					stmt2, err := guild.Bot.DB.Prepare("UPDATE schedule SET Data = ? WHERE ID = ?")
+					if err != nil {
+						guild.Logger().Error("Migration error:", err)
+					} else {
+						q, err := stmt.Query(SBatoi(guild.ID))
+						if err != nil {
+							guild.Logger().Error("Migration error:", err)
+						} else {
+							defer q.Close()
+							for q.Next() {
+								var id uint64
+								var dat string
+								if err := q.Scan(&id, &dat); err == nil {
+									datas := strings.SplitN(dat, "|", 2)
+									groups := strings.Split(datas[0], "+")
+									for i := range groups {
+										rid, ok := idmap[strings.ToLower(groups[i])]
+										if ok {
+											groups[i] = "<@&" + rid + ">"
+										}
+									}
+									_, err = stmt2.Exec(strings.Join(groups, " ")+"|"+datas[1], id)
+									if err != nil {
+										guild.Logger().Error("Migration error:", err)
+									}
+								}
+							}
+						}
+					}
+				} else {
+					guild.Logger().Error("Migration error:", err)
+				}
+				return nil
+			})
+		},
+	},
+	{
+		Version:     15,
+		Description: "Restrict the role commands to moderators",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				restrictCommand("addrole", c.Modules.CommandRoles, c.Basic.ModRole)
+				restrictCommand("removerole", c.Modules.CommandRoles, c.Basic.ModRole)
+				restrictCommand("deleterole", c.Modules.CommandRoles, c.Basic.ModRole)
+				return nil
+			})
+		},
+	},
+	{
+		Version:     16,
+		Description: "Restrict !bannewcomers to moderators and enable lockdown",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				restrictCommand("bannewcomers", c.Modules.CommandRoles, c.Basic.ModRole)
+				c.Spam.LockdownDuration = 120
+				return nil
+			})
+		},
+	},
+	{
+		Version:     17,
+		Description: "Add the command prefix",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				c.Basic.CommandPrefix = "!"
+				return nil
+			})
+		},
+	},
+	{
+		Version:     18,
+		Description: "Mark existing servers as set up",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				c.SetupDone = true
+				return nil
+			})
+		},
+	},
+	{
+		Version:     19,
+		Description: "Restrict the raid commands to moderators and add line pressure",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				restrictCommand("banraid", c.Modules.CommandRoles, c.Basic.ModRole)
+				restrictCommand("getraid", c.Modules.CommandRoles, c.Basic.ModRole)
+				restrictCommand("wipe", c.Modules.CommandRoles, c.Basic.ModRole)
+				restrictCommand("bannewcomers", c.Modules.CommandRoles, c.Basic.ModRole)
+				restrictCommand("getpressure", c.Modules.CommandRoles, c.Basic.ModRole)
+				c.Spam.LinePressure = (c.Spam.MaxPressure - c.Spam.BasePressure) / 70.0
+				return nil
+			})
+		},
+	},
+	{
+		Version:     20,
+		Description: "Move the bucket, status, emote and spoiler collections into config options and import all other collections as tags",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				if len(c.Filter.Filters) == 0 {
+					c.Filter.Filters = make(map[string]map[string]bool)
+				}
+				legacy := legacyBotConfigV19{}
+				err := doc.Decode(&legacy)
+				if err == nil {
+					c.Bucket.Items = legacy.Basic.Collections["bucket"]
+					c.Filter.Filters["emote"] = legacy.Basic.Collections["emote"]
+					c.Status.Lines = legacy.Basic.Collections["status"]
+					c.Filter.Filters["spoiler"] = legacy.Basic.Collections["spoiler"]
+					delete(legacy.Basic.Collections, "bucket")
+					delete(legacy.Basic.Collections, "emote")
+					delete(legacy.Basic.Collections, "status")
+					delete(legacy.Basic.Collections, "spoiler")
+
+					if !dryrun {
+						guild.Bot.GuildsLock.Lock()
+						gID := SBatoi(guild.ID)
+						for k, v := range legacy.Basic.Collections {
+							if len(v) > 0 {
+								guild.Logger().Info("Importing:", k)
+								guild.Bot.DB.CreateTag(k, gID)
+								tag, err := guild.Bot.DB.GetTag(k, gID)
+								if err == nil {
+									for item := range v {
+										id, err := guild.Bot.DB.AddItem(item)
+										if err == nil || err != ErrDuplicateEntry {
+											guild.Bot.DB.AddTag(id, tag)
+										}
+									}
+								}
+							} else {
+								guild.Logger().Info("Skipping empty collection:", k)
+							}
+						}
+						guild.Bot.GuildsLock.Unlock()
+					}
+				} else {
+					guild.Logger().Error("Migration error:", err)
+				}
+				restrictCommand("addset", c.Modules.CommandRoles, c.Basic.ModRole)
+				restrictCommand("removeset", c.Modules.CommandRoles, c.Basic.ModRole)
+				restrictCommand("searchset", c.Modules.CommandRoles, c.Basic.ModRole)
+				return nil
+			})
+		},
+	},
+	{
+		Version:     21,
+		Description: "Split the filters, bucket and status lines out of the collections and rename the old module names",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				legacy := legacyBotConfigV20{}
+				err := doc.Decode(&legacy)
+				if err == nil {
+					c.Basic.ModRole = legacy.Basic.AlertRole
+					c.Miscellaneous.MaxSearchResults = legacy.Search.MaxResults
+					c.Scheduler.BirthdayRole = legacy.Schedule.BirthdayRole
+					c.Filter.Filters = make(map[string]map[string]bool)
+					c.Filter.Channels = make(map[string]map[DiscordChannel]bool)
+					c.Filter.Responses = make(map[string]string)
+					c.Filter.Templates = make(map[string]string)
+					c.Bucket.Items = make(map[string]bool)
+					c.Status.Lines = make(map[string]bool)
+					c.Users.TrackUserLeft = legacy.Basic.TrackUserLeft
+					c.Users.SilenceMessage = legacy.Spam.SilenceMessage
+					c.Basic.SilenceRole = legacy.Spam.SilentRole
+
+					if bucket, ok := legacy.Collections["bucket"]; ok {
+						for k, v := range bucket {
+							c.Bucket.Items[k] = v
+						}
+					}
+
+					if status, ok := legacy.Collections["status"]; ok {
+						for k, v := range status {
+							c.Status.Lines[k] = v
+						}
+					}
+
+					if c.Spam.AutoSilence == -2 {
+						c.Users.NotifyChannel = c.Log.Channel
+					} else if c.Spam.AutoSilence != 0 {
+						c.Users.NotifyChannel = c.Basic.ModChannel
+					}
+					if c.Spam.AutoSilence < 0 {
+						c.Spam.AutoSilence = 0
+					}
+
+					if spoilers, ok := legacy.Collections["spoiler"]; (ok && len(spoilers) > 0) || len(legacy.Spoiler.Channels) > 0 {
+						c.Filter.Filters["spoiler"] = make(map[string]bool)
+						if ok {
+							for k, v := range spoilers {
+								c.Filter.Filters["spoiler"][k] = v
+							}
+						}
+						c.Filter.Channels["spoiler"] = make(map[DiscordChannel]bool)
+						for _, v := range legacy.Spoiler.Channels {
+							c.Filter.Channels["spoiler"][v] = true
+						}
+						c.Filter.Responses["spoiler"] = "[](/nospoilers) ```\nNO SPOILERS! Posting spoilers is a bannable offense. All discussion about new and future content MUST be in #mylittlespoilers.```"
+					}
+
+					if emotes, ok := legacy.Collections["emote"]; ok && len(emotes) > 0 {
+						c.Filter.Filters["emote"] = make(map[string]bool)
+						for k, v := range emotes {
+							c.Filter.Filters["emote"][k] = v
+						}
+						c.Filter.Channels["emote"] = make(map[DiscordChannel]bool)
+						c.Filter.Responses["emote"] = "```\nThat emote isn't allowed here! Try to avoid using large or disturbing emotes, as they can be problematic.```"
+						c.Filter.Templates["emote"] = "\\[\\]\\(\\/r?%%[-) \"]"
+					}
+				}
+
+				if c.Basic.ModRole == "0" {
+					c.Basic.ModRole = ""
+				}
+				if c.Basic.ModChannel == "0" {
+					c.Basic.ModChannel = ""
+				}
+				if c.Basic.SilenceRole == "0" {
+					c.Basic.SilenceRole = ""
+				}
+				if c.Spam.IgnoreRole == "0" {
+					c.Spam.IgnoreRole = ""
+				}
+				if c.Users.WelcomeChannel == "0" {
+					c.Users.WelcomeChannel = ""
+				}
+				if c.Users.NotifyChannel == "0" {
+					c.Users.NotifyChannel = ""
+				}
+				if c.Log.Channel == "0" {
+					c.Log.Channel = ""
+				}
+				if c.Scheduler.BirthdayRole == "0" {
+					c.Scheduler.BirthdayRole = ""
+				}
+
+				for k := range c.Modules.Channels {
+					switch k {
+					case "schedule":
+						c.Modules.Channels["scheduler"] = c.Modules.Channels[k]
+						delete(c.Modules.Channels, k)
+					case "anti-spam":
+						c.Modules.Channels["spam"] = c.Modules.Channels[k]
+						delete(c.Modules.Channels, k)
+					case "help/about":
+						c.Modules.Channels["information"] = c.Modules.Channels[k]
+						delete(c.Modules.Channels, k)
+					}
+				}
+
+				for k := range c.Modules.Disabled {
+					switch k {
+					case "schedule":
+						c.Modules.Channels["scheduler"] = c.Modules.Channels[k]
+						delete(c.Modules.Channels, k)
+					case "anti-spam":
+						c.Modules.Channels["spam"] = c.Modules.Channels[k]
+						delete(c.Modules.Channels, k)
+					case "help/about":
+						c.Modules.Channels["information"] = c.Modules.Channels[k]
+						delete(c.Modules.Channels, k)
+					}
+				}
+				return nil
+			})
+		},
+	},
+	{
+		Version:     22,
+		Description: "Restrict !assignrole to moderators",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				restrictCommand("assignrole", c.Modules.CommandRoles, c.Basic.ModRole)
+				return nil
+			})
+		},
+	},
+	{
+		Version:     23,
+		Description: "Restrict the filter and status commands to moderators",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				restrictCommand("setfilter", c.Modules.CommandRoles, c.Basic.ModRole)
+				restrictCommand("addfilter", c.Modules.CommandRoles, c.Basic.ModRole)
+				restrictCommand("removefilter", c.Modules.CommandRoles, c.Basic.ModRole)
+				restrictCommand("searchfilter", c.Modules.CommandRoles, c.Basic.ModRole)
+				restrictCommand("addstatus", c.Modules.CommandRoles, c.Basic.ModRole)
+				restrictCommand("removestatus", c.Modules.CommandRoles, c.Basic.ModRole)
+				restrictCommand("setstatus", c.Modules.CommandRoles, c.Basic.ModRole)
+				return nil
+			})
+		},
+	},
+	{
+		Version:     24,
+		Description: "Restrict !createrole to moderators",
+		Migrate: func(guild *GuildInfo, doc ConfigDocument, dryrun bool) error {
+			return doc.Update(func(c *BotConfig) error {
+				restrictCommand("createrole", c.Modules.CommandRoles, c.Basic.ModRole)
+				return nil
+			})
+		},
+	},
+}
+
+type legacyBotConfig struct {
+	Version               int                        `json:"version"`
+	LastVersion           int                        `json:"lastversion"`
+	Maxerror              int64                      `json:"maxerror"`
+	Maxwit                int64                      `json:"maxwit"`
+	Maxbored              int64                      `json:"maxbored"`
+	BoredCommands         map[string]bool            `json:"boredcommands"`
+	MaxPMlines            int                        `json:"maxpmlines"`
+	Maxquotelines         int                        `json:"maxquotelines"`
+	Maxsearchresults      int                        `json:"maxsearchresults"`
+	Defaultmarkovlines    int                        `json:"defaultmarkovlines"`
+	Commandperduration    int                        `json:"commandperduration"`
+	Commandmaxduration    int64                      `json:"commandmaxduration"`
+	StatusDelayTime       int                        `json:"statusdelaytime"`
+	MaxRaidTime           int64                      `json:"maxraidtime"`
+	RaidSize              int                        `json:"raidsize"`
+	Witty                 map[string]string          `json:"witty"`
+	Aliases               map[string]string          `json:"aliases"`
+	MaxBucket             int                        `json:"maxbucket"`
+	MaxBucketLength       int                        `json:"maxbucketlength"`
+	MaxFightHP            int                        `json:"maxfighthp"`
+	MaxFightDamage        int                        `json:"maxfightdamage"`
+	MaxImageSpam          int                        `json:"maximagespam"`
+	MaxAttachSpam         int                        `json:"maxattachspam"`
+	MaxPingSpam           int                        `json:"maxpingspam"`
+	MaxMessageSpam        map[int64]int              `json:"maxmessagespam"`
+	MaxSpamRemoveLookback int                        `json:maxspamremovelookback`
+	IgnoreInvalidCommands bool                       `json:"ignoreinvalidcommands"`
+	UseMemberNames        bool                       `json:"usemembernames"`
+	Importable            bool                       `json:"importable"`
+	HideNegativeRules     bool                       `json:"hidenegativerules"`
+	Timezone              int                        `json:"timezone"`
+	TimezoneLocation      string                     `json:"timezonelocation"`
+	AutoSilence           int                        `json:"autosilence"`
+	AlertRole             uint64                     `json:"alertrole"`
+	SilentRole            uint64                     `json:"silentrole"`
+	LogChannel            uint64                     `json:"logchannel"`
+	ModChannel            uint64                     `json:"modchannel"`
+	WelcomeChannel        uint64                     `json:"welcomechannel"`
+	WelcomeMessage        string                     `json:"welcomemessage"`
+	SilenceMessage        string                     `json:"silencemessage"`
+	BirthdayRole          uint64                     `json:"birthdayrole"`
+	SpoilChannels         []uint64                   `json:"spoilchannels"`
+	FreeChannels          map[string]bool            `json:"freechannels"`
+	Command_roles         map[string]map[string]bool `json:"command_roles"`
+	Command_channels      map[string]map[string]bool `json:"command_channels"`
+	Command_limits        map[string]int64           `json:command_limits`
+	Command_disabled      map[string]bool            `json:command_disabled`
+	Module_disabled       map[string]bool            `json:module_disabled`
+	Module_channels       map[string]map[string]bool `json:module_channels`
+	Collections           map[string]map[string]bool `json:"collections"`
+	Groups                map[string]map[string]bool `json:"groups"`
+	Quotes                map[uint64][]string        `json:"quotes"`
+	Rules                 map[int]string             `json:"rules"`
+}
+
+type legacyBotConfigV10 struct {
+	Basic struct {
+		Commandperduration *int   `json:"commandperduration"`
+		Commandmaxduration *int64 `json:"commandmaxduration"`
+	} `json:"basic"`
+}
+
+type legacyBotConfigV12 struct {
+	Spam struct {
+		MaxImages int `json:"maximagespam"`
+		MaxPings  int `json:"maxpingspam"`
+	} `json:"spam"`
+}
+
+type legacyBotConfigV13 struct {
+	Basic struct {
+		Groups map[string]map[string]bool `json:"groups"`
+	} `json:"basic"`
+}
+
+type legacyBotConfigV19 struct {
+	Basic struct {
+		Collections map[string]map[string]bool `json:"collections"`
+	} `json:"basic"`
+}
+
+type legacyBotConfigV20 struct {
+	Collections map[string]map[string]bool `json:"collections"`
+	Spam        struct {
+		SilentRole     DiscordRole `json:"silentrole"`
+		SilenceMessage string      `json:"silencemessage"`
+	} `json:"spam"`
+	Basic struct {
+		AlertRole     DiscordRole `json:"alertrole"`
+		TrackUserLeft bool        `json:"trackuserleft"`
+	} `json:"basic"`
+	Search struct {
+		MaxResults int `json:"maxsearchresults"`
+	} `json:"search"`
+	Spoiler struct {
+		Channels []DiscordChannel `json:"spoilchannels"`
+	} `json:"spoiler"`
+	Schedule struct {
+		BirthdayRole DiscordRole `json:"birthdayrole"`
+	} `json:"schedule"`
+}
+
+func restrictCommand(v string, roles map[CommandID]map[DiscordRole]bool, modrole DiscordRole) {
+	id := CommandID(v)
+	_, ok := roles[id]
+	if !ok && modrole != "" {
+		roles[id] = make(map[DiscordRole]bool)
+		roles[id][modrole] = true
+	}
+}
+
+// ParseConfigDocument parses a config file, keeping numbers exact so legacy snowflake IDs aren't rounded
+func ParseConfigDocument(data []byte) (ConfigDocument, error) {
+	doc := ConfigDocument{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// key returns the key in the document matching name, ignoring case just like encoding/json does
+func (doc ConfigDocument) key(name string) string {
+	if _, ok := doc[name]; ok {
+		return name
+	}
+	for k := range doc {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return name
+}
+
+// Version returns the config version the document was saved with, or 0 if it doesn't have one
+func (doc ConfigDocument) Version() int {
+	switch v := doc[doc.key("version")].(type) {
+	case json.Number:
+		i, _ := v.Int64()
+		return int(i)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// Decode unmarshals the document into v, which is usually a legacy config struct
+func (doc ConfigDocument) Decode(v interface{}) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Update decodes the document into a BotConfig, lets fn modify it, then writes every config option back into the
+// document. Options that BotConfig no longer has are left alone, so later migrations can still read them.
+func (doc ConfigDocument) Update(fn func(c *BotConfig) error) error {
+	c := DefaultConfig()
+	if err := doc.Decode(c); err != nil {
+		return err
+	}
+	if err := fn(c); err != nil {
+		return err
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	update, err := ParseConfigDocument(data)
+	if err != nil {
+		return err
+	}
+	for k, v := range update {
+		old := doc[doc.key(k)]
+		delete(doc, doc.key(k))
+		if prev, ok := old.(map[string]interface{}); ok {
+			if category, ok := v.(map[string]interface{}); ok {
+				for option, value := range category {
+					delete(prev, ConfigDocument(prev).key(option))
+					prev[option] = value
+				}
+				v = prev
+			}
+		}
+		doc[k] = v
+	}
+	return nil
+}
+
+// Migrate runs every migration newer than the document's version in order, and returns the ones that were applied
+func (doc ConfigDocument) Migrate(guild *GuildInfo, dryrun bool) ([]Migration, error) {
+	applied := []Migration{}
+	version := doc.Version()
+	for _, m := range Migrations {
+		if m.Version <= version {
+			continue
+		}
+		if err := m.Migrate(guild, doc, dryrun); err != nil {
+			return applied, fmt.Errorf("Migration to config version %v failed: %s", m.Version, err.Error())
+		}
+		delete(doc, doc.key("version"))
+		doc["version"] = json.Number(strconv.Itoa(m.Version))
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+func configBackupPath(guildID string, version int) string {
+	return fmt.Sprintf("%s.json.v%v.bak", guildID, version)
+}
+
+// MigrateSettings loads a guild's config file, migrating it to the current config version first if necessary. The
+// original file is backed up before any migration runs, so it can be restored with RollbackConfig.
+func (guild *GuildInfo) MigrateSettings(config []byte) error {
+	doc, err := ParseConfigDocument(config)
+	if err != nil {
+		return err
+	}
+	version := doc.Version()
+	if version < ConfigVersion {
+		if err = ioutil.WriteFile(configBackupPath(guild.ID, version), config, 0664); err != nil {
+			json.Unmarshal(config, &guild.Config) // Keep running on the old config instead of doing a migration we can't undo
+			return fmt.Errorf("Couldn't back up the config file, so it wasn't migrated: %s", err.Error())
+		}
+		if _, err = doc.Migrate(guild, false); err != nil {
+			json.Unmarshal(config, &guild.Config)
+			return err
+		}
+		if config, err = json.Marshal(doc); err != nil {
+			return err
+		}
+	}
+
+	if err = json.Unmarshal(config, &guild.Config); err != nil {
+		return err
+	}
+	if version != ConfigVersion {
+		guild.Config.Version = ConfigVersion // set version to most recent config version
+		guild.SaveConfig()
+	}
+	return nil
+}
+
+// DryRunMigration runs any pending migrations over a guild's config file without saving anything or touching discord
+// or the database. It returns the migrations that would run, along with a diff of the config file they would produce.
+func DryRunMigration(guildID string) ([]Migration, string, error) {
+	config, err := ioutil.ReadFile(guildID + ".json")
+	if err != nil {
+		return nil, "", err
+	}
+	doc, err := ParseConfigDocument(config)
+	if err != nil {
+		return nil, "", err
+	}
+	before, _ := ParseConfigDocument(config)
+	applied, err := doc.Migrate(&GuildInfo{ID: guildID, Config: *DefaultConfig()}, true)
+	if err != nil {
+		return applied, "", err
+	}
+
+	migrated := DefaultConfig()
+	if err = doc.Decode(migrated); err != nil {
+		return applied, "", err
+	}
+	migrated.Version = ConfigVersion
+	data, err := json.Marshal(migrated)
+	if err != nil {
+		return applied, "", err
+	}
+	after, err := ParseConfigDocument(data)
+	if err != nil {
+		return applied, "", err
+	}
+	return applied, diffConfig(before, after), nil
+}
+
+// ConfigBackups returns the versions of all the pre-migration backups of a guild's config file, newest first
+func ConfigBackups(guildID string) []int {
+	files, _ := filepath.Glob(guildID + ".json.v*.bak")
+	versions := make([]int, 0, len(files))
+	for _, f := range files {
+		if v, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(f, guildID+".json.v"), ".bak")); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	return versions
+}
+
+// RollbackConfig restores a guild's config file from the backup taken before it was migrated from the given version,
+// or from the newest backup if version is negative. Returns the config version that was restored.
+func RollbackConfig(guildID string, version int) (int, error) {
+	backups := ConfigBackups(guildID)
+	if len(backups) == 0 {
+		return 0, errors.New("There are no config backups for " + guildID)
+	}
+	if version < 0 {
+		version = backups[0]
+	}
+	data, err := ioutil.ReadFile(configBackupPath(guildID, version))
+	if err != nil {
+		return version, fmt.Errorf("There is no backup of %s from config version %v", guildID, version)
+	}
+	return version, ioutil.WriteFile(guildID+".json", data, 0664)
+}
+
+// diffConfig compares two config documents one top-level option at a time, which keeps each diff small
+func diffConfig(before ConfigDocument, after ConfigDocument) string {
+	keys := []string{}
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	s := []string{}
+	for _, k := range keys {
+		a, _ := formatConfigValue(before, k)
+		b, _ := formatConfigValue(after, k)
+		if a != b {
+			s = append(s, "--- "+k, diffLines(a, b))
+		}
+	}
+	return strings.Join(s, "\n")
+}
+
+func formatConfigValue(doc ConfigDocument, k string) (string, error) {
+	v, ok := doc[k]
+	if !ok {
+		return "", nil
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	return string(data), err
+}
+
+// diffLines returns a line diff of two strings, prefixing removed lines with "-" and added lines with "+", along with
+// a couple of unchanged lines around each change for context
+func diffLines(a string, b string) string {
+	x := strings.Split(a, "\n")
+	y := strings.Split(b, "\n")
+	if len(a) == 0 {
+		x = nil
+	}
+	if len(b) == 0 {
+		y = nil
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(x)+len(y))
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, "  "+x[i])
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+x[i])
+			i++
+		default:
+			lines = append(lines, "+ "+y[j])
+			j++
+		}
+	}
+
+	const context = 2
+	s := []string{}
+	last := -1
+	for k, line := range lines {
+		changed := false
+		for d := k - context; d <= k+context && !changed; d++ {
+			changed = d >= 0 && d < len(lines) && lines[d][0] != ' '
+		}
+		if changed {
+			if last >= 0 && k > last+1 {
+				s = append(s, "  ...")
+			}
+			s = append(s, line)
+			last = k
+		}
+	}
+	return strings.Join(s, "\n")
+}
@@ -0,0 +1,77 @@
+package sweetiebot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMigrationOrder(t *testing.T) {
+	t.Parallel()
+
+	for i, m := range Migrations {
+		CheckNot(m.Description, "", t)
+		if i > 0 && !Check(m.Version > Migrations[i-1].Version, true, t) {
+			t.Error("Migration out of order: ", m.Version)
+		}
+	}
+	Check(Migrations[len(Migrations)-1].Version, ConfigVersion, t)
+}
+
+func migrateTestDocument(data string, t *testing.T) (*BotConfig, ConfigDocument, []Migration) {
+	doc, err := ParseConfigDocument([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := doc.Migrate(&GuildInfo{Config: *DefaultConfig()}, true)
+	Check(err, nil, t)
+	config := DefaultConfig()
+	Check(doc.Decode(config), nil, t)
+	return config, doc, applied
+}
+
+func TestMigrateConfig(t *testing.T) {
+	t.Parallel()
+
+	config, doc, applied := migrateTestDocument(`{"version": 23, "basic": {"modrole": "5", "oldoption": 1}, "modules": {"commandroles": {}}}`, t)
+	Check(len(applied), 1, t)
+	Check(doc.Version(), ConfigVersion, t)
+	Check(config.Version, ConfigVersion, t)
+	Check(config.Modules.CommandRoles["createrole"]["5"], true, t)
+	Check(doc["basic"].(map[string]interface{})["oldoption"] != nil, true, t) // Unknown options must survive for later migrations
+
+	config, _, applied = migrateTestDocument(`{"version": 10, "basic": {"commandperduration": 7, "commandmaxduration": 11}}`, t)
+	Check(len(applied), ConfigVersion-10, t)
+	Check(config.Modules.CommandPerDuration, 7, t)
+	Check(config.Modules.CommandMaxDuration, int64(11), t)
+
+	config, _, applied = migrateTestDocument(`{"version": 24}`, t)
+	Check(len(applied), 0, t)
+}
+
+func TestMigrateLegacyConfig(t *testing.T) {
+	t.Parallel()
+
+	config, _, applied := migrateTestDocument(`{"version": 9, "logchannel": 123456789012345678, "maxbucket": 7, "command_roles": {}, "witty": {"hi": "hello"}}`, t)
+	Check(len(applied), len(Migrations), t)
+	Check(config.Log.Channel, DiscordChannel("123456789012345678"), t)
+	Check(config.Bucket.MaxItems, 7, t)
+	Check(config.Witty.Responses["hi"], "hello", t)
+	Check(config.Modules.CommandDisabled != nil, true, t)
+	Check(config.Basic.CommandPrefix, "!", t)
+	Check(config.SetupDone, true, t)
+}
+
+func TestDiffConfig(t *testing.T) {
+	t.Parallel()
+
+	before, _ := ParseConfigDocument([]byte(`{"version": 23, "basic": {"a": 1, "b": 2, "c": 3}, "old": true}`))
+	after, _ := ParseConfigDocument([]byte(`{"version": 24, "basic": {"a": 1, "b": 4, "c": 3}}`))
+	diff := diffConfig(before, after)
+	Check(strings.Contains(diff, "--- basic"), true, t)
+	Check(strings.Contains(diff, "-   \"b\": 2"), true, t)
+	Check(strings.Contains(diff, "+   \"b\": 4"), true, t)
+	Check(strings.Contains(diff, "- true"), true, t)
+	Check(strings.Contains(diff, "+ 24"), true, t)
+	Check(diffConfig(after, after), "", t)
+	Check(diffLines("a\nb", "a\nb"), "", t)
+}
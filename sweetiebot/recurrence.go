@@ -0,0 +1,485 @@
+package sweetiebot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is how often a recurrence rule repeats, before its BYDAY, BYMONTHDAY and BYMONTH parts pick the days
+type Frequency uint8
+
+// Supported recurrence frequencies. Occurrences always happen at the time of day of the event, so there is no
+// HOURLY or MINUTELY.
+const (
+	FreqDaily Frequency = iota + 1
+	FreqWeekly
+	FreqMonthly
+	FreqYearly
+)
+
+var frequencyNames = []string{"", "DAILY", "WEEKLY", "MONTHLY", "YEARLY"}
+var frequencyUnits = []string{"", "day", "week", "month", "year"}
+var weekdayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Recurrences never look further ahead than this many days, which is one 400 year cycle of the Gregorian calendar, so
+// that a rule that can never happen again, like the 31st of February, gives up quickly instead of looping forever.
+const maxRecurrenceDays = 146097
+
+// The largest INTERVAL a recurrence rule can have
+const maxRecurrenceInterval = 100
+
+// RecurrenceDay is a weekday in the BYDAY part of a recurrence rule. N picks one of them in the month, or in the year
+// for yearly rules without BYMONTH: 2 is the second one and -1 is the last one. If N is 0, every one of them matches.
+type RecurrenceDay struct {
+	N   int
+	Day time.Weekday
+}
+
+// Recurrence is an iCalendar recurrence rule (RFC 5545), along with the timezone it's evaluated in and the dates it
+// skips. It's written as an RRULE, like "FREQ=MONTHLY;BYDAY=2TU", with two extra parts: TZID names the timezone and
+// EXDATE lists the skipped dates as YYYYMMDD, separated by commas.
+type Recurrence struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []RecurrenceDay
+	ByMonthDay []int
+	ByMonth    []time.Month
+	Until      *time.Time // The last moment an occurrence can happen
+	Count      int        // How many occurrences are left, including the current one. 0 means there is no limit.
+	Location   *time.Location
+	Except     []string // Skipped dates in the YYYYMMDD form, in the rule's timezone
+}
+
+func parseRecurrenceInts(value string, min int, max int) ([]int, error) {
+	r := []int{}
+	for _, s := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimPrefix(s, "+"))
+		if err != nil || n < min || n > max || n == 0 {
+			return nil, fmt.Errorf("%s must be a number from %v to %v, other than 0", s, min, max)
+		}
+		r = append(r, n)
+	}
+	return r, nil
+}
+
+func parseRecurrenceDay(s string) (RecurrenceDay, error) {
+	if len(s) < 2 {
+		return RecurrenceDay{}, fmt.Errorf("%s is not a day of the week", s)
+	}
+	d := RecurrenceDay{Day: -1}
+	for i, v := range weekdayCodes {
+		if v == s[len(s)-2:] {
+			d.Day = time.Weekday(i)
+		}
+	}
+	if d.Day < 0 {
+		return d, fmt.Errorf("%s is not a day of the week! Use MO, TU, WE, TH, FR, SA or SU", s)
+	}
+	if len(s) > 2 {
+		n, err := strconv.Atoi(strings.TrimPrefix(s[:len(s)-2], "+"))
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return d, fmt.Errorf("%s has an invalid number in front of the day", s)
+		}
+		d.N = n
+	}
+	return d, nil
+}
+
+func parseRecurrenceTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("20060102", value, loc)
+	if err != nil {
+		return t, fmt.Errorf("%s is not a date! Use YYYYMMDD or YYYYMMDDTHHMMSS", value)
+	}
+	return t.AddDate(0, 0, 1).Add(-time.Second), nil // A date on its own includes the whole day
+}
+
+// ParseRecurrence parses a recurrence rule, with or without the "RRULE:" in front of it. If the rule doesn't have a
+// TZID part, it is evaluated in loc, or UTC if loc is nil.
+func ParseRecurrence(s string, loc *time.Location) (*Recurrence, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.ToUpper(s[:6]) == "RRULE:" {
+		s = s[6:]
+	}
+	r := &Recurrence{Interval: 1, Location: loc}
+	parts := make(map[string]string)
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 || len(kv[1]) == 0 {
+			return nil, fmt.Errorf("%s is not a KEY=VALUE part of a recurrence rule", part)
+		}
+		parts[strings.ToUpper(kv[0])] = kv[1]
+	}
+	if tz, ok := parts["TZID"]; ok {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a timezone", tz)
+		}
+		r.Location = l
+	}
+
+	var err error
+	for k, v := range parts {
+		value := strings.ToUpper(v)
+		switch k {
+		case "TZID":
+		case "FREQ":
+			for i, name := range frequencyNames {
+				if i > 0 && name == value {
+					r.Freq = Frequency(i)
+				}
+			}
+			if r.Freq == 0 {
+				return nil, fmt.Errorf("%s is not a supported frequency! Use DAILY, WEEKLY, MONTHLY or YEARLY", v)
+			}
+		case "INTERVAL":
+			if r.Interval, err = strconv.Atoi(value); err != nil || r.Interval < 1 || r.Interval > maxRecurrenceInterval {
+				return nil, fmt.Errorf("INTERVAL must be a number from 1 to %v", maxRecurrenceInterval)
+			}
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				d, err := parseRecurrenceDay(day)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, d)
+			}
+		case "BYMONTHDAY":
+			if r.ByMonthDay, err = parseRecurrenceInts(value, -31, 31); err != nil {
+				return nil, err
+			}
+		case "BYMONTH":
+			months, err := parseRecurrenceInts(value, 1, 12)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range months {
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "UNTIL":
+			t, err := parseRecurrenceTime(value, r.Location)
+			if err != nil {
+				return nil, err
+			}
+			t = t.UTC()
+			r.Until = &t
+		case "COUNT":
+			if r.Count, err = strconv.Atoi(value); err != nil || r.Count < 1 {
+				return nil, errors.New("COUNT must be a positive number")
+			}
+		case "EXDATE":
+			for _, date := range strings.Split(value, ",") {
+				if len(date) < 8 {
+					return nil, fmt.Errorf("%s is not a date! Use YYYYMMDD", date)
+				}
+				if _, err := time.Parse("20060102", date[:8]); err != nil {
+					return nil, fmt.Errorf("%s is not a date! Use YYYYMMDD", date)
+				}
+				r.Except = append(r.Except, date[:8])
+			}
+			sort.Strings(r.Except)
+		case "WKST":
+			if value != "MO" {
+				return nil, errors.New("Weeks always start on Monday")
+			}
+		default:
+			return nil, fmt.Errorf("%s is not a supported part of a recurrence rule", k)
+		}
+	}
+
+	if r.Freq == 0 {
+		return nil, errors.New("A recurrence rule needs a FREQ")
+	}
+	if r.Until != nil && r.Count > 0 {
+		return nil, errors.New("A recurrence rule can't have both an UNTIL and a COUNT")
+	}
+	for _, d := range r.ByDay {
+		if d.N != 0 && r.Freq != FreqMonthly && r.Freq != FreqYearly {
+			return nil, errors.New("Only MONTHLY and YEARLY rules can pick a specific weekday, like 2TU")
+		}
+		if d.N != 0 && (d.N < -5 || d.N > 5) && (r.Freq == FreqMonthly || len(r.ByMonth) > 0) {
+			return nil, errors.New("A month has at most 5 of each weekday")
+		}
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq == FreqWeekly {
+		return nil, errors.New("WEEKLY rules can't have a BYMONTHDAY")
+	}
+	return r, nil
+}
+
+// String writes the rule in the form ParseRecurrence reads, always including the timezone
+func (r *Recurrence) String() string {
+	s := r.RRule() + ";TZID=" + r.location().String()
+	if len(r.Except) > 0 {
+		s += ";EXDATE=" + strings.Join(r.Except, ",")
+	}
+	return s
+}
+
+// RRule writes the rule as a standard RRULE value, without the timezone or the skipped dates
+func (r *Recurrence) RRule() string {
+	s := []string{"FREQ=" + frequencyNames[r.Freq]}
+	if r.Interval > 1 {
+		s = append(s, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = weekdayCodes[d.Day]
+			if d.N != 0 {
+				days[i] = strconv.Itoa(d.N) + days[i]
+			}
+		}
+		s = append(s, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			days[i] = strconv.Itoa(d)
+		}
+		s = append(s, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, m := range r.ByMonth {
+			months[i] = strconv.Itoa(int(m))
+		}
+		s = append(s, "BYMONTH="+strings.Join(months, ","))
+	}
+	if r.Until != nil {
+		s = append(s, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if r.Count > 0 {
+		s = append(s, "COUNT="+strconv.Itoa(r.Count))
+	}
+	return strings.Join(s, ";")
+}
+
+func (r *Recurrence) location() *time.Location {
+	if r.Location == nil {
+		return time.UTC
+	}
+	return r.Location
+}
+
+// period returns which day, week, month or year the date falls in, counting from an arbitrary starting point. Weeks
+// start on Monday.
+func (r *Recurrence) period(day time.Time) int {
+	switch r.Freq {
+	case FreqWeekly:
+		return int((day.Unix()/86400 + 3) / 7) // January 1st 1970 was a Thursday
+	case FreqMonthly:
+		return day.Year()*12 + int(day.Month())
+	case FreqYearly:
+		return day.Year()
+	}
+	return int(day.Unix() / 86400)
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// matchDay returns true if the weekday, and which one of them it is in the month or year, matches the BYDAY part
+func (r *Recurrence) matchDay(d RecurrenceDay, day time.Time) bool {
+	if d.Day != day.Weekday() {
+		return false
+	}
+	if d.N == 0 {
+		return true
+	}
+	n, last := day.Day(), daysIn(day.Year(), day.Month())
+	if r.Freq == FreqYearly && len(r.ByMonth) == 0 {
+		n, last = day.YearDay(), time.Date(day.Year(), 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+	}
+	if d.N > 0 {
+		return (n-1)/7+1 == d.N
+	}
+	return (last-n)/7+1 == -d.N
+}
+
+// matches returns true if an occurrence can happen on the day. prev is the previous occurrence, which is where the
+// day of the week, month or year comes from when the rule doesn't say.
+func (r *Recurrence) matches(day time.Time, prev time.Time) bool {
+	if len(r.ByMonth) > 0 {
+		found := false
+		for _, m := range r.ByMonth {
+			found = found || m == day.Month()
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.ByMonthDay) > 0 {
+		found := false
+		last := daysIn(day.Year(), day.Month())
+		for _, n := range r.ByMonthDay {
+			found = found || n == day.Day() || (n < 0 && last+n+1 == day.Day())
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.ByDay) > 0 {
+		found := false
+		for _, d := range r.ByDay {
+			found = found || r.matchDay(d, day)
+		}
+		return found
+	}
+	if len(r.ByMonthDay) > 0 {
+		return true
+	}
+	switch r.Freq {
+	case FreqWeekly:
+		return day.Weekday() == prev.Weekday()
+	case FreqMonthly:
+		return day.Day() == prev.Day()
+	case FreqYearly:
+		return day.Day() == prev.Day() && (len(r.ByMonth) > 0 || day.Month() == prev.Month())
+	}
+	return true
+}
+
+// Advance finds the occurrence that comes after prev and counts the current one off the COUNT, if the rule has one.
+// Returns false if there are no more occurrences. Occurrences happen at the same time of day as prev in the rule's
+// timezone, even across daylight saving changes.
+func (r *Recurrence) Advance(prev time.Time) (time.Time, bool) {
+	loc := r.location()
+	local := prev.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC) // Count days without DST
+	first := r.period(start)
+	for i := 1; i <= maxRecurrenceDays; i++ {
+		day := start.AddDate(0, 0, i)
+		if (r.period(day)-first)%r.Interval != 0 || !r.matches(day, local) {
+			continue
+		}
+		t := time.Date(day.Year(), day.Month(), day.Day(), local.Hour(), local.Minute(), local.Second(), 0, loc).UTC()
+		if r.Until != nil && t.After(*r.Until) {
+			return time.Time{}, false
+		}
+		if r.Count > 0 { // Skipped dates still use up an occurrence, like they do in iCalendar
+			r.Count--
+			if r.Count == 0 {
+				return time.Time{}, false
+			}
+		}
+		if r.Skips(t) {
+			continue
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// Next returns the occurrence after prev without changing the rule
+func (r *Recurrence) Next(prev time.Time) (time.Time, bool) {
+	c := *r
+	return c.Advance(prev)
+}
+
+// Validate returns an error if the rule never has an occurrence after start, ignoring its COUNT and UNTIL
+func (r *Recurrence) Validate(start time.Time) error {
+	c := *r
+	c.Count = 0
+	c.Until = nil
+	if _, ok := c.Advance(start); !ok {
+		return errors.New("that rule never happens again, check the dates it repeats on")
+	}
+	return nil
+}
+
+// Skips returns true if the time falls on one of the rule's skipped dates
+func (r *Recurrence) Skips(t time.Time) bool {
+	date := t.In(r.location()).Format("20060102")
+	i := sort.SearchStrings(r.Except, date)
+	return i < len(r.Except) && r.Except[i] == date
+}
+
+func ordinal(n int) string {
+	switch {
+	case n == -1:
+		return "last"
+	case n < 0:
+		return ordinal(-n) + " to last"
+	case n%100 >= 11 && n%100 <= 13:
+		return strconv.Itoa(n) + "th"
+	case n%10 == 1:
+		return strconv.Itoa(n) + "st"
+	case n%10 == 2:
+		return strconv.Itoa(n) + "nd"
+	case n%10 == 3:
+		return strconv.Itoa(n) + "rd"
+	}
+	return strconv.Itoa(n) + "th"
+}
+
+func joinAnd(s []string) string {
+	if len(s) < 2 {
+		return strings.Join(s, "")
+	}
+	return strings.Join(s[:len(s)-1], ", ") + " and " + s[len(s)-1]
+}
+
+// Describe explains the rule in plain english, like "every 2 weeks on Tuesday and Thursday"
+func (r *Recurrence) Describe() string {
+	unit := frequencyUnits[r.Freq]
+	s := "every " + unit
+	if r.Interval > 1 {
+		s = fmt.Sprintf("every %v %ss", r.Interval, unit)
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = d.Day.String()
+			if d.N != 0 {
+				days[i] = "the " + ordinal(d.N) + " " + days[i]
+			}
+		}
+		if len(days) == 5 && strings.Join(days, ",") == "Monday,Tuesday,Wednesday,Thursday,Friday" {
+			days = []string{"weekdays"}
+		}
+		s += " on " + joinAnd(days)
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			days[i] = "the " + ordinal(d)
+		}
+		s += " on " + joinAnd(days)
+		if r.ByMonthDay[len(r.ByMonthDay)-1] < 0 {
+			s += " day"
+		}
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, m := range r.ByMonth {
+			months[i] = m.String()
+		}
+		s += " in " + joinAnd(months)
+	}
+	if r.Until != nil {
+		s += " until " + r.Until.In(r.location()).Format("Jan 2 2006")
+	}
+	if r.Count == 1 {
+		s += ", for the last time"
+	} else if r.Count > 1 {
+		s += fmt.Sprintf(", %v more times", r.Count-1)
+	}
+	if len(r.Except) > 0 {
+		s += ", " + Pluralize(int64(len(r.Except)), " date") + " skipped"
+	}
+	return s + " (" + r.location().String() + ")"
+}
@@ -0,0 +1,134 @@
+package sweetiebot
+
+import (
+	"testing"
+	"time"
+)
+
+func checkNext(rule string, prev time.Time, expected time.Time, t *testing.T) {
+	r, err := ParseRecurrence(rule, nil)
+	if !Check(err, nil, t) {
+		return
+	}
+	next, ok := r.Next(prev)
+	Check(ok, true, t)
+	if !next.Equal(expected) {
+		t.Errorf("%s after %v: expected %v but got %v", rule, prev, expected, next)
+	}
+}
+
+func TestParseRecurrence(t *testing.T) {
+	t.Parallel()
+
+	for k, v := range map[string]string{
+		"RRULE:FREQ=MONTHLY;BYDAY=2TU;TZID=America/New_York":   "FREQ=MONTHLY;BYDAY=2TU;TZID=America/New_York",
+		"freq=weekly;interval=2;byday=tu,th":                   "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;TZID=UTC",
+		"FREQ=DAILY;COUNT=3;EXDATE=20240105,20240103":          "FREQ=DAILY;COUNT=3;TZID=UTC;EXDATE=20240103,20240105",
+		"FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=-1;UNTIL=20301231":   "FREQ=YEARLY;BYMONTHDAY=-1;BYMONTH=3;UNTIL=20301231T235959Z;TZID=UTC",
+		"FREQ=MONTHLY;INTERVAL=1;BYDAY=-1FR;WKST=MO;TZID=UTC ": "FREQ=MONTHLY;BYDAY=-1FR;TZID=UTC",
+	} {
+		r, err := ParseRecurrence(k, nil)
+		if Check(err, nil, t) {
+			Check(r.String(), v, t)
+			r, err = ParseRecurrence(r.String(), nil)
+			Check(err, nil, t)
+			Check(r.String(), v, t)
+		}
+	}
+	for _, v := range []string{
+		"",
+		"BYDAY=MO",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;UNTIL=20240101;COUNT=3",
+		"FREQ=WEEKLY;BYDAY=2TU",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;BYDAY=XX",
+		"FREQ=DAILY;FOO=1",
+		"FREQ=DAILY;TZID=Nowhere/Special",
+		"FREQ=DAILY;EXDATE=2024",
+		"FREQ=DAILY;WKST=SU",
+	} {
+		_, err := ParseRecurrence(v, nil)
+		CheckNot(err, nil, t)
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	t.Parallel()
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("timezone database isn't available")
+	}
+
+	// The second tuesday of the month at 7pm in New York, across the start of daylight saving time on March 10th
+	checkNext("FREQ=MONTHLY;BYDAY=2TU;TZID=America/New_York", time.Date(2024, 1, 9, 19, 0, 0, 0, ny), time.Date(2024, 2, 13, 19, 0, 0, 0, ny), t)
+	checkNext("FREQ=MONTHLY;BYDAY=2TU;TZID=America/New_York", time.Date(2024, 2, 13, 19, 0, 0, 0, ny), time.Date(2024, 3, 12, 23, 0, 0, 0, time.UTC), t)
+	checkNext("FREQ=MONTHLY;BYDAY=-1FR", time.Date(2024, 1, 26, 12, 0, 0, 0, time.UTC), time.Date(2024, 2, 23, 12, 0, 0, 0, time.UTC), t)
+	checkNext("FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC), time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC), t)
+	checkNext("FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH", time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC), time.Date(2024, 1, 4, 9, 0, 0, 0, time.UTC), t)
+	checkNext("FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH", time.Date(2024, 1, 4, 9, 0, 0, 0, time.UTC), time.Date(2024, 1, 16, 9, 0, 0, 0, time.UTC), t)
+	checkNext("FREQ=WEEKLY", time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC), time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC), t)
+	checkNext("FREQ=MONTHLY;BYMONTHDAY=31", time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC), time.Date(2024, 3, 31, 9, 0, 0, 0, time.UTC), t)
+	checkNext("FREQ=MONTHLY;BYMONTHDAY=-1", time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC), t)
+	checkNext("FREQ=YEARLY", time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 9, 0, 0, 0, time.UTC), t)
+	checkNext("FREQ=DAILY;EXDATE=20240103,20240104", time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC), time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC), t)
+
+	r, _ := ParseRecurrence("FREQ=DAILY;UNTIL=20240110", nil)
+	_, ok := r.Next(time.Date(2024, 1, 9, 9, 0, 0, 0, time.UTC))
+	Check(ok, true, t)
+	_, ok = r.Next(time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC))
+	Check(ok, false, t)
+
+	r, _ = ParseRecurrence("FREQ=DAILY;COUNT=2", nil)
+	_, ok = r.Advance(time.Date(2024, 1, 9, 9, 0, 0, 0, time.UTC))
+	Check(ok, true, t)
+	Check(r.Count, 1, t)
+	_, ok = r.Advance(time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC))
+	Check(ok, false, t)
+
+	r, _ = ParseRecurrence("FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", nil)
+	_, ok = r.Next(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	Check(ok, false, t)
+}
+
+func TestRecurrenceValidate(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for k, v := range map[string]bool{
+		"FREQ=DAILY;COUNT=1":                               true,
+		"FREQ=DAILY;UNTIL=20240101":                        true,
+		"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29":              true,
+		"FREQ=YEARLY;INTERVAL=100;BYMONTH=2;BYMONTHDAY=29": true,
+		"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30":              false,
+		"FREQ=YEARLY;INTERVAL=100;BYMONTH=2;BYMONTHDAY=30": false,
+		"FREQ=MONTHLY;INTERVAL=12;BYMONTH=4;BYMONTHDAY=31": false,
+	} {
+		r, err := ParseRecurrence(k, nil)
+		if Check(err, nil, t) {
+			Check(r.Validate(start) == nil, v, t)
+		}
+	}
+}
+
+func TestRecurrenceDescribe(t *testing.T) {
+	t.Parallel()
+
+	for k, v := range map[string]string{
+		"FREQ=DAILY": "every day (UTC)",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;TZID=America/New_York":    "every 2 weeks on Tuesday and Thursday (America/New_York)",
+		"FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR":                            "every week on weekdays (UTC)",
+		"FREQ=MONTHLY;BYDAY=2TU;COUNT=3":                              "every month on the 2nd Tuesday, 2 more times (UTC)",
+		"FREQ=MONTHLY;BYMONTHDAY=-1;EXDATE=20241231":                  "every month on the last day, 1 date skipped (UTC)",
+		"FREQ=YEARLY;BYMONTH=12;BYMONTHDAY=25;UNTIL=20301231T000000Z": "every year on the 25th in December until Dec 31 2030 (UTC)",
+	} {
+		r, err := ParseRecurrence(k, nil)
+		if Check(err, nil, t) {
+			Check(r.Describe(), v, t)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package sweetiebot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Types of scheduled events. These are stored in the database, so they must never change.
+const (
+	EventBan        uint8 = 0
+	EventBirthday   uint8 = 1
+	EventMessage    uint8 = 2
+	EventEpisode    uint8 = 3
+	EventUnbirthday uint8 = 4
+	EventGeneric    uint8 = 5
+	EventReminder   uint8 = 6
+	EventRole       uint8 = 7
+	EventSilence    uint8 = 8
+	EventRemoveRole uint8 = 9
+	EventTimeout    uint8 = 10
+)
+
+// Execution states of scheduled events, stored in the database. Once an event has run successfully it's done, so it's
+// removed from the schedule, or goes back to pending at its next occurrence if it repeats.
+const (
+	JobPending uint8 = 0
+	JobRunning uint8 = 1 // Being run right now, or the bot stopped while running it, in which case it's run again later
+	JobFailed  uint8 = 2 // Failed MaxEventAttempts times in a row, so it won't run again until a moderator retries it
+)
+
+// MaxEventAttempts is how many times the scheduler tries to run an event before giving up on it
+const MaxEventAttempts = 8
+
+// EventRetryDelay returns how long the scheduler waits before running an event again after it failed the given number
+// of times in a row. The delay doubles with every attempt, starting at a minute, up to an hour.
+func EventRetryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	if attempts > 7 { // Stops the shift from overflowing
+		return time.Hour
+	}
+	if d := time.Minute << uint(attempts-1); d < time.Hour {
+		return d
+	}
+	return time.Hour
+}
+
+// EventIsAnnouncement returns true if events of this type only post a message, so nothing is lost if one is skipped.
+// Every other event changes something on the server, or is a reminder someone asked for, so it always runs.
+func EventIsAnnouncement(ty uint8) bool {
+	return ty == EventMessage || ty == EventEpisode || ty == EventGeneric || ty == EventRole
+}
+
+// MissedEventPolicy is what the scheduler does with announcements that should have been posted while the bot was offline
+type MissedEventPolicy string
+
+// Missed event policies. An empty policy fires missed events late, which is what the scheduler always did.
+const (
+	MissedFire      MissedEventPolicy = "fire"
+	MissedSkip      MissedEventPolicy = "skip"
+	MissedSummarize MissedEventPolicy = "summarize"
+)
+
+var missedEventPolicies = []MissedEventPolicy{MissedFire, MissedSkip, MissedSummarize}
+
+// ParseMissedEventPolicy parses the name of a missed event policy, ignoring case
+func ParseMissedEventPolicy(s string) (MissedEventPolicy, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, v := range missedEventPolicies {
+		if s == string(v) {
+			return v, nil
+		}
+	}
+	return MissedFire, fmt.Errorf("%s is not a missed event policy! Use fire, skip or summarize.", s)
+}
+
+// EventData is the payload of a scheduled event. Which fields an event uses depends on its type.
+type EventData struct {
+	User    DiscordUser // Who the event is about: bans, birthdays, reminders, silences, role removals and timeouts
+	Role    DiscordRole // The role taken away by a role removal
+	Mention string      // The role pings that a role event sends its message to
+	Message string      // The message, or the name of the episode or event
+}
+
+// ParseEventData decodes the Data column of an event. Reminders are stored as "user|message", role events as
+// "pings|message" and role removals as "user|role". Every other event holds either a user or a message on its own.
+func ParseEventData(ty uint8, data string) EventData {
+	split := func() (string, string) {
+		s := strings.SplitN(data, "|", 2)
+		if len(s) < 2 {
+			return s[0], ""
+		}
+		return s[0], s[1]
+	}
+	switch ty {
+	case EventBan, EventBirthday, EventUnbirthday, EventSilence, EventTimeout:
+		return EventData{User: DiscordUser(data)}
+	case EventReminder:
+		user, message := split()
+		return EventData{User: DiscordUser(user), Message: message}
+	case EventRole:
+		mention, message := split()
+		return EventData{Mention: mention, Message: message}
+	case EventRemoveRole:
+		user, role := split()
+		return EventData{User: DiscordUser(user), Role: DiscordRole(role)}
+	}
+	return EventData{Message: data}
+}
+
+// Encode turns the payload back into the Data column of an event of the given type
+func (d EventData) Encode(ty uint8) string {
+	switch ty {
+	case EventBan, EventBirthday, EventUnbirthday, EventSilence, EventTimeout:
+		return d.User.String()
+	case EventReminder:
+		return d.User.String() + "|" + d.Message
+	case EventRole:
+		return d.Mention + "|" + d.Message
+	case EventRemoveRole:
+		return d.User.String() + "|" + d.Role.String()
+	}
+	return d.Message
+}
+
+// ReminderOffsets is a comma separated list of how long before an event the members going to it are reminded about
+// it, like "1 day, 15 minutes".
+type ReminderOffsets string
+
+// ParseReminderOffsets checks that a list of reminder offsets is valid and returns it in a standard form, longest first
+func ParseReminderOffsets(s string) (ReminderOffsets, error) {
+	offsets := []Duration{}
+	for _, v := range strings.Split(s, ",") {
+		fields := strings.Fields(v)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return "", errors.New("reminders must look like \"1 day, 15 minutes\"")
+		}
+		d, err := parseDuration(fields[0], fields[1])
+		if err != nil {
+			return "", err
+		}
+		offsets = append(offsets, d)
+	}
+	sortOffsets(offsets)
+	r := make([]string, 0, len(offsets))
+	seen := make(map[Duration]bool, len(offsets))
+	for _, v := range offsets {
+		if !seen[v] {
+			seen[v] = true
+			r = append(r, v.String())
+		}
+	}
+	return ReminderOffsets(strings.Join(r, ", ")), nil
+}
+
+// Durations returns the offsets, longest first. Offsets that aren't valid are skipped.
+func (r ReminderOffsets) Durations() []Duration {
+	offsets := []Duration{}
+	for _, v := range strings.Split(string(r), ",") {
+		if fields := strings.Fields(v); len(fields) == 2 {
+			if d, err := parseDuration(fields[0], fields[1]); err == nil {
+				offsets = append(offsets, d)
+			}
+		}
+	}
+	sortOffsets(offsets)
+	return offsets
+}
+
+func sortOffsets(offsets []Duration) {
+	ref := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	sort.SliceStable(offsets, func(i, j int) bool { return offsets[i].After(ref).After(offsets[j].After(ref)) })
+}
+
+// DueReminder returns the latest time before the event starts that the members going to it should have been reminded
+// by now, and how long before the event that was. Returns false if no reminder is due yet, or the event has started.
+func (r ReminderOffsets) DueReminder(event time.Time, now time.Time) (time.Time, Duration, bool) {
+	if !now.Before(event) {
+		return time.Time{}, Duration{}, false
+	}
+	offsets := r.Durations()
+	for i := len(offsets) - 1; i >= 0; i-- { // Shortest first, so the most recent reminder wins
+		if t := offsets[i].Before(event); !t.After(now) {
+			return t, offsets[i], true
+		}
+	}
+	return time.Time{}, Duration{}, false
+}
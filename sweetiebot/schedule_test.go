@@ -0,0 +1,97 @@
+package sweetiebot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventData(t *testing.T) {
+	t.Parallel()
+
+	for ty, v := range map[uint8]string{
+		EventBan:        "1234",
+		EventMessage:    "hello | there",
+		EventReminder:   "1234|don't forget|the milk",
+		EventRole:       "<@&5> <@&6>|movie night",
+		EventRemoveRole: "1234|5678",
+		EventTimeout:    "1234",
+	} {
+		Check(ParseEventData(ty, v).Encode(ty), v, t)
+	}
+
+	Check(ParseEventData(EventReminder, "1234|don't forget|the milk"), EventData{User: "1234", Message: "don't forget|the milk"}, t)
+	Check(ParseEventData(EventRole, "<@&5>|movie night"), EventData{Mention: "<@&5>", Message: "movie night"}, t)
+	Check(ParseEventData(EventRemoveRole, "1234|5678"), EventData{User: "1234", Role: "5678"}, t)
+	Check(ParseEventData(EventRemoveRole, "1234"), EventData{User: "1234"}, t)
+	Check(ParseEventData(EventGeneric, "a|b"), EventData{Message: "a|b"}, t)
+}
+
+func TestReminderOffsets(t *testing.T) {
+	t.Parallel()
+
+	for k, v := range map[string]ReminderOffsets{
+		"":                             "",
+		"15 minutes, 1 day":            "1 day, 15 minutes",
+		" 1 week,2 hours , 1 hour ":    "1 week, 2 hours, 1 hour",
+		"1 day, 24 hours, 1 day":       "1 day, 24 hours",
+		"30 MINUTES,, 1 minute":        "30 minutes, 1 minute",
+		"90 seconds, 1 month, 3 hours": "1 month, 3 hours, 90 seconds",
+	} {
+		r, err := ParseReminderOffsets(k)
+		if Check(err, nil, t) {
+			Check(r, v, t)
+		}
+	}
+	for _, v := range []string{"1 day 15 minutes", "day", "0 days", "-1 hour", "1 fortnight"} {
+		_, err := ParseReminderOffsets(v)
+		CheckNot(err, nil, t)
+	}
+
+	event := time.Date(2030, 1, 10, 20, 0, 0, 0, time.UTC)
+	r := ReminderOffsets("1 day, 15 minutes")
+	_, _, ok := r.DueReminder(event, event.Add(-25*time.Hour))
+	Check(ok, false, t)
+	due, d, ok := r.DueReminder(event, event.Add(-2*time.Hour))
+	Check(ok, true, t)
+	Check(due, event.AddDate(0, 0, -1), t)
+	Check(d.String(), "1 day", t)
+	due, d, ok = r.DueReminder(event, event.Add(-time.Minute))
+	Check(ok, true, t)
+	Check(due, event.Add(-15*time.Minute), t)
+	Check(d.String(), "15 minutes", t)
+	_, _, ok = r.DueReminder(event, event)
+	Check(ok, false, t)
+	_, _, ok = ReminderOffsets("").DueReminder(event, event.Add(-time.Minute))
+	Check(ok, false, t)
+}
+
+func TestEventRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	Check(EventRetryDelay(0), time.Duration(0), t)
+	Check(EventRetryDelay(1), time.Minute, t)
+	Check(EventRetryDelay(2), 2*time.Minute, t)
+	Check(EventRetryDelay(6), 32*time.Minute, t)
+	Check(EventRetryDelay(7), time.Hour, t)
+	Check(EventRetryDelay(100), time.Hour, t)
+}
+
+func TestParseMissedEventPolicy(t *testing.T) {
+	t.Parallel()
+
+	for k, v := range map[string]MissedEventPolicy{
+		"fire":      MissedFire,
+		" Skip ":    MissedSkip,
+		"SUMMARIZE": MissedSummarize,
+	} {
+		p, err := ParseMissedEventPolicy(k)
+		Check(err, nil, t)
+		Check(p, v, t)
+	}
+	_, err := ParseMissedEventPolicy("ignore")
+	CheckNot(err, nil, t)
+	Check(EventIsAnnouncement(EventGeneric), true, t)
+	Check(EventIsAnnouncement(EventRole), true, t)
+	Check(EventIsAnnouncement(EventBan), false, t)
+	Check(EventIsAnnouncement(EventReminder), false, t)
+}
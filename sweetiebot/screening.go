@@ -0,0 +1,49 @@
+package sweetiebot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ScreenAction is what happens to a new member who fails one of the join screening rules in the spam config
+type ScreenAction string
+
+// Join screening actions. An empty action disables the rule.
+const (
+	ScreenNone    ScreenAction = ""
+	ScreenAlert   ScreenAction = "alert"
+	ScreenSilence ScreenAction = "silence"
+	ScreenKick    ScreenAction = "kick"
+	ScreenBan     ScreenAction = "ban"
+)
+
+// From least to most severe
+var screenActions = []ScreenAction{ScreenNone, ScreenAlert, ScreenSilence, ScreenKick, ScreenBan}
+
+// ParseScreenAction parses the name of a join screening action, ignoring case. "none" or "off" disable the rule.
+func ParseScreenAction(s string) (ScreenAction, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "none" || s == "off" {
+		return ScreenNone, nil
+	}
+	for _, v := range screenActions {
+		if s == string(v) {
+			return v, nil
+		}
+	}
+	return ScreenNone, fmt.Errorf("%s is not a screening action! Use alert, silence, kick, ban or none.", s)
+}
+
+// Harsher returns true if this action is more severe than the other one
+func (a ScreenAction) Harsher(other ScreenAction) bool {
+	return a.severity() > other.severity()
+}
+
+func (a ScreenAction) severity() int {
+	for i, v := range screenActions {
+		if a == v {
+			return i
+		}
+	}
+	return 0
+}
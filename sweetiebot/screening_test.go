@@ -0,0 +1,37 @@
+package sweetiebot
+
+import "testing"
+
+func TestParseScreenAction(t *testing.T) {
+	t.Parallel()
+
+	for k, v := range map[string]ScreenAction{
+		"alert":   ScreenAlert,
+		"Kick":    ScreenKick,
+		" BAN ":   ScreenBan,
+		"none":    ScreenNone,
+		"off":     ScreenNone,
+		"":        ScreenNone,
+		"silence": ScreenSilence,
+	} {
+		a, err := ParseScreenAction(k)
+		Check(err, nil, t)
+		Check(a, v, t)
+	}
+	_, err := ParseScreenAction("timeout")
+	CheckNot(err, nil, t)
+
+	Check(ScreenBan.Harsher(ScreenKick), true, t)
+	Check(ScreenSilence.Harsher(ScreenAlert), true, t)
+	Check(ScreenAlert.Harsher(ScreenNone), true, t)
+	Check(ScreenAlert.Harsher(ScreenAlert), false, t)
+	Check(ScreenKick.Harsher(ScreenBan), false, t)
+
+	config := DefaultConfig()
+	_, ok := config.internalSetConfig(nil, "testing.screen", "KICK")
+	Check(ok, true, t)
+	Check(config.Section("testing").(*testConfig).Screen, ScreenKick, t)
+	_, ok = config.internalSetConfig(nil, "testing.screen", "explode")
+	Check(ok, false, t)
+	Check(config.Section("testing").(*testConfig).Screen, ScreenKick, t)
+}
@@ -0,0 +1,747 @@
+package sweetiebot
+
+import (
+	"database/sql"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteStorage is an embedded, pure-Go backend intended for development and testing. The connection string is
+// simply the path to the database file, or ":memory:". SQLite has no stored procedures, so everything in
+// sweetiebot.sql is implemented here in Go instead, except for the triggers, which SQLite supports natively.
+type sqliteStorage struct {
+	stop chan struct{}
+	once sync.Once
+}
+
+// sqliteSchema mirrors sweetiebot.sql. Text columns that MySQL compares using utf8mb4_general_ci use NOCASE here.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS timezones (
+  Location VARCHAR(40) NOT NULL COLLATE NOCASE PRIMARY KEY,
+  ` + "`Offset`" + ` INTEGER NOT NULL,
+  DST INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS users (
+  ID BIGINT NOT NULL PRIMARY KEY,
+  Username VARCHAR(128) NOT NULL DEFAULT '' COLLATE NOCASE,
+  Discriminator INTEGER NOT NULL DEFAULT 0,
+  Avatar VARCHAR(512) NOT NULL DEFAULT '',
+  LastSeen DATETIME NOT NULL,
+  LastNameChange DATETIME NOT NULL,
+  Location VARCHAR(40) DEFAULT NULL COLLATE NOCASE,
+  DefaultServer BIGINT DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS INDEX_USERNAME ON users (Username);
+CREATE TABLE IF NOT EXISTS aliases (
+  User BIGINT NOT NULL,
+  Alias VARCHAR(128) NOT NULL COLLATE NOCASE,
+  Timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  Duration BIGINT NOT NULL,
+  PRIMARY KEY (User, Alias)
+);
+CREATE INDEX IF NOT EXISTS ALIASES_ALIAS ON aliases (Alias);
+CREATE TABLE IF NOT EXISTS cases (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  Guild BIGINT NOT NULL,
+  Number BIGINT NOT NULL,
+  User BIGINT NOT NULL,
+  Moderator BIGINT NOT NULL,
+  Type TINYINT NOT NULL,
+  Reason VARCHAR(1000) NOT NULL DEFAULT '',
+  Duration INTEGER DEFAULT NULL,
+  Schedule BIGINT DEFAULT NULL,
+  Timestamp DATETIME NOT NULL,
+  Pardoned BOOLEAN NOT NULL DEFAULT 0,
+  UNIQUE (Guild, Number)
+);
+CREATE INDEX IF NOT EXISTS INDEX_GUILD_USER ON cases (Guild, User);
+CREATE TABLE IF NOT EXISTS lockdown (
+  Guild BIGINT NOT NULL,
+  Type TINYINT NOT NULL,
+  Channel BIGINT NOT NULL,
+  Value INTEGER NOT NULL DEFAULT 0,
+  Overwrite BOOLEAN NOT NULL DEFAULT 0,
+  Allow BIGINT NOT NULL DEFAULT 0,
+  Deny BIGINT NOT NULL DEFAULT 0,
+  Ends DATETIME DEFAULT NULL,
+  PRIMARY KEY (Guild, Type, Channel)
+);
+CREATE TABLE IF NOT EXISTS filterhits (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  Guild BIGINT NOT NULL,
+  Filter VARCHAR(128) NOT NULL,
+  Word VARCHAR(255) NOT NULL DEFAULT '',
+  User BIGINT NOT NULL,
+  CaseNumber BIGINT DEFAULT NULL,
+  FalsePositive BOOLEAN NOT NULL DEFAULT 0,
+  Timestamp DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS INDEX_GUILD_TIMESTAMP ON filterhits (Guild, Timestamp);
+CREATE INDEX IF NOT EXISTS INDEX_GUILD_CASE ON filterhits (Guild, CaseNumber);
+CREATE TABLE IF NOT EXISTS chatlog (
+  ID BIGINT NOT NULL PRIMARY KEY,
+  Author BIGINT NOT NULL,
+  Message VARCHAR(2000) NOT NULL,
+  Timestamp DATETIME NOT NULL,
+  Channel BIGINT NOT NULL,
+  Everyone BOOLEAN NOT NULL,
+  Guild BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS CHATLOG_TIMESTAMP ON chatlog (Timestamp);
+CREATE INDEX IF NOT EXISTS CHATLOG_CHANNEL ON chatlog (Channel);
+CREATE INDEX IF NOT EXISTS CHATLOG_USERS ON chatlog (Author);
+CREATE TABLE IF NOT EXISTS debuglog (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  Type TINYINT NOT NULL,
+  User BIGINT DEFAULT NULL,
+  Message VARCHAR(4096) NOT NULL,
+  Timestamp DATETIME NOT NULL,
+  Guild BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS DEBUGLOG_TIMESTAMP ON debuglog (Timestamp);
+CREATE TABLE IF NOT EXISTS editlog (
+  ID BIGINT NOT NULL,
+  Timestamp DATETIME NOT NULL,
+  Author BIGINT NOT NULL,
+  Message VARCHAR(2000) NOT NULL,
+  Channel BIGINT NOT NULL,
+  Everyone BOOLEAN NOT NULL,
+  Guild BIGINT NOT NULL,
+  PRIMARY KEY (ID, Timestamp)
+);
+CREATE INDEX IF NOT EXISTS EDITLOG_TIMESTAMP ON editlog (Timestamp);
+CREATE TABLE IF NOT EXISTS items (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  Content VARCHAR(500) NOT NULL COLLATE NOCASE
+);
+CREATE INDEX IF NOT EXISTS CONTENT_INDEX ON items (Content);
+CREATE TABLE IF NOT EXISTS tags (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  Name VARCHAR(50) NOT NULL COLLATE NOCASE,
+  Guild BIGINT NOT NULL,
+  UNIQUE (Name, Guild)
+);
+CREATE TABLE IF NOT EXISTS itemtags (
+  Item BIGINT NOT NULL,
+  Tag BIGINT NOT NULL,
+  PRIMARY KEY (Item, Tag)
+);
+CREATE INDEX IF NOT EXISTS FK_itemtags_tags ON itemtags (Tag);
+CREATE TABLE IF NOT EXISTS markov_transcripts_speaker (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  Speaker VARCHAR(64) NOT NULL UNIQUE COLLATE NOCASE
+);
+CREATE TABLE IF NOT EXISTS markov_transcripts (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  SpeakerID BIGINT NOT NULL DEFAULT 0,
+  Phrase VARCHAR(64) NOT NULL COLLATE NOCASE,
+  UNIQUE (SpeakerID, Phrase)
+);
+CREATE TABLE IF NOT EXISTS markov_transcripts_map (
+  Prev BIGINT NOT NULL,
+  Prev2 BIGINT NOT NULL,
+  Next BIGINT NOT NULL,
+  Count INTEGER NOT NULL DEFAULT 1,
+  PRIMARY KEY (Prev, Next, Prev2)
+);
+CREATE INDEX IF NOT EXISTS INDEX_PREV ON markov_transcripts_map (Prev);
+CREATE TABLE IF NOT EXISTS members (
+  ID BIGINT NOT NULL,
+  Guild BIGINT NOT NULL,
+  FirstSeen DATETIME NOT NULL,
+  Nickname VARCHAR(128) NOT NULL DEFAULT '' COLLATE NOCASE,
+  FirstMessage DATETIME DEFAULT NULL,
+  PRIMARY KEY (ID, Guild)
+);
+CREATE INDEX IF NOT EXISTS INDEX_NICKNAME ON members (Nickname);
+CREATE INDEX IF NOT EXISTS INDEX_GUILD_FIRSTSEEN ON members (Guild, FirstSeen);
+CREATE TABLE IF NOT EXISTS polls (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  Guild BIGINT NOT NULL,
+  Name VARCHAR(50) NOT NULL COLLATE NOCASE,
+  Description VARCHAR(2048) NOT NULL,
+  UNIQUE (Name, Guild)
+);
+CREATE TABLE IF NOT EXISTS polloptions (
+  Poll BIGINT NOT NULL,
+  ` + "`Index`" + ` BIGINT NOT NULL,
+  ` + "`Option`" + ` VARCHAR(128) NOT NULL COLLATE NOCASE,
+  PRIMARY KEY (Poll, ` + "`Index`" + `),
+  UNIQUE (` + "`Option`" + `, Poll)
+);
+CREATE TABLE IF NOT EXISTS schedule (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  Guild BIGINT NOT NULL,
+  Date DATETIME NOT NULL,
+  RepeatInterval TINYINT DEFAULT NULL,
+  ` + "`Repeat`" + ` INTEGER DEFAULT NULL,
+  Type TINYINT NOT NULL,
+  Data TEXT NOT NULL,
+  Channel BIGINT DEFAULT NULL,
+  Recurrence VARCHAR(1024) DEFAULT NULL,
+  RSVPMessage BIGINT DEFAULT NULL,
+  State TINYINT NOT NULL DEFAULT 0,
+  Attempts INTEGER NOT NULL DEFAULT 0,
+  RetryAt DATETIME DEFAULT NULL,
+  LastError VARCHAR(1024) DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS INDEX_GUILD_DATE_TYPE ON schedule (Date, Guild, Type);
+CREATE INDEX IF NOT EXISTS INDEX_GUILD ON schedule (Guild);
+CREATE TABLE IF NOT EXISTS rsvps (
+  Event BIGINT NOT NULL,
+  User BIGINT NOT NULL,
+  Guild BIGINT NOT NULL,
+  Reminded DATETIME NOT NULL,
+  PRIMARY KEY (Event, User)
+);
+CREATE INDEX IF NOT EXISTS INDEX_RSVPS_GUILD ON rsvps (Guild);
+CREATE TABLE IF NOT EXISTS transcripts (
+  Season INTEGER NOT NULL,
+  Episode INTEGER NOT NULL,
+  Line INTEGER NOT NULL,
+  Speaker VARCHAR(64) NOT NULL COLLATE NOCASE,
+  Text VARCHAR(2000) NOT NULL,
+  PRIMARY KEY (Season, Episode, Line)
+);
+CREATE TABLE IF NOT EXISTS votes (
+  Poll BIGINT NOT NULL,
+  User BIGINT NOT NULL,
+  ` + "`Option`" + ` BIGINT NOT NULL,
+  PRIMARY KEY (Poll, User)
+);
+CREATE VIEW IF NOT EXISTS randomwords AS SELECT Phrase FROM markov_transcripts WHERE Phrase NOT IN ('.', '!', '?', 'the', 'of', 'a', 'to', 'too', 'as', 'at', 'an', 'am', 'and', 'be', 'he', 'she', '');
+CREATE TRIGGER IF NOT EXISTS chatlog_before_update BEFORE UPDATE ON chatlog FOR EACH ROW BEGIN
+  INSERT OR REPLACE INTO editlog (ID, Timestamp, Author, Message, Channel, Everyone, Guild)
+  VALUES (OLD.ID, OLD.Timestamp, OLD.Author, OLD.Message, OLD.Channel, OLD.Everyone, OLD.Guild);
+END;
+CREATE TRIGGER IF NOT EXISTS itemtags_after_delete AFTER DELETE ON itemtags FOR EACH ROW BEGIN
+  DELETE FROM items WHERE ID = OLD.Item AND NOT EXISTS (SELECT 1 FROM itemtags WHERE Item = OLD.Item);
+END;
+CREATE TRIGGER IF NOT EXISTS polloptions_before_delete BEFORE DELETE ON polloptions FOR EACH ROW BEGIN
+  DELETE FROM votes WHERE Poll = OLD.Poll AND ` + "`Option`" + ` = OLD.` + "`Index`" + `;
+END;
+CREATE TRIGGER IF NOT EXISTS polls_before_delete BEFORE DELETE ON polls FOR EACH ROW BEGIN
+  DELETE FROM polloptions WHERE Poll = OLD.ID;
+END;
+CREATE TRIGGER IF NOT EXISTS tags_before_delete BEFORE DELETE ON tags FOR EACH ROW BEGIN
+  DELETE FROM itemtags WHERE Tag = OLD.ID;
+END;
+CREATE TRIGGER IF NOT EXISTS users_before_delete BEFORE DELETE ON users FOR EACH ROW BEGIN
+  DELETE FROM aliases WHERE User = OLD.ID;
+  DELETE FROM chatlog WHERE Author = OLD.ID;
+  DELETE FROM debuglog WHERE User = OLD.ID;
+  DELETE FROM editlog WHERE Author = OLD.ID;
+  DELETE FROM votes WHERE User = OLD.ID;
+END;
+`
+
+// sqliteStatements overrides the MySQL queries from LoadStatements that can't be translated by sqliteDialect. An
+// empty string means the statement is a stored routine that sqliteStorage implements in Go.
+var sqliteStatements = map[string]string{
+	"CALL AddChat(?,?,?,?,?,?)":  "",
+	"CALL AddUser(?,?,?,?,?)":    "",
+	"CALL AddMember(?,?,?,?)":    "",
+	"SELECT AddMarkov(?,?,?,?)":  "",
+	"SELECT GetMarkovLine(?)":    "",
+	"SELECT GetMarkovLine2(?,?)": "",
+	"CALL ResetMarkov()":         "",
+	"CALL RemoveSchedule(?)":     "",
+	"SELECT AddItem(?)":          "",
+	"SELECT U.ID, U.Username, U.Discriminator, U.Avatar, U.LastSeen, M.Nickname, M.FirstSeen, M.FirstMessage FROM members M RIGHT OUTER JOIN users U ON U.ID = M.ID WHERE M.ID = ? AND M.Guild = ?": "SELECT U.ID, U.Username, U.Discriminator, U.Avatar, U.LastSeen, M.Nickname, M.FirstSeen, M.FirstMessage FROM users U LEFT OUTER JOIN members M ON U.ID = M.ID WHERE M.ID = ? AND M.Guild = ?",
+	"SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM transcripts WHERE Text != ''))":                         "SELECT ABS(RANDOM()) % MAX(1, (SELECT COUNT(*) FROM transcripts WHERE Text != ''))",
+	"SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM transcripts WHERE Speaker != 'ACTION' AND Text != ''))": "SELECT ABS(RANDOM()) % MAX(1, (SELECT COUNT(*) FROM transcripts WHERE Speaker != 'ACTION' AND Text != ''))",
+	"SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM transcripts WHERE Speaker = ? AND Text != ''))":         "SELECT ABS(RANDOM()) % MAX(1, (SELECT COUNT(*) FROM transcripts WHERE Speaker = ? AND Text != ''))",
+	"SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM markov_transcripts_speaker))":                           "SELECT ABS(RANDOM()) % MAX(1, (SELECT COUNT(*) FROM markov_transcripts_speaker))",
+	"SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM members WHERE Guild = ?))":                              "SELECT ABS(RANDOM()) % MAX(1, (SELECT COUNT(*) FROM members WHERE Guild = ?))",
+	"SELECT FLOOR(RAND()*(SELECT COUNT(*) FROM randomwords))":                                          "SELECT ABS(RANDOM()) % MAX(1, (SELECT COUNT(*) FROM randomwords))",
+	"INSERT INTO debuglog (Type, User, Message, Timestamp, Guild) VALUE(?, ?, ?, UTC_TIMESTAMP(), ?)":  "INSERT INTO debuglog (Type, User, Message, Timestamp, Guild) VALUES (?, ?, ?, datetime('now'), ?)",
+	"SELECT Location FROM timezones WHERE Location LIKE ? AND (Offset = ? OR DST = ?)":                 "SELECT Location FROM timezones WHERE Location LIKE ? AND (`Offset` = ? OR DST = ?)",
+	"INSERT INTO votes (Poll, User, `Option`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `Option` = ?":   "INSERT INTO votes (Poll, User, `Option`) VALUES (?, ?, ?) ON CONFLICT(Poll, User) DO UPDATE SET `Option` = ?",
+	"DELETE M FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID WHERE M.Item = ? AND T.Guild = ?":      "DELETE FROM itemtags WHERE Item = ? AND Tag IN (SELECT ID FROM tags WHERE Guild = ?)",
+	"SELECT CONCAT('Chatlog: ', (SELECT COUNT(*) FROM chatlog), ' rows', '\nEditlog: ', (SELECT COUNT(*) FROM editlog), ' rows',  '\nAliases: ', (SELECT COUNT(*) FROM aliases), ' rows',  '\nDebuglog: ', (SELECT COUNT(*) FROM debuglog), ' rows',  '\nUsers: ', (SELECT COUNT(*) FROM users), ' rows',  '\nSchedule: ', (SELECT COUNT(*) FROM schedule), ' rows \nMembers: ', (SELECT COUNT(*) FROM members), ' rows \nPolls: ', (SELECT COUNT(*) FROM polls), ' rows \nItems: ', (SELECT COUNT(*) FROM items), ' rows \nTags: ', (SELECT COUNT(*) FROM tags), ' rows \nitemtags: ', (SELECT COUNT(*) FROM itemtags), ' rows');": "SELECT 'Chatlog: ' || (SELECT COUNT(*) FROM chatlog) || ' rows' || char(10) || 'Editlog: ' || (SELECT COUNT(*) FROM editlog) || ' rows' || char(10) || 'Aliases: ' || (SELECT COUNT(*) FROM aliases) || ' rows' || char(10) || 'Debuglog: ' || (SELECT COUNT(*) FROM debuglog) || ' rows' || char(10) || 'Users: ' || (SELECT COUNT(*) FROM users) || ' rows' || char(10) || 'Schedule: ' || (SELECT COUNT(*) FROM schedule) || ' rows' || char(10) || 'Members: ' || (SELECT COUNT(*) FROM members) || ' rows' || char(10) || 'Polls: ' || (SELECT COUNT(*) FROM polls) || ' rows' || char(10) || 'Items: ' || (SELECT COUNT(*) FROM items) || ' rows' || char(10) || 'Tags: ' || (SELECT COUNT(*) FROM tags) || ' rows' || char(10) || 'itemtags: ' || (SELECT COUNT(*) FROM itemtags) || ' rows'",
+}
+
+// sqliteDialect rewrites the MySQL functions and syntax that show up in ad-hoc queries throughout the bot
+var sqliteDialect = strings.NewReplacer(
+	"DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)", "datetime('now', '-' || ? || ' seconds')",
+	"UTC_TIMESTAMP()", "datetime('now')",
+	"INSERT IGNORE", "INSERT OR IGNORE",
+	"RAND()", "RANDOM()",
+)
+
+func (s *sqliteStorage) Driver() string { return "sqlite" }
+
+func (s *sqliteStorage) Open(conn string) (*sql.DB, error) {
+	dsn := conn
+	if strings.Contains(dsn, "?") {
+		dsn += "&"
+	} else {
+		dsn += "?"
+	}
+	dsn += "_pragma=busy_timeout(10000)&_txlock=immediate&_time_format=sqlite"
+	memory := conn == ":memory:" || strings.Contains(conn, "mode=memory")
+	if !memory {
+		dsn += "&_pragma=journal_mode(wal)"
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return db, err
+	}
+	if memory {
+		db.SetMaxOpenConns(1) // Every connection to :memory: gets its own database, so we can only ever have one
+	}
+	if _, err = db.Exec(sqliteSchema); err != nil {
+		return db, err
+	}
+	if err = sqliteAddColumns(db); err != nil {
+		return db, err
+	}
+
+	var count int
+	if err = db.QueryRow("SELECT COUNT(*) FROM timezones").Scan(&count); err == nil && count == 0 {
+		if _, staterr := os.Stat("sweetiebot_tz.sql"); staterr == nil {
+			err = ExecuteSQLFile(db, "sweetiebot_tz.sql")
+		}
+	}
+	if err != nil {
+		return db, err
+	}
+
+	s.stop = make(chan struct{})
+	go s.cleanLoop(db, s.stop)
+	return db, nil
+}
+
+// sqliteColumns lists columns that were added to a table after it was created. CREATE TABLE IF NOT EXISTS won't add
+// them to an existing database, so they are added by sqliteAddColumns instead.
+var sqliteColumns = []struct{ table, column, definition string }{
+	{"schedule", "Channel", "BIGINT DEFAULT NULL"},
+	{"schedule", "Recurrence", "VARCHAR(1024) DEFAULT NULL"},
+	{"schedule", "RSVPMessage", "BIGINT DEFAULT NULL"},
+	{"schedule", "State", "TINYINT NOT NULL DEFAULT 0"},
+	{"schedule", "Attempts", "INTEGER NOT NULL DEFAULT 0"},
+	{"schedule", "RetryAt", "DATETIME DEFAULT NULL"},
+	{"schedule", "LastError", "VARCHAR(1024) DEFAULT NULL"},
+}
+
+func sqliteAddColumns(db *sql.DB) error {
+	for _, v := range sqliteColumns {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", v.table, v.column).Scan(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			if _, err := db.Exec("ALTER TABLE " + v.table + " ADD COLUMN " + v.column + " " + v.definition); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStorage) Close() {
+	if s.stop != nil {
+		s.once.Do(func() { close(s.stop) })
+	}
+}
+
+func (s *sqliteStorage) Statement(query string) string {
+	if r, ok := sqliteStatements[query]; ok {
+		return r
+	}
+	return sqliteDialect.Replace(query)
+}
+
+func (s *sqliteStorage) StandardErr(err error) error {
+	if sqliteErr, ok := err.(*sqlite.Error); ok {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY, sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+			return ErrDuplicateEntry
+		}
+		switch sqliteErr.Code() & 0xff { // Primary result code
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			return ErrLockWaitTimeout
+		}
+	}
+	return err
+}
+
+// sqliteTx runs fn inside a transaction, which stands in for the BEGIN ... END block of a stored procedure
+func sqliteTx(db *BotDB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStorage) AddChat(db *BotDB, id uint64, author uint64, message string, channel uint64, everyone bool, guild uint64) error {
+	return sqliteTx(db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO users (ID, Username, Avatar, LastSeen, LastNameChange) VALUES (?, '', '', datetime('now'), datetime('now')) ON CONFLICT(ID) DO UPDATE SET LastSeen = datetime('now')", author)
+		if err != nil {
+			return err
+		}
+		// The upsert prevents a race condition from causing a serious error, and fires chatlog_before_update
+		_, err = tx.Exec("INSERT INTO chatlog (ID, Author, Message, Timestamp, Channel, Everyone, Guild) VALUES (?, ?, ?, datetime('now'), ?, ?, ?) ON CONFLICT(ID) DO UPDATE SET Message = excluded.Message, Timestamp = excluded.Timestamp, Everyone = excluded.Everyone", id, author, message, channel, everyone, guild)
+		return err
+	})
+}
+
+func (s *sqliteStorage) AddUser(db *BotDB, id uint64, username string, discriminator int, avatar string, isonline bool) error {
+	return sqliteTx(db, func(tx *sql.Tx) error {
+		var oldname string
+		err := tx.QueryRow("SELECT Username FROM users WHERE ID = ?", id).Scan(&oldname)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO users (ID, Username, Discriminator, Avatar, LastSeen, LastNameChange) VALUES (?, ?, ?, ?, datetime('now'), datetime('now'))
+ON CONFLICT(ID) DO UPDATE SET
+Username = CASE WHEN excluded.Username = '' THEN Username ELSE excluded.Username END,
+Discriminator = CASE WHEN excluded.Discriminator = 0 THEN Discriminator ELSE excluded.Discriminator END,
+Avatar = CASE WHEN excluded.Avatar = '' THEN Avatar ELSE excluded.Avatar END,
+LastSeen = CASE WHEN ? THEN excluded.LastSeen ELSE LastSeen END,
+LastNameChange = CASE WHEN excluded.Username = '' OR excluded.Username = Username THEN LastNameChange ELSE excluded.LastNameChange END`, id, username, discriminator, avatar, isonline)
+		if err != nil || len(username) == 0 {
+			return err
+		}
+
+		if len(oldname) > 0 {
+			_, err = tx.Exec("INSERT INTO aliases (User, Alias, Duration, Timestamp) VALUES (?, ?, 0, datetime('now')) ON CONFLICT(User, Alias) DO UPDATE SET Duration = Duration + (strftime('%s', 'now') - strftime('%s', Timestamp)), Timestamp = datetime('now')", id, oldname)
+			if err != nil {
+				return err
+			}
+		}
+		if username != oldname {
+			_, err = tx.Exec("INSERT INTO aliases (User, Alias, Duration, Timestamp) VALUES (?, ?, 0, datetime('now')) ON CONFLICT(User, Alias) DO UPDATE SET Timestamp = datetime('now')", id, username)
+		}
+		return err
+	})
+}
+
+func (s *sqliteStorage) AddMember(db *BotDB, id uint64, guild uint64, firstseen time.Time, nickname string) error {
+	_, err := db.db.Exec("INSERT INTO members (ID, Guild, FirstSeen, Nickname) VALUES (?, ?, ?, ?) ON CONFLICT(ID, Guild) DO UPDATE SET FirstSeen = MIN(excluded.FirstSeen, FirstSeen), Nickname = excluded.Nickname", id, guild, firstseen.UTC(), nickname)
+	return err
+}
+
+func (s *sqliteStorage) AddItem(db *BotDB, item string) (uint64, error) {
+	var id uint64
+	err := sqliteTx(db, func(tx *sql.Tx) error {
+		err := tx.QueryRow("SELECT ID FROM items WHERE Content = ?", item).Scan(&id)
+		if err != sql.ErrNoRows {
+			return err
+		}
+		r, err := tx.Exec("INSERT INTO items (Content) VALUES (?)", item)
+		if err != nil {
+			return err
+		}
+		last, err := r.LastInsertId()
+		id = uint64(last)
+		return err
+	})
+	return id, err
+}
+
+func (s *sqliteStorage) AddMarkov(db *BotDB, last uint64, last2 uint64, speaker string, text string) (uint64, error) {
+	var id uint64
+	err := sqliteTx(db, func(tx *sql.Tx) error {
+		var speakerid uint64
+		_, err := tx.Exec("INSERT INTO markov_transcripts_speaker (Speaker) VALUES (?) ON CONFLICT(Speaker) DO NOTHING", speaker)
+		if err == nil {
+			err = tx.QueryRow("SELECT ID FROM markov_transcripts_speaker WHERE Speaker = ?", speaker).Scan(&speakerid)
+		}
+		if err == nil {
+			_, err = tx.Exec("INSERT INTO markov_transcripts (SpeakerID, Phrase) VALUES (?, ?) ON CONFLICT(SpeakerID, Phrase) DO NOTHING", speakerid, text)
+		}
+		if err == nil {
+			err = tx.QueryRow("SELECT ID FROM markov_transcripts WHERE SpeakerID = ? AND Phrase = ?", speakerid, text).Scan(&id)
+		}
+		if err == nil {
+			_, err = tx.Exec("INSERT INTO markov_transcripts_map (Prev, Prev2, Next) VALUES (?, ?, ?) ON CONFLICT(Prev, Next, Prev2) DO UPDATE SET Count = Count + 1", last, last2, id)
+		}
+		return err
+	})
+	return id, err
+}
+
+// sqliteMarkov walks the markov chain starting from prev (and prev2, if order2 is true) using the same algorithm
+// as GetMarkovLine and GetMarkovLine2, and returns the line in the same "line|prev|prev2" format.
+func sqliteMarkov(db *sql.DB, prev uint64, prev2 uint64, order2 bool) (sql.NullString, error) {
+	filter := "Prev = ?"
+	if order2 {
+		filter += " AND Prev2 = ?"
+	}
+	params := func() []interface{} {
+		if order2 {
+			return []interface{}{prev, prev2}
+		}
+		return []interface{}{prev}
+	}
+	exists := func() (bool, error) {
+		var i int
+		err := db.QueryRow("SELECT COUNT(*) FROM markov_transcripts_map WHERE "+filter, params()...).Scan(&i)
+		return i > 0, err
+	}
+	next := func() (uint64, error) { // Picks a random next word, weighted by how often it follows the previous one
+		q, err := db.Query("SELECT Next, Count FROM markov_transcripts_map WHERE "+filter, params()...)
+		if err != nil {
+			return 0, err
+		}
+		defer q.Close()
+		type weighted struct {
+			next  uint64
+			count int64
+		}
+		options := []weighted{}
+		var sum int64
+		for q.Next() {
+			var w weighted
+			if err = q.Scan(&w.next, &w.count); err != nil {
+				return 0, err
+			}
+			sum += w.count
+			options = append(options, w)
+		}
+		if len(options) == 0 {
+			return 0, sql.ErrNoRows
+		}
+		weight := int64(float64(sum-1)*rand.Float64()+0.5) + 1
+		var t int64
+		for _, w := range options {
+			t += w.count
+			if t >= weight {
+				return w.next, nil
+			}
+		}
+		return options[len(options)-1].next, nil
+	}
+	word := func(id uint64) (speakerid uint64, phrase string, err error) {
+		err = db.QueryRow("SELECT SpeakerID, Phrase FROM markov_transcripts WHERE ID = ?", id).Scan(&speakerid, &phrase)
+		return
+	}
+	result := func(line string) sql.NullString {
+		line += "|" + SBitoa(prev)
+		if order2 {
+			line += "|" + SBitoa(prev2)
+		}
+		return sql.NullString{String: line, Valid: true}
+	}
+
+	if ok, err := exists(); err != nil || !ok {
+		return sql.NullString{String: "|", Valid: true}, err
+	}
+
+	n, err := next()
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	prev2 = prev
+	prev = n
+	speakerid, phrase, err := word(prev)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	var speaker string
+	if err = db.QueryRow("SELECT Speaker FROM markov_transcripts_speaker WHERE ID = ?", speakerid).Scan(&speaker); err != nil {
+		return sql.NullString{}, err
+	}
+
+	var line string
+	if speaker == "ACTION" {
+		if len(phrase) == 0 {
+			return result(""), nil
+		}
+		line = "[" + phrase
+	} else {
+		line = "**" + speaker + ":** " + markovCapitalize(phrase)
+	}
+
+	for i := 0; i <= 300; i++ {
+		if ok, err := exists(); err != nil || !ok {
+			break
+		}
+		capitalize := phrase == "." || phrase == "!" || phrase == "?"
+		n, err := next()
+		if err != nil {
+			break
+		}
+		ns, nphrase, err := word(n)
+		if err != nil || ns != speakerid {
+			break
+		}
+		prev2 = prev
+		prev = n
+		phrase = nphrase
+
+		switch {
+		case phrase == "." || phrase == "!" || phrase == "?" || phrase == ",":
+			line += phrase
+		case capitalize:
+			line += " " + markovCapitalize(phrase)
+		default:
+			line += " " + phrase
+		}
+	}
+
+	if speaker == "ACTION" {
+		line += "]"
+	}
+	return result(line), nil
+}
+
+func markovCapitalize(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	if n == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[n:]
+}
+
+func (s *sqliteStorage) GetMarkovLine(db *BotDB, last uint64) (sql.NullString, error) {
+	return sqliteMarkov(db.db, last, 0, false)
+}
+
+func (s *sqliteStorage) GetMarkovLine2(db *BotDB, last uint64, last2 uint64) (sql.NullString, error) {
+	return sqliteMarkov(db.db, last, last2, true)
+}
+
+// addRepeatInterval adds repeat units of the given repeat interval (see ParseRepeatInterval) to t, clamping to the
+// end of the month like MySQL's DATE_ADD does.
+func addRepeatInterval(t time.Time, interval uint8, repeat int) time.Time {
+	switch interval {
+	case 1:
+		return t.Add(time.Duration(repeat) * time.Second)
+	case 2:
+		return t.Add(time.Duration(repeat) * time.Minute)
+	case 3:
+		return t.Add(time.Duration(repeat) * time.Hour)
+	case 4:
+		return t.AddDate(0, 0, repeat)
+	case 5:
+		return t.AddDate(0, 0, repeat*7)
+	case 6:
+		return addMonthsClamped(t, repeat)
+	case 7:
+		return addMonthsClamped(t, repeat*3)
+	case 8:
+		return addMonthsClamped(t, repeat*12)
+	}
+	return t
+}
+
+func addMonthsClamped(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location()).AddDate(0, months, 0)
+	last := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+func (s *sqliteStorage) RemoveSchedule(db *BotDB, id uint64) error {
+	return sqliteTx(db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM schedule WHERE ID = ? AND Date > datetime('now')", id)
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM schedule WHERE ID = ? AND `Repeat` IS NULL AND RepeatInterval IS NULL", id)
+		}
+		if err != nil {
+			return err
+		}
+
+		var date time.Time
+		var interval sql.NullInt64
+		var repeat sql.NullInt64
+		err = tx.QueryRow("SELECT Date, RepeatInterval, `Repeat` FROM schedule WHERE ID = ?", id).Scan(&date, &interval, &repeat)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil || !interval.Valid || !repeat.Valid {
+			return err
+		}
+		_, err = tx.Exec("UPDATE schedule SET Date = ? WHERE ID = ?", addRepeatInterval(date.UTC(), uint8(interval.Int64), int(repeat.Int64)), id)
+		return err
+	})
+}
+
+func (s *sqliteStorage) RemoveGuild(db *BotDB, guild uint64) error {
+	return sqliteTx(db, func(tx *sql.Tx) error {
+		for _, table := range []string{"members", "polls", "schedule", "chatlog", "debuglog", "editlog", "tags", "cases", "lockdown", "filterhits", "rsvps"} {
+			if _, err := tx.Exec("DELETE FROM "+table+" WHERE Guild = ?", guild); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStorage) ResetMarkov(db *BotDB) error {
+	return sqliteTx(db, func(tx *sql.Tx) error {
+		statements := []string{
+			"DELETE FROM markov_transcripts",
+			"DELETE FROM markov_transcripts_speaker",
+			"DELETE FROM markov_transcripts_map",
+			"DELETE FROM sqlite_sequence WHERE name IN ('markov_transcripts', 'markov_transcripts_speaker')",
+			"INSERT INTO markov_transcripts_speaker (Speaker) VALUES ('ACTION')",
+			"INSERT INTO markov_transcripts (ID, SpeakerID, Phrase) VALUES (0, 1, '')",
+		}
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// cleanLoop replaces the CleanChatlog, CleanDebugLog, CleanUsers and CleanAliases events, since SQLite has no
+// event scheduler.
+func (s *sqliteStorage) cleanLoop(db *sql.DB, stop chan struct{}) {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	for {
+		sqliteClean(db)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sqliteClean(db *sql.DB) {
+	db.Exec("DELETE FROM chatlog WHERE Timestamp < datetime('now', '-7 days')")
+	db.Exec("DELETE FROM editlog WHERE Timestamp < datetime('now', '-7 days')")
+	db.Exec("DELETE FROM debuglog WHERE Timestamp < datetime('now', '-8 days')")
+	db.Exec("DELETE FROM users WHERE ID NOT IN (SELECT DISTINCT ID FROM members)")
+
+	q, err := db.Query("SELECT User FROM aliases GROUP BY User HAVING COUNT(Alias) > 10")
+	if err != nil {
+		return
+	}
+	users := []uint64{}
+	for q.Next() {
+		var u uint64
+		if q.Scan(&u) == nil {
+			users = append(users, u)
+		}
+	}
+	q.Close()
+
+	for _, u := range users {
+		db.Exec("DELETE FROM aliases WHERE User = ? AND Alias IN (SELECT A.Alias FROM aliases A INNER JOIN users U ON A.User = U.ID WHERE A.User = ? AND A.Alias != U.Username ORDER BY A.Duration DESC LIMIT -1 OFFSET 10)", u, u)
+	}
+}
@@ -0,0 +1,371 @@
+package sweetiebot
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func mockSQLiteDB(t *testing.T) *BotDB {
+	db, err := dbLoad(NewLogger(LogNone, nil).With(nil), "sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.LoadStatements(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLiteStatements(t *testing.T) {
+	t.Parallel()
+	db := mockSQLiteDB(t)
+	defer db.Close()
+
+	Check(db.sqlAddMessage == nil, true, t)
+	Check(db.sqlGetMarkovLine == nil, true, t)
+	Check(db.sqlGetUser != nil, true, t)
+	Check(db.sqlAddVote != nil, true, t)
+	Check(db.sqlGetTableCounts != nil, true, t)
+}
+
+func TestSQLiteUsers(t *testing.T) {
+	t.Parallel()
+	db := mockSQLiteDB(t)
+	defer db.Close()
+
+	db.AddUser(1, "Sweetie", 1234, "avatar", true)
+	db.AddMember(1, 2, time.Now().UTC(), "nick")
+	db.AddUser(1, "Belle", 0, "", false)
+	u, _, _, _ := db.GetUser(1)
+	Check(u.Username, "Belle", t)
+	Check(u.Discriminator, "1234", t)
+	Check(u.Avatar, "avatar", t)
+	Check(len(db.GetAliases(1)), 2, t)
+	Check(len(db.FindGuildUsers("nick", 10, 0, 2)), 1, t)
+
+	db.AddMessage(5, 1, "first", 3, false, 2)
+	db.AddMessage(5, 1, "edited", 3, false, 2)
+	var edits int
+	db.db.QueryRow("SELECT COUNT(*) FROM editlog WHERE ID = 5").Scan(&edits)
+	Check(edits, 1, t)
+	Check(db.CountNewUsers(60, 2), 1, t)
+}
+
+func TestSQLiteChatlog(t *testing.T) {
+	t.Parallel()
+	db := mockSQLiteDB(t)
+	defer db.Close()
+
+	now := time.Now().UTC()
+	db.AddUser(1, "Sweetie", 0, "", true)
+	db.AddMessage(TimeSnowflake(now.Add(-2*time.Hour)), 1, "too old", 3, false, 2)
+	db.AddMessage(TimeSnowflake(now.Add(-time.Minute)), 1, "first", 3, false, 2)
+	db.AddMessage(TimeSnowflake(now.Add(-time.Second)), 1, "second", 3, false, 2)
+	db.AddMessage(TimeSnowflake(now.Add(-time.Second))+1, 1, "other channel", 4, false, 2)
+	msgs := db.GetChatlog(2, 3, now.Add(-time.Hour), now, 10)
+	Check(len(msgs), 2, t)
+	Check(msgs[0].Content, "first", t)
+	Check(msgs[0].Author.Username, "Sweetie", t)
+	Check(msgs[1].Content, "second", t)
+	Check(GetTimestamp(msgs[1]).Unix(), now.Add(-time.Second).Unix(), t)
+	Check(len(db.GetChatlog(2, 3, now.Add(-time.Hour), now, 1)), 1, t)
+}
+
+func TestSQLiteLockdown(t *testing.T) {
+	t.Parallel()
+	db := mockSQLiteDB(t)
+	defer db.Close()
+
+	ends := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	Check(db.AddLockdown(2, LockdownState{Type: LockdownVerification, Value: 1, Ends: &ends}), nil, t)
+	Check(db.AddLockdown(2, LockdownState{Type: LockdownPermissions, Channel: 3, Overwrite: true, Allow: 1024, Deny: 2048, Ends: &ends}), nil, t)
+	Check(db.AddLockdown(2, LockdownState{Type: LockdownSlowmode, Channel: 3, Value: 5, Ends: &ends}), nil, t)
+	Check(db.AddLockdown(2, LockdownState{Type: LockdownVerification, Value: 3, Ends: &ends}), nil, t) // Doesn't replace the original level
+	Check(db.AddLockdown(4, LockdownState{Type: LockdownVerification, Value: 2}), nil, t)
+	states := db.GetLockdown(2)
+	Check(len(states), 3, t)
+	for _, v := range states {
+		switch v.Type {
+		case LockdownVerification:
+			Check(v.Value, 1, t)
+		case LockdownPermissions:
+			Check(v.Channel, uint64(3), t)
+			Check(v.Overwrite, true, t)
+			Check(v.Allow, 1024, t)
+			Check(v.Deny, 2048, t)
+		case LockdownSlowmode:
+			Check(v.Channel, uint64(3), t)
+			Check(v.Value, 5, t)
+		}
+		if CheckNot(v.Ends, (*time.Time)(nil), t) {
+			Check(v.Ends.Unix(), ends.Unix(), t)
+		}
+	}
+	Check(db.SetLockdownEnd(2, nil), nil, t)
+	Check(db.GetLockdown(2)[0].Ends, (*time.Time)(nil), t)
+	Check(db.RemoveLockdown(2, LockdownPermissions, 3), nil, t)
+	Check(len(db.GetLockdown(2)), 2, t)
+	Check(len(db.GetLockdown(4)), 1, t)
+	Check(db.RemoveLockdown(2, LockdownVerification, 0), nil, t)
+	Check(db.RemoveLockdown(2, LockdownSlowmode, 3), nil, t)
+	Check(len(db.GetLockdown(2)), 0, t)
+}
+
+func TestSQLiteFilterHits(t *testing.T) {
+	t.Parallel()
+	db := mockSQLiteDB(t)
+	defer db.Close()
+
+	number := uint64(7)
+	Check(db.AddFilterHit(2, "badwords", "heck", 5, nil), nil, t)
+	Check(db.AddFilterHit(2, "badwords", "heck", 6, &number), nil, t)
+	Check(db.AddFilterHit(2, "badwords", "darn", 5, nil), nil, t)
+	Check(db.AddFilterHit(2, "spoilers", "", 5, nil), nil, t)
+	Check(db.AddFilterHit(3, "badwords", "heck", 5, nil), nil, t)
+
+	ok, err := db.MarkFalsePositive(2, 7)
+	Check(err, nil, t)
+	Check(ok, true, t)
+	ok, err = db.MarkFalsePositive(2, 8)
+	Check(err, nil, t)
+	Check(ok, false, t)
+
+	stats := db.GetFilterStats(2, time.Hour)
+	if Check(len(stats), 3, t) {
+		Check(stats[0], FilterStat{"badwords", "heck", 2, 1}, t)
+		Check(stats[1], FilterStat{"badwords", "darn", 1, 0}, t)
+		Check(stats[2], FilterStat{"spoilers", "", 1, 0}, t)
+	}
+	positives := db.GetFalsePositives(2, 10)
+	if Check(len(positives), 1, t) {
+		Check(positives[0].Case, number, t)
+		Check(positives[0].Word, "heck", t)
+		Check(positives[0].User, uint64(6), t)
+	}
+	Check(len(db.GetFalsePositives(3, 10)), 0, t)
+	Check(len(db.GetFilterStats(3, time.Hour)), 1, t)
+}
+
+func TestSQLiteItems(t *testing.T) {
+	t.Parallel()
+	db := mockSQLiteDB(t)
+	defer db.Close()
+
+	id, err := db.AddItem("bucket")
+	Check(err, nil, t)
+	id2, _ := db.AddItem("bucket")
+	Check(id2, id, t)
+	Check(db.CreateTag("things", 2), nil, t)
+	Check(db.CreateTag("things", 2), ErrDuplicateEntry, t)
+	tag, _ := db.GetTag("things", 2)
+	Check(db.AddTag(id, tag), nil, t)
+	Check(db.GetItemTags(id, 2)[0], "things", t)
+	Check(db.RemoveItem(id, 2), nil, t)
+	_, err = db.GetItem("bucket")
+	CheckNot(err, nil, t) // itemtags_after_delete removes orphaned items
+}
+
+func TestSQLiteSchedule(t *testing.T) {
+	t.Parallel()
+	db := mockSQLiteDB(t)
+	defer db.Close()
+
+	past := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	Check(db.AddScheduleRepeat(2, past, 4, 1, 3, "repeat"), nil, t)
+	Check(db.AddSchedule(2, past, 3, "once"), nil, t)
+	events := db.GetSchedule(2)
+	Check(len(events), 2, t)
+	for _, e := range events {
+		Check(db.RemoveSchedule(e.ID), nil, t)
+	}
+	Check(len(db.GetSchedule(2)), 0, t)
+	events = db.GetEvents(2, 10)
+	Check(len(events), 1, t)
+	Check(events[0].Data, "repeat", t)
+	Check(events[0].Date.Equal(past.AddDate(0, 0, 1)), true, t)
+}
+
+func TestSQLiteRecurringSchedule(t *testing.T) {
+	t.Parallel()
+	db := mockSQLiteDB(t)
+	defer db.Close()
+
+	past := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	rule, err := ParseRecurrence("FREQ=DAILY;COUNT=2", nil)
+	Check(err, nil, t)
+	data := EventData{Mention: "<@&5>", Message: "standup"}
+	Check(db.AddScheduleEvent(2, past, EventRole, data, NewDiscordChannel(9), rule), nil, t)
+	events := db.GetSchedule(2)
+	Check(len(events), 1, t)
+	Check(events[0].Channel, NewDiscordChannel(9), t)
+	Check(events[0].Payload(), data, t)
+	Check(db.RemoveSchedule(events[0].ID), nil, t)
+
+	e := db.GetEvent(2, events[0].ID)
+	CheckNot(e, (*ScheduleEvent)(nil), t)
+	Check(e.Date.Equal(past.AddDate(0, 0, 1)), true, t)
+	Check(e.Rule().Count, 1, t)
+	_, err = db.sqlAdvanceSchedule.Exec(past, e.Recurrence, e.ID) // Make it due again
+	Check(err, nil, t)
+	Check(db.RemoveSchedule(e.ID), nil, t)
+	Check(db.GetEvent(2, e.ID), (*ScheduleEvent)(nil), t) // The count ran out
+}
+
+func TestSQLiteRSVPs(t *testing.T) {
+	t.Parallel()
+	db := mockSQLiteDB(t)
+	defer db.Close()
+
+	future := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	Check(db.AddScheduleEvent(2, future, EventGeneric, EventData{Message: "movie night"}, ChannelEmpty, nil), nil, t)
+	events := db.GetEvents(2, 10)
+	if !Check(len(events), 1, t) {
+		return
+	}
+	id := events[0].ID
+	added, err := db.AddRSVP(2, id, NewDiscordUser(10))
+	Check(err, nil, t)
+	Check(added, true, t)
+	added, _ = db.AddRSVP(2, id, NewDiscordUser(10))
+	Check(added, false, t)
+	db.AddRSVP(2, id, NewDiscordUser(11))
+	Check(db.CountRSVPs(id), 2, t)
+	rsvps := db.GetRSVPs(id)
+	if Check(len(rsvps), 2, t) {
+		Check(rsvps[0].Reminded.After(future.Add(-2*time.Hour)), true, t)
+	}
+	Check(db.SetRSVPReminded(id, NewDiscordUser(10), future), nil, t)
+	if rsvps = db.GetRSVPs(id); Check(len(rsvps), 2, t) {
+		Check(rsvps[1].User, NewDiscordUser(10), t)
+		Check(rsvps[1].Reminded.Equal(future), true, t)
+	}
+	removed, _ := db.RemoveRSVP(id, NewDiscordUser(11))
+	Check(removed, true, t)
+	removed, _ = db.RemoveRSVP(id, NewDiscordUser(11))
+	Check(removed, false, t)
+
+	Check(db.GetRSVPEvent(2, 99), (*ScheduleEvent)(nil), t)
+	Check(db.SetRSVPMessage(2, id, 99), nil, t)
+	Check(db.SetRSVPMessage(3, id, 98), nil, t) // Wrong guild
+	if e := db.GetRSVPEvent(2, 99); CheckNot(e, (*ScheduleEvent)(nil), t) {
+		Check(e.ID, id, t)
+	}
+	Check(db.GetRSVPEvent(3, 98), (*ScheduleEvent)(nil), t)
+
+	Check(db.RemoveSchedule(id), nil, t)
+	Check(db.CountRSVPs(id), 0, t)
+}
+
+func TestSQLiteEventStates(t *testing.T) {
+	t.Parallel()
+	db := mockSQLiteDB(t)
+	defer db.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	Check(db.AddScheduleEvent(2, now.Add(-time.Hour), EventBan, EventData{User: "5"}, ChannelEmpty, nil), nil, t)
+	jobs := db.GetSchedule(2)
+	if !Check(len(jobs), 1, t) {
+		return
+	}
+	Check(jobs[0].State, JobPending, t)
+	id := jobs[0].ID
+	Check(db.StartEvent(id, now.Add(5*time.Minute)), true, t)
+	Check(db.StartEvent(id, now.Add(5*time.Minute)), false, t)
+	Check(len(db.GetSchedule(2)), 0, t) // It's running
+
+	state, err := db.FailEvent(id, 1, "HTTP 500", now)
+	Check(err, nil, t)
+	Check(state, JobPending, t)
+	Check(len(db.GetSchedule(2)), 0, t) // It's waiting to be retried
+	state, _ = db.FailEvent(id, MaxEventAttempts, "HTTP 500", now)
+	Check(state, JobFailed, t)
+	Check(db.StartEvent(id, now.Add(5*time.Minute)), false, t)
+	failed := db.GetFailedEvents(2, 10)
+	if Check(len(failed), 1, t) {
+		Check(failed[0].Attempts, MaxEventAttempts, t)
+		Check(failed[0].Error, "HTTP 500", t)
+		Check(failed[0].Payload().User, DiscordUser("5"), t)
+	}
+	db.FailEvent(id, MaxEventAttempts, strings.Repeat("é", 1000), now)
+	if failed = db.GetFailedEvents(2, 10); Check(len(failed), 1, t) {
+		Check(utf8.ValidString(failed[0].Error), true, t)
+		Check(len(failed[0].Error) <= 1024, true, t)
+	}
+
+	ok, _ := db.RetryFailedEvent(3, id)
+	Check(ok, false, t)
+	ok, _ = db.RetryFailedEvent(2, id)
+	Check(ok, true, t)
+	Check(len(db.GetFailedEvents(2, 10)), 0, t)
+	if jobs = db.GetSchedule(2); Check(len(jobs), 1, t) {
+		Check(jobs[0].Attempts, 0, t)
+	}
+	Check(db.StartEvent(id, now.Add(5*time.Minute)), true, t)
+	Check(db.FinishEvent(id), nil, t)
+	Check(db.GetEvent(2, id), (*ScheduleEvent)(nil), t)
+}
+
+func TestSQLiteCases(t *testing.T) {
+	t.Parallel()
+	db := mockSQLiteDB(t)
+	defer db.Close()
+
+	event := uint64(7)
+	n, err := db.AddCase(2, 10, 11, CaseWarning, "first", 0, nil)
+	Check(err, nil, t)
+	Check(n, uint64(1), t)
+	n, _ = db.AddCase(2, 10, 11, CaseSilence, "second", time.Hour, &event)
+	Check(n, uint64(2), t)
+	n, _ = db.AddCase(3, 10, 11, CaseBan, "other guild", 0, nil)
+	Check(n, uint64(1), t)
+
+	c := db.GetCase(2, 2)
+	Check(c.Type, uint8(CaseSilence), t)
+	Check(c.Duration, time.Hour, t)
+	Check(*c.Schedule, event, t)
+	Check(db.GetCase(2, 1).Schedule == nil, true, t)
+	Check(db.GetCase(2, 3) == nil, true, t)
+
+	Check(db.SetCaseReason(2, 1, "edited"), nil, t)
+	Check(db.PardonCase(2, 1), nil, t)
+	cases := db.GetUserCases(2, 10, 10)
+	Check(len(cases), 2, t)
+	Check(cases[0].Number, uint64(2), t)
+	Check(cases[1].Reason, "edited", t)
+	Check(cases[1].Pardoned, true, t)
+	Check(cases[0].Pardoned, false, t)
+	Check(db.CountRecentCases(2, 10, CaseSilence, time.Hour), 1, t)
+	Check(db.CountRecentCases(2, 10, CaseWarning, time.Hour), 0, t) // Pardoned cases don't count
+	Check(db.CountRecentCases(3, 10, CaseSilence, time.Hour), 0, t)
+
+	Check(db.RemoveGuild(2), nil, t)
+	Check(len(db.GetUserCases(2, 10, 10)), 0, t)
+	Check(len(db.GetUserCases(3, 10, 10)), 1, t)
+}
+
+func TestSQLiteMarkov(t *testing.T) {
+	t.Parallel()
+	db := mockSQLiteDB(t)
+	defer db.Close()
+
+	Check(db.ResetMarkov(), nil, t)
+	a := db.AddMarkov(0, 0, "Sweetie", "hello")
+	b := db.AddMarkov(a, 0, "Sweetie", "there")
+	db.AddMarkov(b, a, "Sweetie", ".")
+	line, next := db.GetMarkovLine(0)
+	Check(line, "**Sweetie:** Hello there.", t)
+	CheckNot(next, uint64(0), t)
+}
+
+func TestAddRepeatInterval(t *testing.T) {
+	t.Parallel()
+	base := time.Date(2018, time.January, 31, 12, 0, 0, 0, time.UTC)
+
+	Check(addRepeatInterval(base, 1, 30), base.Add(30*time.Second), t)
+	Check(addRepeatInterval(base, 5, 2), base.AddDate(0, 0, 14), t)
+	Check(addRepeatInterval(base, 6, 1), time.Date(2018, time.February, 28, 12, 0, 0, 0, time.UTC), t)
+	Check(addRepeatInterval(base, 7, 1), time.Date(2018, time.April, 30, 12, 0, 0, 0, time.UTC), t)
+	Check(addRepeatInterval(base, 8, 2), time.Date(2020, time.January, 31, 12, 0, 0, 0, time.UTC), t)
+}
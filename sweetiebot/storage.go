@@ -0,0 +1,132 @@
+package sweetiebot
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Storage is a database backend for BotDB. It opens the connection, translates the statements BotDB prepares into
+// its own SQL dialect, and implements the stored procedures and functions from sweetiebot.sql.
+type Storage interface {
+	// Driver returns the name of the database/sql driver this backend uses
+	Driver() string
+	// Open connects to the database using the given connection string and makes sure the schema exists
+	Open(conn string) (*sql.DB, error)
+	// Close releases anything the backend holds on to besides the connection itself
+	Close()
+	// Statement translates a MySQL query into this backend's dialect. Returning an empty string tells BotDB not
+	// to prepare the statement because the backend implements it as a routine instead.
+	Statement(query string) string
+	// StandardErr maps backend specific errors to ErrDuplicateEntry and ErrLockWaitTimeout
+	StandardErr(err error) error
+
+	AddChat(db *BotDB, id uint64, author uint64, message string, channel uint64, everyone bool, guild uint64) error
+	AddUser(db *BotDB, id uint64, username string, discriminator int, avatar string, isonline bool) error
+	AddMember(db *BotDB, id uint64, guild uint64, firstseen time.Time, nickname string) error
+	AddItem(db *BotDB, item string) (uint64, error)
+	AddMarkov(db *BotDB, last uint64, last2 uint64, speaker string, text string) (uint64, error)
+	GetMarkovLine(db *BotDB, last uint64) (sql.NullString, error)
+	GetMarkovLine2(db *BotDB, last uint64, last2 uint64) (sql.NullString, error)
+	RemoveSchedule(db *BotDB, id uint64) error
+	RemoveGuild(db *BotDB, guild uint64) error
+	ResetMarkov(db *BotDB) error
+}
+
+// NewStorage returns the storage backend for the given driver name. An empty driver defaults to mysql.
+func NewStorage(driver string) (Storage, error) {
+	switch strings.ToLower(strings.TrimSpace(driver)) {
+	case "", "mysql", "mariadb":
+		return &mysqlStorage{}, nil
+	case "sqlite", "sqlite3":
+		return &sqliteStorage{}, nil
+	}
+	return nil, fmt.Errorf("Unknown database driver: %s", driver)
+}
+
+// mysqlStorage is the original MariaDB/MySQL backend, which relies on the stored procedures in sweetiebot.sql
+type mysqlStorage struct{}
+
+func (s *mysqlStorage) Driver() string { return "mysql" }
+
+func (s *mysqlStorage) Open(conn string) (*sql.DB, error) {
+	db, err := sql.Open("mysql", conn)
+	if err != nil {
+		return db, err
+	}
+	db.SetMaxOpenConns(70)
+	return db, db.Ping()
+}
+
+func (s *mysqlStorage) Close() {}
+
+func (s *mysqlStorage) Statement(query string) string { return query }
+
+func (s *mysqlStorage) StandardErr(err error) error {
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		switch mysqlErr.Number {
+		case 1062:
+			return ErrDuplicateEntry
+		case 1205:
+			return ErrLockWaitTimeout
+		}
+	}
+	return err
+}
+
+func (s *mysqlStorage) AddChat(db *BotDB, id uint64, author uint64, message string, channel uint64, everyone bool, guild uint64) error {
+	_, err := db.sqlAddMessage.Exec(id, author, message, channel, everyone, guild)
+	return err
+}
+
+func (s *mysqlStorage) AddUser(db *BotDB, id uint64, username string, discriminator int, avatar string, isonline bool) error {
+	_, err := db.sqlAddUser.Exec(id, username, discriminator, avatar, isonline)
+	return err
+}
+
+func (s *mysqlStorage) AddMember(db *BotDB, id uint64, guild uint64, firstseen time.Time, nickname string) error {
+	_, err := db.sqlAddMember.Exec(id, guild, firstseen, nickname)
+	return err
+}
+
+func (s *mysqlStorage) AddItem(db *BotDB, item string) (uint64, error) {
+	var id uint64
+	err := db.sqlAddItem.QueryRow(item).Scan(&id)
+	return id, err
+}
+
+func (s *mysqlStorage) AddMarkov(db *BotDB, last uint64, last2 uint64, speaker string, text string) (uint64, error) {
+	var id uint64
+	err := db.sqlAddMarkov.QueryRow(last, last2, speaker, text).Scan(&id)
+	return id, err
+}
+
+func (s *mysqlStorage) GetMarkovLine(db *BotDB, last uint64) (sql.NullString, error) {
+	var r sql.NullString
+	err := db.sqlGetMarkovLine.QueryRow(last).Scan(&r)
+	return r, err
+}
+
+func (s *mysqlStorage) GetMarkovLine2(db *BotDB, last uint64, last2 uint64) (sql.NullString, error) {
+	var r sql.NullString
+	err := db.sqlGetMarkovLine2.QueryRow(last, last2).Scan(&r)
+	return r, err
+}
+
+func (s *mysqlStorage) RemoveSchedule(db *BotDB, id uint64) error {
+	_, err := db.sqlRemoveSchedule.Exec(id)
+	return err
+}
+
+func (s *mysqlStorage) RemoveGuild(db *BotDB, guild uint64) error {
+	_, err := db.db.Exec("CALL RemoveGuild(?)", guild) // Only used on the rare occasion a guild is purged, so this isn't worth preparing
+	return err
+}
+
+func (s *mysqlStorage) ResetMarkov(db *BotDB) error {
+	_, err := db.sqlResetMarkov.Exec()
+	return err
+}
@@ -37,7 +37,7 @@ var urlregex = regexp.MustCompile("https?:\\/\\/(www\\.)?[-a-zA-Z0-9@:%._\\+~#=]
 var DiscordEpoch uint64 = 1420070400000
 
 // Current version of sweetiebot
-var BotVersion = Version{0, 9, 9, 9}
+var BotVersion = Version{0, 9, 9, 15}
 
 const (
 	MaxPublicLines  = 12
@@ -72,7 +72,15 @@ type SweetieBot struct {
 	Owner            DiscordUser
 	Token            string                          `json:"token"`
 	DBAuth           string                          `json:"dbauth"`
+	DBDriver         string                          `json:"dbdriver"` // Storage backend: "mysql" (default) or "sqlite", in which case dbauth is the database file
 	MainGuildID      DiscordGuild                    `json:"mainguildid"`
+	ShardCount       int                             `json:"shardcount"` // Number of gateway connections to open
+	Shards           []*DiscordGoSession             // The first shard is also DG, which receives private messages
+	LogLevel         LogLevel                        `json:"loglevel"`    // Entries less severe than this aren't logged at all
+	LogFile          string                          `json:"logfile"`     // If set, entries are also written to this file as JSON, one per line
+	LogMaxSize       int64                           `json:"logmaxsize"`  // Size of the log file in megabytes before it's rotated
+	LogMaxFiles      int                             `json:"logmaxfiles"` // Number of rotated log files to keep
+	Logger           *Logger                         `json:"-"`
 	DebugChannels    map[DiscordGuild]DiscordChannel `json:"debugchannels"`
 	quit             uint32                          // QuitNone means to keep running. QuitNow means to quit immediately. QuitRaid means to wait until no raids have occurred before quitting
 	Guilds           map[DiscordGuild]*GuildInfo
@@ -84,7 +92,10 @@ type SweetieBot struct {
 	StartTime        int64
 	MessageCount     uint32 // 32-bit so we can do atomic ops on a 32-bit platform
 	heartbeat        uint32 // perpetually incrementing heartbeat counter to detect deadlock
-	locknumber       uint32
+	shardBeats       []uint32
+	shardMissed      []uint32
+	shardLocks       []uint32       // The last lock each shard's heartbeat got past, so a deadlock can be traced
+	shardHeartbeat   []atomic.Value // The guild each shard's current heartbeat is processed on
 	loader           func(*GuildInfo) []Module
 	memberChan       chan *GuildInfo
 	deferChan        chan deferPair
@@ -93,6 +104,9 @@ type SweetieBot struct {
 	WebSecure        bool       `json:"websecure"`
 	WebDomain        string     `json:"webdomain"`
 	WebPort          string     `json:"webport"`
+	MetricsToken     string     `json:"metricstoken"` // If set, /metrics requires this as a bearer token, otherwise it is open to anyone
+	OAuthSecret      string     `json:"oauthsecret"`  // Client secret of the bot's application, which enables the web dashboard
+	Metrics          Metrics    `json:"-"`
 	EmptyGuild       *GuildInfo // Holds an empty GuildInfo for running server independent commands
 	UpdateLock       AtomicFlag
 }
@@ -102,12 +116,44 @@ func (sb *SweetieBot) IsMainGuild(info *GuildInfo) bool {
 	return sb.MainGuildID.Equals(info.ID)
 }
 
+// ShardID returns the index of the shard that receives events for a guild, which is decided by discord
+func (sb *SweetieBot) ShardID(guildID string) int {
+	if len(sb.Shards) < 2 {
+		return 0
+	}
+	return int((SBatoi(guildID) >> 22) % uint64(len(sb.Shards)))
+}
+
+// Shard returns the session of the shard a guild belongs to, which is the only session that has the guild in its state
+func (sb *SweetieBot) Shard(guildID string) *DiscordGoSession {
+	if len(sb.Shards) < 2 {
+		return sb.DG
+	}
+	return sb.Shards[sb.ShardID(guildID)]
+}
+
+// stateChannel looks up a channel in the state of every shard, because a channel ID doesn't tell us which guild it's in
+func (sb *SweetieBot) stateChannel(id string) (ch *discordgo.Channel, err error) {
+	ch, err = sb.DG.State.Channel(id)
+	for i := 1; err != nil && i < len(sb.Shards); i++ {
+		ch, err = sb.Shards[i].State.Channel(id)
+	}
+	return
+}
+
+// UpdateStatus sets the bot's status on every shard, because each connection has its own presence
+func (sb *SweetieBot) UpdateStatus(status string) {
+	for _, shard := range sb.Shards {
+		shard.UpdateStatus(0, status)
+	}
+}
+
 // ChannelIsPrivate returns true if channel should be considered private, false otherwise.
 func (sb *SweetieBot) ChannelIsPrivate(channelID DiscordChannel) (*discordgo.Channel, bool) {
 	if channelID == "heartbeat" {
 		return nil, true
 	}
-	ch, err := sb.DG.State.Channel(channelID.String())
+	ch, err := sb.stateChannel(channelID.String())
 	if err == nil { // Because of the magic of web development, we can get a message BEFORE the "channel created" packet for the channel being used by that message.
 		return ch, typeIsPrivate(ch.Type)
 	}
@@ -119,7 +165,7 @@ func (sb *SweetieBot) ChannelIsPrivate(channelID DiscordChannel) (*discordgo.Cha
 
 // OnReady discord hook
 func (sb *SweetieBot) OnReady(s *discordgo.Session, r *discordgo.Ready) {
-	fmt.Println("Ready message receieved, waiting for guilds...")
+	sb.Logger.With(LogFields{"shard": s.ShardID}).Info("Ready message received, waiting for guilds...")
 	sb.SelfID = DiscordUser(r.User.ID)
 	sb.SelfAvatar = r.User.Avatar
 	sb.SelfName = r.User.Username
@@ -129,6 +175,9 @@ func (sb *SweetieBot) OnReady(s *discordgo.Session, r *discordgo.Ready) {
 			sb.AttachToGuild(G)
 		}
 	}
+	if s.ShardID != 0 {
+		return // Every shard gets a ready message, but we only need to look up the application once
+	}
 	app, err := s.Application("@me")
 	if err == nil {
 		sb.Owner = DiscordUser(app.Owner.ID)
@@ -176,8 +225,8 @@ func (sb *SweetieBot) AttachToGuild(g *discordgo.Guild) {
 		}
 	}
 
-	fmt.Println("Initializing " + g.Name)
 	guild = NewGuildInfo(sb, g)
+	guild.Logger().Debug("Initializing", g.Name)
 	for _, m := range g.Members {
 		if sb.SelfID.Equals(m.User.ID) {
 			guild.BotNick = m.Nick
@@ -186,12 +235,12 @@ func (sb *SweetieBot) AttachToGuild(g *discordgo.Guild) {
 	config, err := ioutil.ReadFile(g.ID + ".json")
 	disableall := false
 	if err != nil {
-		fmt.Println("New Guild Detected: " + g.Name)
+		guild.Logger().Info("New guild detected:", g.Name)
 
 		ch, e := sb.DG.UserChannelCreate(g.OwnerID)
 		if e == nil {
 			sb.DB.SetDefaultServer(SBatoi(g.OwnerID), SBatoi(g.ID)) // This ensures no one blows up another server by accident
-			perms, _ := guild.Bot.DG.UserPermissions(sb.SelfID, guild.ID)
+			perms, _ := guild.DG.UserPermissions(sb.SelfID, guild.ID)
 			warning := ""
 			if perms&discordgo.PermissionAdministrator != 0 {
 				warning = "\nWARNING: You have given " + guild.GetBotName() + " the Administrator role, which implicitly gives it all roles! " + guild.GetBotName() + " only needs Ban Members, Manage Roles and Manage Messages in order to function correctly." + warning
@@ -216,11 +265,11 @@ func (sb *SweetieBot) AttachToGuild(g *discordgo.Guild) {
 				sb.DG.ChannelMessageSend(ch.ID, warning)
 			}
 		} else {
-			fmt.Println("Error sending introductory PM: ", e)
+			guild.Logger().With(LogFields{"user": g.OwnerID}).Warning("Error sending introductory PM:", e)
 		}
 		disableall = true
 	} else if err := guild.MigrateSettings(config); err != nil {
-		fmt.Println("Error reading config file for "+g.Name+": ", err.Error())
+		guild.Logger().Error("Error reading config file for "+g.Name+":", err)
 	}
 
 	guild.Config.FillConfig()
@@ -253,7 +302,7 @@ func (sb *SweetieBot) AttachToGuild(g *discordgo.Guild) {
 		for _, v := range guild.Modules {
 			_, ok := guild.commands[CommandID(strings.ToLower(v.Name()))]
 			if ok {
-				fmt.Println("WARNING: Ambiguous module/command name ", v.Name())
+				guild.Logger().Warning("Ambiguous module/command name", v.Name())
 			}
 		}
 	}
@@ -267,8 +316,11 @@ func (sb *SweetieBot) AttachToGuild(g *discordgo.Guild) {
 		delete(guild.Config.Modules.CommandDisabled, "setup")
 		guild.SaveConfig()
 	}
+	if register := guild.prepareSlashCommands(); register != nil {
+		go register() // Don't hold up attaching to the guild while we wait for discord
+	}
 	if sb.IsMainGuild(guild) {
-		sb.DB.log = guild
+		sb.DB.log = guild.Logger()
 	}
 
 	debug := "."
@@ -290,12 +342,12 @@ func (sb *SweetieBot) AttachToGuild(g *discordgo.Guild) {
 			changes += "\n\nPlease consider donating $1 to help pay for hosting costs: " + PatreonURL
 		}
 	}
-	guild.Log(sb.AppName+" version ", BotVersion.String(), " successfully loaded on ", g.Name, debug, changes)
+	guild.Logger().Info(sb.AppName + " version " + BotVersion.String() + " successfully loaded on " + g.Name + debug + changes)
 }
 func (sb *SweetieBot) getChannelGuild(id string) *GuildInfo {
-	c, err := sb.DG.State.Channel(id)
+	c, err := sb.stateChannel(id)
 	if err != nil {
-		fmt.Println("Failed to get channel " + id)
+		sb.Logger.With(LogFields{"channel": id}).Warning("Failed to get channel")
 		return nil
 	}
 	return sb.getGuildFromID(c.GuildID)
@@ -311,7 +363,7 @@ func (sb *SweetieBot) getGuildFromID(id string) *GuildInfo {
 }
 func (sb *SweetieBot) getAddMsg(info *GuildInfo) string {
 	if info.Config.Basic.BotChannel != ChannelEmpty {
-		addch, adderr := sb.DG.State.Channel(info.Config.Basic.BotChannel.String())
+		addch, adderr := info.DG.State.Channel(info.Config.Basic.BotChannel.String())
 		if adderr == nil {
 			return fmt.Sprintf(" Try going to #%s instead.", addch.Name)
 		}
@@ -376,102 +428,144 @@ func (sb *SweetieBot) ProcessCommand(m *discordgo.Message, info *GuildInfo, t in
 			}
 		}
 		if ok {
-			if sb.DB.Status.Get() && !sb.SelfID.Equals(m.Author.ID) {
-				sb.DB.Audit(AuditTypeCommand, m.Author, m.Content, SBatoi(info.ID))
-			}
-			cmdname := CommandID(strings.ToLower(c.Info().Name))
-
-			ignore := false
-			if !private {
-				for _, h := range info.hooks.OnCommand {
-					if info.ProcessModule(channelID, h) {
-						ignore = ignore || h.OnCommand(info, m)
-					}
-				}
+			sb.runCommand(c, args[1:], indices[1:], m, info, t, isdebug, isfree, private, &messageOutput{channelID, private})
+		} else if !info.Config.Basic.IgnoreInvalidCommands {
+			info.SendError(channelID, "Sorry, "+args[0]+" is not a valid command.\nFor a list of valid commands, type !help.", t)
+		}
+	} else if info != nil { // If info is nil this was sent through a private message so just ignore it completely
+		for _, h := range info.hooks.OnMessageCreate {
+			if info.ProcessModule(DiscordChannel(m.ChannelID), h) {
+				h.OnMessageCreate(info, m)
 			}
+		}
+	}
+}
 
-			cch := info.Config.Modules.CommandChannels[cmdname]
-			if !private && len(cch) > 0 {
-				_, reverse := cch["!"]
-				_, ok = cch[channelID]
-				ignore = ignore || ok == reverse
-			}
-			bypass, err := info.UserCanUseCommand(DiscordUser(m.Author.ID), c, ignore) // Bypass is true for administrators, mods, and the bot owner
-			if err == errDisabled || err == errIgnored || err == errSilenced || err == errMainGuild {
-				return
-			}
-			if !isdebug && !isfree && !bypass && info.Config.Modules.CommandPerDuration > 0 { // debug channels aren't limited
-				if len(info.commandlimit.times) < info.Config.Modules.CommandPerDuration*2 { // Check if we need to re-allocate the array because the configuration changed
-					info.commandlimit.times = make([]int64, info.Config.Modules.CommandPerDuration*2, info.Config.Modules.CommandPerDuration*2)
-				}
-				if info.commandlimit.check(info.Config.Modules.CommandPerDuration, info.Config.Modules.CommandMaxDuration, t) { // if we've hit the saturation limit, post an error (which itself will only post if the error saturation limit hasn't been hit)
-					info.SendError(channelID, fmt.Sprintf("You can't input more than %v commands every %s!%s", info.Config.Modules.CommandPerDuration, TimeDiff(time.Duration(info.Config.Modules.CommandMaxDuration)*time.Second), sb.getAddMsg(info)), t)
-					return
-				}
-				info.commandlimit.append(t)
-			}
-			if err != nil {
-				info.SendError(channelID, err.Error(), t)
-				return
-			}
+// commandOutput delivers everything a command says back to whoever ran it, which lets commands sent as messages
+// and commands sent as interactions share the same permission checks and rate limits
+type commandOutput interface {
+	Error(info *GuildInfo, message string, t int64)                                                         // Tells the user why the command couldn't run
+	Ignore(info *GuildInfo, err error)                                                                      // Called when the command is silently discarded
+	Result(info *GuildInfo, m *discordgo.Message, result string, usepm bool, embed *discordgo.MessageEmbed) // Sends the command's result
+}
 
-			if c.Info().Silver && !info.Silver.Get() {
-				info.SendError(channelID, "That command is for Silver supporters only. Server owners can donate $1 a month to gain access: "+PatreonURL+". Visit the support channel for help if you already donated.", t)
-				return
-			}
+// messageOutput replies to a command in the channel it was sent in
+type messageOutput struct {
+	channelID DiscordChannel
+	private   bool
+}
 
-			cmdlimit := info.Config.Modules.CommandLimits[cmdname]
-			if !isfree && cmdlimit > 0 && !bypass {
-				info.commandLock.RLock()
-				lastcmd := info.commandLast[channelID][cmdname]
-				info.commandLock.RUnlock()
-				if !RateLimit(&lastcmd, cmdlimit, t) {
-					info.SendError(channelID, fmt.Sprintf("You can only run that command once every %s!%s", TimeDiff(time.Duration(cmdlimit)*time.Second), sb.getAddMsg(info)), t)
-					return
-				}
-				info.commandLock.Lock()
-				if len(info.commandLast[channelID]) == 0 {
-					info.commandLast[channelID] = make(map[CommandID]int64)
-				}
-				info.commandLast[channelID][cmdname] = t
-				info.commandLock.Unlock()
-			}
+func (o *messageOutput) Error(info *GuildInfo, message string, t int64) {
+	info.SendError(o.channelID, message, t)
+}
 
-			result, usepm, resultembed := c.Process(args[1:], m, indices[1:], info)
-			if len(result) > 0 || resultembed != nil {
-				targetchannel := channelID
-				if usepm && !private {
-					channel, err := sb.DG.UserChannelCreate(m.Author.ID)
-					info.LogError("Error opening private channel: ", err)
-					if err == nil {
-						targetchannel = DiscordChannel(channel.ID)
-						private = true
-						if rand.Float32() < 0.01 {
-							info.SendMessage(channelID, "Check your ~~privilege~~ Private Messages for my reply!")
-						} else {
-							info.SendMessage(channelID, "```\nCheck your Private Messages for my reply!```")
-						}
-					}
-				}
+func (o *messageOutput) Ignore(info *GuildInfo, err error) {}
 
-				if resultembed != nil {
-					if err := info.SendEmbed(targetchannel, resultembed); err != nil {
-						fmt.Println(err)
-					}
-				} else if err := info.SendMessage(targetchannel, result); err != nil {
-					fmt.Println(err)
-				}
+func (o *messageOutput) Result(info *GuildInfo, m *discordgo.Message, result string, usepm bool, embed *discordgo.MessageEmbed) {
+	if len(result) == 0 && embed == nil {
+		return
+	}
+	targetchannel := o.channelID
+	if usepm && !o.private {
+		channel, err := info.DG.UserChannelCreate(m.Author.ID)
+		info.Logger().LogError("Error opening private channel: ", err)
+		if err == nil {
+			targetchannel = DiscordChannel(channel.ID)
+			if rand.Float32() < 0.01 {
+				info.SendMessage(o.channelID, "Check your ~~privilege~~ Private Messages for my reply!")
+			} else {
+				info.SendMessage(o.channelID, "```\nCheck your Private Messages for my reply!```")
 			}
-		} else if !info.Config.Basic.IgnoreInvalidCommands {
-			info.SendError(channelID, "Sorry, "+args[0]+" is not a valid command.\nFor a list of valid commands, type !help.", t)
 		}
-	} else if info != nil { // If info is nil this was sent through a private message so just ignore it completely
-		for _, h := range info.hooks.OnMessageCreate {
-			if info.ProcessModule(DiscordChannel(m.ChannelID), h) {
-				h.OnMessageCreate(info, m)
+	}
+
+	var err error
+	if embed != nil {
+		err = info.SendEmbed(targetchannel, embed)
+	} else {
+		err = info.SendMessage(targetchannel, result)
+	}
+	if err != nil {
+		info.MessageLogger(m).hostOnly().Error("Failed to send command result:", err)
+	}
+}
+
+// runCommand checks that the author of m is allowed to run the command right now and, if they are, runs it and sends
+// the result to out. args and indices must not include the command name itself.
+func (sb *SweetieBot) runCommand(c Command, args []string, indices []int, m *discordgo.Message, info *GuildInfo, t int64, isdebug bool, isfree bool, private bool, out commandOutput) {
+	channelID := DiscordChannel(m.ChannelID)
+	if sb.DB.Status.Get() && !sb.SelfID.Equals(m.Author.ID) {
+		sb.DB.Audit(AuditTypeCommand, m.Author, m.Content, SBatoi(info.ID))
+	}
+	cmdname := CommandID(strings.ToLower(c.Info().Name))
+	log := info.MessageLogger(m).With(LogFields{"command": cmdname})
+
+	ignore := false
+	if !private {
+		for _, h := range info.hooks.OnCommand {
+			if info.ProcessModule(channelID, h) {
+				ignore = ignore || h.OnCommand(info, m)
 			}
 		}
 	}
+
+	cch := info.Config.Modules.CommandChannels[cmdname]
+	if !private && len(cch) > 0 {
+		_, reverse := cch["!"]
+		_, ok := cch[channelID]
+		ignore = ignore || ok == reverse
+	}
+	bypass, err := info.UserCanUseCommand(DiscordUser(m.Author.ID), c, ignore) // Bypass is true for administrators, mods, and the bot owner
+	if err == errDisabled || err == errIgnored || err == errSilenced || err == errMainGuild {
+		out.Ignore(info, err)
+		return
+	}
+	if !isdebug && !isfree && !bypass && info.Config.Modules.CommandPerDuration > 0 { // debug channels aren't limited
+		if len(info.commandlimit.times) < info.Config.Modules.CommandPerDuration*2 { // Check if we need to re-allocate the array because the configuration changed
+			info.commandlimit.times = make([]int64, info.Config.Modules.CommandPerDuration*2, info.Config.Modules.CommandPerDuration*2)
+		}
+		if info.commandlimit.check(info.Config.Modules.CommandPerDuration, info.Config.Modules.CommandMaxDuration, t) { // if we've hit the saturation limit, post an error (which itself will only post if the error saturation limit hasn't been hit)
+			sb.Metrics.RateLimited.With("global").Inc()
+			out.Error(info, fmt.Sprintf("You can't input more than %v commands every %s!%s", info.Config.Modules.CommandPerDuration, TimeDiff(time.Duration(info.Config.Modules.CommandMaxDuration)*time.Second), sb.getAddMsg(info)), t)
+			return
+		}
+		info.commandlimit.append(t)
+	}
+	if err != nil {
+		log.Debug("Command refused:", err)
+		out.Error(info, err.Error(), t)
+		return
+	}
+
+	if c.Info().Silver && !info.Silver.Get() {
+		out.Error(info, "That command is for Silver supporters only. Server owners can donate $1 a month to gain access: "+PatreonURL+". Visit the support channel for help if you already donated.", t)
+		return
+	}
+
+	cmdlimit := info.Config.Modules.CommandLimits[cmdname]
+	if !isfree && cmdlimit > 0 && !bypass {
+		info.commandLock.RLock()
+		lastcmd := info.commandLast[channelID][cmdname]
+		info.commandLock.RUnlock()
+		if !RateLimit(&lastcmd, cmdlimit, t) {
+			sb.Metrics.RateLimited.With("command").Inc()
+			out.Error(info, fmt.Sprintf("You can only run that command once every %s!%s", TimeDiff(time.Duration(cmdlimit)*time.Second), sb.getAddMsg(info)), t)
+			return
+		}
+		info.commandLock.Lock()
+		if len(info.commandLast[channelID]) == 0 {
+			info.commandLast[channelID] = make(map[CommandID]int64)
+		}
+		info.commandLast[channelID][cmdname] = t
+		info.commandLock.Unlock()
+	}
+
+	log.Debug("Running command")
+	start := time.Now()
+	result, usepm, resultembed := c.Process(args, m, indices, info)
+	sb.Metrics.Commands.With(string(cmdname)).Inc()
+	sb.Metrics.CommandDuration.With(string(cmdname)).Observe(time.Since(start).Seconds())
+	out.Result(info, m, result, usepm, resultembed)
 }
 
 // MessageCreate discord hook
@@ -520,12 +614,12 @@ func (sb *SweetieBot) MessageCreate(s *discordgo.Session, m *discordgo.MessageCr
 			return
 		}
 	} else {
-		sb.GuildsLock.RLock()
-		info, _ = sb.Guilds[sb.MainGuildID]
+		if s.ShardID < len(sb.shardHeartbeat) {
+			info, _ = sb.shardHeartbeat[s.ShardID].Load().(*GuildInfo)
+		}
 		if info == nil {
-			fmt.Println("Failed to get main guild during heartbeat test!")
+			sb.Logger.With(LogFields{"shard": s.ShardID}).Error("Failed to get a guild during heartbeat test!")
 		}
-		sb.GuildsLock.RUnlock()
 	}
 
 	sb.ProcessCommand(m.Message, info, t, isdebug, private)
@@ -542,19 +636,19 @@ func (sb *SweetieBot) MessageUpdate(s *discordgo.Session, m *discordgo.MessageUp
 		return
 	}
 	if m.Author == nil { // Discord sends an update message with an empty author when certain media links are posted
-		if perms, err := sb.DG.State.UserChannelPermissions(sb.SelfID.String(), m.ChannelID); err != nil || (perms&discordgo.PermissionReadMessageHistory) == 0 {
+		if perms, err := info.DG.State.UserChannelPermissions(sb.SelfID.String(), m.ChannelID); err != nil || (perms&discordgo.PermissionReadMessageHistory) == 0 {
 			return // If we don't have read message history this won't work so give up
 		}
 		original, err := s.ChannelMessage(m.ChannelID, m.ID)
 		if err != nil {
-			info.LogError("Error processing MessageUpdate: ", err)
+			info.Logger().LogError("Error processing MessageUpdate: ", err)
 			return // Fuck it, we can't process this
 		}
 		m.Author = original.Author
 	}
 
-	ch, err := sb.DG.State.Channel(m.ChannelID)
-	info.LogError("Error retrieving channel ID "+m.ChannelID+": ", err)
+	ch, err := info.DG.State.Channel(m.ChannelID)
+	info.Logger().LogError("Error retrieving channel ID "+m.ChannelID+": ", err)
 	private := true
 	if err == nil {
 		private = typeIsPrivate(ch.Type)
@@ -600,7 +694,7 @@ func (sb *SweetieBot) GuildUpdate(s *discordgo.Session, m *discordgo.GuildUpdate
 	if info == nil {
 		return
 	}
-	fmt.Println("Guild update detected, updating", m.Name)
+	info.Logger().Debug("Guild update detected, updating", m.Name)
 	info.ProcessGuild(m.Guild)
 
 	for _, h := range info.hooks.OnGuildUpdate {
@@ -655,7 +749,7 @@ func (sb *SweetieBot) GuildMemberRemove(s *discordgo.Session, m *discordgo.Guild
 	}
 
 	if userID == sb.SelfID {
-		fmt.Println("Sweetie was removed from", info.Name)
+		info.Logger().Info("Sweetie was removed from", info.Name)
 		sb.GuildsLock.Lock()
 		delete(sb.Guilds, DiscordGuild(info.ID))
 		sb.GuildsLock.Unlock()
@@ -727,6 +821,46 @@ func (sb *SweetieBot) GuildRoleDelete(s *discordgo.Session, m *discordgo.GuildRo
 	}
 }
 
+// MessageReactionAdd discord hook
+func (sb *SweetieBot) MessageReactionAdd(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
+	if DiscordUser(m.UserID) == sb.SelfID {
+		return
+	}
+	info := sb.getChannelGuild(m.ChannelID)
+	if info == nil {
+		return
+	}
+	channelID := DiscordChannel(m.ChannelID)
+	if boolXOR(sb.Debug, info.IsDebug(channelID)) {
+		return
+	}
+	for _, h := range info.hooks.OnMessageReactionAdd {
+		if info.ProcessModule(channelID, h) {
+			h.OnMessageReactionAdd(info, m.MessageReaction)
+		}
+	}
+}
+
+// MessageReactionRemove discord hook
+func (sb *SweetieBot) MessageReactionRemove(s *discordgo.Session, m *discordgo.MessageReactionRemove) {
+	if DiscordUser(m.UserID) == sb.SelfID {
+		return
+	}
+	info := sb.getChannelGuild(m.ChannelID)
+	if info == nil {
+		return
+	}
+	channelID := DiscordChannel(m.ChannelID)
+	if boolXOR(sb.Debug, info.IsDebug(channelID)) {
+		return
+	}
+	for _, h := range info.hooks.OnMessageReactionRemove {
+		if info.ProcessModule(channelID, h) {
+			h.OnMessageReactionRemove(info, m.MessageReaction)
+		}
+	}
+}
+
 // GuildCreate discord hook
 func (sb *SweetieBot) GuildCreate(s *discordgo.Session, m *discordgo.GuildCreate) {
 	sb.AttachToGuild(m.Guild)
@@ -734,7 +868,7 @@ func (sb *SweetieBot) GuildCreate(s *discordgo.Session, m *discordgo.GuildCreate
 
 // GuildDelete discord hook
 func (sb *SweetieBot) GuildDelete(s *discordgo.Session, m *discordgo.GuildDelete) {
-	fmt.Println("Sweetie was deleted from", m.Guild.Name)
+	sb.Logger.With(LogFields{"guild": m.Guild.ID}).Info("Sweetie was deleted from", m.Guild.Name)
 	sb.GuildsLock.Lock()
 	delete(sb.Guilds, DiscordGuild(m.Guild.ID))
 	sb.GuildsLock.Unlock()
@@ -806,11 +940,11 @@ func (sb *SweetieBot) memberIngestionLoop() {
 		if !more {
 			return
 		}
-		fmt.Println("Member processing for: " + guild.Name)
+		guild.Logger().Debug("Member processing for:", guild.Name)
 		members := []*discordgo.Member{}
 		lastid := ""
 		for {
-			m, err := sb.DG.GuildMembers(guild.ID, lastid, 999)
+			m, err := guild.DG.GuildMembers(guild.ID, lastid, 999)
 			if err != nil || len(m) == 0 {
 				break
 			}
@@ -827,7 +961,7 @@ func (sb *SweetieBot) memberIngestionLoop() {
 		}
 		for i := range members { // Put the guildID back in because discord is stupid
 			members[i].GuildID = guild.ID
-			sb.DG.State.MemberAdd(members[i])
+			guild.DG.State.MemberAdd(members[i])
 		}
 	}
 }
@@ -849,11 +983,11 @@ func (sb *SweetieBot) deferProcessing() {
 	}
 }
 func (sb *SweetieBot) idleCheck(info *GuildInfo, guild *discordgo.Guild) {
-	sb.DG.State.RLock()
+	info.DG.State.RLock()
 	channels := guild.Channels
-	sb.DG.State.RUnlock()
+	info.DG.State.RUnlock()
 	if sb.Debug { // override this in debug mode
-		c, err := sb.DG.State.Channel(sb.DebugChannels[DiscordGuild(info.ID)].String())
+		c, err := info.DG.State.Channel(sb.DebugChannels[DiscordGuild(info.ID)].String())
 		if err == nil {
 			channels = []*discordgo.Channel{c}
 		} else {
@@ -895,71 +1029,112 @@ func (sb *SweetieBot) idleCheckLoop() {
 		}
 		sb.GuildsLock.RUnlock()
 		for _, info := range infos {
-			guild, err := sb.DG.State.Guild(info.ID)
+			guild, err := info.DG.State.Guild(info.ID)
 			if err != nil {
 				continue
 			}
 			sb.idleCheck(info, guild)
 		}
 
-		fmt.Println("Idle Check: ", time.Now())
+		sb.Logger.Debug("Idle check")
 		time.Sleep(20 * time.Second)
 	}
 }
 
-func (sb *SweetieBot) deadlockTestFunc(s *discordgo.Session, m *discordgo.MessageCreate) {
-	sb.DG.State.RLock()
-	sb.DG.State.RUnlock()
-	sb.locknumber++
-	sb.DG.RLock()
-	sb.DG.RUnlock()
-	sb.locknumber++
+func (sb *SweetieBot) deadlockTestFunc(i int, shard *DiscordGoSession, m *discordgo.MessageCreate) {
+	shard.State.RLock()
+	shard.State.RUnlock()
+	atomic.AddUint32(&sb.shardLocks[i], 1)
+	shard.RLock()
+	shard.RUnlock()
+	atomic.AddUint32(&sb.shardLocks[i], 1)
 	sb.GuildsLock.RLock()
 	sb.GuildsLock.RUnlock()
-	sb.locknumber++
-	sb.MessageCreate(&sb.DG.Session, m)
+	atomic.AddUint32(&sb.shardLocks[i], 1)
+	sb.MessageCreate(&shard.Session, m)
+}
+
+// heartbeatGuild returns the guild that heartbeats for a shard are processed on. This is the main guild if it's on
+// the shard, and otherwise the guild with the lowest ID, so the same guild is picked every time.
+func (sb *SweetieBot) heartbeatGuild(shard int) *GuildInfo {
+	sb.GuildsLock.RLock()
+	defer sb.GuildsLock.RUnlock()
+	if info, ok := sb.Guilds[sb.MainGuildID]; ok && sb.ShardID(info.ID) == shard {
+		return info
+	}
+	var lowest *GuildInfo
+	for _, info := range sb.Guilds {
+		if sb.ShardID(info.ID) == shard && (lowest == nil || SBatoi(info.ID) < SBatoi(lowest.ID)) {
+			lowest = info
+		}
+	}
+	return lowest
+}
+
+// heartbeatReceived records that a guild responded to a heartbeat
+func (sb *SweetieBot) heartbeatReceived(info *GuildInfo) {
+	atomic.AddUint32(&sb.heartbeat, 1)
+	if i := sb.ShardID(info.ID); i < len(sb.shardBeats) {
+		atomic.AddUint32(&sb.shardBeats[i], 1)
+	}
+}
+
+// ShardStatus returns how many guilds a shard has and how many heartbeats in a row it has missed
+func (sb *SweetieBot) ShardStatus(shard int) (guilds int, missed uint32) {
+	sb.Shards[shard].State.RLock()
+	guilds = len(sb.Shards[shard].State.Guilds)
+	sb.Shards[shard].State.RUnlock()
+	if shard < len(sb.shardMissed) {
+		missed = atomic.LoadUint32(&sb.shardMissed[shard])
+	}
+	return
 }
 
 const heartbeatInterval time.Duration = 20 * time.Second
 
+// deadlockDetector sends a fake !about command through every shard and terminates the bot if one of them stops
+// responding. Shards that don't have any guilds yet are skipped.
 func (sb *SweetieBot) deadlockDetector() {
-	var counter = sb.heartbeat
-	var missed = 0
+	counters := make([]uint32, len(sb.Shards))
 	time.Sleep(heartbeatInterval) // Give sweetie time to load everything first before initiating heartbeats
 	for atomic.LoadUint32(&sb.quit) != QuitNow {
-		sb.GuildsLock.RLock()
-		info, ok := sb.Guilds[sb.MainGuildID]
-		sb.GuildsLock.RUnlock()
-
-		if !ok {
-			fmt.Println(sb.MainGuildID, "MAIN GUILD CANNOT BE FOUND! Deadlock detector is nonfunctional until this is addressed.")
-			time.Sleep(heartbeatInterval)
-			continue
-		}
-		m := discordgo.MessageCreate{
-			&discordgo.Message{ChannelID: "heartbeat", Content: info.Config.Basic.CommandPrefix + "about",
-				Author: &discordgo.User{
-					ID:       sb.SelfID.String(),
-					Verified: true,
-					Bot:      true,
+		sent := make([]bool, len(sb.Shards))
+		for i, shard := range sb.Shards {
+			info := sb.heartbeatGuild(i)
+			if info == nil {
+				continue
+			}
+			sb.shardHeartbeat[i].Store(info) // MessageCreate processes the heartbeat on this guild instead of looking it up again
+			atomic.StoreUint32(&sb.shardLocks[i], 0)
+			m := discordgo.MessageCreate{
+				&discordgo.Message{ChannelID: "heartbeat", Content: info.Config.Basic.CommandPrefix + "about",
+					Author: &discordgo.User{
+						ID:       sb.SelfID.String(),
+						Verified: true,
+						Bot:      true,
+					},
+					Timestamp: discordgo.Timestamp(time.Now().UTC().Format(time.RFC3339Nano)),
 				},
-				Timestamp: discordgo.Timestamp(time.Now().UTC().Format(time.RFC3339Nano)),
-			},
+			}
+			counters[i] = atomic.LoadUint32(&sb.shardBeats[i])
+			sent[i] = true
+			go sb.deadlockTestFunc(i, shard, &m) // Do this in another thread so the deadlock detector doesn't deadlock
 		}
-		sb.locknumber = 0
-		go sb.deadlockTestFunc(&sb.DG.Session, &m) // Do this in another thread so the deadlock detector doesn't deadlock
 		time.Sleep(heartbeatInterval)
-		if atomic.LoadUint32(&sb.heartbeat) == counter+1 {
-			counter++
-			missed = 0
-		} else {
-			missed++
-			fmt.Println("MISSED HEARTBEAT SIGNAL ", missed, " TIMES IN A ROW")
-			counter = atomic.LoadUint32(&sb.heartbeat)
-		}
-		if missed >= 5 {
-			fmt.Println("FATAL ERROR: DEADLOCK DETECTED! (", sb.locknumber, ") TERMINATING PROGRAM...")
-			os.Exit(-1)
+		for i := range sb.Shards {
+			if !sent[i] {
+				continue
+			}
+			if atomic.LoadUint32(&sb.shardBeats[i]) != counters[i] {
+				atomic.StoreUint32(&sb.shardMissed[i], 0)
+				continue
+			}
+			missed := atomic.AddUint32(&sb.shardMissed[i], 1)
+			sb.Logger.With(LogFields{"shard": i}).Warning("MISSED HEARTBEAT SIGNAL", missed, "TIMES IN A ROW")
+			if missed >= 5 {
+				sb.Logger.With(LogFields{"shard": i, "locknumber": atomic.LoadUint32(&sb.shardLocks[i])}).Error("FATAL ERROR: DEADLOCK DETECTED! TERMINATING PROGRAM...")
+				os.Exit(-1)
+			}
 		}
 	}
 }
@@ -1002,6 +1177,9 @@ func New(token string, loader func(*GuildInfo) []Module) *SweetieBot {
 		WebSecure:      false,
 		WebDomain:      "localhost",
 		WebPort:        ":80",
+		LogLevel:       LogInfo,
+		LogMaxSize:     10,
+		LogMaxFiles:    5,
 		changelog: map[int]string{
 			AssembleVersion(0, 9, 9, 9):  "- Fix lastseen values\n- Fix missing access error message when sweetie doesn't have read message history permissions.",
 			AssembleVersion(0, 9, 9, 8):  "- Restore old functionality of !echo\n- say whether a user was autosilenced upon joining.",
@@ -1138,6 +1316,12 @@ func New(token string, loader func(*GuildInfo) []Module) *SweetieBot {
 
 	json.Unmarshal(hostfile, sb)
 	sb.Token = strings.TrimSpace(sb.Token)
+	sb.Logger = NewLogger(sb.LogLevel, os.Stdout)
+	if len(sb.LogFile) > 0 {
+		if err := sb.Logger.OpenFile(sb.LogFile, sb.LogMaxSize*1024*1024, sb.LogMaxFiles); err != nil {
+			sb.Logger.Error("Couldn't open log file, only logging to the console:", err)
+		}
+	}
 	sb.EmptyGuild = NewGuildInfo(sb, &discordgo.Guild{})
 
 	sb.EmptyGuild.Config.FillConfig()
@@ -1153,55 +1337,103 @@ func New(token string, loader func(*GuildInfo) []Module) *SweetieBot {
 		}
 	}
 
-	db, err := dbLoad(&emptyLog{}, "mysql", strings.TrimSpace(sb.DBAuth))
+	db, err := dbLoad(sb.Logger.With(nil), sb.DBDriver, strings.TrimSpace(sb.DBAuth))
+	if db == nil {
+		sb.Logger.Error("Failed to load database driver:", err)
+		return nil
+	}
 	sb.DB = db
 	if !db.Status.Get() {
-		fmt.Println("Database connection failure - running in No Database mode: ", err.Error())
+		sb.Logger.Error("Database connection failure - running in No Database mode:", err)
 	} else {
 		err = sb.DB.LoadStatements()
 		if err == nil {
-			fmt.Println("Finished loading database statements")
+			sb.Logger.Info("Finished loading database statements")
 		} else {
-			fmt.Println("Loading database statements failed: ", err)
-			fmt.Println("DATABASE IS BADLY FORMATTED OR CORRUPT - TERMINATING SWEETIE BOT!")
+			sb.Logger.Error("Loading database statements failed:", err)
+			sb.Logger.Error("DATABASE IS BADLY FORMATTED OR CORRUPT - TERMINATING SWEETIE BOT!")
 			return nil
 		}
 	}
 
-	var dg *discordgo.Session
+	if sb.ShardCount < 1 || sb.IsUserMode { // Users can't shard
+		sb.ShardCount = 1
+	}
+	auth := "Bot " + sb.Token
 	if sb.IsUserMode {
-		dg, err = discordgo.New(sb.Token)
-		fmt.Println("Started SweetieBot on a user account.")
-	} else {
-		dg, err = discordgo.New("Bot " + sb.Token)
+		auth = sb.Token
+		sb.Logger.Info("Started SweetieBot on a user account.")
 	}
-	sb.DG = &DiscordGoSession{*dg}
+	for i := 0; i < sb.ShardCount; i++ {
+		dg, err := discordgo.New(auth)
+		if err != nil {
+			sb.Logger.Error("Error creating discord session:", err)
+			return nil
+		}
+		shard := &DiscordGoSession{*dg}
+		shard.ShardID = i
+		shard.ShardCount = sb.ShardCount
+		shard.LogLevel = discordgo.LogWarning
+		if i > 0 {
+			shard.Ratelimiter = sb.DG.Ratelimiter // Rate limits apply to the token, not the connection
+		} else {
+			sb.DG = shard
+		}
 
-	if err != nil {
-		fmt.Println("Error creating discord session", err.Error())
-		return nil
-	}
-	sb.DG.LogLevel = discordgo.LogWarning
-
-	sb.DG.AddHandler(sb.OnReady)
-	sb.DG.AddHandler(sb.MessageCreate)
-	sb.DG.AddHandler(sb.MessageUpdate)
-	sb.DG.AddHandler(sb.MessageDelete)
-	sb.DG.AddHandler(sb.UserUpdate)
-	sb.DG.AddHandler(sb.GuildUpdate)
-	sb.DG.AddHandler(sb.GuildMemberAdd)
-	sb.DG.AddHandler(sb.GuildMemberRemove)
-	sb.DG.AddHandler(sb.GuildMemberUpdate)
-	sb.DG.AddHandler(sb.GuildBanAdd)
-	sb.DG.AddHandler(sb.GuildBanRemove)
-	sb.DG.AddHandler(sb.GuildRoleDelete)
-	sb.DG.AddHandler(sb.GuildCreate)
-	sb.DG.AddHandler(sb.ChannelCreate)
+		shard.AddHandler(sb.OnReady)
+		shard.AddHandler(sb.MessageCreate)
+		shard.AddHandler(sb.MessageUpdate)
+		shard.AddHandler(sb.MessageDelete)
+		shard.AddHandler(sb.UserUpdate)
+		shard.AddHandler(sb.GuildUpdate)
+		shard.AddHandler(sb.GuildMemberAdd)
+		shard.AddHandler(sb.GuildMemberRemove)
+		shard.AddHandler(sb.GuildMemberUpdate)
+		shard.AddHandler(sb.GuildBanAdd)
+		shard.AddHandler(sb.GuildBanRemove)
+		shard.AddHandler(sb.GuildRoleDelete)
+		shard.AddHandler(sb.MessageReactionAdd)
+		shard.AddHandler(sb.MessageReactionRemove)
+		shard.AddHandler(sb.GuildCreate)
+		shard.AddHandler(sb.ChannelCreate)
+		shard.AddHandler(sb.InteractionCreate)
+		sb.Shards = append(sb.Shards, shard)
+	}
+	sb.shardBeats = make([]uint32, sb.ShardCount)
+	sb.shardMissed = make([]uint32, sb.ShardCount)
+	sb.shardLocks = make([]uint32, sb.ShardCount)
+	sb.shardHeartbeat = make([]atomic.Value, sb.ShardCount)
+	sb.EmptyGuild.DG = sb.DG
 
 	return sb
 }
 
-// Connect opens a websocket connection to discord. Only returns after disconnecting.
+// Stop tells the bot to disconnect immediately, which causes Connect to return.
+func (sb *SweetieBot) Stop() {
+	atomic.StoreUint32(&sb.quit, QuitNow)
+}
+
+// ShardConnectDelay is how long to wait between connecting each shard. Discord only allows one connection to identify
+// itself every 5 seconds.
+var ShardConnectDelay = 5 * time.Second
+
+// openShards connects every shard to discord, one at a time
+func (sb *SweetieBot) openShards() error {
+	for i, shard := range sb.Shards {
+		if i > 0 {
+			time.Sleep(ShardConnectDelay)
+		}
+		if err := shard.Open(); err != nil {
+			return fmt.Errorf("shard %v: %s", i, err.Error())
+		}
+		if len(sb.Shards) > 1 {
+			sb.Logger.With(LogFields{"shard": i}).Info("Connected shard")
+		}
+	}
+	return nil
+}
+
+// Connect opens a websocket connection to discord for every shard. Only returns after disconnecting.
 func (sb *SweetieBot) Connect() int {
 	if sb.Debug { // The server does not necessarily tie a standard input to the program
 		go func() {
@@ -1217,9 +1449,9 @@ func (sb *SweetieBot) Connect() int {
 	go sb.memberIngestionLoop()
 	go sb.ServeWeb()
 
-	err := sb.DG.Open()
+	err := sb.openShards()
 	if err == nil {
-		fmt.Println("Connection established")
+		sb.Logger.Info("Connection established")
 		for atomic.LoadUint32(&sb.quit) == QuitNone {
 			time.Sleep(800 * time.Millisecond)
 		}
@@ -1239,7 +1471,7 @@ func (sb *SweetieBot) Connect() int {
 			}
 		}
 	} else {
-		fmt.Println("Error opening websocket connection: ", err.Error())
+		sb.Logger.Error("Error opening websocket connection:", err)
 	}
 
 	/*if q, err := sb.DB.db.Query("SELECT DISTINCT Guild FROM members"); err == nil {
@@ -1255,8 +1487,10 @@ func (sb *SweetieBot) Connect() int {
 		}
 	}*/
 
-	fmt.Println("Sweetiebot quitting")
-	sb.DG.Close()
+	sb.Logger.Info("Sweetiebot quitting")
+	for _, shard := range sb.Shards {
+		shard.Close()
+	}
 	sb.DB.Close()
 	sb.GuildsLock.Lock() // Prevents a race condition from sending a value to a closed channel
 	close(sb.memberChan)
@@ -275,11 +275,12 @@ func mockBotDB() (*BotDB, sqlmock.Sqlmock) {
 	botdb := &BotDB{
 		db:          db,
 		lastattempt: time.Now().UTC(),
-		log:         &emptyLog{},
+		log:         NewLogger(LogNone, nil).With(nil),
 		driver:      "mysql",
 		conn:        "",
+		storage:     &mysqlStorage{},
 	}
-	for i := 0; i < 84; i++ {
+	for i := 0; i < 116; i++ {
 		mock.ExpectPrepare(".*")
 	}
 	botdb.Status.Set(botdb.LoadStatements() == nil)
@@ -350,6 +351,7 @@ func MockSweetieBot(t *testing.T) (*SweetieBot, sqlmock.Sqlmock, *Mock) {
 		memberChan:     make(chan *GuildInfo, 1500),
 		Selfhoster:     &Selfhost{SelfhostBase{BotVersion.Integer()}, sync.RWMutex{}, AtomicBool{0}, make(map[DiscordUser]bool)},
 	}
+	sb.Shards = []*DiscordGoSession{sb.DG}
 	sb.EmptyGuild = NewGuildInfo(sb, &discordgo.Guild{})
 	sb.EmptyGuild.Config.FillConfig()
 
@@ -366,6 +368,7 @@ func MockSweetieBot(t *testing.T) (*SweetieBot, sqlmock.Sqlmock, *Mock) {
 			commandmap:   make(map[CommandID]ModuleID),
 			lastlogerr:   0,
 			Bot:          sb,
+			DG:           sb.DG,
 			Config:       *DefaultConfig(),
 		}
 		info.Config.FillConfig()
@@ -0,0 +1,355 @@
+package sweetiebot
+
+import (
+	"container/list"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MaxTagExprTags is how many tags a single tag expression can refer to
+const MaxTagExprTags = 32
+
+// Characters with a special meaning in tag expressions, which tag names can't contain
+const tagExprOperators = "+-|()"
+
+// TagExpr is a parsed tag expression, like tag1|(tag2+(-tag3)). It is either a TagName, TagNot, TagAnd or TagOr.
+type TagExpr interface {
+	// String returns the normalized form of the expression, which is the same for any two equivalent expressions
+	// that only differ in spacing, case, ordering, redundant parentheses, repeated tags or double negation.
+	String() string
+	where(b *strings.Builder, tags []string) []string
+}
+
+// TagName matches items with the given tag
+type TagName string
+
+// TagNot matches items that don't match X
+type TagNot struct {
+	X TagExpr
+}
+
+// TagAnd matches items that match every expression in it
+type TagAnd []TagExpr
+
+// TagOr matches items that match any expression in it
+type TagOr []TagExpr
+
+func (e TagName) String() string { return string(e) }
+func (e TagNot) String() string {
+	if _, ok := e.X.(TagName); ok {
+		return "-" + e.X.String()
+	}
+	return "-(" + e.X.String() + ")"
+}
+func (e TagAnd) String() string {
+	s := make([]string, len(e))
+	for i, v := range e {
+		s[i] = v.String()
+		if _, ok := v.(TagOr); ok {
+			s[i] = "(" + s[i] + ")"
+		}
+	}
+	return strings.Join(s, "+")
+}
+func (e TagOr) String() string {
+	s := make([]string, len(e))
+	for i, v := range e {
+		s[i] = v.String()
+	}
+	return strings.Join(s, "|")
+}
+
+func (e TagName) where(b *strings.Builder, tags []string) []string {
+	b.WriteString("M.Item IN (SELECT Item FROM itemtags WHERE Tag = ?)")
+	return append(tags, string(e))
+}
+func (e TagNot) where(b *strings.Builder, tags []string) []string {
+	b.WriteString("NOT ")
+	return whereGroup(e.X, b, tags)
+}
+func (e TagAnd) where(b *strings.Builder, tags []string) []string {
+	for i, v := range e {
+		if i > 0 {
+			b.WriteString(" AND ")
+		}
+		tags = whereGroup(v, b, tags)
+	}
+	return tags
+}
+func (e TagOr) where(b *strings.Builder, tags []string) []string {
+	for i, v := range e {
+		if i > 0 {
+			b.WriteString(" OR ")
+		}
+		tags = whereGroup(v, b, tags)
+	}
+	return tags
+}
+
+// whereGroup writes an operand of NOT, AND or OR, in parentheses unless it's a single tag
+func whereGroup(e TagExpr, b *strings.Builder, tags []string) []string {
+	if _, ok := e.(TagName); ok {
+		return e.where(b, tags)
+	}
+	b.WriteString("(")
+	tags = e.where(b, tags)
+	b.WriteString(")")
+	return tags
+}
+
+// TagWhereClause compiles a tag expression into a SQL condition on the items in the itemtags table aliased as M. Each
+// tag becomes a placeholder, so it returns the tag names that have to be bound to them, in order.
+func TagWhereClause(e TagExpr) (string, []string) {
+	var b strings.Builder
+	tags := e.where(&b, make([]string, 0, 4))
+	return b.String(), tags
+}
+
+// TagExprError is a syntax error in a tag expression
+type TagExprError struct {
+	Expr string
+	Pos  int // Which character the error is at, counting from 0. Equal to the length of the expression if it ended too early.
+	Msg  string
+}
+
+func (e *TagExprError) Error() string {
+	return fmt.Sprintf("%s at position %v:\n%s\n%s^", e.Msg, e.Pos+1, e.Expr, strings.Repeat(" ", e.Pos))
+}
+
+type tagExprParser struct {
+	expr  string
+	runes []rune
+	pos   int
+	tags  int
+}
+
+func (p *tagExprParser) fail(pos int, format string, args ...interface{}) *TagExprError {
+	return &TagExprError{p.expr, pos, fmt.Sprintf(format, args...)}
+}
+
+// peek skips whitespace and returns the next character, or 0 at the end of the expression
+func (p *tagExprParser) peek() rune {
+	for p.pos < len(p.runes) && (p.runes[p.pos] == ' ' || p.runes[p.pos] == '\t' || p.runes[p.pos] == '\n') {
+		p.pos++
+	}
+	if p.pos >= len(p.runes) {
+		return 0
+	}
+	return p.runes[p.pos]
+}
+
+func (p *tagExprParser) parseOr() (TagExpr, error) {
+	e, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	terms := []TagExpr{e}
+	for p.peek() == '|' {
+		p.pos++
+		if e, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		terms = append(terms, e)
+	}
+	return newTagOr(terms), nil
+}
+
+func (p *tagExprParser) parseAnd() (TagExpr, error) {
+	e, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	terms := []TagExpr{e}
+	for p.peek() == '+' {
+		p.pos++
+		if e, err = p.parseUnary(); err != nil {
+			return nil, err
+		}
+		terms = append(terms, e)
+	}
+	return newTagAnd(terms), nil
+}
+
+func (p *tagExprParser) parseUnary() (TagExpr, error) {
+	switch c := p.peek(); c {
+	case 0:
+		return nil, p.fail(p.pos, "Expected a tag, but the expression ended")
+	case '-':
+		p.pos++
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if not, ok := e.(TagNot); ok {
+			return not.X, nil
+		}
+		return TagNot{e}, nil
+	case '(':
+		open := p.pos
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, p.fail(p.pos, "Expected ) to close the ( at position %v", open+1)
+		}
+		p.pos++
+		return e, nil
+	case ')', '+', '|':
+		return nil, p.fail(p.pos, "Expected a tag, but found %c", c)
+	}
+
+	start := p.pos
+	for p.pos < len(p.runes) && !strings.ContainsRune(tagExprOperators, p.runes[p.pos]) {
+		p.pos++
+	}
+	p.tags++
+	if p.tags > MaxTagExprTags {
+		return nil, p.fail(start, "Tag expressions can't have more than %v tags", MaxTagExprTags)
+	}
+	return TagName(strings.ToLower(strings.TrimSpace(string(p.runes[start:p.pos])))), nil
+}
+
+// newTagAnd and newTagOr flatten nested operators of the same kind, drop repeated operands and sort them, so that
+// equivalent expressions end up with the same normalized form
+func newTagAnd(terms []TagExpr) TagExpr {
+	flat := []TagExpr{}
+	for _, v := range terms {
+		if and, ok := v.(TagAnd); ok {
+			flat = append(flat, and...)
+		} else {
+			flat = append(flat, v)
+		}
+	}
+	flat = sortTagTerms(flat)
+	if len(flat) == 1 {
+		return flat[0]
+	}
+	return TagAnd(flat)
+}
+
+func newTagOr(terms []TagExpr) TagExpr {
+	flat := []TagExpr{}
+	for _, v := range terms {
+		if or, ok := v.(TagOr); ok {
+			flat = append(flat, or...)
+		} else {
+			flat = append(flat, v)
+		}
+	}
+	flat = sortTagTerms(flat)
+	if len(flat) == 1 {
+		return flat[0]
+	}
+	return TagOr(flat)
+}
+
+func sortTagTerms(terms []TagExpr) []TagExpr {
+	sort.SliceStable(terms, func(i, j int) bool { return terms[i].String() < terms[j].String() })
+	r := terms[:0]
+	for _, v := range terms {
+		if len(r) == 0 || v.String() != r[len(r)-1].String() {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// ParseTagExpr parses a tag expression, where + means AND, | means OR, - means NOT and parentheses group terms, so
+// tag1|(tag2+(-tag3)) means tag1 OR (tag2 AND NOT tag3). + binds tighter than |. Tag names are case-insensitive.
+func ParseTagExpr(s string) (TagExpr, error) {
+	p := &tagExprParser{expr: s, runes: []rune(s)}
+	if p.peek() == 0 {
+		return nil, p.fail(0, "The tag expression is empty")
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	switch c := p.peek(); c {
+	case 0:
+		return e, nil
+	case ')':
+		return nil, p.fail(p.pos, "Found ) without a matching (")
+	default:
+		return nil, p.fail(p.pos, "Expected + or |, but found %c", c)
+	}
+}
+
+// StatementCache keeps prepared statements for queries that are built on the fly, like tag searches. Once it's full,
+// the statement that was used least recently is dropped to make room, and closed once nothing is using it anymore.
+type StatementCache struct {
+	lock    sync.Mutex
+	size    int
+	order   *list.List // Most recently used first
+	queries map[string]*list.Element
+}
+
+type cachedStatement struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int  // How many callers are still using the statement
+	evicted bool // Whether it was dropped from the cache, so the last caller to release it closes it
+}
+
+// NewStatementCache creates a cache that holds up to size prepared statements
+func NewStatementCache(size int) *StatementCache {
+	if size < 1 {
+		size = 1
+	}
+	return &StatementCache{
+		size:    size,
+		order:   list.New(),
+		queries: make(map[string]*list.Element),
+	}
+}
+
+// Prepare returns the cached statement for the query, preparing it first if it isn't in the cache. The statement
+// can't be closed until release is called, which must happen exactly once, after the caller has run its query. Rows
+// that are still open after that keep working until they're closed.
+func (c *StatementCache) Prepare(query string, db *BotDB) (*sql.Stmt, func(), error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var s *cachedStatement
+	if e, ok := c.queries[query]; ok {
+		c.order.MoveToFront(e)
+		s = e.Value.(*cachedStatement)
+	} else {
+		stmt, err := db.Prepare(query)
+		if err != nil {
+			return nil, nil, err
+		}
+		s = &cachedStatement{query: query, stmt: stmt}
+		c.queries[query] = c.order.PushFront(s)
+		for c.order.Len() > c.size {
+			old := c.order.Remove(c.order.Back()).(*cachedStatement)
+			delete(c.queries, old.query)
+			old.evicted = true
+			if old.refs == 0 {
+				old.stmt.Close()
+			}
+		}
+	}
+	s.refs++
+	return s.stmt, func() { c.release(s) }, nil
+}
+
+func (c *StatementCache) release(s *cachedStatement) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	s.refs--
+	if s.refs == 0 && s.evicted {
+		s.stmt.Close()
+	}
+}
+
+// Len returns how many statements are in the cache
+func (c *StatementCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
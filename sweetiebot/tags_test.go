@@ -0,0 +1,187 @@
+package sweetiebot
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseTagExpr(t *testing.T) {
+	t.Parallel()
+
+	for k, v := range map[string]string{
+		"tag1":                  "tag1",
+		" Tag One ":             "tag one",
+		"tag1|(tag2+(-tag3))":   "-tag3+tag2|tag1",
+		"tag1 | (-TAG3+tag2)":   "-tag3+tag2|tag1",
+		"b+a+b":                 "a+b",
+		"(a+(b+c))":             "a+b+c",
+		"a|(b|(c|a))":           "a|b|c",
+		"--a":                   "a",
+		"-(-(a))":               "a",
+		"-(a|b)":                "-(a|b)",
+		"-(b+a)":                "-(a+b)",
+		"a+b|c":                 "a+b|c",
+		"(c|b)+a":               "a+(b|c)",
+		"((((a))))":             "a",
+		"ÄPFEL|bananas":         "bananas|äpfel",
+		"a|b+c|d":               "a|b+c|d",
+		"movie night+-spoilers": "-spoilers+movie night",
+	} {
+		e, err := ParseTagExpr(k)
+		if !Check(err, nil, t) {
+			continue
+		}
+		Check(e.String(), v, t)
+		again, err := ParseTagExpr(e.String())
+		if Check(err, nil, t) {
+			Check(again.String(), v, t)
+		}
+	}
+
+	e, _ := ParseTagExpr("a+b|c")
+	if or, ok := e.(TagOr); Check(ok, true, t) && Check(len(or), 2, t) {
+		_, ok = or[0].(TagAnd)
+		Check(ok, true, t)
+		Check(or[1], TagName("c"), t)
+	}
+	e, _ = ParseTagExpr("-a+b")
+	if and, ok := e.(TagAnd); Check(ok, true, t) && Check(len(and), 2, t) {
+		Check(and[0], TagNot{TagName("a")}, t)
+		Check(and[1], TagName("b"), t)
+	}
+}
+
+func TestTagExprErrors(t *testing.T) {
+	t.Parallel()
+
+	for k, v := range map[string]TagExprError{
+		"":         {"", 0, "The tag expression is empty"},
+		"   ":      {"   ", 0, "The tag expression is empty"},
+		"a+":       {"a+", 2, "Expected a tag, but the expression ended"},
+		"a||b":     {"a||b", 2, "Expected a tag, but found |"},
+		"+a":       {"+a", 0, "Expected a tag, but found +"},
+		"(a+b":     {"(a+b", 4, "Expected ) to close the ( at position 1"},
+		"a|(b+(c)": {"a|(b+(c)", 8, "Expected ) to close the ( at position 3"},
+		"a+b)":     {"a+b)", 3, "Found ) without a matching ("},
+		"a-b":      {"a-b", 1, "Expected + or |, but found -"},
+		"a(b)":     {"a(b)", 1, "Expected + or |, but found ("},
+		"()":       {"()", 1, "Expected a tag, but found )"},
+		"é+-)":     {"é+-)", 3, "Expected a tag, but found )"},
+	} {
+		_, err := ParseTagExpr(k)
+		if e, ok := err.(*TagExprError); !ok {
+			t.Errorf("expected a syntax error for %q, got %v", k, err)
+		} else {
+			Check(*e, v, t)
+		}
+	}
+
+	_, err := ParseTagExpr("a+b)")
+	Check(err.Error(), "Found ) without a matching ( at position 4:\na+b)\n   ^", t)
+
+	s := "t0"
+	for i := 1; i < MaxTagExprTags; i++ {
+		s += "|t" + strconv.Itoa(i)
+	}
+	_, err = ParseTagExpr(s)
+	Check(err, nil, t)
+	_, err = ParseTagExpr(s + "|x")
+	CheckNot(err, nil, t)
+}
+
+func TestTagWhereClause(t *testing.T) {
+	t.Parallel()
+
+	const has = "M.Item IN (SELECT Item FROM itemtags WHERE Tag = ?)"
+	e, _ := ParseTagExpr("tag1|(tag2+(-tag3))")
+	clause, tags := TagWhereClause(e)
+	Check(clause, "((NOT "+has+") AND "+has+") OR "+has, t)
+	Check(strings.Join(tags, ","), "tag3,tag2,tag1", t)
+
+	e, _ = ParseTagExpr("-(a|b)+c")
+	clause, tags = TagWhereClause(e)
+	Check(clause, "(NOT ("+has+" OR "+has+")) AND "+has, t)
+	Check(strings.Join(tags, ","), "a,b,c", t)
+
+	// Tag names only ever end up as arguments, never in the query itself
+	e, _ = ParseTagExpr("x' OR 1=1; DROP TABLE tags")
+	clause, tags = TagWhereClause(e)
+	Check(clause, has, t)
+	Check(strings.Join(tags, ","), "x' or 1=1; drop table tags", t)
+}
+
+func TestSQLiteTagQueries(t *testing.T) {
+	t.Parallel()
+	db := mockSQLiteDB(t)
+	defer db.Close()
+
+	tags := map[string]uint64{}
+	for _, v := range []string{"pony", "pegasus", "princess"} {
+		db.CreateTag(v, 2)
+		tags[v], _ = db.GetTag(v, 2)
+	}
+	for item, v := range map[string][]string{
+		"Twilight": {"pony", "princess"},
+		"Rainbow":  {"pony", "pegasus"},
+		"Luna":     {"pony", "pegasus", "princess"},
+		"Spike":    {},
+	} {
+		id, _ := db.AddItem(item)
+		for _, tag := range v {
+			db.AddTag(id, tags[tag])
+		}
+	}
+
+	cache := NewStatementCache(2)
+	query := func(s string) []string {
+		e, err := ParseTagExpr(s)
+		if !Check(err, nil, t) {
+			return nil
+		}
+		clause, names := TagWhereClause(e)
+		params := []interface{}{}
+		for _, v := range names {
+			params = append(params, tags[v])
+		}
+		stmt, release, err := cache.Prepare("SELECT I.Content FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID INNER JOIN items I ON M.Item = I.ID WHERE ("+clause+") AND T.Guild = ? GROUP BY I.Content ORDER BY I.Content", db)
+		if !Check(err, nil, t) {
+			return nil
+		}
+		q, err := stmt.Query(append(params, uint64(2))...)
+		release()
+		if !Check(err, nil, t) {
+			return nil
+		}
+		defer q.Close()
+		r := []string{}
+		for q.Next() {
+			var p string
+			q.Scan(&p)
+			r = append(r, p)
+		}
+		return r
+	}
+
+	Check(strings.Join(query("pegasus"), ","), "Luna,Rainbow", t)
+	Check(strings.Join(query("pony+-pegasus"), ","), "Twilight", t)
+	Check(strings.Join(query("princess|pegasus"), ","), "Luna,Rainbow,Twilight", t)
+	Check(strings.Join(query("pony+-(pegasus+princess)"), ","), "Rainbow,Twilight", t)
+	Check(strings.Join(query("-pegasus+PONY"), ","), "Twilight", t) // Same query as pony+-pegasus
+	Check(cache.Len(), 2, t)
+	Check(strings.Join(query("pegasus"), ","), "Luna,Rainbow", t) // Prepared again after it was evicted
+	Check(cache.Len(), 2, t)
+
+	// A statement that gets evicted while someone is about to use it isn't closed until they release it
+	held, release, err := cache.Prepare("SELECT COUNT(*) FROM items", db)
+	if !Check(err, nil, t) {
+		return
+	}
+	query("pony")
+	query("princess")
+	var count int
+	Check(held.QueryRow().Scan(&count), nil, t)
+	Check(count, 4, t)
+	release()
+	CheckNot(held.QueryRow().Scan(&count), nil, t)
+}
@@ -74,6 +74,11 @@ func StripPing(s string) string {
 	return s
 }
 
+// FindURLs returns every http or https link in a string
+func FindURLs(s string) []string {
+	return urlregex.FindAllString(s, -1)
+}
+
 // SBatoi converts a string to a uint64. Returns 0 if there is an error.
 func SBatoi(s string) uint64 {
 	if len(s) < 1 {
@@ -284,6 +289,15 @@ func SnowflakeTime(id uint64) time.Time {
 	return time.Unix(int64(((id>>22)+DiscordEpoch)/1000), 0)
 }
 
+// TimeSnowflake returns the smallest snowflake ID that could have been created at the given time
+func TimeSnowflake(t time.Time) uint64 {
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+	if ms < DiscordEpoch {
+		return 0
+	}
+	return (ms - DiscordEpoch) << 22
+}
+
 // WaitForPID loops until there is no longer any running process with the given PID, or returns immediately if no valid ID is given
 func WaitForPID(arg string) {
 	pid, err := strconv.Atoi(arg)
@@ -404,7 +418,7 @@ func GetJoinedAt(m *discordgo.Member) time.Time {
 
 // GetRoleByName gets a role by its name
 func GetRoleByName(role string, info *GuildInfo) (*discordgo.Role, error) {
-	roles, err := info.Bot.DG.GuildRoles(info.ID)
+	roles, err := info.DG.GuildRoles(info.ID)
 	role = strings.ToLower(role)
 	if err != nil {
 		return nil, err
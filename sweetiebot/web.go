@@ -93,27 +93,34 @@ type webModule struct {
 	Config      map[string]string
 }
 type webData struct {
-	Name    string
-	Title   string
-	Index   int
-	Year    int
-	Modules []webModule
+	Name      string
+	Title     string
+	Index     int
+	Year      int
+	Modules   []webModule
+	Dashboard bool // True if the dashboard is enabled, so it can be linked to
 }
 
 var codeBlockRegex = regexp.MustCompile("`[^`]+`")
 
-func (sb *SweetieBot) generateCache(webdir string) *template.Template {
-	t := template.Must(template.New("web.html").Funcs(template.FuncMap{
+// loadWebTemplate parses the web.html file in webdir, which contains the templates for every page
+func loadWebTemplate(webdir string) (*template.Template, error) {
+	return template.New("web.html").Funcs(template.FuncMap{
 		"parsemarkup": func(str string) template.HTML {
 			return template.HTML(codeBlockRegex.ReplaceAllStringFunc(str, func(s string) string { return "<code>" + s[1:len(s)-1] + "</code>" }))
 		},
-	}).ParseFiles(filepath.Join(webdir, "web.html")))
+	}).ParseFiles(filepath.Join(webdir, "web.html"))
+}
+
+func (sb *SweetieBot) generateCache(webdir string) *template.Template {
+	t := template.Must(loadWebTemplate(webdir))
 
 	data := webData{
-		Name:  "Sweetie Bot",
-		Title: "",
-		Index: -1,
-		Year:  time.Now().Year(),
+		Name:      "Sweetie Bot",
+		Title:     "",
+		Index:     -1,
+		Year:      time.Now().Year(),
+		Dashboard: len(sb.OAuthSecret) > 0,
 	}
 
 	configonly := []string{"Basic", "Modules"}
@@ -127,6 +134,21 @@ func (sb *SweetieBot) generateCache(webdir string) *template.Template {
 	}
 
 	modules := sb.loader(sb.EmptyGuild)
+	names := make(map[string]bool, len(modules))
+	for _, m := range modules {
+		names[strings.ToLower(m.Name())] = true
+	}
+	for _, s := range configSections { // Config categories registered by a module with a different name get their own page
+		if !names[s.key] {
+			data.Modules = append(data.Modules, webModule{
+				Name:        s.name,
+				Description: "",
+				URL:         s.key,
+				Config:      ConfigHelp[s.key],
+			})
+		}
+	}
+
 	for _, m := range modules {
 		config, _ := ConfigHelp[strings.ToLower(m.Name())]
 		module := webModule{
@@ -150,7 +172,7 @@ func (sb *SweetieBot) generateCache(webdir string) *template.Template {
 	if home, err := os.Create("home.tcache"); err == nil {
 		defer home.Close()
 		if err = t.ExecuteTemplate(home, "home", data); err != nil {
-			fmt.Println(err)
+			sb.Logger.Error("Error generating home page:", err)
 		}
 	}
 
@@ -160,7 +182,7 @@ func (sb *SweetieBot) generateCache(webdir string) *template.Template {
 			data.Title = m.Name
 			data.Index = k
 			if err = t.ExecuteTemplate(cache, "module", data); err != nil {
-				fmt.Println(err)
+				sb.Logger.Error("Error generating page for", m.Name+":", err)
 			}
 		}
 	}
@@ -169,12 +191,22 @@ func (sb *SweetieBot) generateCache(webdir string) *template.Template {
 
 // ServeWeb starts a webserver on :80 and optionally on :443. If you're doing a reverse-proxy via nginx, SSL terminates at nginx, so use insecure mode.
 func (sb *SweetieBot) ServeWeb() error {
+	if !sb.WebSecure && len(sb.WebPort) == 0 {
+		return nil // Setting webport to an empty string disables the website entirely
+	}
 	sb.generateCache(sb.Selfhoster.GetWebDir())
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", sb.Selfhoster.helpHandler)
 	mux.HandleFunc("/help", sb.Selfhoster.helpHandler)
 	mux.HandleFunc("/help/", sb.Selfhoster.helpHandler)
+	mux.HandleFunc("/metrics", sb.metricsHandler)
+	mux.HandleFunc("/calendar/", sb.calendarHandler)
+	if len(sb.OAuthSecret) > 0 {
+		if err := sb.ConfigureDashboard(mux, sb.Selfhoster.GetWebDir()); err != nil {
+			sb.Logger.Error("Error starting dashboard:", err)
+		}
+	}
 	sb.Selfhoster.ConfigureMux(mux)
 	if sb.WebSecure {
 		go http.ListenAndServe(":80", http.HandlerFunc(fwdhttps))
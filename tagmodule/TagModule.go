@@ -3,7 +3,6 @@ package tagmodule
 import (
 	"database/sql"
 	"fmt"
-	"regexp"
 	"sort"
 	"strings"
 
@@ -14,18 +13,17 @@ import (
 const (
 	maxPublicUniqueItems = 5000
 	maxTagResults        = 50
+	maxCachedQueries     = 200
 )
 
-var tagargregex = regexp.MustCompile("[^-+()| ][^-+()|]*")
-
 // TagModule contains commands for manipulating tags
 type TagModule struct {
-	Cache map[string]*sql.Stmt
+	Cache *bot.StatementCache
 }
 
 // New instance of TagModule
 func New() *TagModule {
-	return &TagModule{make(map[string]*sql.Stmt)}
+	return &TagModule{bot.NewStatementCache(maxCachedQueries)}
 }
 
 // Name of the module
@@ -52,30 +50,27 @@ func (w *TagModule) Commands() []bot.Command {
 func (w *TagModule) Description() string {
 	return "Contains commands for manipulating tags."
 }
-func (w *TagModule) prepStatement(query string, tags string, db *bot.BotDB) (*sql.Stmt, error) {
-	stmt, ok := w.Cache[query]
-	if !ok {
-		var err error
-		stmt, err = db.Prepare(query)
-		if err != nil {
-			//return nil, fmt.Errorf("```Invalid tag expression: %s```", err.Error())
-			return nil, fmt.Errorf("```Invalid tag expression: %s```", tags)
-		}
-		w.Cache[query] = stmt
-	}
-	return stmt, nil
-}
-
-func (w *TagModule) execStatement(query string, tags string, db *bot.BotDB, args ...interface{}) (*sql.Rows, error) {
-	stmt, err := w.prepStatement(query, tags, db)
-	if err != nil {
+func (w *TagModule) execStatement(query string, db *bot.BotDB, args ...interface{}) (*sql.Rows, error) {
+	stmt, release, err := w.Cache.Prepare(query, db)
+	if db.CheckError("Prepare: "+query, err) != nil {
 		return nil, err
 	}
+	defer release()
 
 	q, err := stmt.Query(args...)
 	return q, db.CheckError("Query: "+query, err)
 }
 
+// queryRow runs a query that returns a single row and scans it into dest
+func (w *TagModule) queryRow(query string, db *bot.BotDB, dest interface{}, args ...interface{}) error {
+	stmt, release, err := w.Cache.Prepare(query, db)
+	if db.CheckError("Prepare: "+query, err) != nil {
+		return err
+	}
+	defer release()
+	return stmt.QueryRow(args...).Scan(dest)
+}
+
 func getTagIDs(tags []string, guild uint64, db *bot.BotDB) ([]uint64, error) {
 	tagIDs := make([]uint64, len(tags), len(tags))
 	for k, v := range tags {
@@ -90,14 +85,24 @@ func getTagIDs(tags []string, guild uint64, db *bot.BotDB) ([]uint64, error) {
 	return tagIDs, nil
 }
 
-// BuildWhereClause returns a valid mySQL WHERE clause and argument list from a logical tag expression
-func BuildWhereClause(arg string) (string, []string) {
-	args := tagargregex.FindAllString(arg, -1)
-	arg = tagargregex.ReplaceAllString(arg, "M.Item IN (SELECT Item FROM itemtags WHERE Tag = ?)")
-	arg = strings.Replace(arg, "-", " NOT ", -1)
-	arg = strings.Replace(arg, "+", " AND ", -1)
-	arg = strings.Replace(arg, "|", " OR ", -1)
-	return arg, args
+// BuildWhereClause parses a tag expression and returns a SQL condition for it, along with the IDs of the tags it
+// refers to, in the order their placeholders appear. Equivalent expressions produce the same condition, so they share
+// a prepared statement.
+func BuildWhereClause(arg string, guild uint64, db *bot.BotDB) (string, []interface{}, error) {
+	expr, err := bot.ParseTagExpr(arg)
+	if err != nil {
+		return "", nil, err
+	}
+	clause, tags := bot.TagWhereClause(expr)
+	tagIDs, err := getTagIDs(tags, guild, db)
+	if err != nil {
+		return "", nil, err
+	}
+	params := make([]interface{}, len(tagIDs), len(tagIDs)+2)
+	for k, v := range tagIDs {
+		params[k] = v
+	}
+	return clause, params, nil
 }
 
 type addCommand struct {
@@ -347,19 +352,14 @@ func (c *tagsCommand) Process(args []string, msg *discordgo.Message, indices []i
 	}
 
 	arg := msg.Content[indices[0]:]
-	clause, tags := BuildWhereClause(arg)
 	gID := bot.SBatoi(info.ID)
-	tagIDs, err := getTagIDs(tags, gID, info.Bot.DB)
+	clause, params, err := BuildWhereClause(arg, gID, info.Bot.DB)
 	if err != nil {
 		return bot.ReturnError(err)
 	}
 
-	params := make([]interface{}, len(tagIDs), len(tagIDs))
-	for k, v := range tagIDs {
-		params[k] = v
-	}
 	params = append(params, gID)
-	q, err := c.w.execStatement("SELECT I.Content FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID INNER JOIN items I ON M.Item = I.ID WHERE "+clause+" AND T.Guild = ? GROUP BY I.Content", arg, info.Bot.DB, params...)
+	q, err := c.w.execStatement("SELECT I.Content FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID INNER JOIN items I ON M.Item = I.ID WHERE ("+clause+") AND T.Guild = ? GROUP BY I.Content", info.Bot.DB, params...)
 	if err != nil {
 		return bot.ReturnError(err)
 	}
@@ -414,40 +414,25 @@ func (c *pickCommand) Process(args []string, msg *discordgo.Message, indices []i
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
 	gID := bot.SBatoi(info.ID)
-	var stmt *sql.Stmt
-	var params []interface{}
+	query := "SELECT I.Content FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID INNER JOIN items I ON M.Item = I.ID WHERE T.Guild = ? GROUP BY I.Content ORDER BY RAND() LIMIT 1"
+	params := []interface{}{gID}
 	arg := "any tag"
-	if len(args) < 1 || args[0] == "*" {
-		var err error
-		stmt, err = c.w.prepStatement("SELECT I.Content FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID INNER JOIN items I ON M.Item = I.ID WHERE T.Guild = ? GROUP BY I.Content ORDER BY RAND() LIMIT 1", "", info.Bot.DB)
-		if err != nil {
-			return bot.ReturnError(err)
-		}
-		params = []interface{}{gID}
-	} else {
+	if len(args) > 0 && args[0] != "*" {
 		//arg = msg.Content[indices[0]:]
 		arg = args[0]
-		clause, tags := BuildWhereClause(arg)
-		tagIDs, err := getTagIDs(tags, gID, info.Bot.DB)
-		if err != nil {
-			return bot.ReturnError(err)
-		}
-
-		stmt, err = c.w.prepStatement("SELECT I.Content FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID INNER JOIN items I ON M.Item = I.ID WHERE "+clause+" AND T.Guild = ? GROUP BY I.Content ORDER BY RAND() LIMIT 1", arg, info.Bot.DB)
+		var clause string
+		var err error
+		clause, params, err = BuildWhereClause(arg, gID, info.Bot.DB)
 		if err != nil {
 			return bot.ReturnError(err)
 		}
 
-		params = make([]interface{}, len(tagIDs), len(tagIDs))
+		query = "SELECT I.Content FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID INNER JOIN items I ON M.Item = I.ID WHERE (" + clause + ") AND T.Guild = ? GROUP BY I.Content ORDER BY RAND() LIMIT 1"
 		params = append(params, gID)
-		for k, v := range tagIDs {
-			params[k] = v
-		}
 	}
 
 	var item string
-	q := stmt.QueryRow(params...)
-	err := q.Scan(&item)
+	err := c.w.queryRow(query, info.Bot.DB, &item, params...)
 	if err == sql.ErrNoRows {
 		return fmt.Sprintf("```No items were returned by %s!```", arg), false, nil
 	} else if err != nil {
@@ -566,42 +551,31 @@ func (c *searchTagsCommand) Process(args []string, msg *discordgo.Message, indic
 	}
 	arg := args[0]
 	clause := "1=1"
-	tags := []string{}
-	if arg != "*" {
-		clause, tags = BuildWhereClause(arg)
-	}
-
+	params := []interface{}{}
 	gID := bot.SBatoi(info.ID)
-	tagIDs, err := getTagIDs(tags, gID, info.Bot.DB)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-
-	params := make([]interface{}, len(tagIDs), len(tagIDs))
-	for k, v := range tagIDs {
-		params[k] = v
+	if arg != "*" {
+		var err error
+		if clause, params, err = BuildWhereClause(arg, gID, info.Bot.DB); err != nil {
+			return bot.ReturnError(err)
+		}
 	}
 	params = append(params, gID)
 
 	if len(args) < 2 {
-		stmt, err := c.w.prepStatement("SELECT COUNT(DISTINCT I.Content) FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID INNER JOIN items I ON M.Item = I.ID WHERE ("+clause+") AND T.Guild = ?", arg, info.Bot.DB)
-		if err == nil {
-			var count uint64
-			q := stmt.QueryRow(params...)
-			err := q.Scan(&count)
-			if err == sql.ErrNoRows {
-				return fmt.Sprintf("```No items were returned by %s!```", arg), false, nil
-			} else if err == nil {
-				return fmt.Sprintf("```Number of items matching %s: %v```", arg, count), false, nil
-			}
+		var count uint64
+		err := c.w.queryRow("SELECT COUNT(DISTINCT I.Content) FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID INNER JOIN items I ON M.Item = I.ID WHERE ("+clause+") AND T.Guild = ?", info.Bot.DB, &count, params...)
+		if err == sql.ErrNoRows {
+			return fmt.Sprintf("```No items were returned by %s!```", arg), false, nil
+		} else if err != nil {
+			return bot.ReturnError(err)
 		}
-		return bot.ReturnError(err)
+		return fmt.Sprintf("```Number of items matching %s: %v```", arg, count), false, nil
 	}
 
 	search := "%" + msg.Content[indices[1]:] + "%"
 	params = append(params, search)
 
-	q, err := c.w.execStatement("SELECT I.Content FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID INNER JOIN items I ON M.Item = I.ID WHERE ("+clause+") AND T.Guild = ? AND I.Content LIKE ? GROUP BY I.Content", arg, info.Bot.DB, params...)
+	q, err := c.w.execStatement("SELECT I.Content FROM itemtags M INNER JOIN tags T ON M.Tag = T.ID INNER JOIN items I ON M.Item = I.ID WHERE ("+clause+") AND T.Guild = ? AND I.Content LIKE ? GROUP BY I.Content", info.Bot.DB, params...)
 	if err != nil {
 		return bot.ReturnError(err)
 	}
@@ -3,7 +3,6 @@ package usersmodule
 import (
 	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
@@ -28,17 +27,24 @@ func (w *UsersModule) Name() string {
 // Commands in the module
 func (w *UsersModule) Commands() []bot.Command {
 	return []bot.Command{
-		&newUsersCommand{},
-		&akaCommand{},
-		&banCommand{},
-		&banNewcomersCommand{},
-		&timeCommand{},
-		&setTimeZoneCommand{},
-		&userInfoCommand{},
-		&defaultServerCommand{},
-		&silenceCommand{},
-		&unsilenceCommand{},
-		&assignRoleCommand{},
+		bot.TypedCommand(&newUsersCommand{}),
+		bot.TypedCommand(&akaCommand{}),
+		bot.TypedCommand(&banCommand{}),
+		bot.TypedCommand(&banNewcomersCommand{}),
+		bot.TypedCommand(&timeCommand{}),
+		bot.TypedCommand(&setTimeZoneCommand{}),
+		bot.TypedCommand(&userInfoCommand{}),
+		bot.TypedCommand(&defaultServerCommand{}),
+		bot.TypedCommand(&silenceCommand{}),
+		bot.TypedCommand(&unsilenceCommand{}),
+		bot.TypedCommand(&timeoutCommand{}),
+		bot.TypedCommand(&kickCommand{}),
+		bot.TypedCommand(&assignRoleCommand{}),
+		bot.TypedCommand(&warnCommand{}),
+		bot.TypedCommand(&casesCommand{}),
+		bot.TypedCommand(&caseCommand{}),
+		bot.TypedCommand(&editReasonCommand{}),
+		bot.TypedCommand(&pardonCommand{}),
 	}
 }
 
@@ -52,7 +58,11 @@ func (w *UsersModule) OnGuildMemberAdd(info *bot.GuildInfo, m *discordgo.Member,
 	if info.Config.Users.NotifyChannel != bot.ChannelEmpty {
 		created := "(Created " + bot.TimeDiff(t.Sub(bot.SnowflakeTime(bot.SBatoi(m.User.ID)))) + " ago) joined"
 		if info.Config.Spam.AutoSilence >= 2 || (info.Config.Spam.AutoSilence >= 1 && ((info.LastRaid + info.Config.Spam.RaidTime*2) > t.Unix())) {
-			created += " and was silenced"
+			if info.Config.Spam.TimeoutDuration > 0 {
+				created += " and was timed out"
+			} else {
+				created += " and was silenced"
+			}
 		}
 		info.SendMessage(info.Config.Users.NotifyChannel, "<@"+m.User.ID+"> "+created+".")
 	}
@@ -68,18 +78,18 @@ func (w *UsersModule) OnGuildMemberRemove(info *bot.GuildInfo, m *discordgo.Memb
 
 // assignRoleMember adds a role to a member that already exists
 func assignRoleMember(info *bot.GuildInfo, userID bot.DiscordUser, roleID bot.DiscordRole) (int8, error) {
-	m, merr := info.Bot.DG.GetMember(userID, info.ID)
+	m, merr := info.DG.GetMember(userID, info.ID)
 	if merr == nil { // Manually set our internal state to say this role is set to prevent race conditions
-		info.Bot.DG.State.Lock()
+		info.DG.State.Lock()
 		if bot.MemberHasRole(m, roleID) {
-			info.Bot.DG.State.Unlock()
-			return 1, info.ResolveRoleAddError(info.Bot.DG.GuildMemberRoleAdd(info.ID, userID.String(), roleID.String()))
+			info.DG.State.Unlock()
+			return 1, info.ResolveRoleAddError(info.DG.GuildMemberRoleAdd(info.ID, userID.String(), roleID.String()))
 		}
 		m.Roles = append(m.Roles, roleID.String())
-		info.Bot.DG.State.Unlock()
+		info.DG.State.Unlock()
 	}
 
-	return 0, info.ResolveRoleAddError(info.Bot.DG.GuildMemberRoleAdd(info.ID, userID.String(), roleID.String()))
+	return 0, info.ResolveRoleAddError(info.DG.GuildMemberRoleAdd(info.ID, userID.String(), roleID.String()))
 }
 
 type newUsersCommand struct {
@@ -92,14 +102,11 @@ func (c *newUsersCommand) Info() *bot.CommandInfo {
 	}
 }
 
-func (c *newUsersCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *newUsersCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	maxresults := 5
-	if len(args) > 0 {
-		maxresults, _ = strconv.Atoi(args[0])
-	}
+	maxresults := args.Int("maxresults", 5)
 	if maxresults < 1 {
 		return "```\nHow I return no results???```", false, nil
 	}
@@ -118,7 +125,7 @@ func (c *newUsersCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Lists up to maxresults users, starting with the newest user to join the server.",
 		Params: []bot.CommandUsageParam{
-			{Name: "maxresults", Desc: "Defaults to 5 results, returns a maximum of 40.", Optional: true},
+			{Name: "maxresults", Desc: "Defaults to 5 results, returns a maximum of 40.", Optional: true, Type: bot.ArgInt},
 		},
 	}
 }
@@ -136,20 +143,13 @@ func (c *akaCommand) Info() *bot.CommandInfo {
 func (c *akaCommand) Name() string {
 	return "aka"
 }
-func (c *akaCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *akaCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	if len(args) < 1 {
-		return "```\nYou must provide a user to search for.```", false, nil
-	}
-	user, err := bot.ParseUser(msg.Content[indices[0]:], info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-
+	user := args.User("user")
 	r := info.Bot.DB.GetAliases(user.Convert())
-	u, err := info.Bot.DG.GetMember(user, info.ID)
+	u, err := info.DG.GetMember(user, info.ID)
 	if err != nil {
 		return bot.ReturnError(err)
 	}
@@ -166,63 +166,35 @@ func (c *akaCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Lists all known aliases of the user in question, up to a maximum of 10, with the names used the longest first.",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: true},
+			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false, Type: bot.ArgUser},
 		},
 	}
 }
 
-func processDurationAndReason(args []string, msg *discordgo.Message, indices []int, ty uint8, data string, gID uint64, db *bot.BotDB) (string, error) {
-	if ty == 6 {
-		return "", errors.New("Illegal event type.")
+// scheduleEvent adds an event that will fire once the duration given with for: has passed, if there was one, and
+// returns its ID
+func scheduleEvent(args *bot.CommandArgs, msg *discordgo.Message, ty uint8, data string, info *bot.GuildInfo) (*uint64, error) {
+	if !args.Has("duration") {
+		return nil, nil
 	}
-	reason := ""
-	if len(args) > 0 {
-		if strings.ToLower(args[0]) == "for:" {
-			if len(args) < 3 {
-				return "", errors.New("Duration should be specified as 'for: 5 DAYS' or 'for: 72 HOURS'")
-			}
-			duration, err := strconv.Atoi(args[1])
-			if err != nil {
-				return "", errors.New("Duration number was not an integer.")
-			}
-
-			t := bot.GetTimestamp(msg)
-			switch bot.ParseRepeatInterval(args[2]) {
-			case 1:
-				t = t.Add(time.Duration(duration) * time.Second)
-			case 2:
-				t = t.Add(time.Duration(duration) * time.Minute)
-			case 3:
-				t = t.Add(time.Duration(duration) * time.Hour)
-			case 4:
-				t = t.AddDate(0, 0, duration)
-			case 5:
-				t = t.AddDate(0, 0, duration*7)
-			case 6:
-				t = t.AddDate(0, duration, 0)
-			case 8:
-				t = t.AddDate(duration, 0, 0)
-			case 7, 255:
-				return "", errors.New("unrecognized interval.")
-			}
-
-			if err := db.AddSchedule(gID, t, ty, data); err != nil {
-				return "", err
-			}
-
-			scheduleID := db.FindEvent(data, gID, ty)
-			if scheduleID == nil {
-				return "", errors.New("Could not find inserted event!")
-			}
+	gID := bot.SBatoi(info.ID)
+	if err := info.Bot.DB.AddSchedule(gID, args.Duration("duration").After(bot.GetTimestamp(msg)), ty, data); err != nil {
+		return nil, err
+	}
+	id := info.Bot.DB.FindEvent(data, gID, ty)
+	if id == nil {
+		return nil, errors.New("Could not find inserted event!")
+	}
+	return id, nil
+}
 
-			if len(args) > 3 {
-				reason = msg.Content[indices[3]:]
-			}
-		} else {
-			reason = msg.Content[indices[0]:]
-		}
+// caseDuration returns how long the duration given with for: lasts from the time of the message, or zero if there wasn't one
+func caseDuration(args *bot.CommandArgs, msg *discordgo.Message) time.Duration {
+	if !args.Has("duration") {
+		return 0
 	}
-	return reason, nil
+	t := bot.GetTimestamp(msg)
+	return args.Duration("duration").After(t).Sub(t)
 }
 
 // Ban command that tracks who banned someone, why, and optionally make the ban temporary
@@ -241,37 +213,32 @@ func (c *banCommand) Name() string {
 	return "ban"
 }
 
-func (c *banCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *banCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	if len(args) < 1 {
-		return "```\nYou must specify a username (in quotes, if it has spaces).```", false, nil
-	}
-	name, err := bot.ParseUser(args[0], info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-	reason, err := processDurationAndReason(args[1:], msg, indices[1:], 0, name.String(), bot.SBatoi(info.ID), info.Bot.DB)
+	name := args.User("user")
+	event, err := scheduleEvent(args, msg, bot.EventBan, name.String(), info)
 	if err != nil {
 		return bot.ReturnError(err)
 	}
-	reason = fmt.Sprintf("Banned by %s#%s for %s", msg.Author.Username, msg.Author.Discriminator, reason)
+	reason := fmt.Sprintf("Banned by %s#%s for %s", msg.Author.Username, msg.Author.Discriminator, args.String("reason"))
 	username := info.GetUserName(name)
 
-	err = info.Bot.DG.GuildBanCreateWithReason(info.ID, name.String(), reason, 1) // Note that this will probably generate a SawBan event
+	err = info.DG.GuildBanCreateWithReason(info.ID, name.String(), reason, 1) // Note that this will probably generate a SawBan event
 	if err != nil {
 		return bot.ReturnError(err)
 	}
-	return "```\nBanned " + username + " from the server.```", false, nil
+	number := info.AddCase(bot.CaseBan, name, bot.DiscordUser(msg.Author.ID), args.String("reason"), caseDuration(args, msg), event)
+	return "```\nBanned " + username + " from the server." + caseSuffix(number) + "```", false, nil
 }
 func (c *banCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Bans the given user. Examples: `'" + info.Config.Basic.CommandPrefix + "ban @CrystalFlash for: 5 MINUTES because he's a dunce` or `" + info.Config.Basic.CommandPrefix + "ban \"Name With Spaces\" caught stealing cookies`",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A ping of the user, or simply their name. If the name has spaces, this argument must be put in quotes.", Optional: false},
-			{Name: "for: duration", Desc: "If the keyword `for:` is used after the username, looks for a duration of the form `for: 50 MINUTES` and creates an unban event that will be fired after that much time has passed from now.", Optional: true},
-			{Name: "reason", Desc: "The rest of the message is treated as a reason for the ban.", Optional: true},
+			{Name: "user", Desc: "A ping of the user, or simply their name. If the name has spaces, this argument must be put in quotes.", Optional: false, Type: bot.ArgUser},
+			{Name: "duration", Desc: "If the keyword `for:` is used after the username, looks for a duration of the form `for: 50 MINUTES` and creates an unban event that will be fired after that much time has passed from now.", Optional: true, Type: bot.ArgDuration, Keyword: "for:"},
+			{Name: "reason", Desc: "The rest of the message is treated as a reason for the ban.", Optional: true, Type: bot.ArgRest},
 		},
 	}
 }
@@ -288,18 +255,11 @@ func (c *banNewcomersCommand) Info() *bot.CommandInfo {
 	}
 }
 
-func (c *banNewcomersCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *banNewcomersCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	duration := 120
-	if len(args) > 0 {
-		var err error
-		duration, err = strconv.Atoi(args[0])
-		if err != nil {
-			return "```\nThat's not a valid number of seconds!```", false, nil
-		}
-	}
+	duration := args.Int("duration", 120)
 
 	IDs := info.Bot.DB.GetNewcomers(duration, bot.SBatoi(info.ID))
 	if len(IDs) == 0 {
@@ -307,8 +267,11 @@ func (c *banNewcomersCommand) Process(args []string, msg *discordgo.Message, ind
 	}
 	reason := fmt.Sprintf("Banned by %s#%s via the !bannewcomers command", msg.Author.Username, msg.Author.Discriminator)
 	for _, id := range IDs {
-		err := info.Bot.DG.GuildBanCreateWithReason(info.ID, bot.SBitoa(id), reason, 1)
-		info.LogError("Error banning user: ", err)
+		err := info.DG.GuildBanCreateWithReason(info.ID, bot.SBitoa(id), reason, 1)
+		info.MessageLogger(msg).With(bot.LogFields{"target": bot.SBitoa(id)}).LogError("Error banning user: ", err)
+		if err == nil {
+			info.AddCase(bot.CaseBan, bot.NewDiscordUser(id), bot.DiscordUser(msg.Author.ID), "Banned by the bannewcomers command.", 0, nil)
+		}
 	}
 
 	return fmt.Sprintf("```Banned %v people from the server. Use discord's audit log if you need to reverse a ban.```", len(IDs)), false, nil
@@ -317,7 +280,7 @@ func (c *banNewcomersCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Bans all users who have sent their first message in the past `duration` seconds.",
 		Params: []bot.CommandUsageParam{
-			{Name: "duration", Desc: "The number of seconds to look back, defaults to 120 seconds (so anyone who sent their first message in the past 2 minutes would be banned).", Optional: true},
+			{Name: "duration", Desc: "The number of seconds to look back, defaults to 120 seconds (so anyone who sent their first message in the past 2 minutes would be banned).", Optional: true, Type: bot.ArgInt},
 		},
 	}
 }
@@ -332,19 +295,15 @@ func (c *timeCommand) Info() *bot.CommandInfo {
 	}
 }
 
-func (c *timeCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *timeCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	if len(args) < 1 {
+	if !args.Has("user") {
 		return "```\nThis server's local time is: " + info.ApplyTimezone(bot.GetTimestamp(msg), bot.UserEmpty).Format("Jan 2, 3:04pm```"), false, nil
 	}
 
-	user, err := bot.ParseUser(msg.Content[indices[0]:], info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-	tz := info.Bot.DB.GetTimeZone(user.Convert())
+	tz := info.Bot.DB.GetTimeZone(args.User("user").Convert())
 	if tz == nil {
 		return "```\nThat user has not specified what their timezone is.```", false, nil
 	}
@@ -354,7 +313,7 @@ func (c *timeCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Gets the local time for the specified user, or simply gets the local time for this server.",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: true},
+			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: true, Type: bot.ArgUser},
 		},
 	}
 }
@@ -372,26 +331,20 @@ func (c *setTimeZoneCommand) Name() string {
 	return "settimezone"
 }
 
-func (c *setTimeZoneCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *setTimeZoneCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	if len(args) < 1 {
-		return "```\nYou have to specify what your timezone is!```", false, nil
-	}
 	tz := []string{}
-	if len(args) < 2 {
-		tz = info.Bot.DB.FindTimeZone("%" + args[0] + "%")
+	search := "%" + args.String("timezone") + "%"
+	if !args.Has("offset") {
+		tz = info.Bot.DB.FindTimeZone(search)
 	} else {
-		offset, err := strconv.Atoi(args[1])
-		if err != nil {
-			return "```\nCould not parse offset. Note that timezones do not have spaces - use underscores (_) instead. The second argument should be your time difference from GMT in hours. For example, PDT is GMT-7, so you could search for \"America -7\".```", false, nil
-		}
-		tz = info.Bot.DB.FindTimeZoneOffset("%"+args[0]+"%", offset*60)
+		tz = info.Bot.DB.FindTimeZoneOffset(search, args.Int("offset", 0)*60)
 	}
 
 	if len(tz) < 1 {
-		if len(args) < 2 {
+		if !args.Has("offset") {
 			return "```\nCould not find any timezone locations that match that string. Try broadening your search (for example, search for 'America' or 'Pacific').```", false, nil
 		}
 		return "```\nCould not find any timezone locations that match that string and offset combination. Try broadening your search, or leaving out the timezone offset parameter.```", false, nil
@@ -414,8 +367,8 @@ func (c *setTimeZoneCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Sets your timezone to the given location. Providing a partial timezone name, like \"America\", will return a list of all possible timezones that contain that string.",
 		Params: []bot.CommandUsageParam{
-			{Name: "timezone", Desc: "A timezone location, such as `America/Los_Angeles`. Note that timezones do not have spaces.", Optional: true},
-			{Name: "offset", Desc: "Your expected timezone offset in hours, used to narrow the search. For example, if you know you're in the PDT timezone, which is GMT-7, you could search for `America -7` to list all timezones in america with a standard or DST timezone offset of -7.", Optional: true},
+			{Name: "timezone", Desc: "A timezone location, such as `America/Los_Angeles`. Note that timezones do not have spaces - use underscores (_) instead.", Optional: false},
+			{Name: "offset", Desc: "Your expected timezone offset in hours, used to narrow the search. For example, if you know you're in the PDT timezone, which is GMT-7, you could search for `America -7` to list all timezones in america with a standard or DST timezone offset of -7.", Optional: true, Type: bot.ArgInt},
 		},
 	}
 }
@@ -433,18 +386,11 @@ func (c *userInfoCommand) Info() *bot.CommandInfo {
 func (c *userInfoCommand) Name() string {
 	return "UserInfo"
 }
-func (c *userInfoCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *userInfoCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
-	if len(args) < 1 {
-		return "```\nYou must provide a user to search for.```", false, nil
-	}
-
-	user, err := bot.ParseUser(msg.Content[indices[0]:], info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
+	user := args.User("user")
 	id := user.Convert()
 	aliases := info.Bot.DB.GetAliases(id)
 	dbuser, lastseen, tz, _ := info.Bot.DB.GetUser(id)
@@ -457,14 +403,14 @@ func (c *userInfoCommand) Process(args []string, msg *discordgo.Message, indices
 	} else {
 		localtime = timestamp.In(tz).Format(time.RFC1123)
 	}
-	m, err := info.Bot.DG.GetMember(user, info.ID)
+	m, err := info.DG.GetMember(user, info.ID)
 
 	if err != nil {
 		m = dbmember
 		if m == nil {
 			m = &discordgo.Member{Roles: []string{}}
 		}
-		u, err := info.Bot.DG.User(user.String())
+		u, err := info.DG.User(user.String())
 		if err != nil {
 			if dbuser == nil {
 				return "```\nError retrieving user information: " + err.Error() + "```", false, nil
@@ -519,7 +465,7 @@ func (c *userInfoCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Lists the ID, username, nickname, timezone, roles, avatar, join date, and other information about a given user.",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false},
+			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false, Type: bot.ArgUser},
 		},
 	}
 }
@@ -537,22 +483,18 @@ func (c *defaultServerCommand) Info() *bot.CommandInfo {
 func (c *defaultServerCommand) Name() string {
 	return "DefaultServer"
 }
-func (c *defaultServerCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+func (c *defaultServerCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
 	if !info.Bot.DB.CheckStatus() {
 		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
 	}
 	gIDs := info.Bot.DB.GetUserGuilds(bot.SBatoi(msg.Author.ID))
-	find := ""
-	if len(args) > 0 {
-		find = msg.Content[indices[0]:]
-	}
-	guilds := info.Bot.FindServers(find, gIDs)
+	guilds := info.Bot.FindServers(args.String("server"), gIDs)
 	names := make([]string, len(guilds), len(guilds))
 	for k, v := range guilds {
 		names[k] = v.Name
 	}
 
-	if len(args) < 1 {
+	if !args.Has("server") {
 		server := info.Bot.GetDefaultServer(bot.SBatoi(msg.Author.ID))
 		if server != nil {
 			return fmt.Sprintf("```Your default server is %s. You are on the following servers:\n%s```", server.Name, strings.Join(names, "\n")), false, nil
@@ -567,7 +509,7 @@ func (c *defaultServerCommand) Process(args []string, msg *discordgo.Message, in
 	}
 
 	target := bot.SBatoi(guilds[0].ID)
-	_, err := info.Bot.DG.GuildMember(guilds[0].ID, msg.Author.ID) // Attempt to verify the user is actually in this guild.
+	_, err := info.DG.GuildMember(guilds[0].ID, msg.Author.ID) // Attempt to verify the user is actually in this guild.
 	if err != nil {
 		return fmt.Sprintf("```You aren't a member of %s (or discord blew up, in which case, try again).```", guilds[0].Name), false, nil
 	}
@@ -578,7 +520,7 @@ func (c *defaultServerCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Sets the default server SB will run commands on that you PM to her.",
 		Params: []bot.CommandUsageParam{
-			{Name: "server", Desc: "The exact name of your default server.", Optional: false},
+			{Name: "server", Desc: "The exact name of your default server. If omitted, lists your current default server and all the servers you are on.", Optional: true, Type: bot.ArgRest},
 		},
 	}
 }
@@ -594,25 +536,10 @@ func (c *silenceCommand) Info() *bot.CommandInfo {
 	}
 }
 
-func (c *silenceCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	if len(args) < 1 {
-		return "```\nYou must provide a user to silence.```", false, nil
-	}
-	index := len(args)
-	for i := 1; i < len(args); i++ {
-		if strings.ToLower(args[i]) == "for:" {
-			index = i
-			break
-		}
-	}
-
-	user, err := bot.ParseUser(strings.Join(args[0:index], " "), info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-
+func (c *silenceCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	user := args.User("user")
 	gID := bot.SBatoi(info.ID)
-	reason, err := processDurationAndReason(args[index:], msg, indices[index:], 8, user.String(), gID, info.Bot.DB)
+	event, err := scheduleEvent(args, msg, bot.EventSilence, user.String(), info)
 	if err != nil {
 		return bot.ReturnError(err)
 	}
@@ -623,7 +550,7 @@ func (c *silenceCommand) Process(args []string, msg *discordgo.Message, indices
 	} else if code == 1 {
 		var t *time.Time
 		if info.Bot.DB.Status.Get() {
-			t = info.Bot.DB.GetScheduleDate(gID, 8, user.String())
+			t = info.Bot.DB.GetScheduleDate(gID, bot.EventSilence, user.String())
 		}
 		if t == nil {
 			return "```\n" + info.GetUserName(user) + " is already silenced!```", false, nil
@@ -633,17 +560,16 @@ func (c *silenceCommand) Process(args []string, msg *discordgo.Message, indices
 	if len(info.Config.Users.SilenceMessage) > 0 {
 		info.SendMessage(info.Config.Users.WelcomeChannel, user.Display()+info.Config.Users.SilenceMessage)
 	}
-	if len(reason) > 0 {
-		reason = " because " + reason
-	}
-	return fmt.Sprintf("```\nSilenced %s%s.```", info.GetUserName(user), reason), false, nil
+	number := info.AddCase(bot.CaseSilence, user, bot.DiscordUser(msg.Author.ID), args.String("reason"), caseDuration(args, msg), event)
+	return fmt.Sprintf("```\nSilenced %s.%s```", info.GetUserName(user), caseSuffix(number)), false, nil
 }
 func (c *silenceCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
-		Desc: "Silences the given user.",
+		Desc: "Silences the given user. Example: `" + info.Config.Basic.CommandPrefix + "silence Name With Spaces for: 50 MINUTES because: spamming`",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false},
-			{Name: "for: duration", Desc: "If the keyword `for:` is used after the username, looks for a duration of the form `for: 50 MINUTES` and creates an unsilence event that will be fired after that much time has passed from now.", Optional: true},
+			{Name: "user", Desc: "A ping of the user, or simply their name. Names with spaces don't need quotes, because everything up to `for:` or `because:` is part of the name.", Optional: false, Type: bot.ArgUser},
+			{Name: "duration", Desc: "If the keyword `for:` is used after the username, looks for a duration of the form `for: 50 MINUTES` and creates an unsilence event that will be fired after that much time has passed from now.", Optional: true, Type: bot.ArgDuration, Keyword: "for:"},
+			{Name: "reason", Desc: "If the keyword `because:` is used, the rest of the message is treated as a reason for the silence, which is recorded in the case.", Optional: true, Type: bot.ArgRest, Keyword: "because:"},
 		},
 	}
 }
@@ -659,67 +585,107 @@ func (c *unsilenceCommand) Info() *bot.CommandInfo {
 	}
 }
 
-func (c *unsilenceCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	if len(args) < 1 {
-		return "```\nYou must provide a user to unsilence.```", false, nil
-	}
-	user, err := bot.ParseUser(msg.Content[indices[0]:], info)
-	if err != nil {
-		return bot.ReturnError(err)
-	}
-
-	err = info.Bot.DG.RemoveRole(info.ID, user, info.Config.Basic.SilenceRole)
+func (c *unsilenceCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	user := args.User("user")
+	err := info.DG.RemoveRole(info.ID, user, info.Config.Basic.SilenceRole)
 	if err != nil {
 		return "```\nError unsilencing member: " + err.Error() + "```", false, nil
 	}
-	return "```\nUnsilenced " + info.GetUserName(user) + ".```", false, nil
+	number := info.AddCase(bot.CaseUnsilence, user, bot.DiscordUser(msg.Author.ID), args.String("reason"), 0, nil)
+	return "```\nUnsilenced " + info.GetUserName(user) + "." + caseSuffix(number) + "```", false, nil
 }
 func (c *unsilenceCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Unsilences the given user.",
 		Params: []bot.CommandUsageParam{
-			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false},
+			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false, Type: bot.ArgUser},
+			{Name: "reason", Desc: "The rest of the message is treated as a reason for the unsilence, which is recorded in the case.", Optional: true, Type: bot.ArgRest},
 		},
 	}
 }
 
-type assignRoleCommand struct {
+type timeoutCommand struct {
 }
 
-func (c *assignRoleCommand) Info() *bot.CommandInfo {
+func (c *timeoutCommand) Info() *bot.CommandInfo {
 	return &bot.CommandInfo{
-		Name:      "AssignRole",
-		Usage:     "Assigns an arbitrary role to a user for an optional amount of time.",
+		Name:      "Timeout",
+		Usage:     "Times out a user.",
 		Sensitive: true,
 	}
 }
 
-func (c *assignRoleCommand) Process(args []string, msg *discordgo.Message, indices []int, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
-	if len(args) < 2 {
-		return "```\nYou must provide a role to assign and a user to assign it to.```", false, nil
-	}
-	index := len(args)
-	for i := 1; i < len(args); i++ {
-		if strings.ToLower(args[i]) == "for:" {
-			index = i
-			break
-		}
+func (c *timeoutCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	user := args.User("user")
+	timestamp := bot.GetTimestamp(msg)
+	until := timestamp.Add(bot.MaxTimeout)
+	if args.Has("duration") {
+		until = args.Duration("duration").After(timestamp)
 	}
-
-	g, err := info.GetGuild()
-	role, err := bot.ParseRole(args[0], g)
+	event, err := info.TimeoutMember(user, until)
 	if err != nil {
 		return bot.ReturnError(err)
 	}
+	number := info.AddCase(bot.CaseTimeout, user, bot.DiscordUser(msg.Author.ID), args.String("reason"), until.Sub(timestamp), event)
+	return fmt.Sprintf("```\nTimed out %s for %s.%s```", info.GetUserName(user), bot.TimeDiff(until.Sub(timestamp)), caseSuffix(number)), false, nil
+}
+func (c *timeoutCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Uses discord's native timeout to stop the given user from sending messages, reacting, or speaking, without needing a silence role. The mod channel is told when the timeout ends.",
+		Params: []bot.CommandUsageParam{
+			{Name: "user", Desc: "A ping of the user, or simply their name. Names with spaces don't need quotes, because everything up to `for:` or `because:` is part of the name.", Optional: false, Type: bot.ArgUser},
+			{Name: "duration", Desc: "If the keyword `for:` is used after the username, looks for a duration of the form `for: 50 MINUTES`. Discord doesn't allow timeouts longer than 28 days, which is the default.", Optional: true, Type: bot.ArgDuration, Keyword: "for:"},
+			{Name: "reason", Desc: "If the keyword `because:` is used, the rest of the message is treated as a reason for the timeout, which is recorded in the case.", Optional: true, Type: bot.ArgRest, Keyword: "because:"},
+		},
+	}
+}
 
-	user, err := bot.ParseUser(strings.Join(args[1:index], " "), info)
-	if err != nil {
+type kickCommand struct {
+}
+
+func (c *kickCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "Kick",
+		Usage:     "Kicks a user from the server.",
+		Sensitive: true,
+	}
+}
+
+func (c *kickCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	user := args.User("user")
+	username := info.GetUserName(user)
+	if err := info.DG.GuildMemberDelete(info.ID, user.String()); err != nil {
 		return bot.ReturnError(err)
 	}
+	number := info.AddCase(bot.CaseKick, user, bot.DiscordUser(msg.Author.ID), args.String("reason"), 0, nil)
+	return "```\nKicked " + username + " from the server." + caseSuffix(number) + "```", false, nil
+}
+func (c *kickCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Removes the given user from the server. Unlike a ban, they can rejoin with a new invite.",
+		Params: []bot.CommandUsageParam{
+			{Name: "user", Desc: "A ping of the user, or simply their name. Names with spaces don't need quotes, because everything up to `because:` is part of the name.", Optional: false, Type: bot.ArgUser},
+			{Name: "reason", Desc: "If the keyword `because:` is used, the rest of the message is treated as a reason for the kick, which is recorded in the case.", Optional: true, Type: bot.ArgRest, Keyword: "because:"},
+		},
+	}
+}
+
+type assignRoleCommand struct {
+}
+
+func (c *assignRoleCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "AssignRole",
+		Usage:     "Assigns an arbitrary role to a user for an optional amount of time.",
+		Sensitive: true,
+	}
+}
 
+func (c *assignRoleCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	role := args.Role("role")
+	user := args.User("user")
 	gID := bot.SBatoi(info.ID)
-	reason, err := processDurationAndReason(args[index:], msg, indices[index:], 9, user.String()+"|"+role.String(), gID, info.Bot.DB)
-	if err != nil {
+	if _, err := scheduleEvent(args, msg, bot.EventRemoveRole, bot.EventData{User: user, Role: role}.Encode(bot.EventRemoveRole), info); err != nil {
 		return bot.ReturnError(err)
 	}
 
@@ -729,25 +695,229 @@ func (c *assignRoleCommand) Process(args []string, msg *discordgo.Message, indic
 	} else if code == 1 {
 		var t *time.Time
 		if info.Bot.DB.Status.Get() {
-			t = info.Bot.DB.GetScheduleDate(gID, 9, user.String()+"|"+role.String())
+			t = info.Bot.DB.GetScheduleDate(gID, bot.EventRemoveRole, bot.EventData{User: user, Role: role}.Encode(bot.EventRemoveRole))
 		}
 		if t == nil {
 			return "```\n" + info.GetUserName(user) + " already has that role!```", false, nil
 		}
 		return fmt.Sprintf("```\n%s already has that role, which will be removed in %s```", info.GetUserName(user), bot.TimeDiff(t.Sub(bot.GetTimestamp(msg)))), false, nil
 	}
-	if len(reason) > 0 {
-		reason = " because " + reason
-	}
-	return fmt.Sprintf("```\nAssigned the %s role to %s%s.```", role.Show(info), info.GetUserName(user), reason), false, nil
+	return fmt.Sprintf("```\nAssigned the %s role to %s.```", role.Show(info), info.GetUserName(user)), false, nil
 }
 func (c *assignRoleCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
 	return &bot.CommandUsage{
 		Desc: "Assigns the role to the given user, and optionally adds an event to remove it in the future.",
 		Params: []bot.CommandUsageParam{
-			{Name: "role", Desc: "The role to add, either as a ping or as the name, but must be in quotes if it has spaces.", Optional: false},
-			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false},
-			{Name: "for: duration", Desc: "If the keyword `for:` is used after the username, looks for a duration of the form `for: 50 MINUTES` and creates an event that will remove the role after that much time has passed from now.", Optional: true},
+			{Name: "role", Desc: "The role to add, either as a ping or as the name, but must be in quotes if it has spaces.", Optional: false, Type: bot.ArgRole},
+			{Name: "user", Desc: "A ping of the user, or simply their name.", Optional: false, Type: bot.ArgUser},
+			{Name: "duration", Desc: "If the keyword `for:` is used after the username, looks for a duration of the form `for: 50 MINUTES` and creates an event that will remove the role after that much time has passed from now.", Optional: true, Type: bot.ArgDuration, Keyword: "for:"},
+		},
+	}
+}
+
+// caseSuffix tells the moderator which case number an action was recorded as, if it was recorded
+func caseSuffix(number uint64) string {
+	if number == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (Case #%v)", number)
+}
+
+type warnCommand struct {
+}
+
+func (c *warnCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "Warn",
+		Usage:     "Warns a user.",
+		Sensitive: true,
+	}
+}
+
+func (c *warnCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	user := args.User("user")
+	reason := args.String("reason")
+	number := info.AddCase(bot.CaseWarning, user, bot.DiscordUser(msg.Author.ID), reason, 0, nil)
+	if number == 0 {
+		return "```\nError: could not record the warning.```", false, nil
+	}
+	ch, err := info.DG.UserChannelCreate(user.String())
+	if err == nil {
+		err = info.SendMessage(bot.DiscordChannel(ch.ID), "You have been warned on "+info.Name+": "+reason)
+	}
+	if err != nil {
+		return fmt.Sprintf("```\nWarned %s (Case #%v), but they could not be sent a private message.```", info.GetUserName(user), number), false, nil
+	}
+	return fmt.Sprintf("```\nWarned %s (Case #%v).```", info.GetUserName(user), number), false, nil
+}
+func (c *warnCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Records a warning against the given user as a new case, and sends them the reason in a private message.",
+		Params: []bot.CommandUsageParam{
+			{Name: "user", Desc: "A ping of the user, or simply their name. If the name has spaces, this argument must be put in quotes.", Optional: false, Type: bot.ArgUser},
+			{Name: "reason", Desc: "The rest of the message is treated as the reason for the warning.", Optional: false, Type: bot.ArgRest},
+		},
+	}
+}
+
+type casesCommand struct {
+}
+
+func (c *casesCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "Cases",
+		Usage:     "Lists a user's moderation history.",
+		Sensitive: true,
+	}
+}
+
+func (c *casesCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	user := args.User("user")
+	cases := info.Bot.DB.GetUserCases(bot.SBatoi(info.ID), user.Convert(), args.Int("maxresults", 20))
+	if len(cases) == 0 {
+		return "```\n" + info.GetUserName(user) + " has no cases.```", false, nil
+	}
+	timestamp := bot.GetTimestamp(msg)
+	lines := make([]string, 0, len(cases)+1)
+	lines = append(lines, fmt.Sprintf("Cases for %s:", info.GetUserName(user)))
+	for _, v := range cases {
+		s := fmt.Sprintf("#%v %s", v.Number, bot.CaseTypeName(v.Type))
+		if v.Duration > 0 {
+			s += " for " + bot.TimeDiff(v.Duration)
+		}
+		s += fmt.Sprintf(" by %s, %s ago", info.GetUserName(bot.NewDiscordUser(v.Moderator)), bot.TimeDiff(timestamp.Sub(v.Timestamp)))
+		if len(v.Reason) > 0 {
+			s += ": " + v.Reason
+		}
+		if v.Pardoned {
+			s += " [pardoned]"
+		}
+		lines = append(lines, s)
+	}
+	return "```\n" + strings.Join(lines, "\n") + "```", len(lines) > bot.MaxPublicLines, nil
+}
+func (c *casesCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Lists the most recent cases recorded against the given user, newest first.",
+		Params: []bot.CommandUsageParam{
+			{Name: "user", Desc: "A ping of the user, or simply their name. If the name has spaces, this argument must be put in quotes.", Optional: false, Type: bot.ArgUser},
+			{Name: "maxresults", Desc: "The maximum number of cases to list. Defaults to 20.", Optional: true, Type: bot.ArgInt},
+		},
+	}
+}
+
+type caseCommand struct {
+}
+
+func (c *caseCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "Case",
+		Usage:     "Shows a single moderation case.",
+		Sensitive: true,
+	}
+}
+
+func (c *caseCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	number := args.Int("case", 0)
+	var mcase *bot.ModCase
+	if number > 0 {
+		mcase = info.Bot.DB.GetCase(bot.SBatoi(info.ID), uint64(number))
+	}
+	if mcase == nil {
+		return fmt.Sprintf("```\nError: case #%v doesn't exist.```", number), false, nil
+	}
+	return "", false, info.CaseEmbed(mcase)
+}
+func (c *caseCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Shows who a case was against, who created it, why, and how long it lasts.",
+		Params: []bot.CommandUsageParam{
+			{Name: "case", Desc: "The case number.", Optional: false, Type: bot.ArgInt},
+		},
+	}
+}
+
+type editReasonCommand struct {
+}
+
+func (c *editReasonCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "EditReason",
+		Usage:     "Changes the reason for a moderation case.",
+		Sensitive: true,
+	}
+}
+
+func (c *editReasonCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	number := args.Int("case", 0)
+	gID := bot.SBatoi(info.ID)
+	if number <= 0 || info.Bot.DB.GetCase(gID, uint64(number)) == nil {
+		return fmt.Sprintf("```\nError: case #%v doesn't exist.```", number), false, nil
+	}
+	if err := info.Bot.DB.SetCaseReason(gID, uint64(number), args.String("reason")); err != nil {
+		return bot.ReturnError(err)
+	}
+	return fmt.Sprintf("```\nUpdated the reason for case #%v.```", number), false, nil
+}
+func (c *editReasonCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Replaces the reason recorded for a case, for example to add one to a case the spam or filter modules created.",
+		Params: []bot.CommandUsageParam{
+			{Name: "case", Desc: "The case number.", Optional: false, Type: bot.ArgInt},
+			{Name: "reason", Desc: "The rest of the message is treated as the new reason.", Optional: false, Type: bot.ArgRest},
+		},
+	}
+}
+
+type pardonCommand struct {
+}
+
+func (c *pardonCommand) Info() *bot.CommandInfo {
+	return &bot.CommandInfo{
+		Name:      "Pardon",
+		Usage:     "Pardons a moderation case.",
+		Sensitive: true,
+	}
+}
+
+func (c *pardonCommand) ProcessArgs(args *bot.CommandArgs, msg *discordgo.Message, info *bot.GuildInfo) (string, bool, *discordgo.MessageEmbed) {
+	if !info.Bot.DB.CheckStatus() {
+		return "```\nA temporary database outage is preventing this command from being executed.```", false, nil
+	}
+	number := args.Int("case", 0)
+	gID := bot.SBatoi(info.ID)
+	var mcase *bot.ModCase
+	if number > 0 {
+		mcase = info.Bot.DB.GetCase(gID, uint64(number))
+	}
+	if mcase == nil {
+		return fmt.Sprintf("```\nError: case #%v doesn't exist.```", number), false, nil
+	}
+	if mcase.Pardoned {
+		return fmt.Sprintf("```\nCase #%v has already been pardoned.```", number), false, nil
+	}
+	if err := info.Bot.DB.PardonCase(gID, uint64(number)); err != nil {
+		return bot.ReturnError(err)
+	}
+	return fmt.Sprintf("```\nPardoned case #%v against %s. It will still show up in their history.```", number, info.GetUserName(bot.NewDiscordUser(mcase.User))), false, nil
+}
+func (c *pardonCommand) Usage(info *bot.GuildInfo) *bot.CommandUsage {
+	return &bot.CommandUsage{
+		Desc: "Marks a case as pardoned. This doesn't undo the action the case records, but pardoned cases are marked as such in the user's history.",
+		Params: []bot.CommandUsageParam{
+			{Name: "case", Desc: "The case number.", Optional: false, Type: bot.ArgInt},
 		},
 	}
 }
@@ -70,7 +70,7 @@ func (w *WittyModule) UpdateRegex(info *bot.GuildInfo) bool {
 	}
 
 	if len(w.triggerregex) != len(w.remarks) { // This should never happen but we check just in case
-		info.Log("ERROR! triggers do not equal remarks!!")
+		info.Logger().Error("Triggers do not equal remarks!")
 		return false
 	}
 	return err == nil